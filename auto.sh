@@ -208,6 +208,16 @@ build() {
     # 安装 Go 依赖
     install_go_deps || exit 1
     
+    # 内嵌到导出 HTML 的 ECharts（获取失败时导出的 HTML 引用 CDN）
+    if [ ! -f "pkg/chart/assets/echarts.min.js" ]; then
+        if [ -f "web/node_modules/echarts/dist/echarts.min.js" ]; then
+            cp web/node_modules/echarts/dist/echarts.min.js pkg/chart/assets/echarts.min.js
+        elif ! curl -fsSL -o pkg/chart/assets/echarts.min.js https://cdn.jsdelivr.net/npm/echarts@5.4.3/dist/echarts.min.js; then
+            rm -f pkg/chart/assets/echarts.min.js
+            print_warning "无法获取 ECharts，导出的 HTML 图表需要访问 CDN"
+        fi
+    fi
+
     # 编译后端
     print_info "编译监控程序（后端）..."
     mkdir -p bin
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/executor"
+	"pve-traffic-monitor/pkg/hook"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// executorConfig 操作执行队列配置转换为执行器配置
+func executorConfig(cfg models.ActionQueueConfig) executor.Config {
+	return executor.Config{
+		Workers:    cfg.Workers(),
+		MaxRetries: cfg.Retries(),
+		RetryDelay: cfg.RetryDelay(),
+		Retryable:  retryableAction,
+	}
+}
+
+// retryableAction 操作失败后是否值得重试：权限不足和虚拟机不存在重试也不会成功，
+// 任务或外部命令超时时操作可能仍在进行，由下个周期重新判断
+func retryableAction(err error) bool {
+	switch actionErrorKind(err) {
+	case models.ActionErrorPermission, models.ActionErrorNotFound, models.ActionErrorTimeout:
+		return false
+	}
+	return true
+}
+
+// exceededRule 本轮检查中超限的规则和触发原因
+type exceededRule struct {
+	rule   models.Rule
+	reason string
+	usage  hook.Usage
+}
+
+// submitExceeded 提交虚拟机本轮超限规则的操作：exec 规则都会执行；限制规则按冲突处理策略只执行一条，
+// 其余规则记录在这次操作的日志中（exceeded 已按优先级排序）
+func (m *Monitor) submitExceeded(vm models.VMInfo, exceeded []exceededRule, policy string, creationTime time.Time) {
+	var limits []exceededRule
+	for _, e := range exceeded {
+		if e.rule.IsRestrictive() {
+			limits = append(limits, e)
+		} else {
+			m.submitAction(vm, e.rule, e.reason, e.usage, creationTime, nil)
+		}
+	}
+	if len(limits) == 0 {
+		return
+	}
+
+	rules := make([]models.Rule, len(limits))
+	for i, e := range limits {
+		rules[i] = e.rule
+	}
+	selected := models.SelectRule(policy, rules)
+
+	var overridden []string
+	for i, e := range limits {
+		if i == selected {
+			continue
+		}
+		overridden = append(overridden, e.rule.Name)
+		debugLog("VM%d 规则 %s (%s) 与 %s (%s) 同时超限，按 %s 策略只执行后者",
+			vm.VMID, e.rule.Name, e.rule.Action, limits[selected].rule.Name, limits[selected].rule.Action, policy)
+	}
+	e := limits[selected]
+	m.submitAction(vm, e.rule, e.reason, e.usage, creationTime, overridden)
+}
+
+// submitAction 把规则判断出的操作交给执行队列（同一虚拟机的操作串行执行）
+// 同一虚拟机同一规则的操作还未完成时不重复提交，下个周期重新判断
+// overridden 为按冲突处理策略被这次操作取代的规则
+func (m *Monitor) submitAction(vm models.VMInfo, rule models.Rule, reason string, usage hook.Usage, creationTime time.Time, overridden []string) {
+	if m.actions == nil {
+		if err := m.executeAction(vm, rule, reason, usage, creationTime, overridden); err != nil {
+			log.Printf("执行操作失败: %v", err)
+		}
+		return
+	}
+
+	submitted := m.actions.Submit(executor.Job{
+		Key: vm.VMID,
+		ID:  fmt.Sprintf("%d/%s", vm.VMID, rule.Name),
+		Run: func() error {
+			// 排队或等待重试期间失去主实例身份或进入维护模式时不再执行
+			if !m.isLeader() || m.inMaintenance() {
+				return nil
+			}
+			err := m.executeAction(vm, rule, reason, usage, creationTime, overridden)
+			if err != nil {
+				log.Printf("执行操作失败: %v", err)
+			}
+			return err
+		},
+	})
+	if !submitted {
+		debugLog("VM%d 规则 %s 的操作仍在执行队列中，跳过重复提交", vm.VMID, rule.Name)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// agentPushTimeout 单次推送的超时时间
+const agentPushTimeout = 30 * time.Second
+
+// Agent 采集代理：只采集本节点的虚拟机和节点网卡流量，推送到汇总端
+// 不使用存储、不执行规则、不提供 API，汇总端不可用时在内存中缓存采样
+type Agent struct {
+	configLoader *config.Loader
+	pveClient    *pve.Client
+	httpClient   *http.Client
+	nodeCounter  pve.NodeCounter
+	protocols    protocolSampler // 客户机代理按协议的流量采集（monitor.ip_split）
+	backups      backupTracker   // 正在备份的虚拟机（monitor.backup_traffic）
+
+	// 等待推送的采样（按采集顺序，汇总端恢复后依次重发）
+	pending        []models.AgentPush
+	pendingRecords int
+	dropped        int
+}
+
+// runAgent 以采集代理模式运行
+func runAgent(configLoader *config.Loader) error {
+	cfg := configLoader.GetConfig()
+	pveClient := pve.NewClient(cfg.PVE)
+	if err := pveClient.Login(); err != nil {
+		return fmt.Errorf("登录 PVE 失败: %w", err)
+	}
+
+	agent := &Agent{
+		configLoader: configLoader,
+		pveClient:    pveClient,
+		httpClient:   &http.Client{Timeout: agentPushTimeout},
+	}
+	return agent.Start()
+}
+
+// Start 按采集间隔采集并推送，收到退出信号时返回
+func (a *Agent) Start() error {
+	cfg := a.configLoader.GetConfig()
+	interval := time.Duration(cfg.Monitor.IntervalSeconds) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watcher := config.NewWatcher(a.configLoader)
+	watcher.Start()
+	defer watcher.Stop()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	log.Printf("采集代理已启动 [节点:%s 汇总端:%s 间隔:%ds PID:%d]", cfg.PVE.Node, cfg.Agent.AggregatorURL, cfg.Monitor.IntervalSeconds, os.Getpid())
+
+	a.collectAndPush()
+	for {
+		select {
+		case <-ticker.C:
+			a.collectAndPush()
+
+			// 配置重载修改了采集间隔时重建 ticker
+			if next := time.Duration(a.configLoader.GetConfig().Monitor.IntervalSeconds) * time.Second; next != interval {
+				interval = next
+				ticker.Reset(interval)
+				log.Printf("监控间隔已更新为: %v\n", interval)
+			}
+		case <-sigChan:
+			if a.pendingRecords > 0 {
+				log.Printf("正在退出，%d 条采样尚未推送到汇总端", a.pendingRecords)
+			}
+			log.Println("已退出")
+			return nil
+		}
+	}
+}
+
+// collectAndPush 采集一次并推送所有待推送的采样
+func (a *Agent) collectAndPush() {
+	push, err := a.collect()
+	if err != nil {
+		log.Printf("错误: %v\n", err)
+	} else {
+		a.enqueue(push)
+	}
+	a.flush()
+}
+
+// collect 采集本节点的虚拟机和节点网卡流量
+func (a *Agent) collect() (models.AgentPush, error) {
+	cfg := a.configLoader.GetConfig()
+	vms, err := a.pveClient.GetAllVMsWithFilter(cfg.Monitor.ListsTemplates())
+	if err != nil {
+		return models.AgentPush{}, fmt.Errorf("获取虚拟机列表失败: %w", err)
+	}
+
+	push := models.AgentPush{Node: cfg.PVE.Node, VMs: vms}
+	if cfg.Monitor.DetectsBackups() {
+		a.backups.refresh(a.pveClient, vms)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	vmChan := make(chan models.VMInfo, len(vms))
+	for i := 0; i < models.MaxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vm := range vmChan {
+				record, err := sampleVM(a.pveClient, vm.VMID, cfg.Monitor.Bridges)
+				if err != nil {
+					log.Printf("虚拟机 %d 处理失败: %v", vm.VMID, err)
+					continue
+				}
+				if cfg.Monitor.IPSplit {
+					a.protocols.sample(a.pveClient, vm, &record)
+				}
+				if cfg.Monitor.DetectsBackups() {
+					a.backups.mark(&record)
+				}
+				mu.Lock()
+				push.Records = append(push.Records, record)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, vm := range vms {
+		vmChan <- vm
+	}
+	close(vmChan)
+	wg.Wait()
+
+	if cfg.Monitor.NodeStatsEnabled() {
+		points, err := a.pveClient.GetNodeNetRRD()
+		if err != nil {
+			debugLog("获取节点 %s 网络流量失败: %v", cfg.PVE.Node, err)
+		} else {
+			push.NodeRecords = a.nodeCounter.Add(points)
+		}
+	}
+	return push, nil
+}
+
+// enqueue 加入待推送队列，超过 agent.max_pending 时丢弃最早的采样
+func (a *Agent) enqueue(push models.AgentPush) {
+	a.pending = append(a.pending, push)
+	a.pendingRecords += len(push.Records)
+
+	limit := a.configLoader.GetConfig().Agent.MaxPendingRecords()
+	for a.pendingRecords > limit && len(a.pending) > 1 {
+		a.pendingRecords -= len(a.pending[0].Records)
+		a.dropped += len(a.pending[0].Records)
+		a.pending = a.pending[1:]
+	}
+	if a.dropped > 0 {
+		log.Printf("汇总端长时间不可用，待推送的采样超过 %d 条，已丢弃最早的 %d 条", limit, a.dropped)
+		a.dropped = 0
+	}
+}
+
+// flush 按采集顺序推送，遇到失败时停止，下次采集后重试
+func (a *Agent) flush() {
+	for len(a.pending) > 0 {
+		push := a.pending[0]
+		push.SentAt = time.Now()
+
+		err := a.send(push)
+		if err != nil {
+			var rejected *pushRejectedError
+			if !errors.As(err, &rejected) {
+				log.Printf("推送到汇总端失败，%d 条采样等待重试: %v", a.pendingRecords, err)
+				return
+			}
+			// 汇总端拒绝的数据重试也不会成功，丢弃以免阻塞后续采样
+			log.Printf("汇总端拒绝了 %s 采集的 %d 条采样: %v", push.Node, len(push.Records), err)
+		}
+
+		a.pendingRecords -= len(push.Records)
+		a.pending[0] = models.AgentPush{}
+		a.pending = a.pending[1:]
+	}
+}
+
+// pushRejectedError 汇总端拒绝了推送的数据（HTTP 400 或 413），重试也不会成功
+type pushRejectedError struct {
+	message string
+}
+
+func (e *pushRejectedError) Error() string {
+	return e.message
+}
+
+// send 推送一批采样到汇总端的 /api/agent/push
+func (a *Agent) send(push models.AgentPush) error {
+	cfg := a.configLoader.GetConfig().Agent
+	body, err := json.Marshal(push)
+	if err != nil {
+		return fmt.Errorf("序列化推送数据失败: %w", err)
+	}
+
+	url := strings.TrimRight(cfg.AggregatorURL, "/") + "/api/agent/push"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	message := fmt.Sprintf("HTTP %d %s", resp.StatusCode, result.Error)
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return &pushRejectedError{message: message}
+	}
+	return fmt.Errorf("%s", message)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/ipc"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// remoteVMTTL 代理推送的虚拟机多久没有更新后从虚拟机列表中移除（代理停止、虚拟机删除或迁移）
+const remoteVMTTL = 10 * time.Minute
+
+// remoteInventory 汇总端记录的代理节点上的虚拟机
+type remoteInventory struct {
+	mu  sync.RWMutex
+	vms map[int]remoteVM
+}
+
+// remoteVM 代理最近一次推送的虚拟机信息
+type remoteVM struct {
+	info models.VMInfo
+	seen time.Time
+}
+
+// update 记录代理推送的虚拟机（VMID 在集群内唯一，迁移后以最新推送的节点为准），返回新出现的虚拟机
+func (inv *remoteInventory) update(node string, vms []models.VMInfo, now time.Time) []int {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.vms == nil {
+		inv.vms = make(map[int]remoteVM)
+	}
+	var added []int
+	for _, vm := range vms {
+		if _, ok := inv.vms[vm.VMID]; !ok {
+			added = append(added, vm.VMID)
+		}
+		vm.Node = node
+		inv.vms[vm.VMID] = remoteVM{info: vm, seen: now}
+	}
+	for vmid, vm := range inv.vms {
+		if now.Sub(vm.seen) > remoteVMTTL {
+			delete(inv.vms, vmid)
+		}
+	}
+	return added
+}
+
+// node 虚拟机所在的代理节点
+func (inv *remoteInventory) node(vmid int) (string, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	vm, ok := inv.vms[vmid]
+	if !ok || time.Since(vm.seen) > remoteVMTTL {
+		return "", false
+	}
+	return vm.info.Node, true
+}
+
+// list 代理节点上的虚拟机（按 VMID 排序）
+func (inv *remoteInventory) list(includeTemplates bool) []models.VMInfo {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	vms := make([]models.VMInfo, 0, len(inv.vms))
+	for _, vm := range inv.vms {
+		if time.Since(vm.seen) > remoteVMTTL || (!includeTemplates && vm.info.IsTemplate()) {
+			continue
+		}
+		vms = append(vms, vm.info)
+	}
+	sort.Slice(vms, func(i, j int) bool {
+		return vms[i].VMID < vms[j].VMID
+	})
+	return vms
+}
+
+// pveFor 虚拟机所在节点的 PVE 客户端：本节点和未知的虚拟机使用主客户端，
+// 代理节点上的虚拟机使用同一 PVE 连接访问该节点（要求汇总端连接的 PVE 与代理节点在同一集群）
+func (m *Monitor) pveFor(vmid int) *pve.Client {
+	node, ok := m.remote.node(vmid)
+	if !ok || node == m.pveConfig.Node {
+		return m.pveClient
+	}
+
+	m.nodeClientsMu.Lock()
+	defer m.nodeClientsMu.Unlock()
+	cached := m.nodeClients[node]
+	if cached.client != nil && cached.base == m.pveClient {
+		return cached.client
+	}
+
+	cfg := m.pveConfig
+	cfg.Node = node
+	client := pve.NewClient(cfg)
+	if err := client.Login(); err != nil {
+		log.Printf("登录节点 %s 失败: %v", node, err)
+	}
+	if m.nodeClients == nil {
+		m.nodeClients = make(map[string]nodeClient)
+	}
+	m.nodeClients[node] = nodeClient{client: client, base: m.pveClient}
+	return client
+}
+
+// nodeClient 访问代理节点的客户端（主客户端因配置重载重建后随之重建）
+type nodeClient struct {
+	client *pve.Client
+	base   *pve.Client
+}
+
+// allVMs 本节点和代理节点上的所有虚拟机
+func (m *Monitor) allVMs(includeTemplates bool) ([]models.VMInfo, error) {
+	vms, err := m.pveClient.GetAllVMsWithFilter(includeTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return mergeVMs(vms, m.remote.list(includeTemplates)), nil
+}
+
+// mergeVMs 合并本节点和代理节点的虚拟机（本节点的信息优先）
+func mergeVMs(local, remote []models.VMInfo) []models.VMInfo {
+	if len(remote) == 0 {
+		return local
+	}
+	seen := make(map[int]bool, len(local))
+	for _, vm := range local {
+		seen[vm.VMID] = true
+	}
+	for _, vm := range remote {
+		if !seen[vm.VMID] {
+			local = append(local, vm)
+		}
+	}
+	return local
+}
+
+// handleAgentPush 汇总端处理代理推送：保存采样后在后台对推送的虚拟机执行规则
+func (m *Monitor) handleAgentPush(push models.AgentPush) error {
+	if push.Node == "" {
+		return fmt.Errorf("缺少节点名称")
+	}
+	known := make(map[int]bool, len(push.VMs))
+	for _, vm := range push.VMs {
+		if vm.VMID <= 0 {
+			return fmt.Errorf("虚拟机 ID 无效: %d", vm.VMID)
+		}
+		known[vm.VMID] = true
+	}
+	for _, record := range push.Records {
+		if !known[record.VMID] || record.Timestamp.IsZero() {
+			return fmt.Errorf("流量记录无效: VM%d %s", record.VMID, record.Timestamp)
+		}
+	}
+
+	// 主备模式下接管代理节点上的虚拟机时加载之前的主实例记录的恢复状态
+	added := m.remote.update(push.Node, push.VMs, time.Now())
+	if len(added) > 0 && m.configLoader.GetConfig().Monitor.HA.Enabled && m.isLeader() {
+		if loaded := m.recoveryManager.LoadStates(added); loaded > 0 {
+			log.Printf("已加载节点 %s 上 %d 个虚拟机的恢复记录", push.Node, loaded)
+		}
+	}
+
+	if err := m.stats.SaveTrafficRecords(push.Records); err != nil {
+		return fmt.Errorf("保存流量记录失败: %w", err)
+	}
+	for _, record := range push.Records {
+		m.sampleCollected(record.VMID, record.Timestamp)
+	}
+	for _, record := range push.NodeRecords {
+		if err := storage.SaveNodeTrafficRecord(m.storage, push.Node, record); err != nil && err != storage.ErrNodeRecordsUnsupported {
+			return fmt.Errorf("保存节点流量记录失败: %w", err)
+		}
+	}
+	debugLog("收到节点 %s 的推送: %d 台虚拟机, %d 条记录, %d 条节点记录", push.Node, len(push.VMs), len(push.Records), len(push.NodeRecords))
+
+	if len(push.Records) > 0 {
+		m.queueRules(push.VMs, "节点 "+push.Node)
+	}
+	return nil
+}
+
+// handleInventoryQuery 回复 inventory 查询：代理节点上的虚拟机（命令行清除状态时使用）
+func (m *Monitor) handleInventoryQuery(msg ipc.Message) map[string]interface{} {
+	vmids := []int{}
+	for _, vm := range m.remote.list(true) {
+		vmids = append(vmids, vm.VMID)
+	}
+	return map[string]interface{}{"vmids": vmids}
+}
+
+// queryRemoteVMIDs 命令行模式下向运行中的汇总端查询代理节点上的虚拟机
+func (m *Monitor) queryRemoteVMIDs() ([]int, error) {
+	socketPath := ipc.GetDefaultSocketPath(ipcBasePath(m.configLoader.GetConfig()))
+	reply, err := ipc.NewClient(socketPath).Query(ipc.Message{Type: "inventory", Timestamp: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.Data["vmids"].([]interface{})
+	vmids := make([]int, 0, len(items))
+	for _, item := range items {
+		vmids = append(vmids, int(toInt64(item)))
+	}
+	return vmids, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/archive"
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/objstore"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// archiveMonth archive run/restore 的月份
+var archiveMonth = flag.String("month", "", "archive run/restore 的月份 (格式: 2006-01, run 未指定时归档所有待归档的月份)")
+
+// newArchiver 按配置创建归档器
+func newArchiver(cfg *models.Config, store storage.Interface) (*archive.Archiver, error) {
+	if !cfg.Archive.Enabled() {
+		return nil, i18n.Errorf("cli.archive_disabled")
+	}
+	if err := cfg.Archive.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := objstore.NewS3Client(objstore.S3Options{
+		Endpoint:  cfg.Archive.Endpoint,
+		Region:    cfg.Archive.SigningRegion(),
+		Bucket:    cfg.Archive.Bucket,
+		AccessKey: cfg.Archive.AccessKey,
+		SecretKey: cfg.Archive.SecretKey,
+		PathStyle: cfg.Archive.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archive.New(store, client, cfg.Archive.KeyPrefix(), periodcalc.Location()), nil
+}
+
+// archivePending 归档所有待归档的月份（计划清理时执行，先于按保留期清理）
+func (m *Monitor) archivePending(result *models.CleanupResult) {
+	cfg := m.configLoader.GetConfig()
+	archiver, err := newArchiver(cfg, m.storage)
+	if err != nil {
+		log.Printf("创建归档器失败: %v", err)
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	months, err := archiver.PendingMonths(time.Now(), cfg.Archive.LocalMonths())
+	if err != nil {
+		log.Printf("查询待归档月份失败: %v", err)
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	for _, month := range months {
+		archived, err := archiver.ArchiveMonth(context.Background(), month, true)
+		if err != nil {
+			log.Printf("归档 %s 失败: %v", month.Format(archive.MonthLayout), err)
+			result.Errors = append(result.Errors, err.Error())
+			// 之后的月份依赖同一对象存储，一般也会失败
+			return
+		}
+		log.Printf("已归档 %s: %d 台虚拟机 %d 条记录 (%d 字节) -> %s，本地删除 %d 条",
+			archived.Month, archived.VMs, archived.Records, archived.Bytes, archived.Key, archived.Pruned)
+		if len(archived.Kept) > 0 {
+			log.Printf("归档 %s 期间以下虚拟机有新记录写入，本地记录保留到下次归档: %v", archived.Month, archived.Kept)
+		}
+		result.Archived = append(result.Archived, archived.Month)
+	}
+	if len(result.Archived) > 0 {
+		m.stats.InvalidateAll()
+	}
+}
+
+// runArchiveCommand 归档、列出或恢复已结束月份的流量记录（monitor archive run|list|restore），不需要连接 PVE
+func runArchiveCommand(args []string) error {
+	if len(args) == 0 {
+		return i18n.Errorf("cli.archive_usage")
+	}
+	action := args[0]
+	if action != "run" && action != "list" && action != "restore" {
+		return i18n.Errorf("cli.archive_usage")
+	}
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+	applyPeriodConfig(cfg)
+
+	store, err := storage.NewStorageFromConfig(&cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("创建存储管理器失败: %w", err)
+	}
+	defer store.Close()
+
+	archiver, err := newArchiver(cfg, store)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "list":
+		objects, err := archiver.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			log.Println(i18n.T("cli.archive_item", object.Key, object.Size, object.LastModified.Format("2006-01-02 15:04:05")))
+		}
+		log.Println(i18n.T("cli.archive_count", len(objects)))
+		setResult("archives", objects)
+		return nil
+	case "restore":
+		return restoreArchive(ctx, cfg, store, archiver)
+	default:
+		return runArchive(ctx, cfg, store, archiver)
+	}
+}
+
+// runArchive 归档 -month 指定的月份（必须已结束），未指定时归档所有待归档的月份
+func runArchive(ctx context.Context, cfg *models.Config, store storage.Interface, archiver *archive.Archiver) error {
+	now := time.Now()
+	var months []time.Time
+	if *archiveMonth != "" {
+		month, err := archiver.ParseMonth(*archiveMonth)
+		if err != nil {
+			return err
+		}
+		if !month.AddDate(0, 1, 0).Before(now) {
+			return i18n.Errorf("cli.archive_month_open", *archiveMonth)
+		}
+		months = []time.Time{month}
+	} else {
+		var err error
+		if months, err = archiver.PendingMonths(now, cfg.Archive.LocalMonths()); err != nil {
+			return err
+		}
+	}
+	if len(months) == 0 {
+		log.Println(i18n.T("cli.archive_none"))
+		return nil
+	}
+
+	var results []archive.MonthResult
+	var pruned int64
+	for _, month := range months {
+		if *dryRun {
+			count, err := store.CountRecordsInRange(0, month, month.AddDate(0, 1, 0).Add(-time.Nanosecond))
+			if err != nil {
+				return fmt.Errorf("%s: %w", i18n.T("cli.count_failed"), err)
+			}
+			log.Println(i18n.T("cli.archive_dry_run", month.Format(archive.MonthLayout), count, archiver.Key(month)))
+			results = append(results, archive.MonthResult{Month: month.Format(archive.MonthLayout), Key: archiver.Key(month), Records: int(count)})
+			continue
+		}
+
+		result, err := archiver.ArchiveMonth(ctx, month, true)
+		if err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.archived", result.Month, result.VMs, result.Records, result.Bytes, result.Key, result.Pruned))
+		if len(result.Kept) > 0 {
+			log.Println(i18n.T("cli.archive_kept", result.Kept))
+		}
+		results = append(results, result)
+		pruned += result.Pruned
+	}
+	setResult("months", results)
+	setResult("dry_run", *dryRun)
+
+	if pruned > 0 {
+		notifyProgram(cfg, "reload_cache", map[string]interface{}{"reason": "archive"})
+	}
+	return nil
+}
+
+// restoreArchive 把 -month 的归档写回本地存储（-vmid 只恢复一台虚拟机）
+func restoreArchive(ctx context.Context, cfg *models.Config, store storage.Interface, archiver *archive.Archiver) error {
+	if *archiveMonth == "" {
+		return i18n.Errorf("cli.archive_restore_requires")
+	}
+	month, err := archiver.ParseMonth(*archiveMonth)
+	if err != nil {
+		return err
+	}
+
+	result, err := archiver.Restore(ctx, month, *vmID, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(result.Skipped) > 0 {
+		log.Println(i18n.T("cli.archive_restore_skipped", result.Skipped))
+	}
+	if *dryRun {
+		log.Println(i18n.T("cli.archive_restore_dry_run", result.Records, result.VMs, result.Month))
+	} else {
+		log.Println(i18n.T("cli.archive_restored", result.Records, result.VMs, result.Month))
+	}
+	setResult("restore", result)
+	setResult("dry_run", *dryRun)
+
+	if !*dryRun && result.Records > 0 {
+		notifyProgram(cfg, "reload_cache", map[string]interface{}{"reason": "archive_restore", "vmid": *vmID})
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// backupTracker 记录正在备份（vzdump）的虚拟机，标记备份期间采集的流量记录（monitor.backup_traffic）
+type backupTracker struct {
+	mu       sync.Mutex
+	active   map[int]bool // 本次采集周期正在备份的虚拟机
+	previous map[int]bool // 上个采集周期正在备份的虚拟机（备份在两次采样之间结束时，本次的增量仍包含备份流量）
+}
+
+// refresh 采集周期开始时按运行中的 vzdump 任务和 backup 配置锁更新正在备份的虚拟机
+// 查询任务失败时只按配置锁判断
+func (b *backupTracker) refresh(client *pve.Client, vms []models.VMInfo) {
+	tasks, err := client.GetActiveBackups()
+	if err != nil {
+		debugLog("获取运行中的备份任务失败，只按配置锁判断: %v", err)
+	}
+	active := make(map[int]bool)
+	for _, vm := range vms {
+		if pve.BackingUp(vm, tasks) {
+			active[vm.VMID] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for vmid := range active {
+		if !b.active[vmid] {
+			debugLog("VM%d 正在备份，标记备份期间的流量记录", vmid)
+		}
+	}
+	b.previous, b.active = b.active, active
+}
+
+// mark 虚拟机本周期或上个周期正在备份时标记流量记录
+func (b *backupTracker) mark(record *models.TrafficRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	record.Backup = b.active[record.VMID] || b.previous[record.VMID]
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// benchVMIDBase 基准测试生成的虚拟机 ID 从此开始（远大于 PVE 默认分配的 ID，避免与实际虚拟机混在一起）
+const benchVMIDBase = 900000
+
+// 基准测试参数的上限
+const (
+	maxBenchVMs     = 10000
+	maxBenchDays    = 3650
+	maxBenchWorkers = 64
+)
+
+// bench 命令参数
+var (
+	benchVMs     = flag.Int("vms", 100, "bench 生成的虚拟机数量")
+	benchDays    = flag.Int("days", 30, "bench 生成的天数（截止到当前时间）")
+	benchStep    = flag.Duration("step", time.Hour, "bench 生成的记录间隔（实际采集间隔更短时记录数成倍增加）")
+	benchWorkers = flag.Int("workers", 8, "bench 同时读写存储的协程数")
+	benchKeep    = flag.Bool("keep", false, "bench 结束后保留生成的数据（默认删除）")
+)
+
+// benchOptions 基准测试参数
+type benchOptions struct {
+	VMs     int
+	Days    int
+	Step    time.Duration
+	Workers int
+	Keep    bool
+	End     time.Time
+}
+
+// benchPhase 一个阶段的结果
+type benchPhase struct {
+	Name          string  `json:"name"`
+	Ops           int64   `json:"ops"`     // 调用存储的次数
+	Records       int64   `json:"records"` // 写入、读取或删除的记录数
+	Seconds       float64 `json:"seconds"`
+	OpsPerSec     float64 `json:"ops_per_sec"`
+	RecordsPerSec float64 `json:"records_per_sec"`
+	Errors        int64   `json:"errors"`
+	FirstError    string  `json:"first_error,omitempty"`
+}
+
+// benchReport 基准测试结果
+type benchReport struct {
+	Storage string       `json:"storage"`
+	VMs     int          `json:"vms"`
+	Days    int          `json:"days"`
+	Step    string       `json:"step"`
+	Workers int          `json:"workers"`
+	Records int64        `json:"records"` // 生成的记录数
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Phases  []benchPhase `json:"phases"`
+}
+
+// validate 检查基准测试参数
+func (o benchOptions) validate() error {
+	switch {
+	case o.VMs < 1 || o.VMs > maxBenchVMs:
+		return i18n.Errorf("cli.bench_invalid", fmt.Sprintf("-vms 1-%d", maxBenchVMs))
+	case o.Days < 1 || o.Days > maxBenchDays:
+		return i18n.Errorf("cli.bench_invalid", fmt.Sprintf("-days 1-%d", maxBenchDays))
+	case o.Step < time.Second:
+		return i18n.Errorf("cli.bench_invalid", "-step >= 1s")
+	case o.Workers < 1 || o.Workers > maxBenchWorkers:
+		return i18n.Errorf("cli.bench_invalid", fmt.Sprintf("-workers 1-%d", maxBenchWorkers))
+	}
+	return nil
+}
+
+// vmids 生成数据使用的虚拟机 ID
+func (o benchOptions) vmids() []int {
+	vmids := make([]int, o.VMs)
+	for i := range vmids {
+		vmids[i] = benchVMIDBase + i
+	}
+	return vmids
+}
+
+// start 生成数据的开始时间
+func (o benchOptions) start() time.Time {
+	return o.End.AddDate(0, 0, -o.Days)
+}
+
+// runBenchCommand 处理 bench 子命令：向配置的存储写入合成的流量记录，报告写入、读取和统计的吞吐量
+func runBenchCommand(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+	applyPeriodConfig(cfg)
+
+	opts := benchOptions{
+		VMs:     *benchVMs,
+		Days:    *benchDays,
+		Step:    *benchStep,
+		Workers: *benchWorkers,
+		Keep:    *benchKeep,
+		End:     time.Now().Truncate(time.Minute),
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	store, err := storage.NewStorageFromConfig(&cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("创建存储管理器失败: %w", err)
+	}
+	defer store.Close()
+
+	report, err := runBench(store, cfg.Storage.Type, opts)
+	if err != nil {
+		return err
+	}
+
+	if *exportFormat == "json" || *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printBenchReport(report)
+	if opts.Keep {
+		log.Println(i18n.T("cli.bench_kept", benchVMIDBase, benchVMIDBase+opts.VMs-1))
+	}
+	return nil
+}
+
+// runBench 依次执行写入、读取、统计和删除（-keep 时不删除）阶段
+func runBench(store storage.Interface, storageType string, opts benchOptions) (benchReport, error) {
+	vmids := opts.vmids()
+	start, end := opts.start(), opts.End
+	perVM := int64(end.Sub(start) / opts.Step)
+
+	// 结束时删除的范围内不能有已有的数据，避免删除实际记录
+	from, to := start.Add(-opts.Step), end.Add(opts.Step)
+	for _, vmid := range vmids {
+		count, err := store.CountRecordsInRange(vmid, from, to)
+		if err != nil {
+			return benchReport{}, err
+		}
+		if count > 0 {
+			return benchReport{}, i18n.Errorf("cli.bench_exists", vmid, vmids[0], vmids[len(vmids)-1])
+		}
+	}
+
+	report := benchReport{
+		Storage: storageType,
+		VMs:     opts.VMs,
+		Days:    opts.Days,
+		Step:    opts.Step.String(),
+		Workers: opts.Workers,
+		Records: perVM * int64(len(vmids)),
+		Start:   start,
+		End:     end,
+	}
+	log.Println(i18n.T("cli.bench_start", opts.VMs, opts.Days, opts.Step, report.Records, storageType, opts.Workers))
+
+	report.Phases = append(report.Phases, benchRun("write", vmids, opts.Workers, func(vmid int) (int64, int64, error) {
+		var written int64
+		for _, record := range benchRecords(vmid, start, opts.Step, perVM) {
+			if err := store.SaveTrafficRecord(record); err != nil {
+				return written, written + 1, err
+			}
+			written++
+		}
+		return written, written, nil
+	}))
+	report.Phases = append(report.Phases, benchRun("read_day", vmids, opts.Workers, func(vmid int) (int64, int64, error) {
+		records, err := store.GetTrafficRecords(vmid, end.Add(-24*time.Hour), end)
+		return int64(len(records)), 1, err
+	}))
+	report.Phases = append(report.Phases, benchRun("read_all", vmids, opts.Workers, func(vmid int) (int64, int64, error) {
+		records, err := store.GetTrafficRecords(vmid, start, end)
+		return int64(len(records)), 1, err
+	}))
+	report.Phases = append(report.Phases, benchRun("aggregate", vmids, opts.Workers, func(vmid int) (int64, int64, error) {
+		_, err := store.CalculateTrafficStatsWithTimeRange(vmid, start, end, "both")
+		return 0, 1, err
+	}))
+	if !opts.Keep {
+		report.Phases = append(report.Phases, benchRun("delete", vmids, opts.Workers, func(vmid int) (int64, int64, error) {
+			deleted, err := store.DeleteRecordsInRange(vmid, from, to)
+			return deleted, 1, err
+		}))
+	}
+	return report, nil
+}
+
+// benchRun 用 workers 个协程对每台虚拟机执行 fn（返回处理的记录数和调用存储的次数），统计吞吐量
+func benchRun(name string, vmids []int, workers int, fn func(vmid int) (records, ops int64, err error)) benchPhase {
+	phase := benchPhase{Name: name}
+	var records, ops, errCount atomic.Int64
+	var firstErr sync.Once
+
+	queue := make(chan int, len(vmids))
+	for _, vmid := range vmids {
+		queue <- vmid
+	}
+	close(queue)
+
+	began := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vmid := range queue {
+				n, calls, err := fn(vmid)
+				records.Add(n)
+				ops.Add(calls)
+				if err != nil {
+					errCount.Add(1)
+					firstErr.Do(func() { phase.FirstError = fmt.Sprintf("VM%d: %v", vmid, err) })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	phase.Ops = ops.Load()
+	phase.Records = records.Load()
+	phase.Errors = errCount.Load()
+	phase.Seconds = elapsed.Seconds()
+	if phase.Seconds > 0 {
+		phase.OpsPerSec = float64(phase.Ops) / phase.Seconds
+		phase.RecordsPerSec = float64(phase.Records) / phase.Seconds
+	}
+	return phase
+}
+
+// benchRecords 生成一台虚拟机的累计流量记录：每台虚拟机的平均带宽不同，按一天中的时间起伏并带随机波动
+// 使用 VMID 作为随机种子，相同参数生成的数据相同
+func benchRecords(vmid int, start time.Time, step time.Duration, count int64) []models.TrafficRecord {
+	rng := rand.New(rand.NewSource(int64(vmid)))
+	mbps := 0.1 + rng.Float64()*rng.Float64()*50 // 多数虚拟机流量较小，少数较大
+	bytesPerStep := mbps * 1e6 / 8 * step.Seconds()
+
+	records := make([]models.TrafficRecord, 0, count)
+	var rx, tx, diskRead, diskWrite uint64
+	for i := int64(1); i <= count; i++ {
+		ts := start.Add(time.Duration(i) * step)
+		hour := float64(ts.Hour()) + float64(ts.Minute())/60
+		daily := 1 + 0.6*math.Sin((hour-14)/24*2*math.Pi+math.Pi/2) // 14 点左右最高
+		delta := bytesPerStep * daily * (0.5 + rng.Float64())
+
+		rx += uint64(delta * 0.7)
+		tx += uint64(delta * 0.3)
+		diskRead += uint64(delta * 0.2 * rng.Float64())
+		diskWrite += uint64(delta * 0.1 * rng.Float64())
+		records = append(records, models.TrafficRecord{
+			VMID:       vmid,
+			Timestamp:  ts,
+			RXBytes:    rx,
+			TXBytes:    tx,
+			TotalBytes: rx + tx,
+			DiskRead:   diskRead,
+			DiskWrite:  diskWrite,
+		})
+	}
+	return records
+}
+
+// printBenchReport 以表格形式输出基准测试结果
+func printBenchReport(report benchReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, i18n.T("cli.bench_header"))
+	for _, phase := range report.Phases {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%.0f\t%.0f\t%d\t\n",
+			i18n.T("cli.bench_phase_"+phase.Name), phase.Ops, phase.Records, phase.Seconds, phase.OpsPerSec, phase.RecordsPerSec, phase.Errors)
+	}
+	w.Flush()
+
+	for _, phase := range report.Phases {
+		if phase.FirstError != "" {
+			log.Println(i18n.T("cli.bench_error", i18n.T("cli.bench_phase_"+phase.Name), phase.Errors, phase.FirstError))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// dataCleaner 当前存储的操作日志和状态清除接口
+func (m *Monitor) dataCleaner() (storage.DataCleaner, error) {
+	cleaner, ok := storage.As[storage.DataCleaner](m.storage)
+	if !ok {
+		return nil, i18n.Errorf("cli.cleanup_unsupported")
+	}
+	return cleaner, nil
+}
+
+// cleanupLogs 清除操作日志（-before 之前、-date 某天或 -start/-end 时间段，可用 -vmid 只清除某台虚拟机）
+func (m *Monitor) cleanupLogs() error {
+	cleaner, err := m.dataCleaner()
+	if err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	switch {
+	case *beforeDate != "":
+		date, err := time.ParseInLocation("2006-01-02", *beforeDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
+		}
+		end = date
+	case *exportDate != "":
+		date, err := time.ParseInLocation("2006-01-02", *exportDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
+		}
+		start, end = dayBounds(date)
+		end = end.Add(time.Nanosecond)
+	case *startTime != "" && *endTime != "":
+		if start, err = m.parseTimeParam(*startTime); err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
+		}
+		if end, err = m.parseTimeParam(*endTime); err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
+		}
+		if start.After(end) {
+			return i18n.Errorf("cli.start_after_end")
+		}
+		// 与 -cleanup range 相同，包含结束时间
+		end = end.Add(time.Nanosecond)
+	default:
+		return i18n.Errorf("cli.cleanup_logs_requires")
+	}
+
+	from := "-"
+	if !start.IsZero() {
+		from = start.Format("2006-01-02 15:04:05")
+	}
+	log.Println(i18n.T("cli.cleanup_logs_prepare", from, end.Format("2006-01-02 15:04:05"), vmScope(*vmID)))
+
+	count, err := cleaner.DeleteActionLogs(*vmID, start, end, *dryRun)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.delete_failed"), err)
+	}
+	if *dryRun {
+		log.Println(i18n.T("cli.dry_run_delete_logs", count))
+		setResult("count", count)
+	} else {
+		log.Println(i18n.T("cli.deleted_logs", count))
+		setResult("deleted", count)
+	}
+	return nil
+}
+
+// cleanupStates 清除虚拟机状态（恢复计划、创建时间覆盖等）
+// -vmid 清除指定虚拟机的状态；-before 清除 PVE 中已不存在、且此日期之后未更新的虚拟机的状态
+func (m *Monitor) cleanupStates() error {
+	cleaner, err := m.dataCleaner()
+	if err != nil {
+		return err
+	}
+	if *vmID == 0 && *beforeDate == "" {
+		return i18n.Errorf("cli.cleanup_states_requires")
+	}
+
+	match := func(vmid int, updatedAt time.Time) bool {
+		return vmid == *vmID
+	}
+	if *beforeDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", *beforeDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
+		}
+
+		// 只清除已删除的虚拟机，仍存在的虚拟机的状态可能包含待恢复的限制
+		vms, err := m.pveClient.GetAllVMsWithFilter(true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
+		}
+		existing := make(map[int]bool, len(vms))
+		for _, vm := range vms {
+			existing[vm.VMID] = true
+		}
+
+		// 汇总端的虚拟机列表还包括代理节点上的虚拟机，只有运行中的主程序知道
+		if m.configLoader.GetConfig().RunMode() == models.ModeAggregator {
+			remote, err := m.queryRemoteVMIDs()
+			if err != nil {
+				return i18n.Errorf("cli.cleanup_states_remote", err)
+			}
+			for _, vmid := range remote {
+				existing[vmid] = true
+			}
+		}
+		stale := storage.StaleStates(existing, date)
+		match = func(vmid int, updatedAt time.Time) bool {
+			return (*vmID == 0 || vmid == *vmID) && stale(vmid, updatedAt)
+		}
+		log.Println(i18n.T("cli.cleanup_states_prepare", date.Format("2006-01-02"), vmScope(*vmID)))
+	} else {
+		log.Println(i18n.T("cli.cleanup_vm_state", *vmID))
+	}
+
+	count, err := cleaner.DeleteVMStates(match, *dryRun)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.delete_failed"), err)
+	}
+	if *dryRun {
+		log.Println(i18n.T("cli.dry_run_delete_states", count))
+		setResult("count", count)
+	} else {
+		log.Println(i18n.T("cli.deleted_states", count))
+		setResult("deleted", count)
+	}
+	return nil
+}
+
+// vmScope 清除范围的说明（vmid=0 表示所有虚拟机）
+func vmScope(vmid int) string {
+	if vmid == 0 {
+		return i18n.T("cli.all_vms")
+	}
+	return fmt.Sprintf("VM%d", vmid)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+)
+
+// runConfigCommand 处理 config 子命令（只读取配置，不连接 PVE、不启动监控）
+//
+//	config validate             检查配置，输出错误（带文件和行号）和可疑配置的警告
+//	config show [-effective]    输出解析后的配置（-effective 填充默认值），令牌和密码会被隐藏
+func runConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return i18n.Errorf("cli.config_usage")
+	}
+	// 子命令之后的参数（如 -effective、-config）
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		return err
+	}
+	i18n.SetLocale(*langFlag)
+
+	report := config.Check(*configPath)
+	if *langFlag == "" && report.Config != nil {
+		i18n.SetLocale(report.Config.Locale)
+	}
+	setResult("files", report.Files)
+	setResult("errors", issueList(report.Errors))
+	setResult("warnings", issueList(report.Warnings))
+	setResult("valid", report.Valid())
+
+	switch args[0] {
+	case "validate":
+		log.Println(i18n.T("cli.config_files", strings.Join(report.Files, ", ")))
+		printIssues(report)
+		if !report.Valid() {
+			return i18n.Errorf("cli.config_invalid", len(report.Errors))
+		}
+		log.Println(i18n.T("cli.config_valid", len(report.Config.Rules), len(report.Warnings)))
+		setResult("rules", len(report.Config.Rules))
+		return nil
+
+	case "show":
+		printIssues(report)
+		if !report.Valid() {
+			return i18n.Errorf("cli.config_invalid", len(report.Errors))
+		}
+		cfg := *report.Config
+		if *showEffective {
+			cfg = cfg.Effective()
+		}
+		data, err := json.MarshalIndent(config.Redact(cfg), "", "  ")
+		if err != nil {
+			return err
+		}
+		// 配置输出到标准输出，错误和警告输出到标准错误，便于重定向保存
+		// 输出本身就是 JSON，-json 时不再附加检查结果
+		fmt.Println(string(data))
+		cliResult = map[string]interface{}{}
+		return nil
+
+	default:
+		return i18n.Errorf("cli.config_usage")
+	}
+}
+
+// issueList JSON 输出的问题列表（没有问题时为空数组而不是 null）
+func issueList(issues []config.Issue) []config.Issue {
+	if issues == nil {
+		return []config.Issue{}
+	}
+	return issues
+}
+
+// printIssues 输出配置检查发现的错误和警告
+func printIssues(report *config.Report) {
+	for _, issue := range report.Errors {
+		log.Println(i18n.T("cli.config_error", issue))
+	}
+	for _, issue := range report.Warnings {
+		log.Println(i18n.T("cli.config_warning", issue))
+	}
+}
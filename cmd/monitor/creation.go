@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"pve-traffic-monitor/pkg/i18n"
+)
+
+// handleCreationTime 查看或设置虚拟机创建时间
+// show 输出当前使用的创建时间和来源；clear 清除手动设置；其他值作为时间手动设置（优先于 meta.ctime 等来源）
+func (m *Monitor) handleCreationTime(arg string) error {
+	if *vmID == 0 {
+		return i18n.Errorf("cli.ctime_requires_vmid")
+	}
+
+	switch strings.ToLower(arg) {
+	case "show":
+		result, err := m.creation.Resolve(*vmID)
+		if err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.ctime_show", *vmID, result.Time.Format("2006-01-02 15:04:05"), result.Source))
+		setResult("vmid", *vmID)
+		setResult("creation_time", result.Time)
+		setResult("source", result.Source)
+		return nil
+
+	case "clear":
+		if err := m.creation.ClearOverride(*vmID); err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.ctime_cleared", *vmID))
+		setResult("vmid", *vmID)
+		setResult("cleared", true)
+
+	default:
+		at, err := m.parseTimeParam(arg)
+		if err != nil {
+			return err
+		}
+		if err := m.creation.SetOverride(*vmID, at); err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.ctime_set", *vmID, at.Format("2006-01-02 15:04:05")))
+		setResult("vmid", *vmID)
+		setResult("creation_time", at)
+	}
+
+	// 通知主程序重新解析创建时间并清除统计缓存
+	m.notifyMainProgram("reload_cache", map[string]interface{}{
+		"reason": "creation_time",
+		"vmid":   *vmID,
+	})
+	return nil
+}
@@ -2,12 +2,13 @@ package main
 
 import (
 	"log"
-	"os"
+
+	"pve-traffic-monitor/pkg/utils"
 )
 
 // debugLog 输出调试日志
 func debugLog(format string, args ...interface{}) {
-	if os.Getenv("DEBUG") == "1" || os.Getenv("DEBUG") == "true" {
+	if utils.DebugEnabled() {
 		log.Printf("[DEBUG] "+format, args...)
 	}
 }
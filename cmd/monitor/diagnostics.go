@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/storage"
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// writeDiagnostics 生成运行时诊断报告（运行状态、缓存、恢复状态、存储计数和 goroutine 堆栈）
+func (m *Monitor) writeDiagnostics(buf *bytes.Buffer) {
+	now := time.Now()
+	cfg := m.configLoader.GetConfig()
+
+	fmt.Fprintf(buf, "==== 运行时诊断 %s ====\n", now.Format(time.RFC3339))
+	fmt.Fprintf(buf, "PID: %d, 已运行: %s, Go: %s\n", os.Getpid(), now.Sub(m.startedAt).Truncate(time.Second), runtime.Version())
+	fmt.Fprintf(buf, "调试日志: %v, PVE 调试: %v\n", utils.DebugEnabled(), pve.DebugEnabled())
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Fprintf(buf, "Goroutines: %d, 堆内存: %.2f MB, 系统内存: %.2f MB, GC 次数: %d\n",
+		runtime.NumGoroutine(), float64(mem.HeapAlloc)/1024/1024, float64(mem.Sys)/1024/1024, mem.NumGC)
+
+	total, expired := m.stats.CacheStats()
+	fmt.Fprintf(buf, "\n-- 流量缓存 --\n条目: %d, 已过期: %d, 合并的并发统计: %d\n", total, expired, m.stats.Coalesced())
+
+	fmt.Fprintf(buf, "\n-- 存储 (%s) --\n", cfg.Storage.Type)
+	if count, err := m.storage.GetTotalRecordCount(); err != nil {
+		fmt.Fprintf(buf, "总采样点数: 获取失败: %v\n", err)
+	} else {
+		fmt.Fprintf(buf, "总采样点数: %d\n", count)
+	}
+	if reporter, ok := storage.As[storage.HealthReporter](m.storage); ok {
+		writeStorageHealth(buf, reporter.StorageHealth())
+	}
+	if replica, ok := storage.As[storage.ReplicationReporter](m.storage); ok {
+		st := replica.ReplicationStats()
+		fmt.Fprintf(buf, "双写: 主存储错误 %d, 副本错误 %d, 读取切换 %d\n", st.PrimaryErrors, st.SecondaryErrors, st.Failovers)
+		if st.LastError != "" {
+			fmt.Fprintf(buf, "最近双写错误: %s (%s)\n", st.LastError, st.LastErrorAt.Format(time.RFC3339))
+		}
+	}
+	if spool, ok := storage.As[storage.SpoolReporter](m.storage); ok {
+		st := spool.SpoolStats()
+		fmt.Fprintf(buf, "本地缓冲: 积压 %d, 累计缓冲 %d, 已重放 %d, 丢弃 %d\n", st.Backlog, st.Spooled, st.Replayed, st.Dropped)
+		if st.LastError != "" {
+			fmt.Fprintf(buf, "最近写入错误: %s (%s)\n", st.LastError, st.LastErrorAt.Format(time.RFC3339))
+		}
+	}
+
+	if reporter, ok := storage.As[storage.QueryReporter](m.storage); ok {
+		writeQueryStats(buf, reporter.QueryStats())
+	}
+
+	if remote, ok := storage.As[storage.RemoteWriteReporter](m.storage); ok {
+		st := remote.RemoteWriteStats()
+		fmt.Fprintf(buf, "远程写入: 待发送 %d, 已发送 %d, 丢弃 %d\n", st.Pending, st.Sent, st.Dropped)
+		if st.LastError != "" {
+			fmt.Fprintf(buf, "最近发送错误: %s (%s)\n", st.LastError, st.LastErrorAt.Format(time.RFC3339))
+		}
+	}
+
+	if m.samples != nil {
+		st := m.samples.stats(now)
+		fmt.Fprintf(buf, "\n-- 采集 --\n上次完成: %s (耗时 %d ms), 最久未采样: %ds, 采集中断: %v\n",
+			st.LastCycleAt.Format(time.RFC3339), st.CycleMillis, st.MaxAgeSeconds, st.Gaps)
+	}
+
+	if m.actions != nil {
+		st := m.actions.Stats()
+		fmt.Fprintf(buf, "\n-- 操作执行队列 --\n等待 %d, 执行中 %d, 成功 %d, 失败 %d, 重试 %d\n",
+			st.Pending, st.Running, st.Succeeded, st.Failed, st.Retried)
+	}
+
+	if m.events != nil {
+		st := m.events.Stats()
+		fmt.Fprintf(buf, "\n-- 事件 --\n已发布: %v\n", st.Published)
+		for _, sub := range st.Subscribers {
+			fmt.Fprintf(buf, "订阅者 %s: 已处理 %d, 丢弃 %d\n", sub.Name, sub.Delivered, sub.Dropped)
+		}
+	}
+
+	states := m.recoveryManager.States()
+	fmt.Fprintf(buf, "\n-- 恢复状态 (%d) --\n", len(states))
+	for _, state := range states {
+		fmt.Fprintf(buf, "VM%d: 操作=%s 规则=%s 周期=%s 执行时间=%s 需要恢复=%v 恢复时间=%s\n",
+			state.VMID, state.ActionTaken, state.RuleName, state.Period,
+			state.ActionTime.Format(time.RFC3339), state.NeedsRecovery, state.RecoveryTime.Format(time.RFC3339))
+	}
+
+	fmt.Fprintf(buf, "\n-- Goroutine 堆栈 --\n")
+	pprof.Lookup("goroutine").WriteTo(buf, 2)
+}
+
+// writeStorageHealth 输出存储后端的连接池、文件和计数器状态
+func writeStorageHealth(buf *bytes.Buffer, health storage.StorageHealth) {
+	if pool := health.Pool; pool != nil {
+		fmt.Fprintf(buf, "连接池: 打开 %d/%d, 使用中 %d, 空闲 %d, 等待 %d 次 (%d ms)\n",
+			pool.Open, pool.MaxOpen, pool.InUse, pool.Idle, pool.WaitCount, pool.WaitMillis)
+	}
+	if files := health.Files; files != nil {
+		fmt.Fprintf(buf, "数据文件: 虚拟机 %d, JSONL 文件 %d, 文件总数 %d, %.2f MB\n",
+			files.VMs, files.JSONLFiles, files.Files, float64(files.Bytes)/1024/1024)
+	} else if health.SizeBytes > 0 {
+		fmt.Fprintf(buf, "数据库大小: %.2f MB\n", float64(health.SizeBytes)/1024/1024)
+	}
+	counter := health.Counter
+	fmt.Fprintf(buf, "计数器: 待重建 %v, 重建中 %v", counter.NeedsRebuild, counter.Rebuilding)
+	if !counter.LastRebuild.IsZero() {
+		fmt.Fprintf(buf, ", 上次重建 %s", counter.LastRebuild.Format(time.RFC3339))
+	}
+	fmt.Fprintln(buf)
+	if counter.LastError != "" {
+		fmt.Fprintf(buf, "最近重建错误: %s (%s)\n", counter.LastError, counter.LastErrorAt.Format(time.RFC3339))
+	}
+	if health.Replica != nil {
+		fmt.Fprintf(buf, "副本 (%s):\n", health.Replica.Backend)
+		writeStorageHealth(buf, *health.Replica)
+	}
+}
+
+// writeQueryStats 写入各种存储操作的耗时
+func writeQueryStats(buf *bytes.Buffer, stats storage.QueryStats) {
+	fmt.Fprintf(buf, "存储操作 (慢查询阈值 %d ms):\n", stats.SlowThresholdMillis)
+	for _, m := range stats.Methods {
+		fmt.Fprintf(buf, "  %s: %d 次, 平均 %.1f ms, 最长 %.1f ms, 慢查询 %d, 错误 %d\n",
+			m.Method, m.Count, m.AvgMillis, m.MaxMillis, m.Slow, m.Errors)
+	}
+}
+
+// dumpDiagnostics 输出诊断报告（SIGUSR1）
+// 配置了 monitor.diagnostics_dir 时写入文件，否则输出到日志
+func (m *Monitor) dumpDiagnostics() {
+	var buf bytes.Buffer
+	m.writeDiagnostics(&buf)
+
+	dir := m.configLoader.GetConfig().Monitor.DiagnosticsDir
+	if dir == "" {
+		log.Printf("收到 SIGUSR1，输出诊断信息:\n%s", buf.String())
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("创建诊断目录失败，改为输出到日志: %v\n%s", err, buf.String())
+		return
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("diagnostics_%s.txt", time.Now().Format("20060102_150405")))
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		log.Printf("写入诊断文件失败，改为输出到日志: %v\n%s", err, buf.String())
+		return
+	}
+	log.Printf("收到 SIGUSR1，诊断信息已写入: %s", filename)
+}
+
+// toggleDebug 切换调试日志（SIGUSR2），同时作用于 PVE 客户端调试日志
+func toggleDebug() {
+	enabled := !utils.DebugEnabled()
+	utils.SetDebug(enabled)
+	if enabled {
+		pve.EnableDebug()
+	} else {
+		pve.DisableDebug()
+	}
+	log.Printf("收到 SIGUSR2，调试日志已%s", map[bool]string{true: "开启", false: "关闭"}[enabled])
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/digest"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/notify"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/schedule"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// digestWindow 第一次发送（或命令行生成）摘要时统计的时间范围
+const digestWindow = 24 * time.Hour
+
+// digestState 每日摘要的发送计划
+type digestState struct {
+	cron string    // 计算 next 时使用的 cron 表达式（配置重载修改后重新计算）
+	next time.Time // 下次发送时间
+	last time.Time // 上次发送的计划时间（下次摘要的统计起点）
+}
+
+// checkDigest 到达 notify.digest.schedule 时生成并发送摘要（每分钟检查，没有通道订阅 digest 时不发送）
+func (m *Monitor) checkDigest(now time.Time) {
+	cfg := m.configLoader.GetConfig()
+	if !cfg.Notify.DigestEnabled() {
+		m.digest.next = time.Time{}
+		return
+	}
+	expr := cfg.Notify.Digest.Cron()
+	if expr != m.digest.cron || m.digest.next.IsZero() {
+		cron, err := schedule.Parse(expr)
+		if err != nil {
+			log.Printf("摘要计划无效: %v", err)
+			return
+		}
+		m.digest.cron = expr
+		m.digest.next = cron.Next(now.In(periodcalc.Location()))
+		log.Printf("下次发送摘要: %s", m.digest.next.Format("2006-01-02 15:04"))
+		return
+	}
+	if now.Before(m.digest.next) {
+		return
+	}
+
+	due := m.digest.next
+	if cron, err := schedule.Parse(expr); err == nil {
+		m.digest.next = cron.Next(now.In(periodcalc.Location()))
+	}
+	if !m.isLeader() {
+		return
+	}
+
+	start := m.digest.last
+	if start.IsZero() {
+		start = due.Add(-digestWindow)
+	}
+	m.digest.last = due
+	if _, err := m.sendDigest(cfg, start, due); err != nil {
+		log.Printf("发送摘要失败: %v", err)
+	}
+}
+
+// sendDigest 生成 [start, end) 的摘要并发送到订阅了 digest 的通道
+func (m *Monitor) sendDigest(cfg *models.Config, start, end time.Time) ([]string, error) {
+	report, err := m.buildDigest(cfg, start, end)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := notify.NewNotifier(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := notifier.SendDigest(report)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("摘要已发送到: %v（%d 台虚拟机接近限额，%d 次操作，%d 个异常）", sent, len(report.Quotas), len(report.Actions), len(report.Anomalies))
+	return sent, nil
+}
+
+// buildDigest 汇总 [start, end) 内流量最高的虚拟机、执行的操作和异常，以及当前用量达到限额百分比的流量规则
+// 无法获取虚拟机列表时按存储中有记录的虚拟机统计流量（没有名称，不检查规则用量）
+func (m *Monitor) buildDigest(cfg *models.Config, start, end time.Time) (digest.Report, error) {
+	report := digest.Report{Start: start, End: end, QuotaPercent: cfg.Notify.Digest.QuotaThreshold()}
+
+	vms, err := m.allVMs(false)
+	if err != nil {
+		log.Printf("获取虚拟机列表失败，摘要只包含存储中的记录: %v", err)
+		vmids, err := storage.RecordVMIDs(m.storage, start, end)
+		if err != nil && !errors.Is(err, storage.ErrRecordListUnsupported) {
+			return report, err
+		}
+		vms = nil
+		for _, vmid := range vmids {
+			vms = append(vms, models.VMInfo{VMID: vmid})
+		}
+	} else {
+		report.Quotas = m.digestQuotas(cfg, vms, report.QuotaPercent)
+	}
+
+	var consumers []digest.Consumer
+	for _, vm := range vms {
+		stats, err := m.stats.CalculateRange(vm.VMID, start, end, models.DirectionBoth)
+		if err != nil {
+			log.Printf("摘要: 计算 VM%d 的流量失败: %v", vm.VMID, err)
+			continue
+		}
+		consumers = append(consumers, digest.Consumer{VMID: vm.VMID, Name: vm.Name, RXBytes: stats.RXBytes, TXBytes: stats.TXBytes, TotalBytes: stats.TotalBytes})
+	}
+	report.Consumers = digest.TopConsumers(consumers, cfg.Notify.Digest.TopN())
+
+	actions, err := m.storage.GetActionLogs(start, end)
+	if err != nil {
+		return report, err
+	}
+	report.Actions = actions
+	report.Anomalies = digest.FailedActions(actions)
+	if m.digests != nil {
+		report.Anomalies = append(report.Anomalies, m.digests.Between(start, end)...)
+	}
+	digest.SortAnomalies(report.Anomalies)
+	return report, nil
+}
+
+// digestQuotas 启用的流量规则中当前周期用量达到 threshold% 的虚拟机
+func (m *Monitor) digestQuotas(cfg *models.Config, vms []models.VMInfo, threshold float64) []digest.Quota {
+	var quotas []digest.Quota
+	for _, vm := range vms {
+		for _, rule := range cfg.Rules {
+			if !rule.Enabled || rule.IsRateRule() || rule.IsPercentileRule() || rule.IsHostRule() || rule.LimitGB <= 0 || !m.vmMatchesRule(vm, rule) {
+				continue
+			}
+			direction := models.DirectionBoth
+			if rule.TrafficDirection != "" {
+				direction = rule.TrafficDirection
+			}
+			var creationTime time.Time
+			stats, err := m.calculateTrafficStatsWithCache(vm.VMID, rule, direction, &creationTime)
+			if err != nil {
+				log.Printf("摘要: 计算 VM%d 规则 %s 的用量失败: %v", vm.VMID, rule.Name, err)
+				continue
+			}
+			percent := stats.TotalGB / rule.LimitGB * 100
+			if percent < threshold {
+				continue
+			}
+			quotas = append(quotas, digest.Quota{
+				VMID:     vm.VMID,
+				Name:     vm.Name,
+				Rule:     rule.Name,
+				Period:   rule.Period,
+				UsedGB:   stats.TotalGB,
+				LimitGB:  rule.LimitGB,
+				Percent:  percent,
+				Exceeded: stats.TotalGB > rule.LimitGB,
+			})
+		}
+	}
+	digest.SortQuotas(quotas)
+	return quotas
+}
+
+// handleDigest 生成最近 24 小时的摘要并发送（-dry-run 只输出，不发送；命令行没有事件，异常只包括失败的操作）
+func (m *Monitor) handleDigest() error {
+	cfg := m.configLoader.GetConfig()
+	end := time.Now()
+	report, err := m.buildDigest(cfg, end.Add(-digestWindow), end)
+	if err != nil {
+		return err
+	}
+	setResult("digest", report)
+	if *dryRun {
+		log.Println(report.Title(i18n.Locale()) + "\n" + report.Text(i18n.Locale()))
+		return nil
+	}
+	if !cfg.Notify.DigestEnabled() {
+		return i18n.Errorf("cli.digest_no_channel")
+	}
+	notifier, err := notify.NewNotifier(cfg, nil)
+	if err != nil {
+		return err
+	}
+	sent, err := notifier.SendDigest(report)
+	if err != nil {
+		return err
+	}
+	log.Println(i18n.T("cli.digest_sent", sent))
+	setResult("sent", sent)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+
+	_ "pve-traffic-monitor/pkg/lineproto" // 注册行协议推送插件
+	_ "pve-traffic-monitor/pkg/notify"    // 注册通知插件
+)
+
+// publish 发布事件（CLI 模式没有事件总线）
+func (m *Monitor) publish(ev events.Event) {
+	if m.events != nil {
+		m.events.Publish(ev)
+	}
+}
+
+// loadPlugins 按配置启用事件插件（启动和配置重载时调用）
+func (m *Monitor) loadPlugins(cfg *models.Config) {
+	if m.plugins == nil {
+		return
+	}
+	if loaded := m.plugins.Load(cfg); len(loaded) > 0 {
+		log.Printf("事件插件: %v", loaded)
+	}
+}
+
+// limitExceeded 规则超限：每个采集周期都发布 limit_exceeded 事件（由通知插件的告警状态机去重），
+// 返回是否刚开始超限，持续超限期间不再重复记录日志
+func (m *Monitor) limitExceeded(vm models.VMInfo, rule models.Rule, reason string, usage *events.Usage) bool {
+	m.publish(events.Event{Type: events.LimitExceeded, VMID: vm.VMID, Rule: rule.Name, Reason: reason, Usage: usage})
+	_, exceeding := m.exceeded.LoadOrStore(fmt.Sprintf("%d/%s", vm.VMID, rule.Name), true)
+	return !exceeding
+}
+
+// clearExceeded 规则不再超限
+func (m *Monitor) clearExceeded(vmid int, rule models.Rule) {
+	m.exceeded.Delete(fmt.Sprintf("%d/%s", vmid, rule.Name))
+}
+
+// volumeUsage 流量规则的用量（滑动窗口规则的周期随时间移动，周期开始时间为零值）
+func volumeUsage(rule models.Rule, stats *models.TrafficStats) *events.Usage {
+	usage := &events.Usage{
+		UsedGB:      stats.TotalGB,
+		LimitGB:     rule.LimitGB,
+		Percent:     stats.TotalGB / rule.LimitGB * 100,
+		Period:      rule.Period,
+		PeriodStart: stats.StartTime,
+	}
+	if _, rolling := rule.RollingWindow(); rolling {
+		usage.PeriodStart = time.Time{}
+	}
+	return usage
+}
+
+// checkLimitWarning 流量规则用量达到预警百分比（尚未超限）时发布预警，每个周期每条规则只发布一次
+// 滑动窗口规则的周期随时间移动，用量回落到预警线以下后才会再次预警
+func (m *Monitor) checkLimitWarning(vm models.VMInfo, rule models.Rule, stats *models.TrafficStats) {
+	threshold := m.configLoader.GetConfig().Events.WarnThreshold()
+	if m.events == nil || threshold == 0 || rule.LimitGB <= 0 {
+		return
+	}
+
+	key := fmt.Sprintf("%d/%s", vm.VMID, rule.Name)
+	percent := stats.TotalGB / rule.LimitGB * 100
+	if percent < float64(threshold) || stats.TotalGB > rule.LimitGB {
+		m.warned.Delete(key)
+		return
+	}
+
+	periodStart := stats.StartTime
+	if _, rolling := rule.RollingWindow(); rolling {
+		periodStart = time.Time{}
+	}
+	if last, ok := m.warned.Load(key); ok && last.(time.Time).Equal(periodStart) {
+		return
+	}
+	m.warned.Store(key, periodStart)
+
+	log.Printf("VM%d 流量用量达到限额的 %.0f%% %.2f/%.2f GB [%s]", vm.VMID, percent, stats.TotalGB, rule.LimitGB, rule.Name)
+	m.publish(events.Event{
+		Type: events.LimitWarning,
+		VMID: vm.VMID,
+		Rule: rule.Name,
+		Usage: &events.Usage{
+			UsedGB:      stats.TotalGB,
+			LimitGB:     rule.LimitGB,
+			Percent:     percent,
+			Period:      rule.Period,
+			PeriodStart: stats.StartTime,
+		},
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// fsckRepair fsck 时修复发现的问题
+var fsckRepair = flag.Bool("repair", false, "fsck 时修复发现的问题（隔离损坏的行、删除不会被读取的记录并重建记录计数器）")
+
+// runFsckCommand 检查存储完整性（monitor fsck [-repair]）：重新统计记录数并与计数器比较，检查损坏的 JSONL 行和不会被读取的记录，
+// 不需要连接 PVE；有未修复的问题时返回错误（退出码非 0）
+func runFsckCommand(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+	applyPeriodConfig(cfg)
+
+	store, err := storage.NewStorageFromConfig(&cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("创建存储管理器失败: %w", err)
+	}
+	defer store.Close()
+
+	verifier, ok := storage.As[storage.Verifier](store)
+	if !ok {
+		return i18n.Errorf("cli.fsck_unsupported", cfg.Storage.Type)
+	}
+	report, err := verifier.Verify(*fsckRepair)
+	if err != nil {
+		return err
+	}
+
+	log.Println(i18n.T("cli.fsck_summary", report.Records, report.Readable, report.Files))
+	if report.Cached >= 0 {
+		log.Println(i18n.T("cli.fsck_counter", report.Cached))
+	}
+	for _, issue := range report.Issues {
+		key := "cli.fsck_issue"
+		if issue.Repaired {
+			key = "cli.fsck_issue_repaired"
+		}
+		log.Println(i18n.T(key, issue.Kind, issue.Location, issue.Count))
+	}
+	if *fsckRepair {
+		log.Println(i18n.T("cli.fsck_rebuilt", report.Rebuilt))
+	}
+	setResult("fsck", report)
+	setResult("repair", *fsckRepair)
+
+	if *fsckRepair {
+		// 主程序内存中的计数器按修复后的数据重新统计，并清除统计缓存
+		notifyProgram(cfg, "reload_cache", map[string]interface{}{"reason": "fsck", "rebuild_counter": true})
+	}
+
+	unrepaired := report.Unrepaired()
+	switch {
+	case unrepaired == 0 && len(report.Issues) == 0:
+		log.Println(i18n.T("cli.fsck_clean"))
+	case unrepaired == 0:
+		log.Println(i18n.T("cli.fsck_repaired", len(report.Issues)))
+	case *fsckRepair:
+		return i18n.Errorf("cli.fsck_unrepairable", unrepaired)
+	default:
+		return i18n.Errorf("cli.fsck_issues", unrepaired)
+	}
+	return nil
+}
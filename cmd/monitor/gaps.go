@@ -0,0 +1,228 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// vmSample 虚拟机的采样状态
+type vmSample struct {
+	VMID        int       `json:"vmid"`
+	Running     bool      `json:"running"`                 // 最近一次获取虚拟机列表时是否在运行
+	LastSample  time.Time `json:"last_sample,omitempty"`   // 最近一次成功采样的时间
+	LastError   string    `json:"last_error,omitempty"`    // 最近一次采样的错误（成功采样后清除）
+	LastErrorAt time.Time `json:"last_error_at,omitempty"` // 最近一次采样失败的时间
+	Gap         bool      `json:"gap"`                     // 是否处于采集中断中
+
+	since time.Time // 开始跟踪的时间（本次运行中从未采样时作为中断的起点）
+}
+
+// CollectionStats 采集状态（显示在系统统计中）
+type CollectionStats struct {
+	LastCycleAt   time.Time  `json:"last_cycle_at,omitempty"` // 最近一次完成采集的时间
+	CycleMillis   int64      `json:"cycle_ms"`                // 最近一次采集的耗时（毫秒）
+	LagSeconds    int64      `json:"lag_seconds"`             // 距最近一次完成采集的时间（秒）
+	MaxAgeSeconds int64      `json:"max_age_seconds"`         // 运行中的虚拟机距最近一次成功采样的最长时间（秒）
+	Gaps          []int      `json:"gaps"`                    // 处于采集中断中的虚拟机
+	VMs           []vmSample `json:"vms"`
+}
+
+// sampleTracker 记录每台虚拟机最近一次成功采样的时间，运行中的虚拟机超过阈值没有采样时告警
+// 采集中断期间的流量不会出现在任何记录中，周期用量会偏低
+type sampleTracker struct {
+	mu          sync.Mutex
+	vms         map[int]*vmSample
+	lastCycleAt time.Time
+	cycle       time.Duration
+}
+
+// newSampleTracker 创建采样状态
+func newSampleTracker() *sampleTracker {
+	return &sampleTracker{vms: make(map[int]*vmSample)}
+}
+
+// get 获取虚拟机的采样状态（调用方持有锁）
+func (t *sampleTracker) get(vmid int, now time.Time) *vmSample {
+	s, ok := t.vms[vmid]
+	if !ok {
+		s = &vmSample{VMID: vmid, since: now}
+		t.vms[vmid] = s
+	}
+	return s
+}
+
+// sampled 记录一次成功采样，返回采集中断的时长（不在中断中时为 0）
+func (t *sampleTracker) sampled(vmid int, at time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.get(vmid, at)
+	var gap time.Duration
+	if s.Gap {
+		gap = at.Sub(s.lastGood())
+		s.Gap = false
+	}
+	if at.After(s.LastSample) {
+		s.LastSample = at
+	}
+	s.LastError = ""
+	return gap
+}
+
+// failed 记录一次采样失败
+func (t *sampleTracker) failed(vmid int, err error, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.get(vmid, at)
+	s.LastError = err.Error()
+	s.LastErrorAt = at
+}
+
+// sampleStopped 已停止的虚拟机本次是否需要采样：刚停止（上次获取列表时还在运行）或本次运行中未采样过时采样，
+// 之后每隔 every 采样一次（every 为 0 时不再采样）；interval 为采集间隔，用于容忍定时器的抖动
+func (t *sampleTracker) sampleStopped(vmid int, every, interval time.Duration, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.vms[vmid]
+	if !ok || s.Running || s.LastSample.IsZero() {
+		return true
+	}
+	if every <= 0 {
+		return false
+	}
+	return now.Sub(s.LastSample) >= every-interval/2
+}
+
+// lastGood 最近一次成功采样的时间（从未采样时为开始跟踪的时间）
+func (s *vmSample) lastGood() time.Time {
+	if s.LastSample.IsZero() {
+		return s.since
+	}
+	return s.LastSample
+}
+
+// check 完成一次采集后检查采集中断，返回新出现中断的虚拟机
+// vms 为本次获取的虚拟机列表，获取失败时为 nil，按上次的运行状态检查
+func (t *sampleTracker) check(vms []models.VMInfo, threshold, interval time.Duration, now time.Time) []events.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if vms != nil {
+		listed := make(map[int]bool, len(vms))
+		for _, vm := range vms {
+			listed[vm.VMID] = true
+			s := t.get(vm.VMID, now)
+			running := vm.Status == "running"
+			if running && !s.Running {
+				// 刚启动的虚拟机从现在开始计算
+				s.since = now
+				s.Gap = false
+			}
+			s.Running = running
+		}
+		for vmid := range t.vms {
+			if !listed[vmid] {
+				delete(t.vms, vmid)
+			}
+		}
+	}
+	if threshold <= 0 || interval <= 0 {
+		return nil
+	}
+
+	var gaps []events.Event
+	for _, s := range t.vms {
+		if !s.Running || s.Gap {
+			continue
+		}
+		last := s.lastGood()
+		if now.Sub(last) <= threshold {
+			continue
+		}
+		s.Gap = true
+		gaps = append(gaps, events.Event{
+			Type: events.CollectionGap,
+			Time: now,
+			VMID: s.VMID,
+			Gap:  &events.Gap{LastSample: last, Intervals: int(now.Sub(last) / interval), Error: s.LastError},
+		})
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].VMID < gaps[j].VMID })
+	return gaps
+}
+
+// finishCycle 记录一次采集完成
+func (t *sampleTracker) finishCycle(start, end time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastCycleAt = end
+	t.cycle = end.Sub(start)
+}
+
+// reset 清除采样状态（成为主实例时，之前由其他实例采集）
+func (t *sampleTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.vms = make(map[int]*vmSample)
+	t.lastCycleAt = time.Time{}
+	t.cycle = 0
+}
+
+// stats 采集状态
+func (t *sampleTracker) stats(now time.Time) CollectionStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := CollectionStats{
+		LastCycleAt: t.lastCycleAt,
+		CycleMillis: t.cycle.Milliseconds(),
+		Gaps:        []int{},
+		VMs:         make([]vmSample, 0, len(t.vms)),
+	}
+	if !t.lastCycleAt.IsZero() {
+		st.LagSeconds = int64(now.Sub(t.lastCycleAt).Seconds())
+	}
+	for _, s := range t.vms {
+		st.VMs = append(st.VMs, *s)
+		if s.Gap {
+			st.Gaps = append(st.Gaps, s.VMID)
+		}
+		if age := int64(now.Sub(s.lastGood()).Seconds()); s.Running && age > st.MaxAgeSeconds {
+			st.MaxAgeSeconds = age
+		}
+	}
+	sort.Ints(st.Gaps)
+	sort.Slice(st.VMs, func(i, j int) bool { return st.VMs[i].VMID < st.VMs[j].VMID })
+	return st
+}
+
+// checkGaps 检查采集中断：新出现的中断记录日志并发布 collection_gap 事件
+func (m *Monitor) checkGaps(vms []models.VMInfo, now time.Time) {
+	cfg := m.configLoader.GetConfig().Monitor
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	for _, ev := range m.samples.check(vms, cfg.GapThreshold(), interval, now) {
+		gap := ev.Gap
+		if gap.Error != "" {
+			log.Printf("警告: VM%d 已有 %d 个采集间隔没有成功采样（最近一次采样: %s，错误: %s），期间的流量不会计入周期用量",
+				ev.VMID, gap.Intervals, gap.LastSample.Format(time.RFC3339), gap.Error)
+		} else {
+			log.Printf("警告: VM%d 已有 %d 个采集间隔没有成功采样（最近一次采样: %s），期间的流量不会计入周期用量",
+				ev.VMID, gap.Intervals, gap.LastSample.Format(time.RFC3339))
+		}
+		m.publish(ev)
+	}
+}
+
+// sampleCollected 记录成功采样，采集中断结束时记录日志
+func (m *Monitor) sampleCollected(vmid int, at time.Time) {
+	if gap := m.samples.sampled(vmid, at); gap > 0 {
+		log.Printf("VM%d 已恢复采集（中断 %s）", vmid, gap.Truncate(time.Second))
+	}
+}
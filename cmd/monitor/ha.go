@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// haCheckInterval 检查租约的间隔（实际获取或续期的间隔为租约时长的 1/3）
+const haCheckInterval = 2 * time.Second
+
+// isLeader 是否采集数据和执行操作（未启用主备模式时总是 true）
+// 除主实例标记外还要求租约未到期：存储无法访问或续期延迟时，到期后其他实例可能已经接管，本实例立即停止操作
+func (m *Monitor) isLeader() bool {
+	if !m.configLoader.GetConfig().Monitor.HA.Enabled {
+		return true
+	}
+	return m.leader.Load() && time.Now().UnixNano() < m.leaseExpires.Load()
+}
+
+// startLeaseRenewal 在独立的 goroutine 中定期续期租约
+// 主循环中的采集、清理和等待 PVE 任务可能超过租约时长，不能在主循环中续期
+func (m *Monitor) startLeaseRenewal() {
+	m.haStop = make(chan struct{})
+	m.haDone = make(chan struct{})
+	go func() {
+		defer close(m.haDone)
+		ticker := time.NewTicker(haCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.haStop:
+				return
+			case now := <-ticker.C:
+				m.renewLease(now)
+			}
+		}
+	}()
+}
+
+// stopLeaseRenewal 停止续期 goroutine（退出时在释放租约之前调用，避免释放后又被续期）
+func (m *Monitor) stopLeaseRenewal() {
+	if m.haStop == nil {
+		return
+	}
+	close(m.haStop)
+	<-m.haDone
+	m.haStop = nil
+}
+
+// renewLease 获取或续期主实例租约：获得租约后通知主循环接管，失去租约时立即转为备用实例
+func (m *Monitor) renewLease(now time.Time) {
+	ha := m.configLoader.GetConfig().Monitor.HA
+	if !ha.Enabled {
+		return
+	}
+	ttl := ha.LeaseDuration()
+	first := m.leaseCheckedAt.IsZero()
+	if !first && now.Sub(m.leaseCheckedAt) < ttl/3 {
+		return
+	}
+	m.leaseCheckedAt = now
+
+	acquired, err := storage.AcquireLease(m.storage, storage.LeaderLease, ha.ID(), ttl, now)
+	if err != nil {
+		// 无法访问存储时，在已持有的租约到期前保持主实例身份；到期后其他实例可能已经接管（isLeader 同样检查到期时间）
+		log.Printf("续期主实例租约失败: %v", err)
+		acquired = m.leader.Load() && now.UnixNano() < m.leaseExpires.Load()
+	} else if acquired {
+		m.leaseExpires.Store(now.Add(ttl).UnixNano())
+	}
+
+	switch {
+	case acquired && !m.leader.Load():
+		// 接管（加载恢复记录、核对标签）在主循环中进行，与采集和执行规则串行
+		select {
+		case m.leaderChan <- struct{}{}:
+		default:
+		}
+	case !acquired && m.leader.Load():
+		m.leader.Store(false)
+		log.Printf("主实例租约已被其他实例持有，转为备用实例（只提供 API）")
+	case !acquired && first:
+		if lease, err := storage.GetLease(m.storage, storage.LeaderLease); err == nil && lease != nil {
+			log.Printf("主实例为 %s，本实例 (%s) 作为备用实例运行（只提供 API）", lease.Holder, ha.ID())
+		}
+	}
+}
+
+// takeLeadership 启动时获得了租约则立即接管（之后由主循环处理续期 goroutine 的通知）
+func (m *Monitor) takeLeadership() {
+	select {
+	case <-m.leaderChan:
+		m.becomeLeader()
+	default:
+	}
+}
+
+// becomeLeader 成为主实例：接管之前的主实例记录的恢复状态并核对操作标签，清除备用期间的统计缓存
+// 接管完成后才开始采集和执行规则；租约在等待期间到期时放弃接管
+func (m *Monitor) becomeLeader() {
+	if m.leader.Load() || time.Now().UnixNano() >= m.leaseExpires.Load() {
+		return
+	}
+	id := m.configLoader.GetConfig().Monitor.HA.ID()
+	log.Printf("本实例 (%s) 成为主实例，开始采集和执行规则", id)
+
+	vms, err := m.allVMs(true)
+	if err != nil {
+		log.Printf("获取虚拟机列表失败，无法加载恢复记录: %v", err)
+	} else {
+		vmids := make([]int, 0, len(vms))
+		for _, vm := range vms {
+			vmids = append(vmids, vm.VMID)
+		}
+		if loaded := m.recoveryManager.LoadStates(vmids); loaded > 0 {
+			log.Printf("已加载 %d 个虚拟机的恢复记录", loaded)
+		}
+		m.reconcileTags(vms)
+	}
+	m.stats.InvalidateAll()
+	m.samples.reset()
+	m.leader.Store(true)
+}
+
+// releaseLease 退出时释放持有的租约，备用实例无需等待租约到期即可接管
+func (m *Monitor) releaseLease() {
+	if !m.leader.Load() {
+		return
+	}
+	ha := m.configLoader.GetConfig().Monitor.HA
+	if err := storage.ReleaseLease(m.storage, storage.LeaderLease, ha.ID()); err != nil {
+		log.Printf("释放主实例租约失败: %v", err)
+		return
+	}
+	m.leader.Store(false)
+	log.Println("已释放主实例租约")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"pve-traffic-monitor/pkg/hook"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// vmNode 虚拟机所在的节点（集群聚合模式下可能不是本节点）
+func (m *Monitor) vmNode(vmid int) string {
+	if node, ok := m.remote.node(vmid); ok {
+		return node
+	}
+	return m.pveConfig.Node
+}
+
+// runExecAction 执行 exec 操作的命令，输出记录到操作日志
+func (m *Monitor) runExecAction(actionLog *models.ActionLog, req hook.Request) error {
+	log.Printf("执行操作: VM%d 运行命令 %s", req.VM.VMID, req.Rule.Exec.Command[0])
+	req.Phase = hook.PhaseAction
+	output, err := hook.Run(*req.Rule.Exec, req.Env())
+	actionLog.Output = output
+	return err
+}
+
+// runPostAction 操作执行后运行规则的钩子命令（无论操作成功与否），输出和错误记录到操作日志
+// 钩子失败不影响操作的结果
+func (m *Monitor) runPostAction(actionLog *models.ActionLog, req hook.Request) {
+	if req.Rule.PostAction == nil {
+		return
+	}
+	req.Phase = hook.PhasePostAction
+	req.Result = actionLog
+	output, err := hook.Run(*req.Rule.PostAction, req.Env())
+	actionLog.HookOutput = output
+	if err != nil {
+		actionLog.HookError = err.Error()
+		log.Printf("VM%d 规则 %s 的操作后钩子失败: %v", req.VM.VMID, req.Rule.Name, err)
+	}
+}
+
+// actionErrorKind 操作失败的错误类型（外部命令的错误不属于 PVE 错误）
+func actionErrorKind(err error) string {
+	switch {
+	case errors.Is(err, hook.ErrTimeout):
+		return models.ActionErrorTimeout
+	case errors.Is(err, hook.ErrExit):
+		return models.ActionErrorExec
+	default:
+		return pve.ErrorKind(err)
+	}
+}
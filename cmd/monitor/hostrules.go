@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/hook"
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+)
+
+// hostTalker 主机规则下一台虚拟机的周期用量
+type hostTalker struct {
+	vm     models.VMInfo
+	usedGB float64
+}
+
+// applyHostRules 检查主机级规则（scope=host）：匹配虚拟机在规则周期内的流量总和超过限额时，
+// 对用量最高的 top_n 台虚拟机执行规则的操作（每个采集周期结束后执行一次）
+func (m *Monitor) applyHostRules(vms []models.VMInfo) {
+	if m.inMaintenance() {
+		return
+	}
+	for _, rule := range m.configLoader.GetConfig().Rules {
+		if rule.Enabled && rule.IsHostRule() {
+			m.applyHostRule(vms, rule)
+		}
+	}
+}
+
+// applyHostRule 检查一条主机规则
+func (m *Monitor) applyHostRule(vms []models.VMInfo, rule models.Rule) {
+	direction := models.DirectionBoth
+	if rule.TrafficDirection != "" {
+		direction = rule.TrafficDirection
+	}
+
+	calc := periodcalc.ForRule(rule, time.Time{})
+	var talkers []hostTalker
+	var totalGB float64
+	for _, vm := range vms {
+		if !m.vmMatchesRule(vm, rule) {
+			continue
+		}
+		stats, err := m.stats.CalculateMetricFor(calc, vm.VMID, direction, rule.Metric)
+		if err != nil {
+			// 缺少一台虚拟机的用量时总和偏低，本周期不判断，避免按不完整的数据选出虚拟机
+			log.Printf("计算流量统计失败 (VM %d, 主机规则 %s): %v", vm.VMID, rule.Name, err)
+			return
+		}
+		talkers = append(talkers, hostTalker{vm: vm, usedGB: stats.TotalGB})
+		totalGB += stats.TotalGB
+	}
+
+	key := "host/" + rule.Name
+	if totalGB <= rule.LimitGB {
+		if _, exceeding := m.exceeded.LoadAndDelete(key); exceeding {
+			log.Printf("主机规则 %s 的流量总和已回落到限额以下: %.2f/%.2f GB", rule.Name, totalGB, rule.LimitGB)
+		}
+		return
+	}
+	if _, exceeding := m.exceeded.LoadOrStore(key, true); !exceeding {
+		log.Printf("%d 台虚拟机的%s流量总和超出主机规则限制 %.2f/%.2f GB [%s]",
+			len(talkers), getDirectionText(direction), totalGB, rule.LimitGB, rule.Name)
+	}
+
+	usage := hook.Usage{Used: totalGB, Limit: rule.LimitGB, Unit: "GB"}
+	for i, talker := range selectTopTalkers(talkers, rule.TopTalkers()) {
+		reason := fmt.Sprintf("超出主机流量限制: %.2f GB / %.2f GB，本机用量 %.2f GB（第 %d 高）", totalGB, rule.LimitGB, talker.usedGB, i+1)
+		if m.limitExceeded(talker.vm, rule, reason, hostUsage(rule, calc, totalGB)) {
+			log.Printf("VM%d 为主机规则 %s 用量第 %d 高的虚拟机 (%.2f GB)，执行操作 %s", talker.vm.VMID, rule.Name, i+1, talker.usedGB, rule.Action)
+		}
+		m.submitAction(talker.vm, rule, reason, usage, time.Time{}, nil)
+	}
+}
+
+// selectTopTalkers 按用量从高到低选出 n 台有流量的虚拟机（用量相同时 VMID 小的优先）
+func selectTopTalkers(talkers []hostTalker, n int) []hostTalker {
+	sorted := append([]hostTalker(nil), talkers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].usedGB != sorted[j].usedGB {
+			return sorted[i].usedGB > sorted[j].usedGB
+		}
+		return sorted[i].vm.VMID < sorted[j].vm.VMID
+	})
+	for i, talker := range sorted {
+		if talker.usedGB <= 0 {
+			sorted = sorted[:i]
+			break
+		}
+	}
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// hostUsage 主机规则的用量（用量和限额为所有匹配虚拟机的总和）
+func hostUsage(rule models.Rule, calc *periodcalc.Calculator, totalGB float64) *events.Usage {
+	usage := &events.Usage{
+		UsedGB:      totalGB,
+		LimitGB:     rule.LimitGB,
+		Percent:     totalGB / rule.LimitGB * 100,
+		Period:      rule.Period,
+		PeriodStart: calc.GetCurrentPeriodStart(),
+	}
+	if _, rolling := rule.RollingWindow(); rolling {
+		usage.PeriodStart = time.Time{}
+	}
+	return usage
+}
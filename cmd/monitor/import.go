@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/importer"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// importBatchSize 导入时每批写入的记录数
+const importBatchSize = 1000
+
+// importFormat 导入格式：显式指定 -format 时使用该值，否则按扩展名推断（.json 为 vnstat）
+func importFormat(path string) string {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "format" {
+			explicit = true
+		}
+	})
+	if explicit {
+		return strings.ToLower(*exportFormat)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return importer.FormatVnstat
+	}
+	return importer.FormatCSV
+}
+
+// handleImport 将外部监控工具的流量数据导入为指定虚拟机的流量记录
+// 已有记录的时间范围不允许重复导入（需先用 -cleanup vm 清除），避免流量重复计算
+func (m *Monitor) handleImport(path string) error {
+	if *vmID == 0 {
+		return i18n.Errorf("cli.import_requires_vmid")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.import_read_failed"), err)
+	}
+	defer file.Close()
+
+	format := importFormat(path)
+	records, err := importer.Parse(format, file, *vmID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.import_parse_failed"), err)
+	}
+	if len(records) == 0 {
+		return i18n.Errorf("cli.import_empty")
+	}
+
+	first, last := records[0].Timestamp, records[len(records)-1].Timestamp
+	log.Println(i18n.T("cli.import_prepare", len(records), format, *vmID,
+		first.Format("2006-01-02 15:04:05"), last.Format("2006-01-02 15:04:05")))
+	setResult("vmid", *vmID)
+	setResult("format", format)
+	setResult("records", len(records))
+	setResult("start", first)
+	setResult("end", last)
+	setResult("dry_run", *dryRun)
+
+	existing, err := m.storage.CountRecordsInRange(*vmID, first, last)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.count_failed"), err)
+	}
+	if existing > 0 {
+		return i18n.Errorf("cli.import_overlap", *vmID, existing)
+	}
+
+	// 导入的计数器从 0 开始，接到前后已有的记录上，否则衔接处的计数器回退会被当作重启
+	prev, next, err := m.adjacentRecords(*vmID, first, last)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.import_rebase_failed"), err)
+	}
+	if err := importer.Rebase(records, prev, next); err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.import_rebase_failed"), err)
+	}
+	anchor := next
+	if anchor == nil {
+		anchor = prev
+	}
+	if anchor != nil {
+		log.Println(i18n.T("cli.import_rebased", anchor.Timestamp.Format("2006-01-02 15:04:05")))
+		setResult("rebased_onto", anchor.Timestamp)
+	}
+
+	if *dryRun {
+		log.Println(i18n.T("cli.import_dry_run", len(records)))
+		return nil
+	}
+
+	for i := 0; i < len(records); i += importBatchSize {
+		batch := records[i:min(i+importBatchSize, len(records))]
+		if err := storage.SaveTrafficRecords(m.storage, batch); err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.import_save_failed", i), err)
+		}
+		log.Println(i18n.T("cli.import_progress", i+len(batch), len(records)))
+	}
+	log.Println(i18n.T("cli.imported", len(records), *vmID))
+
+	// 通知主程序清除统计缓存
+	m.notifyMainProgram("reload_cache", map[string]interface{}{
+		"reason": "import",
+		"vmid":   *vmID,
+	})
+	return nil
+}
+
+// adjacentSearchSpans 查找导入范围前后已有记录时逐步扩大的查询范围
+var adjacentSearchSpans = []time.Duration{time.Hour, 24 * time.Hour, 31 * 24 * time.Hour, 366 * 24 * time.Hour, 10 * 366 * 24 * time.Hour}
+
+// adjacentRecords 查找 [first, last] 之前的最后一条和之后的第一条已有记录（没有时为 nil）
+func (m *Monitor) adjacentRecords(vmid int, first, last time.Time) (prev, next *models.TrafficRecord, err error) {
+	for _, span := range adjacentSearchSpans {
+		records, err := m.storage.GetTrafficRecords(vmid, first.Add(-span), first)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(records) > 0 {
+			prev = &records[len(records)-1]
+			break
+		}
+	}
+	for _, span := range adjacentSearchSpans {
+		records, err := m.storage.GetTrafficRecords(vmid, last, last.Add(span))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(records) > 0 {
+			next = &records[0]
+			break
+		}
+	}
+	return prev, next, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ruleQueueSize 等待执行规则的批次数量上限
+const ruleQueueSize = 64
+
+// handleIngested 通过 /api/ingest 写入记录后，对本节点和代理节点上存在的虚拟机执行规则
+// 其他来源的虚拟机（如其他主机、其他虚拟化平台）无法执行操作，只记录流量
+func (m *Monitor) handleIngested(vmids []int) {
+	vms, err := m.allVMs(m.configLoader.GetConfig().Monitor.ListsTemplates())
+	if err != nil {
+		log.Printf("获取虚拟机列表失败，跳过写入记录的规则检查: %v", err)
+		return
+	}
+	byID := make(map[int]bool, len(vmids))
+	for _, vmid := range vmids {
+		byID[vmid] = true
+	}
+	var matched []models.VMInfo
+	for _, vm := range vms {
+		if byID[vm.VMID] {
+			matched = append(matched, vm)
+		}
+	}
+	if len(matched) > 0 {
+		m.queueRules(matched, fmt.Sprintf("写入的 %d 台虚拟机", len(vmids)))
+	}
+}
+
+// queueRules 在后台对虚拟机执行规则（关机等操作需要等待任务完成，不阻塞请求）
+// 队列已满时跳过本次，下次收到记录时再检查
+func (m *Monitor) queueRules(vms []models.VMInfo, source string) {
+	if !m.isLeader() || m.ruleQueue == nil {
+		return
+	}
+	select {
+	case m.ruleQueue <- vms:
+	default:
+		log.Printf("规则队列已满，跳过%s本次的规则检查", source)
+	}
+}
+
+// runQueuedRules 执行队列中的规则检查
+func (m *Monitor) runQueuedRules() {
+	for vms := range m.ruleQueue {
+		if !m.isLeader() {
+			continue
+		}
+		for _, vm := range vms {
+			if err := m.applyRules(vm); err != nil {
+				log.Printf("应用规则失败 (VM %d): %v\n", vm.VMID, err)
+			}
+			m.checkRatio(vm)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// protocolRetryDelay 客户机代理采集失败后暂停的时间（没有安装或运行代理的虚拟机不必每次都尝试）
+const protocolRetryDelay = 10 * time.Minute
+
+// protocolSampler 通过客户机代理采集按协议（IPv4/IPv6）的累计流量（monitor.ip_split）
+type protocolSampler struct {
+	retryAt sync.Map // VMID -> time.Time 采集失败后下次尝试的时间
+}
+
+// sample 为运行中的虚拟机补充按协议的计数，失败时记录不变（按协议统计时跳过该记录）
+func (p *protocolSampler) sample(client *pve.Client, vm models.VMInfo, record *models.TrafficRecord) {
+	if vm.Status != "running" {
+		return
+	}
+	now := time.Now()
+	if retryAt, ok := p.retryAt.Load(vm.VMID); ok && now.Before(retryAt.(time.Time)) {
+		return
+	}
+
+	counters, err := client.GetGuestProtocolCounters(vm.VMID)
+	if err != nil {
+		debugLog("VM%d 通过客户机代理读取协议流量失败，%v 后重试: %v", vm.VMID, protocolRetryDelay, err)
+		p.retryAt.Store(vm.VMID, now.Add(protocolRetryDelay))
+		return
+	}
+	p.retryAt.Delete(vm.VMID)
+	record.IPv4RX, record.IPv4TX = counters.IPv4RX, counters.IPv4TX
+	record.IPv6RX, record.IPv6TX = counters.IPv6RX, counters.IPv6TX
+}
@@ -2,88 +2,283 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"pve-traffic-monitor/pkg/api"
-	"pve-traffic-monitor/pkg/cache"
 	"pve-traffic-monitor/pkg/chart"
 	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/creation"
+	"pve-traffic-monitor/pkg/digest"
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/executor"
+	"pve-traffic-monitor/pkg/hook"
+	"pve-traffic-monitor/pkg/i18n"
 	"pve-traffic-monitor/pkg/ipc"
 	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/objstore"
 	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/pve"
 	"pve-traffic-monitor/pkg/recovery"
+	"pve-traffic-monitor/pkg/schedule"
+	"pve-traffic-monitor/pkg/stats"
 	"pve-traffic-monitor/pkg/storage"
+	"pve-traffic-monitor/pkg/tenant"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	_ "time/tzdata" // 内置时区数据库，主机缺少 tzdata 时 timezone 配置仍可用
 )
 
 var (
-	configPath   = flag.String("config", "config.json", "配置文件路径")
-	exportCmd    = flag.String("export", "", "导出图表 (格式: vm_id 或 all)")
-	exportFormat = flag.String("format", "html", "导出格式 (json/png/html), 默认: html")
-	useDarkTheme = flag.Bool("dark", false, "使用暗色主题 (仅html格式)")
-	period       = flag.String("period", "hour", "聚合粒度 (minute/hour/day/month), 也用于确定默认时间范围")
+	configPath   = flag.String("config", "config.json", "配置文件路径 (JSON/YAML/TOML, 按扩展名识别)")
+	exportCmd    = flag.String("export", "", "导出图表 (格式: vm_id、all、tenants 或 host)")
+	exportFormat = flag.String("format", "html", "导出格式 (json/png/svg/html), 默认: html")
+	useDarkTheme = flag.Bool("dark", false, "使用暗色主题 (html/png/svg格式)")
+	period       = flag.String("period", "hour", "聚合粒度 (minute/hour/day/month, 也用于确定默认时间范围; 导出单个虚拟机时也可以是步长如 15m/6h/1d/auto, 默认最近24小时)")
 	direction    = flag.String("direction", "both", "流量方向 (both/rx/tx)")
 	startTime    = flag.String("start", "", "开始时间 (格式: 2006-01-02 或 2006-01-02T15:04:05)")
 	endTime      = flag.String("end", "", "结束时间 (格式: 2006-01-02 或 2006-01-02T15:04:05)")
 	exportDate   = flag.String("date", "", "指定日期 (格式: 2006-01-02, 导出某天的数据)")
 
+	// 导出图表的尺寸和文字（png/svg/html）
+	chartWidth  = flag.Int("width", 0, "图表宽度 (像素, 200-8000), 默认按图表类型")
+	chartHeight = flag.Int("height", 0, "图表高度 (像素, 200-8000), 默认按图表类型")
+	chartDPI    = flag.Float64("dpi", 0, "图表 DPI (36-600, 仅png/svg), 默认: 92")
+	chartTitle  = flag.String("title", "", "图表标题模板 (Go template, 可用 {{.VMID}} {{.Name}} {{.Start}} {{.End}} {{.Period}} {{.Direction}} {{.Unit}})")
+	chartXLabel = flag.String("x-label", "", "横轴名称模板 (字段同 -title)")
+	chartYLabel = flag.String("y-label", "", "纵轴名称模板 (字段同 -title)")
+	chartCDN    = flag.Bool("cdn", false, "HTML 引用 CDN 上的 ECharts (默认内嵌到文件中, 离线可用)")
+
+	// 导出文件名（默认带时间戳，保存在 monitor.export_path）
+	exportFilename  = flag.String("filename", "", "导出文件名模板 (相对导出目录, 可用 {kind} {vmid} {name} {period} {direction} {date} {start} {end} {timestamp} {ext}, 如 {vmid}_{period}_{date}.{ext})")
+	exportOutput    = flag.String("output", "", "导出文件的完整路径 (优先于 -filename)")
+	exportOverwrite = flag.Bool("overwrite", false, "导出文件已存在时覆盖 (默认追加序号 _1、_2…)")
+	exportDest      = flag.String("dest", "", "导出目标名称 (monitor.export_destinations 中的 name, local 表示本地导出目录), 默认: monitor.export_destination")
+
 	// 清除数据相关参数
-	cleanupCmd = flag.String("cleanup", "", "清除历史数据 (range/vm/before)")
+	cleanupCmd = flag.String("cleanup", "", "清除历史数据 (range/vm/before, logs 操作日志, states 虚拟机状态, compact 只压缩存储)")
 	vmID       = flag.Int("vmid", 0, "虚拟机ID (cleanup=vm时使用)")
 	beforeDate = flag.String("before", "", "删除此日期之前的数据 (格式: 2006-01-02, cleanup=before时使用)")
 	dryRun     = flag.Bool("dry-run", false, "仅显示将删除的数据，不实际执行")
+	compact    = flag.Bool("compact", false, "清除后压缩存储 (SQLite VACUUM、MySQL OPTIMIZE TABLE、PostgreSQL VACUUM, 文件存储删除空文件和空目录)")
+
+	// 导入外部流量数据
+	importFile = flag.String("import", "", "导入外部流量数据文件 (csv 累计计数器或 vnstat --json 输出, 需要 -vmid, 格式由 -format 指定或按扩展名推断)")
+
+	// 重新计算派生数据
+	recomputeCmd = flag.Bool("recompute", false, "手动修改或导入数据后重新计算时间范围内的用量汇总，重建记录计数器并通知主程序清除缓存 (需要 -start 和 -end)")
+
+	// 规则模拟
+	simulateRule = flag.String("simulate", "", "用历史数据模拟规则文件 (单条规则 JSON)，报告会被限制的虚拟机")
+
+	// 摘要
+	digestCmd = flag.Bool("digest", false, "生成最近 24 小时的摘要并发送到订阅了 digest 的通知通道 (-dry-run 只输出, 不发送)")
+
+	// 虚拟机创建时间（meta.ctime 缺失或不正确时手动设置）
+	creationTimeCmd = flag.String("creation-time", "", "查看或设置虚拟机创建时间 (show、clear 或时间如 2006-01-02T15:04:05, 需要 -vmid)")
+
+	// 配置命令（config show 时输出填充默认值后的实际配置）
+	showEffective = flag.Bool("effective", false, "config show 时输出填充默认值后的实际生效配置")
+
+	// 公开状态页链接
+	publicLinkVMID = flag.Int("public-link", 0, "生成虚拟机只读公开状态页链接 (虚拟机ID, 需要配置 api.public_secret)")
+	linkTTL        = flag.Duration("link-ttl", models.DefaultPublicLinkTTL, "公开链接有效期 (如 720h, 0 表示永不过期)")
+
+	// shutdown 命令（默认保留限制，-recover 时先恢复所有被限制的虚拟机）
+	shutdownRecover = flag.Bool("recover", false, "shutdown 时先恢复所有被限制的虚拟机再退出")
+
+	// maintenance 命令（维护期间暂停执行规则和自动恢复）
+	maintenanceReasonFlag = flag.String("reason", "", "maintenance on 时记录的维护原因")
+
+	// 输出语言（留空则使用配置中的 locale）
+	langFlag = flag.String("lang", "", "输出语言 (zh-CN/en-US), 默认使用配置中的 locale")
 )
 
 type Monitor struct {
 	configLoader    *config.Loader
 	pveClient       *pve.Client
+	pveConfig       models.PVEConfig // 当前 PVE 客户端使用的连接配置（重载时比较）
 	storage         storage.Interface
 	exporter        *chart.Exporter
 	apiServer       *api.Server
 	watcher         *config.Watcher
 	recoveryManager *recovery.Manager
-	trafficCache    *cache.TrafficCache // 流量统计缓存
-	ipcServer       *ipc.Server         // IPC服务器
+	stats           *stats.Service     // 流量统计服务（缓存与 API 服务器共用）
+	creation        *creation.Resolver // 虚拟机创建时间解析（缓存与 API 服务器共用）
+	ipcServer       *ipc.Server        // IPC服务器
+	instanceLock    *ipc.InstanceLock  // 单实例锁（CLI 模式不获取）
+	startedAt       time.Time          // 启动时间（用于诊断信息）
+	nodeCounter     *pve.NodeCounter   // 节点网卡流量计数器（节点名变化时重建）
+	nodeName        string             // 节点计数器对应的节点
+
+	// 主备模式（monitor.ha）
+	leader         atomic.Bool   // 是否持有主实例租约（接管完成后设置）
+	leaseCheckedAt time.Time     // 上次获取或续期租约的时间（只在续期 goroutine 中使用）
+	leaseExpires   atomic.Int64  // 本实例持有的租约到期时间（UnixNano）
+	leaderChan     chan struct{} // 获得租约后通知主循环接管
+	haStop         chan struct{} // 停止续期 goroutine
+	haDone         chan struct{} // 续期 goroutine 已退出
+
+	// 汇总模式（mode: aggregator）
+	remote        remoteInventory       // 代理节点上的虚拟机
+	nodeClients   map[string]nodeClient // 访问代理节点的 PVE 客户端
+	nodeClientsMu sync.Mutex            // 保护 nodeClients
+
+	ruleQueue chan []models.VMInfo // 代理推送和外部写入的记录等待执行规则的虚拟机
+
+	shutdownChan chan bool // shutdown 命令请求退出（参数为是否恢复虚拟机）
+
+	maintenance atomic.Pointer[models.Maintenance] // 维护模式状态（保存在存储中）
+
+	actions *executor.Executor // 操作执行队列（CLI 模式为 nil，直接执行）
+
+	// 内部事件（CLI 模式为 nil）：通知、审计日志等作为插件订阅
+	events  *events.Bus
+	plugins *events.Plugins
+	warned  sync.Map // 已发布预警的 "vmid/规则" -> 周期开始时间
+
+	exceeded sync.Map // 正在超限的 "vmid/规则"（只在开始超限时记录日志）
+
+	ratioAnomalies sync.Map // 上传/下载比例异常中的虚拟机 VMID（只在异常开始时发布事件）
+
+	samples *sampleTracker // 每台虚拟机最近一次成功采样的时间（检查采集中断）
+
+	protocols protocolSampler   // 客户机代理按协议的流量采集（monitor.ip_split）
+	backups   backupTracker     // 正在备份的虚拟机（monitor.backup_traffic）
+	digests   *digest.Collector // 从事件中收集摘要的异常（CLI 模式为 nil）
+	digest    digestState       // 每日摘要的发送计划
+	power     sync.Map          // 每台虚拟机最近记录的运行状态（vmid -> status，只在变化时读写存储）
 }
 
 func main() {
 	flag.Parse()
+	i18n.SetLocale(*langFlag)
+
+	// config 子命令只检查和输出配置，不创建监控器
+	if flag.Arg(0) == "config" {
+		if err := runConfigCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.config_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// shutdown 子命令请求正在运行的主程序退出
+	if flag.Arg(0) == "shutdown" {
+		if err := runShutdownCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.shutdown_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// maintenance 子命令启用、关闭或查看维护模式
+	if flag.Arg(0) == "maintenance" {
+		if err := runMaintenanceCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.maintenance_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// status 子命令查询正在运行的主程序
+	if flag.Arg(0) == "status" {
+		if err := runStatusCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.status_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// bench 子命令向配置的存储写入合成数据并测试吞吐量，不需要连接 PVE
+	if flag.Arg(0) == "bench" {
+		if err := runBenchCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.bench_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// archive 子命令归档、列出或恢复已结束月份的流量记录，不需要连接 PVE
+	if flag.Arg(0) == "archive" {
+		if err := runArchiveCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.archive_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// fsck 子命令检查存储完整性（-repair 修复），不需要连接 PVE
+	if flag.Arg(0) == "fsck" {
+		if err := runFsckCommand(flag.Args()[1:]); err != nil {
+			fatal(i18n.T("cli.fsck_failed", err))
+		}
+		printResult()
+		return
+	}
 
-	// 检查是否为CLI模式（导出或清除命令）
-	isCliMode := *exportCmd != "" || *cleanupCmd != ""
+	// 检查是否为CLI模式（导出、清除、导入、重新计算、模拟或创建时间命令）
+	isCliMode := *exportCmd != "" || *cleanupCmd != "" || *importFile != "" || *recomputeCmd || *simulateRule != "" || *creationTimeCmd != "" || *digestCmd
 
 	// 加载配置（使用配置加载器）
 	configLoader, err := config.NewLoader(*configPath)
 	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+		fatal(i18n.T("cli.load_config_failed", err))
+	}
+	if *langFlag == "" {
+		i18n.SetLocale(configLoader.GetConfig().Locale)
+	}
+	applyPeriodConfig(configLoader.GetConfig())
+
+	// 采集代理只采集和推送，不创建监控器（命令行操作仍在本地执行）
+	if configLoader.GetConfig().RunMode() == models.ModeAgent && !isCliMode && *publicLinkVMID == 0 {
+		if err := runAgent(configLoader); err != nil {
+			fatal(i18n.T("cli.start_failed", err))
+		}
+		return
+	}
+
+	// 生成公开链接只需要配置，不创建监控器
+	if *publicLinkVMID != 0 {
+		link, expires, err := api.PublicLink(configLoader.GetConfig().API, *publicLinkVMID, *linkTTL)
+		if err != nil {
+			fatal(i18n.T("cli.public_link_failed", err))
+		}
+		fmt.Println(link)
+		if expires.IsZero() {
+			log.Println(i18n.T("cli.public_link_never"))
+		} else {
+			log.Println(i18n.T("cli.public_link_expires", expires.Format("2006-01-02 15:04")))
+		}
+		printResult()
+		return
 	}
 
 	// 创建监控器（CLI模式不启动API服务器）
 	monitor, err := NewMonitor(configLoader, isCliMode)
 	if err != nil {
-		log.Fatalf("创建监控器失败: %v", err)
+		fatal(i18n.T("cli.create_monitor_failed", err))
 	}
 
 	// 处理导出命令
 	if *exportCmd != "" {
 		if err := monitor.handleExport(*exportCmd, *period); err != nil {
-			log.Fatalf("导出失败: %v", err)
+			fatal(i18n.T("cli.export_failed", err))
 		}
+		printResult()
 		return
 	}
 
 	// 处理清除数据命令
 	if *cleanupCmd != "" {
 		if err := monitor.handleCleanup(*cleanupCmd); err != nil {
-			log.Fatalf("清除数据失败: %v", err)
+			fatal(i18n.T("cli.cleanup_failed", err))
 		}
 
 		// 清除完成后，通知主程序（如果在运行）
@@ -93,19 +288,81 @@ func main() {
 			"success": true,
 		})
 
+		printResult()
+		return
+	}
+
+	// 处理导入命令
+	if *importFile != "" {
+		if err := monitor.handleImport(*importFile); err != nil {
+			fatal(i18n.T("cli.import_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// 处理重新计算命令
+	if *recomputeCmd {
+		if err := monitor.handleRecompute(); err != nil {
+			fatal(i18n.T("cli.recompute_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// 处理规则模拟命令
+	if *simulateRule != "" {
+		if err := monitor.handleSimulate(*simulateRule); err != nil {
+			fatal(i18n.T("cli.simulate_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// 处理摘要命令
+	if *digestCmd {
+		if err := monitor.handleDigest(); err != nil {
+			fatal(i18n.T("cli.digest_failed", err))
+		}
+		printResult()
+		return
+	}
+
+	// 处理创建时间命令
+	if *creationTimeCmd != "" {
+		if err := monitor.handleCreationTime(*creationTimeCmd); err != nil {
+			fatal(i18n.T("cli.ctime_failed", err))
+		}
+		printResult()
 		return
 	}
 
 	// 启动监控
-	log.Println("启动 PVE 流量监控程序...")
+	log.Println(i18n.T("cli.starting"))
 	if err := monitor.Start(); err != nil {
-		log.Fatalf("启动监控失败: %v", err)
+		fatal(i18n.T("cli.start_failed", err))
 	}
 }
 
 func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 	cfg := configLoader.GetConfig()
 
+	// 单实例：同一存储只允许一个主程序运行，避免重复采集和重复执行规则
+	var instanceLock *ipc.InstanceLock
+	if !isCliMode {
+		var err error
+		instanceLock, err = ipc.AcquireInstanceLock(ipc.GetDefaultPIDPath(ipcBasePath(cfg)), ipc.InstanceInfo{
+			PID:        os.Getpid(),
+			Hostname:   hostname(),
+			StartedAt:  time.Now(),
+			ConfigPath: absPath(*configPath),
+			Storage:    storageDescription(cfg.Storage),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 创建 PVE 客户端
 	pveClient := pve.NewClient(cfg.PVE)
 	if err := pveClient.Login(); err != nil {
@@ -119,6 +376,38 @@ func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 	}
 	log.Printf("存储类型: %s", cfg.Storage.Type)
 
+	// 文件存储启动检查：隔离上次异常退出时写了一半的记录（CLI 模式可能与主程序同时运行，不检查）
+	if checker, ok := storage.As[storage.ConsistencyChecker](store); ok && !isCliMode {
+		report, err := checker.CheckConsistency(time.Now().Add(-48 * time.Hour))
+		if err != nil {
+			log.Printf("存储一致性检查失败: %v", err)
+		} else if report.RepairedFiles > 0 || report.TempFilesRemoved > 0 {
+			log.Printf("存储一致性检查: 修复 %d 个文件，隔离 %d 行损坏的记录，删除 %d 个残留临时文件",
+				report.RepairedFiles, report.QuarantinedLines, report.TempFilesRemoved)
+		}
+	}
+
+	// 记录存储操作耗时（/metrics 和系统统计），超过阈值的操作记录慢查询日志
+	if !isCliMode {
+		store = storage.NewInstrumentedStorage(store, cfg.Storage.SlowQueryThreshold())
+	}
+
+	// 本地缓冲：存储暂时不可用时保留采样，恢复后重放（CLI 模式不写入采样，不启用）
+	if cfg.Storage.SpoolDir != "" && !isCliMode {
+		spooled, err := storage.NewSpoolStorage(store, cfg.Storage.SpoolDir, cfg.Storage.SpoolMaxRecords)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("创建本地缓冲失败: %w", err)
+		}
+		store = spooled
+	}
+
+	// 远程写入：采集的流量记录同时转发到另一个实例（CLI 模式不写入采样，不启用）
+	if cfg.RemoteWrite.Enabled() && !isCliMode {
+		store = storage.NewRemoteWriteStorage(store, cfg.RemoteWrite, cfg.PVE.Node)
+		log.Printf("远程写入已启用: %s", cfg.RemoteWrite.URL)
+	}
+
 	// 创建图表导出器
 	exporter, err := chart.NewExporter(cfg.Monitor.ExportPath)
 	if err != nil {
@@ -130,28 +419,26 @@ func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 
 	// 创建恢复管理器
 	recoveryMgr := recovery.NewManager(pveClient, store)
+	recoveryMgr.SetMarkerConfig(cfg.Monitor)
 
-	// 从存储加载状态
-	if err := recoveryMgr.LoadStatesFromStorage(); err != nil {
+	// 从存储加载上次退出时保留的限制，继续按计划恢复
+	if loaded, err := recoveryMgr.LoadStatesFromStorage(); err != nil {
 		log.Printf("加载虚拟机状态失败: %v", err)
+	} else if loaded > 0 && !isCliMode {
+		log.Printf("已加载 %d 个虚拟机的恢复记录", loaded)
 	}
 
-	// 创建流量缓存（5分钟TTL）
-	trafficCache := cache.NewTrafficCache(5 * time.Minute)
+	// 创建流量统计服务（Monitor 和 API 服务器共用，统计缓存5分钟TTL）
+	statsService := stats.NewService(store, 5*time.Minute)
+
+	// 创建虚拟机创建时间解析器（meta.ctime 缺失时回退到任务历史和首次采集时间）
+	creationResolver := creation.NewResolver(pveClient, store)
 
 	// 创建IPC服务器（获取合适的socket路径）
 	// CLI模式不创建IPC服务器
 	var ipcServer *ipc.Server
 	if !isCliMode {
-		var socketBasePath string
-		if cfg.Storage.Type == "file" || cfg.Storage.Type == "" {
-			socketBasePath = cfg.Storage.FilePath
-		} else {
-			// 数据库存储，使用临时目录
-			socketBasePath = filepath.Join(os.TempDir(), "pve-traffic-monitor")
-		}
-
-		socketPath := ipc.GetDefaultSocketPath(socketBasePath)
+		socketPath := ipc.GetDefaultSocketPath(ipcBasePath(cfg))
 		ipcServer, err = ipc.NewServer(socketPath)
 		if err != nil {
 			return nil, fmt.Errorf("创建IPC服务器失败: %w", err)
@@ -161,12 +448,34 @@ func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 	monitor := &Monitor{
 		configLoader:    configLoader,
 		pveClient:       pveClient,
+		pveConfig:       cfg.PVE,
 		storage:         store,
 		exporter:        exporter,
 		watcher:         watcher,
 		recoveryManager: recoveryMgr,
-		trafficCache:    trafficCache,
+		stats:           statsService,
+		creation:        creationResolver,
 		ipcServer:       ipcServer,
+		instanceLock:    instanceLock,
+		shutdownChan:    make(chan bool, 1),
+		leaderChan:      make(chan struct{}, 1),
+		samples:         newSampleTracker(),
+	}
+	recoveryMgr.SetClientResolver(monitor.pveFor)
+
+	// 维护期间重启时保持维护模式，不执行规则
+	if !isCliMode {
+		monitor.refreshMaintenance()
+		monitor.actions = executor.New(executorConfig(cfg.Monitor.Actions))
+
+		monitor.events = events.NewBus()
+		monitor.plugins = events.NewPlugins(monitor.events)
+		monitor.loadPlugins(cfg)
+		monitor.digests = digest.NewCollector()
+		monitor.events.Subscribe("digest", monitor.digests.Handle, monitor.digests.Types()...)
+		recoveryMgr.OnRecovered(func(state models.VMState) {
+			monitor.publish(events.Event{Type: events.RecoveryDone, VMID: state.VMID, Rule: state.RuleName, Recovery: &state})
+		})
 	}
 
 	// 注册配置重载回调
@@ -174,7 +483,26 @@ func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 
 	// 如果启用了API服务器且非CLI模式，创建并启动
 	if cfg.API.Enabled && !isCliMode {
-		monitor.apiServer = api.NewServer(cfg, store, pveClient)
+		monitor.apiServer = api.NewServer(cfg, store, pveClient, statsService, creationResolver)
+		monitor.ruleQueue = make(chan []models.VMInfo, ruleQueueSize)
+		go monitor.runQueuedRules()
+		monitor.apiServer.SetIngestHook(monitor.handleIngested)
+		monitor.apiServer.SetEventStats(func() interface{} {
+			return monitor.events.Stats()
+		})
+		monitor.apiServer.SetCollectionStats(func() interface{} {
+			return monitor.samples.stats(time.Now())
+		})
+		monitor.apiServer.SetMaintenanceHandler(func(enabled bool, reason string) (models.Maintenance, error) {
+			return monitor.setMaintenance(enabled, reason, "api")
+		})
+		monitor.apiServer.SetRecoveryHandler(monitor.recoveryManager.Reschedule)
+		if cfg.RunMode() == models.ModeAggregator {
+			monitor.apiServer.SetAgentHandler(monitor.handleAgentPush)
+			monitor.apiServer.SetRemoteVMs(func() []models.VMInfo {
+				return monitor.remote.list(true)
+			})
+		}
 		go func() {
 			if err := monitor.apiServer.Start(); err != nil {
 				log.Printf("API 服务器错误: %v\n", err)
@@ -189,20 +517,63 @@ func NewMonitor(configLoader *config.Loader, isCliMode bool) (*Monitor, error) {
 func (m *Monitor) onConfigReload(newConfig *models.Config) {
 	log.Println("配置已重载")
 
-	// 如果 PVE 连接信息改变，重新登录
-	currentConfig := m.configLoader.GetConfig()
-	if currentConfig.PVE.Host != newConfig.PVE.Host ||
-		currentConfig.PVE.Port != newConfig.PVE.Port ||
-		currentConfig.PVE.APITokenID != newConfig.PVE.APITokenID ||
-		currentConfig.PVE.APITokenSecret != newConfig.PVE.APITokenSecret {
+	if *langFlag == "" {
+		i18n.SetLocale(newConfig.Locale)
+	}
+
+	m.recoveryManager.SetMarkerConfig(newConfig.Monitor)
+	if m.actions != nil {
+		m.actions.SetConfig(executorConfig(newConfig.Monitor.Actions))
+	}
+	m.loadPlugins(newConfig)
+	defer m.publish(events.Event{Type: events.ConfigReloaded})
+
+	// 时区改变后周期边界随之改变，是否计入备份流量改变后用量随之改变，清除统计缓存
+	previousLocation, previousExclude := periodcalc.Location(), storage.ExcludingBackups()
+	applyPeriodConfig(newConfig)
+	if periodcalc.Location() != previousLocation || storage.ExcludingBackups() != previousExclude {
+		m.stats.InvalidateAll()
+	}
+
+	// 如果 PVE 连接地址改变，重新登录（回调中 GetConfig 已是新配置，与客户端使用的配置比较）
+	previousPVE := m.pveConfig
+	m.pveConfig = newConfig.PVE
+	if previousPVE.Host != newConfig.PVE.Host ||
+		previousPVE.Port != newConfig.PVE.Port ||
+		previousPVE.VerifyTLS != newConfig.PVE.VerifyTLS ||
+		previousPVE.CACert != newConfig.PVE.CACert ||
+		previousPVE.Fingerprint != newConfig.PVE.Fingerprint {
 		log.Println("PVE 连接信息已更改，重新登录...")
 		m.pveClient = pve.NewClient(newConfig.PVE)
 		if err := m.pveClient.Login(); err != nil {
 			log.Printf("重新登录失败: %v", err)
 		}
+		m.creation.SetClient(m.pveClient)
+	} else if previousPVE.AuthMode() != newConfig.PVE.AuthMode() ||
+		previousPVE.APITokenID != newConfig.PVE.APITokenID ||
+		previousPVE.APITokenSecret != newConfig.PVE.APITokenSecret ||
+		previousPVE.Username != newConfig.PVE.Username ||
+		previousPVE.Password != newConfig.PVE.Password {
+		// 只有认证信息改变（如密钥轮换）时原地更新，恢复管理器和 API 服务器持有的同一客户端随之生效
+		if err := m.pveClient.UpdateCredentials(newConfig.PVE); err != nil {
+			log.Printf("更新 PVE 认证信息失败: %v", err)
+		} else {
+			log.Println("PVE 认证信息已更新")
+		}
+	}
+
+	// 数据库凭据轮换：新建的连接使用新的连接字符串
+	if changed, err := storage.UpdateCredentials(m.storage, &newConfig.Storage); err != nil {
+		log.Printf("更新数据库连接信息失败: %v", err)
+	} else if changed {
+		log.Println("数据库连接信息已更新")
+	}
+	if instrumented, ok := storage.As[*storage.InstrumentedStorage](m.storage); ok {
+		instrumented.SetSlowThreshold(newConfig.Storage.SlowQueryThreshold())
 	}
 
 	// 如果 API 配置改变，重启 API 服务器
+	currentConfig := m.configLoader.GetConfig()
 	if currentConfig.API.Enabled != newConfig.API.Enabled ||
 		currentConfig.API.Port != newConfig.API.Port {
 		if m.apiServer != nil {
@@ -225,6 +596,7 @@ func countEnabledRules(rules []models.Rule) int {
 
 func (m *Monitor) Start() error {
 	cfg := m.configLoader.GetConfig()
+	defer m.instanceLock.Release()
 	ticker := time.NewTicker(time.Duration(cfg.Monitor.IntervalSeconds) * time.Second)
 	defer ticker.Stop()
 
@@ -242,6 +614,10 @@ func (m *Monitor) Start() error {
 			// 注册消息处理器
 			m.ipcServer.OnMessage("cleanup_done", m.handleCleanupNotification)
 			m.ipcServer.OnMessage("reload_cache", m.handleReloadCacheNotification)
+			m.ipcServer.OnQuery("status", m.handleStatusQuery)
+			m.ipcServer.OnQuery("inventory", m.handleInventoryQuery)
+			m.ipcServer.OnQuery("shutdown", m.handleShutdownQuery)
+			m.ipcServer.OnQuery("maintenance", m.handleMaintenanceQuery)
 		}
 	}
 
@@ -249,11 +625,35 @@ func (m *Monitor) Start() error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// 诊断信号：SIGUSR1 输出诊断信息，SIGUSR2 切换调试日志
+	diagChan := make(chan os.Signal, 1)
+	if len(diagnosticSignals) > 0 {
+		signal.Notify(diagChan, diagnosticSignals...)
+	}
+	defer signal.Stop(diagChan)
+	m.startedAt = time.Now()
+
 	log.Printf("监控已启动 [间隔:%ds PID:%d]", cfg.Monitor.IntervalSeconds, os.Getpid())
 
+	// 主备模式：先确定是否为主实例，备用实例只提供 API；之后在独立的 goroutine 中续期
+	m.renewLease(time.Now())
+	m.takeLeadership()
+	m.startLeaseRenewal()
+
+	// 核对上次运行留下的操作标签和恢复记录（主备模式在成为主实例时核对）
+	if !cfg.Monitor.HA.Enabled {
+		if vms, err := m.allVMs(true); err != nil {
+			log.Printf("获取虚拟机列表失败，跳过标签核对: %v", err)
+		} else {
+			m.reconcileTags(vms)
+		}
+	}
+
 	// 立即执行一次
-	if err := m.collectAndProcess(); err != nil {
-		log.Printf("错误: %v\n", err)
+	if m.isLeader() {
+		if err := m.collectAndProcess(); err != nil {
+			log.Printf("错误: %v\n", err)
+		}
 	}
 
 	// 用于动态调整 ticker 的通道
@@ -269,71 +669,96 @@ func (m *Monitor) Start() error {
 	recoveryTicker := time.NewTicker(1 * time.Minute)
 	defer recoveryTicker.Stop()
 
-	// 定期清理旧数据（每天凌晨3点检查一次）
-	cleanupTicker := time.NewTicker(1 * time.Hour)
+	// 定期清理旧数据（按 cleanup_schedule，默认每天凌晨3点；错过的清理在启动时补做）
+	cleanupTicker := time.NewTicker(1 * time.Minute)
 	defer cleanupTicker.Stop()
-	lastCleanupDay := time.Now().Day()
+	cleanupCron := m.configLoader.GetConfig().Monitor.CleanupCron()
+	if due, missed := m.missedCleanup(time.Now()); missed && m.isLeader() {
+		m.runCleanup(due, true)
+	}
+	nextCleanup := m.nextCleanupAt(time.Now())
 
 	for {
 		select {
 		case <-ticker.C:
+			if !m.isLeader() {
+				continue
+			}
 			if err := m.collectAndProcess(); err != nil {
 				log.Printf("错误: %v\n", err)
 			}
+		case <-m.leaderChan:
+			m.becomeLeader()
 		case <-recoveryTicker.C:
+			if !m.isLeader() {
+				continue
+			}
+
+			// 维护期间不自动恢复，到期的虚拟机在退出维护模式后恢复
+			if m.refreshMaintenance(); m.inMaintenance() {
+				continue
+			}
+
+			// 滑动窗口规则的用量随旧流量移出窗口而回落，回落到限额以下时提前恢复
+			m.recoverRollingWindows()
+
 			// 检查是否有需要恢复的虚拟机
 			if err := m.recoveryManager.CheckAndRecoverDue(); err != nil {
 				log.Printf("检查恢复失败: %v\n", err)
 			}
-		case <-cleanupTicker.C:
-			// 每天凌晨3点清理一次旧数据
-			now := time.Now()
-			if now.Hour() == 3 && now.Day() != lastCleanupDay {
-				cfg := m.configLoader.GetConfig()
-				if cfg.Monitor.DataRetentionDays > 0 {
-					log.Printf("开始清理旧数据 (保留 %d 天)", cfg.Monitor.DataRetentionDays)
-					if err := m.storage.CleanupOldData(cfg.Monitor.DataRetentionDays); err != nil {
-						log.Printf("清理旧数据失败: %v", err)
-					}
+		case now := <-cleanupTicker.C:
+			// 配置重载修改了清理计划时重新计算下次清理时间
+			if expr := m.configLoader.GetConfig().Monitor.CleanupCron(); expr != cleanupCron {
+				cleanupCron = expr
+				nextCleanup = m.nextCleanupAt(now)
+				log.Printf("清理计划已更新为 %q，下次清理: %s", expr, nextCleanup.Format("2006-01-02 15:04"))
+			}
+			if !nextCleanup.IsZero() && !now.Before(nextCleanup) {
+				if m.isLeader() {
+					m.runCleanup(nextCleanup, false)
 				}
-				lastCleanupDay = now.Day()
+				nextCleanup = m.nextCleanupAt(now)
 			}
+			m.checkDigest(now)
 		case newInterval := <-tickerUpdateChan:
 			// 更新监控间隔
 			ticker.Stop()
 			ticker = time.NewTicker(newInterval)
 			log.Printf("监控间隔已更新为: %v\n", newInterval)
+		case sig := <-diagChan:
+			if isDumpSignal(sig) {
+				m.dumpDiagnostics()
+			} else {
+				toggleDebug()
+			}
 		case <-sigChan:
-			log.Println("正在退出...")
-
-			// 获取所有虚拟机
+			// 主备模式下由备用实例接管，维护期间保留限制，都不恢复虚拟机
 			cfg := m.configLoader.GetConfig()
-			vms, err := m.pveClient.GetAllVMsWithFilter(cfg.Monitor.IncludeTemplates)
-			if err == nil {
-				m.recoveryManager.CleanupAllTags(vms)
-			}
-
-			// 恢复所有虚拟机
-			m.recoveryManager.RecoverAll()
-
-			// 关闭存储（保存计数器等）
-			if err := m.storage.Close(); err != nil {
-				log.Printf("关闭存储失败: %v", err)
-			}
-
-			log.Println("已退出")
+			m.exit(cfg.Monitor.RecoversOnExit() && !cfg.Monitor.HA.Enabled && !m.inMaintenance())
+			return nil
+		case recoverVMs := <-m.shutdownChan:
+			m.exit(recoverVMs)
 			return nil
 		}
 	}
 }
 
 func (m *Monitor) collectAndProcess() error {
+	// 维护模式可能由其他实例或离线的 maintenance 命令修改
+	m.refreshMaintenance()
+
 	// 获取所有虚拟机（根据配置决定是否包含模板）
 	cfg := m.configLoader.GetConfig()
-	vms, err := m.pveClient.GetAllVMsWithFilter(cfg.Monitor.IncludeTemplates)
+	start := time.Now()
+	vms, err := m.pveClient.GetAllVMsWithFilter(cfg.Monitor.ListsTemplates())
 	if err != nil {
+		// 无法获取虚拟机列表时按上次的运行状态检查采集中断
+		m.checkGaps(nil, start)
 		return fmt.Errorf("获取虚拟机列表失败: %w", err)
 	}
+	if cfg.Monitor.DetectsBackups() {
+		m.backups.refresh(m.pveClient, vms)
+	}
 
 	// 使用worker pool并发处理
 	const maxWorkers = models.MaxWorkers
@@ -348,6 +773,7 @@ func (m *Monitor) collectAndProcess() error {
 			defer wg.Done()
 			for vm := range vmChan {
 				if err := m.processVM(vm); err != nil {
+					m.samples.failed(vm.VMID, err, time.Now())
 					log.Printf("虚拟机 %d 处理失败: %v", vm.VMID, err)
 				}
 			}
@@ -362,53 +788,149 @@ func (m *Monitor) collectAndProcess() error {
 
 	// 等待所有worker完成
 	wg.Wait()
+	m.samples.finishCycle(start, time.Now())
+	all := append(vms, m.remote.list(true)...)
+	m.checkGaps(all, time.Now())
+	m.trackPowerStates(all, start)
+	m.applyHostRules(all)
+
+	if cfg.Monitor.NodeStatsEnabled() {
+		m.collectNodeTraffic(cfg.PVE.Node)
+	}
 
 	return nil
 }
 
-func (m *Monitor) processVM(vm models.VMInfo) error {
-	// 再次检查是否为模板（双重保险）
-	if vm.IsTemplate() {
-		return nil
+// collectNodeTraffic 采集 PVE 节点自身的网卡流量（用于对比虚拟机流量总和与实际上行用量）
+func (m *Monitor) collectNodeTraffic(node string) {
+	if m.nodeCounter == nil || m.nodeName != node {
+		m.nodeCounter = &pve.NodeCounter{}
+		m.nodeName = node
+
+		// 从最近保存的记录继续累加，补上程序重启期间 RRD 仍保留的采样点
+		now := time.Now()
+		records, err := storage.GetNodeTrafficRecords(m.storage, node, now.Add(-time.Hour), now)
+		if err != nil {
+			debugLog("读取节点 %s 的流量记录失败，从当前开始累计: %v", node, err)
+		} else if len(records) > 0 {
+			m.nodeCounter.Resume(records[len(records)-1])
+		}
 	}
 
-	// 获取最新状态
-	status, err := m.pveClient.GetVMStatus(vm.VMID)
+	points, err := m.pveClient.GetNodeNetRRD()
 	if err != nil {
-		return err
+		debugLog("获取节点 %s 网络流量失败: %v", node, err)
+		return
 	}
 
-	// 保存流量记录
-	now := time.Now()
-	record := models.TrafficRecord{
-		VMID:       vm.VMID,
-		Timestamp:  now,
-		RXBytes:    status.NetworkRX,
-		TXBytes:    status.NetworkTX,
-		TotalBytes: status.NetworkRX + status.NetworkTX,
+	for _, record := range m.nodeCounter.Add(points) {
+		if err := storage.SaveNodeTrafficRecord(m.storage, node, record); err != nil {
+			log.Printf("保存节点流量记录失败: %v", err)
+			return
+		}
 	}
+}
+
+func (m *Monitor) processVM(vm models.VMInfo) error {
+	// 模板只在 templates 为 record/enforce 时出现在列表中，是否执行规则由规则匹配判断
+	cfg := m.configLoader.GetConfig().Monitor
+	if m.shouldSample(vm, cfg) {
+		record, err := sampleVM(m.pveClient, vm.VMID, cfg.Bridges)
+		if err != nil {
+			return err
+		}
+		if cfg.IPSplit {
+			m.protocols.sample(m.pveClient, vm, &record)
+		}
+		if cfg.DetectsBackups() {
+			m.backups.mark(&record)
+		}
 
-	if err := m.storage.SaveTrafficRecord(record); err != nil {
-		return fmt.Errorf("保存流量记录失败: %w", err)
+		// 通过统计服务保存，使该虚拟机的统计和 API 响应缓存失效
+		if err := m.stats.SaveTrafficRecord(record); err != nil {
+			return fmt.Errorf("保存流量记录失败: %w", err)
+		}
+		m.sampleCollected(vm.VMID, record.Timestamp)
+		m.publish(events.Event{Type: events.SampleCollected, VMID: vm.VMID, Time: record.Timestamp, Record: &record})
 	}
-	m.trafficCache.Invalidate(vm.VMID)
 
 	// 检查并应用规则
 	// 只有匹配规则的虚拟机才会被打标签
 	if err := m.applyRules(vm); err != nil {
 		log.Printf("应用规则失败 (VM %d): %v\n", vm.VMID, err)
 	}
+	m.checkRatio(vm)
 
 	return nil
 }
 
+// shouldSample 本次是否采样：运行中的虚拟机每次都采样，已停止的虚拟机按 stopped_intervals 降低频率
+// （不采样时仍检查规则，停止期间用量不变）
+func (m *Monitor) shouldSample(vm models.VMInfo, cfg models.MonitorConfig) bool {
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	every := cfg.StoppedSampleInterval()
+	if vm.Status == "running" || every == interval {
+		return true
+	}
+	sample := m.samples.sampleStopped(vm.VMID, every, interval, time.Now())
+	if !sample {
+		debugLog("VM%d 已停止，本次不采样", vm.VMID)
+	}
+	return sample
+}
+
+// sampleVM 采集虚拟机当前的流量计数（本节点和采集代理共用）
+func sampleVM(client *pve.Client, vmid int, filter models.BridgeFilter) (models.TrafficRecord, error) {
+	status, err := client.GetVMStatus(vmid)
+	if err != nil {
+		return models.TrafficRecord{}, err
+	}
+
+	// 按网桥过滤时只统计计入配额的网卡（不计内部存储、备份网络的流量）
+	rx, tx := status.NetworkRX, status.NetworkTX
+	if filter.Enabled() {
+		rx, tx = countedTraffic(client, status, filter)
+	}
+
+	return models.TrafficRecord{
+		VMID:       vmid,
+		Timestamp:  time.Now(),
+		RXBytes:    rx,
+		TXBytes:    tx,
+		TotalBytes: rx + tx,
+		DiskRead:   status.DiskRead,
+		DiskWrite:  status.DiskWrite,
+	}, nil
+}
+
+// countedTraffic 按网桥过滤汇总网卡流量，无法获取网卡计数或配置时使用 PVE 汇总的流量
+func countedTraffic(client *pve.Client, status *models.VMInfo, filter models.BridgeFilter) (uint64, uint64) {
+	config, err := client.GetVMConfig(status.VMID)
+	if err != nil {
+		debugLog("VM%d 获取网卡配置失败，统计所有网卡的流量: %v", status.VMID, err)
+		return status.NetworkRX, status.NetworkTX
+	}
+	rx, tx, ok := pve.CountedTraffic(status, config, filter)
+	if !ok {
+		debugLog("VM%d 状态中没有网卡计数，统计所有网卡的流量", status.VMID)
+		return status.NetworkRX, status.NetworkTX
+	}
+	return rx, tx
+}
+
 func (m *Monitor) applyRules(vm models.VMInfo) error {
+	// 维护期间只采集数据，不执行规则
+	if m.inMaintenance() {
+		return nil
+	}
+
 	cfg := m.configLoader.GetConfig()
 
 	// 1. 收集该VM匹配的所有规则
 	var matchedRules []models.Rule
 	for _, rule := range cfg.Rules {
-		if !rule.Enabled {
+		// 主机规则按所有虚拟机的总和判断，在采集周期结束后统一检查
+		if !rule.Enabled || rule.IsHostRule() {
 			continue
 		}
 
@@ -422,12 +944,17 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 	if len(matchedRules) == 0 {
 		return nil
 	}
+	models.SortRulesByPriority(matchedRules)
 
-	// 2. 按 (period, direction, useCreationTime) 分组，避免重复计算
+	// 2. 按 (period, direction, useCreationTime, metric) 分组，避免重复计算
 	type StatsKey struct {
 		Period          string
 		Direction       string
 		UseCreationTime bool
+		Timezone        string
+		AnchorDay       int
+		AnchorHour      int
+		Metric          string
 	}
 
 	statsMap := make(map[StatsKey]*models.TrafficStats)
@@ -435,6 +962,10 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 
 	// 3. 对每组只计算一次
 	for _, rule := range matchedRules {
+		if rule.IsRateRule() || rule.IsPercentileRule() {
+			continue
+		}
+
 		direction := "both"
 		if rule.TrafficDirection != "" {
 			direction = rule.TrafficDirection
@@ -444,6 +975,10 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 			Period:          rule.Period,
 			Direction:       direction,
 			UseCreationTime: rule.UseCreationTime,
+			Timezone:        rule.Timezone,
+			AnchorDay:       rule.AnchorDay,
+			AnchorHour:      rule.AnchorHour,
+			Metric:          rule.Metric,
 		}
 
 		// 如果已经计算过这个组合，跳过
@@ -452,7 +987,7 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 		}
 
 		// 计算流量统计
-		stats, err := m.calculateTrafficStatsWithCache(vm.VMID, rule.Period, direction, rule.UseCreationTime, &vmCreationTime)
+		stats, err := m.calculateTrafficStatsWithCache(vm.VMID, rule, direction, &vmCreationTime)
 		if err != nil {
 			log.Printf("计算流量统计失败 (VM %d): %v", vm.VMID, err)
 			continue
@@ -461,8 +996,22 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 		statsMap[key] = stats
 	}
 
-	// 4. 使用计算结果检查所有匹配的规则
+	// 4. 使用计算结果检查所有匹配的规则，超限的规则检查完后按冲突处理策略统一提交
+	var exceeded []exceededRule
 	for _, rule := range matchedRules {
+		if rule.IsRateRule() {
+			if e := m.applyRateRule(vm, rule, &vmCreationTime); e != nil {
+				exceeded = append(exceeded, *e)
+			}
+			continue
+		}
+		if rule.IsPercentileRule() {
+			if e := m.applyPercentileRule(vm, rule, &vmCreationTime); e != nil {
+				exceeded = append(exceeded, *e)
+			}
+			continue
+		}
+
 		direction := "both"
 		if rule.TrafficDirection != "" {
 			direction = rule.TrafficDirection
@@ -472,6 +1021,10 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 			Period:          rule.Period,
 			Direction:       direction,
 			UseCreationTime: rule.UseCreationTime,
+			Timezone:        rule.Timezone,
+			AnchorDay:       rule.AnchorDay,
+			AnchorHour:      rule.AnchorHour,
+			Metric:          rule.Metric,
 		}
 
 		stats, exists := statsMap[key]
@@ -480,129 +1033,275 @@ func (m *Monitor) applyRules(vm models.VMInfo) error {
 		}
 
 		// 为每个匹配的规则打独立的流量状态标签
-		if err := m.pveClient.AutoTagByTrafficWithRule(vm.VMID, stats.TotalGB, rule.LimitGB, rule.Name); err != nil {
+		if err := m.pveFor(vm.VMID).AutoTagByTrafficWithRule(vm.VMID, stats.TotalGB, rule.LimitGB, cfg.Monitor.Tags.RuleTag(rule.Name)); err != nil {
 			debugLog("自动打流量标签失败 (VM %d, 规则 %s): %v", vm.VMID, rule.Name, err)
 		}
 
-		// 检查是否超出限制
+		// 接近限额时预警，超出限制时执行操作
+		m.checkLimitWarning(vm, rule, stats)
 		if stats.TotalGB > rule.LimitGB {
-			directionText := getDirectionText(stats.Direction)
-			log.Printf("VM%d 超%s流量限制 %.2f/%.2f GB [%s]",
-				vm.VMID, directionText, stats.TotalGB, rule.LimitGB, rule.Name)
-
-			// 执行操作（传递创建时间信息）
-			if err := m.executeAction(vm, rule, stats, vmCreationTime); err != nil {
-				log.Printf("执行操作失败: %v", err)
-				// 继续执行其他规则
+			reason := fmt.Sprintf("超出流量限制: %.2f GB / %.2f GB", stats.TotalGB, rule.LimitGB)
+			if m.limitExceeded(vm, rule, reason, volumeUsage(rule, stats)) {
+				directionText := getDirectionText(stats.Direction)
+				log.Printf("VM%d 超%s流量限制 %.2f/%.2f GB [%s]",
+					vm.VMID, directionText, stats.TotalGB, rule.LimitGB, rule.Name)
 			}
+
+			exceeded = append(exceeded, exceededRule{rule: rule, reason: reason, usage: hook.Usage{Used: stats.TotalGB, Limit: rule.LimitGB, Unit: "GB"}})
+		} else {
+			m.clearExceeded(vm.VMID, rule)
 		}
 	}
 
+	// 交给执行队列（传递创建时间信息）
+	m.submitExceeded(vm, exceeded, cfg.Monitor.ConflictResolution(), vmCreationTime)
 	return nil
 }
 
-// calculateTrafficStatsWithCache 带缓存的流量统计计算
-func (m *Monitor) calculateTrafficStatsWithCache(vmid int, period string, direction string, useCreationTime bool, vmCreationTime *time.Time) (*models.TrafficStats, error) {
-	now := time.Now()
-	var startTime time.Time
-	var creationTime time.Time
+// applyRateRule 检查带宽规则：统计窗口内的平均带宽超过阈值时返回需要执行的操作
+func (m *Monitor) applyRateRule(vm models.VMInfo, rule models.Rule, vmCreationTime *time.Time) *exceededRule {
+	direction := "both"
+	if rule.TrafficDirection != "" {
+		direction = rule.TrafficDirection
+	}
 
-	// 计算周期开始时间
-	if useCreationTime {
-		ct, err := m.pveClient.GetVMCreationTime(vmid)
-		if err == nil {
-			creationTime = ct
-			*vmCreationTime = ct
-			startTime = m.calculatePeriodStart(period, creationTime, now)
-		} else {
-			startTime = m.calculateFixedPeriodStart(period, now)
+	mbps, covered, err := m.calculateAverageRate(vm.VMID, direction, rule.RateWindow(), rule.Metric)
+	if err != nil {
+		log.Printf("计算平均带宽失败 (VM %d): %v", vm.VMID, err)
+		return nil
+	}
+	if !covered {
+		debugLog("VM%d 采样点不足以覆盖 %v 统计窗口，跳过带宽规则 %s", vm.VMID, rule.RateWindow(), rule.Name)
+		return nil
+	}
+
+	// 流量状态标签按 GB 用量分级，带宽规则不打该标签
+	if mbps <= rule.RateThresholdMbps {
+		m.clearExceeded(vm.VMID, rule)
+		return nil
+	}
+
+	reason := fmt.Sprintf("超出带宽限制: %.2f Mbps / %.2f Mbps (%v 平均)", mbps, rule.RateThresholdMbps, rule.RateWindow())
+	if rule.IsDiskRule() {
+		reason = fmt.Sprintf("超出磁盘吞吐限制: %.2f Mbps / %.2f Mbps (%v 平均)", mbps, rule.RateThresholdMbps, rule.RateWindow())
+	} else if rule.IsProtocolRule() {
+		reason = fmt.Sprintf("超出 %s 带宽限制: %.2f Mbps / %.2f Mbps (%v 平均)", strings.ToUpper(rule.Metric), mbps, rule.RateThresholdMbps, rule.RateWindow())
+	}
+	if m.limitExceeded(vm, rule, reason, nil) {
+		// 磁盘规则的 rx/tx 表示读取/写入，不按网络方向描述
+		limitText := getDirectionText(direction) + "带宽"
+		if rule.IsDiskRule() {
+			limitText = "磁盘吞吐"
+		} else if rule.IsProtocolRule() {
+			limitText = getDirectionText(direction) + strings.ToUpper(rule.Metric) + "带宽"
 		}
-	} else {
-		startTime = m.calculateFixedPeriodStart(period, now)
+		log.Printf("VM%d 超%s限制 %.2f/%.2f Mbps (%v 平均) [%s]",
+			vm.VMID, limitText, mbps, rule.RateThresholdMbps, rule.RateWindow(), rule.Name)
 	}
 
-	// 尝试从缓存获取
-	if cachedStats, ok := m.trafficCache.Get(vmid, period, direction, startTime); ok {
-		debugLog("缓存命中: VM%d period=%s direction=%s", vmid, period, direction)
-		return cachedStats, nil
+	if rule.UseCreationTime && vmCreationTime.IsZero() {
+		if ct, err := m.creation.Time(vm.VMID); err == nil {
+			*vmCreationTime = ct
+		}
 	}
 
-	debugLog("缓存未命中: VM%d period=%s direction=%s", vmid, period, direction)
+	return &exceededRule{rule: rule, reason: reason, usage: hook.Usage{Used: mbps, Limit: rule.RateThresholdMbps, Unit: "Mbps"}}
+}
 
-	// 缓存未命中，计算统计
-	var stats *models.TrafficStats
-	var err error
+// applyPercentileRule 检查 95 百分位规则：周期内 5 分钟平均带宽的 95 百分位超过阈值时返回需要执行的操作
+// 周期开始不久区间数不足时百分位接近最大值，至少有 MinPercentileSamples 个区间才判断
+func (m *Monitor) applyPercentileRule(vm models.VMInfo, rule models.Rule, vmCreationTime *time.Time) *exceededRule {
+	direction := models.DirectionBoth
+	if rule.TrafficDirection != "" {
+		direction = rule.TrafficDirection
+	}
 
-	if useCreationTime && !creationTime.IsZero() {
-		stats, err = m.storage.CalculateTrafficStatsWithDirection(vmid, period, creationTime, true, direction)
-	} else {
-		stats, err = m.storage.CalculateTrafficStatsWithDirection(vmid, period, time.Time{}, false, direction)
+	var creationTime time.Time
+	if rule.UseCreationTime {
+		if ct, err := m.creation.Time(vm.VMID); err == nil {
+			*vmCreationTime = ct
+			creationTime = ct
+		}
 	}
 
+	now := time.Now()
+	startTime := periodcalc.ForRule(rule, creationTime).PeriodStartAt(now)
+	records, err := m.storage.GetTrafficRecords(vm.VMID, startTime, now)
 	if err != nil {
-		return nil, err
+		log.Printf("获取流量记录失败 (VM %d): %v", vm.VMID, err)
+		return nil
 	}
 
-	// 存入缓存
-	m.trafficCache.Set(vmid, period, direction, startTime, stats)
+	profile := storage.UsageProfileOf(vm.VMID, rule.Period, startTime, now, direction, storage.MetricRecords(records, rule.Metric))
+	if profile.Samples < storage.MinPercentileSamples {
+		debugLog("VM%d 周期内只有 %d 个 5 分钟区间，跳过 95 百分位规则 %s", vm.VMID, profile.Samples, rule.Name)
+		return nil
+	}
+	if profile.P95Mbps <= rule.RateThresholdMbps {
+		m.clearExceeded(vm.VMID, rule)
+		return nil
+	}
 
-	return stats, nil
+	reason := fmt.Sprintf("超出 95 百分位带宽限制: %.2f Mbps / %.2f Mbps", profile.P95Mbps, rule.RateThresholdMbps)
+	if m.limitExceeded(vm, rule, reason, &events.Usage{Period: rule.Period, PeriodStart: startTime}) {
+		log.Printf("VM%d 超%s 95 百分位带宽限制 %.2f/%.2f Mbps (%d 个区间) [%s]",
+			vm.VMID, getDirectionText(direction), profile.P95Mbps, rule.RateThresholdMbps, profile.Samples, rule.Name)
+	}
+	return &exceededRule{rule: rule, reason: reason, usage: hook.Usage{Used: profile.P95Mbps, Limit: rule.RateThresholdMbps, Unit: "Mbps"}}
 }
 
-// calculateFixedPeriodStart 计算固定周期的开始时间
-func (m *Monitor) calculateFixedPeriodStart(period string, now time.Time) time.Time {
-	switch period {
-	case models.PeriodHour:
-		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
-	case models.PeriodDay:
-		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	case models.PeriodMonth:
-		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	default:
-		return now
+// calculateAverageRate 计算最近 window 内的平均带宽（Mbps），metric 为统计对象（disk 时计算磁盘读写吞吐）
+// covered 表示采样点是否覆盖了足够的窗口（至少为窗口减去一个采集间隔）
+func (m *Monitor) calculateAverageRate(vmid int, direction string, window time.Duration, metric string) (mbps float64, covered bool, err error) {
+	now := time.Now()
+	records, err := m.storage.GetTrafficRecords(vmid, now.Add(-window), now)
+	if err != nil {
+		return 0, false, err
 	}
+	records = storage.MetricRecords(records, metric)
+
+	bitsPerSecond, span := storage.CalculateAverageRate(vmid, records, direction)
+	return bitsPerSecond / 1_000_000, span > 0 && span >= m.minWindowSpan(window), nil
 }
 
-// calculatePeriodStart 计算基于创建时间的周期开始时间
-func (m *Monitor) calculatePeriodStart(period string, creationTime, now time.Time) time.Time {
-	return periodcalc.CalculateCreationBasedPeriodStart(period, creationTime, now)
+// minWindowSpan 采样点覆盖统计窗口所需的最短时间跨度（窗口减去一个采集间隔，窗口不大于采集间隔时为一半）
+func (m *Monitor) minWindowSpan(window time.Duration) time.Duration {
+	interval := time.Duration(m.configLoader.GetConfig().Monitor.IntervalSeconds) * time.Second
+	minSpan := window - interval
+	if minSpan <= 0 {
+		minSpan = window / 2
+	}
+	return minSpan
 }
 
-func (m *Monitor) vmMatchesRule(vm models.VMInfo, rule models.Rule) bool {
-	// 使用统一的规则匹配函数
-	return pve.VMMatchesRule(vm, rule)
+// calculateTrafficStatsWithCache 带缓存的流量统计计算（缓存由统计服务管理，与 API 服务器共用）
+// 周期按规则的时区、账单日和创建时间基准划分
+func (m *Monitor) calculateTrafficStatsWithCache(vmid int, rule models.Rule, direction string, vmCreationTime *time.Time) (*models.TrafficStats, error) {
+	var creationTime time.Time
+	if rule.UseCreationTime {
+		result, err := m.creation.Resolve(vmid)
+		if err == nil {
+			debugLog("VM%d 创建时间 %s (来源: %s)", vmid, result.Time.Format("2006-01-02 15:04:05"), result.Source)
+			*vmCreationTime = result.Time
+			creationTime = result.Time
+		} else {
+			debugLog("VM%d %v，使用固定周期", vmid, err)
+		}
+	}
+	return m.stats.CalculateMetricFor(periodcalc.ForRule(rule, creationTime), vmid, direction, rule.Metric)
 }
 
-func (m *Monitor) executeAction(vm models.VMInfo, rule models.Rule, stats *models.TrafficStats, creationTime time.Time) error {
-	actionLog := models.ActionLog{
-		VMID:      vm.VMID,
-		RuleName:  rule.Name,
-		Action:    rule.Action,
-		Reason:    fmt.Sprintf("超出流量限制: %.2f GB / %.2f GB", stats.TotalGB, rule.LimitGB),
-		Timestamp: time.Now(),
+// applyPeriodConfig 设置周期边界和图表时间使用的全局时区（未配置时使用主机本地时区）、minute 周期的统计窗口、跨边界增量的拆分间隔
+// 和是否去掉备份期间的流量
+func applyPeriodConfig(cfg *models.Config) {
+	loc, err := periodcalc.LoadLocation(cfg.Timezone)
+	if err != nil {
+		log.Printf("时区无效，使用主机本地时区: %v", err)
 	}
+	periodcalc.SetLocation(loc)
+	models.SetMinuteWindow(cfg.Monitor.MinuteWindow())
+	storage.SetBoundarySplitLimit(cfg.Monitor.GapThreshold())
+	storage.SetExcludeBackups(cfg.Monitor.ExcludesBackups())
+}
 
-	// 检查是否已经执行过该操作（通过检查对应的标签）
-	actionTag := ""
-	switch rule.Action {
-	case models.ActionShutdown, models.ActionStop:
-		actionTag = models.TagTrafficShutdown
-	case models.ActionDisconnect:
-		actionTag = models.TagTrafficDisconnect
-	case models.ActionRateLimit:
-		actionTag = models.TagTrafficLimited
+// calculatePeriodStart 计算基于创建时间的周期开始时间
+func (m *Monitor) calculatePeriodStart(period string, creationTime, now time.Time) time.Time {
+	return periodcalc.NewCalculator(period, creationTime, true).In(now.Location()).PeriodStartAt(now)
+}
+
+func (m *Monitor) vmMatchesRule(vm models.VMInfo, rule models.Rule) bool {
+	if vm.IsTemplate() && !rule.AppliesToTemplates(m.configLoader.GetConfig().Monitor.TemplateMode()) {
+		return false
 	}
+	// 使用统一的规则匹配函数
+	return pve.VMMatchesRule(vm, rule)
+}
 
-	if rule.Action == models.ActionRateLimit {
-		needsTighten, err := m.pveClient.ShouldTightenNetworkRateLimit(vm.VMID, rule.RateLimitMB)
+func (m *Monitor) executeAction(vm models.VMInfo, rule models.Rule, reason string, usage hook.Usage, creationTime time.Time, overridden []string) error {
+	actionLog := models.ActionLog{
+		VMID:            vm.VMID,
+		RuleName:        rule.Name,
+		Action:          rule.Action,
+		Reason:          reason,
+		Timestamp:       time.Now(),
+		OverriddenRules: overridden,
+	}
+
+	// 先检查存储中的执行记录：本周期已按该规则执行过（且之后没有恢复）时跳过，不需要访问 PVE，标签被删除也不会重复执行
+	calc := periodcalc.ForRule(rule, creationTime)
+	periodStart := calc.GetCurrentPeriodStart()
+	if window, rolling := calc.Rolling(); rolling {
+		periodStart = time.Time{}
+		// exec 操作不需要恢复，滑动窗口规则按窗口长度划分，每个窗口最多执行一次
+		if rule.Action == models.ActionExec {
+			periodStart = time.Now().Truncate(window)
+		}
+	}
+	executed, err := storage.LoadExecutedAction(m.storage, vm.VMID, rule.Name)
+	if err != nil {
+		debugLog("VM%d 读取执行记录失败: %v", vm.VMID, err)
+	}
+	executions := 1
+	reenforce := false
+	if executed != nil {
+		if cooldown := rule.Cooldown(); cooldown > 0 && time.Since(executed.ExecutedAt) < cooldown {
+			debugLog("VM%d 规则 %s 冷却中（上次执行于 %s），跳过执行", vm.VMID, rule.Name, executed.ExecutedAt.Format(time.RFC3339))
+			return nil
+		}
+		if executed.Covers(rule.Action, periodStart, m.recoveryManager.LastRecoveredAt(vm.VMID)) {
+			if executed.Executions() >= rule.ExecutionLimit() {
+				debugLog("VM%d 本周期已按规则 %s 执行过操作 %s（%d 次），跳过重复执行", vm.VMID, rule.Name, rule.Action, executed.Executions())
+				return nil
+			}
+			executions = executed.Executions() + 1
+			reenforce = true
+		}
+	}
+
+	// 没有执行记录（如升级前执行的操作）时通过对应的标签或备注中的状态块判断，都不使用时检查恢复状态和操作日志
+	monitorConfig := m.configLoader.GetConfig().Monitor
+	useDescription := monitorConfig.MarkerBackend() == models.MarkerDescription
+	manageTags := !useDescription && monitorConfig.TagsManaged()
+	actionTag := monitorConfig.Tags.ActionTag(rule.Action)
+
+	if reenforce {
+		// 本周期已执行过但允许多次执行：限制仍然有效时不重复执行，被手动解除（如管理员开机、恢复网络）后再次执行
+		if m.restrictionInEffect(vm, rule) {
+			debugLog("VM%d 规则 %s 的操作 %s 仍然有效，跳过再次执行", vm.VMID, rule.Name, rule.Action)
+			return nil
+		}
+		log.Printf("VM%d 本周期第 %d 次按规则 %s 执行操作 %s", vm.VMID, executions, rule.Name, rule.Action)
+	} else if rule.Action == models.ActionExec {
+		// exec 操作不标记虚拟机，只通过执行记录（或不管理标签时的操作日志）判断
+		if m.actionAlreadyTaken(vm.VMID, rule, creationTime) {
+			debugLog("VM%d 已执行过操作 %s，跳过重复执行", vm.VMID, rule.Action)
+			return nil
+		}
+	} else if rule.Action == models.ActionRateLimit {
+		needsTighten, err := m.pveFor(vm.VMID).ShouldTightenNetworkRateLimit(vm.VMID, rule.RateLimitMB, rule.Interfaces)
 		if err == nil && !needsTighten {
 			debugLog("VM%d 当前限速已不高于目标 %.2fMB/s，跳过重复限速",
 				vm.VMID, rule.RateLimitMB)
 			return nil
 		}
+	} else if useDescription {
+		marker, err := m.pveFor(vm.VMID).GetVMMarker(vm.VMID)
+		if err != nil {
+			debugLog("VM%d 读取备注中的限制状态失败: %v", vm.VMID, err)
+		}
+		if (marker != nil && models.SameAction(marker.Action, rule.Action)) ||
+			(err != nil && m.actionAlreadyTaken(vm.VMID, rule, creationTime)) {
+			debugLog("VM%d 已执行过操作 %s，跳过重复执行", vm.VMID, rule.Action)
+			return nil
+		}
+	} else if !manageTags {
+		if m.actionAlreadyTaken(vm.VMID, rule, creationTime) {
+			debugLog("VM%d 已执行过操作 %s，跳过重复执行", vm.VMID, rule.Action)
+			return nil
+		}
 	} else if actionTag != "" {
 		// 如果已经有对应的标签，说明操作已执行，跳过
-		tags, err := m.pveClient.GetVMTags(vm.VMID)
+		tags, err := m.pveFor(vm.VMID).GetVMTags(vm.VMID)
 		if err == nil {
 			for _, tag := range tags {
 				if strings.ToLower(tag) == actionTag {
@@ -613,51 +1312,66 @@ func (m *Monitor) executeAction(vm models.VMInfo, rule models.Rule, stats *model
 		}
 	}
 
-	// 先记录虚拟机状态（在执行操作前，传递创建时间信息）
-	if err := m.recoveryManager.RecordVMState(vm.VMID, rule.Action, rule.Period, rule.Name, rule.UseCreationTime, creationTime); err != nil {
-		log.Printf("记录虚拟机状态失败 (VM %d): %v\n", vm.VMID, err)
+	// 先记录虚拟机状态（在执行操作前，传递创建时间信息）；exec 操作不限制虚拟机，不需要恢复
+	// 再次执行时保留第一次执行前记录的原始状态，恢复时回到限制之前
+	if rule.Action != models.ActionExec && !(reenforce && m.recoveryManager.ActionActive(vm.VMID, rule.Action)) {
+		if err := m.recoveryManager.RecordVMState(vm.VMID, rule.Action, rule.Name, rule.Interfaces, calc); err != nil {
+			log.Printf("记录虚拟机状态失败 (VM %d): %v\n", vm.VMID, err)
+		}
 	}
+	req := hook.Request{VM: vm, Node: m.vmNode(vm.VMID), Rule: rule, Reason: reason, Usage: usage}
 
-	var err error
+	var markErr error
+	var upid string
 	switch rule.Action {
 	case models.ActionShutdown:
 		if rule.ForceStop {
 			log.Printf("执行操作: VM%d 强制停止", vm.VMID)
-			err = m.pveClient.StopVM(vm.VMID)
+			upid, err = m.pveFor(vm.VMID).StopVM(vm.VMID)
 		} else {
 			log.Printf("执行操作: VM%d 关机", vm.VMID)
-			err = m.pveClient.ShutdownVM(vm.VMID)
+			upid, err = m.pveFor(vm.VMID).ShutdownVM(vm.VMID)
 		}
 
+		// 任务成功结束后才标记，失败或超时时下个周期重新执行
+		if err == nil {
+			err = m.waitTask(&actionLog, upid, monitorConfig)
+		}
 		if err == nil {
-			m.pveClient.AddVMTag(vm.VMID, models.TagTrafficShutdown)
+			markErr = m.markEnforced(vm.VMID, rule, reason, monitorConfig)
 		}
 
 	case models.ActionStop:
 		log.Printf("执行操作: VM%d 强制停止", vm.VMID)
-		err = m.pveClient.StopVM(vm.VMID)
+		upid, err = m.pveFor(vm.VMID).StopVM(vm.VMID)
 
 		if err == nil {
-			m.pveClient.AddVMTag(vm.VMID, models.TagTrafficShutdown)
+			err = m.waitTask(&actionLog, upid, monitorConfig)
+		}
+		if err == nil {
+			markErr = m.markEnforced(vm.VMID, rule, reason, monitorConfig)
 		}
 
 	case models.ActionDisconnect:
 		log.Printf("执行操作: VM%d 断网", vm.VMID)
-		err = m.pveClient.DisconnectNetwork(vm.VMID)
+		err = m.pveFor(vm.VMID).DisconnectNetwork(vm.VMID, rule.Interfaces)
 
 		if err == nil {
-			m.pveClient.AddVMTag(vm.VMID, models.TagTrafficDisconnect)
+			markErr = m.markEnforced(vm.VMID, rule, reason, monitorConfig)
 		}
 
 	case models.ActionRateLimit:
 		log.Printf("执行操作: VM%d 限速至 %.2fMB/s", vm.VMID, rule.RateLimitMB)
 		var applied bool
-		applied, err = m.pveClient.TightenNetworkRateLimit(vm.VMID, rule.RateLimitMB)
+		applied, err = m.pveFor(vm.VMID).TightenNetworkRateLimit(vm.VMID, rule.RateLimitMB, rule.Interfaces)
 
 		if err == nil && applied {
-			m.pveClient.AddVMTag(vm.VMID, models.TagTrafficLimited)
+			markErr = m.markEnforced(vm.VMID, rule, reason, monitorConfig)
 		}
 
+	case models.ActionExec:
+		err = m.runExecAction(&actionLog, req)
+
 	default:
 		err = fmt.Errorf("未知操作: %s", rule.Action)
 	}
@@ -665,17 +1379,286 @@ func (m *Monitor) executeAction(vm models.VMInfo, rule models.Rule, stats *model
 	if err != nil {
 		actionLog.Success = false
 		actionLog.Error = err.Error()
-		log.Printf("操作失败: %v", err)
+		actionLog.ErrorKind = actionErrorKind(err)
+		log.Printf("操作失败 (%s): %v", actionLog.ErrorKind, err)
 	} else {
 		actionLog.Success = true
+		// 操作已执行但标记失败时同样记录（执行记录仍能避免重复执行）
+		if markErr != nil {
+			actionLog.Error = markErr.Error()
+			actionLog.ErrorKind = pve.ErrorKind(markErr)
+		}
+
+		if err := storage.SaveExecutedAction(m.storage, vm.VMID, models.ExecutedAction{
+			Rule:        rule.Name,
+			Action:      rule.Action,
+			PeriodStart: periodStart,
+			ExecutedAt:  actionLog.Timestamp,
+			Count:       executions,
+		}); err != nil {
+			log.Printf("VM%d 保存执行记录失败: %v", vm.VMID, err)
+		}
+		// 再次执行后重新建立了限制，之前被手动开机等外部修改不再影响恢复
+		if reenforce {
+			m.recoveryManager.ClearExternalChange(vm.VMID)
+		}
 	}
+	m.runPostAction(&actionLog, req)
 
 	// 保存操作日志
 	m.storage.SaveActionLog(actionLog)
+	m.publish(events.Event{Type: events.ActionExecuted, VMID: vm.VMID, Rule: rule.Name, Action: &actionLog})
 
 	return err
 }
 
+// waitTask 等待关机/停止任务结束，并在操作日志中记录任务 UPID 和结束状态（PVE 未返回 UPID 时不等待）
+func (m *Monitor) waitTask(actionLog *models.ActionLog, upid string, cfg models.MonitorConfig) error {
+	if upid == "" {
+		return nil
+	}
+	actionLog.TaskID = upid
+
+	status, err := m.pveFor(actionLog.VMID).WaitTask(upid, cfg.TaskWait())
+	if status != nil {
+		actionLog.TaskStatus = status.ExitStatus
+		if status.Running() {
+			actionLog.TaskStatus = status.Status
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("VM%d 任务未成功完成: %w", actionLog.VMID, err)
+	}
+	debugLog("VM%d 任务完成: %s (%s)", actionLog.VMID, upid, actionLog.TaskStatus)
+	return nil
+}
+
+// markEnforced 标记虚拟机已执行操作：添加操作标签（manage_tags=false 时跳过），
+// 或在 marker=description 时将限制状态写入虚拟机备注
+func (m *Monitor) markEnforced(vmid int, rule models.Rule, reason string, cfg models.MonitorConfig) error {
+	if cfg.MarkerBackend() == models.MarkerDescription {
+		marker := &models.EnforcementMarker{
+			Action:      rule.Action,
+			Rule:        rule.Name,
+			Reason:      reason,
+			Period:      rule.Period,
+			LimitGB:     rule.LimitGB,
+			RateLimitMB: rule.RateLimitMB,
+			ActionTime:  time.Now(),
+		}
+		if rule.IsRateRule() || rule.IsPercentileRule() {
+			marker.LimitGB = 0
+			marker.RateThresholdMbps = rule.RateThresholdMbps
+		}
+		if rule.Action != models.ActionRateLimit {
+			marker.RateLimitMB = 0
+		}
+		if state, ok := m.recoveryManager.State(vmid); ok {
+			marker.ActionTime = state.ActionTime
+			marker.RecoveryTime = state.RecoveryTime
+		}
+		if err := m.pveFor(vmid).SetVMMarker(vmid, marker); err != nil {
+			log.Printf("VM%d 写入备注限制状态失败: %v", vmid, err)
+			return fmt.Errorf("写入备注限制状态失败: %w", err)
+		}
+		return nil
+	}
+
+	tag := cfg.Tags.ActionTag(rule.Action)
+	if !cfg.TagsManaged() || tag == "" {
+		return nil
+	}
+	if err := m.pveFor(vmid).AddVMTag(vmid, tag); err != nil {
+		log.Printf("VM%d 添加标签 %s 失败: %v", vmid, tag, err)
+		return fmt.Errorf("添加标签 %s 失败: %w", tag, err)
+	}
+	return nil
+}
+
+// cleanupOldData 按保留期清理流量记录、操作日志和已不存在的虚拟机的状态
+func (m *Monitor) cleanupOldData() models.CleanupResult {
+	cfg := m.configLoader.GetConfig()
+	result := models.CleanupResult{StartedAt: time.Now()}
+
+	// 先归档已结束的月份，避免按保留期清理时删除尚未归档的记录
+	if cfg.Archive.Enabled() {
+		m.archivePending(&result)
+	}
+
+	if cfg.Monitor.DataRetentionDays > 0 {
+		log.Printf("开始清理旧数据 (保留 %d 天)", cfg.Monitor.DataRetentionDays)
+		result.RetentionDays = cfg.Monitor.DataRetentionDays
+		if err := m.storage.CleanupOldData(cfg.Monitor.DataRetentionDays); err != nil {
+			log.Printf("清理旧数据失败: %v", err)
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	retention := cfg.Monitor.Retention
+	if retention.ActionLogDays > 0 || retention.StateDays > 0 {
+		// 获取虚拟机列表失败时不清理状态，避免删除仍在限制中的虚拟机的恢复信息
+		var existing map[int]bool
+		if retention.StateDays > 0 && cfg.RunMode() == models.ModeAggregator && time.Since(m.startedAt) < remoteVMTTL {
+			// 汇总端刚启动时尚未收到所有代理的推送，虚拟机列表不完整
+			log.Printf("尚未收到所有代理节点的推送，跳过虚拟机状态清理")
+		} else if retention.StateDays > 0 {
+			vms, err := m.allVMs(true)
+			if err != nil {
+				log.Printf("获取虚拟机列表失败，跳过虚拟机状态清理: %v", err)
+				result.Errors = append(result.Errors, err.Error())
+			} else {
+				existing = make(map[int]bool, len(vms))
+				for _, vm := range vms {
+					existing[vm.VMID] = true
+				}
+			}
+		}
+
+		cleaned, err := storage.CleanupRetention(m.storage, retention, existing, time.Now())
+		result.ActionLogs, result.States = cleaned.ActionLogs, cleaned.States
+		if err != nil {
+			log.Printf("按保留期清理数据失败: %v", err)
+			result.Errors = append(result.Errors, err.Error())
+		} else if cleaned.ActionLogs > 0 || cleaned.States > 0 {
+			log.Printf("按保留期清理完成: 操作日志 %d, 虚拟机状态 %d", cleaned.ActionLogs, cleaned.States)
+		}
+	}
+
+	result.FinishedAt = time.Now()
+	return result
+}
+
+// runCleanup 执行一次计划清理并保存结果（missed 表示启动时补做错过的清理）
+func (m *Monitor) runCleanup(scheduledAt time.Time, missed bool) {
+	if missed {
+		log.Printf("上次计划清理 (%s) 未执行，启动时补做", scheduledAt.Format("2006-01-02 15:04"))
+	}
+
+	result := m.cleanupOldData()
+	result.ScheduledAt = scheduledAt
+	result.Missed = missed
+	if len(result.Errors) > 0 {
+		log.Printf("旧数据清理完成，%d 个错误: %s", len(result.Errors), strings.Join(result.Errors, "; "))
+	}
+
+	if err := storage.SaveLastCleanup(m.storage, result); err != nil && !errors.Is(err, storage.ErrMetadataUnsupported) {
+		log.Printf("保存清理结果失败: %v", err)
+	}
+}
+
+// nextCleanupAt 按当前配置的 cleanup_schedule 计算 after 之后的下次清理时间（在配置的时区中计算）
+func (m *Monitor) nextCleanupAt(after time.Time) time.Time {
+	cron, err := schedule.Parse(m.configLoader.GetConfig().Monitor.CleanupCron())
+	if err != nil {
+		log.Printf("清理计划无效，不再自动清理: %v", err)
+		return time.Time{}
+	}
+	return cron.Next(after.In(periodcalc.Location()))
+}
+
+// missedCleanup 上次清理之后是否错过了计划时间（如程序在计划时间前后重启），返回错过的计划时间
+func (m *Monitor) missedCleanup(now time.Time) (time.Time, bool) {
+	last, err := storage.LastCleanup(m.storage)
+	if err != nil {
+		log.Printf("读取上次清理结果失败: %v", err)
+		return time.Time{}, false
+	}
+	if last == nil {
+		return time.Time{}, false
+	}
+
+	due := m.nextCleanupAt(last.StartedAt)
+	if due.IsZero() || due.After(now) {
+		return time.Time{}, false
+	}
+	// 错过多次时只补做一次，按最近一次计划时间记录
+	for next := m.nextCleanupAt(due); !next.IsZero() && !next.After(now); next = m.nextCleanupAt(next) {
+		due = next
+	}
+	return due, true
+}
+
+// recoverRollingWindows 重新计算滑动窗口规则限制中的虚拟机用量（虚拟机停止后不再采集，不能依赖监控循环），
+// 窗口内用量不再超过限额时提前恢复，不等待窗口长度的恢复时间
+func (m *Monitor) recoverRollingWindows() {
+	rules := make(map[string]models.Rule)
+	for _, rule := range m.configLoader.GetConfig().Rules {
+		// 主机规则按总和判断，单台虚拟机的用量回落不代表总和回落，按窗口长度恢复
+		if _, rolling := rule.RollingWindow(); rolling && rule.Enabled && !rule.IsRateRule() && !rule.IsPercentileRule() && !rule.IsHostRule() {
+			rules[rule.Name] = rule
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	for _, state := range m.recoveryManager.States() {
+		rule, ok := rules[state.RuleName]
+		if !ok || !state.NeedsRecovery || state.RecoveryCanceled {
+			continue
+		}
+
+		direction := models.DirectionBoth
+		if rule.TrafficDirection != "" {
+			direction = rule.TrafficDirection
+		}
+		stats, err := m.stats.CalculateMetricFor(periodcalc.ForRule(rule, time.Time{}), state.VMID, direction, rule.Metric)
+		if err != nil {
+			debugLog("VM%d 计算滑动窗口用量失败: %v", state.VMID, err)
+			continue
+		}
+		if stats.TotalGB > rule.LimitGB {
+			continue
+		}
+
+		log.Printf("VM%d 滑动窗口用量已回落 %.2f/%.2f GB [%s]，提前恢复", state.VMID, stats.TotalGB, rule.LimitGB, rule.Name)
+		if err := m.recoveryManager.RecoverVM(state.VMID); err != nil {
+			log.Printf("VM%d 恢复失败: %v", state.VMID, err)
+		}
+	}
+}
+
+// restrictionInEffect 再次执行前检查规则的限制是否仍然有效（关机后被手动开机、网络被手动恢复或限速被放宽时为 false）
+// exec 操作不限制虚拟机，总是返回 false；无法读取网卡配置时按仍然有效处理，避免重复断网
+func (m *Monitor) restrictionInEffect(vm models.VMInfo, rule models.Rule) bool {
+	switch rule.Action {
+	case models.ActionShutdown, models.ActionStop:
+		return vm.Status != "running"
+	case models.ActionDisconnect:
+		disconnected, err := m.pveFor(vm.VMID).NetworkDisconnected(vm.VMID, rule.Interfaces)
+		return err != nil || disconnected
+	case models.ActionRateLimit:
+		needsTighten, err := m.pveFor(vm.VMID).ShouldTightenNetworkRateLimit(vm.VMID, rule.RateLimitMB, rule.Interfaces)
+		return err == nil && !needsTighten
+	}
+	return false
+}
+
+// actionAlreadyTaken 不使用标签时判断操作是否已执行：
+// 恢复状态中仍处于该操作的限制，或本周期内（最近一次恢复之后）已有成功的同类操作日志
+func (m *Monitor) actionAlreadyTaken(vmid int, rule models.Rule, creationTime time.Time) bool {
+	if m.recoveryManager.ActionActive(vmid, rule.Action) {
+		return true
+	}
+
+	since := periodcalc.ForRule(rule, creationTime).GetCurrentPeriodStart()
+	if recoveredAt := m.recoveryManager.LastRecoveredAt(vmid); recoveredAt.After(since) {
+		since = recoveredAt
+	}
+
+	logs, err := m.storage.GetActionLogs(since, time.Now())
+	if err != nil {
+		debugLog("VM%d 读取操作日志失败: %v", vmid, err)
+		return false
+	}
+	for _, entry := range logs {
+		if entry.VMID == vmid && entry.Success && entry.RuleName == rule.Name && entry.Action == rule.Action {
+			return true
+		}
+	}
+	return false
+}
+
 func shouldApplyRateLimit(currentRateMB, desiredRateMB float64) bool {
 	if desiredRateMB <= 0 {
 		return false
@@ -687,33 +1670,222 @@ func shouldApplyRateLimit(currentRateMB, desiredRateMB float64) bool {
 }
 
 func (m *Monitor) handleExport(vmidStr string, period string) error {
-	if vmidStr == "all" {
+	options := chart.RenderOptions{
+		Width:  *chartWidth,
+		Height: *chartHeight,
+		DPI:    *chartDPI,
+		Title:  *chartTitle,
+		XLabel: *chartXLabel,
+		YLabel: *chartYLabel,
+		Period: period,
+		Dark:   *useDarkTheme,
+		CDN:    *chartCDN,
+	}
+	if *exportFormat == chart.FormatSVG {
+		options.Format = chart.FormatSVG
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	m.exporter.SetRenderOptions(options)
+
+	naming := chart.FileNaming{Template: *exportFilename, Output: *exportOutput, Overwrite: *exportOverwrite}
+	if err := naming.Validate(); err != nil {
+		return err
+	}
+	m.exporter.SetFileNaming(naming)
+	if err := m.setExportDestination(); err != nil {
+		return err
+	}
+
+	switch vmidStr {
+	case "all":
 		return m.exportAllVMs(period)
+	case "tenants":
+		return m.exportTenants(period)
+	case "host":
+		return m.exportHost(period)
 	}
 
 	// 导出单个虚拟机
 	var vmid int
 	if _, err := fmt.Sscanf(vmidStr, "%d", &vmid); err != nil {
-		return fmt.Errorf("无效的虚拟机 ID: %s", vmidStr)
+		return i18n.Errorf("cli.invalid_vmid", vmidStr)
 	}
 
 	return m.exportVM(vmid, period)
 }
 
+// setExportDestination 按 -dest（默认 monitor.export_destination）设置导出文件的上传目标
+func (m *Monitor) setExportDestination() error {
+	cfg := m.configLoader.GetConfig()
+	name := *exportDest
+	if name == "" {
+		name = cfg.Monitor.ExportDestination
+	}
+	if name == "" || name == models.ExportLocal || *exportOutput == chart.StdoutOutput {
+		m.exporter.SetDestination(nil, "")
+		return nil
+	}
+	dest, ok := cfg.Monitor.Destination(name)
+	if !ok {
+		return i18n.Errorf("cli.export_dest_unknown", name)
+	}
+	uploader, err := objstore.NewUploader(dest)
+	if err != nil {
+		return fmt.Errorf("导出目标 %s 配置无效: %w", name, err)
+	}
+	m.exporter.SetDestination(uploader, dest.Prefix)
+	return nil
+}
+
 func (m *Monitor) exportVM(vmid int, period string) error {
 	// 验证导出格式
 	format := *exportFormat
-	if format != "json" && format != "png" && format != "html" {
-		return fmt.Errorf("无效的导出格式: %s (支持: json/png/html)", format)
+	if format != "json" && format != "png" && format != "svg" && format != "html" {
+		return i18n.Errorf("cli.invalid_format", format)
+	}
+
+	// 验证 period 参数（也可以是步长，如 15m、6h、1d、auto）
+	validPeriods := map[string]bool{"minute": true, "hour": true, "day": true, "month": true}
+	if _, isStep := storage.ParseBucketStep(period); !validPeriods[period] && !isStep {
+		return i18n.Errorf("cli.invalid_period", period)
+	}
+
+	start, end, err := m.exportRange(period)
+	if err != nil {
+		return err
+	}
+
+	// 获取流量记录
+	records, err := m.storage.GetTrafficRecords(vmid, start, end)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.get_records_failed"), err)
+	}
+
+	if len(records) == 0 {
+		return i18n.Errorf("cli.no_records", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	}
+
+	// 获取虚拟机信息
+	vmInfo, err := m.pveClient.GetVMStatus(vmid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.get_vm_failed"), err)
+	}
+
+	var filename string
+
+	// 根据格式导出（使用带 period 参数的新函数）
+	switch format {
+	case "json":
+		filename, err = m.exporter.ExportJSONDataWithPeriod(vmid, vmInfo.Name, records, start, end, period)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_json_failed"), err)
+		}
+	case "png", "svg":
+		filename, err = m.exporter.ExportTrafficChartWithRangeAndPeriod(vmid, vmInfo.Name, records, start, end, period)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_png_failed"), err)
+		}
+	case "html":
+		filename, err = m.exporter.ExportHTMLChartWithRangeAndPeriod(vmid, vmInfo.Name, records, start, end, period, *useDarkTheme)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_html_failed"), err)
+		}
 	}
 
-	// 验证 period 参数
+	log.Println(i18n.T("cli.exported", format, filename))
+	log.Println(i18n.T("cli.time_range", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04")))
+	log.Println(i18n.T("cli.granularity", period))
+	log.Println(i18n.T("cli.raw_points", len(records)))
+	setExportResult("vm", format, filename, start, end, period)
+	setResult("vmid", vmid)
+	setResult("raw_points", len(records))
+	return nil
+}
+
+// exportHost 导出所有虚拟机按时间段叠加的主机流量趋势（堆叠面积图）
+func (m *Monitor) exportHost(period string) error {
+	dir := *direction
+	if dir != "both" && dir != "rx" && dir != "tx" {
+		return i18n.Errorf("cli.invalid_direction", dir)
+	}
+	format := *exportFormat
+	if format != "json" && format != "png" && format != "svg" && format != "html" {
+		return i18n.Errorf("cli.invalid_format", format)
+	}
 	validPeriods := map[string]bool{"minute": true, "hour": true, "day": true, "month": true}
-	if !validPeriods[period] {
-		return fmt.Errorf("无效的聚合周期: %s (支持: minute/hour/day/month)", period)
+	if _, isStep := storage.ParseBucketStep(period); !validPeriods[period] && !isStep {
+		return i18n.Errorf("cli.invalid_period", period)
+	}
+
+	start, end, err := m.exportRange(period)
+	if err != nil {
+		return err
 	}
 
-	// 计算时间范围
+	vms, err := m.pveClient.GetAllVMs()
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
+	}
+	host := chart.HostExport{
+		Records:   make(map[int][]models.TrafficRecord, len(vms)),
+		Names:     make(map[int]string, len(vms)),
+		StartTime: start,
+		EndTime:   end,
+		Period:    period,
+		Direction: dir,
+		Top:       models.DefaultHostHistoryTop,
+	}
+	rawPoints := 0
+	for _, vm := range vms {
+		records, err := m.storage.GetTrafficRecords(vm.VMID, start, end)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.get_records_failed"), err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		host.Records[vm.VMID] = records
+		host.Names[vm.VMID] = vm.Name
+		rawPoints += len(records)
+	}
+	if len(host.Records) == 0 {
+		return i18n.Errorf("cli.no_records", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	}
+
+	var filename string
+	switch format {
+	case "json":
+		filename, err = m.exporter.ExportHostJSONData(host)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_json_failed"), err)
+		}
+	case "png", "svg":
+		filename, err = m.exporter.ExportHostTrafficChart(host)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_png_failed"), err)
+		}
+	case "html":
+		filename, err = m.exporter.ExportHostHTMLChart(host, *useDarkTheme)
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_html_failed"), err)
+		}
+	}
+
+	log.Println(i18n.T("cli.host_exported", format, filename, len(host.Records)))
+	log.Println(i18n.T("cli.time_range", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04")))
+	log.Println(i18n.T("cli.granularity", period))
+	log.Println(i18n.T("cli.raw_points", rawPoints))
+	setExportResult("host", format, filename, start, end, period)
+	setResult("direction", dir)
+	setResult("vms", len(host.Records))
+	setResult("raw_points", rawPoints)
+	return nil
+}
+
+// exportRange 导出的时间范围：-start/-end 指定的范围、-date 指定的某一天，或按 period 取最近一段时间
+func (m *Monitor) exportRange(period string) (time.Time, time.Time, error) {
 	now := time.Now()
 	var start, end time.Time
 	var err error
@@ -723,19 +1895,19 @@ func (m *Monitor) exportVM(vmid int, period string) error {
 		// 自定义时间范围
 		start, err = m.parseTimeParam(*startTime)
 		if err != nil {
-			return fmt.Errorf("解析开始时间失败: %w", err)
+			return time.Time{}, time.Time{}, fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
 		}
 		end, err = m.parseTimeParam(*endTime)
 		if err != nil {
-			return fmt.Errorf("解析结束时间失败: %w", err)
+			return time.Time{}, time.Time{}, fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
 		}
 		// 自定义时间范围时，period 参数用于控制聚合粒度
-		log.Printf("使用自定义时间范围，聚合粒度: %s\n", period)
+		log.Println(i18n.T("cli.custom_range", period))
 	} else if *exportDate != "" {
 		// 指定日期（导出某天的数据）
 		date, err := time.ParseInLocation("2006-01-02", *exportDate, time.Local)
 		if err != nil {
-			return fmt.Errorf("解析日期失败: %w (格式应为: 2006-01-02)", err)
+			return time.Time{}, time.Time{}, fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
 		}
 		start, end = dayBounds(date)
 	} else {
@@ -749,52 +1921,12 @@ func (m *Monitor) exportVM(vmid int, period string) error {
 			start = now.AddDate(0, 0, -30) // 最近30天，按天聚合
 		case "month":
 			start = now.AddDate(-1, 0, 0) // 最近1年，按月聚合
+		default:
+			start = now.Add(-24 * time.Hour) // 步长：最近24小时
 		}
 		end = now
 	}
-
-	// 获取流量记录
-	records, err := m.storage.GetTrafficRecords(vmid, start, end)
-	if err != nil {
-		return fmt.Errorf("获取流量记录失败: %w", err)
-	}
-
-	if len(records) == 0 {
-		return fmt.Errorf("没有找到流量记录 (时间范围: %s - %s)", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
-	}
-
-	// 获取虚拟机信息
-	vmInfo, err := m.pveClient.GetVMStatus(vmid)
-	if err != nil {
-		return fmt.Errorf("获取虚拟机信息失败: %w", err)
-	}
-
-	var filename string
-
-	// 根据格式导出（使用带 period 参数的新函数）
-	switch format {
-	case "json":
-		filename, err = m.exporter.ExportJSONData(vmid, vmInfo.Name, records, start, end)
-		if err != nil {
-			return fmt.Errorf("导出JSON失败: %w", err)
-		}
-	case "png":
-		filename, err = m.exporter.ExportTrafficChartWithRangeAndPeriod(vmid, vmInfo.Name, records, start, end, period)
-		if err != nil {
-			return fmt.Errorf("导出PNG图表失败: %w", err)
-		}
-	case "html":
-		filename, err = m.exporter.ExportHTMLChartWithRangeAndPeriod(vmid, vmInfo.Name, records, start, end, period, *useDarkTheme)
-		if err != nil {
-			return fmt.Errorf("导出HTML图表失败: %w", err)
-		}
-	}
-
-	log.Printf("已导出 (%s): %s\n", format, filename)
-	log.Printf("时间范围: %s - %s\n", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
-	log.Printf("聚合粒度: %s\n", period)
-	log.Printf("原始数据点数: %d\n", len(records))
-	return nil
+	return start, end, nil
 }
 
 // parseTimeParam 解析时间参数（支持多种格式）
@@ -814,7 +1946,7 @@ func (m *Monitor) parseTimeParam(timeStr string) (time.Time, error) {
 		}
 	}
 
-	return time.Time{}, fmt.Errorf("无效的时间格式: %s (支持格式: 2006-01-02 或 2006-01-02T15:04:05)", timeStr)
+	return time.Time{}, i18n.Errorf("cli.invalid_time", timeStr)
 }
 
 func dayBounds(date time.Time) (time.Time, time.Time) {
@@ -826,71 +1958,28 @@ func (m *Monitor) exportAllVMs(period string) error {
 	// 验证方向参数
 	dir := *direction
 	if dir != "both" && dir != "rx" && dir != "tx" {
-		return fmt.Errorf("无效的流量方向: %s (支持: both/rx/tx)", dir)
+		return i18n.Errorf("cli.invalid_direction", dir)
 	}
 
 	// 验证导出格式
 	format := *exportFormat
-	if format != "json" && format != "png" && format != "html" {
-		return fmt.Errorf("无效的导出格式: %s (支持: json/png/html)", format)
+	if format != "json" && format != "png" && format != "svg" && format != "html" {
+		return i18n.Errorf("cli.invalid_format", format)
 	}
 
 	// 获取所有虚拟机
 	vms, err := m.pveClient.GetAllVMs()
 	if err != nil {
-		return fmt.Errorf("获取虚拟机列表失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
 	}
 
-	// 处理时间范围参数（支持start/end参数或period参数）
-	var start, end time.Time
-	var usePeriod bool
-
-	// 优先使用start/end参数
-	if *startTime != "" && *endTime != "" {
-		start, err = m.parseTimeParam(*startTime)
-		if err != nil {
-			return fmt.Errorf("解析开始时间失败: %w", err)
-		}
-		end, err = m.parseTimeParam(*endTime)
-		if err != nil {
-			return fmt.Errorf("解析结束时间失败: %w", err)
-		}
-		if start.After(end) {
-			return fmt.Errorf("开始时间不能晚于结束时间")
-		}
-		usePeriod = false
-	} else {
-		// 使用period参数
-		usePeriod = true
-	}
-
-	// 收集统计信息 - 使用与API一致的方法
-	var stats []models.TrafficStats
-	for _, vm := range vms {
-		var stat *models.TrafficStats
-
-		if usePeriod {
-			// 使用周期统计
-			stat, err = m.storage.CalculateTrafficStatsWithDirection(vm.VMID, period, time.Time{}, false, dir)
-			if err != nil {
-				log.Printf("计算虚拟机 %d 统计失败: %v\n", vm.VMID, err)
-				continue
-			}
-		} else {
-			// 使用时间范围统计
-			stat, err = m.storage.CalculateTrafficStatsWithTimeRange(vm.VMID, start, end, dir)
-			if err != nil {
-				log.Printf("计算虚拟机 %d 统计失败: %v\n", vm.VMID, err)
-				continue
-			}
-		}
-
-		stat.Name = vm.Name // 设置VM名称
-		stats = append(stats, *stat)
+	stats, start, end, usePeriod, err := m.collectStats(vms, period, dir)
+	if err != nil {
+		return err
 	}
 
 	if len(stats) == 0 {
-		return fmt.Errorf("没有统计数据")
+		return i18n.Errorf("cli.no_stats")
 	}
 
 	var filename string
@@ -898,29 +1987,31 @@ func (m *Monitor) exportAllVMs(period string) error {
 	// 根据格式导出
 	switch format {
 	case "json":
-		filename, err = m.exporter.ExportStatsJSONData(stats, dir)
+		filename, err = m.exporter.ExportStatsJSONDataWithRange(stats, dir, start, end)
 		if err != nil {
-			return fmt.Errorf("导出JSON失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_json_failed"), err)
 		}
-	case "png":
-		filename, err = m.exporter.ExportStatsChart(stats, dir)
+	case "png", "svg":
+		filename, err = m.exporter.ExportStatsChartWithRange(stats, dir, start, end)
 		if err != nil {
-			return fmt.Errorf("导出PNG图表失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_png_failed"), err)
 		}
 	case "html":
 		filename, err = m.exporter.ExportStatsHTMLChartWithRange(stats, dir, start, end, *useDarkTheme)
 		if err != nil {
-			return fmt.Errorf("导出HTML图表失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.export_html_failed"), err)
 		}
 	}
 
+	log.Println(i18n.T("cli.summary_exported", format, filename))
+	setExportResult("stats", format, filename, start, end, period)
+	setResult("direction", dir)
+	setResult("vms", len(stats))
 	if usePeriod {
-		log.Printf("汇总已导出 (%s): %s\n", format, filename)
-		log.Printf("统计周期: %s, 流量方向: %s, 虚拟机数量: %d\n", period, dir, len(stats))
+		log.Println(i18n.T("cli.summary_period", period, dir, len(stats)))
 	} else {
-		log.Printf("汇总已导出 (%s): %s\n", format, filename)
-		log.Printf("时间范围: %s - %s, 流量方向: %s, 虚拟机数量: %d\n",
-			start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"), dir, len(stats))
+		log.Println(i18n.T("cli.summary_range",
+			start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"), dir, len(stats)))
 	}
 
 	return nil
@@ -959,16 +2050,12 @@ func getDirectionText(direction string) string {
 
 // notifyMainProgram 通知主程序
 func (m *Monitor) notifyMainProgram(msgType string, data map[string]interface{}) {
-	cfg := m.configLoader.GetConfig()
-
-	var socketBasePath string
-	if cfg.Storage.Type == "file" || cfg.Storage.Type == "" {
-		socketBasePath = cfg.Storage.FilePath
-	} else {
-		socketBasePath = filepath.Join(os.TempDir(), "pve-traffic-monitor")
-	}
+	notifyProgram(m.configLoader.GetConfig(), msgType, data)
+}
 
-	socketPath := ipc.GetDefaultSocketPath(socketBasePath)
+// notifyProgram 通知使用 cfg 的主程序（不创建监控器的子命令使用）
+func notifyProgram(cfg *models.Config, msgType string, data map[string]interface{}) {
+	socketPath := ipc.GetDefaultSocketPath(ipcBasePath(cfg))
 	client := ipc.NewClient(socketPath)
 
 	msg := ipc.Message{
@@ -979,9 +2066,9 @@ func (m *Monitor) notifyMainProgram(msgType string, data map[string]interface{})
 
 	if err := client.SendMessage(msg); err != nil {
 		// 主程序可能未运行，这是正常的
-		log.Printf("通知主程序失败（主程序可能未运行）: %v", err)
+		log.Println(i18n.T("cli.notify_failed", err))
 	} else {
-		log.Printf("已通知主程序: %s", msgType)
+		log.Println(i18n.T("cli.notified", msgType))
 	}
 }
 
@@ -989,82 +2076,154 @@ func (m *Monitor) notifyMainProgram(msgType string, data map[string]interface{})
 func (m *Monitor) handleCleanupNotification(msg ipc.Message) {
 	log.Printf("收到数据清除通知: type=%v, vmid=%v", msg.Data["type"], msg.Data["vmid"])
 
-	// 清除流量缓存
-	m.trafficCache.Clear()
-	log.Println("已清除流量缓存")
+	// 虚拟机状态被清除时，同时移除内存中的恢复记录
+	if msg.Data["type"] == "states" {
+		if removed := m.recoveryManager.SyncStates(); removed > 0 {
+			log.Printf("已移除 %d 个虚拟机的恢复记录", removed)
+		}
+	}
 
-	// 如果API服务器在运行，也应该清除它的缓存
-	// API服务器的缓存会自动过期，这里主要是清除monitor的缓存
+	// 清除统计缓存（API服务器通过失效回调同步清除响应缓存）
+	m.stats.InvalidateAll()
+	m.creation.Invalidate(0)
+	log.Println("已清除流量缓存")
 }
 
 // handleReloadCacheNotification 处理重载缓存通知
 func (m *Monitor) handleReloadCacheNotification(msg ipc.Message) {
 	log.Println("收到重载缓存通知")
-	m.trafficCache.Clear()
+	m.stats.InvalidateAll()
+	m.creation.Invalidate(0)
 	log.Println("已清除流量缓存")
+
+	// monitor fsck -repair 修复数据后，内存中的计数器按实际数据重新统计（避免退出时覆盖修复后的计数器文件）
+	if rebuild, _ := msg.Data["rebuild_counter"].(bool); rebuild {
+		if rebuilder, ok := storage.As[storage.CounterRebuilder](m.storage); ok {
+			go func() {
+				count, err := rebuilder.RebuildRecordCount()
+				if err != nil {
+					log.Printf("重建记录计数器失败: %v", err)
+					return
+				}
+				log.Printf("已重建记录计数器: %d 条记录", count)
+			}()
+		}
+	}
 }
 
 // handleCleanup 处理清除数据命令
 func (m *Monitor) handleCleanup(cleanupType string) error {
+	setResult("cleanup", cleanupType)
+	setResult("dry_run", *dryRun)
+
+	var err error
 	switch cleanupType {
 	case "range":
 		// 清除指定时间段的数据
-		return m.cleanupRange()
+		err = m.cleanupRange()
 	case "vm":
 		// 清除指定VM指定日期的数据
-		return m.cleanupVM()
+		err = m.cleanupVM()
 	case "before":
 		// 清除指定日期之前的数据
-		return m.cleanupBefore()
+		err = m.cleanupBefore()
+	case "logs":
+		// 清除操作日志
+		err = m.cleanupLogs()
+	case "states":
+		// 清除虚拟机状态
+		err = m.cleanupStates()
+	case "compact":
+		// 只压缩存储，不删除记录
+		return m.compactStorage()
 	default:
-		return fmt.Errorf("无效的清除类型: %s (支持: range/vm/before)", cleanupType)
+		return i18n.Errorf("cli.invalid_cleanup_type", cleanupType)
 	}
+
+	if err != nil || !*compact {
+		return err
+	}
+	return m.compactStorage()
+}
+
+// compactStorage 压缩存储，回收删除记录后的空间并输出进度
+func (m *Monitor) compactStorage() error {
+	if *dryRun {
+		log.Println(i18n.T("cli.compact_dry_run"))
+		return nil
+	}
+
+	log.Println(i18n.T("cli.compact_start"))
+	result, err := storage.Compact(m.storage, func(p storage.CompactProgress) {
+		log.Println(i18n.T("cli.compact_progress", p.Done, p.Total, p.Step))
+	})
+	if errors.Is(err, storage.ErrCompactUnsupported) {
+		log.Println(i18n.T("cli.compact_unsupported"))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.compact_failed"), err)
+	}
+
+	if result.BytesBefore > 0 {
+		log.Println(i18n.T("cli.compact_size", float64(result.BytesBefore)/(1<<20), float64(result.BytesAfter)/(1<<20)))
+	}
+	log.Println(i18n.T("cli.compact_done", result.RemovedFiles, result.RemovedDirs))
+	setResult("compact", map[string]interface{}{
+		"bytes_before":  result.BytesBefore,
+		"bytes_after":   result.BytesAfter,
+		"removed_files": result.RemovedFiles,
+		"removed_dirs":  result.RemovedDirs,
+	})
+	return nil
 }
 
 // cleanupRange 清除指定时间段的数据
 func (m *Monitor) cleanupRange() error {
 	if *startTime == "" || *endTime == "" {
-		return fmt.Errorf("清除时间段数据需要指定 -start 和 -end 参数")
+		return i18n.Errorf("cli.cleanup_range_requires")
 	}
 
 	start, err := m.parseTimeParam(*startTime)
 	if err != nil {
-		return fmt.Errorf("解析开始时间失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
 	}
 
 	end, err := m.parseTimeParam(*endTime)
 	if err != nil {
-		return fmt.Errorf("解析结束时间失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
 	}
 
 	if start.After(end) {
-		return fmt.Errorf("开始时间不能晚于结束时间")
+		return i18n.Errorf("cli.start_after_end")
 	}
 
-	log.Printf("准备清除时间段数据: %s 至 %s\n", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	log.Println(i18n.T("cli.cleanup_range_prepare", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05")))
 
 	if *dryRun {
 		count, err := m.storage.CountRecordsInRange(0, start, end)
 		if err != nil {
-			return fmt.Errorf("统计记录数失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.count_failed"), err)
 		}
-		log.Printf("[DRY RUN] 将删除 %d 条记录\n", count)
+		log.Println(i18n.T("cli.dry_run_delete", count))
+		setResult("count", count)
 		return nil
 	}
 
 	deleted, err := m.storage.DeleteRecordsInRange(0, start, end)
 	if err != nil {
-		return fmt.Errorf("删除记录失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.delete_failed"), err)
 	}
 
-	log.Printf("成功删除 %d 条记录\n", deleted)
+	log.Println(i18n.T("cli.deleted", deleted))
+	setResult("deleted", deleted)
 	return nil
 }
 
 // cleanupVM 清除指定VM指定日期的数据
 func (m *Monitor) cleanupVM() error {
 	if *vmID == 0 {
-		return fmt.Errorf("清除VM数据需要指定 -vmid 参数")
+		return i18n.Errorf("cli.cleanup_vm_requires_vmid")
 	}
 
 	var start, end time.Time
@@ -1074,71 +2233,170 @@ func (m *Monitor) cleanupVM() error {
 		// 清除指定日期的数据
 		date, err := time.ParseInLocation("2006-01-02", *exportDate, time.Local)
 		if err != nil {
-			return fmt.Errorf("解析日期失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
 		}
 		start, end = dayBounds(date)
 	} else if *startTime != "" && *endTime != "" {
 		// 清除指定时间段的数据
 		start, err = m.parseTimeParam(*startTime)
 		if err != nil {
-			return fmt.Errorf("解析开始时间失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
 		}
 		end, err = m.parseTimeParam(*endTime)
 		if err != nil {
-			return fmt.Errorf("解析结束时间失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
 		}
 	} else {
-		return fmt.Errorf("清除VM数据需要指定 -date 或 (-start 和 -end) 参数")
+		return i18n.Errorf("cli.cleanup_vm_requires_range")
 	}
 
-	log.Printf("准备清除 VM%d 的数据: %s 至 %s\n", *vmID, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	log.Println(i18n.T("cli.cleanup_vm_prepare", *vmID, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05")))
 
 	if *dryRun {
 		count, err := m.storage.CountRecordsInRange(*vmID, start, end)
 		if err != nil {
-			return fmt.Errorf("统计记录数失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.count_failed"), err)
 		}
-		log.Printf("[DRY RUN] 将删除 VM%d 的 %d 条记录\n", *vmID, count)
+		log.Println(i18n.T("cli.dry_run_delete_vm", *vmID, count))
+		setResult("count", count)
 		return nil
 	}
 
 	deleted, err := m.storage.DeleteRecordsInRange(*vmID, start, end)
 	if err != nil {
-		return fmt.Errorf("删除记录失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.delete_failed"), err)
 	}
 
-	log.Printf("成功删除 VM%d 的 %d 条记录\n", *vmID, deleted)
+	log.Println(i18n.T("cli.deleted_vm", *vmID, deleted))
+	setResult("deleted", deleted)
 	return nil
 }
 
 // cleanupBefore 清除指定日期之前的数据
 func (m *Monitor) cleanupBefore() error {
 	if *beforeDate == "" {
-		return fmt.Errorf("清除历史数据需要指定 -before 参数")
+		return i18n.Errorf("cli.cleanup_before_requires")
 	}
 
 	date, err := time.ParseInLocation("2006-01-02", *beforeDate, time.Local)
 	if err != nil {
-		return fmt.Errorf("解析日期失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
 	}
 
 	beforeTime := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	log.Printf("准备清除 %s 之前的所有数据\n", beforeTime.Format("2006-01-02"))
+	log.Println(i18n.T("cli.cleanup_before_prepare", beforeTime.Format("2006-01-02")))
 
 	if *dryRun {
 		count, err := m.storage.CountRecordsBefore(beforeTime)
 		if err != nil {
-			return fmt.Errorf("统计记录数失败: %w", err)
+			return fmt.Errorf("%s: %w", i18n.T("cli.count_failed"), err)
 		}
-		log.Printf("[DRY RUN] 将删除 %d 条记录\n", count)
+		log.Println(i18n.T("cli.dry_run_delete", count))
+		setResult("count", count)
 		return nil
 	}
 
 	deleted, err := m.storage.DeleteRecordsBefore(beforeTime)
 	if err != nil {
-		return fmt.Errorf("删除记录失败: %w", err)
+		return fmt.Errorf("%s: %w", i18n.T("cli.delete_failed"), err)
+	}
+
+	log.Println(i18n.T("cli.deleted", deleted))
+	setResult("deleted", deleted)
+	return nil
+}
+
+// collectStats 收集虚拟机流量统计 - 使用与API一致的方法
+// 指定了 -start/-end 或 -date 时按时间范围统计，否则按 period 周期统计
+func (m *Monitor) collectStats(vms []models.VMInfo, period, dir string) ([]models.TrafficStats, time.Time, time.Time, bool, error) {
+	var start, end time.Time
+	var err error
+	usePeriod := true
+
+	// 优先使用start/end参数
+	if *startTime != "" && *endTime != "" {
+		start, err = m.parseTimeParam(*startTime)
+		if err != nil {
+			return nil, start, end, false, fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
+		}
+		end, err = m.parseTimeParam(*endTime)
+		if err != nil {
+			return nil, start, end, false, fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
+		}
+		if start.After(end) {
+			return nil, start, end, false, i18n.Errorf("cli.start_after_end")
+		}
+		usePeriod = false
+	} else if *exportDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", *exportDate, time.Local)
+		if err != nil {
+			return nil, start, end, false, fmt.Errorf("%s: %w", i18n.T("cli.parse_date_failed"), err)
+		}
+		start, end = dayBounds(date)
+		usePeriod = false
+	}
+
+	var stats []models.TrafficStats
+	for _, vm := range vms {
+		var stat *models.TrafficStats
+
+		if usePeriod {
+			// 使用周期统计
+			stat, err = m.stats.Calculate(vm.VMID, period, time.Time{}, false, dir)
+		} else {
+			// 使用时间范围统计
+			stat, err = m.stats.CalculateRange(vm.VMID, start, end, dir)
+		}
+		if err != nil {
+			log.Println(i18n.T("cli.vm_stats_failed", vm.VMID, err))
+			continue
+		}
+
+		stat.Name = vm.Name // 设置VM名称
+		stats = append(stats, *stat)
+	}
+
+	return stats, start, end, usePeriod, nil
+}
+
+// exportTenants 按客户汇总导出流量（JSON）
+func (m *Monitor) exportTenants(period string) error {
+	dir := *direction
+	if dir != "both" && dir != "rx" && dir != "tx" {
+		return i18n.Errorf("cli.invalid_direction", dir)
+	}
+
+	resolver, err := tenant.NewResolver(m.configLoader.GetConfig().Tenants)
+	if err != nil {
+		return err
+	}
+	if !resolver.Enabled() {
+		return i18n.Errorf("cli.no_tenants")
+	}
+
+	vms, err := m.pveClient.GetAllVMs()
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
+	}
+
+	names, groups := resolver.Group(vms)
+	result := make([]models.TenantStats, 0, len(names))
+	for _, name := range names {
+		stats, _, _, _, err := m.collectStats(groups[name], period, dir)
+		if err != nil {
+			return err
+		}
+		result = append(result, tenant.Aggregate(name, stats))
+	}
+
+	filename, err := m.exporter.ExportTenantStatsJSON(result, dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.export_tenants_failed"), err)
 	}
 
-	log.Printf("成功删除 %d 条记录\n", deleted)
+	log.Println(i18n.T("cli.tenants_exported", "json", filename))
+	setExportResult("tenants", "json", filename, time.Time{}, time.Time{}, period)
+	setResult("direction", dir)
+	setResult("tenants", len(result))
 	return nil
 }
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
 )
 
 func TestDayBoundsUsesInclusiveEndOfDay(t *testing.T) {
@@ -84,3 +91,252 @@ func TestCalculatePeriodStartUsesCreationTime(t *testing.T) {
 		})
 	}
 }
+
+func TestScanRecordsReportsAnomalies(t *testing.T) {
+	base := time.Date(2026, 6, 12, 0, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{Timestamp: base, RXBytes: 100, TXBytes: 100},
+		{Timestamp: base.Add(time.Minute), RXBytes: 200, TXBytes: 150},
+		{Timestamp: base.Add(time.Minute), RXBytes: 200, TXBytes: 150},
+		{Timestamp: base.Add(2 * time.Minute), RXBytes: 50, TXBytes: 160},
+		{Timestamp: base.Add(30 * time.Second), RXBytes: 60, TXBytes: 170},
+	}
+
+	got := scanRecords(records)
+	want := recordScan{Count: 5, Resets: 1, OutOfOrder: 1, Duplicates: 1}
+	if got != want {
+		t.Fatalf("scanRecords() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSampleTrackerGaps(t *testing.T) {
+	tracker := newSampleTracker()
+	interval := time.Minute
+	threshold := 3 * interval
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	vms := []models.VMInfo{{VMID: 100, Status: "running"}, {VMID: 101, Status: "stopped"}, {VMID: 102, Status: "running"}}
+
+	// 第一次看到的虚拟机从现在开始计算
+	if gaps := tracker.check(vms, threshold, interval, start); len(gaps) != 0 {
+		t.Fatalf("first check gaps = %v", gaps)
+	}
+	tracker.sampled(100, start)
+
+	// VM102 采样一直失败，超过阈值后只告警一次；停止的 VM101 不告警
+	for i := 1; i <= 5; i++ {
+		now := start.Add(time.Duration(i) * interval)
+		tracker.sampled(100, now)
+		tracker.failed(102, errors.New("timeout"), now)
+		gaps := tracker.check(vms, threshold, interval, now)
+		switch {
+		case i == 4 && (len(gaps) != 1 || gaps[0].VMID != 102 || gaps[0].Gap.Intervals != 4 || gaps[0].Gap.Error != "timeout"):
+			t.Fatalf("check at %d gaps = %+v", i, gaps)
+		case i != 4 && len(gaps) != 0:
+			t.Fatalf("check at %d gaps = %+v, want none", i, gaps)
+		}
+	}
+
+	// 无法获取虚拟机列表时按上次的运行状态检查
+	now := start.Add(10 * interval)
+	if gaps := tracker.check(nil, threshold, interval, now); len(gaps) != 1 || gaps[0].VMID != 100 {
+		t.Fatalf("check without list gaps = %+v, want VM100", gaps)
+	}
+
+	st := tracker.stats(now)
+	if len(st.Gaps) != 2 || st.MaxAgeSeconds != int64(10*interval/time.Second) || len(st.VMs) != 3 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	// 恢复采样后结束中断，删除的虚拟机不再跟踪
+	if gap := tracker.sampled(102, now); gap != 10*interval {
+		t.Fatalf("sampled gap = %s, want %s", gap, 10*interval)
+	}
+	tracker.check(vms[:2], threshold, interval, now)
+	if st := tracker.stats(now); len(st.Gaps) != 1 || st.Gaps[0] != 100 || len(st.VMs) != 2 {
+		t.Fatalf("stats after recovery = %+v", st)
+	}
+}
+
+func TestSampleTrackerStoppedVMs(t *testing.T) {
+	tracker := newSampleTracker()
+	interval := time.Minute
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	running := []models.VMInfo{{VMID: 100, Status: "running"}}
+	stopped := []models.VMInfo{{VMID: 100, Status: "stopped"}}
+
+	// 第一次看到和刚停止的虚拟机都采样
+	if !tracker.sampleStopped(100, 0, interval, start) {
+		t.Fatal("untracked VM not sampled")
+	}
+	tracker.sampled(100, start)
+	tracker.check(running, 0, interval, start)
+	stop := start.Add(interval)
+	if !tracker.sampleStopped(100, 0, interval, stop) {
+		t.Fatal("stop transition not sampled")
+	}
+	tracker.sampled(100, stop)
+	tracker.check(stopped, 0, interval, stop)
+
+	// 之后只在间隔到期时采样（允许定时器提前半个间隔）
+	if tracker.sampleStopped(100, 0, interval, stop.Add(time.Hour)) {
+		t.Fatal("stopped VM sampled with stopped_intervals -1")
+	}
+	if tracker.sampleStopped(100, 5*interval, interval, stop.Add(4*interval)) {
+		t.Fatal("stopped VM sampled before its interval")
+	}
+	if !tracker.sampleStopped(100, 5*interval, interval, stop.Add(5*interval-time.Second)) {
+		t.Fatal("stopped VM not sampled after its interval")
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	opts := benchOptions{VMs: 3, Days: 2, Step: time.Hour, Workers: 2, Keep: true, End: time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)}
+
+	report, err := runBench(store, "file", opts)
+	if err != nil {
+		t.Fatalf("runBench() error = %v", err)
+	}
+	if report.Records != 3*48 || len(report.Phases) != 4 {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, phase := range report.Phases {
+		if phase.Errors != 0 {
+			t.Fatalf("phase %s errors: %s", phase.Name, phase.FirstError)
+		}
+	}
+	if write, all := report.Phases[0], report.Phases[2]; write.Records != 144 || all.Records != 144 {
+		t.Fatalf("write = %+v, read_all = %+v", write, all)
+	}
+
+	// 保留的数据与之后的基准测试冲突时拒绝写入
+	opts.Keep = false
+	if _, err := runBench(store, "file", opts); err == nil {
+		t.Fatal("runBench() over existing data succeeded")
+	}
+	if _, err := store.DeleteRecordsInRange(benchVMIDBase, opts.start().Add(-time.Hour), opts.End.Add(time.Hour)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	opts.VMs = 1
+	if report, err = runBench(store, "file", opts); err != nil {
+		t.Fatalf("runBench() error = %v", err)
+	}
+	if phase := report.Phases[len(report.Phases)-1]; phase.Name != "delete" || phase.Records != 48 {
+		t.Fatalf("delete phase = %+v", phase)
+	}
+}
+
+func TestSelectTopTalkers(t *testing.T) {
+	talkers := []hostTalker{
+		{vm: models.VMInfo{VMID: 100}, usedGB: 10},
+		{vm: models.VMInfo{VMID: 101}, usedGB: 50},
+		{vm: models.VMInfo{VMID: 102}, usedGB: 0},
+		{vm: models.VMInfo{VMID: 103}, usedGB: 50},
+		{vm: models.VMInfo{VMID: 104}, usedGB: 5},
+	}
+
+	got := selectTopTalkers(talkers, 3)
+	if len(got) != 3 || got[0].vm.VMID != 101 || got[1].vm.VMID != 103 || got[2].vm.VMID != 100 {
+		t.Fatalf("selectTopTalkers(3) = %+v, want 101, 103, 100", got)
+	}
+	// 没有流量的虚拟机不会被选中
+	if got := selectTopTalkers(talkers, 10); len(got) != 4 {
+		t.Fatalf("selectTopTalkers(10) = %+v, want the 4 VMs with traffic", got)
+	}
+	if talkers[0].vm.VMID != 100 {
+		t.Fatalf("selectTopTalkers reordered its input: %+v", talkers)
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	tags := models.TagConfig{}
+	keep := func(tag string) bool { return tag == "traffic-limit-monthly" }
+
+	// 异常退出后留下的关机标签没有恢复记录，限速标签缺失；规则状态标签和其他标签不处理
+	current := []string{"web", "Traffic-Exceeded-Shutdown", "traffic-limit-monthly", "traffic-limit-removed"}
+	diff := diffTags(current, []string{tags.ActionTag(models.ActionRateLimit)}, tags, keep)
+	if len(diff.Orphan) != 2 || diff.Orphan[0] != "traffic-exceeded-shutdown" || diff.Orphan[1] != "traffic-limit-removed" {
+		t.Fatalf("orphan tags = %v, want the shutdown tag and the removed rule's tag", diff.Orphan)
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0] != "traffic-exceeded-limited" {
+		t.Fatalf("missing tags = %v, want traffic-exceeded-limited", diff.Missing)
+	}
+
+	diff = diffTags([]string{"traffic-exceeded-limited"}, []string{"traffic-exceeded-limited"}, tags, keep)
+	if len(diff.Orphan) != 0 || len(diff.Missing) != 0 {
+		t.Fatalf("diff = %+v, want no changes when tags match the recovery records", diff)
+	}
+}
+
+func TestOrphanAction(t *testing.T) {
+	tags := models.TagConfig{Prefix: "quota-"}
+
+	// 断网和限速标签需要先解除限制；关机标签、规则状态标签不对应需要撤销的网络操作
+	cases := map[string]string{
+		"quota-exceeded-disconnected": models.ActionDisconnect,
+		"quota-exceeded-limited":      models.ActionRateLimit,
+		"quota-exceeded-shutdown":     "",
+		"quota-limit-monthly":         "",
+		"traffic-exceeded-limited":    "",
+	}
+	for tag, want := range cases {
+		if got := orphanAction(tag, tags); got != want {
+			t.Errorf("orphanAction(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestIsLeaderRequiresUnexpiredLease(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(`pve:
+  host: localhost
+  port: 8006
+  node: pve
+  api_token_id: monitor@pve!traffic
+  api_token_secret: secret
+monitor:
+  interval_seconds: 60
+  ha:
+    enabled: true
+    instance_id: pve1
+    lease_seconds: 30
+storage:
+  type: file
+  file_path: `+dir+`
+`), 0644)
+	loader, err := config.NewLoader(path)
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	defer store.Close()
+
+	m := &Monitor{configLoader: loader, storage: store, leaderChan: make(chan struct{}, 1)}
+	m.renewLease(time.Now())
+	select {
+	case <-m.leaderChan:
+	default:
+		t.Fatal("renewLease() acquired no lease, want the main loop notified to take over")
+	}
+	if m.isLeader() {
+		t.Fatal("isLeader() = true before the takeover finished")
+	}
+
+	m.leader.Store(true)
+	if !m.isLeader() {
+		t.Fatal("isLeader() = false with a valid lease")
+	}
+	// 续期停滞（例如主循环阻塞或存储无法访问）时，租约到期后立即停止操作
+	m.leaseExpires.Store(time.Now().Add(-time.Second).UnixNano())
+	if m.isLeader() {
+		t.Fatal("isLeader() = true after the lease expired")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/ipc"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// inMaintenance 是否处于维护模式（暂停执行规则和自动恢复）
+func (m *Monitor) inMaintenance() bool {
+	state := m.maintenance.Load()
+	return state != nil && state.Enabled
+}
+
+// refreshMaintenance 从存储重新读取维护模式状态（主备模式下可能由其他实例修改）
+func (m *Monitor) refreshMaintenance() {
+	state, err := storage.LoadMaintenance(m.storage)
+	if err != nil {
+		log.Printf("读取维护模式状态失败，保持当前状态: %v", err)
+		return
+	}
+
+	previous := m.maintenance.Swap(&state)
+	switch {
+	case state.Enabled && (previous == nil || !previous.Enabled):
+		log.Printf("维护模式已启用（%s），暂停执行规则和自动恢复", maintenanceReason(state))
+	case !state.Enabled && previous != nil && previous.Enabled:
+		log.Println("维护模式已关闭，恢复执行规则和自动恢复")
+	}
+}
+
+// setMaintenance 启用或关闭维护模式并保存到存储
+func (m *Monitor) setMaintenance(enabled bool, reason, by string) (models.Maintenance, error) {
+	var current models.Maintenance
+	if loaded := m.maintenance.Load(); loaded != nil {
+		current = *loaded
+	}
+	state := changeMaintenance(current, enabled, reason, by)
+	if err := storage.SaveMaintenance(m.storage, state); err != nil {
+		return models.Maintenance{}, err
+	}
+
+	previous := m.maintenance.Swap(&state)
+	if enabled {
+		log.Printf("维护模式已启用（%s，来源: %s），暂停执行规则和自动恢复", maintenanceReason(state), by)
+	} else if previous != nil && previous.Enabled {
+		log.Printf("维护模式已关闭（来源: %s），恢复执行规则和自动恢复", by)
+	}
+	return state, nil
+}
+
+// changeMaintenance 修改后的维护模式状态（已启用时再次启用只更新原因，保留开始时间）
+func changeMaintenance(current models.Maintenance, enabled bool, reason, by string) models.Maintenance {
+	state := models.Maintenance{Enabled: enabled, Reason: reason, Since: time.Now(), By: by}
+	if current.Enabled && enabled {
+		state.Since = current.Since
+	}
+	return state
+}
+
+// maintenanceReason 日志中的维护原因
+func maintenanceReason(state models.Maintenance) string {
+	if state.Reason == "" {
+		return "未填写原因"
+	}
+	return state.Reason
+}
+
+// handleMaintenanceQuery 回复 maintenance 请求（monitor maintenance 命令）
+// action 为 on/off 时修改状态，为空时只查询
+func (m *Monitor) handleMaintenanceQuery(msg ipc.Message) map[string]interface{} {
+	action, _ := msg.Data["action"].(string)
+	reason, _ := msg.Data["reason"].(string)
+
+	state := models.Maintenance{}
+	if current := m.maintenance.Load(); current != nil {
+		state = *current
+	}
+	if action == "on" || action == "off" {
+		var err error
+		if state, err = m.setMaintenance(action == "on", reason, "cli"); err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+	}
+	return map[string]interface{}{
+		"enabled": state.Enabled,
+		"reason":  state.Reason,
+		"since":   state.Since.Format(time.RFC3339),
+	}
+}
+
+// runMaintenanceCommand 处理 maintenance 子命令
+//
+//	maintenance on [-reason 原因]    启用维护模式
+//	maintenance off                  关闭维护模式
+//	maintenance status               查看维护模式状态
+//
+// 主程序未运行时直接修改存储中的状态，启动后生效
+func runMaintenanceCommand(args []string) error {
+	if len(args) == 0 {
+		return i18n.Errorf("cli.maintenance_usage")
+	}
+	action := args[0]
+	if action != "on" && action != "off" && action != "status" {
+		return i18n.Errorf("cli.maintenance_usage")
+	}
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+	if action == "status" {
+		action = ""
+	}
+
+	var state models.Maintenance
+	reply, err := ipc.NewClient(ipc.GetDefaultSocketPath(ipcBasePath(cfg))).Query(ipc.Message{
+		Type:      "maintenance",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"action": action, "reason": *maintenanceReasonFlag},
+	})
+	setResult("running", err == nil)
+	if err == nil {
+		if msg, ok := reply.Data["error"].(string); ok {
+			return i18n.Errorf("cli.maintenance_save", msg)
+		}
+		state.Enabled, _ = reply.Data["enabled"].(bool)
+		state.Reason, _ = reply.Data["reason"].(string)
+		if since, ok := reply.Data["since"].(string); ok {
+			state.Since, _ = time.Parse(time.RFC3339, since)
+		}
+	} else {
+		// 主程序未运行（或在其他主机上）：直接读写存储
+		if state, err = maintenanceFromStorage(cfg, action); err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.maintenance_offline"))
+	}
+	setResult("maintenance", state)
+
+	if state.Enabled {
+		log.Println(i18n.T("cli.maintenance_on", state.Since.Format(time.RFC3339), state.Reason))
+	} else {
+		log.Println(i18n.T("cli.maintenance_off"))
+	}
+	return nil
+}
+
+// maintenanceFromStorage 主程序不可用时直接读取或修改存储中的维护模式状态
+func maintenanceFromStorage(cfg *models.Config, action string) (models.Maintenance, error) {
+	store, err := storage.NewStorageFromConfig(&cfg.Storage)
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+	defer store.Close()
+
+	current, err := storage.LoadMaintenance(store)
+	if err != nil || action == "" {
+		return current, err
+	}
+	state := changeMaintenance(current, action == "on", *maintenanceReasonFlag, "cli")
+	if err := storage.SaveMaintenance(store, state); err != nil {
+		return models.Maintenance{}, i18n.Errorf("cli.maintenance_save", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"pve-traffic-monitor/pkg/chart"
+)
+
+// 命令行的机器可读输出：-json 时命令结果以一个 JSON 对象输出到标准输出，日志仍输出到标准错误
+
+var jsonOutput = flag.Bool("json", false, "命令结果以 JSON 输出到标准输出 (便于 jq 等工具处理, 日志仍输出到标准错误)")
+
+// cliResult 命令结果的字段（-json 时命令结束后输出）
+var cliResult = map[string]interface{}{}
+
+// setResult 记录命令结果的字段
+func setResult(key string, value interface{}) {
+	cliResult[key] = value
+}
+
+// setExportResult 记录导出命令的结果（未指定时间范围时不输出 start/end）
+func setExportResult(kind, format, filename string, start, end time.Time, period string) {
+	setResult("export", kind)
+	setResult("format", format)
+	setResult("file", filename)
+	setResult("period", period)
+	if !start.IsZero() && !end.IsZero() {
+		setResult("start", start)
+		setResult("end", end)
+	}
+}
+
+// printResult 命令成功结束：-json 时输出记录的结果
+// 导出内容已经写到标准输出（-output -）时不再输出，避免混在一起
+func printResult() {
+	if !*jsonOutput || len(cliResult) == 0 {
+		return
+	}
+	if *exportCmd != "" && *exportOutput == chart.StdoutOutput {
+		return
+	}
+	if err := writeJSON(os.Stdout, cliResult); err != nil {
+		log.Printf("输出结果失败: %v", err)
+	}
+}
+
+// fatal 命令失败退出：-json 时在标准输出写入已记录的结果和 error 字段，便于脚本判断
+func fatal(msg string) {
+	if *jsonOutput {
+		cliResult["error"] = msg
+		writeJSON(os.Stdout, cliResult)
+	}
+	log.Fatal(msg)
+}
+
+// writeJSON 以缩进格式输出 JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// trackPowerStates 记录虚拟机运行状态的变化（每个采集周期调用，now 为获取虚拟机列表的时间），状态与上次相同时不访问存储
+// 程序重启后第一次检测时与存储中的状态比较，停机期间发生的变化记在检测到的时间
+func (m *Monitor) trackPowerStates(vms []models.VMInfo, now time.Time) {
+	var keepSince time.Time
+	if days := m.configLoader.GetConfig().Monitor.DataRetentionDays; days > 0 {
+		keepSince = now.AddDate(0, 0, -days)
+	}
+
+	for _, vm := range vms {
+		if vm.IsTemplate() || vm.Status == "" {
+			continue
+		}
+		if last, ok := m.power.Load(vm.VMID); ok && last.(string) == vm.Status {
+			continue
+		}
+		event, err := storage.RecordPowerState(m.storage, vm.VMID, vm.Status, now, keepSince)
+		if err != nil {
+			debugLog("VM%d 记录运行状态失败: %v", vm.VMID, err)
+			continue
+		}
+		m.power.Store(vm.VMID, vm.Status)
+		if event == nil {
+			continue
+		}
+		log.Printf("VM%d 运行状态变化: %s -> %s", vm.VMID, event.From, event.To)
+		m.publish(events.Event{Type: events.PowerChanged, Time: now, VMID: vm.VMID, Power: event})
+
+		// 限制期间的变化不是监控程序造成的（如管理员手动开机或关机）：标记后恢复时不再改变运行状态，并发出提醒
+		if state, ok := m.recoveryManager.NoteExternalChange(vm.VMID, *event); ok {
+			log.Printf("警告: VM%d 在规则 %s 的 %s 限制期间运行状态被外部修改（%s -> %s），恢复时不会改变其运行状态",
+				vm.VMID, state.RuleName, state.ActionTaken, event.From, event.To)
+			m.publish(events.Event{Type: events.ExternalChange, Time: now, VMID: vm.VMID, Rule: state.RuleName, Power: event, Recovery: &state})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// checkRatio 检查运行中虚拟机的上传/下载比例（monitor.ratio_alert）：异常开始时添加 tags.ratio 标签并发布 ratio_anomaly 事件，
+// 异常期间不重复发布，恢复正常后移除标签；采样点未覆盖统计窗口时保持原状态
+func (m *Monitor) checkRatio(vm models.VMInfo) {
+	if m.inMaintenance() {
+		return
+	}
+	cfg := m.configLoader.GetConfig().Monitor
+	alert := cfg.RatioAlert
+	if !alert.Enabled() || vm.Status != "running" || alert.Excludes(vm) {
+		m.clearRatioAnomaly(vm.VMID, cfg)
+		return
+	}
+
+	window := alert.Window()
+	now := time.Now()
+	records, err := m.storage.GetTrafficRecords(vm.VMID, now.Add(-window), now)
+	if err != nil {
+		debugLog("VM%d 获取流量记录失败，跳过上传/下载比例检查: %v", vm.VMID, err)
+		return
+	}
+	upload, download, ratio, span := storage.CalculateUploadRatio(vm.VMID, records)
+	if span <= 0 || span < m.minWindowSpan(window) {
+		return
+	}
+
+	uploadMbps, downloadMbps := upload/1_000_000, download/1_000_000
+	if !alert.Anomalous(uploadMbps, ratio) {
+		m.clearRatioAnomaly(vm.VMID, cfg)
+		return
+	}
+	if _, anomalous := m.ratioAnomalies.LoadOrStore(vm.VMID, true); anomalous {
+		return
+	}
+
+	log.Printf("警告: VM%d 上传/下载比例异常: 最近 %v 平均上传 %.2f Mbps、下载 %.2f Mbps (%.1f:1，阈值 %.1f:1)",
+		vm.VMID, window, uploadMbps, downloadMbps, ratio, alert.Ratio)
+	if cfg.TagsManaged() {
+		if err := m.pveFor(vm.VMID).AddVMTag(vm.VMID, cfg.Tags.RatioTag()); err != nil {
+			log.Printf("VM%d 添加标签 %s 失败: %v", vm.VMID, cfg.Tags.RatioTag(), err)
+		}
+	}
+	m.publish(events.Event{Type: events.RatioAnomaly, VMID: vm.VMID, Ratio: &events.Ratio{
+		UploadMbps:    uploadMbps,
+		DownloadMbps:  downloadMbps,
+		Ratio:         ratio,
+		Threshold:     alert.Ratio,
+		WindowMinutes: int(window / time.Minute),
+	}})
+}
+
+// clearRatioAnomaly 上传/下载比例恢复正常（或不再检查）时移除标签
+func (m *Monitor) clearRatioAnomaly(vmid int, cfg models.MonitorConfig) {
+	if _, anomalous := m.ratioAnomalies.LoadAndDelete(vmid); !anomalous {
+		return
+	}
+	log.Printf("VM%d 上传/下载比例已恢复正常", vmid)
+	if cfg.TagsManaged() {
+		if err := m.pveFor(vmid).RemoveVMTag(vmid, cfg.Tags.RatioTag()); err != nil {
+			log.Printf("VM%d 移除标签 %s 失败: %v", vmid, cfg.Tags.RatioTag(), err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// recordScan 单个虚拟机记录的检查结果
+type recordScan struct {
+	Count      int // 记录数
+	Resets     int // 计数器回退次数（虚拟机重启或数据异常）
+	OutOfOrder int // 时间戳早于前一条的记录数
+	Duplicates int // 时间戳与前一条相同的记录数
+}
+
+// scanRecords 按存储返回的顺序检查记录，统计计数器回退和时间戳异常
+func scanRecords(records []models.TrafficRecord) recordScan {
+	scan := recordScan{Count: len(records)}
+	for i := 1; i < len(records); i++ {
+		prev, cur := records[i-1], records[i]
+		switch {
+		case cur.Timestamp.Before(prev.Timestamp):
+			scan.OutOfOrder++
+		case cur.Timestamp.Equal(prev.Timestamp):
+			scan.Duplicates++
+		}
+		if cur.RXBytes < prev.RXBytes || cur.TXBytes < prev.TXBytes {
+			scan.Resets++
+		}
+	}
+	return scan
+}
+
+// handleRecompute 手动修改或导入数据后，重新计算时间范围内的用量汇总并重建派生数据：
+// 逐台虚拟机输出记录数、用量和异常记录，然后重建总记录计数器并通知主程序清除统计缓存
+func (m *Monitor) handleRecompute() error {
+	if *startTime == "" || *endTime == "" {
+		return i18n.Errorf("cli.recompute_requires_range")
+	}
+	start, err := m.parseTimeParam(*startTime)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
+	}
+	end, err := m.parseTimeParam(*endTime)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
+	}
+	if start.After(end) {
+		return i18n.Errorf("cli.start_after_end")
+	}
+
+	var vmids []int
+	if *vmID != 0 {
+		vmids = []int{*vmID}
+	} else {
+		vms, err := m.pveClient.GetAllVMsWithFilter(m.configLoader.GetConfig().Monitor.ListsTemplates())
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
+		}
+		for _, vm := range vms {
+			vmids = append(vmids, vm.VMID)
+		}
+	}
+
+	log.Println(i18n.T("cli.recompute_prepare", len(vmids), start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05")))
+
+	var totalRecords int
+	var totalBytes uint64
+	for i, vmid := range vmids {
+		records, err := m.storage.GetTrafficRecords(vmid, start, end)
+		if err != nil {
+			log.Println(i18n.T("cli.recompute_vm_failed", i+1, len(vmids), vmid, err))
+			continue
+		}
+		scan := scanRecords(records)
+
+		stats, err := m.storage.CalculateTrafficStatsWithTimeRange(vmid, start, end, models.DirectionBoth)
+		if err != nil {
+			log.Println(i18n.T("cli.recompute_vm_failed", i+1, len(vmids), vmid, err))
+			continue
+		}
+
+		totalRecords += scan.Count
+		totalBytes += stats.TotalBytes
+		log.Println(i18n.T("cli.recompute_vm", i+1, len(vmids), vmid, scan.Count,
+			float64(stats.RXBytes)/models.BytesPerGB, float64(stats.TXBytes)/models.BytesPerGB))
+		if scan.Resets > 0 || scan.OutOfOrder > 0 || scan.Duplicates > 0 {
+			log.Println(i18n.T("cli.recompute_vm_anomalies", vmid, scan.Resets, scan.OutOfOrder, scan.Duplicates))
+		}
+	}
+
+	log.Println(i18n.T("cli.recompute_summary", len(vmids), totalRecords, float64(totalBytes)/models.BytesPerGB))
+	setResult("vms", len(vmids))
+	setResult("records", totalRecords)
+	setResult("total_bytes", totalBytes)
+	setResult("start", start)
+	setResult("end", end)
+	setResult("dry_run", *dryRun)
+
+	if *dryRun {
+		log.Println(i18n.T("cli.recompute_dry_run"))
+		return nil
+	}
+
+	// 重建总记录计数器（文件存储的 .record_count、数据库存储的缓存计数）
+	if rebuilder, ok := storage.As[storage.CounterRebuilder](m.storage); ok {
+		started := time.Now()
+		count, err := rebuilder.RebuildRecordCount()
+		if err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.recompute_counter_failed"), err)
+		}
+		log.Println(i18n.T("cli.recompute_counter", count, time.Since(started).Round(time.Millisecond)))
+		setResult("record_count", count)
+	}
+
+	// 通知主程序清除统计缓存（API 响应缓存随之失效）
+	m.notifyMainProgram("reload_cache", map[string]interface{}{
+		"reason": "recompute",
+		"vmid":   *vmID,
+		"start":  start,
+		"end":    end,
+	})
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// tagDiff 一台虚拟机的标签与恢复记录的差异
+type tagDiff struct {
+	Orphan  []string // 标签命名空间内没有对应限制的标签（移除）
+	Missing []string // 仍在限制中但缺少的标签（重新添加）
+}
+
+// diffTags 比较虚拟机上的标签和应有的标签（不区分大小写）：命名空间内既不在 expected、keep 也不保留的标签为孤立标签
+func diffTags(current, expected []string, tags models.TagConfig, keep func(tag string) bool) tagDiff {
+	var diff tagDiff
+	want := make(map[string]bool, len(expected))
+	for _, tag := range expected {
+		want[strings.ToLower(tag)] = true
+	}
+	have := make(map[string]bool, len(current))
+	for _, tag := range current {
+		lower := strings.ToLower(tag)
+		have[lower] = true
+		if tags.IsManaged(lower) && !want[lower] && !keep(lower) {
+			diff.Orphan = append(diff.Orphan, lower)
+		}
+	}
+	for _, tag := range expected {
+		if lower := strings.ToLower(tag); !have[lower] {
+			diff.Missing = append(diff.Missing, lower)
+		}
+	}
+	return diff
+}
+
+// orphanAction 孤立标签对应的网络限制操作（断网或限速），其他标签返回空字符串
+func orphanAction(tag string, tags models.TagConfig) string {
+	for _, action := range []string{models.ActionDisconnect, models.ActionRateLimit} {
+		if tag == tags.ActionTag(action) {
+			return action
+		}
+	}
+	return ""
+}
+
+// undoOrphanAction 解除孤立标签对应的网络限制：没有恢复记录，无法还原原始设置，按默认值恢复（连接网卡、移除限速）
+func (m *Monitor) undoOrphanAction(vmid int, action string) error {
+	client := m.pveFor(vmid)
+	switch action {
+	case models.ActionDisconnect:
+		return client.ConnectNetwork(vmid)
+	case models.ActionRateLimit:
+		return client.RemoveNetworkRateLimit(vmid)
+	}
+	return nil
+}
+
+// reconcileTags 启动或成为主实例时核对虚拟机上的操作标签和恢复记录：移除没有恢复记录的标签，为仍在限制中的虚拟机补上缺少的标签，并记录差异
+// 异常退出可能留下没有安排恢复的标签（规则按标签判断已执行过操作，不再处理该虚拟机），或者执行了操作但还没有添加标签
+func (m *Monitor) reconcileTags(vms []models.VMInfo) {
+	cfg := m.configLoader.GetConfig()
+	monitorConfig := cfg.Monitor
+	if monitorConfig.MarkerBackend() != models.MarkerTags || !monitorConfig.TagsManaged() {
+		return
+	}
+
+	// 规则的流量状态标签每个周期按用量更新，只移除已删除的规则、带宽规则（不打该标签）和旧版本命名留下的标签
+	ruleTags := make(map[string]bool, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		if rule.IsRateRule() {
+			continue
+		}
+		ruleTags[monitorConfig.Tags.RuleTag(rule.Name)] = true
+	}
+	keep := func(tag string) bool { return ruleTags[tag] }
+
+	listed := make(map[int]bool, len(vms))
+	var removed, added int
+	for _, vm := range vms {
+		listed[vm.VMID] = true
+
+		var expected []string
+		if state, limited := m.recoveryManager.State(vm.VMID); limited {
+			if tag := monitorConfig.Tags.ActionTag(state.ActionTaken); tag != "" {
+				expected = append(expected, tag)
+			}
+		}
+		if _, anomalous := m.ratioAnomalies.Load(vm.VMID); anomalous {
+			expected = append(expected, monitorConfig.Tags.RatioTag())
+		}
+
+		diff := diffTags(vm.Tags, expected, monitorConfig.Tags, keep)
+		for _, tag := range diff.Orphan {
+			// 标签移除后规则不再认为执行过操作，先解除网络限制，失败时保留标签，下次核对时重试
+			if action := orphanAction(tag, monitorConfig.Tags); action != "" {
+				log.Printf("VM%d 的标签 %s 没有对应的恢复记录（上次可能异常退出），解除限制并移除标签", vm.VMID, tag)
+				if err := m.undoOrphanAction(vm.VMID, action); err != nil {
+					log.Printf("VM%d 解除限制失败，保留标签 %s: %v", vm.VMID, tag, err)
+					continue
+				}
+			} else {
+				log.Printf("VM%d 的标签 %s 没有对应的恢复记录（上次可能异常退出），移除标签；关机的虚拟机不自动启动", vm.VMID, tag)
+			}
+			if err := m.pveFor(vm.VMID).RemoveVMTag(vm.VMID, tag); err != nil {
+				log.Printf("VM%d 移除标签 %s 失败: %v", vm.VMID, tag, err)
+				continue
+			}
+			removed++
+		}
+		for _, tag := range diff.Missing {
+			log.Printf("VM%d 仍在限制中，补上缺少的标签 %s", vm.VMID, tag)
+			if err := m.pveFor(vm.VMID).AddVMTag(vm.VMID, tag); err != nil {
+				log.Printf("VM%d 添加标签 %s 失败: %v", vm.VMID, tag, err)
+				continue
+			}
+			added++
+		}
+	}
+
+	// 代理节点的虚拟机在代理推送后才出现在列表中，只记录不处理
+	for _, state := range m.recoveryManager.States() {
+		if !listed[state.VMID] {
+			log.Printf("VM%d 有待恢复的记录（规则 %s，操作 %s），但不在当前的虚拟机列表中", state.VMID, state.RuleName, state.ActionTaken)
+		}
+	}
+	if removed > 0 || added > 0 {
+		log.Printf("标签核对完成: 移除 %d 个孤立标签，补上 %d 个缺少的标签", removed, added)
+	}
+}
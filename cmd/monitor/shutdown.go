@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/ipc"
+)
+
+// exit 退出前的处理：按需恢复被限制的虚拟机（否则保留限制和恢复记录），释放租约并关闭存储
+func (m *Monitor) exit(recoverVMs bool) {
+	log.Println("正在退出...")
+	cfg := m.configLoader.GetConfig()
+
+	// 等待正在执行的操作结束，未开始的操作丢弃（重启后由规则重新判断）
+	if m.actions != nil {
+		if dropped := m.actions.Stop(); dropped > 0 {
+			log.Printf("丢弃执行队列中 %d 个未开始的操作", dropped)
+		}
+	}
+
+	// 备用实例没有执行过操作，内存中的恢复记录可能已过期，不恢复
+	if recoverVMs && m.isLeader() {
+		vms, err := m.allVMs(cfg.Monitor.ListsTemplates())
+		if err == nil {
+			m.recoveryManager.CleanupAllTags(vms)
+		}
+		m.recoveryManager.RecoverAll()
+	} else if states := len(m.recoveryManager.States()); states > 0 && m.isLeader() {
+		log.Printf("保留 %d 台虚拟机的限制，恢复记录已保存，重启后继续按计划恢复", states)
+	}
+
+	// 等待事件插件处理完已发布的事件（如恢复事件写入审计日志）
+	if m.plugins != nil {
+		m.plugins.Close()
+		m.events.Close()
+	}
+
+	// 主备模式：停止续期并释放租约，由备用实例立即接管
+	m.stopLeaseRenewal()
+	if cfg.Monitor.HA.Enabled {
+		m.releaseLease()
+	}
+
+	// 关闭存储（保存计数器等）
+	if err := m.storage.Close(); err != nil {
+		log.Printf("关闭存储失败: %v", err)
+	}
+
+	log.Println("已退出")
+}
+
+// handleShutdownQuery 回复 shutdown 请求并通知主循环退出（monitor shutdown 命令）
+func (m *Monitor) handleShutdownQuery(msg ipc.Message) map[string]interface{} {
+	recoverVMs, _ := msg.Data["recover"].(bool)
+	select {
+	case m.shutdownChan <- recoverVMs:
+	default:
+		// 已有退出请求在处理
+	}
+	return map[string]interface{}{
+		"recover":         recoverVMs,
+		"recovery_states": len(m.recoveryManager.States()),
+	}
+}
+
+// runShutdownCommand 处理 shutdown 子命令：请求正在运行的主程序退出（-recover 时先恢复所有被限制的虚拟机）
+func runShutdownCommand(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+
+	reply, err := ipc.NewClient(ipc.GetDefaultSocketPath(ipcBasePath(cfg))).Query(ipc.Message{
+		Type:      "shutdown",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"recover": *shutdownRecover},
+	})
+	if err != nil {
+		return i18n.Errorf("cli.shutdown_no_daemon", err)
+	}
+
+	states := toInt64(reply.Data["recovery_states"])
+	setResult("recover", *shutdownRecover)
+	setResult("recovery_states", states)
+	if *shutdownRecover {
+		log.Println(i18n.T("cli.shutdown_recover", states))
+	} else {
+		log.Println(i18n.T("cli.shutdown_keep", states))
+	}
+	return nil
+}
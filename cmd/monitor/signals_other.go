@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// diagnosticSignals 其他平台没有 SIGUSR1/SIGUSR2，不监听诊断信号
+var diagnosticSignals []os.Signal
+
+// isDumpSignal 其他平台不会收到诊断信号
+func isDumpSignal(sig os.Signal) bool {
+	return false
+}
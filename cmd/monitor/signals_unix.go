@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticSignals 诊断信号：SIGUSR1 输出诊断信息，SIGUSR2 切换调试日志
+var diagnosticSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+
+// isDumpSignal 是否为输出诊断信息的信号（其余诊断信号切换调试日志）
+func isDumpSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR1
+}
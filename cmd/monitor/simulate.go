@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/simulate"
+)
+
+// loadSimulationRule 读取待模拟的规则文件（单条规则的 JSON），-period 显式指定时覆盖规则周期
+func loadSimulationRule(path string) (models.Rule, error) {
+	var rule models.Rule
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rule, fmt.Errorf("%s: %w", i18n.T("cli.simulate_read_rule_failed"), err)
+	}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return rule, fmt.Errorf("%s: %w", i18n.T("cli.simulate_read_rule_failed"), err)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "period" {
+			rule.Period = *period
+		}
+	})
+	rule.Enabled = true
+
+	if err := rule.Validate(); err != nil {
+		return rule, fmt.Errorf("%s: %w", i18n.T("cli.simulate_invalid_rule"), err)
+	}
+	return rule, nil
+}
+
+// handleSimulate 用历史数据回放假设的规则，报告哪些虚拟机会在何时被限制
+// 时间范围默认为上一个完整周期的开始到现在，可用 -start/-end 指定
+func (m *Monitor) handleSimulate(rulePath string) error {
+	rule, err := loadSimulationRule(rulePath)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now()
+	calc := periodcalc.ForRule(rule, time.Time{})
+	start := calc.PeriodStartAt(calc.PeriodStartAt(end).Add(-time.Nanosecond))
+	if *startTime != "" && *endTime != "" {
+		if start, err = m.parseTimeParam(*startTime); err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_start_failed"), err)
+		}
+		if end, err = m.parseTimeParam(*endTime); err != nil {
+			return fmt.Errorf("%s: %w", i18n.T("cli.parse_end_failed"), err)
+		}
+		if start.After(end) {
+			return i18n.Errorf("cli.start_after_end")
+		}
+	}
+
+	cfg := m.configLoader.GetConfig()
+	vms, err := m.pveClient.GetAllVMsWithFilter(cfg.Monitor.ListsTemplates())
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("cli.list_vms_failed"), err)
+	}
+
+	log.Println(i18n.T("cli.simulate_range", rule.Name, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05")))
+
+	opts := simulate.Options{Interval: time.Duration(cfg.Monitor.IntervalSeconds) * time.Second}
+	matched := 0
+	events := []simulate.Event{}
+	for _, vm := range vms {
+		if !m.vmMatchesRule(vm, rule) {
+			continue
+		}
+		matched++
+
+		opts.CreationTime = time.Time{}
+		if rule.UseCreationTime {
+			if ct, err := m.creation.Time(vm.VMID); err == nil {
+				opts.CreationTime = ct
+			}
+		}
+
+		// 从开始时间所在周期的起点（带宽规则为一个窗口之前）读取记录，保证开始时的用量正确
+		from := periodcalc.ForRule(rule, opts.CreationTime).PeriodStartAt(start)
+		if rule.IsRateRule() {
+			from = start.Add(-rule.RateWindow())
+		}
+		records, err := m.storage.GetTrafficRecords(vm.VMID, from, end)
+		if err != nil {
+			log.Println(i18n.T("cli.vm_stats_failed", vm.VMID, err))
+			continue
+		}
+
+		for _, event := range simulate.Run(rule, vm, records, opts) {
+			if !event.TriggeredAt.Before(start) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	if *exportFormat == "json" || *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(events)
+	}
+
+	printSimulationEvents(rule, events)
+	log.Println(i18n.T("cli.simulate_summary", matched, len(events)))
+	return nil
+}
+
+// printSimulationEvents 以表格形式输出模拟结果
+func printSimulationEvents(rule models.Rule, events []simulate.Event) {
+	if len(events) == 0 {
+		log.Println(i18n.T("cli.simulate_none"))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, i18n.T("cli.simulate_header"))
+	for _, event := range events {
+		value := fmt.Sprintf("%.2f / %.2f GB", event.UsedGB, event.LimitGB)
+		if rule.IsRateRule() || rule.IsPercentileRule() {
+			value = fmt.Sprintf("%.2f / %.2f Mbps", event.RateMbps, event.RateThresholdMbps)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			event.VMID,
+			event.Name,
+			event.Action,
+			event.TriggeredAt.Format("2006-01-02 15:04:05"),
+			event.RecoveryAt.Format("2006-01-02 15:04:05"),
+			value,
+		)
+	}
+	w.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"pve-traffic-monitor/pkg/config"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/ipc"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ipcBasePath socket 和 PID 文件所在目录：文件存储使用存储目录，数据库存储使用临时目录
+// 主备模式下存储目录可能由多台主机共用（网络文件系统），也使用本机的临时目录
+func ipcBasePath(cfg *models.Config) string {
+	if (cfg.Storage.Type == "file" || cfg.Storage.Type == "") && !cfg.Monitor.HA.Enabled {
+		return cfg.Storage.FilePath
+	}
+	return filepath.Join(os.TempDir(), "pve-traffic-monitor")
+}
+
+// hostname 当前主机名（获取失败时为空）
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// absPath 转换为绝对路径（失败时保持原样）
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// storageDescription 存储的简要描述（数据库只显示类型，不包含可能带密码的 DSN）
+func storageDescription(cfg models.StorageConfig) string {
+	if cfg.Type == "file" || cfg.Type == "" {
+		return "file:" + absPath(cfg.FilePath)
+	}
+	return cfg.Type
+}
+
+// handleStatusQuery 回复 status 查询（monitor status 命令）
+func (m *Monitor) handleStatusQuery(msg ipc.Message) map[string]interface{} {
+	cfg := m.configLoader.GetConfig()
+	return map[string]interface{}{
+		"pid":              os.Getpid(),
+		"hostname":         hostname(),
+		"started_at":       m.startedAt,
+		"uptime_seconds":   int64(time.Since(m.startedAt).Seconds()),
+		"config_path":      absPath(*configPath),
+		"storage":          storageDescription(cfg.Storage),
+		"node":             cfg.PVE.Node,
+		"interval_seconds": cfg.Monitor.IntervalSeconds,
+		"rules":            len(cfg.Rules),
+		"recovery_states":  len(m.recoveryManager.States()),
+		"leader":           m.isLeader(),
+		"maintenance":      m.inMaintenance(),
+		"go_version":       runtime.Version(),
+	}
+}
+
+// runStatusCommand 处理 status 子命令：通过 IPC 查询正在运行的主程序，IPC 不可用时读取 PID 文件
+func runStatusCommand(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+
+	loader, err := config.NewLoader(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := loader.GetConfig()
+	if *langFlag == "" {
+		i18n.SetLocale(cfg.Locale)
+	}
+	base := ipcBasePath(cfg)
+
+	reply, err := ipc.NewClient(ipc.GetDefaultSocketPath(base)).Query(ipc.Message{Type: "status", Timestamp: time.Now()})
+	if err == nil {
+		d := reply.Data
+		setResult("running", true)
+		setResult("status", d)
+		log.Println(i18n.T("cli.status_running", d["pid"], d["hostname"], d["started_at"],
+			time.Duration(toInt64(d["uptime_seconds"]))*time.Second, d["config_path"]))
+		log.Println(i18n.T("cli.status_detail", d["storage"], d["node"], d["interval_seconds"], d["rules"], d["recovery_states"]))
+		if maintenance, _ := d["maintenance"].(bool); maintenance {
+			log.Println(i18n.T("cli.status_maintenance"))
+		}
+		return nil
+	}
+
+	// IPC 不可用（主程序启动失败或是旧版本）时根据实例锁判断
+	pidPath := ipc.GetDefaultPIDPath(base)
+	info, running, lockErr := ipc.RunningInstance(pidPath)
+	if lockErr != nil {
+		return lockErr
+	}
+	setResult("running", running)
+	if !running {
+		log.Println(i18n.T("cli.status_not_running", pidPath))
+		return nil
+	}
+	if info == nil {
+		info = &ipc.InstanceInfo{}
+	}
+	setResult("status", map[string]interface{}{"pid": info.PID, "hostname": info.Hostname, "started_at": info.StartedAt})
+	setResult("ipc_error", err.Error())
+	log.Println(i18n.T("cli.status_no_ipc", info.PID, info.Hostname, info.StartedAt.Format(time.RFC3339), err))
+	return nil
+}
+
+// toInt64 JSON 数字转换为整数
+func toInt64(v interface{}) int64 {
+	if f, ok := v.(float64); ok {
+		return int64(f)
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// SetAgentHandler 设置代理推送的处理函数（汇总模式，未设置时 /api/agent/push 返回 404）
+func (s *Server) SetAgentHandler(handler func(models.AgentPush) error) {
+	s.agentHandler = handler
+}
+
+// SetRemoteVMs 设置代理节点上的虚拟机来源（汇总模式，合并到虚拟机列表）
+func (s *Server) SetRemoteVMs(list func() []models.VMInfo) {
+	s.remoteVMs = list
+}
+
+// handleAgentPush 接收采集代理推送的采样（使用 aggregator.agent_token 认证，与 API 令牌分开）
+// POST /api/agent/push
+func (s *Server) handleAgentPush(w http.ResponseWriter, r *http.Request) {
+	if s.agentHandler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.sendError(w, s.tr(r, "api.method_not_allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	expected := s.config.Aggregator.AgentToken
+	if expected == "" || subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(expected)) != 1 {
+		s.sendError(w, s.tr(r, "api.unauthorized"), http.StatusUnauthorized)
+		return
+	}
+
+	var push models.AgentPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		s.sendError(w, s.tr(r, "api.invalid_body", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.agentHandler(push); err != nil {
+		s.sendError(w, s.tr(r, "api.agent_push_failed", err), http.StatusBadRequest)
+		return
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success":  true,
+		"accepted": len(push.Records),
+	})
+}
+
+// allVMs 本节点和代理节点上的虚拟机（本节点的信息优先）
+func (s *Server) allVMs(includeTemplates bool) ([]models.VMInfo, error) {
+	vms, err := s.pveClient.GetAllVMsWithFilter(includeTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if s.remoteVMs == nil {
+		return vms, nil
+	}
+
+	seen := make(map[int]bool, len(vms))
+	for _, vm := range vms {
+		seen[vm.VMID] = true
+	}
+	for _, vm := range s.remoteVMs() {
+		if !seen[vm.VMID] && (includeTemplates || !vm.IsTemplate()) {
+			vms = append(vms, vm)
+		}
+	}
+	return vms, nil
+}
+
+// vmStatus 虚拟机当前状态（代理节点上的虚拟机使用最近一次推送的信息）
+func (s *Server) vmStatus(vmid int) (*models.VMInfo, error) {
+	if s.remoteVMs != nil {
+		for _, vm := range s.remoteVMs() {
+			if vm.VMID == vmid {
+				return &vm, nil
+			}
+		}
+	}
+	return s.pveClient.GetVMStatus(vmid)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestHandleAgentPush(t *testing.T) {
+	s := &Server{config: &models.Config{
+		API:        models.APIConfig{Token: "admin"},
+		Aggregator: models.AggregatorConfig{AgentToken: "agent-secret"},
+	}}
+
+	body := `{"node":"pve2","vms":[{"vmid":200}],"records":[{"vmid":200,"timestamp":"2026-03-01T00:00:00Z","rx_bytes":1}]}`
+	push := func(method, token, body string) int {
+		req := httptest.NewRequest(method, "/api/agent/push", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.handleAgentPush(rec, req)
+		return rec.Code
+	}
+
+	// 非汇总模式不提供推送接口
+	if code := push("POST", "agent-secret", body); code != http.StatusNotFound {
+		t.Fatalf("push without handler: code = %d, want 404", code)
+	}
+
+	var received []models.AgentPush
+	s.SetAgentHandler(func(p models.AgentPush) error {
+		if len(p.Records) == 0 {
+			return errors.New("no records")
+		}
+		received = append(received, p)
+		return nil
+	})
+
+	tests := []struct {
+		method, token, body string
+		wantCode            int
+	}{
+		{"GET", "agent-secret", "", http.StatusMethodNotAllowed},
+		{"POST", "admin", body, http.StatusUnauthorized}, // API 令牌不能推送
+		{"POST", "", body, http.StatusUnauthorized},
+		{"POST", "agent-secret", "{", http.StatusBadRequest},
+		{"POST", "agent-secret", `{"node":"pve2"}`, http.StatusBadRequest},
+		{"POST", "agent-secret", body, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := push(tt.method, tt.token, tt.body); code != tt.wantCode {
+			t.Fatalf("%s token=%q body=%q: code = %d, want %d", tt.method, tt.token, tt.body, code, tt.wantCode)
+		}
+	}
+	if len(received) != 1 || received[0].Node != "pve2" || received[0].Records[0].VMID != 200 {
+		t.Fatalf("received = %+v, want one push from pve2", received)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"pve-traffic-monitor/pkg/chart"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// handleChart 以图片返回虚拟机的流量图表（与 -export 的 png/svg 相同）
+// GET /api/chart/{vmid}?format=svg&width=1200&height=600&dpi=144&dark=true&title=...
+// 时间范围与 /api/history 相同（period 或 start/end/granularity），step 指定时按步长聚合
+func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
+	vmid, err := strconv.Atoi(r.URL.Path[len("/api/chart/"):])
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.invalid_vmid"), http.StatusBadRequest)
+		return
+	}
+	if !s.checkVMAccess(w, r, vmid) {
+		return
+	}
+
+	options, ok := s.chartOptions(w, r)
+	if !ok {
+		return
+	}
+	window, ok := s.parseHistoryWindow(w, r)
+	if !ok {
+		return
+	}
+	period := window.Period
+	if step := r.URL.Query().Get("step"); step != "" {
+		if _, ok := storage.ParseBucketStep(step); !ok {
+			s.sendError(w, s.tr(r, "api.invalid_param", "step", step), http.StatusBadRequest)
+			return
+		}
+		period = step
+	}
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = models.MetricNetwork
+	}
+	if !models.ValidMetric(metric) {
+		s.sendError(w, s.tr(r, "api.invalid_param", "metric", metric), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.storage.GetTrafficRecords(vmid, window.Start, window.End)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		s.sendError(w, s.tr(r, "api.chart_no_records", vmid), http.StatusNotFound)
+		return
+	}
+	records = storage.MetricRecords(records, metric)
+
+	var name string
+	if vms, err := s.allVMs(false); err == nil {
+		for _, vm := range vms {
+			if vm.VMID == vmid {
+				name = vm.Name
+				break
+			}
+		}
+	}
+
+	// 先渲染到缓冲区，失败时仍可返回 JSON 错误
+	var buf bytes.Buffer
+	if err := chart.RenderTrafficChart(&buf, vmid, name, records, window.Start, window.End, period, options); err != nil {
+		s.sendError(w, s.tr(r, "api.chart_render_failed", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", options.ContentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// chartOptions 解析图表的 format、width、height、dpi、dark、title、x_label、y_label 参数，出错时已写入错误响应
+func (s *Server) chartOptions(w http.ResponseWriter, r *http.Request) (chart.RenderOptions, bool) {
+	query := r.URL.Query()
+	options := chart.RenderOptions{
+		Format: query.Get("format"),
+		Title:  query.Get("title"),
+		XLabel: query.Get("x_label"),
+		YLabel: query.Get("y_label"),
+	}
+	for _, param := range []struct {
+		name string
+		dst  *int
+	}{{"width", &options.Width}, {"height", &options.Height}} {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				s.sendError(w, s.tr(r, "api.invalid_param", param.name, v), http.StatusBadRequest)
+				return chart.RenderOptions{}, false
+			}
+			*param.dst = n
+		}
+	}
+	if v := query.Get("dpi"); v != "" {
+		dpi, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.invalid_param", "dpi", v), http.StatusBadRequest)
+			return chart.RenderOptions{}, false
+		}
+		options.DPI = dpi
+	}
+	if v := query.Get("dark"); v != "" {
+		dark, err := strconv.ParseBool(v)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.invalid_param", "dark", v), http.StatusBadRequest)
+			return chart.RenderOptions{}, false
+		}
+		options.Dark = dark
+	}
+
+	if err := options.Validate(); err != nil {
+		var optErr *chart.OptionError
+		if errors.As(err, &optErr) {
+			s.sendError(w, s.tr(r, "api.invalid_param", optErr.Param, fmt.Sprint(optErr.Value)), http.StatusBadRequest)
+		} else {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+		}
+		return chart.RenderOptions{}, false
+	}
+	return options, true
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestHandleChartParams(t *testing.T) {
+	s := &Server{
+		config:  &models.Config{},
+		storage: recordsStorage{},
+		cache:   &Cache{data: make(map[string]*CacheEntry)},
+	}
+	for _, query := range []string{"format=gif", "width=10", "height=abc", "dpi=2000", "title={{.Nope}}", "step=7s", "metric=cpu", "period=week"} {
+		rec := httptest.NewRecorder()
+		s.handleChart(rec, httptest.NewRequest("GET", "/api/chart/101?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: code = %d, want 400", query, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleChart(rec, httptest.NewRequest("GET", "/api/chart/101?format=svg&period=hour", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("no records: code = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cacheETag 根据缓存 key、缓存生成时间和响应变体（查询参数、客户等）生成弱 ETag
+// 缓存重新生成后 ETag 随之变化
+func cacheETag(key string, createdAt time.Time, variants ...string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d", key, createdAt.UnixNano())
+	for _, v := range variants {
+		fmt.Fprintf(h, "|%s", v)
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// etagMatches 检查 If-None-Match 是否包含指定 ETag（弱比较）
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == target {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified 设置 ETag / Last-Modified，客户端缓存仍然有效时返回 304
+// 返回 true 表示已写入 304 响应
+func (s *Server) checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	// 允许浏览器和代理缓存，但每次都需要重新验证；响应随令牌变化
+	h.Set("Cache-Control", "private, no-cache")
+	h.Add("Vary", "Authorization, X-API-Token")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match 优先于 If-Modified-Since
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !modified.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
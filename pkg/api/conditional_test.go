@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/stats"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := cacheETag("history_100_day", time.Unix(1700000000, 0))
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{etag, true},
+		{"*", true},
+		{`"other", ` + etag, true},
+		{etag[2:], true},
+		{`W/"other"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Fatalf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	if cacheETag("k", time.Unix(1, 0), "a") == cacheETag("k", time.Unix(1, 0), "b") {
+		t.Fatalf("cacheETag ignores variants")
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	s := &Server{}
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := cacheETag("stats_day_both", modified)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", http.MethodGet, nil, false},
+		{"etag match", http.MethodGet, map[string]string{"If-None-Match": etag}, true},
+		{"etag mismatch wins over date", http.MethodGet, map[string]string{"If-None-Match": `W/"x"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, false},
+		{"post ignored", http.MethodPost, map[string]string{"If-None-Match": etag}, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/stats", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		got := s.checkNotModified(rec, req, etag, modified)
+		if got != tt.want {
+			t.Fatalf("%s: checkNotModified = %v, want %v", tt.name, got, tt.want)
+		}
+		if got && rec.Code != http.StatusNotModified {
+			t.Fatalf("%s: status = %d, want %d", tt.name, rec.Code, http.StatusNotModified)
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Fatalf("%s: ETag = %q, want %q", tt.name, rec.Header().Get("ETag"), etag)
+		}
+	}
+}
+
+func TestInvalidateCacheOnNewSample(t *testing.T) {
+	s := &Server{cache: &Cache{data: make(map[string]*CacheEntry)}}
+	rangeEnd := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	s.setScopedCache("history_100_day", "a", time.Minute, 100, time.Time{})
+	s.setScopedCache("history_101_day", "b", time.Minute, 101, time.Time{})
+	s.setScopedCache("history_100_range", "c", time.Minute, 100, rangeEnd)
+	s.setCache("stats_day_both", "d", time.Minute)
+
+	s.invalidateCache(stats.Invalidation{VMID: 100, At: rangeEnd.Add(time.Hour)})
+
+	for key, want := range map[string]bool{
+		"history_100_day":   false,
+		"history_101_day":   true,
+		"history_100_range": true,
+		"stats_day_both":    false,
+	} {
+		if _, ok := s.getCacheEntry(key); ok != want {
+			t.Fatalf("cache %s present = %v, want %v", key, ok, want)
+		}
+	}
+
+	s.invalidateCache(stats.Invalidation{VMID: stats.AllVMs})
+	if total, _ := s.cache.getStats(); total != 0 {
+		t.Fatalf("cache total after clear = %d, want 0", total)
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+const ruleUsageCacheTTL = 30 * time.Second
+
+// RuleVMUsage 单个虚拟机在某条规则下的用量
+type RuleVMUsage struct {
+	VMID       int     `json:"vmid"`
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	UsedBytes  uint64  `json:"used_bytes,omitempty"`  // 流量规则：周期内已用字节数
+	LimitBytes uint64  `json:"limit_bytes,omitempty"` // 流量规则：限额字节数
+	RateMbps   float64 `json:"rate_mbps,omitempty"`   // 带宽规则：窗口内平均带宽
+	Percent    float64 `json:"percent"`               // 使用率（%）
+	Exceeded   bool    `json:"exceeded"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// RuleUsage 规则及其匹配虚拟机的用量汇总
+type RuleUsage struct {
+	Rule          models.Rule   `json:"rule"`
+	VMCount       int           `json:"vm_count"`
+	ExceededCount int           `json:"exceeded_count"`
+	VMs           []RuleVMUsage `json:"vms"`
+}
+
+// ActionSummary 操作日志聚合统计
+type ActionSummary struct {
+	Total     int            `json:"total"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	ByAction  map[string]int `json:"by_action"`
+	ByRule    map[string]int `json:"by_rule"`
+	ByVM      map[int]int    `json:"by_vm"`
+	LastTime  *time.Time     `json:"last_time,omitempty"`
+}
+
+// actionLogFilter 操作日志过滤条件
+type actionLogFilter struct {
+	Start   time.Time
+	End     time.Time
+	VMID    int          // 0 表示不过滤
+	Rule    string       // 空表示不过滤
+	Action  string       // 空表示不过滤
+	Success *bool        // nil 表示不过滤
+	VMIDs   map[int]bool // 客户可访问的虚拟机，nil 表示不过滤
+}
+
+// parseActionLogFilter 解析 ?start, ?end, ?vmid, ?rule, ?action, ?success 参数
+// 时间范围默认为最近 7 天
+func parseActionLogFilter(r *http.Request) (*actionLogFilter, error) {
+	query := r.URL.Query()
+	filter := &actionLogFilter{
+		End:    time.Now(),
+		Rule:   query.Get("rule"),
+		Action: query.Get("action"),
+	}
+	filter.Start = filter.End.AddDate(0, 0, -7)
+
+	if start := query.Get("start"); start != "" {
+		if t, err := time.Parse(time.RFC3339, start); err == nil {
+			filter.Start = t
+		}
+	}
+	if end := query.Get("end"); end != "" {
+		if t, err := time.Parse(time.RFC3339, end); err == nil {
+			filter.End = t
+		}
+	}
+
+	if vmidStr := query.Get("vmid"); vmidStr != "" {
+		vmid, err := strconv.Atoi(vmidStr)
+		if err != nil {
+			return nil, i18n.Errorf("api.invalid_param", "vmid", vmidStr)
+		}
+		filter.VMID = vmid
+	}
+
+	if successStr := query.Get("success"); successStr != "" {
+		success, err := strconv.ParseBool(successStr)
+		if err != nil {
+			return nil, i18n.Errorf("api.invalid_success", successStr)
+		}
+		filter.Success = &success
+	}
+
+	return filter, nil
+}
+
+// apply 过滤操作日志，结果按时间倒序排列
+func (f *actionLogFilter) apply(logs []models.ActionLog) []models.ActionLog {
+	result := make([]models.ActionLog, 0, len(logs))
+	for _, entry := range logs {
+		if f.VMID != 0 && entry.VMID != f.VMID {
+			continue
+		}
+		if f.VMIDs != nil && !f.VMIDs[entry.VMID] {
+			continue
+		}
+		if f.Rule != "" && entry.RuleName != f.Rule {
+			continue
+		}
+		if f.Action != "" && entry.Action != f.Action {
+			continue
+		}
+		if f.Success != nil && entry.Success != *f.Success {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.After(result[j].Timestamp)
+	})
+	return result
+}
+
+// summarizeActionLogs 按操作、规则、虚拟机聚合操作日志
+func summarizeActionLogs(logs []models.ActionLog) ActionSummary {
+	summary := ActionSummary{
+		ByAction: make(map[string]int),
+		ByRule:   make(map[string]int),
+		ByVM:     make(map[int]int),
+	}
+
+	for i, entry := range logs {
+		summary.Total++
+		if entry.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		summary.ByAction[entry.Action]++
+		summary.ByRule[entry.RuleName]++
+		summary.ByVM[entry.VMID]++
+
+		if summary.LastTime == nil || entry.Timestamp.After(*summary.LastTime) {
+			summary.LastTime = &logs[i].Timestamp
+		}
+	}
+
+	return summary
+}
+
+// handleActionSummary 获取操作日志聚合统计（支持与 /api/logs 相同的过滤参数）
+func (s *Server) handleActionSummary(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseActionLogFilter(r)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+
+	filter.VMIDs, err = s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	logs, err := s.storage.GetActionLogs(filter.Start, filter.End)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_logs_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    summarizeActionLogs(filter.apply(logs)),
+		"start":   filter.Start,
+		"end":     filter.End,
+	})
+}
+
+// scopeRuleUsage 仅保留可访问的虚拟机并重新计算数量（allowed 为 nil 时原样返回）
+func scopeRuleUsage(usages []RuleUsage, allowed map[int]bool) []RuleUsage {
+	if allowed == nil {
+		return usages
+	}
+
+	result := make([]RuleUsage, len(usages))
+	for i, usage := range usages {
+		scoped := RuleUsage{Rule: usage.Rule, VMs: []RuleVMUsage{}}
+		for _, vm := range usage.VMs {
+			if !allowed[vm.VMID] {
+				continue
+			}
+			if vm.Exceeded {
+				scoped.ExceededCount++
+			}
+			scoped.VMs = append(scoped.VMs, vm)
+		}
+		scoped.VMCount = len(scoped.VMs)
+		result[i] = scoped
+	}
+	return result
+}
+
+// handleRuleUsage 获取每条规则匹配的虚拟机及其用量（带缓存，客户密钥只能看到自己的虚拟机）
+func (s *Server) handleRuleUsage(w http.ResponseWriter, r *http.Request) {
+	allowed, err := s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	const cacheKey = "rule_usage"
+	if cached, ok := s.getCache(cacheKey); ok {
+		s.sendJSON(w, map[string]interface{}{
+			"success": true,
+			"data":    scopeRuleUsage(cached.([]RuleUsage), allowed),
+			"cached":  true,
+		})
+		return
+	}
+
+	vms, err := s.allVMs(false)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	getCreationTime := s.creationTimeLookup()
+
+	usages := make([]RuleUsage, 0, len(s.config.Rules))
+	for _, rule := range s.config.Rules {
+		usage := RuleUsage{Rule: rule, VMs: []RuleVMUsage{}}
+		if rule.Enabled {
+			for _, vm := range vms {
+				if !pve.VMMatchesRule(vm, rule) {
+					continue
+				}
+
+				vmUsage := s.calculateRuleVMUsage(vm, rule, getCreationTime)
+				if vmUsage.Exceeded {
+					usage.ExceededCount++
+				}
+				usage.VMs = append(usage.VMs, vmUsage)
+			}
+		}
+
+		// 使用率高的排在前面
+		sort.SliceStable(usage.VMs, func(i, j int) bool {
+			return usage.VMs[i].Percent > usage.VMs[j].Percent
+		})
+		usage.VMCount = len(usage.VMs)
+		usages = append(usages, usage)
+	}
+
+	s.setCache(cacheKey, usages, ruleUsageCacheTTL)
+
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    scopeRuleUsage(usages, allowed),
+		"cached":  false,
+	})
+}
+
+// calculateRuleVMUsage 计算虚拟机在规则下的使用率
+func (s *Server) calculateRuleVMUsage(vm models.VMInfo, rule models.Rule, getCreationTime func(int) time.Time) RuleVMUsage {
+	usage := RuleVMUsage{
+		VMID:   vm.VMID,
+		Name:   vm.Name,
+		Status: vm.Status,
+	}
+
+	direction := models.DirectionBoth
+	if rule.TrafficDirection != "" {
+		direction = strings.ToLower(rule.TrafficDirection)
+	}
+
+	if rule.IsRateRule() {
+		now := time.Now()
+		records, err := s.storage.GetTrafficRecords(vm.VMID, now.Add(-rule.RateWindow()), now)
+		if err != nil {
+			usage.Error = err.Error()
+			return usage
+		}
+
+		records = storage.MetricRecords(records, rule.Metric)
+
+		bitsPerSecond, _ := storage.CalculateAverageRate(vm.VMID, records, direction)
+		usage.RateMbps = bitsPerSecond / 1_000_000
+		if rule.RateThresholdMbps > 0 {
+			usage.Percent = usage.RateMbps / rule.RateThresholdMbps * 100
+		}
+		usage.Exceeded = usage.RateMbps > rule.RateThresholdMbps
+		return usage
+	}
+
+	var creationTime time.Time
+	if rule.UseCreationTime {
+		creationTime = getCreationTime(vm.VMID)
+	}
+
+	if rule.IsPercentileRule() {
+		now := time.Now()
+		startTime := periodcalc.ForRule(rule, creationTime).PeriodStartAt(now)
+		records, err := s.storage.GetTrafficRecords(vm.VMID, startTime, now)
+		if err != nil {
+			usage.Error = err.Error()
+			return usage
+		}
+
+		profile := storage.UsageProfileOf(vm.VMID, rule.Period, startTime, now, direction, storage.MetricRecords(records, rule.Metric))
+		usage.RateMbps = profile.P95Mbps
+		if rule.RateThresholdMbps > 0 {
+			usage.Percent = usage.RateMbps / rule.RateThresholdMbps * 100
+		}
+		usage.Exceeded = profile.Samples >= storage.MinPercentileSamples && usage.RateMbps > rule.RateThresholdMbps
+		return usage
+	}
+
+	stats, err := s.stats.CalculateMetricFor(periodcalc.ForRule(rule, creationTime), vm.VMID, direction, rule.Metric)
+	if err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+
+	usage.UsedBytes = stats.TotalBytes
+	usage.LimitBytes = uint64(rule.LimitGB * models.BytesPerGB)
+	if usage.LimitBytes > 0 {
+		usage.Percent = float64(usage.UsedBytes) / float64(usage.LimitBytes) * 100
+	}
+	usage.Exceeded = stats.TotalGB > rule.LimitGB
+	return usage
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/stats"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestActionLogFilterAndSummary(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := []models.ActionLog{
+		{VMID: 100, RuleName: "monthly", Action: models.ActionShutdown, Timestamp: base, Success: true},
+		{VMID: 101, RuleName: "monthly", Action: models.ActionShutdown, Timestamp: base.Add(time.Hour), Success: false},
+		{VMID: 100, RuleName: "daily", Action: models.ActionRateLimit, Timestamp: base.Add(2 * time.Hour), Success: true},
+	}
+
+	filter, err := parseActionLogFilter(httptest.NewRequest("GET", "/api/logs?rule=monthly", nil))
+	if err != nil {
+		t.Fatalf("parseActionLogFilter() error = %v", err)
+	}
+	filtered := filter.apply(logs)
+	if len(filtered) != 2 || filtered[0].VMID != 101 {
+		t.Fatalf("filtered = %+v, want 2 monthly logs newest first", filtered)
+	}
+
+	filter, err = parseActionLogFilter(httptest.NewRequest("GET", "/api/logs?vmid=100&success=true", nil))
+	if err != nil {
+		t.Fatalf("parseActionLogFilter() error = %v", err)
+	}
+	if got := len(filter.apply(logs)); got != 2 {
+		t.Fatalf("len(filtered) = %d, want 2", got)
+	}
+
+	summary := summarizeActionLogs(logs)
+	if summary.Total != 3 || summary.Succeeded != 2 || summary.Failed != 1 {
+		t.Fatalf("summary = %+v, want total 3 / succeeded 2 / failed 1", summary)
+	}
+	if summary.ByRule["monthly"] != 2 || summary.ByVM[100] != 2 || summary.ByAction[models.ActionRateLimit] != 1 {
+		t.Fatalf("summary groups = %+v", summary)
+	}
+	if summary.LastTime == nil || !summary.LastTime.Equal(base.Add(2*time.Hour)) {
+		t.Fatalf("LastTime = %v, want %v", summary.LastTime, base.Add(2*time.Hour))
+	}
+
+	if _, err := parseActionLogFilter(httptest.NewRequest("GET", "/api/logs?success=maybe", nil)); err == nil {
+		t.Fatalf("parseActionLogFilter() accepted invalid success value")
+	}
+}
+
+func TestRulePeriodsUseRuleBasis(t *testing.T) {
+	s := &Server{config: &models.Config{Rules: []models.Rule{
+		{Name: "monthly", Enabled: true, Period: models.PeriodMonth, AnchorDay: 5},
+		{Name: "weekly", Enabled: true, Period: "rolling:7d"},
+		{Name: "disabled", Enabled: false, Period: models.PeriodDay},
+		{Name: "other-vm", Enabled: true, Period: models.PeriodDay, VMIDs: []int{200}},
+	}}}
+
+	periods := s.rulePeriods(models.VMInfo{VMID: 100})
+	if len(periods) != 2 {
+		t.Fatalf("periods = %+v, want monthly and weekly", periods)
+	}
+	if periods[0].Rule != "monthly" || periods[0].Basis != "anchor" || periods[0].Start.Day() != 5 {
+		t.Fatalf("monthly = %+v, want anchor on the 5th", periods[0])
+	}
+	if periods[1].Basis != "rolling" || periods[1].End.Sub(periods[1].Start) != 7*24*time.Hour {
+		t.Fatalf("weekly = %+v, want 7 day rolling window", periods[1])
+	}
+}
+
+func TestRuleEvaluations(t *testing.T) {
+	store, err := storage.NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	if err := storage.SaveTrafficRecords(store, []models.TrafficRecord{
+		{VMID: 100, Timestamp: now.Add(-20 * time.Second)},
+		{VMID: 100, Timestamp: now.Add(-10 * time.Second), RXBytes: 9 * models.BytesPerGB, TotalBytes: 9 * models.BytesPerGB},
+	}); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	s := &Server{
+		config: &models.Config{Rules: []models.Rule{
+			{Name: "hourly", Enabled: true, Period: "rolling:1h", LimitGB: 10, Action: models.ActionRateLimit},
+			{Name: "strict", Enabled: true, Period: "rolling:1h", LimitGB: 5, TrafficDirection: "RX", Action: models.ActionShutdown},
+			{Name: "relaxed", Enabled: true, Period: "rolling:1h", LimitGB: 100, Action: models.ActionShutdown},
+			{Name: "disabled", Enabled: false, Period: "rolling:1h", LimitGB: 1},
+			{Name: "other-vm", Enabled: true, Period: "rolling:1h", LimitGB: 1, VMIDs: []int{200}},
+		}},
+		storage: store,
+		stats:   stats.NewService(store, time.Minute),
+	}
+
+	evals := s.ruleEvaluations(models.VMInfo{VMID: 100}, now)
+	if len(evals) != 3 {
+		t.Fatalf("evaluations = %+v, want hourly, strict and relaxed", evals)
+	}
+	for i, want := range []string{RuleStatusWarning, RuleStatusExceeded, RuleStatusOK} {
+		if evals[i].Status != want || evals[i].Used != 9 || evals[i].Unit != "GB" || evals[i].Type != models.RuleTypeVolume {
+			t.Fatalf("evaluations[%d] = %+v, want status %s with 9 GB used", i, evals[i], want)
+		}
+	}
+	if evals[1].Direction != models.DirectionRX || !evals[1].End.Equal(now) || !evals[1].Start.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("strict = %+v, want rx over the last hour", evals[1])
+	}
+}
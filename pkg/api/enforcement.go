@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// enforcementLogMargin 查找操作日志时在限制开始时间之前多查的时间（日志时间为开始执行的时间，早于记录恢复状态的时间）
+const enforcementLogMargin = time.Hour
+
+// handleVMEnforcement 虚拟机当前是否被监控程序限速、断网或关机，由哪条规则触发、何时开始、计划何时恢复
+// 直接读取存储中的恢复状态和操作日志（备用实例也能看到主实例执行的操作），不需要解析标签
+// GET /api/vm/{vmid}/enforcement
+func (s *Server) handleVMEnforcement(w http.ResponseWriter, r *http.Request, vmid int) {
+	enforcement, err := s.vmEnforcement(vmid, time.Now())
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.enforcement_failed", err), http.StatusInternalServerError)
+		return
+	}
+	s.sendJSON(w, map[string]interface{}{"success": true, "data": enforcement})
+}
+
+// vmEnforcement 组合恢复状态、执行记录、规则配置和操作日志得到虚拟机当前的限制状态
+func (s *Server) vmEnforcement(vmid int, now time.Time) (models.Enforcement, error) {
+	enforcement := models.Enforcement{VMID: vmid, State: models.EnforcementNone}
+	recoveredAt := storage.LastRecoveredAt(s.storage, vmid)
+	if !recoveredAt.IsZero() {
+		enforcement.RecoveredAt = &recoveredAt
+	}
+
+	state, err := storage.LoadRecoveryState(s.storage, vmid)
+	if err != nil || state == nil {
+		return enforcement, err
+	}
+	enforcement.Enforced = true
+	enforcement.State = models.EnforcementState(state.ActionTaken)
+	enforcement.Action = state.ActionTaken
+	enforcement.Rule = state.RuleName
+	enforcement.Since = &state.ActionTime
+	if !state.RecoveryTime.IsZero() {
+		enforcement.RecoveryAt = &state.RecoveryTime
+	}
+	if state.ExternallyModified() {
+		enforcement.External = state.ExternalStatus
+		enforcement.ExternalAt = &state.ExternalAt
+	}
+
+	for _, rule := range s.config.Rules {
+		if rule.Name == state.RuleName {
+			if rule.Action == models.ActionRateLimit {
+				enforcement.RateLimitMB = rule.RateLimitMB
+			}
+			enforcement.Interfaces = rule.Interfaces
+			break
+		}
+	}
+
+	executed, err := storage.LoadExecutedAction(s.storage, vmid, state.RuleName)
+	if err == nil && executed != nil && executed.ExecutedAt.After(recoveredAt) && models.SameAction(executed.Action, state.ActionTaken) {
+		enforcement.Executions = executed.Executions()
+	}
+
+	logs, err := s.storage.GetActionLogs(state.ActionTime.Add(-enforcementLogMargin), now)
+	if err != nil {
+		return enforcement, err
+	}
+	for i := range logs {
+		entry := logs[i]
+		if entry.VMID != vmid || !entry.Success || entry.RuleName != state.RuleName || !models.SameAction(entry.Action, state.ActionTaken) {
+			continue
+		}
+		if enforcement.LastAction == nil || entry.Timestamp.After(enforcement.LastAction.Timestamp) {
+			enforcement.LastAction = &entry
+		}
+	}
+	if enforcement.LastAction != nil {
+		enforcement.Reason = enforcement.LastAction.Reason
+	}
+	return enforcement, nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestHandleVMEnforcement(t *testing.T) {
+	store, err := storage.NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	limitedAt := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	recoveryAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	if err := storage.UpdateVMState(store, 101, map[string]interface{}{
+		"action_taken":   models.ActionRateLimit,
+		"action_time":    limitedAt,
+		"rule_name":      "monthly",
+		"needs_recovery": true,
+		"recovery_time":  recoveryAt,
+	}); err != nil {
+		t.Fatalf("UpdateVMState() error = %v", err)
+	}
+	storage.SaveExecutedAction(store, 101, models.ExecutedAction{Rule: "monthly", Action: models.ActionRateLimit, ExecutedAt: limitedAt, Count: 2})
+	store.SaveActionLog(models.ActionLog{VMID: 101, RuleName: "monthly", Action: models.ActionRateLimit, Reason: "old", Timestamp: limitedAt.Add(-time.Second), Success: true})
+	store.SaveActionLog(models.ActionLog{VMID: 101, RuleName: "monthly", Action: models.ActionRateLimit, Reason: "failed", Timestamp: limitedAt.Add(time.Hour), Success: false})
+	store.SaveActionLog(models.ActionLog{VMID: 102, RuleName: "monthly", Action: models.ActionRateLimit, Reason: "other vm", Timestamp: limitedAt.Add(time.Hour), Success: true})
+
+	s := &Server{
+		config:  &models.Config{Rules: []models.Rule{{Name: "monthly", Action: models.ActionRateLimit, RateLimitMB: 2, Interfaces: []string{"net0"}}}},
+		storage: store,
+	}
+
+	get := func(vmid string) models.Enforcement {
+		rec := httptest.NewRecorder()
+		s.handleVM(rec, httptest.NewRequest("GET", "/api/vm/"+vmid+"/enforcement", nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET enforcement for %s = %d: %s", vmid, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Data models.Enforcement `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return body.Data
+	}
+
+	got := get("101")
+	if !got.Enforced || got.State != models.EnforcementLimited || got.Rule != "monthly" || got.RateLimitMB != 2 ||
+		len(got.Interfaces) != 1 || got.Executions != 2 {
+		t.Fatalf("enforcement = %+v, want monthly rate limit of 2MB/s on net0, executed twice", got)
+	}
+	if got.Since == nil || !got.Since.Equal(limitedAt) || got.RecoveryAt == nil || !got.RecoveryAt.Equal(recoveryAt) {
+		t.Fatalf("since = %v, recovery_at = %v, want %v and %v", got.Since, got.RecoveryAt, limitedAt, recoveryAt)
+	}
+	if got.Reason != "old" || got.LastAction == nil || got.LastAction.VMID != 101 {
+		t.Fatalf("reason = %q, last_action = %+v, want the successful log of VM 101", got.Reason, got.LastAction)
+	}
+
+	if got := get("102"); got.Enforced || got.State != models.EnforcementNone || got.LastAction != nil {
+		t.Fatalf("enforcement without state = %+v, want none", got)
+	}
+}
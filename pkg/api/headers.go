@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	defaultCORSOrigins = []string{"*"}
+	defaultCORSMethods = []string{"GET", "POST", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-API-Token"}
+)
+
+// defaultContentSecurityPolicy 内置页面默认 CSP（页面使用内联脚本和 jsDelivr 上的 Chart.js）
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// originAllowed 检查请求来源是否在允许列表中（支持 * 和 https://*.example.com）
+func originAllowed(origin string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		if i := strings.Index(pattern, "://*."); i >= 0 {
+			prefix := strings.ToLower(pattern[:i+3]) // https://
+			suffix := strings.ToLower(pattern[i+4:]) // .example.com
+			lower := strings.ToLower(origin)
+			if strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) && len(lower) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// corsMiddleware CORS 中间件（来源、方法、请求头由 api.cors 配置）
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cors := s.config.API.CORS
+		origins := cors.AllowedOrigins
+		if origins == nil {
+			origins = defaultCORSOrigins
+		}
+		methods := cors.AllowedMethods
+		if len(methods) == 0 {
+			methods = defaultCORSMethods
+		}
+		headers := cors.AllowedHeaders
+		if len(headers) == 0 {
+			headers = defaultCORSHeaders
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin != "" && originAllowed(origin, origins) {
+			h := w.Header()
+			if len(origins) == 1 && origins[0] == "*" {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// 非通配时回显来源，缓存需按 Origin 区分
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			if cors.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if cors.MaxAgeSeconds > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAgeSeconds))
+			}
+		}
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// securityHeadersMiddleware 为所有响应添加通用安全响应头
+func (s *Server) securityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.config.API.SecurityHeaders
+		if !cfg.Disabled {
+			h := w.Header()
+			frameOptions := strings.ToUpper(cfg.FrameOptions)
+			if frameOptions == "" {
+				frameOptions = "DENY"
+			}
+			h.Set("X-Frame-Options", frameOptions)
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "same-origin")
+
+			// 只在 HTTPS（直连或反向代理）请求上发送 HSTS
+			if cfg.HSTSMaxAgeSeconds > 0 && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+				h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(cfg.HSTSMaxAgeSeconds))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setContentSecurityPolicy 为内置页面设置 CSP（构建后的前端由 web/dist 提供，不设置）
+func (s *Server) setContentSecurityPolicy(w http.ResponseWriter) {
+	cfg := s.config.API.SecurityHeaders
+	if cfg.Disabled {
+		return
+	}
+
+	policy := cfg.ContentSecurityPolicy
+	if policy == "" {
+		policy = defaultContentSecurityPolicy
+		// 允许 SAMEORIGIN 时同步放开 frame-ancestors
+		if strings.EqualFold(cfg.FrameOptions, "SAMEORIGIN") {
+			policy = strings.Replace(policy, "frame-ancestors 'none'", "frame-ancestors 'self'", 1)
+		}
+	}
+	w.Header().Set("Content-Security-Policy", policy)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.corp.example"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://grafana.corp.example", true},
+		{"https://corp.example", false},
+		{"http://grafana.corp.example", false},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, allowed); got != tt.want {
+			t.Fatalf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		cors       models.CORSConfig
+		origin     string
+		wantOrigin string
+	}{
+		{"default allows any", models.CORSConfig{}, "https://a.example", "*"},
+		{"listed origin echoed", models.CORSConfig{AllowedOrigins: []string{"https://a.example"}}, "https://a.example", "https://a.example"},
+		{"unlisted origin rejected", models.CORSConfig{AllowedOrigins: []string{"https://a.example"}}, "https://b.example", ""},
+		{"empty list disables", models.CORSConfig{AllowedOrigins: []string{}}, "https://a.example", ""},
+	}
+	for _, tt := range tests {
+		s := &Server{config: &models.Config{API: models.APIConfig{CORS: tt.cors}}}
+		req := httptest.NewRequest("OPTIONS", "/api/vms", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		s.corsMiddleware(next).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Fatalf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+	}
+}
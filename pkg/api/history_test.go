@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// recordsStorage 只返回固定流量记录和操作日志的存储
+type recordsStorage struct {
+	storage.Interface
+	records []models.TrafficRecord
+	logs    []models.ActionLog
+}
+
+func (s recordsStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	return s.records, nil
+}
+
+func (s recordsStorage) GetActionLogs(startTime, endTime time.Time) ([]models.ActionLog, error) {
+	return s.logs, nil
+}
+
+func (s recordsStorage) LoadVMState(vmid int) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (s recordsStorage) EarliestRecordTime(vmid int) (time.Time, error) {
+	return s.records[0].Timestamp.Add(-24 * time.Hour), nil
+}
+
+func TestHandleHistoryStep(t *testing.T) {
+	periodcalc.SetLocation(time.UTC)
+	defer periodcalc.SetLocation(nil)
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []models.TrafficRecord
+	for i := 0; i <= 120; i++ {
+		if i > 30 && i < 90 {
+			continue
+		}
+		records = append(records, models.TrafficRecord{VMID: 101, Timestamp: start.Add(time.Duration(i) * time.Minute), RXBytes: uint64(i) * 1000})
+	}
+	s := &Server{
+		config:  &models.Config{Monitor: models.MonitorConfig{IntervalSeconds: 60}},
+		storage: recordsStorage{records: records},
+		cache:   &Cache{data: make(map[string]*CacheEntry)},
+	}
+
+	get := func(query string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		s.handleHistory(rec, httptest.NewRequest("GET", "/api/history/101?start=2024-03-01T10:00:00Z&end=2024-03-01T12:00:00Z&"+query, nil))
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	code, body := get("step=5m&fill=null&max_points=10")
+	if code != http.StatusOK || body["step"] != "15m" {
+		t.Fatalf("code = %d, step = %v, want 15m after downsampling to 10 points", code, body["step"])
+	}
+	data := body["data"].([]interface{})
+	if len(data) != 9 {
+		t.Fatalf("points = %d, want 9 (10:00-12:00 in 15m buckets)", len(data))
+	}
+	if gap := data[3].(map[string]interface{}); gap["rx_bytes"] != nil || gap["timestamp"] != "2024-03-01 10:45" {
+		t.Fatalf("gap point = %v, want null traffic at 10:45", gap)
+	}
+
+	if _, body := get("step=auto"); body["step"] != "1m" {
+		t.Fatalf("auto step = %v, want 1m for a 2 hour range", body["step"])
+	}
+	for _, query := range []string{"step=7s", "step=5m&fill=none", "step=5m&max_points=0"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: code = %d, want 400", query, code)
+		}
+	}
+}
+
+func TestHandleHistoryDowntime(t *testing.T) {
+	periodcalc.SetLocation(time.UTC)
+	defer periodcalc.SetLocation(nil)
+
+	// 10:10-10:30 被规则关机（计数器清零），11:00-11:20 监控中断（计数器连续），11:40 之后没有采样
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []models.TrafficRecord
+	for i := 0; i <= 100; i++ {
+		if (i > 10 && i < 30) || (i > 60 && i < 80) {
+			continue
+		}
+		rx := uint64(i) * 1000
+		if i >= 30 {
+			rx = uint64(i-30) * 1000
+		}
+		records = append(records, models.TrafficRecord{VMID: 101, Timestamp: start.Add(time.Duration(i) * time.Minute), RXBytes: rx})
+	}
+	s := &Server{
+		config: &models.Config{Monitor: models.MonitorConfig{IntervalSeconds: 60}},
+		storage: recordsStorage{records: records, logs: []models.ActionLog{
+			{VMID: 102, Action: models.ActionShutdown, Success: true, Timestamp: start.Add(65 * time.Minute)},
+			{VMID: 101, RuleName: "monthly", Action: models.ActionShutdown, Success: true, Timestamp: start.Add(12 * time.Minute)},
+		}},
+		cache: &Cache{data: make(map[string]*CacheEntry)},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest("GET", "/api/history/101?start=2024-03-01T09:00:00Z&end=2024-03-01T12:00:00Z&granularity=minute&fill=null", nil))
+	var body struct {
+		Data     []map[string]interface{} `json:"data"`
+		Downtime []map[string]interface{} `json:"downtime"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("code = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if len(body.Data) != 181 || body.Data[0]["rx_bytes"] != nil || body.Data[75]["rx_bytes"] != nil || body.Data[65]["rx_bytes"] == nil {
+		t.Fatalf("data = %d points, want 181 minutes with nulls before 10:00 and during 11:01-11:19", len(body.Data))
+	}
+
+	want := []struct{ reason, from, to string }{
+		{storage.DowntimeNoSamples, "2024-03-01 09:00", "2024-03-01 10:00"},
+		{storage.DowntimeStopped, "2024-03-01 10:10", "2024-03-01 10:30"},
+		{storage.DowntimeNoSamples, "2024-03-01 11:00", "2024-03-01 11:20"},
+		{storage.DowntimeNoSamples, "2024-03-01 11:40", "2024-03-01 12:00"},
+	}
+	if len(body.Downtime) != len(want) {
+		t.Fatalf("downtime = %v, want %d entries", body.Downtime, len(want))
+	}
+	for i, w := range want {
+		d := body.Downtime[i]
+		if d["reason"] != w.reason || d["from"] != w.from || d["to"] != w.to {
+			t.Errorf("downtime[%d] = %v, want %s %s-%s", i, d, w.reason, w.from, w.to)
+		}
+	}
+	if body.Downtime[1]["rule_name"] != "monthly" || body.Downtime[3]["ongoing"] != true {
+		t.Errorf("downtime = %v, want rule name and ongoing flag", body.Downtime)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleHistory(rec, httptest.NewRequest("GET", "/api/history/101?start=2023-03-01T00:00:00Z&end=2024-03-01T12:00:00Z&granularity=minute&fill=zero", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("filling a year of minutes: code = %d, want 400", rec.Code)
+	}
+}
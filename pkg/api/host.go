@@ -0,0 +1,172 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// handleHostHistory 所有虚拟机按时间段叠加的主机流量趋势（用于堆叠面积图，仅限不限定客户的令牌）
+// GET /api/host/history?period=day&direction=tx&top=10
+// 时间范围和聚合方式与 /api/history 相同（period 或 start/end/granularity，step/max_points），空时间段总是填 0
+func (s *Server) handleHostHistory(w http.ResponseWriter, r *http.Request) {
+	if requestTenant(r) != "" {
+		s.sendError(w, s.tr(r, "api.node_forbidden"), http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	direction := query.Get("direction")
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+	switch direction {
+	case models.DirectionBoth, models.DirectionRX, models.DirectionTX, models.DirectionDownload, models.DirectionUpload:
+	default:
+		s.sendError(w, s.tr(r, "api.invalid_param", "direction", direction), http.StatusBadRequest)
+		return
+	}
+	metric := query.Get("metric")
+	if metric == "" {
+		metric = models.MetricNetwork
+	}
+	if !models.ValidMetric(metric) {
+		s.sendError(w, s.tr(r, "api.invalid_param", "metric", metric), http.StatusBadRequest)
+		return
+	}
+	top := models.DefaultHostHistoryTop
+	if v := query.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			s.sendError(w, s.tr(r, "api.invalid_param", "top", v), http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	bucket, useStep, ok := s.historyBucketQuery(w, r)
+	if !ok {
+		return
+	}
+	window, ok := s.parseHistoryWindow(w, r)
+	if !ok {
+		return
+	}
+
+	// 堆叠时每个虚拟机都需要完整的时间轴，空时间段填 0
+	bucket.Fill = storage.FillZero
+	cacheKey := fmt.Sprintf("host_history_%s_%s_%s_%d", window.Period, metric, direction, top)
+	if window.Custom {
+		cacheKey += fmt.Sprintf("_%s_%s", window.Start.Format("20060102150405"), window.End.Format("20060102150405"))
+	}
+	var layout string
+	if useStep {
+		bucket.Start, bucket.End = window.Start, window.End
+		bucket.Step = bucket.EffectiveStep()
+		layout = stepTimeFormat(bucket.Step)
+		cacheKey += fmt.Sprintf("_step%s_%d", storage.FormatBucketStep(bucket.Step), bucket.MaxPoints)
+	} else {
+		if storage.CountPeriodBuckets(window.Period, window.Start, window.End) > models.MaxHistoryPoints {
+			s.sendError(w, s.tr(r, "api.history_too_many_points", models.MaxHistoryPoints), http.StatusBadRequest)
+			return
+		}
+		layout = getTimeFormat(window.Period)
+	}
+
+	if entry, ok := s.getCacheEntry(cacheKey); ok {
+		if s.checkNotModified(w, r, cacheETag(cacheKey, entry.CreatedAt), entry.CreatedAt) {
+			return
+		}
+		s.sendJSON(w, hostHistoryResponse(entry.Data.(storage.HostTraffic), layout, window.Period, metric, direction, top, bucket, useStep, true))
+		return
+	}
+
+	vms, err := s.allVMs(false)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	series := make([]storage.StackedSeries, 0, len(vms))
+	for _, vm := range vms {
+		records, err := s.storage.GetTrafficRecords(vm.VMID, window.Start, window.End)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+			return
+		}
+		if len(records) == 0 {
+			continue
+		}
+		records = storage.MetricRecords(records, metric)
+
+		var points []storage.AggregatedPoint
+		if useStep {
+			points, _ = storage.AggregateTrafficByStep(records, bucket)
+		} else {
+			points = storage.FillPeriodGaps(storage.AggregateTrafficByPeriod(records, window.Period), window.Period, window.Start, window.End)
+		}
+		series = append(series, storage.StackedSeries{VMID: vm.VMID, Name: vm.Name, Points: points})
+	}
+	traffic := storage.StackTraffic(series, direction, top)
+
+	var until time.Time
+	if window.Custom {
+		until = window.End
+	}
+	entry := s.setScopedCache(cacheKey, traffic, window.CacheTTL, 0, until)
+	if s.checkNotModified(w, r, cacheETag(cacheKey, entry.CreatedAt), entry.CreatedAt) {
+		return
+	}
+
+	s.sendJSON(w, hostHistoryResponse(traffic, layout, window.Period, metric, direction, top, bucket, useStep, false))
+}
+
+// hostHistoryResponse 主机流量趋势响应：timestamps 为横轴，每个序列的 data 与其一一对应（按方向的字节数）
+func hostHistoryResponse(traffic storage.HostTraffic, layout, period, metric, direction string, top int, bucket storage.BucketQuery, useStep, cached bool) map[string]interface{} {
+	timestamps := make([]string, len(traffic.Timestamps))
+	for i, t := range traffic.Timestamps {
+		timestamps[i] = t.Format(layout)
+	}
+
+	series := make([]map[string]interface{}, len(traffic.Series))
+	for i, s := range traffic.Series {
+		data := make([]uint64, len(s.Points))
+		for j, point := range s.Points {
+			data[j] = storage.PointBytes(point, direction)
+		}
+		series[i] = map[string]interface{}{
+			"vmid":        s.VMID,
+			"name":        s.Name,
+			"data":        data,
+			"rx_bytes":    s.RXBytes,
+			"tx_bytes":    s.TXBytes,
+			"total_bytes": s.TotalBytes,
+		}
+	}
+
+	total := make([]uint64, len(traffic.Total))
+	for i, point := range traffic.Total {
+		total[i] = storage.PointBytes(point, direction)
+	}
+
+	resp := map[string]interface{}{
+		"success":    true,
+		"timestamps": timestamps,
+		"series":     series,
+		"total":      total,
+		"period":     period,
+		"metric":     metric,
+		"direction":  direction,
+		"top":        top,
+		"cached":     cached,
+	}
+	if useStep {
+		resp["step"] = storage.FormatBucketStep(bucket.Step)
+		resp["max_points"] = bucket.MaxPoints
+	}
+	return resp
+}
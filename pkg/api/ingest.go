@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// SetIngestHook 设置写入外部记录后的回调（参数为写入了新记录的虚拟机，用于执行规则）
+func (s *Server) SetIngestHook(hook func(vmids []int)) {
+	s.ingestHook = hook
+}
+
+// handleIngest 写入外部来源（远程写入、脚本、其他虚拟化平台）的流量记录（仅限管理员令牌）
+// POST /api/ingest
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.sendError(w, s.tr(r, "api.method_not_allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	// 未配置管理员令牌时 API 不认证，不接受写入
+	if s.config.API.Token == "" {
+		s.sendError(w, s.tr(r, "api.ingest_disabled"), http.StatusForbidden)
+		return
+	}
+	if requestTenant(r) != "" {
+		s.sendError(w, s.tr(r, "api.ingest_forbidden"), http.StatusForbidden)
+		return
+	}
+
+	var batch models.IngestBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		s.sendError(w, s.tr(r, "api.invalid_body", err), http.StatusBadRequest)
+		return
+	}
+	if err := batch.Validate(time.Now()); err != nil {
+		s.sendError(w, s.tr(r, "api.ingest_invalid", err), http.StatusBadRequest)
+		return
+	}
+	for i := range batch.Records {
+		if batch.Records[i].TotalBytes == 0 {
+			batch.Records[i].TotalBytes = batch.Records[i].RXBytes + batch.Records[i].TXBytes
+		}
+	}
+
+	records, duplicates, err := storage.FilterDuplicates(s.storage, batch.Records)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.ingest_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 通过统计服务保存，使相应虚拟机的统计和响应缓存失效；保存失败时返回 500，来源可以整批重发（已保存的记录会被去重）
+	if err := s.stats.SaveTrafficRecords(records); err != nil {
+		s.sendError(w, s.tr(r, "api.ingest_failed", err), http.StatusInternalServerError)
+		return
+	}
+	vmids := make(map[int]bool)
+	for _, record := range records {
+		vmids[record.VMID] = true
+	}
+	if len(records) > 0 {
+		log.Printf("写入来自 %s 的 %d 条流量记录（重复 %d 条）", sourceName(batch.Source), len(records), duplicates)
+	}
+
+	if s.ingestHook != nil && len(vmids) > 0 {
+		list := make([]int, 0, len(vmids))
+		for vmid := range vmids {
+			list = append(list, vmid)
+		}
+		sort.Ints(list)
+		s.ingestHook(list)
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success":    true,
+		"accepted":   len(records),
+		"duplicates": duplicates,
+	})
+}
+
+// sourceName 日志中的来源名称
+func sourceName(source string) string {
+	if source == "" {
+		return "unknown"
+	}
+	return source
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"pve-traffic-monitor/pkg/i18n"
+)
+
+// serverLocale 配置的默认语言
+func (s *Server) serverLocale() string {
+	if locale := i18n.Normalize(s.config.Locale); locale != "" {
+		return locale
+	}
+	return i18n.DefaultLocale
+}
+
+// requestLocale 请求使用的语言：?lang 参数 > Accept-Language > 配置的默认语言
+func (s *Server) requestLocale(r *http.Request) string {
+	if locale := i18n.Normalize(r.URL.Query().Get("lang")); locale != "" {
+		return locale
+	}
+	return i18n.Negotiate(r.Header.Get("Accept-Language"), s.serverLocale())
+}
+
+// tr 按请求语言翻译消息
+func (s *Server) tr(r *http.Request, key string, args ...interface{}) string {
+	return i18n.Tl(s.requestLocale(r), key, args...)
+}
+
+// localizeError 按请求语言生成错误信息
+func (s *Server) localizeError(r *http.Request, err error) string {
+	return i18n.LocalizeError(err, s.requestLocale(r))
+}
+
+// renderIndexI18n 将界面文案注入内置页面（前端可在支持的语言间切换）
+func (s *Server) renderIndexI18n(html string, r *http.Request) string {
+	messages := make(map[string]map[string]string)
+	for _, locale := range i18n.Supported() {
+		messages[locale] = i18n.Messages(locale, "ui.")
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"default":  s.requestLocale(r),
+		"messages": messages,
+	})
+	if err != nil {
+		config = []byte("null")
+	}
+	return strings.Replace(html, "/*__I18N_CONFIG__*/null", string(config), 1)
+}
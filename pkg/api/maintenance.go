@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// SetMaintenanceHandler 设置修改维护模式的处理函数（由主程序保存状态并立即生效）
+func (s *Server) SetMaintenanceHandler(handler func(enabled bool, reason string) (models.Maintenance, error)) {
+	s.maintenanceHandler = handler
+}
+
+// maintenanceRequest 修改维护模式的请求
+type maintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// handleMaintenance 查看或修改维护模式（仅限不限定客户的令牌，修改需要配置 api.token）
+// GET /api/maintenance
+// POST /api/maintenance {"enabled": true, "reason": "..."}
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if requestTenant(r) != "" {
+		s.sendError(w, s.tr(r, "api.maintenance_forbidden"), http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// 直接读取存储：备用实例也能看到主实例设置的状态
+		state, err := storage.LoadMaintenance(s.storage)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.maintenance_failed", err), http.StatusInternalServerError)
+			return
+		}
+		s.sendJSON(w, map[string]interface{}{"success": true, "data": state})
+
+	case http.MethodPost:
+		// 未配置管理员令牌时 API 不认证，不允许暂停执行规则
+		if s.config.API.Token == "" || s.maintenanceHandler == nil {
+			s.sendError(w, s.tr(r, "api.maintenance_disabled"), http.StatusForbidden)
+			return
+		}
+		var req maintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			s.sendError(w, s.tr(r, "api.maintenance_invalid"), http.StatusBadRequest)
+			return
+		}
+		state, err := s.maintenanceHandler(*req.Enabled, req.Reason)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.maintenance_failed", err), http.StatusInternalServerError)
+			return
+		}
+		s.sendJSON(w, map[string]interface{}{"success": true, "data": state})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		s.sendError(w, s.tr(r, "api.method_not_allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// handleMetrics 以 Prometheus 文本格式输出存储操作的耗时（仅限不限定客户的令牌）
+// GET /metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, s.tr(r, "api.method_not_allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	if requestTenant(r) != "" {
+		s.sendError(w, s.tr(r, "api.metrics_forbidden"), http.StatusForbidden)
+		return
+	}
+
+	var buf bytes.Buffer
+	if reporter, ok := storage.As[storage.QueryReporter](s.storage); ok {
+		writeQueryMetrics(&buf, reporter.QueryStats())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// writeQueryMetrics 写入存储操作的耗时直方图、错误数和慢查询数
+func writeQueryMetrics(buf *bytes.Buffer, stats storage.QueryStats) {
+	fmt.Fprintln(buf, "# HELP pve_tm_storage_query_duration_seconds Duration of storage operations.")
+	fmt.Fprintln(buf, "# TYPE pve_tm_storage_query_duration_seconds histogram")
+	for _, m := range stats.Methods {
+		for i, count := range m.Buckets {
+			le := "+Inf"
+			if i < len(storage.QueryBuckets) {
+				le = strconv.FormatFloat(storage.QueryBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(buf, "pve_tm_storage_query_duration_seconds_bucket{method=%q,le=%q} %d\n", m.Method, le, count)
+		}
+		fmt.Fprintf(buf, "pve_tm_storage_query_duration_seconds_sum{method=%q} %g\n", m.Method, m.SumSeconds)
+		fmt.Fprintf(buf, "pve_tm_storage_query_duration_seconds_count{method=%q} %d\n", m.Method, m.Count)
+	}
+
+	fmt.Fprintln(buf, "# HELP pve_tm_storage_query_errors_total Storage operations that returned an error.")
+	fmt.Fprintln(buf, "# TYPE pve_tm_storage_query_errors_total counter")
+	for _, m := range stats.Methods {
+		fmt.Fprintf(buf, "pve_tm_storage_query_errors_total{method=%q} %d\n", m.Method, m.Errors)
+	}
+
+	fmt.Fprintln(buf, "# HELP pve_tm_storage_slow_queries_total Storage operations slower than storage.slow_query_ms.")
+	fmt.Fprintln(buf, "# TYPE pve_tm_storage_slow_queries_total counter")
+	for _, m := range stats.Methods {
+		fmt.Fprintf(buf, "pve_tm_storage_slow_queries_total{method=%q} %d\n", m.Method, m.Slow)
+	}
+
+	fmt.Fprintln(buf, "# HELP pve_tm_storage_slow_query_threshold_seconds Slow query log threshold (0 when disabled).")
+	fmt.Fprintln(buf, "# TYPE pve_tm_storage_slow_query_threshold_seconds gauge")
+	fmt.Fprintf(buf, "pve_tm_storage_slow_query_threshold_seconds %g\n", float64(stats.SlowThresholdMillis)/1000)
+}
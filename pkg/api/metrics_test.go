@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// countStorage 只实现 GetTotalRecordCount 的存储
+type countStorage struct {
+	storage.Interface
+}
+
+func (countStorage) GetTotalRecordCount() (int64, error) { return 42, nil }
+
+func TestHandleMetrics(t *testing.T) {
+	store := storage.NewInstrumentedStorage(countStorage{}, time.Second)
+	store.GetTotalRecordCount()
+	s := &Server{
+		config:  &models.Config{API: models.APIConfig{Token: "admin", Keys: []models.APIKey{{Token: "acme-key", Tenant: "acme"}}}},
+		storage: store,
+	}
+	handler := s.authMiddleware(s.handleMetrics)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer acme-key")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("tenant key: code = %d, want 403", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`pve_tm_storage_query_duration_seconds_bucket{method="GetTotalRecordCount",le="+Inf"} 1`,
+		`pve_tm_storage_query_duration_seconds_count{method="GetTotalRecordCount"} 1`,
+		`pve_tm_storage_slow_query_threshold_seconds 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/network"
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// networkMembershipTTL 虚拟机与网桥对应关系的缓存时间（需要读取每台虚拟机的配置）
+const networkMembershipTTL = 5 * time.Minute
+
+// networkCache 网络成员关系缓存
+type networkCache struct {
+	mu         sync.Mutex
+	membership *network.Membership
+	fetchedAt  time.Time
+}
+
+// networkMembership 获取虚拟机与网桥、SDN VNet 的对应关系（缓存 5 分钟）
+func (s *Server) networkMembership() (*network.Membership, error) {
+	s.networks.mu.Lock()
+	defer s.networks.mu.Unlock()
+
+	if s.networks.membership != nil && time.Since(s.networks.fetchedAt) < networkMembershipTTL {
+		return s.networks.membership, nil
+	}
+
+	vms, err := s.pveClient.GetAllVMsWithFilter(false)
+	if err != nil {
+		return nil, err
+	}
+
+	bridges := make(map[int][]string, len(vms))
+	for _, vm := range vms {
+		config, err := s.pveClient.GetVMConfig(vm.VMID)
+		if err != nil {
+			log.Printf("VM%d 获取网卡配置失败，不计入网络汇总: %v", vm.VMID, err)
+			continue
+		}
+		bridges[vm.VMID] = pve.VMBridges(config)
+	}
+
+	// 未启用 SDN 时所有网络按普通网桥处理
+	vnets, err := s.pveClient.GetSDNVNets()
+	if err != nil {
+		vnets = nil
+	}
+
+	s.networks.membership = network.NewMembership(bridges, vnets)
+	s.networks.fetchedAt = time.Now()
+	return s.networks.membership, nil
+}
+
+// scopedMembership 获取请求可访问的网络成员关系（客户密钥只汇总自己的虚拟机）
+func (s *Server) scopedMembership(w http.ResponseWriter, r *http.Request) (*network.Membership, bool) {
+	membership, err := s.networkMembership()
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	allowed, err := s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return membership.Scope(allowed), true
+}
+
+// handleNetworks 按网桥和 SDN VNet 汇总流量
+// GET /api/networks 汇总所有网络，GET /api/networks/{name}/history 获取单个网络的历史数据
+func (s *Server) handleNetworks(w http.ResponseWriter, r *http.Request) {
+	if name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/networks/"), "/history"); ok && name != "" {
+		s.handleNetworkHistory(w, r, name)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodMonth
+	}
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+
+	switch period {
+	case models.PeriodMinute, models.PeriodHour, models.PeriodDay, models.PeriodMonth:
+	default:
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := s.scopedMembership(w, r)
+	if !ok {
+		return
+	}
+
+	names := membership.Names()
+	result := make([]models.NetworkStats, 0, len(names))
+	for _, name := range names {
+		var stats []models.TrafficStats
+		for _, vmid := range membership.Members[name] {
+			stat, err := s.stats.Calculate(vmid, period, time.Time{}, false, direction)
+			if err != nil {
+				continue
+			}
+			stats = append(stats, *stat)
+		}
+		result = append(result, membership.Aggregate(name, stats))
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success":   true,
+		"data":      result,
+		"period":    period,
+		"direction": direction,
+	})
+}
+
+// handleNetworkHistory 获取网络的历史流量（成员虚拟机每个时间段的增量之和）
+func (s *Server) handleNetworkHistory(w http.ResponseWriter, r *http.Request, name string) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodDay
+	}
+	now := time.Now()
+	startTime, _, ok := historyRange(period, now)
+	if !ok {
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := s.scopedMembership(w, r)
+	if !ok {
+		return
+	}
+	vmids, exists := membership.Members[name]
+	if !exists {
+		s.sendError(w, s.tr(r, "api.network_not_found", name), http.StatusNotFound)
+		return
+	}
+
+	series := make([][]storage.AggregatedPoint, 0, len(vmids))
+	for _, vmid := range vmids {
+		records, err := s.storage.GetTrafficRecords(vmid, startTime, now)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+			return
+		}
+		series = append(series, storage.AggregateTrafficByPeriod(records, period))
+	}
+
+	networkType, zone := membership.Type(name)
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    formatHistory(network.MergeHistory(series...), period),
+		"period":  period,
+		"name":    name,
+		"type":    networkType,
+		"zone":    zone,
+		"vmids":   vmids,
+	})
+}
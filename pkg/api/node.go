@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// handleNodeStats 节点物理网卡流量与虚拟机流量总和的对比（仅限不限定客户的令牌）
+// GET /api/node/stats?period=day&direction=both
+func (s *Server) handleNodeStats(w http.ResponseWriter, r *http.Request) {
+	if requestTenant(r) != "" {
+		s.sendError(w, s.tr(r, "api.node_forbidden"), http.StatusForbidden)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodDay
+	}
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+
+	now := time.Now().In(periodcalc.Location())
+	historyStart, _, ok := historyRange(period, now)
+	if !ok {
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+	startTime := periodcalc.NewCalculator(period, time.Time{}, false).PeriodStartAt(now)
+
+	node := s.config.PVE.Node
+	records, err := storage.GetNodeTrafficRecords(s.storage, node, historyStart, now)
+	if err != nil && !errors.Is(err, storage.ErrNodeRecordsUnsupported) {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	var current []models.TrafficRecord
+	for _, record := range records {
+		if !record.Timestamp.Before(startTime) {
+			current = append(current, record)
+		}
+	}
+	nodeStats := storage.NodeTrafficStats(current, period, startTime, now, direction)
+
+	result := models.NodeStats{
+		Node:       node,
+		Period:     period,
+		StartTime:  startTime,
+		EndTime:    now,
+		Direction:  nodeStats.Direction,
+		RXBytes:    nodeStats.RXBytes,
+		TXBytes:    nodeStats.TXBytes,
+		TotalBytes: nodeStats.TotalBytes,
+		TotalGB:    nodeStats.TotalGB,
+	}
+
+	vms, err := s.pveClient.GetAllVMsWithFilter(false)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+	for _, vm := range vms {
+		stat, err := s.stats.Calculate(vm.VMID, period, time.Time{}, false, direction)
+		if err != nil {
+			continue
+		}
+		result.VMCount++
+		result.VMRXBytes += stat.RXBytes
+		result.VMTXBytes += stat.TXBytes
+		result.VMTotalBytes += stat.TotalBytes
+	}
+	result.VMTotalGB = float64(result.VMTotalBytes) / models.BytesPerGB
+
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    result,
+		"history": formatHistory(storage.AggregateTrafficByPeriod(records, period), period),
+	})
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// handleVMPower 虚拟机在时间范围内的运行状态变化（running ↔ stopped 等，每个采集周期检测）
+// GET /api/vm/{vmid}/power?period=day 或 ?start=...&end=...（与 /api/history 相同）
+func (s *Server) handleVMPower(w http.ResponseWriter, r *http.Request, vmid int) {
+	window, ok := s.parseHistoryWindow(w, r)
+	if !ok {
+		return
+	}
+	powerEvents, err := storage.LoadPowerEvents(s.storage, vmid, window.Start, window.End)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+	s.sendJSON(w, map[string]interface{}{
+		"success":    true,
+		"data":       powerEvents,
+		"start_time": window.Start,
+		"end_time":   window.End,
+	})
+}
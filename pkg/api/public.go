@@ -0,0 +1,454 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// 公开链接签名长度（HMAC-SHA256 截断，字节）
+const publicSignatureBytes = 16
+
+var errInvalidPublicToken = errors.New("公开链接无效或已过期")
+
+// PublicQuota 公开状态页中的单条规则用量
+type PublicQuota struct {
+	Rule              string      `json:"rule"`
+	Type              string      `json:"type"`
+	Period            string      `json:"period"`
+	LimitGB           float64     `json:"limit_gb,omitempty"`
+	RateThresholdMbps float64     `json:"rate_threshold_mbps,omitempty"`
+	Usage             RuleVMUsage `json:"usage"`
+}
+
+// signPublicPayload 计算公开链接签名
+func signPublicPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:publicSignatureBytes])
+}
+
+// PublicToken 生成虚拟机公开状态页令牌，格式: {vmid}.{过期时间戳}.{签名}
+// expires 为零值表示永不过期
+func PublicToken(secret string, vmid int, expires time.Time) string {
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.Unix()
+	}
+	payload := fmt.Sprintf("%d.%d", vmid, exp)
+	return payload + "." + signPublicPayload(secret, payload)
+}
+
+// verifyPublicToken 校验公开状态页令牌，返回虚拟机 ID 和过期时间
+func verifyPublicToken(secret, token string, now time.Time) (int, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if secret == "" || len(parts) != 3 {
+		return 0, time.Time{}, errInvalidPublicToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signPublicPayload(secret, payload))) {
+		return 0, time.Time{}, errInvalidPublicToken
+	}
+
+	vmid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, time.Time{}, errInvalidPublicToken
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errInvalidPublicToken
+	}
+
+	var expires time.Time
+	if exp > 0 {
+		expires = time.Unix(exp, 0)
+		if now.After(expires) {
+			return 0, time.Time{}, errInvalidPublicToken
+		}
+	}
+	return vmid, expires, nil
+}
+
+// PublicLink 生成虚拟机公开状态页链接（ttl 为 0 表示永不过期）
+// 未配置 public_url 时使用 API 监听地址
+func PublicLink(cfg models.APIConfig, vmid int, ttl time.Duration) (string, time.Time, error) {
+	host := cfg.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return publicLink(cfg, fmt.Sprintf("http://%s:%d", host, cfg.Port), vmid, ttl)
+}
+
+func publicLink(cfg models.APIConfig, fallbackBase string, vmid int, ttl time.Duration) (string, time.Time, error) {
+	if cfg.PublicSecret == "" {
+		return "", time.Time{}, i18n.Errorf("api.public_disabled")
+	}
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).Truncate(time.Second)
+	}
+
+	base := cfg.PublicURL
+	if base == "" {
+		base = fallbackBase
+	}
+	return strings.TrimRight(base, "/") + "/public/vm/" + PublicToken(cfg.PublicSecret, vmid, expires), expires, nil
+}
+
+// handlePublicLink 生成虚拟机公开状态页链接（?vmid=100&ttl=720h，ttl=0 表示永不过期）
+func (s *Server) handlePublicLink(w http.ResponseWriter, r *http.Request) {
+	vmid, err := strconv.Atoi(r.URL.Query().Get("vmid"))
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.invalid_vmid"), http.StatusBadRequest)
+		return
+	}
+
+	ttl := models.DefaultPublicLinkTTL
+	if ttlStr := r.URL.Query().Get("ttl"); ttlStr != "" {
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil || ttl < 0 {
+			s.sendError(w, s.tr(r, "api.invalid_param", "ttl", ttlStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !s.checkVMAccess(w, r, vmid) {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	link, expires, err := publicLink(s.config.API, scheme+"://"+r.Host, vmid, ttl)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+
+	data := map[string]interface{}{
+		"vmid": vmid,
+		"url":  link,
+	}
+	if !expires.IsZero() {
+		data["expires_at"] = expires
+	}
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// publicVMID 从路径中解析并校验公开令牌，无效时已写入 404 响应
+func (s *Server) publicVMID(w http.ResponseWriter, r *http.Request, prefix string) (int, time.Time, bool) {
+	vmid, expires, err := verifyPublicToken(s.config.API.PublicSecret, strings.TrimPrefix(r.URL.Path, prefix), time.Now())
+	if err != nil {
+		// 不区分“未启用”、“签名错误”和“已过期”
+		s.sendError(w, s.tr(r, "api.public_link_invalid"), http.StatusNotFound)
+		return 0, time.Time{}, false
+	}
+	return vmid, expires, true
+}
+
+// handlePublicVMData 公开状态页数据：虚拟机基本信息、规则用量和历史流量
+func (s *Server) handlePublicVMData(w http.ResponseWriter, r *http.Request) {
+	vmid, expires, ok := s.publicVMID(w, r, "/public/api/vm/")
+	if !ok {
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodDay
+	}
+	now := time.Now()
+	startTime, _, ok := historyRange(period, now)
+	if !ok {
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+
+	vms, err := s.allVMs(true)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+	var vm *models.VMInfo
+	for i := range vms {
+		if vms[i].VMID == vmid {
+			vm = &vms[i]
+			break
+		}
+	}
+	if vm == nil {
+		s.sendError(w, s.tr(r, "api.vm_not_found", vmid), http.StatusNotFound)
+		return
+	}
+
+	getCreationTime := func(id int) time.Time {
+		ct, _ := s.creation.Time(id)
+		return ct
+	}
+	quotas := []PublicQuota{}
+	for _, rule := range s.config.Rules {
+		if !rule.Enabled || !pve.VMMatchesRule(*vm, rule) {
+			continue
+		}
+		ruleType := models.RuleTypeVolume
+		if rule.IsRateRule() {
+			ruleType = models.RuleTypeRate
+		} else if rule.IsPercentileRule() {
+			ruleType = models.RuleTypePercentile
+		}
+		quotas = append(quotas, PublicQuota{
+			Rule:              rule.Name,
+			Type:              ruleType,
+			Period:            rule.Period,
+			LimitGB:           rule.LimitGB,
+			RateThresholdMbps: rule.RateThresholdMbps,
+			Usage:             s.calculateRuleVMUsage(*vm, rule, getCreationTime),
+		})
+	}
+
+	records, err := s.storage.GetTrafficRecords(vmid, startTime, now)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"vmid":    vm.VMID,
+		"name":    vm.Name,
+		"status":  vm.Status,
+		"period":  period,
+		"quotas":  quotas,
+		"history": historyPoints(records, period),
+	}
+	if !expires.IsZero() {
+		data["expires_at"] = expires
+	}
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// handlePublicVM 只读的虚拟机公开状态页（不需要 Token，仅展示该虚拟机）
+func (s *Server) handlePublicVM(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := verifyPublicToken(s.config.API.PublicSecret, strings.TrimPrefix(r.URL.Path, "/public/vm/"), time.Now()); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	html := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Traffic Status</title>
+    <script src="https://cdn.jsdelivr.net/npm/chart.js@4.4.1/dist/chart.umd.min.js"></script>
+    <style>
+        /*__THEME_STYLE__*/
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: var(--page-bg); color: var(--text); }
+        .container { max-width: 1000px; margin: 0 auto; padding: 20px; }
+        header { background: var(--header-bg); color: white; padding: 20px; border-radius: 8px; margin-bottom: 20px; display: flex; justify-content: space-between; align-items: center; }
+        h1 { font-size: 22px; }
+        .meta { font-size: 13px; opacity: 0.85; }
+        .card { background: var(--card-bg); border-radius: 8px; padding: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); margin-bottom: 20px; }
+        .card h2 { font-size: 16px; margin-bottom: 12px; }
+        .quota { margin-bottom: 14px; }
+        .quota-header { display: flex; justify-content: space-between; font-size: 14px; margin-bottom: 6px; }
+        .quota-header span { color: var(--muted); }
+        .usage-bar { height: 18px; background: var(--grid-line); border-radius: 9px; overflow: hidden; }
+        .usage-bar .fill { height: 100%; background: var(--success); }
+        .usage-bar .fill.warning { background: var(--warning); }
+        .usage-bar .fill.danger { background: var(--danger); }
+        .controls { display: flex; gap: 10px; margin-bottom: 12px; align-items: center; }
+        .controls select { padding: 6px 12px; border: 1px solid var(--border); border-radius: 4px; background: var(--card-bg); color: var(--text); }
+        .chart-container { position: relative; height: 360px; }
+        .status { display: inline-block; padding: 4px 12px; border-radius: 12px; font-size: 12px; background: rgba(255,255,255,0.2); }
+        .muted { color: var(--muted); }
+        .error { color: var(--danger); }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <header>
+            <div>
+                <h1 id="vm-title">Traffic Status</h1>
+                <div class="meta" id="vm-expires"></div>
+            </div>
+            <span class="status" id="vm-status"></span>
+        </header>
+        <div class="card">
+            <h2 data-i18n="public.quotas">Quota Usage</h2>
+            <div id="quotas" class="muted" data-i18n="common.loading">Loading...</div>
+        </div>
+        <div class="card">
+            <div class="controls">
+                <h2 style="flex: 1; margin: 0;" data-i18n="charts.usage_pattern">Traffic Usage Pattern</h2>
+                <label data-i18n="label.period">Period:</label>
+                <select id="period" onchange="loadStatus()">
+                    <option value="hour" data-i18n="period.hour">Hour</option>
+                    <option value="day" selected data-i18n="period.day">Day</option>
+                    <option value="month" data-i18n="period.month">Month</option>
+                </select>
+            </div>
+            <div class="chart-container"><canvas id="history-chart"></canvas></div>
+        </div>
+    </div>
+    <script>
+        const I18N_CONFIG = /*__I18N_CONFIG__*/null;
+        const THEME_CONFIG = /*__THEME_CONFIG__*/null;
+        const token = location.pathname.split('/').pop();
+        let historyChart = null;
+
+        function currentLocale() {
+            const saved = localStorage.getItem('locale');
+            if (I18N_CONFIG && I18N_CONFIG.messages[saved]) {
+                return saved;
+            }
+            return I18N_CONFIG ? I18N_CONFIG.default : 'en-US';
+        }
+
+        function t(key, ...args) {
+            const messages = I18N_CONFIG ? I18N_CONFIG.messages[currentLocale()] : null;
+            let text = (messages && messages[key]) || key;
+            args.forEach(arg => { text = text.replace(/%[sd]/, arg); });
+            return text;
+        }
+
+        document.documentElement.lang = currentLocale();
+        document.querySelectorAll('[data-i18n]').forEach(el => { el.textContent = t(el.dataset.i18n); });
+
+        // 主题跟随管理页面的选择，未选择时使用服务端默认值
+        const saved = localStorage.getItem('theme');
+        let theme = (saved === 'light' || saved === 'dark') ? saved : (THEME_CONFIG && THEME_CONFIG.default) || 'auto';
+        if (theme === 'auto') {
+            theme = window.matchMedia('(prefers-color-scheme: dark)').matches ? 'dark' : 'light';
+        }
+        document.documentElement.dataset.theme = theme;
+        const palette = THEME_CONFIG ? THEME_CONFIG[theme] : null;
+        if (palette && window.Chart) {
+            Chart.defaults.color = palette.chart.text_color;
+            Chart.defaults.borderColor = palette.chart.split_line;
+        }
+
+        function formatBytes(bytes) {
+            if (bytes === 0) return '0 B';
+            const k = 1024;
+            const sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+            const i = Math.floor(Math.log(bytes) / Math.log(k));
+            return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
+        }
+
+        function hexToRgba(hex, alpha) {
+            const value = parseInt(hex.slice(1), 16);
+            return 'rgba(' + ((value >> 16) & 255) + ', ' + ((value >> 8) & 255) + ', ' + (value & 255) + ', ' + alpha + ')';
+        }
+
+        function escapeHtml(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML;
+        }
+
+        function renderQuotas(quotas) {
+            const el = document.getElementById('quotas');
+            if (!quotas || quotas.length === 0) {
+                el.className = 'muted';
+                el.textContent = t('public.no_quotas');
+                return;
+            }
+            el.className = '';
+            el.innerHTML = quotas.map(q => {
+                const usage = q.usage;
+                const percent = Math.min(usage.percent || 0, 100);
+                const level = usage.percent >= 100 ? 'danger' : (usage.percent >= 80 ? 'warning' : '');
+                const detail = q.type === 'rate'
+                    ? (usage.rate_mbps || 0).toFixed(2) + ' / ' + q.rate_threshold_mbps + ' Mbps'
+                    : formatBytes(usage.used_bytes || 0) + ' / ' + formatBytes(usage.limit_bytes || 0) + ' (' + t('period.' + q.period) + ')';
+                return '<div class="quota">' +
+                    '<div class="quota-header"><strong>' + escapeHtml(q.rule) + '</strong><span>' + detail + ' · ' + (usage.percent || 0).toFixed(1) + '%</span></div>' +
+                    '<div class="usage-bar"><div class="fill ' + level + '" style="width: ' + percent + '%"></div></div>' +
+                '</div>';
+            }).join('');
+        }
+
+        function renderHistory(points) {
+            const ctx = document.getElementById('history-chart').getContext('2d');
+            if (historyChart) historyChart.destroy();
+
+            const colors = palette ? palette.chart : { download: '#36a2eb', upload: '#4bc0c0', total: '#ff6384' };
+            const divisor = 1024 * 1024 * 1024;
+            historyChart = new Chart(ctx, {
+                type: 'line',
+                data: {
+                    labels: points.map(p => p.timestamp),
+                    datasets: [
+                        { label: t('charts.download_gb'), data: points.map(p => p.rx_bytes / divisor), borderColor: colors.download, backgroundColor: hexToRgba(colors.download, 0.1), tension: 0.2, fill: true, pointRadius: 2 },
+                        { label: t('charts.upload_gb'), data: points.map(p => p.tx_bytes / divisor), borderColor: colors.upload, backgroundColor: hexToRgba(colors.upload, 0.1), tension: 0.2, fill: true, pointRadius: 2 },
+                        { label: t('charts.total_gb'), data: points.map(p => p.total_bytes / divisor), borderColor: colors.total, backgroundColor: hexToRgba(colors.total, 0.1), tension: 0.2, fill: false, borderWidth: 2, pointRadius: 3 }
+                    ]
+                },
+                options: {
+                    responsive: true,
+                    maintainAspectRatio: false,
+                    interaction: { mode: 'index', intersect: false },
+                    plugins: {
+                        tooltip: { callbacks: { label: c => c.dataset.label + ': ' + formatBytes(c.parsed.y * divisor) } }
+                    },
+                    scales: { y: { beginAtZero: true, title: { display: true, text: t('charts.usage_gb') } } }
+                }
+            });
+        }
+
+        async function loadStatus() {
+            const period = document.getElementById('period').value;
+            try {
+                const response = await fetch('/public/api/vm/' + token + '?period=' + period);
+                const result = await response.json();
+                if (!result.success) {
+                    document.getElementById('quotas').innerHTML = '<span class="error">' + escapeHtml(result.error) + '</span>';
+                    return;
+                }
+
+                const data = result.data;
+                document.title = 'VM' + data.vmid + ' - ' + data.name;
+                document.getElementById('vm-title').textContent = 'VM' + data.vmid + ' - ' + data.name;
+                document.getElementById('vm-status').textContent = t('status.' + data.status);
+                document.getElementById('vm-expires').textContent = data.expires_at
+                    ? t('public.expires', new Date(data.expires_at).toLocaleString(currentLocale()))
+                    : '';
+                renderQuotas(data.quotas);
+                renderHistory(data.history);
+            } catch (error) {
+                document.getElementById('quotas').innerHTML = '<span class="error">' + t('common.load_failed') + '</span>';
+            }
+        }
+
+        loadStatus();
+        setInterval(loadStatus, 60000);
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	s.setContentSecurityPolicy(w)
+	w.Write([]byte(s.renderIndexI18n(s.renderIndexTheme(html), r)))
+}
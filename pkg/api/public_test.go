@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicToken(t *testing.T) {
+	const secret = "0123456789abcdef"
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := PublicToken(secret, 100, now.Add(time.Hour))
+
+	vmid, expires, err := verifyPublicToken(secret, token, now)
+	if err != nil || vmid != 100 || !expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("verifyPublicToken() = %d, %v, %v, want 100, %v, nil", vmid, expires, err, now.Add(time.Hour))
+	}
+
+	if _, _, err := verifyPublicToken(secret, token, now.Add(2*time.Hour)); err == nil {
+		t.Fatalf("verifyPublicToken() expired token error = nil, want error")
+	}
+	if _, _, err := verifyPublicToken("another-secret-value", token, now); err == nil {
+		t.Fatalf("verifyPublicToken() wrong secret error = nil, want error")
+	}
+	if _, _, err := verifyPublicToken(secret, "101"+strings.TrimPrefix(token, "100"), now); err == nil {
+		t.Fatalf("verifyPublicToken() tampered vmid error = nil, want error")
+	}
+	if _, _, err := verifyPublicToken("", token, now); err == nil {
+		t.Fatalf("verifyPublicToken() without secret error = nil, want error")
+	}
+
+	// 零值过期时间表示永不过期
+	forever := PublicToken(secret, 200, time.Time{})
+	if vmid, expires, err := verifyPublicToken(secret, forever, now.AddDate(10, 0, 0)); err != nil || vmid != 200 || !expires.IsZero() {
+		t.Fatalf("verifyPublicToken() non-expiring = %d, %v, %v, want 200, zero, nil", vmid, expires, err)
+	}
+}
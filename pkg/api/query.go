@@ -0,0 +1,346 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pve-traffic-monitor/pkg/i18n"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// listQuery 列表接口的分页、排序与字段选择参数
+type listQuery struct {
+	Page     int      // 页码（从1开始）
+	PerPage  int      // 每页条数
+	Sort     string   // 排序字段（JSON 字段名）
+	Desc     bool     // 是否降序
+	Fields   []string // 返回字段（为空表示全部）
+	Cursor   string   // 游标（优先于 page）
+	Paginate bool     // 是否请求了分页
+}
+
+// listCursor 游标内容：上一页最后一条记录的排序值和 vmid
+type listCursor struct {
+	Value interface{} `json:"v"`
+	VMID  json.Number `json:"id"`
+}
+
+// parseListQuery 解析 ?page, ?per_page, ?sort, ?order, ?fields, ?cursor 参数
+func parseListQuery(r *http.Request) (*listQuery, error) {
+	query := r.URL.Query()
+	q := &listQuery{
+		Page:    1,
+		PerPage: defaultPerPage,
+		Sort:    query.Get("sort"),
+		Cursor:  query.Get("cursor"),
+	}
+
+	if pageStr := query.Get("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return nil, i18n.Errorf("api.invalid_param", "page", pageStr)
+		}
+		q.Page = page
+		q.Paginate = true
+	}
+
+	if perPageStr := query.Get("per_page"); perPageStr != "" {
+		perPage, err := strconv.Atoi(perPageStr)
+		if err != nil || perPage < 1 {
+			return nil, i18n.Errorf("api.invalid_param", "per_page", perPageStr)
+		}
+		if perPage > maxPerPage {
+			perPage = maxPerPage
+		}
+		q.PerPage = perPage
+		q.Paginate = true
+	}
+
+	if q.Cursor != "" {
+		q.Paginate = true
+	}
+
+	switch strings.ToLower(query.Get("order")) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, i18n.Errorf("api.invalid_order", query.Get("order"))
+	}
+
+	if fieldsStr := query.Get("fields"); fieldsStr != "" {
+		for _, field := range strings.Split(fieldsStr, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				q.Fields = append(q.Fields, field)
+			}
+		}
+	}
+
+	return q, nil
+}
+
+// applyListQuery 对列表数据执行排序、分页和字段选择
+// items 会先序列化为 JSON 对象，因此排序和字段名均使用 JSON 字段名；
+// 数字保留为 json.Number（超过 2^53 的字节计数不会因转换为 float64 而改变）；
+// 排序相同时以 vmid 作为次序，保证分页和游标稳定
+func applyListQuery(items interface{}, q *listQuery) ([]map[string]interface{}, map[string]interface{}, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []map[string]interface{}{}
+	if err := decodeJSON(data, &rows); err != nil {
+		return nil, nil, err
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+
+	if q.Sort != "" && len(rows) > 0 && !sortableField(items, rows, q.Sort) {
+		return nil, nil, i18n.Errorf("api.invalid_sort", q.Sort)
+	}
+
+	less := func(a, b map[string]interface{}) bool {
+		if q.Sort != "" {
+			if cmp := compareValues(a[q.Sort], b[q.Sort]); cmp != 0 {
+				if q.Desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+		}
+		return compareValues(a["vmid"], b["vmid"]) < 0
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return less(rows[i], rows[j])
+	})
+
+	total := len(rows)
+	if !q.Paginate {
+		return selectFields(rows, q.Fields), nil, nil
+	}
+
+	start := (q.Page - 1) * q.PerPage
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		anchor := map[string]interface{}{"vmid": cursor.VMID}
+		if q.Sort != "" {
+			anchor[q.Sort] = cursor.Value
+		}
+		start = sort.Search(len(rows), func(i int) bool {
+			return less(anchor, rows[i])
+		})
+	}
+	if start > total {
+		start = total
+	}
+	end := start + q.PerPage
+	if end > total {
+		end = total
+	}
+
+	page := rows[start:end]
+	pagination := map[string]interface{}{
+		"total":       total,
+		"per_page":    q.PerPage,
+		"total_pages": (total + q.PerPage - 1) / q.PerPage,
+	}
+	if q.Cursor == "" {
+		pagination["page"] = q.Page
+	}
+	if end < total && len(page) > 0 {
+		last := page[len(page)-1]
+		pagination["next_cursor"] = encodeCursor(last, q.Sort)
+	}
+
+	return selectFields(page, q.Fields), pagination, nil
+}
+
+// selectFields 仅保留指定字段（vmid 始终保留，用于标识记录）
+func selectFields(rows []map[string]interface{}, fields []string) []map[string]interface{} {
+	if len(fields) == 0 {
+		return rows
+	}
+
+	result := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		selected := map[string]interface{}{"vmid": row["vmid"]}
+		for _, field := range fields {
+			if value, ok := row[field]; ok {
+				selected[field] = value
+			}
+		}
+		result[i] = selected
+	}
+	return result
+}
+
+// sortableField 检查排序字段是否存在：结构体列表按声明的 JSON 字段判断（omitempty 的字段在部分行中缺失），
+// 其他类型按所有行的字段并集判断
+func sortableField(items interface{}, rows []map[string]interface{}, name string) bool {
+	elem := reflect.TypeOf(items)
+	if elem != nil && (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array) {
+		elem = elem.Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			return hasJSONField(elem, name)
+		}
+	}
+
+	for _, row := range rows {
+		if _, ok := row[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJSONField 结构体是否声明了该 JSON 字段（包括嵌入结构体展开的字段）
+func hasJSONField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		fieldName, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && fieldName == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if hasJSONField(embedded, name) {
+					return true
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		if fieldName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSON 解码 JSON，数字解码为 json.Number
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// compareValues 比较两个 JSON 值（数字、字符串、布尔），返回 -1/0/1
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case json.Number:
+		if bv, ok := b.(json.Number); ok {
+			if cmp, ok := compareNumbers(av, bv); ok {
+				return cmp
+			}
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// 类型不一致或无法比较时按字符串比较
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareNumbers 精确比较两个 JSON 数字：整数直接比较，其他按有理数比较
+func compareNumbers(a, b json.Number) (int, bool) {
+	if ai, err := strconv.ParseInt(string(a), 10, 64); err == nil {
+		if bi, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			switch {
+			case ai < bi:
+				return -1, true
+			case ai > bi:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if au, err := strconv.ParseUint(string(a), 10, 64); err == nil {
+		if bu, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+			switch {
+			case au < bu:
+				return -1, true
+			case au > bu:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	ar, ok := new(big.Rat).SetString(string(a))
+	if !ok {
+		return 0, false
+	}
+	br, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		return 0, false
+	}
+	return ar.Cmp(br), true
+}
+
+func encodeCursor(row map[string]interface{}, sortField string) string {
+	cursor := listCursor{}
+	if vmid, ok := row["vmid"].(json.Number); ok {
+		cursor.VMID = vmid
+	}
+	if sortField != "" {
+		cursor.Value = row[sortField]
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, i18n.Errorf("api.invalid_cursor")
+	}
+
+	var cursor listCursor
+	if err := decodeJSON(data, &cursor); err != nil {
+		return nil, i18n.Errorf("api.invalid_cursor")
+	}
+	return &cursor, nil
+}
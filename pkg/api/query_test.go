@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+type testRow struct {
+	VMID       int    `json:"vmid"`
+	Name       string `json:"name"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+func TestApplyListQuerySortsAndPaginates(t *testing.T) {
+	rows := []testRow{
+		{VMID: 103, Name: "c", TotalBytes: 300},
+		{VMID: 101, Name: "a", TotalBytes: 500},
+		{VMID: 102, Name: "b", TotalBytes: 300},
+		{VMID: 104, Name: "d", TotalBytes: 100},
+	}
+
+	q, err := parseListQuery(httptest.NewRequest("GET", "/api/stats?sort=total_bytes&order=desc&per_page=2&fields=total_bytes", nil))
+	if err != nil {
+		t.Fatalf("parseListQuery() error = %v", err)
+	}
+
+	page, pagination, err := applyListQuery(rows, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() error = %v", err)
+	}
+	if got := vmidsOf(page); len(got) != 2 || got[0] != 101 || got[1] != 102 {
+		t.Fatalf("first page vmids = %v, want [101 102]", got)
+	}
+	if _, ok := page[0]["name"]; ok {
+		t.Fatalf("name should be removed by fields selection: %#v", page[0])
+	}
+	if pagination["total"] != 4 || pagination["total_pages"] != 2 {
+		t.Fatalf("pagination = %#v", pagination)
+	}
+
+	cursor, ok := pagination["next_cursor"].(string)
+	if !ok || cursor == "" {
+		t.Fatalf("next_cursor missing: %#v", pagination)
+	}
+
+	q.Cursor = cursor
+	page, pagination, err = applyListQuery(rows, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() with cursor error = %v", err)
+	}
+	if got := vmidsOf(page); len(got) != 2 || got[0] != 103 || got[1] != 104 {
+		t.Fatalf("second page vmids = %v, want [103 104]", got)
+	}
+	if _, ok := pagination["next_cursor"]; ok {
+		t.Fatalf("last page should not have next_cursor: %#v", pagination)
+	}
+}
+
+func TestApplyListQueryWithoutPaginationReturnsAll(t *testing.T) {
+	q, err := parseListQuery(httptest.NewRequest("GET", "/api/vms", nil))
+	if err != nil {
+		t.Fatalf("parseListQuery() error = %v", err)
+	}
+
+	rows, pagination, err := applyListQuery([]testRow{{VMID: 2}, {VMID: 1}}, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() error = %v", err)
+	}
+	if pagination != nil {
+		t.Fatalf("pagination = %#v, want nil", pagination)
+	}
+	if got := vmidsOf(rows); len(got) != 2 || got[0] != 1 {
+		t.Fatalf("vmids = %v, want stable vmid order", got)
+	}
+}
+
+func TestParseListQueryRejectsInvalidValues(t *testing.T) {
+	for _, rawQuery := range []string{"page=0", "per_page=abc", "order=up"} {
+		if _, err := parseListQuery(httptest.NewRequest("GET", "/api/vms?"+rawQuery, nil)); err == nil {
+			t.Fatalf("parseListQuery(%q) should fail", rawQuery)
+		}
+	}
+
+	q, _ := parseListQuery(httptest.NewRequest("GET", "/api/vms?sort=missing", nil))
+	if _, _, err := applyListQuery([]testRow{{VMID: 1}}, q); err == nil {
+		t.Fatal("unknown sort field should fail")
+	}
+}
+
+func TestApplyListQuerySortsByOmitemptyField(t *testing.T) {
+	type sparseRow struct {
+		VMID  int    `json:"vmid"`
+		Owner string `json:"owner,omitempty"`
+	}
+
+	q, _ := parseListQuery(httptest.NewRequest("GET", "/api/vms?sort=owner&order=desc", nil))
+	rows, _, err := applyListQuery([]sparseRow{{VMID: 1}, {VMID: 2, Owner: "alice"}}, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() error = %v, want owner accepted although the first row omits it", err)
+	}
+	if got := vmidsOf(rows); got[0] != 2 {
+		t.Fatalf("vmids = %v, want the row with an owner first", got)
+	}
+
+	// 非结构体列表按所有行的字段并集判断
+	maps := []map[string]interface{}{{"vmid": 1}, {"vmid": 2, "owner": "alice"}}
+	if _, _, err := applyListQuery(maps, q); err != nil {
+		t.Fatalf("applyListQuery() on maps error = %v", err)
+	}
+}
+
+func TestApplyListQueryKeepsLargeCountersExact(t *testing.T) {
+	// 超过 2^53 的计数转换为 float64 后会改变，且相邻的值无法区分
+	rows := []testRow{
+		{VMID: 101, TotalBytes: 1<<63 + 1},
+		{VMID: 102, TotalBytes: 1<<63 + 3},
+		{VMID: 103, TotalBytes: 1<<63 + 2},
+	}
+
+	q, _ := parseListQuery(httptest.NewRequest("GET", "/api/stats?sort=total_bytes&per_page=2", nil))
+	page, pagination, err := applyListQuery(rows, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() error = %v", err)
+	}
+	if got := vmidsOf(page); got[0] != 101 || got[1] != 103 {
+		t.Fatalf("first page vmids = %v, want [101 103]", got)
+	}
+	if got := page[1]["total_bytes"]; got != json.Number("9223372036854775810") {
+		t.Fatalf("total_bytes = %v, want the exact counter", got)
+	}
+
+	q.Cursor = pagination["next_cursor"].(string)
+	page, _, err = applyListQuery(rows, q)
+	if err != nil {
+		t.Fatalf("applyListQuery() with cursor error = %v", err)
+	}
+	if got := vmidsOf(page); len(got) != 1 || got[0] != 102 {
+		t.Fatalf("second page vmids = %v, want [102]", got)
+	}
+}
+
+func vmidsOf(rows []map[string]interface{}) []int {
+	vmids := make([]int, len(rows))
+	for i, row := range rows {
+		vmid, _ := row["vmid"].(json.Number).Int64()
+		vmids[i] = int(vmid)
+	}
+	return vmids
+}
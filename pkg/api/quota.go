@@ -0,0 +1,198 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/pve"
+)
+
+// QuotaEntry 一台虚拟机在一条规则下的用量（流量规则单位为 GB，带宽规则为 Mbps）
+type QuotaEntry struct {
+	VMID             int        `json:"vmid"`
+	Name             string     `json:"name"`
+	Status           string     `json:"status"`
+	Rule             string     `json:"rule"`
+	Type             string     `json:"type"` // volume/rate/percentile
+	Period           string     `json:"period"`
+	PeriodStart      *time.Time `json:"period_start,omitempty"` // 流量规则当前周期的开始时间（滑动窗口为窗口起点）
+	PeriodEnd        *time.Time `json:"period_end,omitempty"`   // 固定周期的结束时间
+	Used             float64    `json:"used"`
+	Limit            float64    `json:"limit"`
+	Unit             string     `json:"unit"` // GB 或 Mbps
+	Percent          float64    `json:"percent"`
+	Projected        float64    `json:"projected"`            // 固定周期的流量规则：按周期内平均速度推算的周期结束时用量（其他规则为 0）
+	ProjectedPercent float64    `json:"projected_percent"`    // 推算用量占限额的百分比
+	ExceedsAt        *time.Time `json:"exceeds_at,omitempty"` // 按平均速度推算的超限时间（周期结束前不会超限或已超限时不返回）
+	Exceeded         bool       `json:"exceeded"`
+	Critical         bool       `json:"critical"` // 已超限、使用率达到 critical_percent 或推算会在周期结束前超限
+	Error            string     `json:"error,omitempty"`
+}
+
+// minProjectionElapsed 推算周期末用量前周期至少已经过的时间（周期刚开始时平均速度没有意义），不超过周期长度的 1/10
+const minProjectionElapsed = time.Hour
+
+// handleQuotaSummary 所有虚拟机 × 规则的用量、限额、使用率和推算用量（带缓存，客户密钥只能看到自己的虚拟机）
+// 支持 ?critical=true 只返回需要关注的条目、?critical_percent 调整阈值（默认 events.warn_percent），
+// 以及与 /api/vms 相同的分页、排序和字段参数（默认按 percent 降序）
+// GET /api/quota-summary
+func (s *Server) handleQuotaSummary(w http.ResponseWriter, r *http.Request) {
+	listQuery, err := parseListQuery(r)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+	if listQuery.Sort == "" {
+		listQuery.Sort = "percent"
+		listQuery.Desc = listQuery.Desc || r.URL.Query().Get("order") == ""
+	}
+
+	query := r.URL.Query()
+	criticalOnly := false
+	if value := query.Get("critical"); value != "" {
+		if criticalOnly, err = strconv.ParseBool(value); err != nil {
+			s.sendError(w, s.tr(r, "api.invalid_param", "critical", value), http.StatusBadRequest)
+			return
+		}
+	}
+	threshold := float64(s.config.Events.WarnThreshold())
+	if threshold == 0 {
+		threshold = models.DefaultWarnPercent
+	}
+	if value := query.Get("critical_percent"); value != "" {
+		if threshold, err = strconv.ParseFloat(value, 64); err != nil || threshold <= 0 {
+			s.sendError(w, s.tr(r, "api.invalid_param", "critical_percent", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	allowed, err := s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	const cacheKey = "quota_summary"
+	var entries []QuotaEntry
+	cached, ok := s.getCache(cacheKey)
+	if ok {
+		entries = cached.([]QuotaEntry)
+	} else {
+		vms, err := s.allVMs(false)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+			return
+		}
+		entries = s.quotaEntries(vms, time.Now())
+		s.setCache(cacheKey, entries, ruleUsageCacheTTL)
+	}
+
+	result := make([]QuotaEntry, 0, len(entries))
+	var critical int
+	for _, entry := range entries {
+		if allowed != nil && !allowed[entry.VMID] {
+			continue
+		}
+		entry.Critical = entry.Exceeded || entry.Percent >= threshold || entry.ProjectedPercent >= 100
+		if entry.Critical {
+			critical++
+		} else if criticalOnly {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	s.sendList(w, r, result, listQuery, map[string]interface{}{
+		"critical_count":   critical,
+		"critical_percent": threshold,
+		"cached":           ok,
+	})
+}
+
+// quotaEntries 计算每台虚拟机在每条启用且匹配的规则下的用量
+func (s *Server) quotaEntries(vms []models.VMInfo, now time.Time) []QuotaEntry {
+	getCreationTime := s.creationTimeLookup()
+	entries := []QuotaEntry{}
+	for _, rule := range s.config.Rules {
+		// 主机规则的限额是所有虚拟机的总和，不按单台虚拟机计算使用率
+		if !rule.Enabled || rule.IsHostRule() {
+			continue
+		}
+		for _, vm := range vms {
+			if !pve.VMMatchesRule(vm, rule) {
+				continue
+			}
+			usage := s.calculateRuleVMUsage(vm, rule, getCreationTime)
+			entry := QuotaEntry{
+				VMID:     vm.VMID,
+				Name:     vm.Name,
+				Status:   vm.Status,
+				Rule:     rule.Name,
+				Type:     rule.Type,
+				Period:   rule.Period,
+				Percent:  usage.Percent,
+				Exceeded: usage.Exceeded,
+				Error:    usage.Error,
+			}
+			if entry.Type == "" {
+				entry.Type = models.RuleTypeVolume
+			}
+			entry.Used, entry.Limit, entry.Unit = ruleUsageAmount(rule, usage)
+			if !rule.IsRateRule() && !rule.IsPercentileRule() {
+				var creationTime time.Time
+				if rule.UseCreationTime {
+					creationTime = getCreationTime(vm.VMID)
+				}
+				projectQuota(&entry, periodcalc.ForRule(rule, creationTime), now)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// projectQuota 按当前周期内的平均速度推算流量规则在周期结束时的用量和超限时间（滑动窗口不推算）
+func projectQuota(entry *QuotaEntry, calc *periodcalc.Calculator, now time.Time) {
+	start := calc.PeriodStartAt(now)
+	entry.PeriodStart = &start
+	if _, rolling := calc.Rolling(); rolling {
+		return
+	}
+	end := calc.NextPeriodStartAt(now)
+	entry.PeriodEnd = &end
+
+	length, elapsed := end.Sub(start), now.Sub(start)
+	if elapsed < min(minProjectionElapsed, length/10) || elapsed <= 0 || entry.Limit <= 0 {
+		return
+	}
+	entry.Projected = entry.Used * float64(length) / float64(elapsed)
+	entry.ProjectedPercent = entry.Projected / entry.Limit * 100
+	if !entry.Exceeded && entry.Used > 0 && entry.Projected > entry.Limit {
+		at := start.Add(time.Duration(float64(elapsed) * entry.Limit / entry.Used))
+		entry.ExceedsAt = &at
+	}
+}
+
+// ruleUsageAmount 规则下的用量和限额（流量规则单位为 GB，带宽规则为 Mbps）
+func ruleUsageAmount(rule models.Rule, usage RuleVMUsage) (used, limit float64, unit string) {
+	if rule.IsRateRule() || rule.IsPercentileRule() {
+		return usage.RateMbps, rule.RateThresholdMbps, "Mbps"
+	}
+	return float64(usage.UsedBytes) / models.BytesPerGB, rule.LimitGB, "GB"
+}
+
+// creationTimeLookup 同一请求内复用创建时间，避免重复查询 VM 配置
+func (s *Server) creationTimeLookup() func(int) time.Time {
+	creationTimes := make(map[int]time.Time)
+	return func(vmid int) time.Time {
+		if ct, ok := creationTimes[vmid]; ok {
+			return ct
+		}
+		ct, _ := s.creation.Time(vmid)
+		creationTimes[vmid] = ct
+		return ct
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+)
+
+func TestProjectQuota(t *testing.T) {
+	loc := periodcalc.Location()
+	now := time.Date(2024, 1, 11, 0, 0, 0, 0, loc)
+	monthly := periodcalc.ForRule(models.Rule{Period: models.PeriodMonth}, time.Time{})
+
+	// 10 天用了 400 GB：31 天推算 1240 GB，第 25 天 0 点达到 1000 GB
+	entry := QuotaEntry{Used: 400, Limit: 1000}
+	projectQuota(&entry, monthly, now)
+	if entry.PeriodStart == nil || entry.PeriodEnd == nil || entry.PeriodEnd.Sub(*entry.PeriodStart) != 31*24*time.Hour {
+		t.Fatalf("period = %v - %v, want January", entry.PeriodStart, entry.PeriodEnd)
+	}
+	if entry.Projected != 1240 || entry.ProjectedPercent != 124 {
+		t.Fatalf("projected = %v (%v%%), want 1240 (124%%)", entry.Projected, entry.ProjectedPercent)
+	}
+	if want := time.Date(2024, 1, 26, 0, 0, 0, 0, loc); entry.ExceedsAt == nil || !entry.ExceedsAt.Equal(want) {
+		t.Fatalf("exceeds_at = %v, want %v", entry.ExceedsAt, want)
+	}
+
+	// 周期刚开始不推算
+	entry = QuotaEntry{Used: 10, Limit: 1000}
+	projectQuota(&entry, monthly, time.Date(2024, 1, 1, 0, 30, 0, 0, loc))
+	if entry.Projected != 0 || entry.ExceedsAt != nil {
+		t.Fatalf("early projection = %+v, want none", entry)
+	}
+
+	// 已超限不再给出超限时间
+	entry = QuotaEntry{Used: 1100, Limit: 1000, Exceeded: true}
+	projectQuota(&entry, monthly, now)
+	if entry.ExceedsAt != nil || entry.ProjectedPercent <= 100 {
+		t.Fatalf("exceeded projection = %+v", entry)
+	}
+
+	// 滑动窗口只有窗口起点
+	entry = QuotaEntry{Used: 400, Limit: 1000}
+	projectQuota(&entry, periodcalc.ForRule(models.Rule{Period: "rolling:7d"}, time.Time{}), now)
+	if entry.PeriodStart == nil || entry.PeriodEnd != nil || entry.Projected != 0 {
+		t.Fatalf("rolling projection = %+v, want window start only", entry)
+	}
+}
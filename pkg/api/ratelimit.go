@@ -0,0 +1,180 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// expensivePaths 需要查询大量存储数据的接口（按前缀匹配），每次请求消耗 expensive_cost 配额
+var expensivePaths = []string{
+	"/api/stats",
+	"/api/history/",
+	"/api/logs",
+	"/api/rules/usage",
+	"/api/quota-summary",
+	"/api/actions/summary",
+	"/api/tenants",
+	"/api/networks",
+	"/api/node/stats",
+	"/public/api/vm/",
+}
+
+// bucketIdleTTL 令牌桶空闲多久后清理
+const bucketIdleTTL = 10 * time.Minute
+
+// tokenBucket 单个客户端的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter 按客户端（API 令牌或 IP）限流的令牌桶
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	rate      float64 // 每秒补充的令牌数
+	burst     float64 // 桶容量
+	expensive float64 // 耗时接口消耗的令牌数
+	now       func() time.Time
+}
+
+// newRateLimiter 创建限流器（未配置的参数使用默认值）
+func newRateLimiter(cfg models.RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		rate:      cfg.RequestsPerSecond,
+		burst:     float64(cfg.Burst),
+		expensive: cfg.ExpensiveCost,
+		now:       time.Now,
+	}
+	if l.rate <= 0 {
+		l.rate = models.DefaultRateLimitRPS
+	}
+	if l.burst <= 0 {
+		l.burst = models.DefaultRateLimitBurst
+	}
+	if l.expensive <= 0 {
+		l.expensive = models.DefaultRateLimitExpensive
+	}
+	// 单次消耗不能超过桶容量，否则永远无法通过
+	l.expensive = math.Min(l.expensive, l.burst)
+	return l
+}
+
+// allow 尝试消耗 cost 个令牌，失败时返回需要等待的时间
+func (l *rateLimiter) allow(key string, cost float64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens >= cost {
+		bucket.tokens -= cost
+		return true, 0
+	}
+
+	wait := time.Duration((cost - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// cleanup 清理长时间未使用（已经补满）的令牌桶
+func (l *rateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// cleanupLoop 定期清理空闲令牌桶
+func (l *rateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(bucketIdleTTL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.cleanup()
+	}
+}
+
+// requestCost 请求消耗的令牌数
+func (l *rateLimiter) requestCost(path string) float64 {
+	for _, prefix := range expensivePaths {
+		if strings.HasPrefix(path, prefix) {
+			return l.expensive
+		}
+	}
+	return 1
+}
+
+// clientIP 获取客户端 IP（trust_proxy 时使用反向代理传递的地址）
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// 第一个地址为原始客户端
+			return strings.TrimSpace(strings.SplitN(forwarded, ",", 2)[0])
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitKey 限流维度：有效的 API 令牌按令牌计算，其余按客户端 IP 计算
+// 无效令牌不单独计数，避免通过更换令牌绕过限流
+func (s *Server) rateLimitKey(r *http.Request) string {
+	token := requestToken(r)
+	if token != "" && (token == s.config.API.Token || s.findAPIKey(token) != nil) {
+		return "token:" + token
+	}
+	return "ip:" + clientIP(r, s.config.API.RateLimit.TrustProxy)
+}
+
+// rateLimitMiddleware 请求大小限制和限流中间件
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBodyBytes := s.config.API.RateLimit.MaxBodyBytes
+		if maxBodyBytes <= 0 {
+			maxBodyBytes = models.DefaultMaxBodyBytes
+		}
+		if r.ContentLength > maxBodyBytes {
+			s.sendError(w, s.tr(r, "api.body_too_large", maxBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+		if s.limiter != nil {
+			if ok, wait := s.limiter.allow(s.rateLimitKey(r), s.limiter.requestCost(r.URL.Path)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				s.sendError(w, s.tr(r, "api.rate_limited"), http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(models.RateLimitConfig{RequestsPerSecond: 1, Burst: 3, ExpensiveCost: 10})
+	l.now = func() time.Time { return now }
+
+	if l.expensive != 3 {
+		t.Fatalf("expensive = %v, want capped at burst 3", l.expensive)
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", 1); !ok {
+			t.Fatalf("request %d denied, want allowed within burst", i)
+		}
+	}
+	ok, wait := l.allow("a", 1)
+	if ok || wait != time.Second {
+		t.Fatalf("allow() = %v, %v, want denied with 1s wait", ok, wait)
+	}
+	if ok, _ := l.allow("b", 1); !ok {
+		t.Fatalf("other client denied, want separate bucket")
+	}
+
+	now = now.Add(2 * time.Second)
+	if ok, _ := l.allow("a", 2); !ok {
+		t.Fatalf("allow() after refill denied, want allowed")
+	}
+
+	now = now.Add(bucketIdleTTL + time.Second)
+	l.cleanup()
+	if len(l.buckets) != 0 {
+		t.Fatalf("len(buckets) = %d after cleanup, want 0", len(l.buckets))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := &Server{config: &models.Config{API: models.APIConfig{
+		Token:     "admin",
+		RateLimit: models.RateLimitConfig{Enabled: true, RequestsPerSecond: 1, Burst: 5, MaxBodyBytes: 8},
+	}}}
+	s.limiter = newRateLimiter(s.config.API.RateLimit)
+	handler := s.rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// 耗时接口一次消耗 5 个令牌（默认值受 burst 限制）
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request code = %d, want 200", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("second request code = %d Retry-After = %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// 有效令牌使用独立配额
+	req.Header.Set("X-API-Token", "admin")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token request code = %d, want 200", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/vms", nil)
+	req.ContentLength = 100
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large body code = %d, want 413", rec.Code)
+	}
+}
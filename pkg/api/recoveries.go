@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/recovery"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// 修改恢复计划的操作
+const (
+	recoveryPostpone = "postpone" // 修改计划恢复时间
+	recoveryCancel   = "cancel"   // 取消自动恢复
+)
+
+// SetRecoveryHandler 设置修改恢复计划的处理函数（由恢复管理器保存并立即生效，until 为零值表示取消自动恢复）
+func (s *Server) SetRecoveryHandler(handler func(vmid int, until time.Time) (models.VMState, error)) {
+	s.recoveryHandler = handler
+}
+
+// pendingRecovery 待恢复的虚拟机
+type pendingRecovery struct {
+	VMID             int        `json:"vmid"`
+	Action           string     `json:"action"`
+	Rule             string     `json:"rule"`
+	Since            time.Time  `json:"since"`
+	RecoveryAt       *time.Time `json:"recovery_at,omitempty"`       // 计划恢复时间（取消自动恢复时为空）
+	RemainingSeconds int64      `json:"remaining_seconds,omitempty"` // 距离恢复的秒数（已到期等待下次检查时为 0）
+	Canceled         bool       `json:"canceled,omitempty"`          // 已取消自动恢复
+	External         string     `json:"external_status,omitempty"`   // 限制期间被外部修改后的运行状态
+	WillStart        bool       `json:"will_start"`                  // 恢复时是否会启动虚拟机（关机/停止前为运行状态，且没有被外部修改）
+}
+
+// newPendingRecovery 由恢复状态生成列表项
+func newPendingRecovery(state models.VMState, now time.Time) pendingRecovery {
+	item := pendingRecovery{
+		VMID:      state.VMID,
+		Action:    state.ActionTaken,
+		Rule:      state.RuleName,
+		Since:     state.ActionTime,
+		Canceled:  state.RecoveryCanceled,
+		External:  state.ExternalStatus,
+		WillStart: models.EnforcementState(state.ActionTaken) == models.EnforcementStopped && state.OriginalStatus == "running" && !state.ExternallyModified(),
+	}
+	if !state.RecoveryCanceled {
+		recoveryAt := state.RecoveryTime
+		item.RecoveryAt = &recoveryAt
+		if remaining := recoveryAt.Sub(now); remaining > 0 {
+			item.RemainingSeconds = int64(remaining / time.Second)
+		}
+	}
+	return item
+}
+
+// recoveryRequest 修改恢复计划的请求
+type recoveryRequest struct {
+	VMID  int       `json:"vmid"`
+	Op    string    `json:"op"`    // postpone / cancel
+	Until time.Time `json:"until"` // postpone 的新恢复时间
+}
+
+// handleRecoveries 查看待恢复的虚拟机，推迟或取消自动恢复（客户密钥只能查看自己的虚拟机，修改需要配置 api.token）
+// GET /api/recoveries
+// POST /api/recoveries {"vmid": 100, "op": "postpone", "until": "..."} 或 {"vmid": 100, "op": "cancel"}
+func (s *Server) handleRecoveries(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.pendingRecoveries(r, time.Now())
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.recoveries_failed", err), http.StatusInternalServerError)
+			return
+		}
+		s.sendJSON(w, map[string]interface{}{"success": true, "data": items})
+
+	case http.MethodPost:
+		if requestTenant(r) != "" {
+			s.sendError(w, s.tr(r, "api.recoveries_forbidden"), http.StatusForbidden)
+			return
+		}
+		// 未配置管理员令牌时 API 不认证，不允许修改恢复计划
+		if s.config.API.Token == "" || s.recoveryHandler == nil {
+			s.sendError(w, s.tr(r, "api.recoveries_disabled"), http.StatusForbidden)
+			return
+		}
+		var req recoveryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VMID <= 0 ||
+			(req.Op != recoveryCancel && (req.Op != recoveryPostpone || !req.Until.After(time.Now()))) {
+			s.sendError(w, s.tr(r, "api.recoveries_invalid"), http.StatusBadRequest)
+			return
+		}
+		until := req.Until
+		if req.Op == recoveryCancel {
+			until = time.Time{}
+		}
+		state, err := s.recoveryHandler(req.VMID, until)
+		if errors.Is(err, recovery.ErrNoPendingRecovery) {
+			s.sendError(w, s.tr(r, "api.recovery_not_found", req.VMID), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.recoveries_failed", err), http.StatusInternalServerError)
+			return
+		}
+		s.sendJSON(w, map[string]interface{}{"success": true, "data": newPendingRecovery(state, time.Now())})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		s.sendError(w, s.tr(r, "api.method_not_allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// pendingRecoveries 从存储读取待恢复的虚拟机（备用实例也能看到主实例的恢复计划），按恢复时间排序，取消自动恢复的排在最后
+func (s *Server) pendingRecoveries(r *http.Request, now time.Time) ([]pendingRecovery, error) {
+	allowed, err := s.allowedVMIDs(r)
+	if err != nil {
+		return nil, err
+	}
+	vmids, err := storage.StateVMIDs(s.storage)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]pendingRecovery, 0)
+	for _, vmid := range vmids {
+		if allowed != nil && !allowed[vmid] {
+			continue
+		}
+		state, err := storage.LoadRecoveryState(s.storage, vmid)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil {
+			items = append(items, newPendingRecovery(*state, now))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Canceled != b.Canceled {
+			return b.Canceled
+		}
+		if a.RecoveryAt != nil && b.RecoveryAt != nil && !a.RecoveryAt.Equal(*b.RecoveryAt) {
+			return a.RecoveryAt.Before(*b.RecoveryAt)
+		}
+		return a.VMID < b.VMID
+	})
+	return items, nil
+}
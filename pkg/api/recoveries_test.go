@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/recovery"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestHandleRecoveries(t *testing.T) {
+	store, err := storage.NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	states := map[int]map[string]interface{}{
+		101: {"action_taken": models.ActionShutdown, "original_status": "running", "rule_name": "monthly", "needs_recovery": true, "recovery_time": now.Add(2 * time.Hour)},
+		102: {"action_taken": models.ActionRateLimit, "rule_name": "daily", "needs_recovery": true, "recovery_time": now.Add(time.Hour)},
+		103: {"action_taken": models.ActionDisconnect, "rule_name": "daily", "needs_recovery": true, "recovery_canceled": true},
+		104: {"action_taken": models.ActionStop, "rule_name": "daily", "needs_recovery": false},
+	}
+	for vmid, state := range states {
+		if err := storage.UpdateVMState(store, vmid, state); err != nil {
+			t.Fatalf("UpdateVMState(%d) error = %v", vmid, err)
+		}
+	}
+
+	var rescheduled []time.Time
+	s := &Server{
+		config:  &models.Config{API: models.APIConfig{Token: "secret"}},
+		storage: store,
+		recoveryHandler: func(vmid int, until time.Time) (models.VMState, error) {
+			if vmid != 101 {
+				return models.VMState{}, recovery.ErrNoPendingRecovery
+			}
+			rescheduled = append(rescheduled, until)
+			return models.VMState{VMID: vmid, ActionTaken: models.ActionShutdown, NeedsRecovery: true, RecoveryTime: until, RecoveryCanceled: until.IsZero()}, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleRecoveries(rec, httptest.NewRequest("GET", "/api/recoveries", nil))
+	var body struct {
+		Data []pendingRecovery `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != 200 {
+		t.Fatalf("GET = %d %s, %v", rec.Code, rec.Body.String(), err)
+	}
+	if len(body.Data) != 3 || body.Data[0].VMID != 102 || body.Data[1].VMID != 101 || body.Data[2].VMID != 103 {
+		t.Fatalf("recoveries = %+v, want 102, 101, then the canceled 103", body.Data)
+	}
+	if !body.Data[1].WillStart || body.Data[0].WillStart || body.Data[1].RemainingSeconds <= 3600 {
+		t.Fatalf("recovery of 101 = %+v, want a start in about two hours", body.Data[1])
+	}
+	if !body.Data[2].Canceled || body.Data[2].RecoveryAt != nil {
+		t.Fatalf("recovery of 103 = %+v, want canceled without a time", body.Data[2])
+	}
+
+	post := func(payload string) int {
+		rec := httptest.NewRecorder()
+		s.handleRecoveries(rec, httptest.NewRequest("POST", "/api/recoveries", strings.NewReader(payload)))
+		return rec.Code
+	}
+	until := now.Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	for payload, want := range map[string]int{
+		`{"vmid": 101, "op": "postpone", "until": "` + until + `"}`:        200,
+		`{"vmid": 101, "op": "cancel"}`:                                    200,
+		`{"vmid": 101, "op": "postpone", "until": "2024-01-01T00:00:00Z"}`: 400,
+		`{"vmid": 101, "op": "resume"}`:                                    400,
+		`{"vmid": 102, "op": "cancel"}`:                                    404,
+	} {
+		if code := post(payload); code != want {
+			t.Fatalf("POST %s = %d, want %d", payload, code, want)
+		}
+	}
+	if len(rescheduled) != 2 {
+		t.Fatalf("rescheduled = %v, want a postpone and a cancel", rescheduled)
+	}
+
+	s.config.API.Token = ""
+	if code := post(`{"vmid": 101, "op": "cancel"}`); code != 403 {
+		t.Fatalf("POST without api.token = %d, want 403", code)
+	}
+}
@@ -7,9 +7,14 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"pve-traffic-monitor/pkg/creation"
 	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/schedule"
+	"pve-traffic-monitor/pkg/stats"
 	"pve-traffic-monitor/pkg/storage"
+	"pve-traffic-monitor/pkg/tenant"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,7 +28,24 @@ type Server struct {
 	pveClient *pve.Client
 	mux       *http.ServeMux
 	cache     *Cache
-	perfStats *PerformanceStats // 性能统计
+	perfStats *PerformanceStats  // 性能统计
+	tenants   *tenant.Resolver   // 客户划分
+	limiter   *rateLimiter       // 请求限流（未启用时为 nil）
+	stats     *stats.Service     // 流量统计（与 Monitor 共用，合并并发计算）
+	creation  *creation.Resolver // 虚拟机创建时间解析（与 Monitor 共用）
+	networks  networkCache       // 虚拟机与网桥、SDN VNet 的对应关系
+
+	// 汇总模式：代理推送的处理和代理节点上的虚拟机
+	agentHandler func(models.AgentPush) error
+	remoteVMs    func() []models.VMInfo
+
+	ingestHook func(vmids []int) // 写入外部记录后执行规则
+
+	maintenanceHandler func(enabled bool, reason string) (models.Maintenance, error) // 修改维护模式
+	recoveryHandler    func(vmid int, until time.Time) (models.VMState, error)       // 推迟或取消自动恢复
+
+	eventStats      func() interface{} // 内部事件统计
+	collectionStats func() interface{} // 采集状态（每台虚拟机最近一次采样、采集中断）
 }
 
 // PerformanceStats 性能统计
@@ -44,15 +66,28 @@ type Cache struct {
 
 type CacheEntry struct {
 	Data      interface{}
+	CreatedAt time.Time // 生成时间（用于 ETag 和 Last-Modified）
 	ExpiresAt time.Time
+	VMID      int       // 数据所属虚拟机（0 表示包含全部虚拟机）
+	Until     time.Time // 数据覆盖的结束时间（零值表示截至当前）
+}
+
+// affectedBy 失效事件是否影响该缓存条目（新采样在数据覆盖范围内）
+func (e *CacheEntry) affectedBy(ev stats.Invalidation) bool {
+	if ev.VMID != stats.AllVMs && e.VMID != 0 && e.VMID != ev.VMID {
+		return false
+	}
+	return ev.At.IsZero() || e.Until.IsZero() || !ev.At.After(e.Until)
 }
 
 // NewServer 创建新的 API 服务器
-func NewServer(config *models.Config, storage storage.Interface, pveClient *pve.Client) *Server {
+func NewServer(config *models.Config, storage storage.Interface, pveClient *pve.Client, statsService *stats.Service, creationResolver *creation.Resolver) *Server {
 	s := &Server{
 		config:    config,
 		storage:   storage,
 		pveClient: pveClient,
+		stats:     statsService,
+		creation:  creationResolver,
 		mux:       http.NewServeMux(),
 		cache: &Cache{
 			data: make(map[string]*CacheEntry),
@@ -63,6 +98,22 @@ func NewServer(config *models.Config, storage storage.Interface, pveClient *pve.
 		},
 	}
 
+	tenants, err := tenant.NewResolver(config.Tenants)
+	if err != nil {
+		log.Printf("加载客户划分失败，按未划分处理: %v", err)
+	}
+	s.tenants = tenants
+
+	if config.API.RateLimit.Enabled {
+		s.limiter = newRateLimiter(config.API.RateLimit)
+		go s.limiter.cleanupLoop()
+	}
+
+	// 清除数据、重载缓存时同步清除响应缓存
+	if statsService != nil {
+		statsService.OnInvalidate(s.invalidateCache)
+	}
+
 	s.setupRoutes()
 
 	// 启动缓存清理协程
@@ -140,6 +191,19 @@ func (s *Server) cleanExpiredCache() {
 	}
 }
 
+// invalidateCache 统计服务缓存失效回调
+// 新采样到达时删除包含该采样的响应缓存，清除数据、重载缓存时全部删除
+func (s *Server) invalidateCache(ev stats.Invalidation) {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+
+	for key, entry := range s.cache.data {
+		if entry.affectedBy(ev) {
+			delete(s.cache.data, key)
+		}
+	}
+}
+
 // getStats 获取缓存统计
 func (c *Cache) getStats() (total int, expired int) {
 	c.mu.RLock()
@@ -159,6 +223,15 @@ func (c *Cache) getStats() (total int, expired int) {
 
 // getCache 获取缓存
 func (s *Server) getCache(key string) (interface{}, bool) {
+	entry, ok := s.getCacheEntry(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.Data, true
+}
+
+// getCacheEntry 获取缓存条目（包含生成时间）
+func (s *Server) getCacheEntry(key string) (*CacheEntry, bool) {
 	s.cache.mu.RLock()
 	defer s.cache.mu.RUnlock()
 
@@ -171,18 +244,29 @@ func (s *Server) getCache(key string) (interface{}, bool) {
 		return nil, false
 	}
 
-	return entry.Data, true
+	return entry, true
 }
 
-// setCache 设置缓存
-func (s *Server) setCache(key string, data interface{}, ttl time.Duration) {
+// setCache 设置缓存（数据包含全部虚拟机且截至当前，任何新采样都会使其失效）
+func (s *Server) setCache(key string, data interface{}, ttl time.Duration) *CacheEntry {
+	return s.setScopedCache(key, data, ttl, 0, time.Time{})
+}
+
+// setScopedCache 设置限定虚拟机和结束时间的缓存，只有范围内的新采样才会使其失效
+func (s *Server) setScopedCache(key string, data interface{}, ttl time.Duration, vmid int, until time.Time) *CacheEntry {
 	s.cache.mu.Lock()
 	defer s.cache.mu.Unlock()
 
-	s.cache.data[key] = &CacheEntry{
+	now := time.Now()
+	entry := &CacheEntry{
 		Data:      data,
-		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		VMID:      vmid,
+		Until:     until,
 	}
+	s.cache.data[key] = entry
+	return entry
 }
 
 // performanceMiddleware 性能监控中间件
@@ -195,35 +279,46 @@ func (s *Server) performanceMiddleware(handler http.HandlerFunc) http.HandlerFun
 	}
 }
 
+// requestToken 从多个来源获取 token（X-API-Token、Authorization: Bearer、?token）
+func requestToken(r *http.Request) string {
+	token := r.Header.Get("X-API-Token")
+	if token == "" {
+		token = r.Header.Get("Authorization")
+		// 支持 Bearer token 格式
+		if len(token) > 7 && token[:7] == "Bearer " {
+			token = token[7:]
+		}
+	}
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token
+}
+
 // authMiddleware token 验证中间件
 func (s *Server) authMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 如果没有配置 token，直接放行
-		if s.config.API.Token == "" {
+		// 如果没有配置 token 和客户密钥，直接放行
+		if s.config.API.Token == "" && len(s.config.API.Keys) == 0 {
 			handler(w, r)
 			return
 		}
 
-		// 从多个来源获取 token
-		token := r.Header.Get("X-API-Token")
-		if token == "" {
-			token = r.Header.Get("Authorization")
-			// 支持 Bearer token 格式
-			if len(token) > 7 && token[:7] == "Bearer " {
-				token = token[7:]
-			}
-		}
-		if token == "" {
-			token = r.URL.Query().Get("token")
+		token := requestToken(r)
+
+		// 管理员 token 可访问全部虚拟机
+		if s.config.API.Token != "" && token == s.config.API.Token {
+			handler(w, r)
+			return
 		}
 
-		// 验证 token
-		if token != s.config.API.Token {
-			s.sendError(w, "Unauthorized: invalid or missing token", http.StatusUnauthorized)
+		// 客户密钥只能访问所属客户的虚拟机
+		if key := s.findAPIKey(token); key != nil {
+			handler(w, withTenant(r, key.Tenant))
 			return
 		}
 
-		handler(w, r)
+		s.sendError(w, s.tr(r, "api.unauthorized"), http.StatusUnauthorized)
 	}
 }
 
@@ -234,9 +329,33 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/vm/", s.performanceMiddleware(s.authMiddleware(s.handleVM)))
 	s.mux.HandleFunc("/api/stats", s.performanceMiddleware(s.authMiddleware(s.handleStats)))
 	s.mux.HandleFunc("/api/history/", s.performanceMiddleware(s.authMiddleware(s.handleHistory)))
+	s.mux.HandleFunc("/api/chart/", s.performanceMiddleware(s.authMiddleware(s.handleChart)))
 	s.mux.HandleFunc("/api/logs", s.performanceMiddleware(s.authMiddleware(s.handleLogs)))
 	s.mux.HandleFunc("/api/rules", s.performanceMiddleware(s.authMiddleware(s.handleRules)))
+	s.mux.HandleFunc("/api/rules/usage", s.performanceMiddleware(s.authMiddleware(s.handleRuleUsage)))
+	s.mux.HandleFunc("/api/quota-summary", s.performanceMiddleware(s.authMiddleware(s.handleQuotaSummary)))
+	s.mux.HandleFunc("/api/actions/summary", s.performanceMiddleware(s.authMiddleware(s.handleActionSummary)))
+	s.mux.HandleFunc("/api/tenants", s.performanceMiddleware(s.authMiddleware(s.handleTenants)))
+	s.mux.HandleFunc("/api/networks", s.performanceMiddleware(s.authMiddleware(s.handleNetworks)))
+	s.mux.HandleFunc("/api/networks/", s.performanceMiddleware(s.authMiddleware(s.handleNetworks)))
+	s.mux.HandleFunc("/api/node/stats", s.performanceMiddleware(s.authMiddleware(s.handleNodeStats)))
+	s.mux.HandleFunc("/api/host/history", s.performanceMiddleware(s.authMiddleware(s.handleHostHistory)))
 	s.mux.HandleFunc("/api/system/stats", s.performanceMiddleware(s.authMiddleware(s.handleSystemStats)))
+	s.mux.HandleFunc("/api/public-link", s.performanceMiddleware(s.authMiddleware(s.handlePublicLink)))
+	s.mux.HandleFunc("/api/ingest", s.performanceMiddleware(s.authMiddleware(s.handleIngest)))
+	s.mux.HandleFunc("/api/maintenance", s.performanceMiddleware(s.authMiddleware(s.handleMaintenance)))
+	s.mux.HandleFunc("/api/recoveries", s.performanceMiddleware(s.authMiddleware(s.handleRecoveries)))
+	s.mux.HandleFunc("/api/theme", s.performanceMiddleware(s.handleTheme))
+
+	// Prometheus 指标（使用 API Token 认证，可配置 bearer_token）
+	s.mux.HandleFunc("/metrics", s.authMiddleware(s.handleMetrics))
+
+	// 采集代理推送（使用代理令牌认证）
+	s.mux.HandleFunc("/api/agent/push", s.performanceMiddleware(s.handleAgentPush))
+
+	// 公开状态页（使用签名链接，不需要 Token）
+	s.mux.HandleFunc("/public/vm/", s.performanceMiddleware(s.handlePublicVM))
+	s.mux.HandleFunc("/public/api/vm/", s.performanceMiddleware(s.handlePublicVMData))
 
 	// 静态文件（前端）
 	// 优先使用构建后的web/dist目录，如果不存在则使用内嵌的简化版本
@@ -308,23 +427,18 @@ func (s *Server) setupRoutes() {
 func (s *Server) Start() error {
 	addr := fmt.Sprintf("%s:%d", s.config.API.Host, s.config.API.Port)
 	log.Printf("API 服务器: http://%s\n", addr)
-	return http.ListenAndServe(addr, s.corsMiddleware(s.mux))
-}
 
-// corsMiddleware CORS 中间件
-func (s *Server) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Token")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+	maxHeaderBytes := s.config.API.RateLimit.MaxHeaderBytes
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = models.DefaultMaxHeaderBytes
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.securityHeadersMiddleware(s.corsMiddleware(s.rateLimitMiddleware(s.mux))),
+		ReadHeaderTimeout: 10 * time.Second,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+	return server.ListenAndServe()
 }
 
 // handleIndex 首页
@@ -342,41 +456,57 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
     <title>PVE Traffic Monitor</title>
     <script src="https://cdn.jsdelivr.net/npm/chart.js@4.4.1/dist/chart.umd.min.js"></script>
     <style>
+        /*__THEME_STYLE__*/
         * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f5f5f5; }
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: var(--page-bg); color: var(--text); transition: background 0.3s, color 0.3s; }
         .container { max-width: 1400px; margin: 0 auto; padding: 20px; }
-        header { background: #2c3e50; color: white; padding: 20px; border-radius: 8px; margin-bottom: 20px; display: flex; justify-content: space-between; align-items: center; }
+        header { background: var(--header-bg); color: white; padding: 20px; border-radius: 8px; margin-bottom: 20px; display: flex; justify-content: space-between; align-items: center; }
         h1 { font-size: 24px; }
         .tabs { display: flex; gap: 10px; }
         .tab { background: rgba(255,255,255,0.2); color: white; border: none; padding: 8px 16px; border-radius: 4px; cursor: pointer; transition: all 0.3s; }
         .tab:hover, .tab.active { background: rgba(255,255,255,0.3); }
         .stats { display: grid; grid-template-columns: repeat(auto-fit, minmax(250px, 1fr)); gap: 20px; margin-bottom: 20px; }
-        .stat-card { background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
-        .stat-card h3 { color: #7f8c8d; font-size: 14px; margin-bottom: 10px; }
-        .stat-card .value { font-size: 32px; font-weight: bold; color: #2c3e50; }
-        .card { background: white; border-radius: 8px; padding: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); margin-bottom: 20px; }
+        .stat-card { background: var(--card-bg); padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .stat-card h3 { color: var(--muted); font-size: 14px; margin-bottom: 10px; }
+        .stat-card .value { font-size: 32px; font-weight: bold; color: var(--text); }
+        .card { background: var(--card-bg); border-radius: 8px; padding: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); margin-bottom: 20px; }
         .controls { display: flex; gap: 10px; margin-bottom: 20px; align-items: center; }
-        .controls select, .controls button { padding: 8px 16px; border: 1px solid #ddd; border-radius: 4px; background: white; cursor: pointer; }
-        .controls button { background: #3498db; color: white; border: none; }
-        .controls button:hover { background: #2980b9; }
+        .controls select, .controls button { padding: 8px 16px; border: 1px solid var(--border); border-radius: 4px; background: var(--card-bg); color: var(--text); cursor: pointer; }
+        .controls button, .btn-primary { background: var(--primary); color: white; border: none; }
+        .controls button:hover, .btn-primary:hover { filter: brightness(0.9); }
+        .btn-primary { border-radius: 4px; cursor: pointer; }
         table { width: 100%; border-collapse: collapse; }
-        th, td { padding: 12px; text-align: left; border-bottom: 1px solid #ecf0f1; }
-        th { background: #34495e; color: white; font-weight: 500; }
-        tr:hover { background: #f8f9fa; }
+        th, td { padding: 12px; text-align: left; border-bottom: 1px solid var(--grid-line); }
+        th { background: var(--header-bg); color: white; font-weight: 500; }
+        tr:hover { background: var(--grid-line); }
         tr.clickable { cursor: pointer; }
         .status { display: inline-block; padding: 4px 12px; border-radius: 12px; font-size: 12px; font-weight: 500; }
         .status.running { background: #d4edda; color: #155724; }
         .status.stopped { background: #f8d7da; color: #721c24; }
-        .traffic { color: #3498db; font-weight: 500; }
-        .loading { text-align: center; padding: 40px; color: #7f8c8d; }
+        :root[data-theme="dark"] .status.running { background: rgba(103, 194, 58, 0.2); color: var(--success); }
+        :root[data-theme="dark"] .status.stopped { background: rgba(245, 108, 108, 0.2); color: var(--danger); }
+        .traffic { color: var(--primary); font-weight: 500; }
+        .loading { text-align: center; padding: 40px; color: var(--muted); }
+        .error { color: var(--danger); }
         .page { display: none; }
         .page.active { display: block; }
         .chart-container { position: relative; height: 400px; }
         .modal { display: none; position: fixed; top: 0; left: 0; right: 0; bottom: 0; background: rgba(0,0,0,0.5); z-index: 1000; }
         .modal.active { display: flex; align-items: center; justify-content: center; }
-        .modal-content { background: white; border-radius: 8px; padding: 20px; max-width: 90%; max-height: 90%; overflow: auto; }
+        .modal-content { background: var(--card-bg); border-radius: 8px; padding: 20px; max-width: 90%; max-height: 90%; overflow: auto; }
         .modal-header { display: flex; justify-content: space-between; align-items: center; margin-bottom: 20px; }
-        .modal-close { background: #e74c3c; color: white; border: none; padding: 8px 16px; border-radius: 4px; cursor: pointer; }
+        .modal-close { background: var(--danger); color: white; border: none; padding: 8px 16px; border-radius: 4px; cursor: pointer; }
+        .rule-header { display: flex; justify-content: space-between; align-items: baseline; margin-bottom: 12px; }
+        .rule-meta { color: var(--muted); font-size: 13px; }
+        .usage-bar { position: relative; height: 18px; min-width: 160px; background: var(--grid-line); border-radius: 9px; overflow: hidden; }
+        .usage-bar .fill { height: 100%; background: var(--success); }
+        .usage-bar .fill.warning { background: var(--warning); }
+        .usage-bar .fill.danger { background: var(--danger); }
+        .usage-bar span { position: absolute; top: 0; left: 8px; font-size: 12px; line-height: 18px; color: var(--text); }
+        .badge { display: inline-block; padding: 2px 10px; border-radius: 10px; font-size: 12px; background: var(--grid-line); color: var(--text); }
+        .badge.success { color: var(--success); }
+        .badge.danger { color: var(--danger); }
+        .controls input { padding: 8px; border: 1px solid var(--border); border-radius: 4px; background: var(--card-bg); color: var(--text); width: 120px; }
     </style>
 </head>
 <body>
@@ -384,76 +514,139 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         <header>
             <h1>PVE Traffic Monitor</h1>
             <div class="tabs">
-                <button class="tab active" onclick="switchTab('overview')">Overview</button>
-                <button class="tab" onclick="switchTab('charts')">Charts</button>
-                <button class="tab" onclick="showTokenPrompt()" style="background: rgba(255,255,255,0.1);">Token</button>
+                <button class="tab active" data-tab="overview" onclick="switchTab('overview')" data-i18n="nav.overview">Overview</button>
+                <button class="tab" data-tab="charts" onclick="switchTab('charts')" data-i18n="nav.charts">Charts</button>
+                <button class="tab" data-tab="rules" onclick="switchTab('rules')" data-i18n="nav.rules">Rules</button>
+                <button class="tab" data-tab="actions" onclick="switchTab('actions')" data-i18n="nav.actions">Actions</button>
+                <button class="tab" onclick="showTokenPrompt()" style="background: rgba(255,255,255,0.1);" data-i18n="nav.token">Token</button>
+                <button class="tab" id="theme-toggle" onclick="toggleTheme()" style="background: rgba(255,255,255,0.1);" title="Toggle theme" data-i18n-title="theme.toggle">Theme</button>
+                <button class="tab" id="locale-toggle" onclick="toggleLocale()" style="background: rgba(255,255,255,0.1);">EN</button>
             </div>
         </header>
         
         <div id="overview-page" class="page active">
-            <button class="controls button" onclick="loadData()" style="background: #3498db; color: white; border: none; padding: 10px 20px; border-radius: 4px; cursor: pointer; margin-bottom: 20px;">Refresh</button>
+            <button class="btn-primary" onclick="loadData()" style="padding: 10px 20px; margin-bottom: 20px;" data-i18n="common.refresh">Refresh</button>
             
             <div class="stats" id="stats">
                 <div class="stat-card">
-                    <h3>Total VMs</h3>
+                    <h3 data-i18n="stats.total_vms">Total VMs</h3>
                     <div class="value" id="total-vms">-</div>
                 </div>
                 <div class="stat-card">
-                    <h3>Running</h3>
+                    <h3 data-i18n="stats.running">Running</h3>
                     <div class="value" id="running-vms">-</div>
                 </div>
                 <div class="stat-card">
-                    <h3>Today's Traffic</h3>
+                    <h3 data-i18n="stats.today_traffic">Today's Traffic</h3>
                     <div class="value" id="total-traffic">-</div>
                 </div>
                 <div class="stat-card">
-                    <h3>Total Samples</h3>
+                    <h3 data-i18n="stats.total_samples">Total Samples</h3>
                     <div class="value" id="total-samples">-</div>
                 </div>
                 <div class="stat-card">
-                    <h3>API Avg Response</h3>
+                    <h3 data-i18n="stats.api_avg">API Avg Response</h3>
                     <div class="value" id="api-avg-time">-</div>
                 </div>
             </div>
             
             <div class="card">
-                <h2 style="margin-bottom: 20px;">Virtual Machines</h2>
-                <div id="vm-list" class="loading">Loading...</div>
+                <h2 style="margin-bottom: 20px;" data-i18n="vms.title">Virtual Machines</h2>
+                <div id="vm-list" class="loading" data-i18n="common.loading">Loading...</div>
             </div>
         </div>
 
         <div id="charts-page" class="page">
             <div class="controls">
-                <label>Period:</label>
+                <label data-i18n="label.period">Period:</label>
                 <select id="chart-period">
-                    <option value="minute">Current Minute</option>
-                    <option value="hour">Current Hour</option>
-                    <option value="day" selected>Today</option>
-                    <option value="month">Current Month</option>
+                    <option value="minute" data-i18n="period.current_minute">Current Minute</option>
+                    <option value="hour" data-i18n="period.current_hour">Current Hour</option>
+                    <option value="day" selected data-i18n="period.today">Today</option>
+                    <option value="month" data-i18n="period.current_month">Current Month</option>
                 </select>
-                <label>Direction:</label>
+                <label data-i18n="label.direction">Direction:</label>
                 <select id="chart-direction">
-                    <option value="both" selected>Both</option>
-                    <option value="download">Download</option>
-                    <option value="upload">Upload</option>
+                    <option value="both" selected data-i18n="direction.both">Both</option>
+                    <option value="download" data-i18n="direction.download">Download</option>
+                    <option value="upload" data-i18n="direction.upload">Upload</option>
                 </select>
-                <button onclick="loadChartData()">Update Chart</button>
+                <button onclick="loadChartData()" data-i18n="charts.update">Update Chart</button>
             </div>
 
             <div class="card">
-                <h2 style="margin-bottom: 20px;">Traffic Overview</h2>
+                <h2 style="margin-bottom: 20px;" data-i18n="charts.overview">Traffic Overview</h2>
                 <div class="chart-container">
                     <canvas id="overview-chart"></canvas>
                 </div>
             </div>
 
             <div class="card">
-                <h2 style="margin-bottom: 20px;">Top 10 VMs by Traffic</h2>
+                <h2 style="margin-bottom: 20px;" data-i18n="charts.top_vms">Top 10 VMs by Traffic</h2>
                 <div class="chart-container">
                     <canvas id="top-vms-chart"></canvas>
                 </div>
             </div>
         </div>
+
+        <div id="rules-page" class="page">
+            <div id="rules-list" class="loading" data-i18n="common.loading">Loading...</div>
+        </div>
+
+        <div id="actions-page" class="page">
+            <div class="controls">
+                <label data-i18n="actions.range">Range:</label>
+                <select id="action-range">
+                    <option value="1" data-i18n="actions.last_24h">Last 24 Hours</option>
+                    <option value="7" selected data-i18n="actions.last_7d">Last 7 Days</option>
+                    <option value="30" data-i18n="actions.last_30d">Last 30 Days</option>
+                    <option value="90" data-i18n="actions.last_90d">Last 90 Days</option>
+                </select>
+                <label data-i18n="actions.vmid">VM ID:</label>
+                <input id="action-vmid" type="number" min="1" placeholder="All" data-i18n-placeholder="common.all">
+                <label data-i18n="actions.rule">Rule:</label>
+                <select id="action-rule"><option value="" data-i18n="common.all">All</option></select>
+                <label data-i18n="actions.action">Action:</label>
+                <select id="action-type">
+                    <option value="" data-i18n="common.all">All</option>
+                    <option value="shutdown" data-i18n="action.shutdown">Shutdown</option>
+                    <option value="stop" data-i18n="action.stop">Stop</option>
+                    <option value="disconnect" data-i18n="action.disconnect">Disconnect</option>
+                    <option value="rate_limit" data-i18n="action.rate_limit">Rate Limit</option>
+                </select>
+                <label data-i18n="actions.result">Result:</label>
+                <select id="action-success">
+                    <option value="" data-i18n="common.all">All</option>
+                    <option value="true" data-i18n="actions.success">Success</option>
+                    <option value="false" data-i18n="actions.failed">Failed</option>
+                </select>
+                <button onclick="loadActions()" data-i18n="actions.apply">Apply</button>
+            </div>
+
+            <div class="stats">
+                <div class="stat-card">
+                    <h3 data-i18n="actions.total">Total Actions</h3>
+                    <div class="value" id="actions-total">-</div>
+                </div>
+                <div class="stat-card">
+                    <h3 data-i18n="actions.succeeded">Succeeded</h3>
+                    <div class="value" id="actions-succeeded">-</div>
+                </div>
+                <div class="stat-card">
+                    <h3 data-i18n="actions.failed">Failed</h3>
+                    <div class="value" id="actions-failed">-</div>
+                </div>
+                <div class="stat-card">
+                    <h3 data-i18n="actions.top_rule">Most Triggered Rule</h3>
+                    <div class="value" id="actions-top-rule" style="font-size: 20px;">-</div>
+                </div>
+            </div>
+
+            <div class="card">
+                <h2 style="margin-bottom: 20px;" data-i18n="actions.history">Action History</h2>
+                <div id="actions-list" class="loading" data-i18n="common.loading">Loading...</div>
+            </div>
+        </div>
     </div>
 
     <!-- VM Detail Modal -->
@@ -461,17 +654,18 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         <div class="modal-content" style="width: 90%; max-width: 1200px;">
             <div class="modal-header">
                 <h2 id="modal-title">VM Details</h2>
-                <button class="modal-close" onclick="closeModal()">Close</button>
+                <button class="modal-close" onclick="closeModal()" data-i18n="common.close">Close</button>
             </div>
             <div class="controls" style="margin-bottom: 20px;">
-                <label>Period:</label>
+                <label data-i18n="label.period">Period:</label>
                 <select id="vm-detail-period">
-                    <option value="minute">Minute (Last Hour)</option>
-                    <option value="hour">Hour</option>
-                    <option value="day" selected>Day</option>
-                    <option value="month">Month</option>
+                    <option value="minute" data-i18n="period.minute_last_hour">Minute (Last Hour)</option>
+                    <option value="hour" data-i18n="period.hour">Hour</option>
+                    <option value="day" selected data-i18n="period.day">Day</option>
+                    <option value="month" data-i18n="period.month">Month</option>
                 </select>
-                <button onclick="reloadVMDetail()">Update</button>
+                <button onclick="reloadVMDetail()" data-i18n="common.update">Update</button>
+                <button onclick="shareVM()" data-i18n="vm.share">Share</button>
             </div>
             <div class="chart-container" style="height: 400px;">
                 <canvas id="vm-detail-chart"></canvas>
@@ -485,6 +679,141 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         let topVMsChart = null;
         let vmDetailChart = null;
 
+        // 多语言（默认语言由服务端根据 Accept-Language 和配置协商，用户选择保存在 localStorage）
+        const I18N_CONFIG = /*__I18N_CONFIG__*/null;
+
+        function currentLocale() {
+            const saved = localStorage.getItem('locale');
+            if (I18N_CONFIG && I18N_CONFIG.messages[saved]) {
+                return saved;
+            }
+            return I18N_CONFIG ? I18N_CONFIG.default : 'en-US';
+        }
+
+        // t 翻译界面文本，按顺序替换 %s/%d 参数
+        function t(key, ...args) {
+            const messages = I18N_CONFIG ? I18N_CONFIG.messages[currentLocale()] : null;
+            let text = (messages && messages[key]) || key;
+            args.forEach(arg => { text = text.replace(/%[sd]/, arg); });
+            return text;
+        }
+
+        function applyI18n() {
+            document.documentElement.lang = currentLocale();
+            document.querySelectorAll('[data-i18n]').forEach(el => { el.textContent = t(el.dataset.i18n); });
+            document.querySelectorAll('[data-i18n-title]').forEach(el => { el.title = t(el.dataset.i18nTitle); });
+            document.querySelectorAll('[data-i18n-placeholder]').forEach(el => { el.placeholder = t(el.dataset.i18nPlaceholder); });
+            document.getElementById('locale-toggle').textContent = t('lang.switch');
+        }
+
+        function toggleLocale() {
+            const locales = I18N_CONFIG ? Object.keys(I18N_CONFIG.messages).sort() : [];
+            if (locales.length === 0) return;
+            localStorage.setItem('locale', locales[(locales.indexOf(currentLocale()) + 1) % locales.length]);
+            applyI18n();
+            applyTheme(document.documentElement.dataset.theme || 'light');
+            const active = document.querySelector('.tab.active');
+            switchTab(active ? active.dataset.tab : 'overview');
+            if (document.getElementById('vm-modal').classList.contains('active')) {
+                reloadVMDetail();
+            }
+        }
+
+        applyI18n();
+
+        // 主题管理（默认值由服务端 api.theme 提供，用户选择保存在 localStorage）
+        const THEME_CONFIG = /*__THEME_CONFIG__*/null;
+        const darkMedia = window.matchMedia('(prefers-color-scheme: dark)');
+
+        function resolveTheme() {
+            const saved = localStorage.getItem('theme');
+            const preference = (saved === 'light' || saved === 'dark') ? saved : (THEME_CONFIG && THEME_CONFIG.default) || 'auto';
+            if (preference === 'auto') {
+                return darkMedia.matches ? 'dark' : 'light';
+            }
+            return preference;
+        }
+
+        function themePalette() {
+            const theme = document.documentElement.dataset.theme || 'light';
+            return THEME_CONFIG ? THEME_CONFIG[theme] : null;
+        }
+
+        function hexToRgba(hex, alpha) {
+            const value = parseInt(hex.slice(1), 16);
+            return 'rgba(' + ((value >> 16) & 255) + ', ' + ((value >> 8) & 255) + ', ' + (value & 255) + ', ' + alpha + ')';
+        }
+
+        function applyTheme(theme) {
+            document.documentElement.dataset.theme = theme;
+            document.getElementById('theme-toggle').textContent = theme === 'dark' ? t('theme.light') : t('theme.dark');
+
+            const palette = themePalette();
+            if (palette && window.Chart) {
+                Chart.defaults.color = palette.chart.text_color;
+                Chart.defaults.borderColor = palette.chart.split_line;
+            }
+        }
+
+        function toggleTheme() {
+            const next = document.documentElement.dataset.theme === 'dark' ? 'light' : 'dark';
+            localStorage.setItem('theme', next);
+            applyTheme(next);
+            redrawCharts();
+        }
+
+        // 重新绘制已显示的图表以应用新配色
+        function redrawCharts() {
+            if (document.getElementById('charts-page').classList.contains('active')) {
+                loadChartData();
+            }
+            if (document.getElementById('vm-modal').classList.contains('active')) {
+                reloadVMDetail();
+            }
+        }
+
+        // 未手动选择主题时跟随系统变化
+        darkMedia.addEventListener('change', () => {
+            if (!localStorage.getItem('theme')) {
+                applyTheme(resolveTheme());
+                redrawCharts();
+            }
+        });
+
+        applyTheme(resolveTheme());
+
+        // 折线图的下载/上传/总计数据集（配色与导出图表一致）
+        function trafficDatasets(rxData, txData, totalData) {
+            const palette = themePalette();
+            const colors = palette ? palette.chart : { download: '#36a2eb', upload: '#4bc0c0', total: '#ff6384' };
+            return [{
+                label: t('charts.download_gb'),
+                data: rxData,
+                borderColor: colors.download,
+                backgroundColor: hexToRgba(colors.download, 0.1),
+                tension: 0.2,
+                fill: true,
+                pointRadius: 2
+            }, {
+                label: t('charts.upload_gb'),
+                data: txData,
+                borderColor: colors.upload,
+                backgroundColor: hexToRgba(colors.upload, 0.1),
+                tension: 0.2,
+                fill: true,
+                pointRadius: 2
+            }, {
+                label: t('charts.total_gb'),
+                data: totalData,
+                borderColor: colors.total,
+                backgroundColor: hexToRgba(colors.total, 0.1),
+                tension: 0.2,
+                fill: false,
+                borderWidth: 2,
+                pointRadius: 3
+            }];
+        }
+
         // Token 管理
         function getApiToken() {
             return localStorage.getItem('api_token') || '';
@@ -499,7 +828,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         }
 
         function showTokenPrompt() {
-            const token = prompt('Enter API Token (leave empty for no auth):', getApiToken());
+            const token = prompt(t('token.prompt'), getApiToken());
             if (token !== null) {
                 setApiToken(token);
                 loadData();
@@ -532,17 +861,164 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         function switchTab(tab) {
             document.querySelectorAll('.tab').forEach(t => t.classList.remove('active'));
             document.querySelectorAll('.page').forEach(p => p.classList.remove('active'));
-            
+
+            document.querySelector('.tab[data-tab="' + tab + '"]').classList.add('active');
+            document.getElementById(tab + '-page').classList.add('active');
+
             if (tab === 'overview') {
-                document.querySelector('.tab:nth-child(1)').classList.add('active');
-                document.getElementById('overview-page').classList.add('active');
                 loadData();
             } else if (tab === 'charts') {
-                document.querySelector('.tab:nth-child(2)').classList.add('active');
-                document.getElementById('charts-page').classList.add('active');
                 loadChartData();
+            } else if (tab === 'rules') {
+                loadRules();
+            } else if (tab === 'actions') {
+                loadActions();
+            }
+        }
+
+        function escapeHtml(value) {
+            return String(value === undefined || value === null ? '' : value)
+                .replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+        }
+
+        function statusBadge(status) {
+            return '<span class="status ' + escapeHtml(status) + '">' + (status === 'running' ? t('status.running') : t('status.stopped')) + '</span>';
+        }
+
+        function usageBar(percent, label) {
+            const level = percent >= 100 ? 'danger' : (percent >= 80 ? 'warning' : '');
+            return '<div class="usage-bar"><div class="fill ' + level + '" style="width: ' + Math.min(percent, 100).toFixed(1) + '%;"></div>' +
+                '<span>' + escapeHtml(label) + '</span></div>';
+        }
+
+        async function loadRules() {
+            try {
+                const response = await apiFetch('/api/rules/usage');
+                const data = await response.json();
+                if (data.success) {
+                    renderRules(data.data || []);
+                }
+            } catch (error) {
+                console.error('Failed to load rules:', error);
+                document.getElementById('rules-list').innerHTML = '<p class="error">' + t('rules.load_failed') + '</p>';
             }
         }
+
+        function renderRules(usages) {
+            if (usages.length === 0) {
+                document.getElementById('rules-list').innerHTML = '<div class="card loading">' + t('rules.none') + '</div>';
+                return;
+            }
+
+            const html = usages.map(usage => {
+                const rule = usage.rule;
+                const isRate = rule.type === 'rate';
+                const direction = t('direction.' + (rule.traffic_direction || 'both'));
+                const limit = isRate ? rule.rate_threshold_mbps + ' Mbps (' + t('rules.avg', rule.rate_window_minutes || 5) + ')' : rule.limit_gb + ' GB / ' + t('period.' + rule.period);
+                const meta = [isRate ? t('rules.type_rate') : t('rules.type_volume'), direction, limit, t('action.' + rule.action), rule.enabled ? t('rules.vms', usage.vm_count) : t('rules.disabled')];
+                if (usage.exceeded_count > 0) {
+                    meta.push(t('rules.exceeded', usage.exceeded_count));
+                }
+
+                const rows = usage.vms.map(vm => {
+                    const label = isRate
+                        ? (vm.rate_mbps || 0).toFixed(2) + ' / ' + rule.rate_threshold_mbps + ' Mbps'
+                        : formatBytes(vm.used_bytes || 0) + ' / ' + formatBytes(vm.limit_bytes || 0);
+                    return '<tr class="clickable" onclick="showVMDetail(' + vm.vmid + ', \'' + escapeHtml(vm.name) + '\')">' +
+                        '<td>' + vm.vmid + '</td>' +
+                        '<td>' + escapeHtml(vm.name) + '</td>' +
+                        '<td>' + statusBadge(vm.status) + '</td>' +
+                        '<td style="width: 50%;">' + (vm.error ? '<span class="error">' + escapeHtml(vm.error) + '</span>' : usageBar(vm.percent, label + ' (' + vm.percent.toFixed(1) + '%)')) + '</td>' +
+                    '</tr>';
+                }).join('');
+
+                return '<div class="card">' +
+                    '<div class="rule-header"><h2>' + escapeHtml(rule.name) + '</h2><span class="rule-meta">' + escapeHtml(meta.join(' · ')) + '</span></div>' +
+                    (rows ? '<table><thead><tr><th>' + t('col.id') + '</th><th>' + t('col.name') + '</th><th>' + t('col.status') + '</th><th>' + t('col.utilization') + '</th></tr></thead><tbody>' + rows + '</tbody></table>'
+                          : '<div class="loading">' + t('rules.no_vms') + '</div>') +
+                '</div>';
+            }).join('');
+
+            document.getElementById('rules-list').innerHTML = html;
+            updateRuleFilter(usages.map(u => u.rule.name));
+        }
+
+        function updateRuleFilter(names) {
+            const select = document.getElementById('action-rule');
+            const current = select.value;
+            select.innerHTML = '<option value="">' + t('common.all') + '</option>' +
+                names.map(name => '<option value="' + escapeHtml(name) + '">' + escapeHtml(name) + '</option>').join('');
+            select.value = current;
+        }
+
+        function actionQuery() {
+            const params = new URLSearchParams();
+            const days = parseInt(document.getElementById('action-range').value, 10);
+            const end = new Date();
+            params.set('start', new Date(end.getTime() - days * 24 * 3600 * 1000).toISOString().replace(/\.\d{3}Z$/, 'Z'));
+            params.set('end', end.toISOString().replace(/\.\d{3}Z$/, 'Z'));
+
+            const filters = { vmid: 'action-vmid', rule: 'action-rule', action: 'action-type', success: 'action-success' };
+            Object.keys(filters).forEach(key => {
+                const value = document.getElementById(filters[key]).value;
+                if (value) params.set(key, value);
+            });
+            return params.toString();
+        }
+
+        async function loadActions() {
+            const query = actionQuery();
+            try {
+                if (document.getElementById('action-rule').options.length <= 1) {
+                    const rulesResp = await apiFetch('/api/rules');
+                    const rulesData = await rulesResp.json();
+                    if (rulesData.success) updateRuleFilter((rulesData.data || []).map(r => r.name));
+                }
+
+                const [summaryResp, logsResp] = await Promise.all([
+                    apiFetch('/api/actions/summary?' + query),
+                    apiFetch('/api/logs?' + query)
+                ]);
+                const summaryData = await summaryResp.json();
+                const logsData = await logsResp.json();
+
+                if (summaryData.success) {
+                    const summary = summaryData.data;
+                    document.getElementById('actions-total').textContent = summary.total;
+                    document.getElementById('actions-succeeded').textContent = summary.succeeded;
+                    document.getElementById('actions-failed').textContent = summary.failed;
+                    const topRule = Object.keys(summary.by_rule || {}).sort((a, b) => summary.by_rule[b] - summary.by_rule[a])[0];
+                    document.getElementById('actions-top-rule').textContent = topRule ? topRule + ' (' + summary.by_rule[topRule] + ')' : '-';
+                }
+                if (logsData.success) {
+                    renderActions(logsData.data || []);
+                }
+            } catch (error) {
+                console.error('Failed to load actions:', error);
+                document.getElementById('actions-list').innerHTML = '<p class="error">' + t('actions.load_failed') + '</p>';
+            }
+        }
+
+        function renderActions(logs) {
+            if (logs.length === 0) {
+                document.getElementById('actions-list').innerHTML = '<div class="loading">' + t('actions.none') + '</div>';
+                return;
+            }
+
+            const headers = ['col.time', 'col.vm', 'col.rule', 'col.action', 'col.reason', 'col.result'];
+            const html = '<table><thead><tr>' + headers.map(h => '<th>' + t(h) + '</th>').join('') + '</tr></thead><tbody>' +
+                logs.map(log => '<tr>' +
+                    '<td>' + new Date(log.timestamp).toLocaleString(currentLocale()) + '</td>' +
+                    '<td>' + log.vmid + '</td>' +
+                    '<td>' + escapeHtml(log.rule_name) + '</td>' +
+                    '<td>' + t('action.' + log.action) + '</td>' +
+                    '<td>' + escapeHtml(log.reason) + '</td>' +
+                    '<td>' + (log.success ? '<span class="badge success">' + t('actions.success') + '</span>' : '<span class="badge danger" title="' + escapeHtml(log.error) + '">' + t('actions.failed') + '</span>') + '</td>' +
+                '</tr>').join('') +
+                '</tbody></table>';
+
+            document.getElementById('actions-list').innerHTML = html;
+        }
         
         async function loadData() {
             try {
@@ -556,7 +1032,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 }
             } catch (error) {
                 console.error('Failed to load data:', error);
-                document.getElementById('vm-list').innerHTML = '<p style="color: red;">Failed to load data</p>';
+                document.getElementById('vm-list').innerHTML = '<p class="error">' + t('common.load_failed') + '</p>';
             }
         }
         
@@ -606,35 +1082,25 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 console.error('Failed to update stats:', error);
                 document.getElementById('total-vms').textContent = total;
                 document.getElementById('running-vms').textContent = running;
-                document.getElementById('total-traffic').textContent = 'Loading...';
+                document.getElementById('total-traffic').textContent = t('common.loading');
                 document.getElementById('total-samples').textContent = '-';
                 document.getElementById('api-avg-time').textContent = '-';
             }
         }
         
         function renderVMs(vms) {
-            // 按VM ID排序（升序），确保每次显示顺序一致
-            const sortedVMs = [...vms].sort((a, b) => a.vmid - b.vmid);
-            
-            const html = '<table><thead><tr><th onclick="sortVMs(\'vmid\')">ID ▲</th><th onclick="sortVMs(\'name\')">Name</th><th onclick="sortVMs(\'status\')">Status</th><th>Matched Rules</th><th onclick="sortVMs(\'download\')">Download</th><th onclick="sortVMs(\'upload\')">Upload</th><th onclick="sortVMs(\'total\')">Total</th><th>Action</th></tr></thead><tbody>' +
-                sortedVMs.map(vm => '<tr class="clickable">' +
-                    '<td>' + vm.vmid + '</td>' +
-                    '<td>' + vm.name + '</td>' +
-                    '<td><span class="status ' + vm.status + '">' + (vm.status === 'running' ? 'Running' : 'Stopped') + '</span></td>' +
-                    '<td>' + (vm.matched_rules || []).join(', ') + '</td>' +
-                    '<td class="traffic">' + formatBytes(vm.netrx || 0) + '</td>' +
-                    '<td class="traffic">' + formatBytes(vm.nettx || 0) + '</td>' +
-                    '<td class="traffic"><strong>' + formatBytes((vm.netrx || 0) + (vm.nettx || 0)) + '</strong></td>' +
-                    '<td><button onclick="showVMDetail(' + vm.vmid + ', \'' + vm.name + '\'); event.stopPropagation();" style="padding: 4px 12px; background: #3498db; color: white; border: none; border-radius: 4px; cursor: pointer;">Details</button></td>' +
-                '</tr>').join('') +
-                '</tbody></table>';
-            
-            document.getElementById('vm-list').innerHTML = html;
+            // 按当前排序字段显示（默认按VM ID升序），确保每次显示顺序一致
+            renderSortedVMs();
         }
 
         let currentSort = { field: 'vmid', order: 'asc' };
         let cachedVMs = [];
 
+        function sortHeader(field, label) {
+            const arrow = currentSort.field === field ? (currentSort.order === 'asc' ? ' ▲' : ' ▼') : '';
+            return '<th onclick="sortVMs(\'' + field + '\')" style="cursor: pointer;">' + t(label) + arrow + '</th>';
+        }
+
         function sortVMs(field) {
             if (currentSort.field === field) {
                 currentSort.order = currentSort.order === 'asc' ? 'desc' : 'asc';
@@ -643,6 +1109,11 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 currentSort.order = 'asc';
             }
 
+            renderSortedVMs();
+        }
+
+        function renderSortedVMs() {
+            const field = currentSort.field;
             const sorted = [...cachedVMs].sort((a, b) => {
                 let valA, valB;
                 switch(field) {
@@ -686,24 +1157,24 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 
         function renderVMsFromCache(vms) {
             const html = '<table><thead><tr>' +
-                '<th onclick="sortVMs(\'vmid\')" style="cursor: pointer;">ID ' + (currentSort.field === 'vmid' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th onclick="sortVMs(\'name\')" style="cursor: pointer;">Name ' + (currentSort.field === 'name' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th onclick="sortVMs(\'status\')" style="cursor: pointer;">Status ' + (currentSort.field === 'status' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th>Matched Rules</th>' +
-                '<th onclick="sortVMs(\'download\')" style="cursor: pointer;">Download ' + (currentSort.field === 'download' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th onclick="sortVMs(\'upload\')" style="cursor: pointer;">Upload ' + (currentSort.field === 'upload' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th onclick="sortVMs(\'total\')" style="cursor: pointer;">Total ' + (currentSort.field === 'total' ? (currentSort.order === 'asc' ? '▲' : '▼') : '') + '</th>' +
-                '<th>Action</th>' +
+                sortHeader('vmid', 'col.id') +
+                sortHeader('name', 'col.name') +
+                sortHeader('status', 'col.status') +
+                '<th>' + t('col.matched_rules') + '</th>' +
+                sortHeader('download', 'col.download') +
+                sortHeader('upload', 'col.upload') +
+                sortHeader('total', 'col.total') +
+                '<th>' + t('col.action') + '</th>' +
                 '</tr></thead><tbody>' +
                 vms.map(vm => '<tr class="clickable">' +
                     '<td>' + vm.vmid + '</td>' +
                     '<td>' + vm.name + '</td>' +
-                    '<td><span class="status ' + vm.status + '">' + (vm.status === 'running' ? 'Running' : 'Stopped') + '</span></td>' +
-                    '<td>' + (vm.matched_rules || []).join(', ') + '</td>' +
+                    '<td>' + statusBadge(vm.status) + '</td>' +
+                    '<td>' + (vm.matched_rules || []).map(rule => rule === vm.conflict_winner ? '<b>' + rule + '</b>' : rule).join(', ') + '</td>' +
                     '<td class="traffic">' + formatBytes(vm.netrx || 0) + '</td>' +
                     '<td class="traffic">' + formatBytes(vm.nettx || 0) + '</td>' +
                     '<td class="traffic"><strong>' + formatBytes((vm.netrx || 0) + (vm.nettx || 0)) + '</strong></td>' +
-                    '<td><button onclick="showVMDetail(' + vm.vmid + ', \'' + vm.name + '\'); event.stopPropagation();" style="padding: 4px 12px; background: #3498db; color: white; border: none; border-radius: 4px; cursor: pointer;">Details</button></td>' +
+                    '<td><button onclick="showVMDetail(' + vm.vmid + ', \'' + vm.name + '\'); event.stopPropagation();" class="btn-primary" style="padding: 4px 12px;">' + t('common.details') + '</button></td>' +
                 '</tr>').join('') +
                 '</tbody></table>';
             
@@ -744,32 +1215,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 type: 'line',
                 data: {
                     labels: labels,
-                    datasets: [{
-                        label: 'Download (GB)',
-                        data: rxData,
-                        borderColor: 'rgb(54, 162, 235)',
-                        backgroundColor: 'rgba(54, 162, 235, 0.1)',
-                        tension: 0.2,
-                        fill: true,
-                        pointRadius: 2
-                    }, {
-                        label: 'Upload (GB)',
-                        data: txData,
-                        borderColor: 'rgb(75, 192, 192)',
-                        backgroundColor: 'rgba(75, 192, 192, 0.1)',
-                        tension: 0.2,
-                        fill: true,
-                        pointRadius: 2
-                    }, {
-                        label: 'Total (GB)',
-                        data: totalData,
-                        borderColor: 'rgb(255, 99, 132)',
-                        backgroundColor: 'rgba(255, 99, 132, 0.1)',
-                        tension: 0.2,
-                        fill: false,
-                        borderWidth: 2,
-                        pointRadius: 3
-                    }]
+                    datasets: trafficDatasets(rxData, txData, totalData)
                 },
                 options: {
                     responsive: true,
@@ -779,7 +1225,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                         intersect: false
                     },
                     plugins: {
-                        title: { display: true, text: 'All VMs Traffic' },
+                        title: { display: true, text: t('charts.all_vms') },
                         legend: { position: 'top' },
                         tooltip: {
                             callbacks: {
@@ -791,7 +1237,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                         }
                     },
                     scales: {
-                        y: { beginAtZero: true, title: { display: true, text: 'Traffic (GB)' } }
+                        y: { beginAtZero: true, title: { display: true, text: t('charts.traffic_gb') } }
                     }
                 }
             });
@@ -803,6 +1249,8 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             const sorted = [...stats].sort((a, b) => b.total_bytes - a.total_bytes).slice(0, 10);
             const labels = sorted.map(s => 'VM' + s.vmid + ' (' + s.name + ')');
             const data = sorted.map(s => s.total_bytes / (1024 * 1024 * 1024)); // 转换为GB显示
+            const palette = themePalette();
+            const barColor = palette ? palette.colors.danger : '#e74c3c';
             
             if (topVMsChart) topVMsChart.destroy();
             
@@ -811,10 +1259,10 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 data: {
                     labels: labels,
                     datasets: [{
-                        label: 'Total Traffic (GB)',
+                        label: t('charts.total_traffic_gb'),
                         data: data,
-                        backgroundColor: 'rgba(231, 76, 60, 0.8)',
-                        borderColor: 'rgb(231, 76, 60)',
+                        backgroundColor: hexToRgba(barColor, 0.8),
+                        borderColor: barColor,
                         borderWidth: 1
                     }]
                 },
@@ -822,19 +1270,19 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                     responsive: true,
                     maintainAspectRatio: false,
                     plugins: {
-                        title: { display: true, text: 'Top 10 VMs by Traffic' },
+                        title: { display: true, text: t('charts.top_vms') },
                         legend: { display: false },
                         tooltip: {
                             callbacks: {
                                 label: function(context) {
                                     const bytes = context.parsed.y * 1024 * 1024 * 1024;
-                                    return 'Total Traffic: ' + formatBytes(bytes);
+                                    return t('charts.total_traffic') + ': ' + formatBytes(bytes);
                                 }
                             }
                         }
                     },
                     scales: {
-                        y: { beginAtZero: true, title: { display: true, text: 'Traffic (GB)' } }
+                        y: { beginAtZero: true, title: { display: true, text: t('charts.traffic_gb') } }
                     }
                 }
             });
@@ -882,9 +1330,9 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 
             if (!historyPoints || historyPoints.length === 0) {
                 ctx.font = '16px sans-serif';
-                ctx.fillStyle = '#7f8c8d';
+                ctx.fillStyle = getComputedStyle(document.documentElement).getPropertyValue('--muted').trim() || '#7f8c8d';
                 ctx.textAlign = 'center';
-                ctx.fillText('No data available', ctx.canvas.width / 2, ctx.canvas.height / 2);
+                ctx.fillText(t('charts.no_data'), ctx.canvas.width / 2, ctx.canvas.height / 2);
                 return;
             }
 
@@ -902,11 +1350,11 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             if (avgBytes < 1024 * 1024 * 100) { // 小于100MB，用MB
                 divisor = 1024 * 1024;
                 unit = 'MB';
-                yAxisLabel = 'Traffic Usage (MB)';
+                yAxisLabel = t('charts.usage_mb');
             } else { // 否则用GB
                 divisor = 1024 * 1024 * 1024;
                 unit = 'GB';
-                yAxisLabel = 'Traffic Usage (GB)';
+                yAxisLabel = t('charts.usage_gb');
             }
             
             rxData = historyPoints.map(p => p.rx_bytes / divisor);
@@ -917,32 +1365,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 type: 'line',
                 data: {
                     labels: labels,
-                    datasets: [{
-                        label: 'Download (GB)',
-                        data: rxData,
-                        borderColor: 'rgb(54, 162, 235)',
-                        backgroundColor: 'rgba(54, 162, 235, 0.1)',
-                        tension: 0.2,
-                        fill: true,
-                        pointRadius: 2
-                    }, {
-                        label: 'Upload (GB)',
-                        data: txData,
-                        borderColor: 'rgb(75, 192, 192)',
-                        backgroundColor: 'rgba(75, 192, 192, 0.1)',
-                        tension: 0.2,
-                        fill: true,
-                        pointRadius: 2
-                    }, {
-                        label: 'Total (GB)',
-                        data: totalData,
-                        borderColor: 'rgb(255, 99, 132)',
-                        backgroundColor: 'rgba(255, 99, 132, 0.1)',
-                        tension: 0.2,
-                        fill: false,
-                        borderWidth: 2,
-                        pointRadius: 3
-                    }]
+                    datasets: trafficDatasets(rxData, txData, totalData)
                 },
                 options: {
                     responsive: true,
@@ -952,7 +1375,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                         intersect: false
                     },
                     plugins: {
-                        title: { display: true, text: 'Traffic Usage Pattern' },
+                        title: { display: true, text: t('charts.usage_pattern') },
                         legend: { position: 'top' },
                         tooltip: {
                             callbacks: {
@@ -966,7 +1389,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                     scales: {
                         x: {
                             display: true,
-                            title: { display: true, text: 'Time' },
+                            title: { display: true, text: t('charts.time') },
                             ticks: {
                                 maxRotation: 45,
                                 minRotation: 45,
@@ -983,11 +1406,12 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         }
 
         function renderVMDetailStats(stats) {
-            const html = '<table><thead><tr><th>Period</th><th>Download</th><th>Upload</th><th>Total</th></tr></thead><tbody>' +
+            const headers = ['col.period', 'col.download', 'col.upload', 'col.total'];
+            const html = '<table><thead><tr>' + headers.map(h => '<th>' + t(h) + '</th>').join('') + '</tr></thead><tbody>' +
                 Object.keys(stats).map(period => {
                     const s = stats[period];
                     return '<tr>' +
-                        '<td style="text-transform: capitalize;">' + period + '</td>' +
+                        '<td>' + t('period.' + period) + '</td>' +
                         '<td>' + formatBytes(s.rx_bytes || 0) + '</td>' +
                         '<td>' + formatBytes(s.tx_bytes || 0) + '</td>' +
                         '<td><strong>' + formatBytes(s.total_bytes || 0) + '</strong></td>' +
@@ -998,6 +1422,22 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             document.getElementById('vm-detail-stats').innerHTML = html;
         }
 
+        // 生成只读公开状态页链接（需要配置 api.public_secret）
+        async function shareVM() {
+            if (!currentVMID) return;
+            try {
+                const response = await apiFetch('/api/public-link?vmid=' + currentVMID);
+                const result = await response.json();
+                if (!result.success) {
+                    alert(result.error);
+                    return;
+                }
+                prompt(t('vm.share_prompt'), result.data.url);
+            } catch (error) {
+                console.error('Failed to create public link:', error);
+            }
+        }
+
         function closeModal() {
             document.getElementById('vm-modal').classList.remove('active');
         }
@@ -1010,7 +1450,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         // Auto refresh
         setInterval(() => {
             const currentTab = document.querySelector('.tab.active');
-            if (currentTab && currentTab.textContent === 'Overview') {
+            if (currentTab && currentTab.dataset.tab === 'overview') {
                 loadData();
             }
         }, 30000);
@@ -1022,45 +1462,66 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 </html>`
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write([]byte(html))
+	s.setContentSecurityPolicy(w)
+	w.Write([]byte(s.renderIndexI18n(s.renderIndexTheme(html), r)))
 }
 
-// handleVMs 获取所有虚拟机
+// handleVMs 获取所有虚拟机（支持分页、排序和字段选择）
 func (s *Server) handleVMs(w http.ResponseWriter, r *http.Request) {
-	vms, err := s.pveClient.GetAllVMs()
+	listQuery, err := parseListQuery(r)
 	if err != nil {
-		s.sendError(w, "获取虚拟机列表失败: "+err.Error(), http.StatusInternalServerError)
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+
+	vms, err := s.allVMs(false)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
 		return
 	}
 
 	// 应用规则匹配（统一在一处完成）
-	vmsWithRules := pve.ApplyRulesToVMs(vms, s.config.Rules)
+	vmsWithRules := pve.ApplyRulesToVMs(s.scopeVMs(r, vms), s.config.Rules, s.config.Monitor.ConflictResolution())
 
-	s.sendJSON(w, map[string]interface{}{
-		"success": true,
-		"data":    vmsWithRules,
-	})
+	s.sendList(w, r, vmsWithRules, listQuery, nil)
 }
 
 // handleVM 获取单个虚拟机信息
 func (s *Server) handleVM(w http.ResponseWriter, r *http.Request) {
-	vmidStr := r.URL.Path[len("/api/vm/"):]
+	vmidStr, sub, _ := strings.Cut(r.URL.Path[len("/api/vm/"):], "/")
 	vmid, err := strconv.Atoi(vmidStr)
-	if err != nil {
-		s.sendError(w, "无效的虚拟机 ID", http.StatusBadRequest)
+	if err != nil || (sub != "" && sub != "stats" && sub != "enforcement" && sub != "power") {
+		s.sendError(w, s.tr(r, "api.invalid_vmid"), http.StatusBadRequest)
 		return
 	}
 
-	vm, err := s.pveClient.GetVMStatus(vmid)
+	if !s.checkVMAccess(w, r, vmid) {
+		return
+	}
+
+	switch sub {
+	case "stats":
+		s.handleVMProfile(w, r, vmid)
+		return
+	case "enforcement":
+		s.handleVMEnforcement(w, r, vmid)
+		return
+	case "power":
+		s.handleVMPower(w, r, vmid)
+		return
+	}
+
+	vm, err := s.vmStatus(vmid)
 	if err != nil {
-		s.sendError(w, "获取虚拟机信息失败: "+err.Error(), http.StatusInternalServerError)
+		s.sendError(w, s.tr(r, "api.get_vm_failed", err), http.StatusInternalServerError)
 		return
 	}
+	vm.Tenant = s.tenants.TenantOf(*vm)
 
 	// 获取流量统计（包含上传/下载分别统计）
 	stats := make(map[string]interface{})
 	for _, period := range []string{"hour", "day", "month"} {
-		stat, err := s.storage.CalculateTrafficStatsWithDirection(vmid, period, time.Time{}, false, "both")
+		stat, err := s.stats.Calculate(vmid, period, time.Time{}, false, "both")
 		if err == nil {
 			stats[period] = map[string]interface{}{
 				"total_bytes": stat.TotalBytes,
@@ -1075,14 +1536,207 @@ func (s *Server) handleVM(w http.ResponseWriter, r *http.Request) {
 	s.sendJSON(w, map[string]interface{}{
 		"success": true,
 		"data": map[string]interface{}{
-			"vm":    vm,
-			"stats": stats,
+			"vm":      vm,
+			"stats":   stats,
+			"periods": s.rulePeriods(*vm),
+			"rules":   s.ruleEvaluations(*vm, time.Now()),
 		},
 	})
 }
 
-// handleStats 获取统计信息
+// handleVMProfile 虚拟机当前周期的 95 百分位带宽、峰值小时和最忙的一天
+// GET /api/vm/{vmid}/stats?period=month&direction=both
+func (s *Server) handleVMProfile(w http.ResponseWriter, r *http.Request, vmid int) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodMonth
+	}
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+
+	switch period {
+	case models.PeriodHour, models.PeriodDay, models.PeriodMonth:
+	default:
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().In(periodcalc.Location())
+	startTime := periodcalc.NewCalculator(period, time.Time{}, false).PeriodStartAt(now)
+	records, err := s.storage.GetTrafficRecords(vmid, startTime, now)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    storage.UsageProfileOf(vmid, period, startTime, now, direction, records),
+	})
+}
+
+// RulePeriod 匹配规则在虚拟机上生效的当前周期窗口
+type RulePeriod struct {
+	Rule           string     `json:"rule"`
+	Period         string     `json:"period"`
+	Basis          string     `json:"basis"`                     // calendar, creation_time, anchor, rolling
+	CreationTime   *time.Time `json:"creation_time,omitempty"`   // basis=creation_time 时的基准时间
+	CreationSource string     `json:"creation_source,omitempty"` // 创建时间来源：override, meta, task, traffic
+	Start          time.Time  `json:"start"`
+	End            time.Time  `json:"end"` // 下一个周期开始（滑动窗口为当前时间）
+}
+
+// rulePeriods 计算虚拟机匹配的启用规则的当前周期窗口（与监控判断限额使用的窗口一致）
+func (s *Server) rulePeriods(vm models.VMInfo) []RulePeriod {
+	periods := []RulePeriod{}
+	var created creation.Result
+	var creationLoaded bool
+
+	now := time.Now()
+	for _, rule := range s.config.Rules {
+		if !rule.Enabled || rule.Period == "" || !pve.VMMatchesRule(vm, rule) {
+			continue
+		}
+
+		if rule.UseCreationTime && !creationLoaded {
+			created, _ = s.creation.Resolve(vm.VMID)
+			creationLoaded = true
+		}
+
+		ct := time.Time{}
+		if rule.UseCreationTime {
+			ct = created.Time
+		}
+		calc := periodcalc.ForRule(rule, ct)
+		period := RulePeriod{
+			Rule:   rule.Name,
+			Period: rule.Period,
+			Basis:  calc.Basis(),
+			Start:  calc.PeriodStartAt(now),
+			End:    calc.NextPeriodStartAt(now),
+		}
+		if _, rolling := calc.Rolling(); rolling {
+			period.End = now.In(period.Start.Location())
+		}
+		if period.Basis == periodcalc.BasisCreationTime {
+			period.CreationTime = &ct
+			period.CreationSource = created.Source
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}
+
+// 规则评估状态
+const (
+	RuleStatusOK       = "ok"
+	RuleStatusWarning  = "warning" // 使用率达到 events.warn_percent
+	RuleStatusExceeded = "exceeded"
+	RuleStatusError    = "error"
+)
+
+// RuleEvaluation 匹配规则在虚拟机上的当前用量（流量规则单位为 GB，带宽规则为 Mbps）
+type RuleEvaluation struct {
+	Rule      string    `json:"rule"`
+	Type      string    `json:"type"`
+	Action    string    `json:"action"`
+	Direction string    `json:"direction"`
+	Period    string    `json:"period,omitempty"`
+	Start     time.Time `json:"start"` // 统计窗口开始（带宽规则为当前时间减去 rate_window）
+	End       time.Time `json:"end"`   // 下一个周期开始（滑动窗口和带宽规则为当前时间）
+	Used      float64   `json:"used"`
+	Limit     float64   `json:"limit"`
+	Unit      string    `json:"unit"`
+	Percent   float64   `json:"percent"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ruleEvaluations 计算虚拟机匹配的启用规则的当前用量（与 /api/rules/usage 相同，流量统计走统计缓存）
+func (s *Server) ruleEvaluations(vm models.VMInfo, now time.Time) []RuleEvaluation {
+	getCreationTime := s.creationTimeLookup()
+	warn := float64(s.config.Events.WarnThreshold())
+
+	evaluations := []RuleEvaluation{}
+	for _, rule := range s.config.Rules {
+		if !rule.Enabled || rule.IsHostRule() || !pve.VMMatchesRule(vm, rule) {
+			continue
+		}
+
+		usage := s.calculateRuleVMUsage(vm, rule, getCreationTime)
+		eval := RuleEvaluation{
+			Rule:      rule.Name,
+			Type:      rule.Type,
+			Action:    rule.Action,
+			Direction: models.DirectionBoth,
+			Period:    rule.Period,
+			Percent:   usage.Percent,
+			Status:    RuleStatusOK,
+			Error:     usage.Error,
+		}
+		if eval.Type == "" {
+			eval.Type = models.RuleTypeVolume
+		}
+		if rule.TrafficDirection != "" {
+			eval.Direction = strings.ToLower(rule.TrafficDirection)
+		}
+		eval.Used, eval.Limit, eval.Unit = ruleUsageAmount(rule, usage)
+
+		if rule.IsRateRule() {
+			eval.Period = ""
+			eval.Start, eval.End = now.Add(-rule.RateWindow()), now
+		} else {
+			var creationTime time.Time
+			if rule.UseCreationTime {
+				creationTime = getCreationTime(vm.VMID)
+			}
+			calc := periodcalc.ForRule(rule, creationTime)
+			eval.Start, eval.End = calc.PeriodStartAt(now), calc.NextPeriodStartAt(now)
+			if _, rolling := calc.Rolling(); rolling {
+				eval.End = now.In(eval.Start.Location())
+			}
+		}
+
+		switch {
+		case usage.Error != "":
+			eval.Status = RuleStatusError
+		case usage.Exceeded:
+			eval.Status = RuleStatusExceeded
+		case warn > 0 && usage.Percent >= warn:
+			eval.Status = RuleStatusWarning
+		}
+		evaluations = append(evaluations, eval)
+	}
+	return evaluations
+}
+
+// statsCacheTTL /api/stats 结果缓存时间
+const statsCacheTTL = 30 * time.Second
+
+// VMStatsResponse 单个虚拟机的流量统计（/api/stats）
+type VMStatsResponse struct {
+	VMID       int       `json:"vmid"`
+	Name       string    `json:"name"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Period     string    `json:"period"`
+	Direction  string    `json:"direction"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	TotalBytes uint64    `json:"total_bytes"`
+	RXBytes    uint64    `json:"rx_bytes"`
+	TXBytes    uint64    `json:"tx_bytes"`
+}
+
+// handleStats 获取统计信息（支持分页、排序和字段选择，带缓存和 ETag）
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	listQuery, err := parseListQuery(r)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+
 	// 获取基本参数
 	direction := r.URL.Query().Get("direction")
 	if direction == "" {
@@ -1104,12 +1758,12 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		var err error
 		startTime, err = time.Parse(time.RFC3339, startStr)
 		if err != nil {
-			s.sendError(w, "Invalid start time format, use RFC3339", http.StatusBadRequest)
+			s.sendError(w, s.tr(r, "api.invalid_start"), http.StatusBadRequest)
 			return
 		}
 		endTime, err = time.Parse(time.RFC3339, endStr)
 		if err != nil {
-			s.sendError(w, "Invalid end time format, use RFC3339", http.StatusBadRequest)
+			s.sendError(w, s.tr(r, "api.invalid_end"), http.StatusBadRequest)
 			return
 		}
 		useCustomRange = true
@@ -1127,84 +1781,109 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	vms, err := s.pveClient.GetAllVMsWithFilter(false)
-	if err != nil {
-		s.sendError(w, "获取虚拟机列表失败: "+err.Error(), http.StatusInternalServerError)
-		return
+	// 构建缓存key（缓存所有虚拟机的统计，客户范围在返回前过滤）
+	var cacheKey string
+	if useCustomRange {
+		cacheKey = fmt.Sprintf("stats_%s_%s_%s_%s", period, direction, startTime.Format("20060102150405"), endTime.Format("20060102150405"))
+	} else {
+		cacheKey = fmt.Sprintf("stats_%s_%s", period, direction)
 	}
 
-	type VMStatsResponse struct {
-		VMID       int       `json:"vmid"`
-		Name       string    `json:"name"`
-		Period     string    `json:"period"`
-		Direction  string    `json:"direction"`
-		StartTime  time.Time `json:"start_time"`
-		EndTime    time.Time `json:"end_time"`
-		TotalBytes uint64    `json:"total_bytes"`
-		RXBytes    uint64    `json:"rx_bytes"`
-		TXBytes    uint64    `json:"tx_bytes"`
-	}
+	entry, cached := s.getCacheEntry(cacheKey)
+	if !cached {
+		vms, err := s.allVMs(false)
+		if err != nil {
+			s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+			return
+		}
 
-	var allStats []VMStatsResponse
-	for _, vm := range vms {
-		var stats *models.TrafficStats
-		var err error
+		allStats := []VMStatsResponse{}
+		for _, vm := range s.tenants.Annotate(vms) {
+			var stats *models.TrafficStats
+			var err error
+
+			if useCustomRange {
+				// 使用自定义时间范围
+				stats, err = s.stats.CalculateRange(vm.VMID, startTime, endTime, direction)
+			} else {
+				// 使用预设周期
+				stats, err = s.stats.Calculate(vm.VMID, period, time.Time{}, false, direction)
+			}
 
+			if err == nil {
+				allStats = append(allStats, VMStatsResponse{
+					VMID:       vm.VMID,
+					Name:       vm.Name,
+					Tenant:     vm.Tenant,
+					Period:     stats.Period,
+					Direction:  stats.Direction,
+					StartTime:  stats.StartTime,
+					EndTime:    stats.EndTime,
+					TotalBytes: stats.TotalBytes,
+					RXBytes:    stats.RXBytes,
+					TXBytes:    stats.TXBytes,
+				})
+			}
+		}
+		var until time.Time
 		if useCustomRange {
-			// 使用自定义时间范围
-			stats, err = s.storage.CalculateTrafficStatsWithTimeRange(vm.VMID, startTime, endTime, direction)
-		} else {
-			// 使用预设周期
-			stats, err = s.storage.CalculateTrafficStatsWithDirection(vm.VMID, period, time.Time{}, false, direction)
+			until = endTime
 		}
+		entry = s.setScopedCache(cacheKey, allStats, statsCacheTTL, 0, until)
+	}
 
-		if err == nil {
-			allStats = append(allStats, VMStatsResponse{
-				VMID:       vm.VMID,
-				Name:       vm.Name,
-				Period:     stats.Period,
-				Direction:  stats.Direction,
-				StartTime:  stats.StartTime,
-				EndTime:    stats.EndTime,
-				TotalBytes: stats.TotalBytes,
-				RXBytes:    stats.RXBytes,
-				TXBytes:    stats.TXBytes,
-			})
+	// ETag 同时区分查询参数（分页、排序、字段）和客户范围
+	tenantName := requestTenant(r)
+	if s.checkNotModified(w, r, cacheETag(cacheKey, entry.CreatedAt, r.URL.RawQuery, tenantName), entry.CreatedAt) {
+		return
+	}
+
+	rows := entry.Data.([]VMStatsResponse)
+	if tenantName != "" {
+		scoped := make([]VMStatsResponse, 0, len(rows))
+		for _, row := range rows {
+			if row.Tenant == tenantName {
+				scoped = append(scoped, row)
+			}
 		}
+		rows = scoped
 	}
 
-	s.sendJSON(w, map[string]interface{}{
-		"success":   true,
-		"data":      allStats,
+	s.sendList(w, r, rows, listQuery, map[string]interface{}{
 		"period":    period,
 		"direction": direction,
+		"cached":    cached,
 	})
 }
 
-// handleLogs 获取操作日志
+// handleLogs 获取操作日志（支持按虚拟机、规则、操作和结果过滤）
 func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
-	// 默认获取最近 7 天的日志
-	endTime := time.Now()
-	startTime := endTime.AddDate(0, 0, -7)
-
-	// 支持自定义时间范围
-	if start := r.URL.Query().Get("start"); start != "" {
-		if t, err := time.Parse(time.RFC3339, start); err == nil {
-			startTime = t
-		}
+	filter, err := parseActionLogFilter(r)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
 	}
-	if end := r.URL.Query().Get("end"); end != "" {
-		if t, err := time.Parse(time.RFC3339, end); err == nil {
-			endTime = t
-		}
+
+	filter.VMIDs, err = s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
 	}
 
-	logs, err := s.storage.GetActionLogs(startTime, endTime)
+	logs, err := s.storage.GetActionLogs(filter.Start, filter.End)
 	if err != nil {
-		s.sendError(w, "获取日志失败: "+err.Error(), http.StatusInternalServerError)
+		s.sendError(w, s.tr(r, "api.get_logs_failed", err), http.StatusInternalServerError)
 		return
 	}
 
+	logs = filter.apply(logs)
+	// 外部命令的输出可能包含内部信息，不返回给客户密钥
+	if requestTenant(r) != "" {
+		for i := range logs {
+			logs[i].Output, logs[i].HookOutput = "", ""
+		}
+	}
+
 	s.sendJSON(w, map[string]interface{}{
 		"success": true,
 		"data":    logs,
@@ -1216,133 +1895,343 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 	vmidStr := r.URL.Path[len("/api/history/"):]
 	vmid, err := strconv.Atoi(vmidStr)
 	if err != nil {
-		s.sendError(w, "Invalid VM ID", http.StatusBadRequest)
+		s.sendError(w, s.tr(r, "api.invalid_vmid"), http.StatusBadRequest)
 		return
 	}
 
-	// 检查是否使用自定义时间范围
-	startStr := r.URL.Query().Get("start")
-	endStr := r.URL.Query().Get("end")
-	granularity := r.URL.Query().Get("granularity")
+	if !s.checkVMAccess(w, r, vmid) {
+		return
+	}
 
-	var startTime, endTime time.Time
-	var period string
-	var useCustomRange bool
-	var cacheTTL time.Duration
+	// metric=disk 返回磁盘读写（rx_bytes=读取，tx_bytes=写入），ipv4/ipv6 返回客户机报告的按协议流量
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = models.MetricNetwork
+	}
+	if !models.ValidMetric(metric) {
+		s.sendError(w, s.tr(r, "api.invalid_param", "metric", metric), http.StatusBadRequest)
+		return
+	}
 
-	now := time.Now()
+	// step（如 15m、6h、1d 或 auto）按步长聚合，可填充空桶并限制点数
+	bucket, useStep, ok := s.historyBucketQuery(w, r)
+	if !ok {
+		return
+	}
+
+	window, ok := s.parseHistoryWindow(w, r)
+	if !ok {
+		return
+	}
+	startTime, endTime, period, useCustomRange, cacheTTL := window.Start, window.End, window.Period, window.Custom, window.CacheTTL
+
+	// 构建缓存key
+	var cacheKey string
+	if useCustomRange {
+		cacheKey = fmt.Sprintf("history_%d_%s_%s_%s", vmid, period, startTime.Format("20060102150405"), endTime.Format("20060102150405"))
+	} else {
+		cacheKey = fmt.Sprintf("history_%d_%s", vmid, period)
+	}
+	if metric != models.MetricNetwork {
+		cacheKey += "_" + metric
+	}
+	if useStep {
+		bucket.Start, bucket.End = startTime, endTime
+		bucket.Step = bucket.EffectiveStep()
+		cacheKey += fmt.Sprintf("_step%s_%s_%d", storage.FormatBucketStep(bucket.Step), bucket.Fill, bucket.MaxPoints)
+	} else if bucket.Fill != storage.FillNone {
+		// 按固定周期填充时检查点数（如按分钟填充一年）
+		if storage.CountPeriodBuckets(period, startTime, endTime) > models.MaxHistoryPoints {
+			s.sendError(w, s.tr(r, "api.history_too_many_points", models.MaxHistoryPoints), http.StatusBadRequest)
+			return
+		}
+		cacheKey += "_fill" + bucket.Fill
+	}
+
+	// 检查缓存（ETag 由缓存 key 和缓存生成时间得出，未变化时返回 304）
+	if entry, ok := s.getCacheEntry(cacheKey); ok {
+		if s.checkNotModified(w, r, cacheETag(cacheKey, entry.CreatedAt), entry.CreatedAt) {
+			return
+		}
+		s.sendJSON(w, historyResponse(entry.Data.(historyData), period, metric, bucket, useStep, true))
+		return
+	}
+
+	// 获取历史记录
+	records, err := s.storage.GetTrafficRecords(vmid, startTime, endTime)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 没有采样的时间段按网络计数器判断（磁盘计数器同样在虚拟机重启时清零）
+	downtimes, err := s.historyDowntime(vmid, records, startTime, endTime)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+	powerEvents, err := storage.LoadPowerEvents(s.storage, vmid, startTime, endTime)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.get_records_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	records = storage.MetricRecords(records, metric)
+	var points []storage.AggregatedPoint
+	var layout string
+	var bucketStart func(time.Time) time.Time
+	if useStep {
+		points, _ = storage.AggregateTrafficByStep(records, bucket)
+		layout, bucketStart = stepTimeFormat(bucket.Step), bucket.BucketStart
+	} else {
+		points = storage.AggregateTrafficByPeriod(records, period)
+		if bucket.Fill != storage.FillNone {
+			points = storage.FillPeriodGaps(points, period, startTime, endTime)
+		}
+		layout = getTimeFormat(period)
+		bucketStart = func(t time.Time) time.Time { return storage.PeriodBucketStart(period, t) }
+	}
+	aggregated := historyData{
+		Points:   formatPoints(points, layout, bucket.Fill == storage.FillNull),
+		Downtime: formatDowntime(downtimes, layout, bucketStart),
+		Power:    formatPowerEvents(powerEvents, layout, bucketStart),
+	}
+
+	// 缓存结果（该虚拟机的新采样落在范围内时失效）
+	var until time.Time
+	if useCustomRange {
+		until = endTime
+	}
+	entry := s.setScopedCache(cacheKey, aggregated, cacheTTL, vmid, until)
+	if s.checkNotModified(w, r, cacheETag(cacheKey, entry.CreatedAt), entry.CreatedAt) {
+		return
+	}
+
+	s.sendJSON(w, historyResponse(aggregated, period, metric, bucket, useStep, false))
+}
+
+// historyWindow 历史数据的时间范围和聚合周期
+type historyWindow struct {
+	Start    time.Time
+	End      time.Time
+	Period   string        // minute/hour/day/month（自定义范围时为 granularity）
+	Custom   bool          // 使用 start/end 指定的自定义范围
+	CacheTTL time.Duration // 结果的缓存时间
+}
+
+// parseHistoryWindow 解析 start/end/granularity（自定义范围）或 period（预设周期），出错时已写入错误响应
+func (s *Server) parseHistoryWindow(w http.ResponseWriter, r *http.Request) (historyWindow, bool) {
+	query := r.URL.Query()
+	startStr, endStr := query.Get("start"), query.Get("end")
 
 	if startStr != "" && endStr != "" {
 		// 自定义时间范围模式
-		var err error
-		startTime, err = time.Parse(time.RFC3339, startStr)
+		startTime, err := time.Parse(time.RFC3339, startStr)
 		if err != nil {
-			s.sendError(w, "Invalid start time format, use RFC3339", http.StatusBadRequest)
-			return
+			s.sendError(w, s.tr(r, "api.invalid_start"), http.StatusBadRequest)
+			return historyWindow{}, false
 		}
-		endTime, err = time.Parse(time.RFC3339, endStr)
+		endTime, err := time.Parse(time.RFC3339, endStr)
 		if err != nil {
-			s.sendError(w, "Invalid end time format, use RFC3339", http.StatusBadRequest)
-			return
+			s.sendError(w, s.tr(r, "api.invalid_end"), http.StatusBadRequest)
+			return historyWindow{}, false
 		}
-		useCustomRange = true
 
-		// 设置粒度（默认为 hour）
+		// 设置粒度（默认为 hour），自定义范围的缓存时间较短
+		granularity := query.Get("granularity")
 		if granularity == "" {
 			granularity = "hour"
 		}
-		period = granularity
+		return historyWindow{Start: startTime, End: endTime, Period: granularity, Custom: true, CacheTTL: 30 * time.Second}, true
+	}
 
-		// 自定义范围的缓存时间较短
-		cacheTTL = 30 * time.Second
-	} else {
-		// 预设周期模式
-		period = r.URL.Query().Get("period")
-		if period == "" {
-			period = "day"
-		}
+	// 预设周期模式
+	period := query.Get("period")
+	if period == "" {
+		period = "day"
+	}
 
-		// 计算时间范围（限制查询范围以优化性能）
-		switch period {
-		case models.PeriodMinute:
-			startTime = now.Add(-1 * time.Hour) // 最近1小时，用于分钟粒度
-			cacheTTL = 30 * time.Second         // 缓存30秒
-		case models.PeriodHour:
-			startTime = now.Add(-24 * time.Hour) // 最近24小时
-			cacheTTL = 1 * time.Minute           // 缓存1分钟
-		case models.PeriodDay:
-			startTime = now.AddDate(0, 0, -30) // 最近30天
-			cacheTTL = 5 * time.Minute         // 缓存5分钟
-		case models.PeriodMonth:
-			startTime = now.AddDate(0, -12, 0) // 最近12个月
-			cacheTTL = 15 * time.Minute        // 缓存15分钟
-		default:
-			s.sendError(w, "Invalid period", http.StatusBadRequest)
-			return
+	// 计算时间范围（限制查询范围以优化性能）
+	now := time.Now()
+	startTime, cacheTTL, ok := historyRange(period, now)
+	if !ok {
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return historyWindow{}, false
+	}
+	return historyWindow{Start: startTime, End: now, Period: period, CacheTTL: cacheTTL}, true
+}
+
+// historyBucketQuery 解析 /api/history 的 step、fill、max_points 参数
+// fill 对两种聚合方式都有效；未指定 step 时返回 false，按 period/granularity 聚合
+func (s *Server) historyBucketQuery(w http.ResponseWriter, r *http.Request) (storage.BucketQuery, bool, bool) {
+	query := r.URL.Query()
+	fill := query.Get("fill")
+	if fill != storage.FillNone && fill != storage.FillZero && fill != storage.FillNull {
+		s.sendError(w, s.tr(r, "api.invalid_param", "fill", fill), http.StatusBadRequest)
+		return storage.BucketQuery{}, false, false
+	}
+	stepStr := query.Get("step")
+	if stepStr == "" {
+		return storage.BucketQuery{Fill: fill}, false, true
+	}
+
+	step, ok := storage.ParseBucketStep(stepStr)
+	if !ok {
+		s.sendError(w, s.tr(r, "api.invalid_param", "step", stepStr), http.StatusBadRequest)
+		return storage.BucketQuery{}, false, false
+	}
+	maxPoints := models.DefaultHistoryMaxPoints
+	if v := query.Get("max_points"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxHistoryPoints {
+			s.sendError(w, s.tr(r, "api.invalid_param", "max_points", v), http.StatusBadRequest)
+			return storage.BucketQuery{}, false, false
 		}
-		endTime = now
+		maxPoints = n
 	}
+	return storage.BucketQuery{Step: step, Fill: fill, MaxPoints: maxPoints}, true, true
+}
 
-	// 构建缓存key
-	var cacheKey string
-	if useCustomRange {
-		cacheKey = fmt.Sprintf("history_%d_%s_%s_%s", vmid, period, startTime.Format("20060102150405"), endTime.Format("20060102150405"))
-	} else {
-		cacheKey = fmt.Sprintf("history_%d_%s", vmid, period)
+// historyData 缓存的历史数据
+type historyData struct {
+	Points   []map[string]interface{}
+	Downtime []map[string]interface{}
+	Power    []map[string]interface{}
+}
+
+// historyResponse 历史数据响应（按步长聚合时附带实际步长和点数上限）
+func historyResponse(data historyData, period, metric string, bucket storage.BucketQuery, useStep, cached bool) map[string]interface{} {
+	resp := map[string]interface{}{
+		"success":      true,
+		"data":         data.Points,
+		"downtime":     data.Downtime,
+		"power_events": data.Power,
+		"period":       period,
+		"metric":       metric,
+		"cached":       cached,
 	}
+	if bucket.Fill != storage.FillNone {
+		resp["fill"] = bucket.Fill
+	}
+	if useStep {
+		resp["step"] = storage.FormatBucketStep(bucket.Step)
+		resp["max_points"] = bucket.MaxPoints
+	}
+	return resp
+}
 
-	// 检查缓存
-	cached, ok := s.getCache(cacheKey)
-	if ok {
-		s.sendJSON(w, map[string]interface{}{
-			"success": true,
-			"data":    cached,
-			"period":  period,
-			"cached":  true,
-		})
-		return
+// historyDowntime 范围内没有采样的时间段（根据采样间隔、计数器变化和该虚拟机的关机操作判断）
+func (s *Server) historyDowntime(vmid int, records []models.TrafficRecord, start, end time.Time) ([]storage.Downtime, error) {
+	logs, err := s.storage.GetActionLogs(start, end)
+	if err != nil {
+		return nil, err
+	}
+	var actions []models.ActionLog
+	for _, entry := range logs {
+		if entry.VMID == vmid {
+			actions = append(actions, entry)
+		}
 	}
 
-	// 获取历史记录
-	records, err := s.storage.GetTrafficRecords(vmid, startTime, endTime)
+	// 范围起点之前没有记录时（新建的虚拟机）不把范围开始到第一次采样算作中断
+	earliest, err := storage.EarliestRecordTime(s.storage, vmid)
 	if err != nil {
-		s.sendError(w, "Failed to get traffic records: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
+	leading := !earliest.IsZero() && earliest.Before(start)
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	return storage.DetectDowntime(records, actions, start, end, s.config.Monitor.GapThreshold(), leading), nil
+}
 
-	if len(records) == 0 {
-		emptyResult := []map[string]interface{}{}
-		s.sendJSON(w, map[string]interface{}{
-			"success": true,
-			"data":    emptyResult,
-			"period":  period,
-		})
-		return
+// formatPowerEvents 将运行状态变化转换为API响应格式，at 为变化所在数据点的 timestamp（图表标注使用）
+func formatPowerEvents(powerEvents []models.PowerEvent, layout string, bucketStart func(time.Time) time.Time) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(powerEvents))
+	for i, event := range powerEvents {
+		result[i] = map[string]interface{}{
+			"time": event.Time,
+			"from": event.From,
+			"to":   event.To,
+			"at":   bucketStart(event.Time).Format(layout),
+		}
+	}
+	return result
+}
+
+// formatDowntime 将没有采样的时间段转换为API响应格式，from/to 为起止时间所在数据点的 timestamp（图表标注使用）
+func formatDowntime(downtimes []storage.Downtime, layout string, bucketStart func(time.Time) time.Time) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(downtimes))
+	for i, d := range downtimes {
+		result[i] = map[string]interface{}{
+			"start":  d.Start,
+			"end":    d.End,
+			"reason": d.Reason,
+			"from":   bucketStart(d.Start).Format(layout),
+			"to":     bucketStart(d.End).Format(layout),
+		}
+		if d.Ongoing {
+			result[i]["ongoing"] = true
+		}
+		if d.Action != "" {
+			result[i]["action"] = d.Action
+			result[i]["rule_name"] = d.RuleName
+		}
 	}
+	return result
+}
 
-	// 按时间段聚合数据 - 使用共用的聚合函数
-	aggregatedPoints := storage.AggregateTrafficByPeriod(records, period)
+// historyPoints 按时间段聚合数据并转换为API响应格式 - 使用共用的聚合函数
+func historyPoints(records []models.TrafficRecord, period string) []map[string]interface{} {
+	return formatHistory(storage.AggregateTrafficByPeriod(records, period), period)
+}
 
-	// 转换为API响应格式
+// formatHistory 将聚合数据点转换为API响应格式
+func formatHistory(aggregatedPoints []storage.AggregatedPoint, period string) []map[string]interface{} {
+	return formatPoints(aggregatedPoints, getTimeFormat(period), false)
+}
+
+// formatPoints 将聚合数据点转换为API响应格式（nullGaps 时填充的空桶流量输出 null）
+func formatPoints(aggregatedPoints []storage.AggregatedPoint, layout string, nullGaps bool) []map[string]interface{} {
 	aggregated := make([]map[string]interface{}, len(aggregatedPoints))
 	for i, point := range aggregatedPoints {
 		aggregated[i] = map[string]interface{}{
-			"timestamp":   point.Timestamp.Format(getTimeFormat(period)),
+			"timestamp":   point.Timestamp.Format(layout),
 			"rx_bytes":    point.RXBytes,
 			"tx_bytes":    point.TXBytes,
 			"total_bytes": point.TotalBytes,
 		}
+		if point.Gap && nullGaps {
+			aggregated[i]["rx_bytes"] = nil
+			aggregated[i]["tx_bytes"] = nil
+			aggregated[i]["total_bytes"] = nil
+		}
 	}
+	return aggregated
+}
 
-	// 缓存结果
-	s.setCache(cacheKey, aggregated, cacheTTL)
+// stepTimeFormat 按步长聚合时的时间格式（整天的步长只显示日期）
+func stepTimeFormat(step time.Duration) string {
+	if step%(24*time.Hour) == 0 {
+		return models.TimeFormatDay
+	}
+	return models.TimeFormatMinute
+}
 
-	s.sendJSON(w, map[string]interface{}{
-		"success": true,
-		"data":    aggregated,
-		"period":  period,
-		"cached":  false,
-	})
+// historyRange 预设周期的历史查询起始时间和缓存时间
+func historyRange(period string, now time.Time) (time.Time, time.Duration, bool) {
+	switch period {
+	case models.PeriodMinute:
+		return now.Add(-1 * time.Hour), 30 * time.Second, true // 最近1小时，用于分钟粒度，缓存30秒
+	case models.PeriodHour:
+		return now.Add(-24 * time.Hour), 1 * time.Minute, true // 最近24小时，缓存1分钟
+	case models.PeriodDay:
+		return now.AddDate(0, 0, -30), 5 * time.Minute, true // 最近30天，缓存5分钟
+	case models.PeriodMonth:
+		return now.AddDate(0, -12, 0), 15 * time.Minute, true // 最近12个月，缓存15分钟
+	default:
+		return time.Time{}, 0, false
+	}
 }
 
 // getTimeFormat 根据period获取时间格式
@@ -1367,6 +2256,58 @@ func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// cleanupStatus 旧数据清理计划和最近一次清理的结果
+func (s *Server) cleanupStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"schedule": s.config.Monitor.CleanupCron(),
+	}
+	if cron, err := schedule.Parse(s.config.Monitor.CleanupCron()); err == nil {
+		if next := cron.Next(time.Now().In(periodcalc.Location())); !next.IsZero() {
+			status["next"] = next
+		}
+	}
+
+	last, err := storage.LastCleanup(s.storage)
+	if err != nil {
+		log.Printf("读取上次清理结果失败: %v", err)
+	}
+	status["last"] = last
+	return status
+}
+
+// haStatus 主备模式状态：本实例标识、当前主实例和租约到期时间
+func (s *Server) haStatus() map[string]interface{} {
+	ha := s.config.Monitor.HA
+	status := map[string]interface{}{
+		"enabled": ha.Enabled,
+	}
+	if !ha.Enabled {
+		return status
+	}
+
+	status["instance"] = ha.ID()
+	lease, err := storage.GetLease(s.storage, storage.LeaderLease)
+	if err != nil {
+		log.Printf("读取主实例租约失败: %v", err)
+	}
+	status["leader"] = lease.Active(time.Now()) && lease.Holder == ha.ID()
+	if lease != nil {
+		status["holder"] = lease.Holder
+		status["expires_at"] = lease.ExpiresAt
+	}
+	return status
+}
+
+// SetEventStats 设置内部事件统计的获取函数（显示在系统统计中）
+func (s *Server) SetEventStats(stats func() interface{}) {
+	s.eventStats = stats
+}
+
+// SetCollectionStats 设置采集状态的获取函数（显示在系统统计中）
+func (s *Server) SetCollectionStats(stats func() interface{}) {
+	s.collectionStats = stats
+}
+
 // handleSystemStats 获取系统统计信息
 func (s *Server) handleSystemStats(w http.ResponseWriter, r *http.Request) {
 	// 获取总采样点数
@@ -1381,18 +2322,61 @@ func (s *Server) handleSystemStats(w http.ResponseWriter, r *http.Request) {
 
 	// 获取缓存统计
 	cacheTotal, cacheExpired := s.cache.getStats()
+	statsCacheTotal, statsCacheExpired := s.stats.CacheStats()
+
+	data := map[string]interface{}{
+		"total_records":   totalRecords,
+		"api_performance": perfStats,
+		"cache_total":     cacheTotal,
+		"cache_expired":   cacheExpired,
+		"stats_cache": map[string]interface{}{
+			"total":     statsCacheTotal,
+			"expired":   statsCacheExpired,
+			"coalesced": s.stats.Coalesced(),
+		},
+		"storage_type":     s.config.Storage.Type,
+		"monitor_interval": s.config.Monitor.IntervalSeconds,
+		"data_retention":   s.config.Monitor.DataRetentionDays,
+		"retention":        s.config.Monitor.Retention,
+	}
+	data["cleanup"] = s.cleanupStatus()
+	data["ha"] = s.haStatus()
+	if maintenance, err := storage.LoadMaintenance(s.storage); err == nil {
+		data["maintenance"] = maintenance
+	}
+	if health, ok := storage.As[storage.HealthReporter](s.storage); ok {
+		data["storage"] = health.StorageHealth()
+	}
+
+	// 等待写入后端的记录数（本地缓冲积压 + 远程写入待发送）
+	pendingWrites := 0
+	if spool, ok := storage.As[storage.SpoolReporter](s.storage); ok {
+		st := spool.SpoolStats()
+		data["spool"] = st
+		pendingWrites += st.Backlog
+	}
+	if replica, ok := storage.As[storage.ReplicationReporter](s.storage); ok {
+		data["replication"] = replica.ReplicationStats()
+	}
+	if remote, ok := storage.As[storage.RemoteWriteReporter](s.storage); ok {
+		st := remote.RemoteWriteStats()
+		data["remote_write"] = st
+		pendingWrites += st.Pending
+	}
+	data["pending_writes"] = pendingWrites
+	if queries, ok := storage.As[storage.QueryReporter](s.storage); ok {
+		data["queries"] = queries.QueryStats()
+	}
+	if s.eventStats != nil {
+		data["events"] = s.eventStats()
+	}
+	if s.collectionStats != nil {
+		data["collection"] = s.collectionStats()
+	}
 
 	s.sendJSON(w, map[string]interface{}{
 		"success": true,
-		"data": map[string]interface{}{
-			"total_records":    totalRecords,
-			"api_performance":  perfStats,
-			"cache_total":      cacheTotal,
-			"cache_expired":    cacheExpired,
-			"storage_type":     s.config.Storage.Type,
-			"monitor_interval": s.config.Monitor.IntervalSeconds,
-			"data_retention":   s.config.Monitor.DataRetentionDays,
-		},
+		"data":    data,
 	})
 }
 
@@ -1402,6 +2386,28 @@ func (s *Server) sendJSON(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// sendList 发送列表响应（应用分页、排序和字段选择）
+func (s *Server) sendList(w http.ResponseWriter, r *http.Request, items interface{}, listQuery *listQuery, extra map[string]interface{}) {
+	rows, pagination, err := applyListQuery(items, listQuery)
+	if err != nil {
+		s.sendError(w, s.localizeError(r, err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    rows,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	if pagination != nil {
+		response["pagination"] = pagination
+	}
+
+	s.sendJSON(w, response)
+}
+
 // sendError 发送错误响应
 func (s *Server) sendError(w http.ResponseWriter, message string, code int) {
 	w.Header().Set("Content-Type", "application/json")
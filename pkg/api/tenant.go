@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/tenant"
+)
+
+type tenantContextKey struct{}
+
+// withTenant 将 API 密钥限定的客户写入请求上下文
+func withTenant(r *http.Request, name string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, name))
+}
+
+// requestTenant 获取请求限定的客户（空字符串表示可访问全部虚拟机）
+func requestTenant(r *http.Request) string {
+	name, _ := r.Context().Value(tenantContextKey{}).(string)
+	return name
+}
+
+// findAPIKey 查找令牌对应的客户密钥
+func (s *Server) findAPIKey(token string) *models.APIKey {
+	if token == "" {
+		return nil
+	}
+	for i := range s.config.API.Keys {
+		if s.config.API.Keys[i].Token == token {
+			return &s.config.API.Keys[i]
+		}
+	}
+	return nil
+}
+
+// scopeVMs 填充虚拟机所属客户，并按请求限定的客户过滤
+func (s *Server) scopeVMs(r *http.Request, vms []models.VMInfo) []models.VMInfo {
+	vms = s.tenants.Annotate(vms)
+	if name := requestTenant(r); name != "" {
+		return s.tenants.Filter(vms, name)
+	}
+	return vms
+}
+
+// allowedVMIDs 获取请求可访问的虚拟机 ID（nil 表示不限制）
+func (s *Server) allowedVMIDs(r *http.Request) (map[int]bool, error) {
+	if requestTenant(r) == "" {
+		return nil, nil
+	}
+
+	vms, err := s.allVMs(false)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[int]bool)
+	for _, vm := range s.scopeVMs(r, vms) {
+		allowed[vm.VMID] = true
+	}
+	return allowed, nil
+}
+
+// checkVMAccess 检查请求是否可以访问指定虚拟机，不可访问时已写入错误响应
+func (s *Server) checkVMAccess(w http.ResponseWriter, r *http.Request, vmid int) bool {
+	allowed, err := s.allowedVMIDs(r)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return false
+	}
+	if allowed != nil && !allowed[vmid] {
+		// 不区分“不存在”和“无权访问”，避免泄露其他客户的虚拟机
+		s.sendError(w, s.tr(r, "api.vm_not_found", vmid), http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// handleTenants 按客户汇总流量（客户密钥只能看到自己的汇总）
+func (s *Server) handleTenants(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.PeriodMonth
+	}
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+
+	switch period {
+	case models.PeriodMinute, models.PeriodHour, models.PeriodDay, models.PeriodMonth:
+	default:
+		s.sendError(w, s.tr(r, "api.invalid_period", period), http.StatusBadRequest)
+		return
+	}
+
+	vms, err := s.allVMs(false)
+	if err != nil {
+		s.sendError(w, s.tr(r, "api.list_vms_failed", err), http.StatusInternalServerError)
+		return
+	}
+
+	names, groups := s.tenants.Group(s.scopeVMs(r, vms))
+	result := make([]models.TenantStats, 0, len(names))
+	for _, name := range names {
+		var stats []models.TrafficStats
+		for _, vm := range groups[name] {
+			stat, err := s.stats.Calculate(vm.VMID, period, time.Time{}, false, direction)
+			if err != nil {
+				continue
+			}
+			stats = append(stats, *stat)
+		}
+		result = append(result, tenant.Aggregate(name, stats))
+	}
+
+	s.sendJSON(w, map[string]interface{}{
+		"success":   true,
+		"data":      result,
+		"period":    period,
+		"direction": direction,
+	})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestAuthMiddlewareTenantKeys(t *testing.T) {
+	s := &Server{config: &models.Config{
+		API: models.APIConfig{
+			Token: "admin",
+			Keys:  []models.APIKey{{Name: "acme", Token: "acme-key", Tenant: "acme"}},
+		},
+	}}
+
+	var gotTenant string
+	handler := s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = requestTenant(r)
+	})
+
+	tests := []struct {
+		token      string
+		wantCode   int
+		wantTenant string
+	}{
+		{"admin", http.StatusOK, ""},
+		{"acme-key", http.StatusOK, "acme"},
+		{"", http.StatusUnauthorized, ""},
+		{"wrong", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		gotTenant = ""
+		req := httptest.NewRequest("GET", "/api/vms", nil)
+		req.Header.Set("X-API-Token", tt.token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != tt.wantCode || gotTenant != tt.wantTenant {
+			t.Fatalf("token %q: code = %d tenant = %q, want %d %q", tt.token, rec.Code, gotTenant, tt.wantCode, tt.wantTenant)
+		}
+	}
+}
+
+func TestScopeRuleUsage(t *testing.T) {
+	usages := []RuleUsage{{
+		VMCount:       2,
+		ExceededCount: 2,
+		VMs:           []RuleVMUsage{{VMID: 100, Exceeded: true}, {VMID: 101, Exceeded: true}},
+	}}
+
+	scoped := scopeRuleUsage(usages, map[int]bool{100: true})
+	if scoped[0].VMCount != 1 || scoped[0].ExceededCount != 1 || scoped[0].VMs[0].VMID != 100 {
+		t.Fatalf("scopeRuleUsage() = %+v, want only VM 100", scoped[0])
+	}
+	if usages[0].VMCount != 2 {
+		t.Fatalf("scopeRuleUsage() modified cached usages")
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// themePalette 单个主题的界面配色与图表配色
+type themePalette struct {
+	Colors models.ColorScheme `json:"colors"`
+	Chart  models.ChartColors `json:"chart"`
+}
+
+// themeConfig 前端主题配置（默认主题 + 亮/暗两套配色）
+type themeConfig struct {
+	Default string       `json:"default"`
+	Light   themePalette `json:"light"`
+	Dark    themePalette `json:"dark"`
+}
+
+// defaultTheme 配置的默认主题（未配置时跟随浏览器）
+func (s *Server) defaultTheme() string {
+	switch s.config.API.Theme {
+	case models.ThemeLight, models.ThemeDark:
+		return s.config.API.Theme
+	default:
+		return models.ThemeAuto
+	}
+}
+
+// themeConfig 构建主题配置，配色与图表导出器共用 models 中的定义
+func (s *Server) themeConfig() themeConfig {
+	return themeConfig{
+		Default: s.defaultTheme(),
+		Light: themePalette{
+			Colors: models.GetColorScheme(false),
+			Chart:  models.GetChartColors(false),
+		},
+		Dark: themePalette{
+			Colors: models.GetColorScheme(true),
+			Chart:  models.GetChartColors(true),
+		},
+	}
+}
+
+// handleTheme 获取主题默认值与配色
+func (s *Server) handleTheme(w http.ResponseWriter, r *http.Request) {
+	s.sendJSON(w, map[string]interface{}{
+		"success": true,
+		"data":    s.themeConfig(),
+	})
+}
+
+// themeCSSVars 将配色转换为 CSS 变量声明
+func themeCSSVars(c models.ColorScheme) string {
+	vars := []struct{ name, value string }{
+		{"primary", c.Primary},
+		{"success", c.Success},
+		{"warning", c.Warning},
+		{"danger", c.Danger},
+		{"info", c.Info},
+		{"download", c.Download},
+		{"upload", c.Upload},
+		{"total", c.Total},
+		{"card-bg", c.Background},
+		{"text", c.Text},
+		{"border", c.Border},
+		{"grid-line", c.GridLine},
+		{"page-bg", c.Page},
+		{"muted", c.Muted},
+		{"header-bg", c.Header},
+	}
+
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "--%s: %s; ", v.name, v.value)
+	}
+	return b.String()
+}
+
+// themeStyle 生成内置页面的主题样式：默认亮色，data-theme="dark" 时切换为暗色
+func themeStyle() string {
+	return fmt.Sprintf(":root { %s}\n        :root[data-theme=\"dark\"] { %s}",
+		themeCSSVars(models.GetColorScheme(false)),
+		themeCSSVars(models.GetColorScheme(true)))
+}
+
+// renderIndexTheme 将主题样式和配置注入内置页面
+func (s *Server) renderIndexTheme(html string) string {
+	config, err := json.Marshal(s.themeConfig())
+	if err != nil {
+		config = []byte("{}")
+	}
+	return strings.NewReplacer(
+		"/*__THEME_STYLE__*/", themeStyle(),
+		"/*__THEME_CONFIG__*/null", string(config),
+	).Replace(html)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestDefaultTheme(t *testing.T) {
+	tests := []struct {
+		configured string
+		want       string
+	}{
+		{"", models.ThemeAuto},
+		{models.ThemeAuto, models.ThemeAuto},
+		{models.ThemeLight, models.ThemeLight},
+		{models.ThemeDark, models.ThemeDark},
+	}
+
+	for _, tt := range tests {
+		s := &Server{config: &models.Config{API: models.APIConfig{Theme: tt.configured}}}
+		if got := s.defaultTheme(); got != tt.want {
+			t.Fatalf("defaultTheme(%q) = %q, want %q", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestRenderIndexThemeInjectsPalettes(t *testing.T) {
+	s := &Server{config: &models.Config{API: models.APIConfig{Theme: models.ThemeDark}}}
+	html := s.renderIndexTheme("<style>/*__THEME_STYLE__*/</style><script>const c = /*__THEME_CONFIG__*/null;</script>")
+
+	if strings.Contains(html, "__THEME_") {
+		t.Fatalf("placeholders not replaced: %s", html)
+	}
+	if !strings.Contains(html, "--download: "+models.LightTheme.Download) || !strings.Contains(html, "--download: "+models.DarkTheme.Download) {
+		t.Fatalf("theme style missing palette colors: %s", html)
+	}
+	if !strings.Contains(html, `"default":"dark"`) {
+		t.Fatalf("theme config missing default: %s", html)
+	}
+}
@@ -0,0 +1,352 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/objstore"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// MonthLayout 归档月份的格式
+const MonthLayout = "2006-01"
+
+// restoreBatchSize 恢复时每批写入的记录数
+const restoreBatchSize = 1000
+
+// ObjectStore 归档使用的对象存储操作（由 objstore.S3Client 实现）
+type ObjectStore interface {
+	// Put 上传对象（覆盖已有对象）
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Get 下载对象（不存在时返回 objstore.ErrNotFound）
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List 列出名称以 prefix 开头的对象（按名称升序）
+	List(ctx context.Context, prefix string) ([]objstore.Object, error)
+}
+
+// MonthResult 一个月份的归档结果
+type MonthResult struct {
+	Month   string `json:"month"`
+	Key     string `json:"key"`
+	VMs     int    `json:"vms"`
+	Records int    `json:"records"` // 上传的记录数（包括对象中已有、本地已删除的记录）
+	Bytes   int    `json:"bytes"`   // 压缩后的大小
+	Pruned  int64  `json:"pruned"`  // 从本地删除的记录数
+	Kept    []int  `json:"kept,omitempty"`
+}
+
+// RestoreResult 一个月份的恢复结果
+type RestoreResult struct {
+	Month   string `json:"month"`
+	VMs     int    `json:"vms"`
+	Records int    `json:"records"`
+	Skipped []int  `json:"skipped,omitempty"` // 本地已有该月记录、未恢复的虚拟机
+}
+
+// Archiver 把已结束月份的流量记录压缩上传到对象存储，再从本地存储删除
+type Archiver struct {
+	storage storage.Interface
+	objects ObjectStore
+	prefix  string
+	loc     *time.Location
+}
+
+// New 创建归档器（月份边界按 loc 计算，对象名为 prefix + traffic/2006-01.jsonl.gz）
+func New(s storage.Interface, objects ObjectStore, prefix string, loc *time.Location) *Archiver {
+	return &Archiver{storage: s, objects: objects, prefix: prefix, loc: loc}
+}
+
+// ParseMonth 解析 2006-01 格式的月份（返回该月第一天零点）
+func (a *Archiver) ParseMonth(value string) (time.Time, error) {
+	month, err := time.ParseInLocation(MonthLayout, value, a.loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("月份格式应为 2006-01: %w", err)
+	}
+	return month, nil
+}
+
+// Key 月份对应的对象名
+func (a *Archiver) Key(month time.Time) string {
+	return a.prefix + "traffic/" + month.Format(MonthLayout) + ".jsonl.gz"
+}
+
+// monthStart 时间所在月份的第一天零点
+func (a *Archiver) monthStart(t time.Time) time.Time {
+	t = t.In(a.loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, a.loc)
+}
+
+// PendingMonths 本地仍有记录、且之后 keep 个月都已结束的月份（按时间升序）
+func (a *Archiver) PendingMonths(now time.Time, keep int) ([]time.Time, error) {
+	cutoff := a.monthStart(now).AddDate(0, -keep, 0)
+	vmids, err := storage.RecordVMIDs(a.storage, time.Time{}, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var earliest time.Time
+	for _, vmid := range vmids {
+		first, err := storage.EarliestRecordTime(a.storage, vmid)
+		if err != nil {
+			return nil, fmt.Errorf("查询虚拟机 %d 最早记录失败: %w", vmid, err)
+		}
+		if !first.IsZero() && (earliest.IsZero() || first.Before(earliest)) {
+			earliest = first
+		}
+	}
+	if earliest.IsZero() {
+		return nil, nil
+	}
+
+	var months []time.Time
+	for month := a.monthStart(earliest); month.Before(cutoff); month = month.AddDate(0, 1, 0) {
+		vmids, err := storage.RecordVMIDs(a.storage, month, month.AddDate(0, 1, 0))
+		if err != nil {
+			return nil, err
+		}
+		if len(vmids) > 0 {
+			months = append(months, month)
+		}
+	}
+	return months, nil
+}
+
+// ArchiveMonth 上传月份的流量记录（对象已存在时与其中的记录合并），prune 时再从本地删除已上传的记录
+// 删除范围截止到每台虚拟机读取到的最后一条记录，读取之后写入的记录（如迟到的代理推送）即使在删除前写入也会保留；
+// 有这类记录的虚拟机记录在 Kept 中，下次归档时重新合并
+func (a *Archiver) ArchiveMonth(ctx context.Context, month time.Time, prune bool) (MonthResult, error) {
+	start := a.monthStart(month)
+	end := start.AddDate(0, 1, 0)
+	last := end.Add(-time.Nanosecond)
+	result := MonthResult{Month: start.Format(MonthLayout), Key: a.Key(start)}
+
+	vmids, err := storage.RecordVMIDs(a.storage, start, end)
+	if err != nil || len(vmids) == 0 {
+		return result, err
+	}
+	local := make(map[int]int, len(vmids))
+	uploaded := make(map[int]time.Time, len(vmids))
+	var records []models.TrafficRecord
+	for _, vmid := range vmids {
+		vmRecords, err := a.storage.GetTrafficRecords(vmid, start, last)
+		if err != nil {
+			return result, fmt.Errorf("读取虚拟机 %d 的流量记录失败: %w", vmid, err)
+		}
+		local[vmid] = len(vmRecords)
+		for _, record := range vmRecords {
+			if record.Timestamp.After(uploaded[vmid]) {
+				uploaded[vmid] = record.Timestamp
+			}
+		}
+		records = append(records, vmRecords...)
+	}
+
+	existing, err := a.download(ctx, result.Key)
+	if err != nil && !errors.Is(err, objstore.ErrNotFound) {
+		return result, err
+	}
+	records = merge(existing, records)
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	body, err := encode(records)
+	if err != nil {
+		return result, err
+	}
+	if err := a.objects.Put(ctx, result.Key, body, "application/gzip"); err != nil {
+		return result, fmt.Errorf("上传 %s 失败: %w", result.Key, err)
+	}
+	result.Records, result.Bytes = len(records), len(body)
+	result.VMs = countVMs(records)
+
+	if !prune {
+		return result, nil
+	}
+	for _, vmid := range vmids {
+		if local[vmid] == 0 {
+			continue
+		}
+		// 已上传的时间范围内有新写入的记录时整台虚拟机保留（范围内的写入不按时间顺序，无法只删除已上传的记录）
+		bound := uploaded[vmid]
+		count, err := a.storage.CountRecordsInRange(vmid, start, bound)
+		if err != nil {
+			return result, fmt.Errorf("统计虚拟机 %d 的流量记录失败: %w", vmid, err)
+		}
+		if count != int64(local[vmid]) {
+			result.Kept = append(result.Kept, vmid)
+			continue
+		}
+		deleted, err := a.storage.DeleteRecordsInRange(vmid, start, bound)
+		result.Pruned += deleted
+		if err != nil {
+			return result, fmt.Errorf("删除虚拟机 %d 的本地记录失败: %w", vmid, err)
+		}
+		remaining, err := a.storage.CountRecordsInRange(vmid, start, last)
+		if err != nil {
+			return result, fmt.Errorf("统计虚拟机 %d 的流量记录失败: %w", vmid, err)
+		}
+		if remaining > 0 {
+			result.Kept = append(result.Kept, vmid)
+		}
+	}
+	return result, nil
+}
+
+// List 对象存储中已归档的月份
+func (a *Archiver) List(ctx context.Context) ([]objstore.Object, error) {
+	objects, err := a.objects.List(ctx, a.prefix+"traffic/")
+	if err != nil {
+		return nil, fmt.Errorf("列出归档失败: %w", err)
+	}
+	return objects, nil
+}
+
+// Restore 把归档的月份写回本地存储（vmid=0 表示所有虚拟机）
+// 本地已有该月记录的虚拟机跳过，避免流量重复计算（需先清除本地记录再恢复）
+func (a *Archiver) Restore(ctx context.Context, month time.Time, vmid int, dryRun bool) (RestoreResult, error) {
+	start := a.monthStart(month)
+	last := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	result := RestoreResult{Month: start.Format(MonthLayout)}
+
+	records, err := a.download(ctx, a.Key(start))
+	if err != nil {
+		return result, err
+	}
+
+	byVM := make(map[int][]models.TrafficRecord)
+	for _, record := range records {
+		if vmid == 0 || record.VMID == vmid {
+			byVM[record.VMID] = append(byVM[record.VMID], record)
+		}
+	}
+	vmids := make([]int, 0, len(byVM))
+	for id := range byVM {
+		vmids = append(vmids, id)
+	}
+	sort.Ints(vmids)
+
+	var restore []models.TrafficRecord
+	for _, id := range vmids {
+		existing, err := a.storage.CountRecordsInRange(id, start, last)
+		if err != nil {
+			return result, fmt.Errorf("统计虚拟机 %d 的流量记录失败: %w", id, err)
+		}
+		if existing > 0 {
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+		restore = append(restore, byVM[id]...)
+		result.VMs++
+	}
+	result.Records = len(restore)
+	if dryRun {
+		return result, nil
+	}
+
+	for i := 0; i < len(restore); i += restoreBatchSize {
+		batch := restore[i:min(i+restoreBatchSize, len(restore))]
+		if err := storage.SaveTrafficRecords(a.storage, batch); err != nil {
+			return result, fmt.Errorf("写入流量记录失败（已写入 %d 条）: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// download 下载并解码归档对象
+func (a *Archiver) download(ctx context.Context, key string) ([]models.TrafficRecord, error) {
+	data, err := a.objects.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("下载 %s 失败: %w", key, err)
+	}
+	records, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", key, err)
+	}
+	return records, nil
+}
+
+// encode 把流量记录编码为 gzip 压缩的 JSON Lines
+func encode(records []models.TrafficRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, fmt.Errorf("序列化流量记录失败: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("压缩流量记录失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decode 解码 gzip 压缩的 JSON Lines
+func decode(data []byte) ([]models.TrafficRecord, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var records []models.TrafficRecord
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record models.TrafficRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// merge 合并对象中已有的记录和本地记录（同一虚拟机同一时间的记录以本地为准），按虚拟机和时间排序
+func merge(existing, local []models.TrafficRecord) []models.TrafficRecord {
+	type recordKey struct {
+		vmid int
+		ts   int64
+	}
+	seen := make(map[recordKey]bool, len(local))
+	merged := make([]models.TrafficRecord, 0, len(existing)+len(local))
+	for _, record := range local {
+		seen[recordKey{record.VMID, record.Timestamp.UnixNano()}] = true
+		merged = append(merged, record)
+	}
+	for _, record := range existing {
+		if !seen[recordKey{record.VMID, record.Timestamp.UnixNano()}] {
+			merged = append(merged, record)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].VMID != merged[j].VMID {
+			return merged[i].VMID < merged[j].VMID
+		}
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged
+}
+
+// countVMs 记录涉及的虚拟机数量
+func countVMs(records []models.TrafficRecord) int {
+	vms := make(map[int]bool)
+	for _, record := range records {
+		vms[record.VMID] = true
+	}
+	return len(vms)
+}
@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/objstore"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// memoryObjects 内存中的对象存储
+type memoryObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memoryObjects) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[key] = append([]byte(nil), body...)
+	return nil
+}
+
+func (m *memoryObjects) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, objstore.ErrNotFound)
+	}
+	return data, nil
+}
+
+func (m *memoryObjects) List(ctx context.Context, prefix string) ([]objstore.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var objects []objstore.Object
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, objstore.Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func newTestStorage(t *testing.T) storage.Interface {
+	t.Helper()
+	store, err := storage.NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestArchiveAndRestoreMonth(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStorage(t)
+	objects := &memoryObjects{}
+	a := New(store, objects, "pve/", time.UTC)
+
+	sep := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	var records []models.TrafficRecord
+	for day := 0; day < 3; day++ {
+		for _, vmid := range []int{101, 102} {
+			records = append(records, models.TrafficRecord{VMID: vmid, Timestamp: sep.AddDate(0, 0, day*10), RXBytes: uint64(day * 100), TotalBytes: uint64(day * 100)})
+		}
+	}
+	// 下个月的记录不归档
+	records = append(records, models.TrafficRecord{VMID: 101, Timestamp: sep.AddDate(0, 1, 0), RXBytes: 500, TotalBytes: 500})
+	if err := storage.SaveTrafficRecords(store, records); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	months, err := a.PendingMonths(time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC), 1)
+	if err != nil || len(months) != 1 || !months[0].Equal(sep) {
+		t.Fatalf("pending months = %v, %v, want only 2026-09 (2026-10 kept locally)", months, err)
+	}
+
+	result, err := a.ArchiveMonth(ctx, sep, true)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if result.Key != "pve/traffic/2026-09.jsonl.gz" || result.Records != 6 || result.VMs != 2 || result.Pruned != 6 || len(result.Kept) != 0 {
+		t.Fatalf("archive result = %+v", result)
+	}
+	if n, _ := store.CountRecordsInRange(0, sep, sep.AddDate(0, 1, 0).Add(-time.Nanosecond)); n != 0 {
+		t.Fatalf("local records after prune = %d, want 0", n)
+	}
+	if n, _ := store.CountRecordsInRange(101, sep.AddDate(0, 1, 0), sep.AddDate(0, 1, 0)); n != 1 {
+		t.Fatalf("next month records = %d, want 1", n)
+	}
+
+	restored, err := a.Restore(ctx, sep, 102, false)
+	if err != nil || restored.Records != 3 || restored.VMs != 1 {
+		t.Fatalf("restore VM 102 = %+v, %v", restored, err)
+	}
+	got, _ := store.GetTrafficRecords(102, sep, sep.AddDate(0, 1, 0))
+	if len(got) != 3 || got[2].RXBytes != 200 {
+		t.Fatalf("restored records = %+v", got)
+	}
+
+	// 只恢复了一台虚拟机，再次归档时与对象中的记录合并，不丢失另一台虚拟机的记录
+	result, err = a.ArchiveMonth(ctx, sep, true)
+	if err != nil || result.Records != 6 || result.Pruned != 3 {
+		t.Fatalf("re-archive = %+v, %v", result, err)
+	}
+
+	restored, err = a.Restore(ctx, sep, 0, false)
+	if err != nil || restored.Records != 6 || len(restored.Skipped) != 0 {
+		t.Fatalf("restore all = %+v, %v", restored, err)
+	}
+	restored, err = a.Restore(ctx, sep, 0, true)
+	if err != nil || restored.Records != 0 || len(restored.Skipped) != 2 {
+		t.Fatalf("restore over existing = %+v, %v, want both VMs skipped", restored, err)
+	}
+
+	if _, err := a.Restore(ctx, sep.AddDate(0, -1, 0), 0, false); err == nil {
+		t.Fatal("restore of a month without archive succeeded")
+	}
+}
+
+// lateWriteStorage 第一次统计某台虚拟机的记录时写入一条迟到的记录（模拟统计和删除之间的代理推送）
+type lateWriteStorage struct {
+	storage.Interface
+	late    models.TrafficRecord
+	written bool
+}
+
+func (s *lateWriteStorage) Unwrap() storage.Interface { return s.Interface }
+
+func (s *lateWriteStorage) CountRecordsInRange(vmid int, startTime, endTime time.Time) (int64, error) {
+	count, err := s.Interface.CountRecordsInRange(vmid, startTime, endTime)
+	if vmid == s.late.VMID && !s.written {
+		s.written = true
+		if err := s.Interface.SaveTrafficRecord(s.late); err != nil {
+			return 0, err
+		}
+	}
+	return count, err
+}
+
+func TestArchiveMonthKeepsRecordsWrittenBeforeDelete(t *testing.T) {
+	ctx := context.Background()
+	sep := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	late := models.TrafficRecord{VMID: 101, Timestamp: sep.AddDate(0, 0, 25), RXBytes: 900, TotalBytes: 900}
+	store := &lateWriteStorage{Interface: newTestStorage(t), late: late}
+	a := New(store, &memoryObjects{}, "pve/", time.UTC)
+
+	if err := storage.SaveTrafficRecords(store, []models.TrafficRecord{
+		{VMID: 101, Timestamp: sep, RXBytes: 100, TotalBytes: 100},
+		{VMID: 101, Timestamp: sep.AddDate(0, 0, 10), RXBytes: 300, TotalBytes: 300},
+	}); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	result, err := a.ArchiveMonth(ctx, sep, true)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if result.Records != 2 || result.Pruned != 2 || len(result.Kept) != 1 || result.Kept[0] != 101 {
+		t.Fatalf("archive result = %+v, want the uploaded records pruned and VM 101 kept", result)
+	}
+	got, err := store.GetTrafficRecords(101, sep, sep.AddDate(0, 1, 0))
+	if err != nil || len(got) != 1 || !got[0].Timestamp.Equal(late.Timestamp) {
+		t.Fatalf("local records = %+v, %v, want only the late record that was not uploaded", got, err)
+	}
+}
@@ -43,7 +43,7 @@ func (c *TrafficCache) Get(vmid int, period string, direction string, periodStar
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	key := c.makeKey(vmid, period, direction)
+	key := c.makeKey(vmid, period, direction, periodStart)
 	cached, exists := c.cache[key]
 	if !exists {
 		return nil, false
@@ -67,7 +67,7 @@ func (c *TrafficCache) Set(vmid int, period string, direction string, periodStar
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	key := c.makeKey(vmid, period, direction)
+	key := c.makeKey(vmid, period, direction, periodStart)
 	c.cache[key] = &CachedStats{
 		Stats:       stats,
 		LastUpdate:  time.Now(),
@@ -115,9 +115,9 @@ func (c *TrafficCache) GetStats() (total, expired int) {
 	return total, expired
 }
 
-// makeKey 生成缓存键
-func (c *TrafficCache) makeKey(vmid int, period string, direction string) string {
-	return fmt.Sprintf("%d:%s:%s", vmid, period, direction)
+// makeKey 生成缓存键（包含周期开始时间，固定周期和按创建时间划分的周期分别缓存）
+func (c *TrafficCache) makeKey(vmid int, period string, direction string, periodStart time.Time) string {
+	return fmt.Sprintf("%d:%s:%s:%d", vmid, period, direction, periodStart.Unix())
 }
 
 // cleanupLoop 定期清理过期缓存
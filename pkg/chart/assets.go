@@ -0,0 +1,80 @@
+package chart
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+// EChartsVersion 内嵌和 CDN 使用的 ECharts 版本
+const EChartsVersion = "5.4.3"
+
+// echartsCDN HTML 引用 ECharts 的 CDN 地址（go-echarts 的 AssetsHost）
+const echartsCDN = "https://cdn.jsdelivr.net/npm/echarts@" + EChartsVersion + "/dist/"
+
+// assets 编译时内嵌的资源（echarts.min.js 由 make echarts-asset 获取，不存在时 HTML 引用 CDN）
+//
+//go:embed assets
+var assets embed.FS
+
+var warnCDNOnce sync.Once
+
+// EChartsEmbedded 程序中是否内嵌了 echarts.min.js
+func EChartsEmbedded() bool {
+	_, err := assets.ReadFile("assets/echarts.min.js")
+	return err == nil
+}
+
+// newPage 创建引用 CDN 的 go-echarts 页面（renderPage 输出时再替换为内嵌脚本）
+func newPage(title string) *components.Page {
+	page := components.NewPage()
+	page.PageTitle = title
+	page.AssetsHost = echartsCDN
+	return page
+}
+
+// renderPage 输出 HTML 页面：默认内嵌 echarts.min.js（离线可用），设置了 CDN 或程序中没有内嵌时引用 CDN
+func (e *Exporter) renderPage(page *components.Page, w io.Writer) error {
+	if e.options.CDN {
+		return page.Render(w)
+	}
+	js, err := assets.ReadFile("assets/echarts.min.js")
+	if err != nil {
+		warnCDNOnce.Do(func() {
+			log.Printf("程序中没有内嵌 ECharts（运行 make echarts-asset 后重新编译），导出的 HTML 需要访问 %s", echartsCDN)
+		})
+		return page.Render(w)
+	}
+
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		return err
+	}
+	html, ok := inlineScript(buf.Bytes(), echartsCDN+"echarts.min.js", js)
+	if !ok {
+		return fmt.Errorf("HTML 中没有找到 ECharts 脚本引用")
+	}
+	_, err = w.Write(html)
+	return err
+}
+
+// inlineScript 将 <script src="src"></script> 替换为内嵌脚本
+// 脚本中的 "</script" 转义为 "<\/script"，避免提前结束 script 标签（只会出现在字符串、正则或注释中）
+func inlineScript(html []byte, src string, js []byte) ([]byte, bool) {
+	tag := []byte(`<script src="` + src + `"></script>`)
+	if !bytes.Contains(html, tag) {
+		return html, false
+	}
+	js = bytes.ReplaceAll(js, []byte("</script"), []byte(`<\/script`))
+
+	inline := make([]byte, 0, len(js)+64)
+	inline = append(inline, "<script type=\"text/javascript\">\n"...)
+	inline = append(inline, js...)
+	inline = append(inline, "\n</script>"...)
+	return bytes.Replace(html, tag, inline, 1), true
+}
@@ -0,0 +1,32 @@
+package chart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+func TestInlineScript(t *testing.T) {
+	line := charts.NewLine()
+	line.SetXAxis([]string{"10:00"}).AddSeries("Total", []opts.LineData{{Value: 1}})
+	page := newPage("test")
+	page.AddCharts(line)
+
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html, ok := inlineScript(buf.Bytes(), echartsCDN+"echarts.min.js", []byte(`var echarts={s:"</script>"};`))
+	if !ok {
+		t.Fatalf("script tag for %secharts.min.js not found in:\n%.500s", echartsCDN, buf.String())
+	}
+	if strings.Contains(string(html), "cdn.jsdelivr.net") {
+		t.Errorf("html still references the CDN")
+	}
+	if !strings.Contains(string(html), `var echarts={s:"<\/script>"};`) {
+		t.Errorf("inline script not escaped")
+	}
+}
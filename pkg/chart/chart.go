@@ -2,9 +2,12 @@ package chart
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/objstore"
+	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/storage"
 	"time"
 
@@ -15,6 +18,11 @@ import (
 // Exporter 图表导出器
 type Exporter struct {
 	exportPath string
+	options    RenderOptions
+	naming     FileNaming
+
+	uploader     objstore.Uploader // 设置后导出文件上传到远程目标
+	uploadPrefix string
 }
 
 // NewExporter 创建新的图表导出器
@@ -28,21 +36,76 @@ func NewExporter(exportPath string) (*Exporter, error) {
 	}, nil
 }
 
+// SetRenderOptions 设置之后导出的图表格式（png/svg）、尺寸和标题模板，需要先通过 Validate 检查
+func (e *Exporter) SetRenderOptions(options RenderOptions) {
+	e.options = options
+}
+
 // ExportTrafficChart 导出流量图表（显示时间序列的流量变化趋势）
 func (e *Exporter) ExportTrafficChart(vmid int, vmName string, records []models.TrafficRecord) (string, error) {
-	return e.ExportTrafficChartWithRangeAndPeriod(vmid, vmName, records, time.Time{}, time.Time{}, "hour")
+	return e.ExportTrafficChartWithRangeAndPeriod(vmid, vmName, records, time.Time{}, time.Time{}, e.options.period())
 }
 
-// ExportTrafficChartWithRange 导出流量图表（带时间范围信息，默认按小时聚合）
+// ExportTrafficChartWithRange 导出流量图表（带时间范围信息，聚合周期见 RenderOptions.Period，默认按小时）
 func (e *Exporter) ExportTrafficChartWithRange(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time) (string, error) {
-	return e.ExportTrafficChartWithRangeAndPeriod(vmid, vmName, records, startTime, endTime, "hour")
+	return e.ExportTrafficChartWithRangeAndPeriod(vmid, vmName, records, startTime, endTime, e.options.period())
 }
 
-// ExportTrafficChartWithRangeAndPeriod 导出流量图表（带时间范围和自定义聚合周期）
-// period: minute/hour/day/month
+// ExportTrafficChartWithRangeAndPeriod 导出流量图表（带时间范围和自定义聚合周期，格式和尺寸见 SetRenderOptions）
+// period: minute/hour/day/month，或步长如 15m/6h/1d/auto
 func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, period string) (string, error) {
+	graph, err := trafficGraph(vmid, vmName, records, startTime, endTime, period, e.options)
+	if err != nil {
+		return "", err
+	}
+
+	// 生成文件名（包含时间范围信息）
+	timestamp := time.Now().Format("20060102_150405")
+	var filename string
+	if !startTime.IsZero() && !endTime.IsZero() {
+		dateRange := fmt.Sprintf("%s_to_%s",
+			startTime.Format("20060102"),
+			endTime.Format("20060102"))
+		filename = filepath.Join(e.exportPath,
+			fmt.Sprintf("vm_%d_traffic_%s_%s.%s", vmid, dateRange, timestamp, e.options.Ext()))
+	} else {
+		filename = filepath.Join(e.exportPath,
+			fmt.Sprintf("vm_%d_traffic_%s.%s", vmid, timestamp, e.options.Ext()))
+	}
+
+	// 保存图表
+	f, filename, err := e.createFile(filename, fileVars{Kind: "vm", VMID: vmid, Name: vmName, Period: period, Direction: models.DirectionBoth, Start: startTime, End: endTime, Ext: e.options.Ext()})
+	if err != nil {
+		return "", fmt.Errorf("创建图表文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := graph.Render(e.options.renderer(), f); err != nil {
+		return "", fmt.Errorf("failed to render chart: %w", err)
+	}
+
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	return filename, nil
+}
+
+// RenderTrafficChart 将流量图表写入 w（格式、尺寸和标题由 options 指定，API 直接返回图片时使用）
+func RenderTrafficChart(w io.Writer, vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, period string, options RenderOptions) error {
+	graph, err := trafficGraph(vmid, vmName, records, startTime, endTime, period, options)
+	if err != nil {
+		return err
+	}
+	if err := graph.Render(options.renderer(), w); err != nil {
+		return fmt.Errorf("failed to render chart: %w", err)
+	}
+	return nil
+}
+
+// trafficGraph 生成流量图表
+func trafficGraph(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, period string, options RenderOptions) (chart.Chart, error) {
 	if len(records) == 0 {
-		return "", fmt.Errorf("no traffic records")
+		return chart.Chart{}, fmt.Errorf("no traffic records")
 	}
 
 	// 验证 period 参数
@@ -52,10 +115,10 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 
 	// 按时间段聚合数据，显示趋势而不是累计值
 	// 使用共用的聚合函数，与API保持一致
-	aggregated := storage.AggregateTrafficByPeriod(records, period)
+	aggregated, timeFormat := aggregateForChart(records, startTime, endTime, period)
 
 	if len(aggregated) == 0 {
-		return "", fmt.Errorf("no aggregated data")
+		return chart.Chart{}, fmt.Errorf("no aggregated data")
 	}
 
 	// 先找出最大流量值,决定使用什么单位
@@ -91,96 +154,81 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 		yValuesTotal[i] = float64(point.TotalBytes) / divisor
 	}
 
-	// 创建图表标题（包含时间范围）
+	// 创建图表标题（包含时间范围），可由模板覆盖
 	title := fmt.Sprintf("VM %s (ID: %d) Traffic Statistics", vmName, vmid)
 	if !startTime.IsZero() && !endTime.IsZero() {
 		title = fmt.Sprintf("VM %s (ID: %d) Traffic (%s - %s)",
 			vmName, vmid,
-			startTime.Format("01-02 15:04"),
-			endTime.Format("01-02 15:04"))
+			displayTime(startTime).Format("01-02 15:04"),
+			displayTime(endTime).Format("01-02 15:04"))
 	}
+	labels := LabelData{VMID: vmid, Name: vmName, Period: period, Direction: models.DirectionBoth, Unit: unitLabel}
+	labels.Start, labels.End = rangeLabel(startTime, endTime)
+	width, height := options.size(DefaultWidth, DefaultHeight)
 
-	// 根据 period 选择时间显示格式
-	var timeFormat string
-	switch period {
-	case "minute":
-		timeFormat = "01-02 15:04"
-	case "hour":
-		timeFormat = "01-02 15:04"
-	case "day":
-		timeFormat = "01-02"
-	case "month":
-		timeFormat = "2006-01"
-	default:
-		timeFormat = "01-02 15:04"
-	}
-
-	// 现代化配色方案（与前端一致）
-	colorDownload := drawing.Color{R: 54, G: 162, B: 235, A: 255}    // 蓝色
-	colorUpload := drawing.Color{R: 75, G: 192, B: 192, A: 255}      // 青色
-	colorTotal := drawing.Color{R: 255, G: 99, B: 132, A: 255}       // 红色
-	colorDownloadFill := drawing.Color{R: 54, G: 162, B: 235, A: 50} // 半透明蓝
-	colorUploadFill := drawing.Color{R: 75, G: 192, B: 192, A: 50}   // 半透明青
-	colorGrid := drawing.Color{R: 230, G: 230, B: 230, A: 255}       // 浅灰网格
-	colorBackground := drawing.Color{R: 250, G: 250, B: 250, A: 255} // 浅色背景
+	// 配色与前端一致，Dark 时使用暗色配色
+	colors := options.palette()
+	colorDownloadFill := colors.Download.WithAlpha(50) // 半透明填充
+	colorUploadFill := colors.Upload.WithAlpha(50)
 
 	// 创建现代化图表
 	graph := chart.Chart{
-		Title: title,
+		Title: label(options.Title, title, labels),
 		TitleStyle: chart.Style{
 			FontSize:  20,
-			FontColor: drawing.Color{R: 44, G: 62, B: 80, A: 255}, // 深色标题
+			FontColor: colors.Title,
 			Padding:   chart.Box{Top: 10, Bottom: 10},
 		},
-		Width:  1600, // 更宽的图表
-		Height: 800,  // 更高的图表
+		Width:  width,
+		Height: height,
+		DPI:    options.DPI,
 		Background: chart.Style{
-			FillColor: colorBackground,
+			FillColor: colors.Background,
 			Padding:   chart.Box{Top: 50, Left: 50, Right: 50, Bottom: 50},
 		},
 		Canvas: chart.Style{
-			FillColor: drawing.Color{R: 255, G: 255, B: 255, A: 255}, // 白色画布
+			FillColor: colors.Canvas,
 		},
 		XAxis: chart.XAxis{
-			Name: "Time",
+			Name: label(options.XLabel, "Time", labels),
 			NameStyle: chart.Style{
 				FontSize:  14,
-				FontColor: drawing.Color{R: 52, G: 73, B: 94, A: 255},
+				FontColor: colors.AxisName,
 			},
 			Style: chart.Style{
 				FontSize:    11,
-				FontColor:   drawing.Color{R: 100, G: 100, B: 100, A: 255},
-				StrokeColor: drawing.Color{R: 200, G: 200, B: 200, A: 255},
+				FontColor:   colors.AxisText,
+				StrokeColor: colors.AxisStroke,
 				StrokeWidth: 1,
 			},
-			ValueFormatter: chart.TimeValueFormatterWithFormat(timeFormat),
+			ValueFormatter: timeValueFormatter(timeFormat),
 			GridMajorStyle: chart.Style{
-				StrokeColor: colorGrid,
+				StrokeColor: colors.Grid,
 				StrokeWidth: 1,
 			},
 			GridMinorStyle: chart.Style{
-				StrokeColor: drawing.Color{R: 245, G: 245, B: 245, A: 255},
+				StrokeColor: colors.GridMinor,
 				StrokeWidth: 0.5,
 			},
 		},
 		YAxis: chart.YAxis{
-			Name: fmt.Sprintf("Traffic (%s)", unitLabel),
+			Name: label(options.YLabel, fmt.Sprintf("Traffic (%s)", unitLabel), labels),
 			NameStyle: chart.Style{
 				FontSize:  14,
-				FontColor: drawing.Color{R: 52, G: 73, B: 94, A: 255},
+				FontColor: colors.AxisName,
 			},
 			Style: chart.Style{
 				FontSize:    11,
-				FontColor:   drawing.Color{R: 100, G: 100, B: 100, A: 255},
-				StrokeColor: drawing.Color{R: 200, G: 200, B: 200, A: 255},
+				FontColor:   colors.AxisText,
+				StrokeColor: colors.AxisStroke,
 				StrokeWidth: 1,
 			},
 			GridMajorStyle: chart.Style{
-				StrokeColor: colorGrid,
+				StrokeColor: colors.Grid,
 				StrokeWidth: 1,
 			},
 			GridMinorStyle: chart.Style{
-				StrokeColor: drawing.Color{R: 245, G: 245, B: 245, A: 255},
+				StrokeColor: colors.GridMinor,
 				StrokeWidth: 0.5,
 			},
 		},
@@ -198,7 +246,7 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 			chart.TimeSeries{
 				Name: "Download (RX)",
 				Style: chart.Style{
-					StrokeColor: colorDownload,
+					StrokeColor: colors.Download,
 					StrokeWidth: 3,
 					DotWidth:    4,
 				},
@@ -218,7 +266,7 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 			chart.TimeSeries{
 				Name: "Upload (TX)",
 				Style: chart.Style{
-					StrokeColor: colorUpload,
+					StrokeColor: colors.Upload,
 					StrokeWidth: 3,
 					DotWidth:    4,
 				},
@@ -229,7 +277,7 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 			chart.TimeSeries{
 				Name: "Total",
 				Style: chart.Style{
-					StrokeColor: colorTotal,
+					StrokeColor: colors.Total,
 					StrokeWidth: 4,
 					DotWidth:    6,
 				},
@@ -241,43 +289,29 @@ func (e *Exporter) ExportTrafficChartWithRangeAndPeriod(vmid int, vmName string,
 
 	// 添加图例
 	graph.Elements = []chart.Renderable{
-		chart.Legend(&graph),
-	}
-
-	// 生成文件名（包含时间范围信息）
-	timestamp := time.Now().Format("20060102_150405")
-	var filename string
-	if !startTime.IsZero() && !endTime.IsZero() {
-		dateRange := fmt.Sprintf("%s_to_%s",
-			startTime.Format("20060102"),
-			endTime.Format("20060102"))
-		filename = filepath.Join(e.exportPath,
-			fmt.Sprintf("vm_%d_traffic_%s_%s.png", vmid, dateRange, timestamp))
-	} else {
-		filename = filepath.Join(e.exportPath,
-			fmt.Sprintf("vm_%d_traffic_%s.png", vmid, timestamp))
+		chart.Legend(&graph, chart.Style{
+			FillColor:   colors.Canvas,
+			FontColor:   colors.AxisText,
+			StrokeColor: colors.AxisStroke,
+		}),
 	}
 
-	// 保存图表
-	f, err := os.Create(filename)
-	if err != nil {
-		return "", fmt.Errorf("创建图表文件失败: %w", err)
-	}
-	defer f.Close()
-
-	if err := graph.Render(chart.PNG, f); err != nil {
-		return "", fmt.Errorf("failed to render chart: %w", err)
-	}
-
-	return filename, nil
+	return graph, nil
 }
 
 // ExportStatsChart 导出统计图表（柱状图）
 // direction: both(全部)/rx(下载)/tx(上传)
 func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction string) (string, error) {
+	return e.ExportStatsChartWithRange(stats, direction, time.Time{}, time.Time{})
+}
+
+// ExportStatsChartWithRange 导出统计图表（带时间范围信息，未指定时使用统计周期的范围）
+func (e *Exporter) ExportStatsChartWithRange(stats []models.TrafficStats, direction string, startTime, endTime time.Time) (string, error) {
 	if len(stats) == 0 {
 		return "", fmt.Errorf("no statistics data")
 	}
+	startTime, endTime = statsRange(stats, startTime, endTime)
+	colors := e.options.palette()
 
 	// 按流量大小排序（从大到小）
 	sortedStats := make([]models.TrafficStats, len(stats))
@@ -348,21 +382,18 @@ func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction strin
 	// 为每个VM创建柱状条（根据方向参数决定显示哪些柱子）
 	for _, stat := range sortedStats {
 		var bytes uint64
-		var fillColor, strokeColor drawing.Color
+		var color drawing.Color
 
 		switch direction {
 		case "both":
 			bytes = stat.TotalBytes
-			fillColor = drawing.Color{R: 255, G: 99, B: 132, A: 255} // 完全不透明的红色
-			strokeColor = drawing.Color{R: 220, G: 50, B: 90, A: 255}
+			color = colors.Total
 		case "rx":
 			bytes = stat.RXBytes
-			fillColor = drawing.Color{R: 54, G: 162, B: 235, A: 255} // 完全不透明的蓝色
-			strokeColor = drawing.Color{R: 30, G: 130, B: 200, A: 255}
+			color = colors.Download
 		case "tx":
 			bytes = stat.TXBytes
-			fillColor = drawing.Color{R: 75, G: 192, B: 192, A: 255} // 完全不透明的青色
-			strokeColor = drawing.Color{R: 40, G: 160, B: 160, A: 255}
+			color = colors.Upload
 		}
 
 		value := float64(bytes) / divisor
@@ -389,17 +420,13 @@ func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction strin
 			Label: label,
 			Value: value,
 			Style: chart.Style{
-				FillColor:   fillColor,
-				StrokeColor: strokeColor,
+				FillColor:   color.WithAlpha(220),
+				StrokeColor: color,
 				StrokeWidth: 2,
 			},
 		})
 	}
 
-	// 现代化配色方案 - 网格线使用合适的颜色和透明度
-	colorGrid := drawing.Color{R: 220, G: 220, B: 220, A: 120} // 半透明网格线,清晰但不遮挡
-	colorBackground := drawing.Color{R: 250, G: 250, B: 250, A: 255}
-
 	// 根据方向生成标题
 	var title string
 	switch direction {
@@ -411,6 +438,14 @@ func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction strin
 		title = "VM Traffic Statistics Summary"
 	}
 
+	// 添加时间范围信息
+	if !startTime.IsZero() && !endTime.IsZero() {
+		title = fmt.Sprintf("%s (%s - %s)",
+			title,
+			displayTime(startTime).Format("01-02 15:04"),
+			displayTime(endTime).Format("01-02 15:04"))
+	}
+
 	// Y轴标签
 	yAxisName := fmt.Sprintf("Traffic (%s)", unitLabel)
 
@@ -433,43 +468,49 @@ func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction strin
 		barWidth = (chartWidth-200)/vmCount - 20
 	}
 
+	// 标题和尺寸可由导出参数覆盖
+	labels := LabelData{Period: stats[0].Period, Direction: direction, Unit: unitLabel}
+	labels.Start, labels.End = rangeLabel(startTime, endTime)
+	chartWidth, chartHeight := e.options.size(chartWidth, 700) // 默认高度从900降到700
+
 	// 创建柱状图
 	graph := chart.BarChart{
-		Title: title,
+		Title: label(e.options.Title, title, labels),
 		TitleStyle: chart.Style{
 			FontSize:  24,
-			FontColor: drawing.Color{R: 44, G: 62, B: 80, A: 255},
+			FontColor: colors.Title,
 			Padding:   chart.Box{Top: 15, Bottom: 15},
 		},
 		Width:  chartWidth,
-		Height: 700, // 减小高度,从900降到700
+		Height: chartHeight,
+		DPI:    e.options.DPI,
 		Background: chart.Style{
-			FillColor: colorBackground,
+			FillColor: colors.Background,
 			Padding:   chart.Box{Top: 50, Left: 100, Right: 50, Bottom: 50}, // 减小底部内边距
 		},
 		Canvas: chart.Style{
-			FillColor: drawing.Color{R: 255, G: 255, B: 255, A: 255},
+			FillColor: colors.Canvas,
 		},
 		XAxis: chart.Style{
 			FontSize:    12,
-			FontColor:   drawing.Color{R: 80, G: 80, B: 80, A: 255},
-			StrokeColor: drawing.Color{R: 180, G: 180, B: 180, A: 255},
+			FontColor:   colors.AxisText,
+			StrokeColor: colors.AxisStroke,
 			StrokeWidth: 1.5,
 		},
 		YAxis: chart.YAxis{
-			Name: yAxisName,
+			Name: label(e.options.YLabel, yAxisName, labels),
 			NameStyle: chart.Style{
 				FontSize:  16,
-				FontColor: drawing.Color{R: 52, G: 73, B: 94, A: 255},
+				FontColor: colors.AxisName,
 			},
 			Style: chart.Style{
 				FontSize:    13,
-				FontColor:   drawing.Color{R: 80, G: 80, B: 80, A: 255},
-				StrokeColor: drawing.Color{R: 180, G: 180, B: 180, A: 255},
+				FontColor:   colors.AxisText,
+				StrokeColor: colors.AxisStroke,
 				StrokeWidth: 1.5,
 			},
 			GridMajorStyle: chart.Style{
-				StrokeColor: colorGrid,
+				StrokeColor: colors.Grid,
 				StrokeWidth: 1.0, // 适中的网格线宽度
 			},
 		},
@@ -481,21 +522,91 @@ func (e *Exporter) ExportStatsChart(stats []models.TrafficStats, direction strin
 	timestamp := time.Now().Format("20060102_150405")
 	var filename string
 	if direction == "both" {
-		filename = filepath.Join(e.exportPath, fmt.Sprintf("traffic_stats_%s.png", timestamp))
+		filename = filepath.Join(e.exportPath, fmt.Sprintf("traffic_stats_%s.%s", timestamp, e.options.Ext()))
 	} else {
-		filename = filepath.Join(e.exportPath, fmt.Sprintf("traffic_stats_%s_%s.png", direction, timestamp))
+		filename = filepath.Join(e.exportPath, fmt.Sprintf("traffic_stats_%s_%s.%s", direction, timestamp, e.options.Ext()))
 	}
 
 	// 保存图表
-	f, err := os.Create(filename)
+	f, filename, err := e.createFile(filename, fileVars{Kind: "stats", Period: stats[0].Period, Direction: direction, Start: startTime, End: endTime, Ext: e.options.Ext()})
 	if err != nil {
 		return "", fmt.Errorf("failed to create chart file: %w", err)
 	}
 	defer f.Close()
 
-	if err := graph.Render(chart.PNG, f); err != nil {
+	if err := graph.Render(e.options.renderer(), f); err != nil {
 		return "", fmt.Errorf("failed to render chart: %w", err)
 	}
 
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
 	return filename, nil
 }
+
+// statsRange 统计导出的时间范围：未指定时使用各虚拟机统计周期的最早起点和最晚终点
+func statsRange(stats []models.TrafficStats, startTime, endTime time.Time) (time.Time, time.Time) {
+	if !startTime.IsZero() && !endTime.IsZero() {
+		return startTime, endTime
+	}
+	startTime, endTime = time.Time{}, time.Time{}
+	for _, stat := range stats {
+		if !stat.StartTime.IsZero() && (startTime.IsZero() || stat.StartTime.Before(startTime)) {
+			startTime = stat.StartTime
+		}
+		if stat.EndTime.After(endTime) {
+			endTime = stat.EndTime
+		}
+	}
+	return startTime, endTime
+}
+
+// aggregateForChart 按周期（minute/hour/day/month）或步长（如 15m、6h、1d、auto）聚合，返回数据点和横轴时间格式
+// 步长模式与 /api/history 一致：空桶填 0，点数超过默认上限时自动放大步长
+func aggregateForChart(records []models.TrafficRecord, startTime, endTime time.Time, period string) ([]storage.AggregatedPoint, string) {
+	if step, ok := storage.ParseBucketStep(period); ok {
+		points, step := storage.AggregateTrafficByStep(records, storage.BucketQuery{
+			Start:     startTime,
+			End:       endTime,
+			Step:      step,
+			Fill:      storage.FillZero,
+			MaxPoints: models.DefaultHistoryMaxPoints,
+		})
+		if step%(24*time.Hour) == 0 {
+			return points, "01-02"
+		}
+		return points, "01-02 15:04"
+	}
+
+	// 根据 period 选择时间显示格式
+	var timeFormat string
+	switch period {
+	case "day":
+		timeFormat = "01-02"
+	case "month":
+		timeFormat = "2006-01"
+	default:
+		timeFormat = "01-02 15:04"
+	}
+	return storage.AggregateTrafficByPeriod(records, period), timeFormat
+}
+
+// displayTime 转换为配置的时区（与周期边界一致）用于显示
+func displayTime(t time.Time) time.Time {
+	return t.In(periodcalc.Location())
+}
+
+// timeValueFormatter 按配置的时区格式化横轴时间（go-chart 默认使用主机本地时区）
+func timeValueFormatter(format string) chart.ValueFormatter {
+	return func(v interface{}) string {
+		switch typed := v.(type) {
+		case time.Time:
+			return displayTime(typed).Format(format)
+		case int64:
+			return displayTime(time.Unix(0, typed)).Format(format)
+		case float64:
+			return displayTime(time.Unix(0, int64(typed))).Format(format)
+		}
+		return ""
+	}
+}
@@ -2,29 +2,26 @@ package chart
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"pve-traffic-monitor/pkg/models"
-	"pve-traffic-monitor/pkg/storage"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
-	"github.com/go-echarts/go-echarts/v2/components"
 	"github.com/go-echarts/go-echarts/v2/opts"
 )
 
-// ExportHTMLChart 导出HTML图表（使用go-echarts）
+// ExportHTMLChart 导出HTML图表（使用go-echarts，isDark 或 RenderOptions.Dark 时使用暗色主题）
 func (e *Exporter) ExportHTMLChart(vmid int, vmName string, records []models.TrafficRecord, isDark bool) (string, error) {
-	return e.ExportHTMLChartWithRangeAndPeriod(vmid, vmName, records, time.Time{}, time.Time{}, "hour", isDark)
+	return e.ExportHTMLChartWithRangeAndPeriod(vmid, vmName, records, time.Time{}, time.Time{}, e.options.period(), isDark)
 }
 
-// ExportHTMLChartWithRange 导出HTML图表（带时间范围信息，默认按小时聚合）
+// ExportHTMLChartWithRange 导出HTML图表（带时间范围信息，聚合周期见 RenderOptions.Period，默认按小时）
 func (e *Exporter) ExportHTMLChartWithRange(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, isDark bool) (string, error) {
-	return e.ExportHTMLChartWithRangeAndPeriod(vmid, vmName, records, startTime, endTime, "hour", isDark)
+	return e.ExportHTMLChartWithRangeAndPeriod(vmid, vmName, records, startTime, endTime, e.options.period(), isDark)
 }
 
 // ExportHTMLChartWithRangeAndPeriod 导出HTML图表（带时间范围和自定义聚合周期）
-// period: minute/hour/day/month
+// period: minute/hour/day/month，或步长如 15m/6h/1d/auto
 func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, period string, isDark bool) (string, error) {
 	if len(records) == 0 {
 		return "", fmt.Errorf("no traffic records")
@@ -36,19 +33,18 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 	}
 
 	// 使用storage包的正确聚合函数（计算增量而非累积值）
-	aggregated := storage.AggregateTrafficByPeriod(records, period)
+	aggregated, timeFormat := aggregateForChart(records, startTime, endTime, period)
 
 	if len(aggregated) == 0 {
 		return "", fmt.Errorf("no aggregated data")
 	}
 
 	// 获取配色方案
+	isDark = isDark || e.options.Dark
 	colors := models.GetChartColors(isDark)
 
 	// 创建页面
-	page := components.NewPage()
-	page.PageTitle = fmt.Sprintf("VM %s (ID: %d) Traffic Statistics", vmName, vmid)
-	page.AssetsHost = "https://cdn.jsdelivr.net/npm/echarts@5.4.3/dist/"
+	page := newPage(fmt.Sprintf("VM %s (ID: %d) Traffic Statistics", vmName, vmid))
 
 	// 准备数据
 	var xAxis []string
@@ -74,21 +70,6 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 		unitLabel = "MB"
 	}
 
-	// 根据 period 选择时间显示格式
-	var timeFormat string
-	switch period {
-	case "minute":
-		timeFormat = "01-02 15:04"
-	case "hour":
-		timeFormat = "01-02 15:04"
-	case "day":
-		timeFormat = "01-02"
-	case "month":
-		timeFormat = "2006-01"
-	default:
-		timeFormat = "01-02 15:04"
-	}
-
 	for _, point := range aggregated {
 		xAxis = append(xAxis, point.Timestamp.Format(timeFormat))
 		rxValue := float64(point.RXBytes) / divisor
@@ -108,10 +89,15 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 	if !startTime.IsZero() && !endTime.IsZero() {
 		title = fmt.Sprintf("VM %s (ID: %d) Traffic (%s - %s)",
 			vmName, vmid,
-			startTime.Format("01-02 15:04"),
-			endTime.Format("01-02 15:04"))
+			displayTime(startTime).Format("01-02 15:04"),
+			displayTime(endTime).Format("01-02 15:04"))
 	}
 
+	// 标题和尺寸可由导出参数覆盖
+	labels := LabelData{VMID: vmid, Name: vmName, Period: period, Direction: models.DirectionBoth, Unit: unitLabel}
+	labels.Start, labels.End = rangeLabel(startTime, endTime)
+	width, height := e.options.size(DefaultWidth, DefaultHeight)
+
 	// 获取主题
 	theme := "light"
 	if isDark {
@@ -120,12 +106,12 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
-			Width:  "1600px",
-			Height: "800px",
+			Width:  fmt.Sprintf("%dpx", width),
+			Height: fmt.Sprintf("%dpx", height),
 			Theme:  theme,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title: title,
+			Title: label(e.options.Title, title, labels),
 			Left:  "center",
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
@@ -141,13 +127,13 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 			Top:    "15%",
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
-			Name: "Time",
+			Name: label(e.options.XLabel, "Time", labels),
 			AxisLabel: &opts.AxisLabel{
 				Rotate: 45,
 			},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name: "Traffic (" + unitLabel + ")",
+			Name: label(e.options.YLabel, "Traffic ("+unitLabel+")", labels),
 		}),
 	)
 
@@ -188,16 +174,19 @@ func (e *Exporter) ExportHTMLChartWithRangeAndPeriod(vmid int, vmName string, re
 	}
 
 	// 保存文件
-	f, err := os.Create(filename)
+	f, filename, err := e.createFile(filename, fileVars{Kind: "vm", VMID: vmid, Name: vmName, Period: period, Direction: models.DirectionBoth, Start: startTime, End: endTime, Ext: "html"})
 	if err != nil {
 		return "", fmt.Errorf("创建HTML文件失败: %w", err)
 	}
 	defer f.Close()
 
-	if err := page.Render(f); err != nil {
+	if err := e.renderPage(page, f); err != nil {
 		return "", fmt.Errorf("渲染HTML失败: %w", err)
 	}
 
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
 	return filename, nil
 }
 
@@ -206,13 +195,15 @@ func (e *Exporter) ExportStatsHTMLChart(stats []models.TrafficStats, direction s
 	return e.ExportStatsHTMLChartWithRange(stats, direction, time.Time{}, time.Time{}, isDark)
 }
 
-// ExportStatsHTMLChartWithRange 导出统计HTML图表（带时间范围信息）
+// ExportStatsHTMLChartWithRange 导出统计HTML图表（带时间范围信息，未指定时使用统计周期的范围）
 func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, direction string, startTime, endTime time.Time, isDark bool) (string, error) {
 	if len(stats) == 0 {
 		return "", fmt.Errorf("no statistics data")
 	}
+	startTime, endTime = statsRange(stats, startTime, endTime)
 
 	// 获取配色方案
+	isDark = isDark || e.options.Dark
 	colors := models.GetChartColors(isDark)
 
 	// 排序
@@ -309,9 +300,7 @@ func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, di
 	}
 
 	// 创建页面
-	page := components.NewPage()
-	page.PageTitle = "VM Traffic Statistics Summary"
-	page.AssetsHost = "https://cdn.jsdelivr.net/npm/echarts@5.4.3/dist/"
+	page := newPage("VM Traffic Statistics Summary")
 
 	// 创建柱状图
 	bar := charts.NewBar()
@@ -331,10 +320,15 @@ func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, di
 	if !startTime.IsZero() && !endTime.IsZero() {
 		title = fmt.Sprintf("%s (%s - %s)",
 			title,
-			startTime.Format("01-02 15:04"),
-			endTime.Format("01-02 15:04"))
+			displayTime(startTime).Format("01-02 15:04"),
+			displayTime(endTime).Format("01-02 15:04"))
 	}
 
+	// 标题和尺寸可由导出参数覆盖
+	labels := LabelData{Period: stats[0].Period, Direction: direction, Unit: unitLabel}
+	labels.Start, labels.End = rangeLabel(startTime, endTime)
+	width, height := e.options.size(DefaultWidth, 900)
+
 	// 获取主题
 	theme := "light"
 	if isDark {
@@ -343,12 +337,12 @@ func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, di
 
 	bar.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
-			Width:  "1600px",
-			Height: "900px",
+			Width:  fmt.Sprintf("%dpx", width),
+			Height: fmt.Sprintf("%dpx", height),
 			Theme:  theme,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title: title,
+			Title: label(e.options.Title, title, labels),
 			Left:  "center",
 			Top:   "2%",
 		}),
@@ -370,7 +364,7 @@ func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, di
 			},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name: fmt.Sprintf("Traffic (%s)", unitLabel),
+			Name: label(e.options.YLabel, fmt.Sprintf("Traffic (%s)", unitLabel), labels),
 		}),
 	)
 
@@ -406,16 +400,19 @@ func (e *Exporter) ExportStatsHTMLChartWithRange(stats []models.TrafficStats, di
 	}
 
 	// 保存文件
-	f, err := os.Create(filename)
+	f, filename, err := e.createFile(filename, fileVars{Kind: "stats", Period: stats[0].Period, Direction: direction, Start: startTime, End: endTime, Ext: "html"})
 	if err != nil {
 		return "", fmt.Errorf("创建HTML文件失败: %w", err)
 	}
 	defer f.Close()
 
-	if err := page.Render(f); err != nil {
+	if err := e.renderPage(page, f); err != nil {
 		return "", fmt.Errorf("渲染HTML失败: %w", err)
 	}
 
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
 	return filename, nil
 }
 
@@ -0,0 +1,366 @@
+package chart
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// hostPalette 主机流量堆叠图的序列颜色（合并的其他虚拟机固定使用灰色）
+var hostPalette = []string{
+	"#36a2eb", "#ff6384", "#4bc0c0", "#ff9f40", "#9966ff",
+	"#8bc34a", "#ffc107", "#e91e63", "#00bcd4", "#795548",
+}
+
+const hostOtherColor = "#b0b0b0"
+
+// HostExport 主机流量趋势导出的输入
+type HostExport struct {
+	Records   map[int][]models.TrafficRecord // 各虚拟机的流量记录
+	Names     map[int]string                 // 虚拟机名称
+	StartTime time.Time
+	EndTime   time.Time
+	Period    string // minute/hour/day/month，或步长如 15m/6h/1d/auto
+	Direction string // both/rx/tx
+	Top       int    // 单独显示的虚拟机数，其余合并（0 表示全部单独显示）
+}
+
+// stack 按导出的周期或步长聚合各虚拟机的记录并叠加，返回叠加结果和横轴时间格式
+func (h HostExport) stack() (storage.HostTraffic, string) {
+	timeFormat := "01-02 15:04"
+	series := make([]storage.StackedSeries, 0, len(h.Records))
+	for vmid, records := range h.Records {
+		if len(records) == 0 {
+			continue
+		}
+		var points []storage.AggregatedPoint
+		points, timeFormat = aggregateForChart(records, h.StartTime, h.EndTime, h.Period)
+		series = append(series, storage.StackedSeries{VMID: vmid, Name: h.Names[vmid], Points: points})
+	}
+	return storage.StackTraffic(series, h.Direction, h.Top), timeFormat
+}
+
+// seriesName 序列的图例名称
+func (h HostExport) seriesName(s storage.StackedSeries) string {
+	if s.VMID == storage.StackOtherVMID {
+		return "Other VMs"
+	}
+	if s.Name == "" {
+		return fmt.Sprintf("VM%d", s.VMID)
+	}
+	return fmt.Sprintf("VM%d (%s)", s.VMID, s.Name)
+}
+
+// title 图表标题（包含方向和时间范围）
+func (h HostExport) title() string {
+	title := "Host Traffic by VM"
+	switch h.Direction {
+	case models.DirectionRX, models.DirectionDownload:
+		title = "Host Traffic by VM - Download (RX)"
+	case models.DirectionTX, models.DirectionUpload:
+		title = "Host Traffic by VM - Upload (TX)"
+	}
+	if !h.StartTime.IsZero() && !h.EndTime.IsZero() {
+		title = fmt.Sprintf("%s (%s - %s)", title,
+			displayTime(h.StartTime).Format("01-02 15:04"),
+			displayTime(h.EndTime).Format("01-02 15:04"))
+	}
+	return title
+}
+
+// labels 标题和坐标轴名称模板的字段
+func (h HostExport) labels(unit string) LabelData {
+	data := LabelData{Period: h.Period, Direction: h.Direction, Unit: unit}
+	data.Start, data.End = rangeLabel(h.StartTime, h.EndTime)
+	return data
+}
+
+// fileVars 文件名模板的变量
+func (h HostExport) fileVars(ext string) fileVars {
+	return fileVars{Kind: "host", Period: h.Period, Direction: h.Direction, Start: h.StartTime, End: h.EndTime, Ext: ext}
+}
+
+// filename 默认的导出文件名（包含方向和时间范围）
+func (h HostExport) filename(dir, ext string) string {
+	name := "host_traffic"
+	if h.Direction != "" && h.Direction != models.DirectionBoth {
+		name += "_" + h.Direction
+	}
+	if !h.StartTime.IsZero() && !h.EndTime.IsZero() {
+		name += fmt.Sprintf("_%s_to_%s", h.StartTime.Format("20060102"), h.EndTime.Format("20060102"))
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102_150405"), ext))
+}
+
+// seriesColor 第 i 个序列的颜色
+func seriesColor(s storage.StackedSeries, i int) string {
+	if s.VMID == storage.StackOtherVMID {
+		return hostOtherColor
+	}
+	return hostPalette[i%len(hostPalette)]
+}
+
+// hostUnit 按最大值选择单位
+func hostUnit(traffic storage.HostTraffic, direction string) (float64, string) {
+	var maxBytes uint64
+	for _, point := range traffic.Total {
+		maxBytes = max(maxBytes, storage.PointBytes(point, direction))
+	}
+	switch {
+	case maxBytes < 1024*1024:
+		return 1024, "KB"
+	case maxBytes < 1024*1024*1024:
+		return 1024 * 1024, "MB"
+	default:
+		return 1024 * 1024 * 1024, "GB"
+	}
+}
+
+// ExportHostTrafficChart 导出主机流量趋势的堆叠面积图（PNG 或 SVG，见 SetRenderOptions）
+func (e *Exporter) ExportHostTrafficChart(h HostExport) (string, error) {
+	traffic, timeFormat := h.stack()
+	if len(traffic.Series) == 0 || len(traffic.Timestamps) == 0 {
+		return "", fmt.Errorf("no traffic records")
+	}
+	divisor, unitLabel := hostUnit(traffic, h.Direction)
+
+	// go-chart 不支持堆叠：按累计值从上到下绘制，每层填充覆盖下一层以下的部分
+	cumulative := make([]float64, len(traffic.Timestamps))
+	layers := make([]chart.Series, len(traffic.Series))
+	for i, s := range traffic.Series {
+		y := make([]float64, len(s.Points))
+		for j, point := range s.Points {
+			cumulative[j] += float64(storage.PointBytes(point, h.Direction)) / divisor
+			y[j] = cumulative[j]
+		}
+		color := drawing.ColorFromHex(strings.TrimPrefix(seriesColor(s, i), "#"))
+		layers[len(layers)-1-i] = chart.TimeSeries{
+			Name: h.seriesName(s),
+			Style: chart.Style{
+				StrokeColor: color,
+				StrokeWidth: 1.5,
+				FillColor:   color.WithAlpha(220),
+			},
+			XValues: traffic.Timestamps,
+			YValues: y,
+		}
+	}
+
+	labels := h.labels(unitLabel)
+	width, height := e.options.size(DefaultWidth, DefaultHeight)
+	colors := e.options.palette()
+	axisStyle := chart.Style{FontColor: colors.AxisText, StrokeColor: colors.AxisStroke, StrokeWidth: 1}
+	graph := chart.Chart{
+		Title: label(e.options.Title, h.title(), labels),
+		TitleStyle: chart.Style{
+			FontSize:  20,
+			FontColor: colors.Title,
+			Padding:   chart.Box{Top: 10, Bottom: 10},
+		},
+		Width:  width,
+		Height: height,
+		DPI:    e.options.DPI,
+		Background: chart.Style{
+			FillColor: colors.Background,
+			Padding:   chart.Box{Top: 50, Left: 50, Right: 50, Bottom: 50},
+		},
+		Canvas: chart.Style{
+			FillColor: colors.Canvas,
+		},
+		XAxis: chart.XAxis{
+			Name:           label(e.options.XLabel, "Time", labels),
+			NameStyle:      chart.Style{FontColor: colors.AxisName},
+			Style:          axisStyle,
+			ValueFormatter: timeValueFormatter(timeFormat),
+			GridMajorStyle: chart.Style{StrokeColor: colors.Grid, StrokeWidth: 1},
+		},
+		YAxis: chart.YAxis{
+			Name:           label(e.options.YLabel, fmt.Sprintf("Traffic (%s)", unitLabel), labels),
+			NameStyle:      chart.Style{FontColor: colors.AxisName},
+			Style:          axisStyle,
+			GridMajorStyle: chart.Style{StrokeColor: colors.Grid, StrokeWidth: 1},
+		},
+		Series: layers,
+	}
+	graph.Elements = []chart.Renderable{
+		chart.LegendLeft(&graph, chart.Style{
+			FillColor:   colors.Canvas,
+			FontColor:   colors.AxisText,
+			StrokeColor: colors.AxisStroke,
+		}),
+	}
+
+	f, filename, err := e.createFile(h.filename(e.exportPath, e.options.Ext()), h.fileVars(e.options.Ext()))
+	if err != nil {
+		return "", fmt.Errorf("创建图表文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := graph.Render(e.options.renderer(), f); err != nil {
+		return "", fmt.Errorf("failed to render chart: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	return filename, nil
+}
+
+// ExportHostHTMLChart 导出主机流量趋势的堆叠面积图（HTML，使用go-echarts）
+func (e *Exporter) ExportHostHTMLChart(h HostExport, isDark bool) (string, error) {
+	traffic, timeFormat := h.stack()
+	if len(traffic.Series) == 0 || len(traffic.Timestamps) == 0 {
+		return "", fmt.Errorf("no traffic records")
+	}
+	divisor, unitLabel := hostUnit(traffic, h.Direction)
+
+	xAxis := make([]string, len(traffic.Timestamps))
+	for i, t := range traffic.Timestamps {
+		xAxis[i] = displayTime(t).Format(timeFormat)
+	}
+
+	theme := "light"
+	if isDark || e.options.Dark {
+		theme = "dark"
+	}
+
+	labels := h.labels(unitLabel)
+	width, height := e.options.size(DefaultWidth, DefaultHeight)
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:  fmt.Sprintf("%dpx", width),
+			Height: fmt.Sprintf("%dpx", height),
+			Theme:  theme,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title: label(e.options.Title, h.title(), labels),
+			Left:  "center",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Top:  "6%",
+			Type: "scroll",
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "3%",
+			Right:  "4%",
+			Bottom: "15%",
+			Top:    "15%",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: label(e.options.XLabel, "Time", labels),
+			AxisLabel: &opts.AxisLabel{
+				Rotate: 45,
+			},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: label(e.options.YLabel, "Traffic ("+unitLabel+")", labels),
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for i, s := range traffic.Series {
+		data := make([]opts.LineData, len(s.Points))
+		for j, point := range s.Points {
+			data[j] = opts.LineData{Value: fmt.Sprintf("%.3f", float64(storage.PointBytes(point, h.Direction))/divisor)}
+		}
+		color := seriesColor(s, i)
+		line.AddSeries(h.seriesName(s), data,
+			charts.WithLineChartOpts(opts.LineChart{Stack: "total", ShowSymbol: opts.Bool(false)}),
+			charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: 0.7}),
+			charts.WithItemStyleOpts(opts.ItemStyle{Color: color}),
+		)
+	}
+
+	page := newPage("Host Traffic by VM")
+	page.AddCharts(line)
+
+	f, filename, err := e.createFile(h.filename(e.exportPath, "html"), h.fileVars("html"))
+	if err != nil {
+		return "", fmt.Errorf("创建HTML文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := e.renderPage(page, f); err != nil {
+		return "", fmt.Errorf("渲染HTML失败: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	return filename, nil
+}
+
+// ExportHostJSONData 导出主机流量趋势数据（各虚拟机每个时间段的增量和合计）
+func (e *Exporter) ExportHostJSONData(h HostExport) (string, error) {
+	traffic, timeFormat := h.stack()
+	if len(traffic.Series) == 0 || len(traffic.Timestamps) == 0 {
+		return "", fmt.Errorf("no traffic records")
+	}
+
+	timestamps := make([]string, len(traffic.Timestamps))
+	for i, t := range traffic.Timestamps {
+		timestamps[i] = displayTime(t).Format(timeFormat)
+	}
+	series := make([]map[string]interface{}, len(traffic.Series))
+	for i, s := range traffic.Series {
+		series[i] = map[string]interface{}{
+			"vmid":        s.VMID,
+			"name":        h.seriesName(s),
+			"rx_bytes":    s.RXBytes,
+			"tx_bytes":    s.TXBytes,
+			"total_bytes": s.TotalBytes,
+			"points":      s.Points,
+		}
+	}
+
+	var totalRX, totalTX uint64
+	for _, point := range traffic.Total {
+		totalRX += point.RXBytes
+		totalTX += point.TXBytes
+	}
+	exportData := map[string]interface{}{
+		"start_time":  displayTime(h.StartTime).Format(time.RFC3339),
+		"end_time":    displayTime(h.EndTime).Format(time.RFC3339),
+		"period":      h.Period,
+		"direction":   h.Direction,
+		"data_points": len(timestamps),
+		"timestamps":  timestamps,
+		"series":      series,
+		"total":       traffic.Total,
+		"summary": map[string]interface{}{
+			"vm_count":       len(h.Records),
+			"total_rx_bytes": totalRX,
+			"total_tx_bytes": totalTX,
+			"total_bytes":    totalRX + totalTX,
+		},
+	}
+
+	f, filename, err := e.createFile(h.filename(e.exportPath, "json"), h.fileVars("json"))
+	if err != nil {
+		return "", fmt.Errorf("创建JSON文件失败: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exportData); err != nil {
+		return "", fmt.Errorf("写入JSON失败: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	return filename, nil
+}
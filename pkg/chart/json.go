@@ -3,21 +3,24 @@ package chart
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"pve-traffic-monitor/pkg/models"
-	"pve-traffic-monitor/pkg/storage"
 	"time"
 )
 
-// ExportJSONData 导出JSON格式数据
+// ExportJSONData 导出JSON格式数据（聚合周期见 RenderOptions.Period，默认按小时）
 func (e *Exporter) ExportJSONData(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time) (string, error) {
+	return e.ExportJSONDataWithPeriod(vmid, vmName, records, startTime, endTime, e.options.period())
+}
+
+// ExportJSONDataWithPeriod 导出JSON格式数据（自定义聚合周期或步长，与图表导出相同）
+func (e *Exporter) ExportJSONDataWithPeriod(vmid int, vmName string, records []models.TrafficRecord, startTime, endTime time.Time, period string) (string, error) {
 	if len(records) == 0 {
 		return "", fmt.Errorf("no traffic records")
 	}
 
 	// 使用storage包的正确聚合函数（计算增量而非累积值）
-	aggregated := storage.AggregateTrafficByPeriod(records, "hour")
+	aggregated, _ := aggregateForChart(records, startTime, endTime, period)
 
 	if len(aggregated) == 0 {
 		return "", fmt.Errorf("no aggregated data")
@@ -27,8 +30,9 @@ func (e *Exporter) ExportJSONData(vmid int, vmName string, records []models.Traf
 	exportData := map[string]interface{}{
 		"vmid":        vmid,
 		"vm_name":     vmName,
-		"start_time":  startTime.Format(time.RFC3339),
-		"end_time":    endTime.Format(time.RFC3339),
+		"start_time":  displayTime(startTime).Format(time.RFC3339),
+		"end_time":    displayTime(endTime).Format(time.RFC3339),
+		"period":      period,
 		"data_points": len(aggregated),
 		"records":     aggregated,
 		"summary": map[string]interface{}{
@@ -71,7 +75,7 @@ func (e *Exporter) ExportJSONData(vmid int, vmName string, records []models.Traf
 	}
 
 	// 保存JSON文件
-	f, err := os.Create(filename)
+	f, filename, err := e.createFile(filename, fileVars{Kind: "vm", VMID: vmid, Name: vmName, Period: period, Direction: models.DirectionBoth, Start: startTime, End: endTime, Ext: "json"})
 	if err != nil {
 		return "", fmt.Errorf("创建JSON文件失败: %w", err)
 	}
@@ -83,14 +87,23 @@ func (e *Exporter) ExportJSONData(vmid int, vmName string, records []models.Traf
 		return "", fmt.Errorf("写入JSON失败: %w", err)
 	}
 
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
 	return filename, nil
 }
 
 // ExportStatsJSONData 导出统计JSON数据
 func (e *Exporter) ExportStatsJSONData(stats []models.TrafficStats, direction string) (string, error) {
+	return e.ExportStatsJSONDataWithRange(stats, direction, time.Time{}, time.Time{})
+}
+
+// ExportStatsJSONDataWithRange 导出统计JSON数据（带时间范围信息，未指定时使用统计周期的范围）
+func (e *Exporter) ExportStatsJSONDataWithRange(stats []models.TrafficStats, direction string, startTime, endTime time.Time) (string, error) {
 	if len(stats) == 0 {
 		return "", fmt.Errorf("no statistics data")
 	}
+	startTime, endTime = statsRange(stats, startTime, endTime)
 
 	// 排序
 	sortedStats := make([]models.TrafficStats, len(stats))
@@ -133,6 +146,9 @@ func (e *Exporter) ExportStatsJSONData(stats []models.TrafficStats, direction st
 
 	exportData := map[string]interface{}{
 		"direction":  direction,
+		"period":     stats[0].Period,
+		"start_time": displayTime(startTime).Format(time.RFC3339),
+		"end_time":   displayTime(endTime).Format(time.RFC3339),
 		"vm_count":   len(sortedStats),
 		"statistics": sortedStats,
 		"summary": map[string]interface{}{
@@ -152,7 +168,48 @@ func (e *Exporter) ExportStatsJSONData(stats []models.TrafficStats, direction st
 	}
 
 	// 保存JSON文件
-	f, err := os.Create(filename)
+	f, filename, err := e.createFile(filename, fileVars{Kind: "stats", Period: stats[0].Period, Direction: direction, Start: startTime, End: endTime, Ext: "json"})
+	if err != nil {
+		return "", fmt.Errorf("创建JSON文件失败: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exportData); err != nil {
+		return "", fmt.Errorf("写入JSON失败: %w", err)
+	}
+
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	return filename, nil
+}
+
+// ExportTenantStatsJSON 导出按客户汇总的统计JSON数据
+func (e *Exporter) ExportTenantStatsJSON(stats []models.TenantStats, direction string) (string, error) {
+	if len(stats) == 0 {
+		return "", fmt.Errorf("no tenant statistics data")
+	}
+
+	var totalBytes uint64
+	for _, stat := range stats {
+		totalBytes += stat.TotalBytes
+	}
+
+	exportData := map[string]interface{}{
+		"direction":    direction,
+		"tenant_count": len(stats),
+		"tenants":      stats,
+		"summary": map[string]interface{}{
+			"total_bytes": totalBytes,
+		},
+	}
+
+	timestamp := time.Now().Format("20060102_150405")
+	filename := filepath.Join(e.exportPath, fmt.Sprintf("tenant_stats_%s.json", timestamp))
+
+	f, filename, err := e.createFile(filename, fileVars{Kind: "tenants", Period: stats[0].Period, Direction: direction, Start: stats[0].StartTime, End: stats[0].EndTime, Ext: "json"})
 	if err != nil {
 		return "", fmt.Errorf("创建JSON文件失败: %w", err)
 	}
@@ -164,5 +221,8 @@ func (e *Exporter) ExportStatsJSONData(stats []models.TrafficStats, direction st
 		return "", fmt.Errorf("写入JSON失败: %w", err)
 	}
 
+	if err := f.Commit(); err != nil {
+		return "", fmt.Errorf("保存导出文件失败: %w", err)
+	}
 	return filename, nil
 }
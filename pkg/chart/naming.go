@@ -0,0 +1,253 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/objstore"
+)
+
+// FileNaming 导出文件的命名方式（零值使用带时间戳的默认文件名）
+type FileNaming struct {
+	Template  string // 文件名模板（相对导出目录，可包含子目录），如 {vmid}_{period}_{date}.{ext}
+	Output    string // 明确的输出路径，优先于 Template；StdoutOutput 表示写到标准输出
+	Overwrite bool   // 文件已存在时覆盖，否则在扩展名前追加序号（_1、_2…）
+}
+
+// StdoutOutput Output 为 "-" 时导出内容写到标准输出（返回的文件名也是 "-"）
+const StdoutOutput = "-"
+
+// FilenamePlaceholders 文件名模板可以使用的变量
+var FilenamePlaceholders = []string{"kind", "vmid", "name", "period", "direction", "date", "start", "end", "timestamp", "ext"}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Validate 检查文件名模板：只能使用已知变量，且不能是绝对路径或跳出导出目录
+func (n FileNaming) Validate() error {
+	if n.Template == "" {
+		return nil
+	}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(n.Template, -1) {
+		known := false
+		for _, name := range FilenamePlaceholders {
+			if match[1] == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return &OptionError{Param: "filename", Value: n.Template, Err: fmt.Errorf("未知变量 %s", match[0])}
+		}
+	}
+	if filepath.IsAbs(n.Template) {
+		return &OptionError{Param: "filename", Value: n.Template, Err: errors.New("模板必须是相对导出目录的路径")}
+	}
+	for _, part := range strings.Split(filepath.ToSlash(n.Template), "/") {
+		if part == ".." {
+			return &OptionError{Param: "filename", Value: n.Template, Err: errors.New("模板不能跳出导出目录")}
+		}
+	}
+	return nil
+}
+
+// fileVars 文件名模板中变量的值
+type fileVars struct {
+	Kind      string // vm/stats/host/tenants
+	VMID      int
+	Name      string
+	Period    string
+	Direction string
+	Start     time.Time
+	End       time.Time
+	Ext       string
+}
+
+// expand 展开文件名模板（变量值中的路径分隔符和空白替换为 _）
+func (v fileVars) expand(template string, now time.Time) string {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return displayTime(t).Format("2006-01-02")
+	}
+	values := map[string]string{
+		"kind":      v.Kind,
+		"name":      v.Name,
+		"period":    v.Period,
+		"direction": v.Direction,
+		"date":      date(now),
+		"start":     date(v.Start),
+		"end":       date(v.End),
+		"timestamp": now.Format("20060102_150405"),
+		"ext":       v.Ext,
+	}
+	if v.VMID != 0 {
+		values["vmid"] = strconv.Itoa(v.VMID)
+	}
+	return placeholderPattern.ReplaceAllStringFunc(template, func(match string) string {
+		return sanitizeFilePart(values[match[1:len(match)-1]])
+	})
+}
+
+// sanitizeFilePart 替换文件名中不能出现或容易出错的字符
+func sanitizeFilePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// SetFileNaming 设置之后导出文件的命名方式，需要先通过 Validate 检查
+func (e *Exporter) SetFileNaming(naming FileNaming) {
+	e.naming = naming
+}
+
+// SetDestination 设置之后导出文件的上传目标：文件名（含 prefix）作为相对目标的路径，不写入本地导出目录
+// uploader 为 nil 时恢复写入本地导出目录
+func (e *Exporter) SetDestination(uploader objstore.Uploader, prefix string) {
+	e.uploader = uploader
+	e.uploadPrefix = prefix
+}
+
+// exportFile 正在写入的导出文件：写完后调用 Commit 保存（上传目标此时才上传），出错返回时由 Close 放弃
+type exportFile struct {
+	io.Writer
+	file   *os.File           // 本地文件（标准输出和上传目标时为 nil）
+	buf    *bytes.Buffer      // 上传目标：缓存的文件内容
+	upload func([]byte) error // 上传目标：上传缓存的内容
+	done   bool
+}
+
+// Commit 保存导出文件：关闭本地文件或上传缓存的内容
+func (f *exportFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	if f.file != nil {
+		return f.file.Close()
+	}
+	if f.upload != nil {
+		return f.upload(f.buf.Bytes())
+	}
+	return nil
+}
+
+// Close 关闭导出文件（未 Commit 时上传目标不上传；不关闭 os.Stdout）
+func (f *exportFile) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	if f.file != nil {
+		return f.file.Close()
+	}
+	return nil
+}
+
+// uploadTimeout 上传一个导出文件的超时时间
+const uploadTimeout = 5 * time.Minute
+
+// contentTypes 上传时按扩展名设置的 Content-Type
+var contentTypes = map[string]string{
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".html": "text/html; charset=utf-8",
+	".json": "application/json",
+	".csv":  "text/csv; charset=utf-8",
+}
+
+// createFile 创建导出文件：优先使用 Output，其次按 Template 在导出目录下生成，否则使用 defaultPath
+// 文件已存在且没有设置 Overwrite 时追加序号，返回实际的文件名（上传目标返回文件的远程位置）
+func (e *Exporter) createFile(defaultPath string, vars fileVars) (*exportFile, string, error) {
+	if e.naming.Output == StdoutOutput {
+		return &exportFile{Writer: os.Stdout}, StdoutOutput, nil
+	}
+	if e.uploader != nil {
+		key := e.remoteKey(defaultPath, vars)
+		return e.createRemoteFile(key), e.uploader.Location(key), nil
+	}
+
+	filename := defaultPath
+	switch {
+	case e.naming.Output != "":
+		filename = e.naming.Output
+	case e.naming.Template != "":
+		filename = filepath.Join(e.exportPath, vars.expand(e.naming.Template, time.Now()))
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, "", err
+	}
+	if e.naming.Overwrite {
+		f, err := os.Create(filename)
+		if err != nil {
+			return nil, "", err
+		}
+		return &exportFile{Writer: f, file: f}, filename, nil
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for i := 0; ; i++ {
+		candidate := filename
+		if i > 0 {
+			candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+		}
+		// O_EXCL 保证不会覆盖同时创建的同名文件
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return &exportFile{Writer: f, file: f}, candidate, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", err
+		}
+	}
+}
+
+// remoteKey 上传目标中的文件路径：prefix 加上 Output、展开的 Template 或默认文件名（相对导出目录）
+func (e *Exporter) remoteKey(defaultPath string, vars fileVars) string {
+	var name string
+	switch {
+	case e.naming.Output != "":
+		name = e.naming.Output
+	case e.naming.Template != "":
+		name = vars.expand(e.naming.Template, time.Now())
+	default:
+		name = filepath.Base(defaultPath)
+		if rel, err := filepath.Rel(e.exportPath, defaultPath); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	return e.uploadPrefix + strings.TrimLeft(filepath.ToSlash(name), "/")
+}
+
+// createRemoteFile 创建缓存在内存中的导出文件，Commit 时上传到 key（远程已有同名文件时覆盖）
+func (e *Exporter) createRemoteFile(key string) *exportFile {
+	uploader := e.uploader
+	buf := &bytes.Buffer{}
+	return &exportFile{
+		Writer: buf,
+		buf:    buf,
+		upload: func(body []byte) error {
+			ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+			defer cancel()
+			if err := uploader.Put(ctx, key, body, contentTypes[strings.ToLower(path.Ext(key))]); err != nil {
+				return fmt.Errorf("上传 %s 失败: %w", uploader.Location(key), err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,140 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCreateFileNaming(t *testing.T) {
+	dir := t.TempDir()
+	exporter, err := NewExporter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := fileVars{Kind: "vm", VMID: 101, Name: "web/01", Period: "day", Start: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Ext: "json"}
+
+	create := func() string {
+		f, filename, err := exporter.createFile(filepath.Join(dir, "default.json"), vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		return filename
+	}
+
+	exporter.SetFileNaming(FileNaming{Template: "{kind}/{vmid}_{name}_{period}_{start}.{ext}"})
+	want := filepath.Join(dir, "vm", "101_web_01_day_2024-03-01.json")
+	if got := create(); got != want {
+		t.Fatalf("filename = %s, want %s", got, want)
+	}
+	// 已存在时追加序号
+	if got := create(); got != filepath.Join(dir, "vm", "101_web_01_day_2024-03-01_1.json") {
+		t.Fatalf("second filename = %s", got)
+	}
+
+	exporter.SetFileNaming(FileNaming{Template: "{kind}/{vmid}_{name}_{period}_{start}.{ext}", Overwrite: true})
+	if got := create(); got != want {
+		t.Fatalf("overwrite filename = %s, want %s", got, want)
+	}
+
+	output := filepath.Join(dir, "out", "latest.json")
+	exporter.SetFileNaming(FileNaming{Template: "ignored.{ext}", Output: output, Overwrite: true})
+	if got := create(); got != output {
+		t.Fatalf("output filename = %s, want %s", got, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ignored.json")); err == nil {
+		t.Errorf("template used although output was set")
+	}
+
+	// 标准输出：不创建文件，关闭时不关闭 os.Stdout
+	exporter.SetFileNaming(FileNaming{Output: StdoutOutput})
+	if got := create(); got != StdoutOutput {
+		t.Fatalf("stdout filename = %s", got)
+	}
+	if _, err := os.Stdout.Stat(); err != nil {
+		t.Errorf("stdout closed: %v", err)
+	}
+}
+
+func TestFileNamingValidate(t *testing.T) {
+	if err := (FileNaming{Template: "{vmid}_{period}_{date}.{ext}"}).Validate(); err != nil {
+		t.Fatalf("valid template: %v", err)
+	}
+	for _, template := range []string{"{vm}.{ext}", "/tmp/{vmid}.json", "../{vmid}.json"} {
+		var optErr *OptionError
+		if err := (FileNaming{Template: template}).Validate(); !errors.As(err, &optErr) || optErr.Param != "filename" {
+			t.Errorf("%s: error = %v", template, err)
+		}
+	}
+}
+
+// memoryUploader 记录上传内容的上传目标
+type memoryUploader struct {
+	files        map[string][]byte
+	contentTypes map[string]string
+}
+
+func (u *memoryUploader) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	u.files[key] = body
+	u.contentTypes[key] = contentType
+	return nil
+}
+
+func (u *memoryUploader) Location(key string) string { return "mem://" + key }
+
+func TestExportToDestination(t *testing.T) {
+	dir := t.TempDir()
+	exporter, err := NewExporter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploader := &memoryUploader{files: map[string][]byte{}, contentTypes: map[string]string{}}
+	exporter.SetDestination(uploader, "reports/")
+	exporter.SetFileNaming(FileNaming{Template: "{kind}/{vmid}.{ext}"})
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: start, RXBytes: 100, TXBytes: 50, TotalBytes: 150},
+		{VMID: 101, Timestamp: start.Add(time.Hour), RXBytes: 300, TXBytes: 150, TotalBytes: 450},
+	}
+	filename, err := exporter.ExportJSONDataWithPeriod(101, "web", records, start, start.Add(2*time.Hour), "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filename != "mem://reports/vm/101.json" {
+		t.Fatalf("filename = %s", filename)
+	}
+	if len(uploader.files["reports/vm/101.json"]) == 0 || uploader.contentTypes["reports/vm/101.json"] != "application/json" {
+		t.Fatalf("uploaded = %v, content types = %v", uploader.files, uploader.contentTypes)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("local export directory not empty: %v", entries)
+	}
+
+	// 没有 Commit（导出出错）时不上传
+	exporter.SetFileNaming(FileNaming{})
+	f, _, err := exporter.createFile(filepath.Join(dir, "aborted.json"), fileVars{Ext: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, ok := uploader.files["reports/aborted.json"]; ok {
+		t.Error("file uploaded without Commit")
+	}
+
+	exporter.SetDestination(nil, "")
+	f, filename, err = exporter.createFile(filepath.Join(dir, "local.json"), fileVars{Ext: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if filename != filepath.Join(dir, "local.json") {
+		t.Errorf("local filename = %s", filename)
+	}
+}
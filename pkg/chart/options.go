@@ -0,0 +1,218 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// 静态图表格式
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// 图表尺寸和 DPI 的范围
+const (
+	DefaultWidth  = 1600
+	DefaultHeight = 800
+	MinDimension  = 200
+	MaxDimension  = 8000
+	MinDPI        = 36
+	MaxDPI        = 600
+)
+
+// RenderOptions 图表的格式、尺寸和文字（零值使用各图表的默认值）
+type RenderOptions struct {
+	Format string  // 静态图表格式：png（默认）或 svg
+	Width  int     // 宽度（像素），0 使用默认值
+	Height int     // 高度（像素），0 使用默认值
+	DPI    float64 // 静态图表的 DPI，0 使用 go-chart 默认值（92）
+	Title  string  // 标题模板（text/template，字段见 LabelData），为空使用默认标题
+	XLabel string  // 横轴名称模板，为空使用默认名称
+	YLabel string  // 纵轴名称模板，为空使用默认名称
+	Period string  // 未指定周期的导出函数使用的聚合周期或步长，为空按小时聚合
+
+	Dark bool // 使用暗色配色（PNG/SVG 和 HTML 都生效）
+	CDN  bool // HTML 引用 CDN 上的 ECharts 而不是内嵌（文件更小，但需要能访问外网）
+}
+
+// LabelData 标题和坐标轴名称模板可以使用的字段
+type LabelData struct {
+	VMID      int    // 虚拟机 ID（汇总图表为 0）
+	Name      string // 虚拟机名称
+	Start     string // 时间范围起点（01-02 15:04，未指定时为空）
+	End       string // 时间范围终点
+	Period    string // 聚合周期或步长
+	Direction string // 流量方向
+	Unit      string // 纵轴单位（KB/MB/GB）
+}
+
+// OptionError 无效的图表参数（Param 为参数名）
+type OptionError struct {
+	Param string
+	Value interface{}
+	Err   error
+}
+
+func (e *OptionError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("无效的图表参数 %s=%v: %v", e.Param, e.Value, e.Err)
+	}
+	return fmt.Sprintf("无效的图表参数 %s=%v", e.Param, e.Value)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// Validate 检查格式、尺寸、DPI 和模板
+func (o RenderOptions) Validate() error {
+	if o.Format != "" && o.Format != FormatPNG && o.Format != FormatSVG {
+		return &OptionError{Param: "format", Value: o.Format}
+	}
+	if o.Width != 0 && (o.Width < MinDimension || o.Width > MaxDimension) {
+		return &OptionError{Param: "width", Value: o.Width}
+	}
+	if o.Height != 0 && (o.Height < MinDimension || o.Height > MaxDimension) {
+		return &OptionError{Param: "height", Value: o.Height}
+	}
+	if o.DPI != 0 && (o.DPI < MinDPI || o.DPI > MaxDPI) {
+		return &OptionError{Param: "dpi", Value: o.DPI}
+	}
+	for _, field := range []struct{ param, text string }{{"title", o.Title}, {"x_label", o.XLabel}, {"y_label", o.YLabel}} {
+		if field.text == "" {
+			continue
+		}
+		tmpl, err := template.New(field.param).Parse(field.text)
+		if err == nil {
+			err = tmpl.Execute(&bytes.Buffer{}, LabelData{})
+		}
+		if err != nil {
+			return &OptionError{Param: field.param, Value: field.text, Err: err}
+		}
+	}
+	return nil
+}
+
+// Ext 导出文件的扩展名
+func (o RenderOptions) Ext() string {
+	if o.Format == FormatSVG {
+		return FormatSVG
+	}
+	return FormatPNG
+}
+
+// ContentType 静态图表的 MIME 类型
+func (o RenderOptions) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// renderer 格式对应的 go-chart 渲染器
+func (o RenderOptions) renderer() chart.RendererProvider {
+	if o.Format == FormatSVG {
+		return chart.SVG
+	}
+	return chart.PNG
+}
+
+// size 图表尺寸（未指定时使用图表的默认值）
+func (o RenderOptions) size(width, height int) (int, int) {
+	if o.Width > 0 {
+		width = o.Width
+	}
+	if o.Height > 0 {
+		height = o.Height
+	}
+	return width, height
+}
+
+// period 未指定周期的导出函数使用的聚合周期
+func (o RenderOptions) period() string {
+	if o.Period == "" {
+		return "hour"
+	}
+	return o.Period
+}
+
+// palette 静态图表的配色（系列颜色与 HTML 图表一致）
+type palette struct {
+	Background drawing.Color // 图表背景
+	Canvas     drawing.Color // 绘图区背景
+	Title      drawing.Color // 标题
+	AxisName   drawing.Color // 坐标轴名称
+	AxisText   drawing.Color // 坐标轴刻度文字
+	AxisStroke drawing.Color // 坐标轴线
+	Grid       drawing.Color // 主网格线
+	GridMinor  drawing.Color // 次网格线
+
+	Download drawing.Color
+	Upload   drawing.Color
+	Total    drawing.Color
+}
+
+// palette 按 Dark 选择静态图表的配色
+func (o RenderOptions) palette() palette {
+	colors := models.GetChartColors(o.Dark)
+	p := palette{
+		Download: hexColor(colors.Download),
+		Upload:   hexColor(colors.Upload),
+		Total:    hexColor(colors.Total),
+		Canvas:   hexColor(colors.Background),
+		Title:    hexColor(colors.TextColor),
+		Grid:     hexColor(colors.SplitLine),
+	}
+	if o.Dark {
+		p.Background = drawing.Color{R: 20, G: 20, B: 20, A: 255}
+		p.AxisName = p.Title
+		p.AxisText = drawing.Color{R: 168, G: 171, B: 178, A: 255}
+		p.AxisStroke = hexColor(colors.AxisLine)
+		p.GridMinor = drawing.Color{R: 42, G: 42, B: 42, A: 255}
+		return p
+	}
+	p.Background = drawing.Color{R: 250, G: 250, B: 250, A: 255}
+	p.AxisName = drawing.Color{R: 52, G: 73, B: 94, A: 255}
+	p.AxisText = drawing.Color{R: 100, G: 100, B: 100, A: 255}
+	p.AxisStroke = drawing.Color{R: 200, G: 200, B: 200, A: 255}
+	p.GridMinor = drawing.Color{R: 245, G: 245, B: 245, A: 255}
+	return p
+}
+
+// hexColor 将 #rrggbb 转换为 go-chart 颜色
+func hexColor(hex string) drawing.Color {
+	return drawing.ColorFromHex(strings.TrimPrefix(hex, "#"))
+}
+
+// label 按模板生成标题或坐标轴名称，模板为空或执行失败时使用默认值
+func label(text, fallback string, data LabelData) string {
+	if text == "" {
+		return fallback
+	}
+	tmpl, err := template.New("label").Parse(text)
+	if err != nil {
+		return fallback
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fallback
+	}
+	return buf.String()
+}
+
+// rangeLabel 模板中的时间范围
+func rangeLabel(start, end time.Time) (string, string) {
+	if start.IsZero() || end.IsZero() {
+		return "", ""
+	}
+	return displayTime(start).Format("01-02 15:04"), displayTime(end).Format("01-02 15:04")
+}
@@ -0,0 +1,101 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRenderTrafficChartSVG(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []models.TrafficRecord
+	for i := 0; i <= 6; i++ {
+		records = append(records, models.TrafficRecord{VMID: 101, Timestamp: start.Add(time.Duration(i) * time.Hour), RXBytes: uint64(i) << 30, TXBytes: uint64(i) << 29})
+	}
+
+	var buf bytes.Buffer
+	options := RenderOptions{Format: FormatSVG, Width: 800, Height: 400, Title: "{{.Name}} #{{.VMID}} ({{.Period}}, {{.Unit}})"}
+	if err := RenderTrafficChart(&buf, 101, "web", records, start, start.Add(6*time.Hour), "hour", options); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 800 400"`) {
+		t.Fatalf("not an 800x400 svg: %.200s", svg)
+	}
+	if !strings.Contains(svg, "web #101 (hour, GB)") {
+		t.Errorf("svg does not contain the templated title")
+	}
+}
+
+func TestExportHonorsPeriodAndTheme(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var records []models.TrafficRecord
+	for i := 0; i <= 48; i++ {
+		records = append(records, models.TrafficRecord{VMID: 101, Timestamp: start.Add(time.Duration(i) * time.Hour), RXBytes: uint64(i) << 20})
+	}
+
+	exporter, err := NewExporter(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.SetRenderOptions(RenderOptions{Format: FormatSVG, Period: "day", Dark: true})
+
+	// 未指定周期的旧接口使用 RenderOptions.Period
+	filename, err := exporter.ExportJSONData(101, "web", records, start, start.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported struct {
+		Period     string `json:"period"`
+		DataPoints int    `json:"data_points"`
+	}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported.Period != "day" || exported.DataPoints > 3 {
+		t.Errorf("json export = %+v, want daily aggregation", exported)
+	}
+
+	// 暗色配色同样用于 SVG
+	filename, err = exporter.ExportTrafficChartWithRange(101, "web", records, start, start.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(svg), "fill:rgba(20,20,20,1.0)") {
+		t.Errorf("svg does not use the dark background")
+	}
+}
+
+func TestRenderOptionsValidate(t *testing.T) {
+	valid := RenderOptions{Format: FormatSVG, Width: 1200, Height: 600, DPI: 144, Title: "VM {{.VMID}}"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid options: %v", err)
+	}
+	for param, options := range map[string]RenderOptions{
+		"format":  {Format: "gif"},
+		"width":   {Width: 50},
+		"height":  {Height: 100000},
+		"dpi":     {DPI: 1000},
+		"title":   {Title: "{{.Missing}}"},
+		"y_label": {YLabel: "{{"},
+	} {
+		var optErr *OptionError
+		if err := options.Validate(); !errors.As(err, &optErr) || optErr.Param != param {
+			t.Errorf("%s: error = %v", param, err)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// redacted 替换敏感配置值的占位符
+const redacted = "******"
+
+// Issue 配置检查发现的问题（File/Line 为定位到的位置，无法定位时为空）
+type Issue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// String 格式化为 文件:行 字段: 信息
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(&b, ":%d", i.Line)
+		}
+		b.WriteString(" ")
+	}
+	if i.Field != "" {
+		b.WriteString(i.Field)
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// Report 配置检查结果
+type Report struct {
+	Files    []string       // 读取的配置文件（主配置文件和 include 的文件）
+	Config   *models.Config // 解析后的配置（解析失败时为 nil）
+	Errors   []Issue        // 会导致加载失败的错误
+	Warnings []Issue        // 可以加载但可能不符合预期的配置
+}
+
+// Valid 配置是否可以加载
+func (r *Report) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Check 加载并检查配置文件（不启动监控）：收集语法错误、所有规则的校验错误和可疑配置的警告
+func Check(path string) *Report {
+	report := &Report{Files: []string{path}}
+
+	cfg, files, err := parseConfig(path)
+	if len(files) > 0 {
+		report.Files = files
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, report.issue(err))
+		return report
+	}
+	report.Config = cfg
+
+	if err := validateGlobal(cfg); err != nil {
+		report.Errors = append(report.Errors, report.issue(err))
+	}
+	for i, rule := range cfg.Rules {
+		if err := validateRule(i, rule); err != nil {
+			report.Errors = append(report.Errors, report.issue(err))
+		}
+	}
+
+	for _, warning := range lint(cfg) {
+		if warning.File == "" {
+			warning.File, warning.Line = report.locate(warning.Field)
+		}
+		report.Warnings = append(report.Warnings, warning)
+	}
+	return report
+}
+
+// issue 将错误转换为带位置的问题
+func (r *Report) issue(err error) Issue {
+	issue := Issue{Message: err.Error()}
+
+	var sourceErr *SourceError
+	if errors.As(err, &sourceErr) {
+		issue.File = sourceErr.File
+		issue.Line = sourceErr.Line
+		return issue
+	}
+
+	issue.Field = fieldOf(err)
+	issue.File, issue.Line = r.locate(issue.Field)
+	return issue
+}
+
+// lint 检查可以加载但可能不符合预期的配置
+func lint(cfg *models.Config) []Issue {
+	var warnings []Issue
+	warn := func(field, format string, args ...interface{}) {
+		warnings = append(warnings, Issue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	interval := time.Duration(cfg.Monitor.IntervalSeconds) * time.Second
+	if cfg.Monitor.IntervalSeconds > 0 && cfg.Monitor.IntervalSeconds < 10 {
+		warn("monitor.interval_seconds", "采集间隔 %d 秒过短，建议 60-300 秒", cfg.Monitor.IntervalSeconds)
+	}
+	if cfg.Monitor.HA.Enabled && cfg.Storage.Type == "sqlite" {
+		warn("monitor.ha", "主备模式的各实例需要共用同一存储，SQLite 数据库文件不能由多台主机同时访问，建议使用 MySQL 或 PostgreSQL")
+	}
+	if cfg.RunMode() == models.ModeAgent && len(cfg.Rules) > 0 {
+		warn("rules", "采集代理不执行规则，规则由汇总端统一执行，此处的 %d 条规则不会生效", len(cfg.Rules))
+	}
+
+	enabled := 0
+	names := make(map[string]int)
+	for i, rule := range cfg.Rules {
+		field := func(name string) string {
+			return fmt.Sprintf("rules[%d].%s", i, name)
+		}
+
+		if first, exists := names[rule.Name]; exists && rule.Name != "" {
+			warn(field("name"), "规则名称 %s 与 rules[%d] 重复，操作日志和恢复状态按名称区分规则", rule.Name, first)
+		} else {
+			names[rule.Name] = i
+		}
+		if !rule.Enabled {
+			continue
+		}
+		enabled++
+
+		length := rulePeriodLength(rule)
+		if rule.Period == models.PeriodMinute {
+			// 检查的是待加载的配置，不能使用当前生效的窗口
+			length = cfg.Monitor.MinuteWindow()
+			if cfg.Monitor.IntervalSeconds > 0 && length < 2*interval {
+				warn(field("period"), "规则 %s 的 minute 周期统计窗口 %s 内不足两次采集（采集间隔 %d 秒），无法计算用量，请增大 monitor.minute_window_seconds",
+					rule.Name, length, cfg.Monitor.IntervalSeconds)
+			}
+		}
+		if length >= 7*24*time.Hour && cfg.Monitor.IntervalSeconds > 0 && cfg.Monitor.IntervalSeconds < 60 {
+			warn("monitor.interval_seconds", "采集间隔 %d 秒且规则 %s 的周期为 %s：每台虚拟机每周期约 %d 条记录，统计和存储开销较大，建议 60-300 秒",
+				cfg.Monitor.IntervalSeconds, rule.Name, rule.Period, int64(length/interval))
+		}
+		if cfg.Monitor.DataRetentionDays > 0 && length > time.Duration(cfg.Monitor.DataRetentionDays)*24*time.Hour {
+			warn("monitor.data_retention_days", "数据保留 %d 天短于规则 %s 的周期 %s，周期内较早的流量会被清理，用量偏低",
+				cfg.Monitor.DataRetentionDays, rule.Name, rule.Period)
+		}
+		if days := cfg.Monitor.Retention.ActionLogDays; days > 0 && length > time.Duration(days)*24*time.Hour {
+			warn("monitor.retention.action_logs_days", "操作日志保留 %d 天短于规则 %s 的周期 %s，不管理标签时可能在周期内重复执行操作",
+				days, rule.Name, rule.Period)
+		}
+		if rule.IsRateRule() && interval > 0 && rule.RateWindow() <= interval {
+			warn(field("rate_window_minutes"), "规则 %s 的带宽统计窗口 %v 不大于采集间隔 %v，采样点不足时规则不会触发", rule.Name, rule.RateWindow(), interval)
+		}
+		if rule.RateLimitMB > 0 && rule.Action != models.ActionRateLimit {
+			warn(field("rate_limit_mb"), "规则 %s 的操作为 %s，rate_limit_mb 不会生效", rule.Name, rule.Action)
+		}
+		if rule.IsProtocolRule() && !cfg.Monitor.IPSplit {
+			warn(field("metric"), "规则 %s 按 %s 流量统计，但 monitor.ip_split 未启用，没有按协议的记录时用量为 0", rule.Name, rule.Metric)
+		}
+		if rule.IsDiskRule() && rule.Action == models.ActionRateLimit {
+			warn(field("action"), "规则 %s 按磁盘吞吐触发，rate_limit 只限制网卡带宽，不限制磁盘读写", rule.Name)
+		}
+		if len(rule.Interfaces) > 0 && rule.Action != models.ActionDisconnect && rule.Action != models.ActionRateLimit {
+			warn(field("interfaces"), "规则 %s 的操作为 %s，interfaces 不会生效", rule.Name, rule.Action)
+		}
+		if rule.ForceStop && rule.Action != models.ActionShutdown {
+			warn(field("force_stop"), "规则 %s 的操作为 %s，force_stop 不会生效", rule.Name, rule.Action)
+		}
+		if rule.Exec != nil && rule.Action != models.ActionExec {
+			warn(field("exec"), "规则 %s 的操作为 %s，exec 不会生效（操作后运行命令请使用 post_action）", rule.Name, rule.Action)
+		}
+		if len(rule.VMIDs) == 0 && len(rule.VMTags) == 0 {
+			warn(field("vm_ids"), "规则 %s 未指定 vm_ids 或 vm_tags，将作用于所有虚拟机", rule.Name)
+		}
+	}
+	if enabled == 0 {
+		warn("rules", "没有启用的规则，只采集流量不执行任何操作")
+	}
+
+	// 月份在之后 keep_months 个月都结束后才归档，此时月初的记录已保存了约 (keep_months+1)*31 天
+	if days := cfg.Monitor.DataRetentionDays; cfg.Archive.Enabled() && days > 0 && days < (cfg.Archive.LocalMonths()+1)*31 {
+		warn("monitor.data_retention_days", "数据保留 %d 天短于归档前本地保留的时间（keep_months=%d），部分流量记录会在归档前被清理",
+			days, cfg.Archive.LocalMonths())
+	}
+
+	if !cfg.PVE.TLSVerified() && !loopbackHost(cfg.PVE.Host) {
+		warn("pve.host", "PVE 地址 %s 不是本机且未配置证书验证（verify_tls、ca_cert 或 fingerprint），连接可能被中间人攻击", cfg.PVE.Host)
+	}
+
+	if cfg.API.Enabled && cfg.API.Token == "" && !loopbackHost(cfg.API.Host) {
+		warn("api.token", "API 监听 %s 且未设置 token，任何能访问该地址的人都可以查看数据", cfg.API.Host)
+	}
+
+	return warnings
+}
+
+// rulePeriodLength 规则周期的最长长度（用于判断采样量和保留期）
+func rulePeriodLength(rule models.Rule) time.Duration {
+	if window, ok := rule.RollingWindow(); ok {
+		return window
+	}
+	switch rule.Period {
+	case models.PeriodHour:
+		return time.Hour
+	case models.PeriodDay:
+		return 24 * time.Hour
+	case models.PeriodMonth:
+		return 31 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// loopbackHost 监听地址是否只允许本机访问
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// locate 在配置文件中查找字段所在的行（按路径逐级向下查找，找不到完整路径时返回最深的匹配）
+// rules[n] 按规则名称定位，因此 include 文件中的规则也能找到
+func (r *Report) locate(field string) (string, int) {
+	if field == "" {
+		return "", 0
+	}
+	segments := strings.Split(field, ".")
+
+	bestFile, bestLine, bestDepth := "", 0, 0
+	for _, file := range r.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+
+		from, depth := 0, 0
+		for _, segment := range segments {
+			key, index := splitIndex(segment)
+			line := -1
+			if key == "rules" && index >= 0 && r.Config != nil && index < len(r.Config.Rules) && r.Config.Rules[index].Name != "" {
+				line = findRule(lines, r.Config.Rules[index].Name, from)
+			} else {
+				line = findKey(lines, key, from)
+			}
+			if line < 0 {
+				break
+			}
+			from, depth = line, depth+1
+		}
+		if depth > bestDepth {
+			bestFile, bestLine, bestDepth = file, from+1, depth
+		}
+	}
+	return bestFile, bestLine
+}
+
+// splitIndex 拆分 key[n]，没有下标时 index 为 -1
+func splitIndex(segment string) (string, int) {
+	open := strings.Index(segment, "[")
+	if open < 0 || !strings.HasSuffix(segment, "]") {
+		return segment, -1
+	}
+	index, err := strconv.Atoi(segment[open+1 : len(segment)-1])
+	if err != nil {
+		return segment[:open], -1
+	}
+	return segment[:open], index
+}
+
+// findKey 从 from 行开始查找键（JSON "key":、YAML key:、TOML key = 或 [table]）
+func findKey(lines []string, key string, from int) int {
+	quoted := regexp.QuoteMeta(key)
+	pattern := regexp.MustCompile(`(^|[\s{,])(-\s+)?["']?` + quoted + `["']?\s*[:=]|^\s*\[\[?\s*([\w.]+\.)?` + quoted + `\s*\]`)
+	for i := from; i < len(lines); i++ {
+		if pattern.MatchString(lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findRule 从 from 行开始查找指定名称的规则
+func findRule(lines []string, name string, from int) int {
+	pattern := regexp.MustCompile(`["']?name["']?\s*[:=]\s*["']?` + regexp.QuoteMeta(name) + `["']?\s*(,|$|#)`)
+	for i := from; i < len(lines); i++ {
+		if pattern.MatchString(lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 数据库连接字符串中的密码（user:password@ 和 password=xxx 两种写法）
+var (
+	dsnUserPassword  = regexp.MustCompile(`^((?:\w+://)?[^:/@]*:).*@`)
+	dsnPasswordParam = regexp.MustCompile(`(password=)[^\s&]*`)
+)
+
+// Redact 返回隐藏了令牌、密钥和数据库密码的配置副本（用于输出配置）
+func Redact(cfg models.Config) models.Config {
+	mask := func(s string) string {
+		if s == "" {
+			return s
+		}
+		return redacted
+	}
+
+	cfg.PVE.APITokenSecret = mask(cfg.PVE.APITokenSecret)
+	cfg.PVE.Password = mask(cfg.PVE.Password)
+	cfg.API.Token = mask(cfg.API.Token)
+	cfg.API.PublicSecret = mask(cfg.API.PublicSecret)
+	cfg.Vault.Token = mask(cfg.Vault.Token)
+	cfg.Agent.Token = mask(cfg.Agent.Token)
+	cfg.Aggregator.AgentToken = mask(cfg.Aggregator.AgentToken)
+	cfg.RemoteWrite.Token = mask(cfg.RemoteWrite.Token)
+	cfg.Archive.SecretKey = mask(cfg.Archive.SecretKey)
+
+	destinations := make([]models.ExportDestination, len(cfg.Monitor.ExportDestinations))
+	for i, dest := range cfg.Monitor.ExportDestinations {
+		dest.SecretKey = mask(dest.SecretKey)
+		dest.Password = mask(dest.Password)
+		destinations[i] = dest
+	}
+	cfg.Monitor.ExportDestinations = destinations
+
+	channels := make([]models.NotifyChannel, len(cfg.Notify.Channels))
+	for i, channel := range cfg.Notify.Channels {
+		channel.Token = mask(channel.Token)
+		channel.Password = mask(channel.Password)
+		channels[i] = channel
+	}
+	cfg.Notify.Channels = channels
+
+	keys := make([]models.APIKey, len(cfg.API.Keys))
+	for i, key := range cfg.API.Keys {
+		key.Token = mask(key.Token)
+		keys[i] = key
+	}
+	cfg.API.Keys = keys
+
+	for storage := &cfg.Storage; storage != nil; storage = storage.Replica {
+		storage.DSN = redactDSN(storage.DSN)
+		if storage.Replica != nil {
+			replica := *storage.Replica
+			storage.Replica = &replica
+		}
+	}
+	return cfg
+}
+
+// redactDSN 隐藏数据库连接字符串中的密码
+func redactDSN(dsn string) string {
+	dsn = dsnUserPassword.ReplaceAllString(dsn, "${1}"+redacted+"@")
+	return dsnPasswordParam.ReplaceAllString(dsn, "${1}"+redacted)
+}
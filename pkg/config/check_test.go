@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCheckReportsLocationsAndWarnings(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", `pve:
+  host: localhost
+  port: 8006
+  node: pve
+monitor:
+  interval_seconds: 10
+storage:
+  type: file
+  file_path: ./data
+rules:
+  - name: monthly
+    enabled: true
+    period: month
+    limit_gb: 100
+    action: shutdown
+    vm_ids: [100]
+  - name: broken
+    enabled: true
+    period: fortnight
+    limit_gb: 10
+    action: shutdown
+    vm_ids: [101]
+`)
+
+	report := Check(path)
+	if report.Valid() || len(report.Errors) != 1 {
+		t.Fatalf("Errors = %v, want one error", report.Errors)
+	}
+	if got := report.Errors[0]; got.Field != "rules[1].period" || got.File != path || got.Line != 19 {
+		t.Fatalf("error = %+v, want rules[1].period at line 19", got)
+	}
+
+	var intervalWarning *Issue
+	for i, warning := range report.Warnings {
+		if warning.Field == "monitor.interval_seconds" && strings.Contains(warning.Message, "monthly") {
+			intervalWarning = &report.Warnings[i]
+		}
+	}
+	if intervalWarning == nil || intervalWarning.Line != 6 {
+		t.Fatalf("warnings = %v, want interval warning at line 6 for the monthly rule", report.Warnings)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	replica := &models.StorageConfig{Type: "postgresql", DSN: "postgres://monitor:p@ss@db/traffic"}
+	cfg := models.Config{
+		PVE:     models.PVEConfig{APITokenSecret: "secret"},
+		API:     models.APIConfig{Token: "token", Keys: []models.APIKey{{Token: "key"}}},
+		Storage: models.StorageConfig{Type: "mysql", DSN: "monitor:pass@tcp(127.0.0.1:3306)/traffic", Replica: replica},
+	}
+
+	got := Redact(cfg)
+	if got.PVE.APITokenSecret != redacted || got.API.Token != redacted || got.API.Keys[0].Token != redacted {
+		t.Fatalf("Redact() left secrets: %+v %+v", got.PVE, got.API)
+	}
+	if want := "monitor:******@tcp(127.0.0.1:3306)/traffic"; got.Storage.DSN != want {
+		t.Fatalf("dsn = %q, want %q", got.Storage.DSN, want)
+	}
+	if want := "postgres://monitor:******@db/traffic"; got.Storage.Replica.DSN != want {
+		t.Fatalf("replica dsn = %q, want %q", got.Storage.Replica.DSN, want)
+	}
+	if cfg.API.Keys[0].Token != "key" || replica.DSN == got.Storage.Replica.DSN {
+		t.Fatal("Redact() modified the original config")
+	}
+}
+
+func TestValidateRuleRejectsCreationTimeWithoutPeriod(t *testing.T) {
+	rule := models.Rule{Name: "burst", Type: models.RuleTypeRate, RateThresholdMbps: 100, Action: models.ActionRateLimit, RateLimitMB: 1, UseCreationTime: true}
+	err := validateRule(0, rule)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "rules[0].use_creation_time" {
+		t.Fatalf("validateRule() = %v, want a rules[0].use_creation_time error", err)
+	}
+	if err := rule.Validate(); err == nil {
+		t.Fatal("Rule.Validate() accepted use_creation_time without a period")
+	}
+
+	rule.Period = "hour"
+	if err := validateRule(0, rule); err != nil {
+		t.Fatalf("validateRule() with hour period = %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/secret"
+)
+
+// 配置文件格式（按扩展名识别，其他扩展名按 JSON 解析）
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// includeKey 引用其他配置文件的顶层键（如把规则拆分到单独的文件）
+const includeKey = "include"
+
+// envPattern 环境变量引用: ${NAME} 或 ${NAME:-默认值}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// secretPattern 外部密钥引用: ${secret:名称}，名称对应 secrets 中的配置
+var secretPattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.-]+)\}`)
+
+// yamlLinePattern YAML 错误信息中的行号
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// SourceError 配置文件语法错误（Line 为 0 表示无法确定行号）
+type SourceError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// errorLine 获取解析错误所在的行号
+func errorLine(data []byte, err error) int {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tomlErr toml.ParseError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return offsetLine(data, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return offsetLine(data, typeErr.Offset)
+	case errors.As(err, &tomlErr):
+		return tomlErr.Position.Line
+	}
+	if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	return 0
+}
+
+// offsetLine 字节偏移所在的行号（从 1 开始）
+func offsetLine(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// FormatOf 根据扩展名判断配置文件格式
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// parseConfig 解析配置文件及其 include 的文件，返回配置和读取过的所有文件（用于检测修改）
+func parseConfig(path string) (*models.Config, []string, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{path}
+
+	includes, err := includePaths(doc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	delete(doc, includeKey)
+
+	for _, include := range includes {
+		included, err := readDocument(include)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, nested := included[includeKey]; nested {
+			return nil, nil, fmt.Errorf("被引用的配置文件 %s 不能再使用 include", include)
+		}
+		if err := mergeDocument(doc, included, include); err != nil {
+			return nil, nil, err
+		}
+		files = append(files, include)
+	}
+
+	// 所有文件合并后再替换密钥引用，被引用的文件也可以使用主配置文件中定义的密钥
+	if err := expandSecrets(doc); err != nil {
+		return nil, files, err
+	}
+
+	// 通用结构转为 JSON 后按 json 标签解析，各格式共用一套字段定义
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("转换配置失败: %w", err)
+	}
+	var cfg models.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		err = fmt.Errorf("解析配置文件失败: %w", err)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, files, &FieldError{Field: typeErr.Field, Err: err}
+		}
+		return nil, files, err
+	}
+	return &cfg, files, nil
+}
+
+// readDocument 读取单个配置文件为通用结构，并展开字符串中的环境变量
+func readDocument(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	doc := map[string]interface{}{}
+	switch FormatOf(path) {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	}
+	if err != nil {
+		return nil, &SourceError{File: path, Line: errorLine(data, err), Err: fmt.Errorf("解析配置文件 %s 失败: %w", path, err)}
+	}
+
+	expanded, err := replaceStrings(doc, expandEnv)
+	if err != nil {
+		return nil, fmt.Errorf("配置文件 %s: %w", path, err)
+	}
+	return expanded.(map[string]interface{}), nil
+}
+
+// expandEnv 展开字符串中的 ${NAME} 环境变量引用（未设置且没有默认值时报错）
+func expandEnv(value string) (string, error) {
+	var missing []string
+	expanded := envPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		match := envPattern.FindStringSubmatch(ref)
+		if env, ok := os.LookupEnv(match[1]); ok {
+			return env
+		}
+		if match[2] != "" {
+			return match[3]
+		}
+		missing = append(missing, match[1])
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量未设置: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// expandSecrets 将字符串值中的 ${secret:名称} 替换为密钥内容（只读取被引用的密钥，每个密钥读取一次）
+func expandSecrets(doc map[string]interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"secrets": doc["secrets"], "vault": doc["vault"]})
+	if err != nil {
+		return fmt.Errorf("转换密钥配置失败: %w", err)
+	}
+	var sources struct {
+		Secrets map[string]models.SecretConfig `json:"secrets"`
+		Vault   models.VaultConfig             `json:"vault"`
+	}
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return fmt.Errorf("解析密钥配置失败: %w", err)
+	}
+
+	values := make(map[string]string)
+	_, err = replaceStrings(doc, func(value string) (string, error) {
+		var resolveErr error
+		expanded := secretPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			name := secretPattern.FindStringSubmatch(ref)[1]
+			if v, ok := values[name]; ok {
+				return v
+			}
+			source, ok := sources.Secrets[name]
+			if !ok {
+				resolveErr = fmt.Errorf("未定义的密钥: %s", name)
+				return ref
+			}
+			v, err := secret.Resolve(source, sources.Vault)
+			if err != nil {
+				resolveErr = fmt.Errorf("读取密钥 %s 失败: %w", name, err)
+				return ref
+			}
+			values[name] = v
+			return v
+		})
+		return expanded, resolveErr
+	})
+	return err
+}
+
+// replaceStrings 对通用结构中的所有字符串值执行替换
+func replaceStrings(value interface{}, replace func(string) (string, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+
+	case map[string]interface{}:
+		for key, item := range v {
+			replaced, err := replaceStrings(item, replace)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = replaced
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, item := range v {
+			replaced, err := replaceStrings(item, replace)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = replaced
+		}
+		return v, nil
+
+	case []map[string]interface{}:
+		// TOML 的表数组
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			replaced, err := replaceStrings(item, replace)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = replaced
+		}
+		return items, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// includePaths 读取 include 键（字符串或字符串列表），相对路径相对于主配置文件所在目录
+func includePaths(doc map[string]interface{}, path string) ([]string, error) {
+	var names []string
+	switch v := doc[includeKey].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		names = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include 必须是文件路径或文件路径列表")
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("include 必须是文件路径或文件路径列表")
+	}
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(path), name)
+		}
+		paths = append(paths, name)
+	}
+	return paths, nil
+}
+
+// mergeDocument 合并被引用的配置文件：rules 追加到规则列表，其他顶层配置不能与已有配置重复
+func mergeDocument(doc, included map[string]interface{}, path string) error {
+	for key, value := range included {
+		if key == "rules" {
+			existing, _ := doc[key].([]interface{})
+			rules, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("配置文件 %s 的 rules 必须是列表", path)
+			}
+			doc[key] = append(existing, rules...)
+			continue
+		}
+		if _, exists := doc[key]; exists {
+			return fmt.Errorf("配置文件 %s 中的 %s 与其他配置文件重复", path, key)
+		}
+		doc[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile(%s) error = %v", name, err)
+	}
+	return path
+}
+
+func TestParseConfigFormats(t *testing.T) {
+	t.Setenv("TEST_PVE_SECRET", "s3cret")
+	t.Setenv("TEST_DB_PASSWORD", "dbpass")
+
+	dir := t.TempDir()
+	writeFile(t, dir, "rules.toml", `
+[[rules]]
+name = "monthly"
+enabled = true
+period = "month"
+limit_gb = 1000
+action = "shutdown"
+`)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.json", `{
+  "pve": {"host": "localhost", "port": 8006, "node": "pve", "api_token_secret": "${TEST_PVE_SECRET}"},
+  "storage": {"type": "mysql", "dsn": "monitor:${TEST_DB_PASSWORD}@tcp(${TEST_DB_HOST:-127.0.0.1}:3306)/traffic"},
+  "include": "rules.toml"
+}`},
+		{"config.yaml", `
+# 注释
+pve:
+  host: localhost
+  port: 8006
+  node: pve
+  api_token_secret: ${TEST_PVE_SECRET}
+storage:
+  type: mysql
+  dsn: "monitor:${TEST_DB_PASSWORD}@tcp(${TEST_DB_HOST:-127.0.0.1}:3306)/traffic"
+include: [rules.toml]
+`},
+		{"config.toml", `
+include = ["rules.toml"]
+
+[pve]
+host = "localhost"
+port = 8006
+node = "pve"
+api_token_secret = "${TEST_PVE_SECRET}"
+
+[storage]
+type = "mysql"
+dsn = "monitor:${TEST_DB_PASSWORD}@tcp(${TEST_DB_HOST:-127.0.0.1}:3306)/traffic"
+`},
+	}
+
+	for _, tt := range tests {
+		path := writeFile(t, dir, tt.name, tt.content)
+		cfg, files, err := parseConfig(path)
+		if err != nil {
+			t.Fatalf("%s: parseConfig() error = %v", tt.name, err)
+		}
+		if cfg.PVE.Port != 8006 || cfg.PVE.APITokenSecret != "s3cret" {
+			t.Fatalf("%s: pve = %+v, want port 8006 and expanded secret", tt.name, cfg.PVE)
+		}
+		if want := "monitor:dbpass@tcp(127.0.0.1:3306)/traffic"; cfg.Storage.DSN != want {
+			t.Fatalf("%s: dsn = %q, want %q", tt.name, cfg.Storage.DSN, want)
+		}
+		if len(cfg.Rules) != 1 || cfg.Rules[0].Name != "monthly" || cfg.Rules[0].LimitGB != 1000 {
+			t.Fatalf("%s: rules = %+v, want included monthly rule", tt.name, cfg.Rules)
+		}
+		if len(files) != 2 {
+			t.Fatalf("%s: files = %v, want config and included rules file", tt.name, files)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "pve.yaml", "pve:\n  host: other\n")
+	writeFile(t, dir, "nested.yaml", "include: pve.yaml\n")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing_env.yaml", "pve:\n  api_token_secret: ${TEST_UNSET_SECRET}\n"},
+		{"duplicate.yaml", "pve:\n  host: localhost\ninclude: pve.yaml\n"},
+		{"nested_include.yaml", "include: nested.yaml\n"},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, dir, tt.name, tt.content)
+		if _, _, err := parseConfig(path); err == nil {
+			t.Fatalf("%s: parseConfig() should fail", tt.name)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"pve-traffic-monitor/pkg/models"
-	"strings"
+	"pve-traffic-monitor/pkg/secret"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -17,10 +17,12 @@ type Loader struct {
 	config       *models.Config
 	mu           sync.RWMutex
 	lastModified time.Time
+	files        []string  // 上次加载读取的文件（主配置文件、include 的文件和密钥文件）
+	refreshedAt  time.Time // 上次读取 Vault 密钥的时间
 	callbacks    []func(*models.Config)
 }
 
-// NewLoader 创建配置加载器
+// NewLoader 创建配置加载器（支持 JSON、YAML 和 TOML，按扩展名识别）
 func NewLoader(configPath string) (*Loader, error) {
 	loader := &Loader{
 		configPath: configPath,
@@ -37,48 +39,90 @@ func NewLoader(configPath string) (*Loader, error) {
 
 // Reload 重新加载配置
 func (l *Loader) Reload() error {
-	// 检查文件是否修改
-	fileInfo, err := os.Stat(l.configPath)
+	// 检查文件是否修改（包括 include 的文件）
+	l.mu.RLock()
+	files := l.files
+	l.mu.RUnlock()
+	if len(files) == 0 {
+		files = []string{l.configPath}
+	}
+	modTime, err := latestModTime(files)
 	if err != nil {
-		return fmt.Errorf("获取配置文件信息失败: %w", err)
+		return err
 	}
 
-	// 如果文件没有修改，跳过重载
-	if !l.lastModified.IsZero() && !fileInfo.ModTime().After(l.lastModified) {
+	// 如果文件没有修改，跳过重载（Vault 密钥无法检测修改，按刷新间隔重新读取）
+	modified := l.lastModified.IsZero() || modTime.After(l.lastModified)
+	if !modified && !l.vaultRefreshDue() {
 		return nil
 	}
 
-	// 读取配置文件
-	data, err := os.ReadFile(l.configPath)
+	// 读取并解析配置（展开环境变量，合并 include 的文件）
+	newConfig, files, err := parseConfig(l.configPath)
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %w", err)
-	}
-
-	// 解析配置
-	var newConfig models.Config
-	if err := json.Unmarshal(data, &newConfig); err != nil {
-		return fmt.Errorf("解析配置文件失败: %w", err)
+		return err
 	}
 
 	// 验证配置
-	if err := l.validateConfig(&newConfig); err != nil {
+	if err := validateConfig(newConfig); err != nil {
 		return fmt.Errorf("配置验证失败: %w", err)
 	}
 
+	// include 列表和密钥文件可能改变，按本次读取的文件重新计算修改时间
+	files = append(files, secret.Files(newConfig.Secrets)...)
+	if modTime, err = latestModTime(files); err != nil {
+		return err
+	}
+
 	// 更新配置
 	l.mu.Lock()
-	l.config = &newConfig
-	l.lastModified = fileInfo.ModTime()
+	unchanged := !modified && reflect.DeepEqual(l.config, newConfig)
+	if !unchanged {
+		l.config = newConfig
+	}
+	l.lastModified = modTime
+	l.files = files
+	l.refreshedAt = time.Now()
 	l.mu.Unlock()
 
-	log.Println("配置文件已重载")
+	// 定期刷新的 Vault 密钥没有变化时不通知
+	if unchanged {
+		return nil
+	}
+	if modified {
+		log.Println("配置文件已重载")
+	} else {
+		log.Println("Vault 密钥已更新，配置已重载")
+	}
 
 	// 通知所有回调函数
-	l.notifyCallbacks(&newConfig)
+	l.notifyCallbacks(newConfig)
 
 	return nil
 }
 
+// vaultRefreshDue 是否需要重新读取 Vault 密钥
+func (l *Loader) vaultRefreshDue() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config != nil && l.config.UsesVault() && time.Since(l.refreshedAt) >= l.config.Vault.RefreshInterval()
+}
+
+// latestModTime 获取文件中最晚的修改时间
+func latestModTime(files []string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("获取配置文件信息失败: %w", err)
+		}
+		if fileInfo.ModTime().After(latest) {
+			latest = fileInfo.ModTime()
+		}
+	}
+	return latest, nil
+}
+
 // GetConfig 获取当前配置（线程安全）
 func (l *Loader) GetConfig() *models.Config {
 	l.mu.RLock()
@@ -127,89 +171,6 @@ func (l *Loader) StartAutoReload(interval time.Duration) {
 	}()
 }
 
-// validateConfig 验证配置
-func (l *Loader) validateConfig(config *models.Config) error {
-	// 验证 PVE 配置
-	if config.PVE.Host == "" {
-		return fmt.Errorf("PVE 主机地址不能为空")
-	}
-	if config.PVE.Port <= 0 || config.PVE.Port > 65535 {
-		return fmt.Errorf("PVE 端口无效: %d", config.PVE.Port)
-	}
-	if config.PVE.Node == "" {
-		return fmt.Errorf("PVE 节点名称不能为空")
-	}
-
-	// 验证监控配置
-	if config.Monitor.IntervalSeconds <= 0 {
-		return fmt.Errorf("监控间隔必须大于 0")
-	}
-
-	// 验证存储配置
-	if config.Storage.Type == "" {
-		config.Storage.Type = "file" // 默认使用文件存储
-	}
-
-	storageType := strings.ToLower(config.Storage.Type)
-	switch storageType {
-	case "file":
-		if config.Storage.FilePath == "" {
-			return fmt.Errorf("文件存储路径不能为空")
-		}
-	case "mysql", "postgres", "postgresql", "sqlite", "sqlite3":
-		if config.Storage.DSN == "" {
-			return fmt.Errorf("数据库连接字符串不能为空")
-		}
-	default:
-		return fmt.Errorf("不支持的存储类型: %s (支持: file, mysql, postgresql, sqlite)", storageType)
-	}
-
-	// 验证规则
-	for i, rule := range config.Rules {
-		if rule.Name == "" {
-			return fmt.Errorf("规则 #%d 名称不能为空", i)
-		}
-		if rule.Period != "hour" && rule.Period != "day" && rule.Period != "month" {
-			return fmt.Errorf("规则 %s 周期无效: %s", rule.Name, rule.Period)
-		}
-		if rule.LimitGB <= 0 {
-			return fmt.Errorf("规则 %s 流量限制必须大于 0", rule.Name)
-		}
-		// 验证操作类型
-		validActions := map[string]bool{
-			"shutdown":   true,
-			"stop":       true,
-			"disconnect": true,
-			"rate_limit": true,
-		}
-		if !validActions[rule.Action] {
-			return fmt.Errorf("规则 %s 操作无效: %s (支持: shutdown, stop, disconnect, rate_limit)", rule.Name, rule.Action)
-		}
-
-		// 验证限速值
-		if rule.Action == "rate_limit" && rule.RateLimitMB <= 0 {
-			return fmt.Errorf("规则 %s 限速值必须大于 0 MB/s", rule.Name)
-		}
-
-		// 验证流量方向
-		if rule.TrafficDirection != "" {
-			validDirections := map[string]bool{
-				"both":     true,
-				"upload":   true,
-				"download": true,
-				"tx":       true,
-				"rx":       true,
-			}
-			if !validDirections[rule.TrafficDirection] {
-				return fmt.Errorf("规则 %s 流量方向无效: %s (支持: both, upload, download)", rule.Name, rule.TrafficDirection)
-			}
-		}
-
-	}
-
-	return nil
-}
-
 // GetLastModified 获取配置文件最后修改时间
 func (l *Loader) GetLastModified() time.Time {
 	l.mu.RLock()
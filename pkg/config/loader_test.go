@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloadRotatesSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := writeFile(t, dir, "pve-token", "old-secret\n")
+	path := writeFile(t, dir, "config.yaml", `pve:
+  host: localhost
+  port: 8006
+  node: pve
+  api_token_id: monitor@pve!traffic
+  api_token_secret: ${secret:pve_token}
+monitor:
+  interval_seconds: 60
+storage:
+  type: file
+  file_path: ./data
+secrets:
+  pve_token:
+    provider: file
+    path: `+secretPath+`
+`)
+
+	loader, err := NewLoader(path)
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+	if got := loader.GetConfig().PVE.APITokenSecret; got != "old-secret" {
+		t.Fatalf("api_token_secret = %q, want old-secret", got)
+	}
+
+	// 只轮换密钥文件，配置文件本身不变
+	writeFile(t, dir, "pve-token", "new-secret\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(secretPath, later, later); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	if err := loader.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := loader.GetConfig().PVE.APITokenSecret; got != "new-secret" {
+		t.Fatalf("api_token_secret after rotation = %q, want new-secret", got)
+	}
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/secret"
+	"pve-traffic-monitor/pkg/tenant"
+)
+
+// FieldError 带字段路径的配置错误（如 rules[0].period），用于定位配置文件中的行
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrorf 创建带字段路径的配置错误
+func fieldErrorf(field, format string, args ...interface{}) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// validateConfig 验证配置（返回第一个错误）
+func validateConfig(config *models.Config) error {
+	if err := validateGlobal(config); err != nil {
+		return err
+	}
+	for i, rule := range config.Rules {
+		if err := validateRule(i, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateGlobal 验证规则以外的配置
+func validateGlobal(config *models.Config) error {
+	// 验证 PVE 配置
+	if config.PVE.Host == "" {
+		return fieldErrorf("pve.host", "PVE 主机地址不能为空")
+	}
+	if config.PVE.Port <= 0 || config.PVE.Port > 65535 {
+		return fieldErrorf("pve.port", "PVE 端口无效: %d", config.PVE.Port)
+	}
+	if config.PVE.Node == "" {
+		return fieldErrorf("pve.node", "PVE 节点名称不能为空")
+	}
+	if _, err := pve.TLSConfig(config.PVE); err != nil {
+		field := "pve.ca_cert"
+		if config.PVE.Fingerprint != "" {
+			field = "pve.fingerprint"
+		}
+		return &FieldError{Field: field, Err: err}
+	}
+	switch config.PVE.AuthMode() {
+	case models.PVEAuthToken:
+	case models.PVEAuthTicket:
+		if config.PVE.Username == "" || config.PVE.Password == "" {
+			return fieldErrorf("pve.username", "票据认证需要配置 username 和 password")
+		}
+	default:
+		return fieldErrorf("pve.auth_method", "无效的 PVE 认证方式: %s（支持 token, ticket）", config.PVE.AuthMethod)
+	}
+
+	// 验证监控配置
+	if config.Monitor.IntervalSeconds <= 0 {
+		return fieldErrorf("monitor.interval_seconds", "监控间隔必须大于 0")
+	}
+
+	// 验证操作标签
+	if err := config.Monitor.Tags.Validate(); err != nil {
+		return fieldErrorf("monitor.tags", "操作标签配置无效: %w", err)
+	}
+	if !models.ValidMarker(config.Monitor.Marker) {
+		return fieldErrorf("monitor.marker", "无效的限制状态标记方式: %s（支持 tags, description）", config.Monitor.Marker)
+	}
+	if !models.ValidTemplateMode(config.Monitor.Templates) {
+		return fieldErrorf("monitor.templates", "无效的模板处理方式: %s（支持 exclude, record, enforce）", config.Monitor.Templates)
+	}
+	if !models.ValidBackupTrafficMode(config.Monitor.BackupTraffic) {
+		return fieldErrorf("monitor.backup_traffic", "无效的备份流量处理方式: %s（支持 count, tag, exclude）", config.Monitor.BackupTraffic)
+	}
+	if !models.ValidConflictPolicy(config.Monitor.ConflictPolicy) {
+		return fieldErrorf("monitor.conflict_policy", "无效的规则冲突处理策略: %s（支持 most_severe, first_match）", config.Monitor.ConflictPolicy)
+	}
+	if err := config.Monitor.Bridges.Validate(); err != nil {
+		return fieldErrorf("monitor.bridges", "网桥过滤配置无效: %w", err)
+	}
+	if config.Monitor.TaskTimeout < 0 {
+		return fieldErrorf("monitor.task_timeout_seconds", "任务等待时间不能为负数")
+	}
+	if ha := config.Monitor.HA; ha.LeaseSeconds < 0 || (ha.LeaseSeconds > 0 && ha.LeaseSeconds < models.MinHALeaseSeconds) {
+		return fieldErrorf("monitor.ha.lease_seconds", "主备租约时长不能小于 %d 秒", models.MinHALeaseSeconds)
+	}
+	if err := config.Monitor.Actions.Validate(); err != nil {
+		return fieldErrorf("monitor.actions", "操作执行队列配置无效: %w", err)
+	}
+	if config.Monitor.GapIntervals < -1 {
+		return fieldErrorf("monitor.gap_intervals", "采集中断告警的间隔数不能小于 -1")
+	}
+	if config.Monitor.StoppedIntervals < -1 {
+		return fieldErrorf("monitor.stopped_intervals", "已停止虚拟机的采样间隔数不能小于 -1")
+	}
+	if err := config.Monitor.RatioAlert.Validate(); err != nil {
+		return fieldErrorf("monitor.ratio_alert", "上传/下载比例告警配置无效: %w", err)
+	}
+	if w := config.Monitor.MinuteWindowSeconds; w != 0 && (w < models.MinMinuteWindowSeconds || w > models.MaxMinuteWindowSeconds) {
+		return fieldErrorf("monitor.minute_window_seconds", "minute 周期的统计窗口必须在 %d-%d 秒之间", models.MinMinuteWindowSeconds, models.MaxMinuteWindowSeconds)
+	}
+
+	// 验证存储配置
+	if config.Storage.Type == "" {
+		config.Storage.Type = "file" // 默认使用文件存储
+	}
+
+	storageType := strings.ToLower(config.Storage.Type)
+	switch storageType {
+	case "file":
+		if config.Storage.FilePath == "" {
+			return fieldErrorf("storage.file_path", "文件存储路径不能为空")
+		}
+	case "mysql", "postgres", "postgresql", "sqlite", "sqlite3":
+		if config.Storage.DSN == "" {
+			return fieldErrorf("storage.dsn", "数据库连接字符串不能为空")
+		}
+	default:
+		return fieldErrorf("storage.type", "不支持的存储类型: %s (支持: file, mysql, postgresql, sqlite)", storageType)
+	}
+	if config.Storage.SpoolMaxRecords < 0 {
+		return fieldErrorf("storage.spool_max_records", "本地缓冲记录数不能为负数")
+	}
+	if config.Storage.SlowQueryMs < -1 {
+		return fieldErrorf("storage.slow_query_ms", "慢查询日志阈值不能小于 -1")
+	}
+	if config.Storage.Replica != nil {
+		if err := config.Storage.Validate(); err != nil {
+			return fieldErrorf("storage.replica", "副本存储配置无效: %w", err)
+		}
+	}
+
+	// 验证语言
+	if config.Locale != "" && i18n.Normalize(config.Locale) == "" {
+		return fieldErrorf("locale", "不支持的语言: %s (支持: %s)", config.Locale, strings.Join(i18n.Supported(), ", "))
+	}
+
+	// 验证时区
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			return fieldErrorf("timezone", "无效的时区: %s", config.Timezone)
+		}
+	}
+
+	// 验证客户密钥
+	tokens := make(map[string]bool)
+	for i, key := range config.API.Keys {
+		if key.Token == "" || key.Tenant == "" {
+			return fieldErrorf(fmt.Sprintf("api.keys[%d]", i), "API 密钥 #%d 的 token 和 tenant 不能为空", i)
+		}
+		if key.Token == config.API.Token || tokens[key.Token] {
+			return fieldErrorf(fmt.Sprintf("api.keys[%d].token", i), "API 密钥 #%d 的 token 与其他令牌重复", i)
+		}
+		tokens[key.Token] = true
+	}
+
+	// 验证公开状态页签名密钥
+	if config.API.PublicSecret != "" && len(config.API.PublicSecret) < models.MinPublicSecretLength {
+		return fieldErrorf("api.public_secret", "api.public_secret 长度不能少于 %d 个字符", models.MinPublicSecretLength)
+	}
+
+	// 验证跨域和安全响应头
+	if err := config.API.CORS.Validate(); err != nil {
+		return fieldErrorf("api.cors", "api.cors 配置无效: %w", err)
+	}
+	if err := config.API.SecurityHeaders.Validate(); err != nil {
+		return fieldErrorf("api.security_headers", "api.security_headers 配置无效: %w", err)
+	}
+	if err := config.API.RateLimit.Validate(); err != nil {
+		return fieldErrorf("api.rate_limit", "api.rate_limit 配置无效: %w", err)
+	}
+
+	// 验证运行模式（代理的汇总端地址和令牌、汇总端的代理令牌）
+	if err := config.ValidateMode(); err != nil {
+		return fieldErrorf("mode", "运行模式配置无效: %w", err)
+	}
+
+	// 验证远程写入
+	if err := config.RemoteWrite.Validate(); err != nil {
+		return fieldErrorf("remote_write", "远程写入配置无效: %w", err)
+	}
+	if err := config.LineProtocol.Validate(); err != nil {
+		return fieldErrorf("line_protocol", "行协议推送配置无效: %w", err)
+	}
+	if err := config.Archive.Validate(); err != nil {
+		return fieldErrorf("archive", "归档配置无效: %w", err)
+	}
+	if err := config.Events.Validate(); err != nil {
+		return fieldErrorf("events.warn_percent", "事件配置无效: %w", err)
+	}
+	if err := config.Notify.Validate(); err != nil {
+		return fieldErrorf("notify", "通知配置无效: %w", err)
+	}
+	for i, rule := range config.Rules {
+		if err := config.Notify.ValidateRuleNotify(rule); err != nil {
+			return &FieldError{Field: fmt.Sprintf("rules[%d].notify", i), Err: err}
+		}
+	}
+
+	// 验证客户划分（映射文件、重复映射）
+	if _, err := tenant.NewResolver(config.Tenants); err != nil {
+		return &FieldError{Field: "tenants", Err: err}
+	}
+
+	// 验证外部密钥来源（未被引用的密钥不会读取，这里只检查配置是否完整）
+	for name, source := range config.Secrets {
+		if err := secret.Validate(source); err != nil {
+			return fieldErrorf("secrets."+name, "密钥 %s 配置无效: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateRule 验证第 i 条规则
+func validateRule(i int, rule models.Rule) error {
+	field := func(name string) string {
+		return fmt.Sprintf("rules[%d].%s", i, name)
+	}
+
+	if rule.Name == "" {
+		return fieldErrorf(field("name"), "规则 #%d 名称不能为空", i)
+	}
+	if rule.Type != "" && rule.Type != "volume" && rule.Type != "rate" {
+		return fieldErrorf(field("type"), "规则 %s 类型无效: %s (支持: volume, rate)", rule.Name, rule.Type)
+	}
+	isRateRule := rule.Type == "rate"
+	_, rolling := rule.RollingWindow()
+	if rule.Period != "hour" && rule.Period != "day" && rule.Period != "month" && !rolling &&
+		!(isRateRule && rule.Period == "") {
+		return fieldErrorf(field("period"), "规则 %s 周期无效: %s", rule.Name, rule.Period)
+	}
+	if rolling && rule.UseCreationTime {
+		return fieldErrorf(field("use_creation_time"), "规则 %s 滑动窗口周期不能与 use_creation_time 同时使用", rule.Name)
+	}
+	if rule.Period == "" && rule.UseCreationTime {
+		return fieldErrorf(field("use_creation_time"), "规则 %s 未设置周期时不能使用 use_creation_time（无法计算恢复时间）", rule.Name)
+	}
+	if rule.Timezone != "" {
+		if _, err := time.LoadLocation(rule.Timezone); err != nil {
+			return fieldErrorf(field("timezone"), "规则 %s 时区无效: %s", rule.Name, rule.Timezone)
+		}
+	}
+	if rule.AnchorDay < 0 || rule.AnchorDay > 31 || rule.AnchorHour < 0 || rule.AnchorHour > 23 {
+		return fieldErrorf(field("anchor_day"), "规则 %s 账单日无效: %d 日 %d 时 (日: 1-31, 时: 0-23)", rule.Name, rule.AnchorDay, rule.AnchorHour)
+	}
+	if rule.AnchorDay == 0 && rule.AnchorHour != 0 {
+		return fieldErrorf(field("anchor_hour"), "规则 %s 设置 anchor_hour 时必须设置 anchor_day", rule.Name)
+	}
+	if rule.AnchorDay > 0 && (rule.Period != "month" || rule.UseCreationTime) {
+		return fieldErrorf(field("anchor_day"), "规则 %s 账单日仅支持 month 周期，且不能与 use_creation_time 同时使用", rule.Name)
+	}
+	if isRateRule {
+		if rule.RateThresholdMbps <= 0 {
+			return fieldErrorf(field("rate_threshold_mbps"), "规则 %s 带宽阈值必须大于 0 Mbps", rule.Name)
+		}
+		if rule.RateWindowMinutes < 0 {
+			return fieldErrorf(field("rate_window_minutes"), "规则 %s 带宽统计窗口不能为负数", rule.Name)
+		}
+	} else if rule.LimitGB <= 0 {
+		return fieldErrorf(field("limit_gb"), "规则 %s 流量限制必须大于 0", rule.Name)
+	}
+	// 验证主机规则（按匹配虚拟机的流量总和判断）
+	if rule.Scope != "" && rule.Scope != models.RuleScopeVM && rule.Scope != models.RuleScopeHost {
+		return fieldErrorf(field("scope"), "规则 %s 判断范围无效: %s (支持: vm, host)", rule.Name, rule.Scope)
+	}
+	if rule.IsHostRule() {
+		if rule.Type != "" && rule.Type != models.RuleTypeVolume {
+			return fieldErrorf(field("scope"), "规则 %s 的 scope=host 仅支持 volume 规则", rule.Name)
+		}
+		if rule.UseCreationTime {
+			return fieldErrorf(field("use_creation_time"), "规则 %s 的 scope=host 不能与 use_creation_time 同时使用", rule.Name)
+		}
+		if rule.Target != "" && rule.Target != models.TargetTopTalkers {
+			return fieldErrorf(field("target"), "规则 %s 操作对象无效: %s (支持: top_talkers)", rule.Name, rule.Target)
+		}
+		if rule.TopN < 0 {
+			return fieldErrorf(field("top_n"), "规则 %s 的 top_n 不能为负数", rule.Name)
+		}
+	} else if rule.Target != "" || rule.TopN != 0 {
+		return fieldErrorf(field("target"), "规则 %s 的 target 和 top_n 仅支持 scope=host", rule.Name)
+	}
+	// 验证操作类型
+	validActions := map[string]bool{
+		"shutdown":   true,
+		"stop":       true,
+		"disconnect": true,
+		"rate_limit": true,
+		"exec":       true,
+	}
+	if !validActions[rule.Action] {
+		return fieldErrorf(field("action"), "规则 %s 操作无效: %s (支持: shutdown, stop, disconnect, rate_limit, exec)", rule.Name, rule.Action)
+	}
+
+	// 验证外部命令
+	if rule.Action == "exec" && rule.Exec == nil {
+		return fieldErrorf(field("exec"), "规则 %s 的 exec 操作需要指定 exec.command", rule.Name)
+	}
+	if rule.Exec != nil {
+		if err := rule.Exec.Validate(); err != nil {
+			return fieldErrorf(field("exec"), "规则 %s 的命令无效: %v", rule.Name, err)
+		}
+	}
+	if rule.PostAction != nil {
+		if err := rule.PostAction.Validate(); err != nil {
+			return fieldErrorf(field("post_action"), "规则 %s 的操作后钩子无效: %v", rule.Name, err)
+		}
+	}
+
+	// 验证限速值
+	if rule.Action == "rate_limit" && rule.RateLimitMB <= 0 {
+		return fieldErrorf(field("rate_limit_mb"), "规则 %s 限速值必须大于 0 MB/s", rule.Name)
+	}
+
+	// 验证重复执行限制
+	if rule.CooldownMinutes < 0 {
+		return fieldErrorf(field("cooldown_minutes"), "规则 %s 冷却时间不能为负数", rule.Name)
+	}
+	if rule.MaxExecutions < 0 {
+		return fieldErrorf(field("max_executions"), "规则 %s 每周期最多执行次数不能为负数", rule.Name)
+	}
+
+	// 验证网卡选择
+	for _, name := range rule.Interfaces {
+		if strings.TrimSpace(name) == "" {
+			return fieldErrorf(field("interfaces"), "规则 %s 的网卡名不能为空", rule.Name)
+		}
+	}
+
+	// 验证流量方向
+	if rule.TrafficDirection != "" {
+		validDirections := map[string]bool{
+			"both":     true,
+			"upload":   true,
+			"download": true,
+			"tx":       true,
+			"rx":       true,
+		}
+		if !validDirections[rule.TrafficDirection] {
+			return fieldErrorf(field("traffic_direction"), "规则 %s 流量方向无效: %s (支持: both, upload, download)", rule.Name, rule.TrafficDirection)
+		}
+	}
+
+	return nil
+}
+
+// fieldOf 获取错误的字段路径（没有时返回空字符串）
+func fieldOf(err error) string {
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.Field
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package creation
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/pve"
+	"pve-traffic-monitor/pkg/storage"
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// 创建时间来源
+const (
+	SourceOverride = "override" // 运维手动设置
+	SourceMeta     = "meta"     // 虚拟机配置 meta.ctime
+	SourceTask     = "task"     // PVE 任务历史中的创建任务（qmcreate）
+	SourceTraffic  = "traffic"  // 最早的流量记录（首次采集时间）
+)
+
+// 虚拟机状态中使用的键（与恢复状态保存在一起）
+const (
+	stateKeyOverride     = "creation_time_override"
+	stateKeyFirstTraffic = "first_traffic_at"
+)
+
+// cacheTTL 解析结果缓存时间（监控循环每个周期都会查询）
+const cacheTTL = time.Hour
+
+// Result 虚拟机创建时间及其来源
+type Result struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+}
+
+// cachedResult 缓存的解析结果
+type cachedResult struct {
+	result    Result
+	fetchedAt time.Time
+}
+
+// Resolver 虚拟机创建时间解析器
+// 按 运维设置 > meta.ctime > 任务历史 > 最早流量记录 的顺序查找，没有 meta.ctime 的虚拟机（旧版本创建、导入等）也能按创建时间划分周期
+type Resolver struct {
+	mu     sync.RWMutex
+	client *pve.Client // 为 nil 时跳过 PVE 来源
+	store  storage.Interface
+	cache  sync.Map // VMID -> cachedResult
+}
+
+// NewResolver 创建创建时间解析器
+func NewResolver(client *pve.Client, store storage.Interface) *Resolver {
+	return &Resolver{client: client, store: store}
+}
+
+// SetClient 更换 PVE 客户端（配置重载重新登录时调用）
+func (r *Resolver) SetClient(client *pve.Client) {
+	r.mu.Lock()
+	r.client = client
+	r.mu.Unlock()
+}
+
+// Resolve 获取虚拟机创建时间及其来源（成功的结果缓存一小时）
+func (r *Resolver) Resolve(vmid int) (Result, error) {
+	if cached, ok := r.cache.Load(vmid); ok {
+		if entry := cached.(cachedResult); time.Since(entry.fetchedAt) < cacheTTL {
+			return entry.result, nil
+		}
+	}
+
+	result, err := r.resolve(vmid)
+	if err != nil {
+		return Result{}, err
+	}
+	r.cache.Store(vmid, cachedResult{result: result, fetchedAt: time.Now()})
+	return result, nil
+}
+
+// Time 获取虚拟机创建时间（不关心来源时使用）
+func (r *Resolver) Time(vmid int) (time.Time, error) {
+	result, err := r.Resolve(vmid)
+	return result.Time, err
+}
+
+// Invalidate 清除虚拟机的解析缓存（vmid 为 0 时清除全部）
+func (r *Resolver) Invalidate(vmid int) {
+	if vmid != 0 {
+		r.cache.Delete(vmid)
+		return
+	}
+	r.cache.Range(func(key, _ interface{}) bool {
+		r.cache.Delete(key)
+		return true
+	})
+}
+
+// SetOverride 设置运维指定的创建时间（优先于其他来源）
+func (r *Resolver) SetOverride(vmid int, t time.Time) error {
+	if err := storage.UpdateVMState(r.store, vmid, map[string]interface{}{stateKeyOverride: t}); err != nil {
+		return fmt.Errorf("保存创建时间失败: %w", err)
+	}
+	r.Invalidate(vmid)
+	return nil
+}
+
+// ClearOverride 清除运维指定的创建时间
+func (r *Resolver) ClearOverride(vmid int) error {
+	if err := storage.UpdateVMState(r.store, vmid, map[string]interface{}{stateKeyOverride: nil}); err != nil {
+		return fmt.Errorf("清除创建时间失败: %w", err)
+	}
+	r.Invalidate(vmid)
+	return nil
+}
+
+// resolve 按来源优先级查找创建时间
+func (r *Resolver) resolve(vmid int) (Result, error) {
+	state, err := r.store.LoadVMState(vmid)
+	if err != nil {
+		utils.DebugLog("VM%d 读取虚拟机状态失败: %v", vmid, err)
+	}
+	if t, ok := stateTime(state, stateKeyOverride); ok {
+		return Result{Time: t, Source: SourceOverride}, nil
+	}
+
+	var failures []string
+
+	r.mu.RLock()
+	client := r.client
+	r.mu.RUnlock()
+	if client != nil {
+		t, err := client.GetVMCreationTime(vmid)
+		if err == nil {
+			return Result{Time: t, Source: SourceMeta}, nil
+		}
+		failures = append(failures, err.Error())
+
+		t, err = client.GetVMCreateTaskTime(vmid)
+		if err == nil {
+			return Result{Time: t, Source: SourceTask}, nil
+		}
+		failures = append(failures, err.Error())
+	}
+
+	if t, ok := r.firstTraffic(vmid, state); ok {
+		return Result{Time: t, Source: SourceTraffic}, nil
+	}
+	failures = append(failures, "没有流量记录")
+
+	return Result{}, fmt.Errorf("无法获取虚拟机 %d 的创建时间: %s", vmid, strings.Join(failures, "; "))
+}
+
+// firstTraffic 获取虚拟机的首次采集时间
+// 最早的记录会被保留期清理删除，首次发现的时间保存在虚拟机状态中，清理后创建时间不会随之后移
+func (r *Resolver) firstTraffic(vmid int, state map[string]interface{}) (time.Time, bool) {
+	stored, hasStored := stateTime(state, stateKeyFirstTraffic)
+
+	earliest, err := storage.EarliestRecordTime(r.store, vmid)
+	if err != nil {
+		utils.DebugLog("VM%d 查询最早流量记录失败: %v", vmid, err)
+	}
+	if earliest.IsZero() || (hasStored && !earliest.Before(stored)) {
+		return stored, hasStored
+	}
+
+	if err := storage.UpdateVMState(r.store, vmid, map[string]interface{}{stateKeyFirstTraffic: earliest}); err != nil {
+		utils.DebugLog("VM%d 保存首次采集时间失败: %v", vmid, err)
+	}
+	return earliest, true
+}
+
+// stateTime 读取虚拟机状态中的时间（存储反序列化后为 RFC3339 字符串）
+func stateTime(state map[string]interface{}, key string) (time.Time, bool) {
+	switch v := state[key].(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, !t.IsZero()
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,62 @@
+package creation
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestResolverFallsBackToFirstTraffic(t *testing.T) {
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	defer store.Close()
+
+	first := time.Date(2026, 3, 1, 8, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		record := models.TrafficRecord{VMID: 100, Timestamp: first.AddDate(0, 0, i), RXBytes: uint64(i)}
+		if err := store.SaveTrafficRecord(record); err != nil {
+			t.Fatalf("SaveTrafficRecord() error = %v", err)
+		}
+	}
+
+	r := NewResolver(nil, store)
+	got, err := r.Resolve(100)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got.Source != SourceTraffic || !got.Time.Equal(first) {
+		t.Fatalf("Resolve() = %v (%s), want %v (%s)", got.Time, got.Source, first, SourceTraffic)
+	}
+
+	// 保留期清理删除最早的记录后，首次采集时间不变
+	if _, err := store.DeleteRecordsBefore(first.AddDate(0, 0, 2)); err != nil {
+		t.Fatalf("DeleteRecordsBefore() error = %v", err)
+	}
+	r.Invalidate(0)
+	if got, _ := r.Resolve(100); !got.Time.Equal(first) {
+		t.Fatalf("Resolve() after cleanup = %v, want %v", got.Time, first)
+	}
+
+	override := time.Date(2025, 12, 24, 0, 0, 0, 0, time.Local)
+	if err := r.SetOverride(100, override); err != nil {
+		t.Fatalf("SetOverride() error = %v", err)
+	}
+	if got, _ := r.Resolve(100); got.Source != SourceOverride || !got.Time.Equal(override) {
+		t.Fatalf("Resolve() with override = %v (%s), want %v (%s)", got.Time, got.Source, override, SourceOverride)
+	}
+
+	if err := r.ClearOverride(100); err != nil {
+		t.Fatalf("ClearOverride() error = %v", err)
+	}
+	if got, _ := r.Resolve(100); got.Source != SourceTraffic {
+		t.Fatalf("Resolve() after clearing override source = %s, want %s", got.Source, SourceTraffic)
+	}
+
+	if _, err := r.Resolve(101); err == nil {
+		t.Fatal("Resolve() without any source should fail")
+	}
+}
@@ -0,0 +1,184 @@
+package digest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// 异常类型
+const (
+	AnomalyCollectionGap  = "collection_gap"  // 采集中断
+	AnomalyExternalChange = "external_change" // 限制期间运行状态被外部修改
+	AnomalyActionFailed   = "action_failed"   // 规则的操作执行失败
+	AnomalyRatio          = "ratio_anomaly"   // 上传/下载比例异常
+)
+
+// Consumer 时间范围内流量最高的虚拟机
+type Consumer struct {
+	VMID       int    `json:"vmid"`
+	Name       string `json:"name,omitempty"`
+	RXBytes    uint64 `json:"rx_bytes"`
+	TXBytes    uint64 `json:"tx_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// Quota 用量接近或超出限额的流量规则
+type Quota struct {
+	VMID     int     `json:"vmid"`
+	Name     string  `json:"name,omitempty"`
+	Rule     string  `json:"rule"`
+	Period   string  `json:"period"`
+	UsedGB   float64 `json:"used_gb"`
+	LimitGB  float64 `json:"limit_gb"`
+	Percent  float64 `json:"percent"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// Anomaly 需要关注的异常
+type Anomaly struct {
+	Time      time.Time `json:"time"`
+	VMID      int       `json:"vmid"`
+	Type      string    `json:"type"`
+	Rule      string    `json:"rule,omitempty"`
+	Intervals int       `json:"intervals,omitempty"` // collection_gap：错过的采集间隔数
+	From      string    `json:"from,omitempty"`      // external_change：之前的运行状态
+	To        string    `json:"to,omitempty"`        // external_change：变化后的运行状态
+	Action    string    `json:"action,omitempty"`    // external_change、action_failed：规则的操作
+	Ratio     float64   `json:"ratio,omitempty"`     // ratio_anomaly：上传与下载之比
+	Error     string    `json:"error,omitempty"`
+}
+
+// Report 摘要内容
+type Report struct {
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Consumers    []Consumer         `json:"consumers"`
+	QuotaPercent float64            `json:"quota_percent"`
+	Quotas       []Quota            `json:"quotas"`
+	Actions      []models.ActionLog `json:"actions"`
+	Anomalies    []Anomaly          `json:"anomalies"`
+}
+
+// TopConsumers 按总流量从高到低取前 n 台有流量的虚拟机
+func TopConsumers(consumers []Consumer, n int) []Consumer {
+	top := make([]Consumer, 0, len(consumers))
+	for _, c := range consumers {
+		if c.TotalBytes > 0 {
+			top = append(top, c)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].TotalBytes != top[j].TotalBytes {
+			return top[i].TotalBytes > top[j].TotalBytes
+		}
+		return top[i].VMID < top[j].VMID
+	})
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// SortQuotas 按用量百分比从高到低排序
+func SortQuotas(quotas []Quota) {
+	sort.SliceStable(quotas, func(i, j int) bool {
+		if quotas[i].Percent != quotas[j].Percent {
+			return quotas[i].Percent > quotas[j].Percent
+		}
+		return quotas[i].VMID < quotas[j].VMID
+	})
+}
+
+// FailedActions 操作日志中执行失败的操作（作为异常列出）
+func FailedActions(logs []models.ActionLog) []Anomaly {
+	var anomalies []Anomaly
+	for _, entry := range logs {
+		if !entry.Success {
+			anomalies = append(anomalies, Anomaly{Time: entry.Timestamp, VMID: entry.VMID, Type: AnomalyActionFailed, Rule: entry.RuleName, Action: entry.Action, Error: entry.Error})
+		}
+	}
+	return anomalies
+}
+
+// 收集器保留的异常
+const (
+	maxAnomalyAge   = 8 * 24 * time.Hour // 超过该时间的异常丢弃（摘要计划最长为每周）
+	maxAnomalyCount = 1000               // 最多保留的异常数（超过时丢弃最早的）
+)
+
+// Collector 从事件中收集异常（采集中断、外部修改运行状态、上传/下载比例异常），只保存在内存中，重启后从头收集
+type Collector struct {
+	mu        sync.Mutex
+	anomalies []Anomaly
+}
+
+// NewCollector 创建异常收集器
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Types 收集的事件类型
+func (c *Collector) Types() []events.Type {
+	return []events.Type{events.CollectionGap, events.ExternalChange, events.RatioAnomaly}
+}
+
+// Handle 记录异常事件
+func (c *Collector) Handle(ev events.Event) {
+	anomaly := Anomaly{Time: ev.Time, VMID: ev.VMID, Rule: ev.Rule}
+	switch {
+	case ev.Type == events.CollectionGap && ev.Gap != nil:
+		anomaly.Type = AnomalyCollectionGap
+		anomaly.Intervals = ev.Gap.Intervals
+		anomaly.Error = ev.Gap.Error
+	case ev.Type == events.ExternalChange && ev.Power != nil:
+		anomaly.Type = AnomalyExternalChange
+		anomaly.From, anomaly.To = ev.Power.From, ev.Power.To
+		if ev.Recovery != nil {
+			anomaly.Action = ev.Recovery.ActionTaken
+		}
+	case ev.Type == events.RatioAnomaly && ev.Ratio != nil:
+		anomaly.Type = AnomalyRatio
+		anomaly.Ratio = ev.Ratio.Ratio
+	default:
+		return
+	}
+	if anomaly.Time.IsZero() {
+		anomaly.Time = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cutoff := anomaly.Time.Add(-maxAnomalyAge)
+	kept := c.anomalies[:0]
+	for _, a := range c.anomalies {
+		if a.Time.After(cutoff) {
+			kept = append(kept, a)
+		}
+	}
+	c.anomalies = append(kept, anomaly)
+	if len(c.anomalies) > maxAnomalyCount {
+		c.anomalies = append([]Anomaly(nil), c.anomalies[len(c.anomalies)-maxAnomalyCount:]...)
+	}
+}
+
+// Between [start, end) 内收集到的异常（按时间排序）
+func (c *Collector) Between(start, end time.Time) []Anomaly {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var anomalies []Anomaly
+	for _, a := range c.anomalies {
+		if !a.Time.Before(start) && a.Time.Before(end) {
+			anomalies = append(anomalies, a)
+		}
+	}
+	return anomalies
+}
+
+// SortAnomalies 按时间排序
+func SortAnomalies(anomalies []Anomaly) {
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].Time.Before(anomalies[j].Time) })
+}
@@ -0,0 +1,54 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestTopConsumers(t *testing.T) {
+	consumers := []Consumer{{VMID: 101, TotalBytes: 10}, {VMID: 102}, {VMID: 103, TotalBytes: 30}, {VMID: 104, TotalBytes: 10}}
+	top := TopConsumers(consumers, 2)
+	if len(top) != 2 || top[0].VMID != 103 || top[1].VMID != 101 {
+		t.Fatalf("top = %+v", top)
+	}
+	if all := TopConsumers(consumers, 0); len(all) != 3 {
+		t.Fatalf("all = %+v, want VMs without traffic skipped", all)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	c.Handle(events.Event{Type: events.CollectionGap, Time: now.Add(-30 * time.Hour), VMID: 100, Gap: &events.Gap{Intervals: 3}})
+	c.Handle(events.Event{Type: events.CollectionGap, Time: now.Add(-time.Hour), VMID: 101, Gap: &events.Gap{Intervals: 5, Error: "timeout"}})
+	c.Handle(events.Event{Type: events.ExternalChange, Time: now.Add(-2 * time.Hour), VMID: 102, Rule: "monthly",
+		Power: &models.PowerEvent{From: "stopped", To: "running"}, Recovery: &models.VMState{ActionTaken: models.ActionShutdown}})
+	c.Handle(events.Event{Type: events.LimitWarning, Time: now, VMID: 103})
+
+	anomalies := c.Between(now.Add(-24*time.Hour), now)
+	SortAnomalies(anomalies)
+	if len(anomalies) != 2 || anomalies[0].VMID != 102 || anomalies[1].Intervals != 5 {
+		t.Fatalf("anomalies = %+v", anomalies)
+	}
+
+	report := Report{
+		Start:     now.Add(-24 * time.Hour),
+		End:       now,
+		Actions:   []models.ActionLog{{VMID: 104, RuleName: "daily", Action: models.ActionStop, Timestamp: now.Add(-3 * time.Hour), Error: "locked"}},
+		Anomalies: append(FailedActions([]models.ActionLog{{VMID: 104, RuleName: "daily", Action: models.ActionStop, Error: "locked"}}), anomalies...),
+	}
+	text := report.Text("en-US")
+	for _, want := range []string{"Top 0 consumers\n  None", "Actions executed (1)", "VM104 rule daily: Stop failed", "Anomalies (3)", "power state changed externally (stopped -> running)", "no traffic samples for 5 collection intervals: timeout"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+	html, err := report.HTML("zh-CN")
+	if err != nil || !strings.Contains(html, "执行的操作 (1)") {
+		t.Fatalf("html = %s, %v", html, err)
+	}
+}
@@ -0,0 +1,131 @@
+package digest
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// timeLayout 摘要中的时间格式
+const timeLayout = "2006-01-02 15:04"
+
+// maxRows 操作和异常最多列出的条数（其余只显示数量）
+const maxRows = 50
+
+// section 摘要的一部分（标题和各行内容）
+type section struct {
+	Title string
+	Rows  []string
+	Empty string
+}
+
+// Title 摘要标题
+func (r Report) Title(locale string) string {
+	return i18n.Tl(locale, "digest.title", r.Start.Format(timeLayout), r.End.Format(timeLayout))
+}
+
+// sections 按当前语言生成摘要的各部分
+func (r Report) sections(locale string) []section {
+	none := i18n.Tl(locale, "digest.none")
+	vm := func(vmid int, name string) string {
+		if name == "" {
+			return fmt.Sprintf("VM%d", vmid)
+		}
+		return fmt.Sprintf("VM%d %s", vmid, name)
+	}
+	gb := func(bytes uint64) float64 { return float64(bytes) / models.BytesPerGB }
+	action := func(name string) string { return i18n.Tl(locale, "ui.action."+name) }
+
+	consumers := section{Title: i18n.Tl(locale, "digest.top", len(r.Consumers)), Empty: none}
+	for i, c := range r.Consumers {
+		consumers.Rows = append(consumers.Rows, i18n.Tl(locale, "digest.consumer", i+1, vm(c.VMID, c.Name), gb(c.TotalBytes), gb(c.RXBytes), gb(c.TXBytes)))
+	}
+
+	quotas := section{Title: i18n.Tl(locale, "digest.quotas", r.QuotaPercent), Empty: none}
+	for _, q := range r.Quotas {
+		row := i18n.Tl(locale, "digest.quota", vm(q.VMID, q.Name), q.Rule, q.Percent, q.UsedGB, q.LimitGB)
+		if q.Exceeded {
+			row += " " + i18n.Tl(locale, "digest.exceeded")
+		}
+		quotas.Rows = append(quotas.Rows, row)
+	}
+
+	actions := section{Title: i18n.Tl(locale, "digest.actions", len(r.Actions)), Empty: none}
+	for _, a := range r.Actions {
+		result := i18n.Tl(locale, "digest.action_ok")
+		if !a.Success {
+			result = i18n.Tl(locale, "digest.action_failed")
+		}
+		actions.Rows = append(actions.Rows, i18n.Tl(locale, "digest.action", a.Timestamp.Format(timeLayout), vm(a.VMID, ""), a.RuleName, action(a.Action), result))
+	}
+
+	anomalies := section{Title: i18n.Tl(locale, "digest.anomalies", len(r.Anomalies)), Empty: none}
+	for _, a := range r.Anomalies {
+		var detail string
+		switch a.Type {
+		case AnomalyCollectionGap:
+			detail = i18n.Tl(locale, "digest.anomaly.collection_gap", a.Intervals)
+		case AnomalyExternalChange:
+			detail = i18n.Tl(locale, "digest.anomaly.external_change", a.Rule, action(a.Action), a.From, a.To)
+		case AnomalyRatio:
+			detail = i18n.Tl(locale, "digest.anomaly.ratio_anomaly", a.Ratio)
+		default:
+			detail = i18n.Tl(locale, "digest.anomaly.action_failed", a.Rule, action(a.Action))
+		}
+		if a.Error != "" {
+			detail += ": " + a.Error
+		}
+		anomalies.Rows = append(anomalies.Rows, fmt.Sprintf("%s %s %s", a.Time.Format(timeLayout), vm(a.VMID, ""), detail))
+	}
+
+	for _, s := range []*section{&actions, &anomalies} {
+		if len(s.Rows) > maxRows {
+			s.Rows = append(s.Rows[:maxRows], i18n.Tl(locale, "digest.more", len(s.Rows)-maxRows))
+		}
+	}
+	return []section{consumers, quotas, actions, anomalies}
+}
+
+// Text 纯文本摘要（即时通讯通道和邮件的纯文本部分）
+func (r Report) Text(locale string) string {
+	var b strings.Builder
+	for i, s := range r.sections(locale) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(s.Title + "\n")
+		if len(s.Rows) == 0 {
+			b.WriteString("  " + s.Empty + "\n")
+		}
+		for _, row := range s.Rows {
+			b.WriteString("  " + row + "\n")
+		}
+	}
+	return b.String()
+}
+
+var htmlTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#222;max-width:760px">
+<h2 style="font-size:18px">{{.Title}}</h2>
+{{range .Sections}}<h3 style="font-size:15px;margin:18px 0 6px;border-bottom:1px solid #ddd">{{.Title}}</h3>
+{{if .Rows}}<ul style="margin:0;padding-left:20px">{{range .Rows}}
+<li style="margin:2px 0">{{.}}</li>{{end}}
+</ul>{{else}}<p style="color:#888;margin:0">{{.Empty}}</p>{{end}}
+{{end}}<p style="color:#888;font-size:12px;margin-top:24px">{{.Footer}}</p>
+</body></html>
+`))
+
+// HTML HTML 摘要（邮件正文）
+func (r Report) HTML(locale string) (string, error) {
+	var b strings.Builder
+	err := htmlTemplate.Execute(&b, map[string]interface{}{
+		"Title":    r.Title(locale),
+		"Sections": r.sections(locale),
+		"Footer":   i18n.Tl(locale, "digest.footer"),
+	})
+	return b.String(), err
+}
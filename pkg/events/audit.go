@@ -0,0 +1,65 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func init() {
+	Register("audit", newAuditLog)
+}
+
+// auditLog 审计日志：把预警、操作、恢复和配置重载事件逐行写入 JSON Lines 文件
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newAuditLog 配置了 events.audit_log 时创建审计日志
+func newAuditLog(cfg *models.Config) (Subscriber, error) {
+	path := cfg.Events.AuditLog
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+// Types 审计日志记录的事件（不记录每次采集）
+func (a *auditLog) Types() []Type {
+	return []Type{LimitWarning, ActionExecuted, RecoveryDone, ConfigReloaded}
+}
+
+// Handle 写入一行事件
+func (a *auditLog) Handle(ev Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("序列化审计事件失败: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+}
+
+// Close 关闭文件
+func (a *auditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
@@ -0,0 +1,244 @@
+package events
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	SampleCollected Type = "sample_collected" // 保存了一条采集的流量记录
+	LimitWarning    Type = "limit_warning"    // 流量规则用量达到预警百分比（每个周期每条规则只发布一次）
+	LimitExceeded   Type = "limit_exceeded"   // 规则超限（超限期间每个采集周期都发布）
+	ActionExecuted  Type = "action_executed"  // 执行了规则的操作（成功或失败）
+	RecoveryDone    Type = "recovery_done"    // 恢复了被限制的虚拟机
+	ConfigReloaded  Type = "config_reloaded"  // 配置已重载
+	CollectionGap   Type = "collection_gap"   // 运行中的虚拟机超过 gap_intervals 个采集间隔没有成功采样（每次中断只发布一次）
+	PowerChanged    Type = "power_changed"    // 虚拟机运行状态变化（running ↔ stopped 等，每个采集周期检测）
+	ExternalChange  Type = "external_change"  // 被限制的虚拟机运行状态被外部修改（每次限制只发布一次，恢复时不再改变其运行状态）
+	RatioAnomaly    Type = "ratio_anomaly"    // 上传与下载之比持续超过 monitor.ratio_alert 的阈值（每次异常只发布一次）
+)
+
+// queueSize 每个订阅者的事件队列长度，处理不过来时丢弃新事件
+const queueSize = 256
+
+// Usage 预警时的用量
+type Usage struct {
+	UsedGB      float64   `json:"used_gb"`
+	LimitGB     float64   `json:"limit_gb"`
+	Percent     float64   `json:"percent"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+}
+
+// Event 事件，按类型填写对应的内容
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	VMID int       `json:"vmid,omitempty"`
+	Rule string    `json:"rule,omitempty"`
+
+	Reason   string                `json:"reason,omitempty"`   // limit_exceeded：触发原因
+	Record   *models.TrafficRecord `json:"record,omitempty"`   // sample_collected
+	Usage    *Usage                `json:"usage,omitempty"`    // limit_warning、limit_exceeded（流量规则）
+	Action   *models.ActionLog     `json:"action,omitempty"`   // action_executed
+	Recovery *models.VMState       `json:"recovery,omitempty"` // recovery_done（恢复前的限制状态）、external_change（当前的限制状态）
+	Gap      *Gap                  `json:"gap,omitempty"`      // collection_gap
+	Power    *models.PowerEvent    `json:"power,omitempty"`    // power_changed、external_change
+	Ratio    *Ratio                `json:"ratio,omitempty"`    // ratio_anomaly
+}
+
+// Ratio 上传/下载比例异常
+type Ratio struct {
+	UploadMbps    float64 `json:"upload_mbps"`   // 窗口平均上传带宽
+	DownloadMbps  float64 `json:"download_mbps"` // 窗口平均下载带宽
+	Ratio         float64 `json:"ratio"`         // 上传与下载之比
+	Threshold     float64 `json:"threshold"`
+	WindowMinutes int     `json:"window_minutes"`
+}
+
+// Gap 采集中断
+type Gap struct {
+	LastSample time.Time `json:"last_sample"`     // 最近一次成功采样的时间（本次运行中从未采样时为开始跟踪的时间）
+	Intervals  int       `json:"intervals"`       // 已错过的采集间隔数
+	Error      string    `json:"error,omitempty"` // 最近一次采样的错误
+}
+
+// Handler 事件处理函数
+type Handler func(Event)
+
+// SubscriberStats 订阅者统计
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"` // 已处理的事件数
+	Dropped   uint64 `json:"dropped"`   // 队列已满而丢弃的事件数
+}
+
+// Stats 事件统计
+type Stats struct {
+	Published   map[Type]uint64   `json:"published"`
+	Subscribers []SubscriberStats `json:"subscribers"`
+}
+
+// subscription 一个订阅者：独立的队列和处理协程，慢的订阅者不影响采集和其他订阅者
+type subscription struct {
+	name    string
+	types   map[Type]bool // 为空表示订阅所有类型
+	handler Handler
+	queue   chan Event
+	done    chan struct{}
+
+	delivered, dropped uint64 // 由 Bus.mu 保护
+}
+
+// Bus 事件总线：发布不阻塞，事件按订阅顺序异步交给各订阅者
+type Bus struct {
+	mu        sync.Mutex
+	subs      []*subscription
+	published map[Type]uint64
+	closed    bool
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{published: make(map[Type]uint64)}
+}
+
+// Subscribe 订阅事件（types 为空时订阅所有类型），返回取消订阅的函数（会等待已排队的事件处理完）
+func (b *Bus) Subscribe(name string, handler Handler, types ...Type) func() {
+	sub := &subscription{
+		name:    name,
+		handler: handler,
+		queue:   make(chan Event, queueSize),
+		done:    make(chan struct{}),
+	}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return func() {}
+	}
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	go b.deliver(sub)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.remove(sub) })
+	}
+}
+
+// Publish 发布事件（Time 为零值时使用当前时间）
+func (b *Bus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.published[ev.Type]++
+	for _, sub := range b.subs {
+		if sub.types != nil && !sub.types[ev.Type] {
+			continue
+		}
+		select {
+		case sub.queue <- ev:
+		default:
+			if sub.dropped == 0 {
+				log.Printf("事件订阅者 %s 处理不过来，开始丢弃事件", sub.name)
+			}
+			sub.dropped++
+		}
+	}
+}
+
+// Stats 获取事件统计
+func (b *Bus) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := Stats{Published: make(map[Type]uint64, len(b.published))}
+	for t, n := range b.published {
+		st.Published[t] = n
+	}
+	for _, sub := range b.subs {
+		st.Subscribers = append(st.Subscribers, SubscriberStats{Name: sub.name, Delivered: sub.delivered, Dropped: sub.dropped})
+	}
+	sort.Slice(st.Subscribers, func(i, j int) bool {
+		return st.Subscribers[i].Name < st.Subscribers[j].Name
+	})
+	return st
+}
+
+// Close 停止接收事件，等待所有订阅者处理完已排队的事件
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		close(sub.queue)
+		<-sub.done
+	}
+}
+
+// remove 取消订阅并等待已排队的事件处理完
+func (b *Bus) remove(sub *subscription) {
+	b.mu.Lock()
+	found := false
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			found = true
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	// 总线已关闭时由 Close 关闭队列
+	if found {
+		close(sub.queue)
+		<-sub.done
+	}
+}
+
+// deliver 订阅者的处理协程（处理函数 panic 时记录日志，不影响后续事件）
+func (b *Bus) deliver(sub *subscription) {
+	defer close(sub.done)
+	for ev := range sub.queue {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("事件订阅者 %s 处理 %s 事件失败: %v", sub.name, ev.Type, r)
+				}
+			}()
+			sub.handler(ev)
+		}()
+
+		b.mu.Lock()
+		sub.delivered++
+		b.mu.Unlock()
+	}
+}
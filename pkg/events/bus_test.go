@@ -0,0 +1,89 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestBusDeliversByType(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	var all, actions []Type
+	unsubscribeAll := bus.Subscribe("all", func(ev Event) {
+		mu.Lock()
+		all = append(all, ev.Type)
+		mu.Unlock()
+	})
+	unsubscribeActions := bus.Subscribe("actions", func(ev Event) {
+		mu.Lock()
+		actions = append(actions, ev.Type)
+		mu.Unlock()
+		if ev.VMID == 0 {
+			panic("bad event") // 处理函数 panic 不影响后续事件
+		}
+	}, ActionExecuted)
+
+	bus.Publish(Event{Type: SampleCollected, VMID: 100})
+	bus.Publish(Event{Type: ActionExecuted})
+	bus.Publish(Event{Type: ActionExecuted, VMID: 100})
+	unsubscribeActions()
+	bus.Publish(Event{Type: ActionExecuted, VMID: 101})
+	unsubscribeAll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(all) != 4 || all[0] != SampleCollected {
+		t.Fatalf("all subscriber got %v, want 4 events starting with sample_collected", all)
+	}
+	if len(actions) != 2 {
+		t.Fatalf("actions subscriber got %v, want 2 events before unsubscribing", actions)
+	}
+	if st := bus.Stats(); st.Published[ActionExecuted] != 3 || len(st.Subscribers) != 0 {
+		t.Fatalf("Stats() = %+v, want 3 action events and no subscribers left", st)
+	}
+}
+
+func TestAuditPlugin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "events.jsonl")
+	bus := NewBus()
+	plugins := NewPlugins(bus)
+
+	if loaded := plugins.Load(&models.Config{}); len(loaded) != 0 {
+		t.Fatalf("Load() without audit_log = %v, want none", loaded)
+	}
+	loaded := plugins.Load(&models.Config{Events: models.EventsConfig{AuditLog: path}})
+	if len(loaded) != 1 || loaded[0] != "audit" {
+		t.Fatalf("Load() = %v, want [audit]", loaded)
+	}
+
+	bus.Publish(Event{Type: SampleCollected, VMID: 100})
+	bus.Publish(Event{Type: ActionExecuted, VMID: 100, Rule: "monthly", Action: &models.ActionLog{VMID: 100, Action: models.ActionShutdown, Success: true}})
+	bus.Publish(Event{Type: ConfigReloaded})
+	plugins.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var types []Type
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("audit line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, ev.Type)
+	}
+	if len(types) != 2 || types[0] != ActionExecuted || types[1] != ConfigReloaded {
+		t.Fatalf("audit log types = %v, want [action_executed config_reloaded]", types)
+	}
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// Subscriber 插件式订阅者（通知、Webhook、审计日志等）
+// 实现 io.Closer 时在取消订阅（配置重载、退出）后调用 Close
+type Subscriber interface {
+	// Types 订阅的事件类型（为空表示所有类型）
+	Types() []Type
+	// Handle 处理事件（在订阅者自己的协程中调用，可以阻塞）
+	Handle(Event)
+}
+
+// Factory 根据配置创建订阅者，配置中未启用时返回 nil
+type Factory func(cfg *models.Config) (Subscriber, error)
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
+)
+
+// Register 注册插件（在插件的 init 中调用，名称重复时 panic）
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("事件插件 %s 重复注册", name))
+	}
+	registry[name] = factory
+}
+
+// activePlugin 已启用的插件
+type activePlugin struct {
+	name        string
+	subscriber  Subscriber
+	unsubscribe func()
+}
+
+// Plugins 按配置启用的插件，配置重载时整体替换
+type Plugins struct {
+	bus    *Bus
+	mu     sync.Mutex
+	active []activePlugin
+}
+
+// NewPlugins 创建插件集合
+func NewPlugins(bus *Bus) *Plugins {
+	return &Plugins{bus: bus}
+}
+
+// Load 按配置创建所有已注册的插件并订阅，替换之前加载的插件，返回启用的插件名称
+// 创建失败的插件记录日志并跳过，不影响其他插件
+func (p *Plugins) Load(cfg *models.Config) []string {
+	registryMu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	factories := make(map[string]Factory, len(registry))
+	for name, factory := range registry {
+		factories[name] = factory
+	}
+	registryMu.Unlock()
+	sort.Strings(names)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked()
+
+	var loaded []string
+	for _, name := range names {
+		subscriber, err := factories[name](cfg)
+		if err != nil {
+			log.Printf("事件插件 %s 创建失败: %v", name, err)
+			continue
+		}
+		if subscriber == nil {
+			continue
+		}
+		p.active = append(p.active, activePlugin{
+			name:        name,
+			subscriber:  subscriber,
+			unsubscribe: p.bus.Subscribe(name, subscriber.Handle, subscriber.Types()...),
+		})
+		loaded = append(loaded, name)
+	}
+	return loaded
+}
+
+// Close 取消所有插件的订阅（等待已排队的事件处理完）
+func (p *Plugins) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked()
+}
+
+// closeLocked 取消订阅并关闭插件
+func (p *Plugins) closeLocked() {
+	for _, plugin := range p.active {
+		plugin.unsubscribe()
+		if closer, ok := plugin.subscriber.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("关闭事件插件 %s 失败: %v", plugin.name, err)
+			}
+		}
+	}
+	p.active = nil
+}
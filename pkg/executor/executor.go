@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"sync"
+	"time"
+)
+
+// Job 待执行的操作
+type Job struct {
+	Key int    // 串行标识（虚拟机 ID）：同一 Key 的操作按提交顺序逐个执行
+	ID  string // 去重标识：同一 ID 的操作已在队列中或正在执行时不再提交
+	Run func() error
+
+	attempts  int       // 已重试次数
+	notBefore time.Time // 重试前等待到的时间
+}
+
+// Config 执行器配置
+type Config struct {
+	Workers    int              // 同时执行的操作数（至少为 1）
+	MaxRetries int              // 失败后最多重试的次数
+	RetryDelay time.Duration    // 首次重试的等待时间，之后每次翻倍
+	Retryable  func(error) bool // 错误是否值得重试（nil 表示都重试）
+}
+
+// Stats 执行器统计
+type Stats struct {
+	Pending   int    `json:"pending"`   // 等待执行（包括等待重试）的操作数
+	Running   int    `json:"running"`   // 正在执行的操作数
+	Succeeded uint64 `json:"succeeded"` // 累计执行成功的操作数
+	Failed    uint64 `json:"failed"`    // 累计失败（不可重试或重试用尽）的操作数
+	Retried   uint64 `json:"retried"`   // 累计重试次数
+	Dropped   uint64 `json:"dropped"`   // 停止时丢弃的未执行操作数
+}
+
+// Executor 操作执行器：限制并发数，同一虚拟机的操作串行执行，失败的操作按退避间隔重试
+// 操作按提交顺序开始执行；同一 Key 有更早的操作未完成时，后提交的操作不会越过它
+type Executor struct {
+	mu      sync.Mutex
+	config  Config
+	queue   []*Job
+	ids     map[string]bool // 在队列中或正在执行的操作
+	busy    map[int]bool    // 有操作正在执行的 Key
+	running int
+	stats   Stats
+	stopped bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New 创建并启动执行器
+func New(config Config) *Executor {
+	e := &Executor{
+		config: config,
+		ids:    make(map[string]bool),
+		busy:   make(map[int]bool),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.dispatch()
+	return e
+}
+
+// SetConfig 修改配置（配置重载时使用，对之后开始的操作生效）
+func (e *Executor) SetConfig(config Config) {
+	e.mu.Lock()
+	e.config = config
+	e.mu.Unlock()
+	e.signal()
+}
+
+// Submit 提交操作，同一 ID 的操作未完成或执行器已停止时返回 false
+func (e *Executor) Submit(job Job) bool {
+	e.mu.Lock()
+	if e.stopped || e.ids[job.ID] {
+		e.mu.Unlock()
+		return false
+	}
+	e.ids[job.ID] = true
+	e.queue = append(e.queue, &job)
+	e.mu.Unlock()
+
+	e.signal()
+	return true
+}
+
+// Stats 获取统计
+func (e *Executor) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.stats
+	st.Pending = len(e.queue)
+	st.Running = e.running
+	return st
+}
+
+// Stop 停止执行器：等待正在执行的操作结束，丢弃未开始的操作，返回丢弃的数量
+func (e *Executor) Stop() int {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return 0
+	}
+	e.stopped = true
+	e.mu.Unlock()
+
+	close(e.stop)
+	<-e.done
+	e.wg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	dropped := len(e.queue)
+	e.stats.Dropped += uint64(dropped)
+	e.queue = nil
+	return dropped
+}
+
+// signal 唤醒调度
+func (e *Executor) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 调度循环：有空闲并发时按顺序启动可以执行的操作
+func (e *Executor) dispatch() {
+	defer close(e.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		next := e.startReady(time.Now())
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-e.stop:
+			return
+		case <-e.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// startReady 启动所有可以执行的操作，返回最早的重试时间（没有等待重试的操作时为零值）
+func (e *Executor) startReady(now time.Time) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var next time.Time
+	blocked := make(map[int]bool) // 有更早的操作在等待的 Key
+	remaining := e.queue[:0]
+	for _, job := range e.queue {
+		switch {
+		case e.stopped || e.running >= max(e.config.Workers, 1) || e.busy[job.Key] || blocked[job.Key]:
+			remaining = append(remaining, job)
+			blocked[job.Key] = true
+		case now.Before(job.notBefore):
+			remaining = append(remaining, job)
+			blocked[job.Key] = true
+			if next.IsZero() || job.notBefore.Before(next) {
+				next = job.notBefore
+			}
+		default:
+			e.busy[job.Key] = true
+			e.running++
+			e.wg.Add(1)
+			go e.run(job)
+		}
+	}
+	e.queue = remaining
+	return next
+}
+
+// run 执行操作，失败时按配置放回队首等待重试
+func (e *Executor) run(job *Job) {
+	defer e.wg.Done()
+	err := job.Run()
+
+	e.mu.Lock()
+	e.running--
+	delete(e.busy, job.Key)
+	switch {
+	case err == nil:
+		e.stats.Succeeded++
+		delete(e.ids, job.ID)
+	case job.attempts < e.config.MaxRetries && !e.stopped && (e.config.Retryable == nil || e.config.Retryable(err)):
+		job.attempts++
+		job.notBefore = time.Now().Add(e.config.RetryDelay << (job.attempts - 1))
+		e.stats.Retried++
+		// 放在队首：它比队列中同一 Key 的其他操作都早提交
+		e.queue = append([]*Job{job}, e.queue...)
+	default:
+		e.stats.Failed++
+		delete(e.ids, job.ID)
+	}
+	e.mu.Unlock()
+
+	e.signal()
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecutorConcurrencyAndSerialization(t *testing.T) {
+	e := New(Config{Workers: 2})
+	defer e.Stop()
+
+	var mu sync.Mutex
+	var running, peak int
+	order := make(map[int][]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		key, seq := i%3, i
+		wg.Add(1)
+		ok := e.Submit(Job{Key: key, ID: fmt.Sprint(i), Run: func() error {
+			defer wg.Done()
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			order[key] = append(order[key], seq)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}})
+		if !ok {
+			t.Fatalf("Submit(%d) = false, want true", i)
+		}
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", peak)
+	}
+	for key, seqs := range order {
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] < seqs[i-1] {
+				t.Fatalf("key %d ran out of order: %v", key, seqs)
+			}
+		}
+	}
+}
+
+func TestExecutorRetryAndDedup(t *testing.T) {
+	permanent := errors.New("permanent")
+	e := New(Config{
+		Workers:    1,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+		Retryable:  func(err error) bool { return !errors.Is(err, permanent) },
+	})
+
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	e.Submit(Job{Key: 1, ID: "flaky", Run: func() error {
+		if attempts.Add(1) < 3 {
+			return errors.New("locked")
+		}
+		close(done)
+		return nil
+	}})
+	// 同一操作未完成时不重复提交
+	if e.Submit(Job{Key: 1, ID: "flaky", Run: func() error { return nil }}) {
+		t.Fatal("Submit() of a pending ID = true, want false")
+	}
+	<-done
+
+	failed := make(chan struct{})
+	var permanentRuns atomic.Int32
+	e.Submit(Job{Key: 2, ID: "denied", Run: func() error {
+		if permanentRuns.Add(1) == 1 {
+			close(failed)
+		}
+		return permanent
+	}})
+	<-failed
+
+	if dropped := e.Stop(); dropped != 0 {
+		t.Fatalf("Stop() dropped %d jobs, want 0", dropped)
+	}
+	st := e.Stats()
+	if attempts.Load() != 3 || permanentRuns.Load() != 1 || st.Succeeded != 1 || st.Failed != 1 || st.Retried != 2 {
+		t.Fatalf("attempts=%d permanentRuns=%d stats=%+v, want 3 attempts, 1 permanent run, 1 succeeded, 1 failed, 2 retries",
+			attempts.Load(), permanentRuns.Load(), st)
+	}
+	if e.Submit(Job{Key: 3, ID: "late", Run: func() error { return nil }}) {
+		t.Fatal("Submit() after Stop() = true, want false")
+	}
+}
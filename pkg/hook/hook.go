@@ -0,0 +1,158 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ErrTimeout 命令在超时时间内没有结束（进程组已被结束）
+var ErrTimeout = errors.New("外部命令超时")
+
+// ErrExit 命令无法启动或退出码不为 0
+var ErrExit = errors.New("外部命令执行失败")
+
+// waitDelay 结束进程后等待输出管道关闭的时间（后台子进程可能继续持有管道）
+const waitDelay = 2 * time.Second
+
+// Phase 命令的执行阶段
+const (
+	PhaseAction     = "action"      // exec 操作
+	PhasePostAction = "post_action" // 操作后钩子
+)
+
+// Usage 触发操作时的用量
+type Usage struct {
+	Used  float64 // 当前用量
+	Limit float64 // 规则限制
+	Unit  string  // GB（流量规则）或 Mbps（带宽规则）
+}
+
+// Request 执行命令的上下文，转换为命令的环境变量
+type Request struct {
+	Phase  string
+	VM     models.VMInfo
+	Node   string
+	Rule   models.Rule
+	Reason string
+	Usage  Usage
+	Result *models.ActionLog // 操作后钩子：操作的执行结果
+}
+
+// Env 命令的环境变量（追加在监控程序自身的环境变量之后）
+//
+//	PVE_TM_PHASE      action 或 post_action
+//	PVE_TM_VMID       虚拟机 ID
+//	PVE_TM_VM_NAME    虚拟机名称
+//	PVE_TM_NODE       虚拟机所在节点
+//	PVE_TM_RULE       规则名称
+//	PVE_TM_RULE_TYPE  规则类型（volume/rate/percentile）
+//	PVE_TM_PERIOD     规则周期
+//	PVE_TM_ACTION     规则操作
+//	PVE_TM_REASON     触发原因
+//	PVE_TM_USAGE      当前用量
+//	PVE_TM_LIMIT      规则限制
+//	PVE_TM_UNIT       用量单位（GB 或 Mbps）
+//	PVE_TM_SUCCESS    操作是否成功（仅操作后钩子，true/false）
+//	PVE_TM_ERROR      操作失败的原因（仅操作后钩子）
+func (r Request) Env() map[string]string {
+	ruleType := r.Rule.Type
+	if ruleType == "" {
+		ruleType = models.RuleTypeVolume
+	}
+	env := map[string]string{
+		"PVE_TM_PHASE":     r.Phase,
+		"PVE_TM_VMID":      strconv.Itoa(r.VM.VMID),
+		"PVE_TM_VM_NAME":   r.VM.Name,
+		"PVE_TM_NODE":      r.Node,
+		"PVE_TM_RULE":      r.Rule.Name,
+		"PVE_TM_RULE_TYPE": ruleType,
+		"PVE_TM_PERIOD":    r.Rule.Period,
+		"PVE_TM_ACTION":    r.Rule.Action,
+		"PVE_TM_REASON":    r.Reason,
+		"PVE_TM_USAGE":     strconv.FormatFloat(r.Usage.Used, 'f', 2, 64),
+		"PVE_TM_LIMIT":     strconv.FormatFloat(r.Usage.Limit, 'f', 2, 64),
+		"PVE_TM_UNIT":      r.Usage.Unit,
+	}
+	if r.Result != nil {
+		env["PVE_TM_SUCCESS"] = strconv.FormatBool(r.Result.Success)
+		env["PVE_TM_ERROR"] = r.Result.Error
+	}
+	return env
+}
+
+// Run 执行命令（不经过 shell），返回合并的 stdout 和 stderr（超过 MaxExecOutputBytes 截断）
+// 超时后结束整个进程组，返回 ErrTimeout；退出码不为 0 时返回包装 ErrExit 的错误
+func Run(cfg models.ExecConfig, env map[string]string) (string, error) {
+	if len(cfg.Command) == 0 {
+		return "", fmt.Errorf("%w: 未指定命令", ErrExit)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cfg.Command[0], cfg.Command[1:]...)
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+
+	// 命令在独立的进程组中运行，超时时连同它启动的子进程一起结束
+	killProcessGroupOnCancel(cmd)
+	cmd.WaitDelay = waitDelay
+
+	output := &limitedBuffer{limit: models.MaxExecOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return output.String(), fmt.Errorf("%w（%v）: %s", ErrTimeout, cfg.Timeout(), cfg.Command[0])
+	case err != nil:
+		return output.String(), fmt.Errorf("%w: %s: %v", ErrExit, cfg.Command[0], err)
+	}
+	return output.String(), nil
+}
+
+// limitedBuffer 只保留前 limit 字节的输出，之后的输出丢弃并在末尾注明
+type limitedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+// Write 写入输出（总是返回写入成功，避免命令因管道错误退出）
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) > room {
+			b.buf = append(b.buf, p[:room]...)
+			b.truncated = true
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String 获取保留的输出
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return string(b.buf) + "\n...(输出已截断)"
+	}
+	return string(b.buf)
+}
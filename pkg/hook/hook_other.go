@@ -0,0 +1,8 @@
+//go:build !unix
+
+package hook
+
+import "os/exec"
+
+// killProcessGroupOnCancel 其他平台没有进程组，超时时只结束命令本身（exec.CommandContext 的默认行为）
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
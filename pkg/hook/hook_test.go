@@ -0,0 +1,44 @@
+package hook
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRun(t *testing.T) {
+	env := Request{
+		Phase:  PhaseAction,
+		VM:     models.VMInfo{VMID: 101, Name: "web"},
+		Rule:   models.Rule{Name: "monthly", Action: models.ActionExec},
+		Reason: "超出流量限制",
+		Usage:  Usage{Used: 120.5, Limit: 100, Unit: "GB"},
+	}.Env()
+
+	output, err := Run(models.ExecConfig{Command: []string{"sh", "-c", `echo "$PVE_TM_VMID $PVE_TM_RULE $PVE_TM_USAGE/$PVE_TM_LIMIT$PVE_TM_UNIT"; echo err >&2`}}, env)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if output != "101 monthly 120.50/100.00GB\nerr\n" {
+		t.Fatalf("Run() output = %q", output)
+	}
+
+	if _, err := Run(models.ExecConfig{Command: []string{"sh", "-c", "exit 3"}}, nil); !errors.Is(err, ErrExit) {
+		t.Fatalf("Run() error = %v, want ErrExit", err)
+	}
+
+	// 超时时结束整个进程组，后台子进程不会让 Run 一直等待输出管道
+	if _, err := Run(models.ExecConfig{Command: []string{"sh", "-c", "sleep 30 & sleep 30"}, TimeoutSeconds: 1}, nil); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Run() error = %v, want ErrTimeout", err)
+	}
+
+	output, err = Run(models.ExecConfig{Command: []string{"sh", "-c", "head -c 10000 /dev/zero | tr '\\0' a"}}, nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.HasPrefix(output, strings.Repeat("a", models.MaxExecOutputBytes)+"\n...") {
+		t.Fatalf("Run() output length = %d, want truncated to %d", len(output), models.MaxExecOutputBytes)
+	}
+}
@@ -0,0 +1,366 @@
+package i18n
+
+// enUS English messages
+var enUS = Bundle{
+	// CLI
+	"cli.load_config_failed":        "Failed to load config: %v",
+	"cli.create_monitor_failed":     "Failed to create monitor: %v",
+	"cli.export_failed":             "Export failed: %v",
+	"cli.cleanup_failed":            "Cleanup failed: %v",
+	"cli.start_failed":              "Failed to start monitor: %v",
+	"cli.starting":                  "Starting PVE traffic monitor...",
+	"cli.invalid_vmid":              "Invalid VM ID: %s",
+	"cli.invalid_format":            "Invalid export format: %s (supported: json/png/html)",
+	"cli.export_dest_unknown":       "Unknown export destination: %s (configure it in monitor.export_destinations, or use local)",
+	"cli.digest_failed":             "Failed to generate digest: %v",
+	"cli.digest_no_channel":         "No notification channel subscribes to digest (add digest to a channel's events); use -dry-run to print the digest only",
+	"cli.digest_sent":               "Digest sent to: %v",
+	"cli.invalid_period":            "Invalid aggregation period: %s (supported: minute/hour/day/month or a step such as 15m/6h/1d/auto)",
+	"cli.invalid_direction":         "Invalid traffic direction: %s (supported: both/rx/tx)",
+	"cli.invalid_time":              "Invalid time format: %s (supported: 2006-01-02 or 2006-01-02T15:04:05)",
+	"cli.invalid_cleanup_type":      "Invalid cleanup type: %s (supported: range/vm/before/logs/states/compact)",
+	"cli.parse_start_failed":        "Failed to parse start time",
+	"cli.parse_end_failed":          "Failed to parse end time",
+	"cli.parse_date_failed":         "Failed to parse date (expected format: 2006-01-02)",
+	"cli.start_after_end":           "Start time must not be later than end time",
+	"cli.custom_range":              "Using custom time range, granularity: %s",
+	"cli.get_records_failed":        "Failed to get traffic records",
+	"cli.no_records":                "No traffic records found (range: %s - %s)",
+	"cli.get_vm_failed":             "Failed to get VM info",
+	"cli.list_vms_failed":           "Failed to list VMs",
+	"cli.export_json_failed":        "Failed to export JSON",
+	"cli.export_png_failed":         "Failed to export chart image",
+	"cli.export_html_failed":        "Failed to export HTML chart",
+	"cli.exported":                  "Exported (%s): %s",
+	"cli.time_range":                "Time range: %s - %s",
+	"cli.granularity":               "Granularity: %s",
+	"cli.raw_points":                "Raw data points: %d",
+	"cli.vm_stats_failed":           "Failed to calculate stats for VM %d: %v",
+	"cli.no_stats":                  "No statistics available",
+	"cli.summary_exported":          "Summary exported (%s): %s",
+	"cli.host_exported":             "Host traffic trend exported (%s): %s (%d VMs)",
+	"cli.summary_period":            "Period: %s, direction: %s, VMs: %d",
+	"cli.summary_range":             "Time range: %s - %s, direction: %s, VMs: %d",
+	"cli.notify_failed":             "Failed to notify main process (it may not be running): %v",
+	"cli.notified":                  "Notified main process: %s",
+	"cli.cleanup_range_requires":    "Range cleanup requires -start and -end",
+	"cli.cleanup_range_prepare":     "Preparing to delete data from %s to %s",
+	"cli.cleanup_vm_requires_vmid":  "VM cleanup requires -vmid",
+	"cli.cleanup_vm_requires_range": "VM cleanup requires -date or (-start and -end)",
+	"cli.cleanup_vm_prepare":        "Preparing to delete data of VM%d from %s to %s",
+	"cli.cleanup_before_requires":   "History cleanup requires -before",
+	"cli.cleanup_before_prepare":    "Preparing to delete all data before %s",
+	"cli.count_failed":              "Failed to count records",
+	"cli.delete_failed":             "Failed to delete records",
+	"cli.dry_run_delete":            "[DRY RUN] Would delete %d records",
+	"cli.dry_run_delete_vm":         "[DRY RUN] Would delete %[2]d records of VM%[1]d",
+	"cli.deleted":                   "Deleted %d records",
+	"cli.deleted_vm":                "Deleted %[2]d records of VM%[1]d",
+	"cli.status_failed":             "Failed to query status: %v",
+	"cli.shutdown_failed":           "Shutdown failed: %v",
+	"cli.shutdown_no_daemon":        "Cannot reach the running main program: %v",
+	"cli.shutdown_recover":          "Main program is exiting after recovering %d restricted VMs",
+	"cli.shutdown_keep":             "Main program is exiting; restrictions on %d VMs are kept and resume after restart",
+	"cli.maintenance_failed":        "Maintenance command failed: %v",
+	"cli.maintenance_usage":         "Usage: maintenance on [-reason text] | off | status",
+	"cli.maintenance_save":          "Failed to save maintenance mode: %v",
+	"cli.maintenance_offline":       "Main program is not running; maintenance state was read from or written to storage directly (applies on start)",
+	"cli.maintenance_on":            "Maintenance mode is on (since %s, reason: %s); rule enforcement and automatic recovery are paused",
+	"cli.maintenance_off":           "Maintenance mode is off",
+	"cli.status_running":            "Monitor is running: PID %v, host %v, started %v (up %v), config %v",
+	"cli.status_detail":             "Storage %v, node %v, interval %vs, %v rules, %v recovery records",
+	"cli.status_maintenance":        "Maintenance mode is on; rule enforcement and automatic recovery are paused",
+	"cli.status_not_running":        "No monitor is running (PID file: %s)",
+	"cli.status_no_ipc":             "Monitor is running: PID %d, host %s, started %s, but the IPC query failed: %v",
+	"cli.cleanup_logs_requires":     "Action log cleanup requires -before, -date or (-start and -end)",
+	"cli.cleanup_logs_prepare":      "Preparing to delete action logs from %s to %s (%s)",
+	"cli.cleanup_states_requires":   "VM state cleanup requires -vmid or -before",
+	"cli.cleanup_states_remote":     "Aggregator mode needs the running main program to list VMs on agent nodes: %v",
+	"cli.cleanup_states_prepare":    "Preparing to delete states of removed VMs not updated since %s (%s)",
+	"cli.cleanup_vm_state":          "Preparing to delete the state of VM%d (a pending restriction will no longer be recovered automatically)",
+	"cli.cleanup_unsupported":       "This storage does not support deleting action logs and VM states",
+	"cli.all_vms":                   "all VMs",
+	"cli.dry_run_delete_logs":       "[DRY RUN] Would delete %d action logs",
+	"cli.deleted_logs":              "Deleted %d action logs",
+	"cli.dry_run_delete_states":     "[DRY RUN] Would delete %d VM states",
+	"cli.deleted_states":            "Deleted %d VM states",
+	"cli.compact_start":             "Compacting storage...",
+	"cli.compact_progress":          "Compaction %d/%d: %s",
+	"cli.compact_size":              "Storage size: %.1f MB -> %.1f MB",
+	"cli.compact_done":              "Compaction finished, removed %d empty files and %d empty directories",
+	"cli.compact_dry_run":           "[DRY RUN] Would compact storage",
+	"cli.compact_unsupported":       "This storage does not support compaction, skipped",
+	"cli.compact_failed":            "Failed to compact storage",
+	"cli.export_tenants_failed":     "Failed to export tenant summary",
+	"cli.tenants_exported":          "Tenant summary exported (%s): %s",
+	"cli.no_tenants":                "No tenants configured (set tenants.tag_prefix or tenants.mapping)",
+	"cli.public_link_failed":        "Failed to create public link: %v",
+	"cli.public_link_expires":       "Link expires at: %s",
+	"cli.public_link_never":         "Link never expires (rotate api.public_secret to revoke all links)",
+	"cli.import_failed":             "Import failed: %v",
+	"cli.import_requires_vmid":      "Import requires -vmid",
+	"cli.import_read_failed":        "Failed to read import file",
+	"cli.import_parse_failed":       "Failed to parse import file",
+	"cli.import_empty":              "No traffic records in import file",
+	"cli.import_prepare":            "Importing %d records (format: %s) into VM%d: %s to %s",
+	"cli.import_overlap":            "VM%d already has %d records in this time range, clear them with -cleanup vm first",
+	"cli.import_rebase_failed":      "Cannot join imported records to existing records",
+	"cli.import_rebased":            "Imported counters rebased onto the existing record at %s",
+	"cli.import_dry_run":            "[DRY RUN] Would import %d records",
+	"cli.import_save_failed":        "Failed to save record %d",
+	"cli.import_progress":           "Imported %d/%d records",
+	"cli.imported":                  "Imported %d records into VM%d",
+	"cli.recompute_failed":          "Recompute failed: %v",
+	"cli.recompute_requires_range":  "Recompute requires -start and -end",
+	"cli.recompute_prepare":         "Recomputing data of %d VMs: %s to %s",
+	"cli.recompute_vm":              "[%d/%d] VM%d: %d records, download %.2f GB, upload %.2f GB",
+	"cli.recompute_vm_failed":       "[%d/%d] VM%d failed: %v",
+	"cli.recompute_vm_anomalies":    "VM%d anomalies: %d counter resets, %d out-of-order and %d duplicate timestamps",
+	"cli.recompute_summary":         "%d VMs, %d records, %.2f GB in total",
+	"cli.recompute_dry_run":         "[DRY RUN] Would rebuild the record counter and tell the running monitor to drop its stats cache",
+	"cli.recompute_counter":         "Record counter rebuilt: %d records (took %v)",
+	"cli.recompute_counter_failed":  "Failed to rebuild record counter",
+	"cli.simulate_failed":           "Rule simulation failed: %v",
+	"cli.simulate_read_rule_failed": "Failed to read rule file",
+	"cli.simulate_invalid_rule":     "Invalid rule",
+	"cli.simulate_range":            "Simulating rule %s, time range: %s - %s",
+	"cli.simulate_none":             "No VM would trigger this rule in the time range",
+	"cli.simulate_header":           "VMID\tNAME\tACTION\tTRIGGERED\tRECOVERY\tUSAGE/LIMIT",
+	"cli.simulate_summary":          "%d matching VMs, %d triggers in total",
+	"cli.bench_failed":              "Benchmark failed: %v",
+	"cli.fsck_failed":               "Storage check failed: %v",
+	"cli.fsck_unsupported":          "%s storage does not support integrity checks",
+	"cli.fsck_summary":              "Recounted %d records (%d readable), checked %d traffic files",
+	"cli.fsck_counter":              "Records in the cached counter: %d",
+	"cli.fsck_issue":                "Issue %s: %s (%d)",
+	"cli.fsck_issue_repaired":       "Repaired %s: %s (%d)",
+	"cli.fsck_rebuilt":              "Record counter rebuilt: %d records",
+	"cli.fsck_clean":                "No issues found",
+	"cli.fsck_repaired":             "Repaired %d issues",
+	"cli.fsck_issues":               "Found %d issues; run with -repair to fix them",
+	"cli.fsck_unrepairable":         "%d issues cannot be repaired automatically and need manual attention",
+	"cli.archive_failed":            "Archive command failed: %v",
+	"cli.archive_usage":             "Usage: archive run [-month 2006-01] [-dry-run] | list | restore -month 2006-01 [-vmid ID] [-dry-run]",
+	"cli.archive_disabled":          "Archiving is not configured (archive.endpoint)",
+	"cli.archive_month_open":        "%s has not ended yet; only closed months can be archived",
+	"cli.archive_none":              "No months to archive",
+	"cli.archive_dry_run":           "[dry run] %s: %d local records would be uploaded to %s and removed locally",
+	"cli.archived":                  "Archived %s: %d VMs, %d records (%d bytes) -> %s, %d removed locally",
+	"cli.archive_kept":              "These VMs received new records during archiving; their local records are kept until the next run: %v",
+	"cli.archive_item":              "%s\t%d bytes\t%s",
+	"cli.archive_count":             "%d archives",
+	"cli.archive_restore_requires":  "restore requires -month (format: 2006-01)",
+	"cli.archive_restore_skipped":   "These VMs already have local records for the month and were not restored (clean them up first): %v",
+	"cli.archive_restore_dry_run":   "[dry run] Would restore %d records (%d VMs, %s)",
+	"cli.archive_restored":          "Restored %d records (%d VMs, %s)",
+	"cli.bench_invalid":             "Invalid argument, allowed range: %s",
+	"cli.bench_exists":              "VM%d already has traffic records; the benchmark uses VM%d-VM%d, delete their data first",
+	"cli.bench_start":               "Benchmark: %d VMs x %d days, one record every %s, %d records in total (storage: %s, %d workers)",
+	"cli.bench_header":              "PHASE\tOPS\tRECORDS\tSECONDS\tOPS/S\tRECORDS/S\tERRORS\t",
+	"cli.bench_phase_write":         "write",
+	"cli.bench_phase_read_day":      "read last day",
+	"cli.bench_phase_read_all":      "read all",
+	"cli.bench_phase_aggregate":     "period stats",
+	"cli.bench_phase_delete":        "delete",
+	"cli.bench_error":               "%s phase had %d errors, first: %s",
+	"cli.bench_kept":                "Generated data kept (VM%d-VM%d); remove it with -cleanup vm -vmid",
+	"cli.ctime_failed":              "Creation time command failed: %v",
+	"cli.ctime_requires_vmid":       "Creation time command requires -vmid",
+	"cli.ctime_show":                "VM%d creation time: %s (source: %s)",
+	"cli.ctime_set":                 "VM%d creation time set to %s",
+	"cli.ctime_cleared":             "VM%d creation time override cleared",
+	"cli.config_failed":             "Config command failed: %v",
+	"cli.config_usage":              "usage: config validate | config show [-effective]",
+	"cli.config_files":              "Config files: %s",
+	"cli.config_error":              "ERROR: %s",
+	"cli.config_warning":            "WARNING: %s",
+	"cli.config_invalid":            "config has %d error(s)",
+	"cli.config_valid":              "Config is valid (%d rules, %d warnings)",
+
+	// API
+	"api.unauthorized":               "Unauthorized: invalid or missing token",
+	"api.invalid_vmid":               "Invalid VM ID",
+	"api.invalid_param":              "Invalid %s: %s",
+	"api.history_too_many_points":    "More than %d data points in the time range; narrow the range, use a coarser granularity or the step parameter",
+	"api.invalid_order":              "Invalid order: %s (asc/desc)",
+	"api.invalid_sort":               "Invalid sort field: %s",
+	"api.invalid_cursor":             "Invalid cursor",
+	"api.invalid_success":            "Invalid success: %s (true/false)",
+	"api.invalid_start":              "Invalid start time format, use RFC3339",
+	"api.invalid_end":                "Invalid end time format, use RFC3339",
+	"api.invalid_period":             "Invalid period: %s",
+	"api.list_vms_failed":            "Failed to list VMs: %v",
+	"api.get_vm_failed":              "Failed to get VM info: %v",
+	"api.enforcement_failed":         "Failed to read VM enforcement status: %v",
+	"api.get_logs_failed":            "Failed to get action logs: %v",
+	"api.get_records_failed":         "Failed to get traffic records: %v",
+	"api.chart_no_records":           "VM %d has no traffic records in this range",
+	"api.chart_render_failed":        "Failed to render chart: %v",
+	"api.vm_not_found":               "VM %d not found",
+	"api.network_not_found":          "Network %s not found",
+	"api.node_forbidden":             "Tenant keys cannot access node traffic",
+	"api.metrics_forbidden":          "Tenant keys cannot access metrics",
+	"api.public_disabled":            "Public status pages are disabled (api.public_secret is not set)",
+	"api.public_link_invalid":        "Link is invalid or has expired",
+	"api.rate_limited":               "Too many requests, please retry later",
+	"api.body_too_large":             "Request body too large (limit %d bytes)",
+	"api.method_not_allowed":         "Method %s not allowed",
+	"api.invalid_body":               "Invalid request body: %v",
+	"api.agent_push_failed":          "Rejected agent push: %v",
+	"api.ingest_disabled":            "Ingestion requires api.token to be configured",
+	"api.ingest_forbidden":           "Tenant keys cannot write traffic records",
+	"api.ingest_invalid":             "Invalid records: %v",
+	"api.ingest_failed":              "Failed to save traffic records: %v",
+	"api.maintenance_forbidden":      "Tenant keys cannot access maintenance mode",
+	"notify.title":                   "PVE Traffic Monitor",
+	"notify.limit_warning":           "VM%d rule %s reached %.0f%% of its limit: %.2f / %.2f GB",
+	"notify.action_ok":               "VM%d: rule %s executed action %s\nReason: %s",
+	"notify.action_failed":           "VM%d: rule %s failed to execute action %s: %s\nReason: %s",
+	"notify.recovery_done":           "VM%d recovered (rule %s, action: %s)",
+	"notify.config_reloaded":         "Configuration reloaded",
+	"notify.alert_firing":            "VM%d exceeded the limit of rule %s: %s",
+	"notify.alert_repeat":            "VM%d still exceeds the limit of rule %s (for %s): %s",
+	"notify.alert_escalated":         "[Escalated] VM%d has exceeded the limit of rule %s for %s: %s",
+	"notify.alert_resolved":          "VM%d alert for rule %s resolved (lasted %s)",
+	"notify.collection_gap":          "VM%d has not been sampled for %d collection intervals (last sample: %s); traffic during the gap is missing from period totals",
+	"notify.ratio_anomaly":           "VM%d has an anomalous upload/download ratio: %d-minute average upload %.2f Mbps, download %.2f Mbps (%.1f:1, threshold %.1f:1); it may be compromised and used for exfiltration or seeding",
+	"notify.external_change":         "VM%d was changed externally while limited by rule %s (%s): power state %s -> %s; recovery will not change its power state",
+	"notify.collection_gap_error":    "Last error: %s",
+	"digest.title":                   "PVE Traffic Monitor digest (%s ~ %s)",
+	"digest.none":                    "None",
+	"digest.top":                     "Top %d consumers",
+	"digest.consumer":                "%d. %s: %.2f GB (download %.2f GB / upload %.2f GB)",
+	"digest.quotas":                  "VMs over %.0f%% of quota",
+	"digest.quota":                   "%s rule %s: %.1f%% (%.2f / %.2f GB)",
+	"digest.exceeded":                "[exceeded]",
+	"digest.actions":                 "Actions executed (%d)",
+	"digest.action":                  "%s %s rule %s: %s %s",
+	"digest.action_ok":               "succeeded",
+	"digest.action_failed":           "failed",
+	"digest.anomalies":               "Anomalies (%d)",
+	"digest.anomaly.collection_gap":  "no traffic samples for %d collection intervals",
+	"digest.anomaly.external_change": "rule %s (%s): power state changed externally (%s -> %s)",
+	"digest.anomaly.ratio_anomaly":   "anomalous upload/download ratio (%.1f:1)",
+	"digest.anomaly.action_failed":   "rule %s: action %s failed",
+	"digest.more":                    "... and %d more",
+	"digest.footer":                  "Generated by PVE Traffic Monitor",
+	"api.maintenance_disabled":       "Changing maintenance mode requires api.token to be configured",
+	"api.maintenance_invalid":        "Invalid request body: the enabled field is required",
+	"api.maintenance_failed":         "Failed to save maintenance mode: %v",
+	"api.recoveries_failed":          "Failed to read or change the recovery schedule: %v",
+	"api.recoveries_forbidden":       "Tenant keys cannot change the recovery schedule",
+	"api.recoveries_disabled":        "Changing the recovery schedule requires api.token to be configured",
+	"api.recoveries_invalid":         "Invalid request body: vmid and op are required (postpone needs an until in the future, or use cancel)",
+	"api.recovery_not_found":         "VM %d has no pending recovery",
+
+	// Built-in dashboard
+	"ui.lang.switch":             "中文",
+	"ui.nav.overview":            "Overview",
+	"ui.nav.charts":              "Charts",
+	"ui.nav.rules":               "Rules",
+	"ui.nav.actions":             "Actions",
+	"ui.nav.token":               "Token",
+	"ui.theme.dark":              "Dark",
+	"ui.theme.light":             "Light",
+	"ui.theme.toggle":            "Toggle theme",
+	"ui.token.prompt":            "Enter API Token (leave empty for no auth):",
+	"ui.common.refresh":          "Refresh",
+	"ui.common.loading":          "Loading...",
+	"ui.common.update":           "Update",
+	"ui.common.close":            "Close",
+	"ui.common.details":          "Details",
+	"ui.common.all":              "All",
+	"ui.common.load_failed":      "Failed to load data",
+	"ui.stats.total_vms":         "Total VMs",
+	"ui.stats.running":           "Running",
+	"ui.stats.today_traffic":     "Today's Traffic",
+	"ui.stats.total_samples":     "Total Samples",
+	"ui.stats.api_avg":           "API Avg Response",
+	"ui.vms.title":               "Virtual Machines",
+	"ui.vm.details":              "VM Details",
+	"ui.vm.share":                "Share",
+	"ui.vm.share_prompt":         "Read-only status page link:",
+	"ui.public.quotas":           "Quota Usage",
+	"ui.public.no_quotas":        "No quotas configured",
+	"ui.public.expires":          "Link expires at %s",
+	"ui.col.id":                  "ID",
+	"ui.col.name":                "Name",
+	"ui.col.status":              "Status",
+	"ui.col.matched_rules":       "Matched Rules",
+	"ui.col.download":            "Download",
+	"ui.col.upload":              "Upload",
+	"ui.col.total":               "Total",
+	"ui.col.action":              "Action",
+	"ui.col.period":              "Period",
+	"ui.col.time":                "Time",
+	"ui.col.vm":                  "VM",
+	"ui.col.rule":                "Rule",
+	"ui.col.reason":              "Reason",
+	"ui.col.result":              "Result",
+	"ui.col.utilization":         "Utilization",
+	"ui.status.running":          "Running",
+	"ui.status.stopped":          "Stopped",
+	"ui.label.period":            "Period:",
+	"ui.label.direction":         "Direction:",
+	"ui.period.current_minute":   "Current Minute",
+	"ui.period.current_hour":     "Current Hour",
+	"ui.period.today":            "Today",
+	"ui.period.current_month":    "Current Month",
+	"ui.period.minute_last_hour": "Minute (Last Hour)",
+	"ui.period.minute":           "Minute",
+	"ui.period.hour":             "Hour",
+	"ui.period.day":              "Day",
+	"ui.period.month":            "Month",
+	"ui.direction.both":          "Both",
+	"ui.direction.download":      "Download",
+	"ui.direction.upload":        "Upload",
+	"ui.direction.rx":            "Download",
+	"ui.direction.tx":            "Upload",
+	"ui.charts.update":           "Update Chart",
+	"ui.charts.overview":         "Traffic Overview",
+	"ui.charts.top_vms":          "Top 10 VMs by Traffic",
+	"ui.charts.all_vms":          "All VMs Traffic",
+	"ui.charts.traffic_gb":       "Traffic (GB)",
+	"ui.charts.download_gb":      "Download (GB)",
+	"ui.charts.upload_gb":        "Upload (GB)",
+	"ui.charts.total_gb":         "Total (GB)",
+	"ui.charts.total_traffic_gb": "Total Traffic (GB)",
+	"ui.charts.total_traffic":    "Total Traffic",
+	"ui.charts.no_data":          "No data available",
+	"ui.charts.usage_pattern":    "Traffic Usage Pattern",
+	"ui.charts.time":             "Time",
+	"ui.charts.usage_mb":         "Traffic Usage (MB)",
+	"ui.charts.usage_gb":         "Traffic Usage (GB)",
+	"ui.rules.none":              "No rules configured",
+	"ui.rules.no_vms":            "No matched VMs",
+	"ui.rules.load_failed":       "Failed to load rules",
+	"ui.rules.type_rate":         "Rate",
+	"ui.rules.type_volume":       "Volume",
+	"ui.rules.vms":               "%d VMs",
+	"ui.rules.disabled":          "Disabled",
+	"ui.rules.exceeded":          "%d exceeded",
+	"ui.rules.avg":               "%s min avg",
+	"ui.actions.range":           "Range:",
+	"ui.actions.last_24h":        "Last 24 Hours",
+	"ui.actions.last_7d":         "Last 7 Days",
+	"ui.actions.last_30d":        "Last 30 Days",
+	"ui.actions.last_90d":        "Last 90 Days",
+	"ui.actions.vmid":            "VM ID:",
+	"ui.actions.rule":            "Rule:",
+	"ui.actions.action":          "Action:",
+	"ui.actions.result":          "Result:",
+	"ui.actions.apply":           "Apply",
+	"ui.actions.total":           "Total Actions",
+	"ui.actions.succeeded":       "Succeeded",
+	"ui.actions.failed":          "Failed",
+	"ui.actions.success":         "Success",
+	"ui.actions.top_rule":        "Most Triggered Rule",
+	"ui.actions.history":         "Action History",
+	"ui.actions.none":            "No actions in the selected range",
+	"ui.actions.load_failed":     "Failed to load actions",
+	"ui.action.shutdown":         "Shutdown",
+	"ui.action.stop":             "Stop",
+	"ui.action.disconnect":       "Disconnect",
+	"ui.action.rate_limit":       "Rate Limit",
+	"ui.action.exec":             "Run Command",
+}
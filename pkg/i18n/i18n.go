@@ -0,0 +1,187 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 支持的语言
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEnUS = "en-US"
+
+	// DefaultLocale 未配置语言时使用的默认语言
+	DefaultLocale = LocaleZhCN
+)
+
+// Bundle 单个语言的消息表（key -> fmt 格式字符串）
+type Bundle map[string]string
+
+var bundles = map[string]Bundle{
+	LocaleZhCN: zhCN,
+	LocaleEnUS: enUS,
+}
+
+var (
+	mu      sync.RWMutex
+	current = DefaultLocale
+)
+
+// SetLocale 设置全局语言（用于 CLI 和日志输出），不支持的语言回退到默认语言
+func SetLocale(locale string) {
+	normalized := Normalize(locale)
+	if normalized == "" {
+		normalized = DefaultLocale
+	}
+
+	mu.Lock()
+	current = normalized
+	mu.Unlock()
+}
+
+// Locale 获取当前全局语言
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Supported 获取支持的语言列表
+func Supported() []string {
+	locales := make([]string, 0, len(bundles))
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// T 使用全局语言翻译消息
+func T(key string, args ...interface{}) string {
+	return Tl(Locale(), key, args...)
+}
+
+// Tl 使用指定语言翻译消息
+// 查找顺序：指定语言 -> 默认语言 -> key 本身
+func Tl(locale, key string, args ...interface{}) string {
+	format, ok := bundles[Normalize(locale)][key]
+	if !ok {
+		format, ok = bundles[DefaultLocale][key]
+	}
+	if !ok {
+		format = key
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Messages 获取指定语言中以 prefix 开头的消息（返回的 key 去掉 prefix），缺失的 key 使用默认语言补齐
+func Messages(locale, prefix string) map[string]string {
+	result := make(map[string]string)
+	for _, l := range []string{DefaultLocale, Normalize(locale)} {
+		for key, value := range bundles[l] {
+			if strings.HasPrefix(key, prefix) {
+				result[strings.TrimPrefix(key, prefix)] = value
+			}
+		}
+	}
+	return result
+}
+
+// Normalize 规范化语言标识（如 en、en_US、en-us.UTF-8 -> en-US），不支持时返回空字符串
+func Normalize(locale string) string {
+	locale = strings.TrimSpace(locale)
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if locale == "" {
+		return ""
+	}
+
+	for supported := range bundles {
+		if strings.ToLower(supported) == locale {
+			return supported
+		}
+	}
+
+	// 只匹配语言部分（zh-TW、zh-Hans、en-GB 等）
+	lang := strings.SplitN(locale, "-", 2)[0]
+	switch lang {
+	case "zh":
+		return LocaleZhCN
+	case "en":
+		return LocaleEnUS
+	}
+	return ""
+}
+
+// Negotiate 根据 Accept-Language 请求头选择语言，没有支持的语言时返回 fallback
+func Negotiate(acceptLanguage, fallback string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if locale := Normalize(fields[0]); locale != "" && q > 0 {
+			candidates = append(candidates, candidate{locale: locale, q: q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	if len(candidates) > 0 {
+		return candidates[0].locale
+	}
+	return fallback
+}
+
+// Error 可本地化的错误，Error() 使用全局语言
+type Error struct {
+	Key  string
+	Args []interface{}
+}
+
+// Errorf 创建可本地化的错误
+func Errorf(key string, args ...interface{}) error {
+	return &Error{Key: key, Args: args}
+}
+
+func (e *Error) Error() string {
+	return T(e.Key, e.Args...)
+}
+
+// Localize 使用指定语言生成错误信息
+func (e *Error) Localize(locale string) string {
+	return Tl(locale, e.Key, e.Args...)
+}
+
+// LocalizeError 使用指定语言生成错误信息（非本地化错误原样返回）
+func LocalizeError(err error, locale string) string {
+	var localized *Error
+	if errors.As(err, &localized) {
+		return localized.Localize(locale)
+	}
+	return err.Error()
+}
@@ -0,0 +1,72 @@
+package i18n
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"zh-CN":       LocaleZhCN,
+		"zh_CN.UTF-8": LocaleZhCN,
+		"zh-Hans":     LocaleZhCN,
+		"en":          LocaleEnUS,
+		"en-gb":       LocaleEnUS,
+		"EN_us":       LocaleEnUS,
+		"fr-FR":       "",
+		"":            "",
+	}
+
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"en-US,en;q=0.9,zh-CN;q=0.8", LocaleEnUS},
+		{"fr-FR,zh;q=0.5,en;q=0.3", LocaleZhCN},
+		{"en;q=0.2, zh-CN;q=0.9", LocaleZhCN},
+		{"fr-FR", LocaleEnUS},
+		{"", LocaleEnUS},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.header, LocaleEnUS); got != tt.want {
+			t.Fatalf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBundlesHaveSameKeys(t *testing.T) {
+	for locale, bundle := range bundles {
+		for key := range bundles[DefaultLocale] {
+			if _, ok := bundle[key]; !ok {
+				t.Fatalf("%s missing key %q", locale, key)
+			}
+		}
+		for key := range bundle {
+			if _, ok := bundles[DefaultLocale][key]; !ok {
+				t.Fatalf("%s has key %q not in %s", locale, key, DefaultLocale)
+			}
+		}
+	}
+}
+
+func TestLocalizeError(t *testing.T) {
+	err := Errorf("api.invalid_sort", "foo")
+	if got := LocalizeError(err, LocaleEnUS); got != "Invalid sort field: foo" {
+		t.Fatalf("LocalizeError(en-US) = %q", got)
+	}
+	if got := LocalizeError(err, LocaleZhCN); got != "无效的排序字段: foo" {
+		t.Fatalf("LocalizeError(zh-CN) = %q", got)
+	}
+	if got := Tl(LocaleEnUS, "missing.key"); got != "missing.key" {
+		t.Fatalf("Tl(missing) = %q, want key", got)
+	}
+	if got := Tl(LocaleEnUS, "cli.deleted_vm", 100, 5); got != "Deleted 5 records of VM100" {
+		t.Fatalf("Tl(cli.deleted_vm) = %q", got)
+	}
+}
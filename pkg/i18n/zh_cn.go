@@ -0,0 +1,367 @@
+package i18n
+
+// zhCN 简体中文消息
+// key 前缀: cli.* 命令行输出, api.* API 错误信息, ui.* 内置页面文案
+var zhCN = Bundle{
+	// 命令行
+	"cli.load_config_failed":        "加载配置失败: %v",
+	"cli.create_monitor_failed":     "创建监控器失败: %v",
+	"cli.export_failed":             "导出失败: %v",
+	"cli.cleanup_failed":            "清除数据失败: %v",
+	"cli.start_failed":              "启动监控失败: %v",
+	"cli.starting":                  "启动 PVE 流量监控程序...",
+	"cli.invalid_vmid":              "无效的虚拟机 ID: %s",
+	"cli.invalid_format":            "无效的导出格式: %s (支持: json/png/html)",
+	"cli.export_dest_unknown":       "未知的导出目标: %s (在 monitor.export_destinations 中配置, 或使用 local)",
+	"cli.digest_failed":             "生成摘要失败: %v",
+	"cli.digest_no_channel":         "没有通知通道订阅 digest（在通道的 events 中加上 digest），可以使用 -dry-run 只输出摘要",
+	"cli.digest_sent":               "摘要已发送到: %v",
+	"cli.invalid_period":            "无效的聚合周期: %s (支持: minute/hour/day/month 或步长如 15m/6h/1d/auto)",
+	"cli.invalid_direction":         "无效的流量方向: %s (支持: both/rx/tx)",
+	"cli.invalid_time":              "无效的时间格式: %s (支持格式: 2006-01-02 或 2006-01-02T15:04:05)",
+	"cli.invalid_cleanup_type":      "无效的清除类型: %s (支持: range/vm/before/logs/states/compact)",
+	"cli.parse_start_failed":        "解析开始时间失败",
+	"cli.parse_end_failed":          "解析结束时间失败",
+	"cli.parse_date_failed":         "解析日期失败 (格式应为: 2006-01-02)",
+	"cli.start_after_end":           "开始时间不能晚于结束时间",
+	"cli.custom_range":              "使用自定义时间范围，聚合粒度: %s",
+	"cli.get_records_failed":        "获取流量记录失败",
+	"cli.no_records":                "没有找到流量记录 (时间范围: %s - %s)",
+	"cli.get_vm_failed":             "获取虚拟机信息失败",
+	"cli.list_vms_failed":           "获取虚拟机列表失败",
+	"cli.export_json_failed":        "导出JSON失败",
+	"cli.export_png_failed":         "导出图片失败",
+	"cli.export_html_failed":        "导出HTML图表失败",
+	"cli.exported":                  "已导出 (%s): %s",
+	"cli.time_range":                "时间范围: %s - %s",
+	"cli.granularity":               "聚合粒度: %s",
+	"cli.raw_points":                "原始数据点数: %d",
+	"cli.vm_stats_failed":           "计算虚拟机 %d 统计失败: %v",
+	"cli.no_stats":                  "没有统计数据",
+	"cli.summary_exported":          "汇总已导出 (%s): %s",
+	"cli.host_exported":             "主机流量趋势已导出 (%s): %s（%d 个虚拟机）",
+	"cli.summary_period":            "统计周期: %s, 流量方向: %s, 虚拟机数量: %d",
+	"cli.summary_range":             "时间范围: %s - %s, 流量方向: %s, 虚拟机数量: %d",
+	"cli.notify_failed":             "通知主程序失败（主程序可能未运行）: %v",
+	"cli.notified":                  "已通知主程序: %s",
+	"cli.cleanup_range_requires":    "清除时间段数据需要指定 -start 和 -end 参数",
+	"cli.cleanup_range_prepare":     "准备清除时间段数据: %s 至 %s",
+	"cli.cleanup_vm_requires_vmid":  "清除VM数据需要指定 -vmid 参数",
+	"cli.cleanup_vm_requires_range": "清除VM数据需要指定 -date 或 (-start 和 -end) 参数",
+	"cli.cleanup_vm_prepare":        "准备清除 VM%d 的数据: %s 至 %s",
+	"cli.cleanup_before_requires":   "清除历史数据需要指定 -before 参数",
+	"cli.cleanup_before_prepare":    "准备清除 %s 之前的所有数据",
+	"cli.count_failed":              "统计记录数失败",
+	"cli.delete_failed":             "删除记录失败",
+	"cli.dry_run_delete":            "[DRY RUN] 将删除 %d 条记录",
+	"cli.dry_run_delete_vm":         "[DRY RUN] 将删除 VM%d 的 %d 条记录",
+	"cli.deleted":                   "成功删除 %d 条记录",
+	"cli.deleted_vm":                "成功删除 VM%d 的 %d 条记录",
+	"cli.status_failed":             "查询运行状态失败: %v",
+	"cli.shutdown_failed":           "退出主程序失败: %v",
+	"cli.shutdown_no_daemon":        "无法连接正在运行的主程序: %v",
+	"cli.shutdown_recover":          "主程序将在恢复 %d 台被限制的虚拟机后退出",
+	"cli.shutdown_keep":             "主程序正在退出，保留 %d 台虚拟机的限制，重启后继续按计划恢复",
+	"cli.maintenance_failed":        "维护模式命令失败: %v",
+	"cli.maintenance_usage":         "用法: maintenance on [-reason 原因] | off | status",
+	"cli.maintenance_save":          "保存维护模式失败: %v",
+	"cli.maintenance_offline":       "主程序未运行，已直接读写存储中的维护模式状态（启动后生效）",
+	"cli.maintenance_on":            "维护模式已启用（自 %s，原因: %s），暂停执行规则和自动恢复",
+	"cli.maintenance_off":           "维护模式未启用",
+	"cli.status_running":            "主程序正在运行: PID %v, 主机 %v, 启动于 %v (已运行 %v), 配置 %v",
+	"cli.status_detail":             "存储 %v, 节点 %v, 采集间隔 %v 秒, 规则 %v 条, 恢复记录 %v 个",
+	"cli.status_maintenance":        "维护模式已启用，暂停执行规则和自动恢复",
+	"cli.status_not_running":        "没有正在运行的主程序 (PID 文件: %s)",
+	"cli.status_no_ipc":             "主程序正在运行: PID %d, 主机 %s, 启动于 %s, 但 IPC 查询失败: %v",
+	"cli.cleanup_logs_requires":     "清除操作日志需要指定 -before、-date 或 (-start 和 -end) 参数",
+	"cli.cleanup_logs_prepare":      "准备清除操作日志: %s 至 %s (%s)",
+	"cli.cleanup_states_requires":   "清除虚拟机状态需要指定 -vmid 或 -before 参数",
+	"cli.cleanup_states_remote":     "汇总模式需要主程序运行才能获取代理节点上的虚拟机: %v",
+	"cli.cleanup_states_prepare":    "准备清除已删除且 %s 之后未更新的虚拟机状态 (%s)",
+	"cli.cleanup_vm_state":          "准备清除 VM%d 的状态（如有待恢复的限制，将不再自动恢复）",
+	"cli.cleanup_unsupported":       "当前存储不支持清除操作日志和虚拟机状态",
+	"cli.all_vms":                   "所有虚拟机",
+	"cli.dry_run_delete_logs":       "[DRY RUN] 将删除 %d 条操作日志",
+	"cli.deleted_logs":              "成功删除 %d 条操作日志",
+	"cli.dry_run_delete_states":     "[DRY RUN] 将删除 %d 个虚拟机状态",
+	"cli.deleted_states":            "成功删除 %d 个虚拟机状态",
+	"cli.compact_start":             "开始压缩存储...",
+	"cli.compact_progress":          "压缩进度 %d/%d: %s",
+	"cli.compact_size":              "存储大小: %.1f MB -> %.1f MB",
+	"cli.compact_done":              "压缩完成，删除 %d 个空文件、%d 个空目录",
+	"cli.compact_dry_run":           "[DRY RUN] 将压缩存储",
+	"cli.compact_unsupported":       "当前存储不支持压缩，已跳过",
+	"cli.compact_failed":            "压缩存储失败",
+	"cli.export_tenants_failed":     "导出客户汇总失败",
+	"cli.tenants_exported":          "客户汇总已导出 (%s): %s",
+	"cli.no_tenants":                "未配置客户划分（请设置 tenants.tag_prefix 或 tenants.mapping）",
+	"cli.public_link_failed":        "生成公开链接失败: %v",
+	"cli.public_link_expires":       "链接有效期至: %s",
+	"cli.public_link_never":         "链接永不过期（更换 api.public_secret 可使所有链接失效）",
+	"cli.import_failed":             "导入失败: %v",
+	"cli.import_requires_vmid":      "导入数据需要指定 -vmid 参数",
+	"cli.import_read_failed":        "读取导入文件失败",
+	"cli.import_parse_failed":       "解析导入文件失败",
+	"cli.import_empty":              "导入文件中没有流量记录",
+	"cli.import_prepare":            "准备导入 %d 条记录 (格式: %s) 到 VM%d: %s 至 %s",
+	"cli.import_overlap":            "VM%d 在该时间范围内已有 %d 条记录，请先使用 -cleanup vm 清除后再导入",
+	"cli.import_rebase_failed":      "导入的记录无法与已有记录衔接",
+	"cli.import_rebased":            "导入的计数器已接到 %s 的已有记录上",
+	"cli.import_dry_run":            "[DRY RUN] 将导入 %d 条记录",
+	"cli.import_save_failed":        "保存第 %d 条记录失败",
+	"cli.import_progress":           "已导入 %d/%d 条记录",
+	"cli.imported":                  "成功导入 %d 条记录到 VM%d",
+	"cli.recompute_failed":          "重新计算失败: %v",
+	"cli.recompute_requires_range":  "重新计算需要指定 -start 和 -end 参数",
+	"cli.recompute_prepare":         "准备重新计算 %d 台虚拟机的数据: %s 至 %s",
+	"cli.recompute_vm":              "[%d/%d] VM%d: %d 条记录, 下载 %.2f GB, 上传 %.2f GB",
+	"cli.recompute_vm_failed":       "[%d/%d] VM%d 计算失败: %v",
+	"cli.recompute_vm_anomalies":    "VM%d 数据异常: 计数器回退 %d 次, 时间戳乱序 %d 条, 时间戳重复 %d 条",
+	"cli.recompute_summary":         "共 %d 台虚拟机, %d 条记录, 总流量 %.2f GB",
+	"cli.recompute_dry_run":         "[DRY RUN] 将重建记录计数器并通知主程序清除统计缓存",
+	"cli.recompute_counter":         "记录计数器已重建: %d 条记录 (耗时 %v)",
+	"cli.recompute_counter_failed":  "重建记录计数器失败",
+	"cli.simulate_failed":           "规则模拟失败: %v",
+	"cli.simulate_read_rule_failed": "读取规则文件失败",
+	"cli.simulate_invalid_rule":     "规则无效",
+	"cli.simulate_range":            "模拟规则 %s，时间范围: %s - %s",
+	"cli.simulate_none":             "时间范围内没有虚拟机会触发该规则",
+	"cli.simulate_header":           "VMID\t名称\t操作\t触发时间\t恢复时间\t用量/限额",
+	"cli.simulate_summary":          "匹配虚拟机 %d 台，共触发 %d 次",
+	"cli.bench_failed":              "基准测试失败: %v",
+	"cli.fsck_failed":               "存储检查失败: %v",
+	"cli.fsck_unsupported":          "%s 存储不支持完整性检查",
+	"cli.fsck_summary":              "重新统计 %d 条记录（可读取 %d 条），检查 %d 个流量文件",
+	"cli.fsck_counter":              "计数器保存的记录数: %d",
+	"cli.fsck_issue":                "问题 %s: %s (%d)",
+	"cli.fsck_issue_repaired":       "已修复 %s: %s (%d)",
+	"cli.fsck_rebuilt":              "已重建记录计数器: %d 条记录",
+	"cli.fsck_clean":                "未发现问题",
+	"cli.fsck_repaired":             "已修复 %d 个问题",
+	"cli.fsck_issues":               "发现 %d 个问题，使用 -repair 修复",
+	"cli.fsck_unrepairable":         "%d 个问题无法自动修复，请手动处理",
+	"cli.archive_failed":            "归档命令失败: %v",
+	"cli.archive_usage":             "用法: archive run [-month 2006-01] [-dry-run] | list | restore -month 2006-01 [-vmid ID] [-dry-run]",
+	"cli.archive_disabled":          "未配置归档（archive.endpoint）",
+	"cli.archive_month_open":        "%s 尚未结束，只能归档已结束的月份",
+	"cli.archive_none":              "没有待归档的月份",
+	"cli.archive_dry_run":           "[预览] %s: 本地 %d 条记录将上传到 %s 并从本地删除",
+	"cli.archived":                  "已归档 %s: %d 台虚拟机 %d 条记录 (%d 字节) -> %s，本地删除 %d 条",
+	"cli.archive_kept":              "归档期间以下虚拟机有新记录写入，本地记录保留到下次归档: %v",
+	"cli.archive_item":              "%s\t%d 字节\t%s",
+	"cli.archive_count":             "共 %d 个归档",
+	"cli.archive_restore_requires":  "restore 需要 -month 参数（格式: 2006-01）",
+	"cli.archive_restore_skipped":   "以下虚拟机本地已有该月的记录，未恢复（需先清除本地记录）: %v",
+	"cli.archive_restore_dry_run":   "[预览] 将恢复 %d 条记录（%d 台虚拟机，%s）",
+	"cli.archive_restored":          "已恢复 %d 条记录（%d 台虚拟机，%s）",
+	"cli.bench_invalid":             "参数无效，范围: %s",
+	"cli.bench_exists":              "VM%d 已有流量记录；基准测试使用 VM%d-VM%d，请先删除这些虚拟机的数据",
+	"cli.bench_start":               "基准测试: %d 台虚拟机 × %d 天，每 %s 一条记录，共 %d 条（存储: %s，%d 个并发）",
+	"cli.bench_header":              "阶段\t操作数\t记录数\t用时(秒)\t操作/秒\t记录/秒\t错误\t",
+	"cli.bench_phase_write":         "写入",
+	"cli.bench_phase_read_day":      "读取最近一天",
+	"cli.bench_phase_read_all":      "读取全部",
+	"cli.bench_phase_aggregate":     "周期统计",
+	"cli.bench_phase_delete":        "删除",
+	"cli.bench_error":               "%s 阶段有 %d 个错误，第一个: %s",
+	"cli.bench_kept":                "已保留生成的数据（VM%d-VM%d），可用 -cleanup vm -vmid 删除",
+	"cli.ctime_failed":              "创建时间命令失败: %v",
+	"cli.ctime_requires_vmid":       "创建时间命令需要指定 -vmid 参数",
+	"cli.ctime_show":                "VM%d 创建时间: %s (来源: %s)",
+	"cli.ctime_set":                 "VM%d 创建时间已设置为 %s",
+	"cli.ctime_cleared":             "VM%d 已清除手动设置的创建时间",
+	"cli.config_failed":             "配置命令失败: %v",
+	"cli.config_usage":              "用法: config validate | config show [-effective]",
+	"cli.config_files":              "配置文件: %s",
+	"cli.config_error":              "错误: %s",
+	"cli.config_warning":            "警告: %s",
+	"cli.config_invalid":            "配置有 %d 个错误",
+	"cli.config_valid":              "配置有效 (%d 条规则, %d 个警告)",
+
+	// API
+	"api.unauthorized":               "未授权: 令牌无效或缺失",
+	"api.invalid_vmid":               "无效的虚拟机 ID",
+	"api.invalid_param":              "无效的参数 %s: %s",
+	"api.history_too_many_points":    "时间范围内的数据点超过 %d 个，请缩小范围、使用更大的粒度或 step 参数",
+	"api.invalid_order":              "无效的排序方向: %s (asc/desc)",
+	"api.invalid_sort":               "无效的排序字段: %s",
+	"api.invalid_cursor":             "无效的游标",
+	"api.invalid_success":            "无效的 success 参数: %s (true/false)",
+	"api.invalid_start":              "开始时间格式无效，请使用 RFC3339",
+	"api.invalid_end":                "结束时间格式无效，请使用 RFC3339",
+	"api.invalid_period":             "无效的周期: %s",
+	"api.list_vms_failed":            "获取虚拟机列表失败: %v",
+	"api.get_vm_failed":              "获取虚拟机信息失败: %v",
+	"api.enforcement_failed":         "读取虚拟机限制状态失败: %v",
+	"api.get_logs_failed":            "获取日志失败: %v",
+	"api.get_records_failed":         "获取流量记录失败: %v",
+	"api.chart_no_records":           "虚拟机 %d 在该时间范围内没有流量记录",
+	"api.chart_render_failed":        "生成图表失败: %v",
+	"api.vm_not_found":               "虚拟机 %d 不存在",
+	"api.network_not_found":          "网络 %s 不存在",
+	"api.node_forbidden":             "客户密钥不能访问节点流量",
+	"api.metrics_forbidden":          "客户密钥不能访问监控指标",
+	"api.public_disabled":            "未配置 api.public_secret，公开状态页已禁用",
+	"api.public_link_invalid":        "链接无效或已过期",
+	"api.rate_limited":               "请求过于频繁，请稍后再试",
+	"api.body_too_large":             "请求体过大（上限 %d 字节）",
+	"api.method_not_allowed":         "不支持的请求方法: %s",
+	"api.invalid_body":               "请求体无效: %v",
+	"api.agent_push_failed":          "代理推送被拒绝: %v",
+	"api.ingest_disabled":            "未配置 api.token，不接受写入流量记录",
+	"api.ingest_forbidden":           "客户密钥不能写入流量记录",
+	"api.ingest_invalid":             "流量记录无效: %v",
+	"api.ingest_failed":              "保存流量记录失败: %v",
+	"api.maintenance_forbidden":      "客户密钥不能访问维护模式",
+	"notify.title":                   "PVE 流量监控",
+	"notify.limit_warning":           "VM%d 规则 %s 的流量用量达到限额的 %.0f%%: %.2f / %.2f GB",
+	"notify.action_ok":               "VM%d 已按规则 %s 执行操作: %s\n原因: %s",
+	"notify.action_failed":           "VM%d 按规则 %s 执行操作 %s 失败: %s\n原因: %s",
+	"notify.recovery_done":           "VM%d 已恢复（规则 %s，操作: %s）",
+	"notify.config_reloaded":         "配置已重载",
+	"notify.alert_firing":            "VM%d 超出规则 %s 的限制: %s",
+	"notify.alert_repeat":            "VM%d 仍超出规则 %s 的限制（已持续 %s）: %s",
+	"notify.alert_escalated":         "[升级] VM%d 超出规则 %s 的限制已持续 %s: %s",
+	"notify.alert_resolved":          "VM%d 规则 %s 的超限告警已解除（持续 %s）",
+	"notify.collection_gap":          "VM%d 已有 %d 个采集间隔没有采集到流量数据（最近一次采样: %s），期间的流量不会计入周期用量",
+	"notify.ratio_anomaly":           "VM%d 上传/下载比例异常：最近 %d 分钟平均上传 %.2f Mbps、下载 %.2f Mbps（%.1f:1，阈值 %.1f:1），可能被入侵用于外传数据或做种",
+	"notify.external_change":         "VM%d 在规则 %s 的%s限制期间运行状态被外部修改（%s -> %s），恢复时不会改变其运行状态",
+	"notify.collection_gap_error":    "最近的错误: %s",
+	"digest.title":                   "PVE 流量监控摘要 (%s ~ %s)",
+	"digest.none":                    "无",
+	"digest.top":                     "流量最高的 %d 台虚拟机",
+	"digest.consumer":                "%d. %s: %.2f GB（下载 %.2f GB / 上传 %.2f GB）",
+	"digest.quotas":                  "用量达到限额 %.0f%% 的虚拟机",
+	"digest.quota":                   "%s 规则 %s: %.1f%%（%.2f / %.2f GB）",
+	"digest.exceeded":                "[已超限]",
+	"digest.actions":                 "执行的操作 (%d)",
+	"digest.action":                  "%s %s 规则 %s: %s %s",
+	"digest.action_ok":               "成功",
+	"digest.action_failed":           "失败",
+	"digest.anomalies":               "异常 (%d)",
+	"digest.anomaly.collection_gap":  "%d 个采集间隔没有采集到流量数据",
+	"digest.anomaly.external_change": "规则 %s 的%s限制期间运行状态被外部修改（%s -> %s）",
+	"digest.anomaly.ratio_anomaly":   "上传/下载比例异常（%.1f:1）",
+	"digest.anomaly.action_failed":   "规则 %s 的操作%s执行失败",
+	"digest.more":                    "……另外 %d 条",
+	"digest.footer":                  "由 PVE 流量监控生成",
+	"api.maintenance_disabled":       "未配置 api.token，不能通过 API 修改维护模式",
+	"api.maintenance_invalid":        "请求体无效，需要 enabled 字段",
+	"api.maintenance_failed":         "保存维护模式失败: %v",
+	"api.recoveries_failed":          "读取或修改恢复计划失败: %v",
+	"api.recoveries_forbidden":       "客户密钥不能修改恢复计划",
+	"api.recoveries_disabled":        "未配置 api.token，不能通过 API 修改恢复计划",
+	"api.recoveries_invalid":         "请求体无效，需要 vmid 和 op（postpone 需要晚于当前时间的 until，或 cancel）",
+	"api.recovery_not_found":         "虚拟机 %d 没有待恢复的限制",
+
+	// 内置页面
+	"ui.lang.switch":             "English",
+	"ui.nav.overview":            "概览",
+	"ui.nav.charts":              "图表",
+	"ui.nav.rules":               "规则",
+	"ui.nav.actions":             "操作记录",
+	"ui.nav.token":               "令牌",
+	"ui.theme.dark":              "暗色",
+	"ui.theme.light":             "亮色",
+	"ui.theme.toggle":            "切换主题",
+	"ui.token.prompt":            "请输入 API 令牌（留空表示不认证）：",
+	"ui.common.refresh":          "刷新",
+	"ui.common.loading":          "加载中...",
+	"ui.common.update":           "更新",
+	"ui.common.close":            "关闭",
+	"ui.common.details":          "详情",
+	"ui.common.all":              "全部",
+	"ui.common.load_failed":      "加载数据失败",
+	"ui.stats.total_vms":         "虚拟机总数",
+	"ui.stats.running":           "运行中",
+	"ui.stats.today_traffic":     "今日流量",
+	"ui.stats.total_samples":     "采样点总数",
+	"ui.stats.api_avg":           "API 平均响应",
+	"ui.vms.title":               "虚拟机",
+	"ui.vm.details":              "虚拟机详情",
+	"ui.vm.share":                "分享",
+	"ui.vm.share_prompt":         "只读状态页链接：",
+	"ui.public.quotas":           "额度使用情况",
+	"ui.public.no_quotas":        "未配置额度",
+	"ui.public.expires":          "链接有效期至 %s",
+	"ui.col.id":                  "ID",
+	"ui.col.name":                "名称",
+	"ui.col.status":              "状态",
+	"ui.col.matched_rules":       "匹配规则",
+	"ui.col.download":            "下载",
+	"ui.col.upload":              "上传",
+	"ui.col.total":               "总计",
+	"ui.col.action":              "操作",
+	"ui.col.period":              "周期",
+	"ui.col.time":                "时间",
+	"ui.col.vm":                  "虚拟机",
+	"ui.col.rule":                "规则",
+	"ui.col.reason":              "原因",
+	"ui.col.result":              "结果",
+	"ui.col.utilization":         "使用率",
+	"ui.status.running":          "运行中",
+	"ui.status.stopped":          "已停止",
+	"ui.label.period":            "周期：",
+	"ui.label.direction":         "方向：",
+	"ui.period.current_minute":   "当前分钟",
+	"ui.period.current_hour":     "当前小时",
+	"ui.period.today":            "今天",
+	"ui.period.current_month":    "本月",
+	"ui.period.minute_last_hour": "分钟（最近一小时）",
+	"ui.period.minute":           "分钟",
+	"ui.period.hour":             "小时",
+	"ui.period.day":              "天",
+	"ui.period.month":            "月",
+	"ui.direction.both":          "双向",
+	"ui.direction.download":      "下载",
+	"ui.direction.upload":        "上传",
+	"ui.direction.rx":            "下载",
+	"ui.direction.tx":            "上传",
+	"ui.charts.update":           "更新图表",
+	"ui.charts.overview":         "流量总览",
+	"ui.charts.top_vms":          "流量前 10 的虚拟机",
+	"ui.charts.all_vms":          "所有虚拟机流量",
+	"ui.charts.traffic_gb":       "流量 (GB)",
+	"ui.charts.download_gb":      "下载 (GB)",
+	"ui.charts.upload_gb":        "上传 (GB)",
+	"ui.charts.total_gb":         "总计 (GB)",
+	"ui.charts.total_traffic_gb": "总流量 (GB)",
+	"ui.charts.total_traffic":    "总流量",
+	"ui.charts.no_data":          "暂无数据",
+	"ui.charts.usage_pattern":    "流量使用趋势",
+	"ui.charts.time":             "时间",
+	"ui.charts.usage_mb":         "流量 (MB)",
+	"ui.charts.usage_gb":         "流量 (GB)",
+	"ui.rules.none":              "未配置规则",
+	"ui.rules.no_vms":            "没有匹配的虚拟机",
+	"ui.rules.load_failed":       "加载规则失败",
+	"ui.rules.type_rate":         "带宽",
+	"ui.rules.type_volume":       "流量",
+	"ui.rules.vms":               "%d 台虚拟机",
+	"ui.rules.disabled":          "已禁用",
+	"ui.rules.exceeded":          "%d 台超限",
+	"ui.rules.avg":               "%s 分钟平均",
+	"ui.actions.range":           "范围：",
+	"ui.actions.last_24h":        "最近 24 小时",
+	"ui.actions.last_7d":         "最近 7 天",
+	"ui.actions.last_30d":        "最近 30 天",
+	"ui.actions.last_90d":        "最近 90 天",
+	"ui.actions.vmid":            "虚拟机 ID：",
+	"ui.actions.rule":            "规则：",
+	"ui.actions.action":          "操作：",
+	"ui.actions.result":          "结果：",
+	"ui.actions.apply":           "应用",
+	"ui.actions.total":           "操作总数",
+	"ui.actions.succeeded":       "成功",
+	"ui.actions.failed":          "失败",
+	"ui.actions.success":         "成功",
+	"ui.actions.top_rule":        "触发最多的规则",
+	"ui.actions.history":         "操作历史",
+	"ui.actions.none":            "所选范围内没有操作记录",
+	"ui.actions.load_failed":     "加载操作记录失败",
+	"ui.action.shutdown":         "关机",
+	"ui.action.stop":             "强制停止",
+	"ui.action.disconnect":       "断网",
+	"ui.action.rate_limit":       "限速",
+	"ui.action.exec":             "执行命令",
+}
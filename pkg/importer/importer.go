@@ -0,0 +1,307 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// 支持的导入格式
+const (
+	FormatCSV    = "csv"    // 累计计数器 CSV：timestamp, rx_bytes, tx_bytes
+	FormatVnstat = "vnstat" // vnstat --json 输出（jsonversion 2）
+)
+
+// csvTimeFormats CSV 中支持的时间格式（另支持 Unix 秒时间戳）
+var csvTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Parse 按格式解析外部流量数据，返回按时间排序的记录
+func Parse(format string, r io.Reader, vmid int) ([]models.TrafficRecord, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return ParseCSV(r, vmid)
+	case FormatVnstat:
+		return ParseVnstat(r, vmid)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s (支持: csv, vnstat)", format)
+	}
+}
+
+// ParseCSV 解析累计计数器 CSV（与采集的记录相同：rx_bytes/tx_bytes 为累计值，计数器回退视为重启）
+// 第一行为表头，必须包含 timestamp、rx_bytes、tx_bytes 列，其他列忽略
+func ParseCSV(r io.Reader, vmid int) ([]models.TrafficRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"timestamp", "rx_bytes", "tx_bytes"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("缺少列: %s", required)
+		}
+	}
+
+	var records []models.TrafficRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+
+		field := func(name string) string {
+			if i := columns[name]; i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		ts, err := parseCSVTime(field("timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		rx, err := strconv.ParseUint(field("rx_bytes"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: 无效的 rx_bytes: %w", line, err)
+		}
+		tx, err := strconv.ParseUint(field("tx_bytes"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: 无效的 tx_bytes: %w", line, err)
+		}
+
+		records = append(records, models.TrafficRecord{
+			VMID:       vmid,
+			Timestamp:  ts,
+			RXBytes:    rx,
+			TXBytes:    tx,
+			TotalBytes: rx + tx,
+		})
+	}
+
+	sortRecords(records)
+	return records, nil
+}
+
+// parseCSVTime 解析 CSV 时间（不带时区的时间按本地时区处理）
+func parseCSVTime(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	for _, format := range csvTimeFormats {
+		if t, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %q", value)
+}
+
+// vnstatEntry vnstat 的单个统计区间（rx/tx 为该区间内的字节数）
+type vnstatEntry struct {
+	Date struct {
+		Year  int `json:"year"`
+		Month int `json:"month"`
+		Day   int `json:"day"`
+	} `json:"date"`
+	Time *struct {
+		Hour   int `json:"hour"`
+		Minute int `json:"minute"`
+	} `json:"time"`
+	Timestamp int64  `json:"timestamp"`
+	RX        uint64 `json:"rx"`
+	TX        uint64 `json:"tx"`
+}
+
+// start 区间开始时间（优先使用 timestamp 字段）
+func (e vnstatEntry) start() time.Time {
+	if e.Timestamp > 0 {
+		return time.Unix(e.Timestamp, 0)
+	}
+	day := e.Date.Day
+	if day == 0 {
+		day = 1 // 月统计没有日期
+	}
+	hour, minute := 0, 0
+	if e.Time != nil {
+		hour, minute = e.Time.Hour, e.Time.Minute
+	}
+	return time.Date(e.Date.Year, time.Month(e.Date.Month), day, hour, minute, 0, 0, time.Local)
+}
+
+// vnstatExport vnstat --json 输出（仅解析需要的字段）
+type vnstatExport struct {
+	JSONVersion string `json:"jsonversion"`
+	Interfaces  []struct {
+		Name    string `json:"name"`
+		Traffic struct {
+			FiveMinute []vnstatEntry `json:"fiveminute"`
+			Hour       []vnstatEntry `json:"hour"`
+			Day        []vnstatEntry `json:"day"`
+			Month      []vnstatEntry `json:"month"`
+		} `json:"traffic"`
+	} `json:"interfaces"`
+}
+
+// ParseVnstat 解析 vnstat --json 输出（jsonversion 2，单位为字节）
+// 使用可用的最细粒度（5 分钟 > 小时 > 天 > 月），多个网卡按区间合并，
+// 区间流量累加为从 0 开始的累计计数器：区间开始时的累计值为一条记录，区间结束时再记一条
+func ParseVnstat(r io.Reader, vmid int) ([]models.TrafficRecord, error) {
+	var export vnstatExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("解析 vnstat JSON 失败: %w", err)
+	}
+	if export.JSONVersion != "" && export.JSONVersion != "2" {
+		return nil, fmt.Errorf("不支持的 vnstat jsonversion: %s（需要 vnstat 2.x 的 --json 输出）", export.JSONVersion)
+	}
+	if len(export.Interfaces) == 0 {
+		return nil, errors.New("vnstat 数据中没有网卡")
+	}
+
+	type interval struct {
+		rx, tx     uint64
+		start, end time.Time
+	}
+	intervals := make(map[int64]*interval) // 区间开始的 Unix 时间 -> 区间
+
+	for _, iface := range export.Interfaces {
+		var entries []vnstatEntry
+		var length func(time.Time) time.Time
+		switch t := iface.Traffic; {
+		case len(t.FiveMinute) > 0:
+			entries, length = t.FiveMinute, func(s time.Time) time.Time { return s.Add(5 * time.Minute) }
+		case len(t.Hour) > 0:
+			entries, length = t.Hour, func(s time.Time) time.Time { return s.Add(time.Hour) }
+		case len(t.Day) > 0:
+			entries, length = t.Day, func(s time.Time) time.Time { return s.AddDate(0, 0, 1) }
+		default:
+			entries, length = t.Month, func(s time.Time) time.Time { return s.AddDate(0, 1, 0) }
+		}
+
+		for _, entry := range entries {
+			start := entry.start()
+			iv, ok := intervals[start.Unix()]
+			if !ok {
+				iv = &interval{start: start, end: length(start)}
+				intervals[start.Unix()] = iv
+			}
+			iv.rx += entry.RX
+			iv.tx += entry.TX
+		}
+	}
+	if len(intervals) == 0 {
+		return nil, errors.New("vnstat 数据中没有流量记录")
+	}
+
+	sorted := make([]*interval, 0, len(intervals))
+	for _, iv := range intervals {
+		sorted = append(sorted, iv)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+
+	var records []models.TrafficRecord
+	var rx, tx uint64
+	var lastEnd time.Time
+	for _, iv := range sorted {
+		// 第一个区间及区间不连续时补一条区间开始的记录（累计值不变），使流量落在对应区间内
+		if !iv.start.Equal(lastEnd) {
+			records = append(records, models.TrafficRecord{VMID: vmid, Timestamp: iv.start, RXBytes: rx, TXBytes: tx, TotalBytes: rx + tx})
+		}
+		rx += iv.rx
+		tx += iv.tx
+		records = append(records, models.TrafficRecord{VMID: vmid, Timestamp: iv.end, RXBytes: rx, TXBytes: tx, TotalBytes: rx + tx})
+		lastEnd = iv.end
+	}
+
+	return records, nil
+}
+
+// counterFields 流量记录中的累计计数器（导入的数据通常只有收发字节，其余为 0）
+var counterFields = []func(r *models.TrafficRecord) *uint64{
+	func(r *models.TrafficRecord) *uint64 { return &r.RXBytes },
+	func(r *models.TrafficRecord) *uint64 { return &r.TXBytes },
+	func(r *models.TrafficRecord) *uint64 { return &r.DiskRead },
+	func(r *models.TrafficRecord) *uint64 { return &r.DiskWrite },
+	func(r *models.TrafficRecord) *uint64 { return &r.IPv4RX },
+	func(r *models.TrafficRecord) *uint64 { return &r.IPv4TX },
+	func(r *models.TrafficRecord) *uint64 { return &r.IPv6RX },
+	func(r *models.TrafficRecord) *uint64 { return &r.IPv6TX },
+}
+
+// Rebase 将导入的记录接到已有记录上，使衔接处不被当作计数器重启（导入的计数器从 0 开始，与采集的计数器无关）
+// 先把导入的计数器按相邻增量（回退视为重启）改写为连续递增的计数器，导入范围内的用量不变；
+// next（导入范围之后的第一条已有记录）存在时整体平移使最后一条与 next 相同，衔接处增量为 0，
+// 导入范围之前的已有记录到第一条导入记录的增量即为 next 的计数器中没有被导入数据覆盖的部分；
+// 只有 prev（导入范围之前的最后一条已有记录）时平移使第一条与 prev 相同
+// 导入的用量超过 next 的计数器时无法平移，返回错误
+func Rebase(records []models.TrafficRecord, prev, next *models.TrafficRecord) error {
+	if len(records) == 0 || (prev == nil && next == nil) {
+		return nil
+	}
+
+	for _, field := range counterFields {
+		// 改写为从 0 开始的连续计数器
+		var total, last uint64
+		for i := range records {
+			value := field(&records[i])
+			current := *value
+			if i > 0 {
+				if current >= last {
+					total += current - last
+				} else {
+					total += current
+				}
+			}
+			last = current
+			*value = total
+		}
+
+		var base uint64
+		switch {
+		case next != nil:
+			anchor := *field(next)
+			if anchor < total {
+				return fmt.Errorf("导入的流量 (%d) 超过之后已有记录 %s 的计数器 (%d)，无法衔接",
+					total, next.Timestamp.Format("2006-01-02 15:04:05"), anchor)
+			}
+			base = anchor - total
+		default:
+			base = *field(prev)
+		}
+		for i := range records {
+			*field(&records[i]) += base
+		}
+	}
+
+	for i := range records {
+		records[i].TotalBytes = records[i].RXBytes + records[i].TXBytes
+	}
+	return nil
+}
+
+// sortRecords 按时间排序
+func sortRecords(records []models.TrafficRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+}
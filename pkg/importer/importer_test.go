@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := `timestamp,rx_bytes,tx_bytes,note
+2026-01-03 03:05:00,300,30,
+1767323040,100,10,unix
+2026-01-04T03:06:00Z,500,60,utc
+`
+	records, err := ParseCSV(strings.NewReader(input), 101)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %d, want 3", len(records))
+	}
+	if records[0].RXBytes != 100 || records[0].VMID != 101 || records[0].TotalBytes != 110 {
+		t.Fatalf("first record = %+v, want unix timestamp row sorted first", records[0])
+	}
+
+	if _, err := ParseCSV(strings.NewReader("time,rx,tx\n"), 101); err == nil {
+		t.Fatalf("ParseCSV() without required columns should fail")
+	}
+}
+
+func TestParseVnstatMergesInterfacesIntoCounters(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	input := `{"jsonversion":"2","interfaces":[
+		{"name":"eth0","traffic":{"hour":[
+			{"timestamp":` + unix(base) + `,"rx":100,"tx":10},
+			{"timestamp":` + unix(base.Add(time.Hour)) + `,"rx":200,"tx":20},
+			{"timestamp":` + unix(base.Add(3*time.Hour)) + `,"rx":50,"tx":5}
+		],"day":[{"date":{"year":2026,"month":1,"day":2},"rx":999,"tx":999}]}},
+		{"name":"eth1","traffic":{"hour":[
+			{"timestamp":` + unix(base) + `,"rx":1,"tx":1}
+		]}}
+	]}`
+
+	records, err := ParseVnstat(strings.NewReader(input), 102)
+	if err != nil {
+		t.Fatalf("ParseVnstat() error = %v", err)
+	}
+
+	// 基准 + 两个连续小时 + 空档后的区间开始 + 区间结束
+	want := []struct {
+		at     time.Time
+		rx, tx uint64
+	}{
+		{base, 0, 0},
+		{base.Add(time.Hour), 101, 11},
+		{base.Add(2 * time.Hour), 301, 31},
+		{base.Add(3 * time.Hour), 301, 31},
+		{base.Add(4 * time.Hour), 351, 36},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v, want %d records", records, len(want))
+	}
+	for i, w := range want {
+		r := records[i]
+		if !r.Timestamp.Equal(w.at) || r.RXBytes != w.rx || r.TXBytes != w.tx || r.VMID != 102 {
+			t.Fatalf("record %d = %+v, want %s rx=%d tx=%d", i, r, w.at, w.rx, w.tx)
+		}
+	}
+}
+
+func TestRebaseJoinsExistingRecords(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	imported := func() []models.TrafficRecord {
+		// 导入的计数器从 0 开始，中间有一次重启（100 -> 30）
+		return []models.TrafficRecord{
+			{VMID: 101, Timestamp: base, RXBytes: 0},
+			{VMID: 101, Timestamp: base.Add(time.Hour), RXBytes: 100},
+			{VMID: 101, Timestamp: base.Add(2 * time.Hour), RXBytes: 30},
+		}
+	}
+
+	store, err := storage.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	defer store.Close()
+
+	// 开始监控后的记录：计数器为虚拟机启动后的累计值，且有磁盘计数器
+	next := models.TrafficRecord{VMID: 101, Timestamp: base.Add(3 * time.Hour), RXBytes: 5000, DiskRead: 900}
+	later := models.TrafficRecord{VMID: 101, Timestamp: base.Add(4 * time.Hour), RXBytes: 5100, DiskRead: 950}
+	storage.SaveTrafficRecords(store, []models.TrafficRecord{next, later})
+
+	records := imported()
+	if err := Rebase(records, nil, &next); err != nil {
+		t.Fatalf("Rebase() error = %v", err)
+	}
+	if records[2].RXBytes != 5000 || records[0].RXBytes != 4870 || records[0].DiskRead != 900 {
+		t.Fatalf("rebased records = %+v, want the last one equal to the next existing record", records)
+	}
+	if err := storage.SaveTrafficRecords(store, records); err != nil {
+		t.Fatalf("save imported records: %v", err)
+	}
+
+	// 导入的用量 100 + 30，衔接处没有增量，之后监控的用量 100
+	stats, err := store.CalculateTrafficStatsWithTimeRange(101, base, base.Add(4*time.Hour), models.DirectionBoth)
+	if err != nil {
+		t.Fatalf("calculate stats: %v", err)
+	}
+	if stats.RXBytes != 230 {
+		t.Fatalf("RX across the seam = %d, want 230 (without the counter drop counted as a restart)", stats.RXBytes)
+	}
+
+	// 只有之前的记录时接到之前的记录上
+	records = imported()
+	prev := models.TrafficRecord{VMID: 101, Timestamp: base.Add(-time.Hour), RXBytes: 7000}
+	if err := Rebase(records, &prev, nil); err != nil || records[0].RXBytes != 7000 || records[2].RXBytes != 7130 {
+		t.Fatalf("Rebase() onto prev = %+v, %v, want 7000..7130", records, err)
+	}
+
+	// 导入的用量超过之后记录的计数器时无法衔接
+	records = imported()
+	if err := Rebase(records, nil, &models.TrafficRecord{Timestamp: next.Timestamp, RXBytes: 100}); err == nil {
+		t.Fatal("Rebase() onto a smaller counter should fail")
+	}
+}
+
+func unix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -0,0 +1,128 @@
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errInstanceLocked 实例锁已被其他进程持有
+var errInstanceLocked = errors.New("实例锁已被其他进程持有")
+
+// InstanceInfo 正在运行的主程序信息（写入 PID 文件）
+type InstanceInfo struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	StartedAt  time.Time `json:"started_at"`
+	ConfigPath string    `json:"config_path"`
+	Storage    string    `json:"storage"`
+}
+
+// AlreadyRunningError 另一个主程序已持有实例锁
+type AlreadyRunningError struct {
+	Path string
+	Info *InstanceInfo // PID 文件内容无法解析时为 nil
+}
+
+func (e *AlreadyRunningError) Error() string {
+	if e.Info == nil {
+		return fmt.Sprintf("另一个监控主程序正在使用同一存储运行 (锁文件: %s)", e.Path)
+	}
+	return fmt.Sprintf("另一个监控主程序正在使用同一存储运行: PID %d, 主机 %s, 启动于 %s, 配置 %s (锁文件: %s)",
+		e.Info.PID, e.Info.Hostname, e.Info.StartedAt.Format("2006-01-02 15:04:05"), e.Info.ConfigPath, e.Path)
+}
+
+// InstanceLock 主程序的单实例锁：持有 PID 文件的 flock 排他锁直到进程退出
+// 进程崩溃时内核自动释放锁，残留的 PID 文件不会阻止下次启动
+type InstanceLock struct {
+	file *os.File
+}
+
+// AcquireInstanceLock 获取单实例锁并写入当前进程信息，已有主程序运行时返回 *AlreadyRunningError
+func AcquireInstanceLock(path string, info InstanceInfo) (*InstanceLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开 PID 文件失败: %w", err)
+	}
+
+	if err := tryLockExclusive(f); err != nil {
+		running, _ := readInstanceInfo(f)
+		f.Close()
+		if errors.Is(err, errInstanceLocked) {
+			return nil, &AlreadyRunningError{Path: path, Info: running}
+		}
+		return nil, fmt.Errorf("获取实例锁失败: %w", err)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %w", err)
+	}
+	if _, err := f.WriteAt(append(data, '\n'), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入 PID 文件失败: %w", err)
+	}
+
+	return &InstanceLock{file: f}, nil
+}
+
+// Release 清空 PID 文件并释放锁（不删除文件，避免与同时启动的进程竞争）
+func (l *InstanceLock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	l.file.Truncate(0)
+	unlockInstance(l.file)
+	l.file.Close()
+	l.file = nil
+}
+
+// RunningInstance 检查是否有主程序持有实例锁，返回其信息（PID 文件内容无法解析时 info 为 nil）
+func RunningInstance(path string) (info *InstanceInfo, running bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("打开 PID 文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if !instanceLocked(f) {
+		return nil, false, nil
+	}
+
+	info, _ = readInstanceInfo(f)
+	return info, true, nil
+}
+
+// readInstanceInfo 读取 PID 文件内容
+func readInstanceInfo(f *os.File) (*InstanceInfo, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var info InstanceInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析 PID 文件失败: %w", err)
+	}
+	return &info, nil
+}
+
+// GetDefaultPIDPath 获取默认 PID 文件路径（与 socket 位于同一目录）
+func GetDefaultPIDPath(storagePath string) string {
+	if storagePath == "" {
+		storagePath = os.TempDir()
+	}
+	os.MkdirAll(storagePath, 0755)
+	return filepath.Join(storagePath, "monitor.pid")
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package ipc
+
+import "os"
+
+// tryLockExclusive 其他平台没有 flock，不检测重复启动（PVE 主机运行在 Linux 上）
+func tryLockExclusive(f *os.File) error {
+	return nil
+}
+
+// unlockInstance 其他平台不需要释放
+func unlockInstance(f *os.File) {}
+
+// instanceLocked 其他平台无法判断，视为没有正在运行的主程序
+func instanceLocked(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,52 @@
+package ipc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInstanceLock(t *testing.T) {
+	dir := t.TempDir()
+	path := GetDefaultPIDPath(dir)
+
+	lock, err := AcquireInstanceLock(path, InstanceInfo{PID: 1234, Hostname: "pve1", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock() error = %v", err)
+	}
+
+	// 第二个主程序启动失败，错误中包含正在运行的进程信息
+	_, err = AcquireInstanceLock(path, InstanceInfo{PID: 5678})
+	var running *AlreadyRunningError
+	if !errors.As(err, &running) || running.Info == nil || running.Info.PID != 1234 {
+		t.Fatalf("second AcquireInstanceLock() error = %v, want AlreadyRunningError for PID 1234", err)
+	}
+	if info, ok, err := RunningInstance(path); err != nil || !ok || info == nil || info.Hostname != "pve1" {
+		t.Fatalf("RunningInstance() = %+v, %v, %v, want pve1 running", info, ok, err)
+	}
+
+	// 状态查询在同一连接上回复
+	server, _ := NewServer(filepath.Join(dir, "monitor.sock"))
+	server.OnQuery("status", func(msg Message) map[string]interface{} {
+		return map[string]interface{}{"pid": 1234}
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer server.Stop()
+	reply, err := NewClient(filepath.Join(dir, "monitor.sock")).Query(Message{Type: "status"})
+	if err != nil || reply.Data["pid"] != float64(1234) {
+		t.Fatalf("Query() = %+v, %v, want pid 1234", reply, err)
+	}
+
+	lock.Release()
+	if _, ok, err := RunningInstance(path); err != nil || ok {
+		t.Fatalf("RunningInstance() after Release() = %v, %v, want not running", ok, err)
+	}
+	lock, err = AcquireInstanceLock(path, InstanceInfo{PID: 5678})
+	if err != nil {
+		t.Fatalf("AcquireInstanceLock() after Release() error = %v", err)
+	}
+	lock.Release()
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package ipc
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockExclusive 非阻塞地获取 PID 文件的 flock 排他锁，已被其他进程持有时返回 errInstanceLocked
+func tryLockExclusive(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errInstanceLocked
+	}
+	return err
+}
+
+// unlockInstance 释放 PID 文件的 flock
+func unlockInstance(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// instanceLocked 是否有进程持有 PID 文件的排他锁（能拿到共享锁说明没有）
+func instanceLocked(f *os.File) bool {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return false
+	}
+	return true
+}
@@ -13,7 +13,7 @@ import (
 
 // Message IPC消息
 type Message struct {
-	Type      string                 `json:"type"`      // 消息类型: reload_cache, cleanup_done
+	Type      string                 `json:"type"`      // 消息类型: reload_cache, cleanup_done, status
 	Timestamp time.Time              `json:"timestamp"` // 消息时间
 	Data      map[string]interface{} `json:"data"`      // 附加数据
 }
@@ -24,6 +24,9 @@ type Server struct {
 	listener   net.Listener
 	handlers   map[string]func(Message)
 	stopChan   chan struct{}
+
+	// 需要回复的查询（如 status）
+	queries map[string]func(Message) map[string]interface{}
 }
 
 // NewServer 创建新的Socket服务器
@@ -35,6 +38,7 @@ func NewServer(socketPath string) (*Server, error) {
 		socketPath: socketPath,
 		handlers:   make(map[string]func(Message)),
 		stopChan:   make(chan struct{}),
+		queries:    make(map[string]func(Message) map[string]interface{}),
 	}, nil
 }
 
@@ -97,6 +101,19 @@ func (s *Server) handleConnection(conn net.Conn) {
 			continue
 		}
 
+		// 查询：在同一连接上回复一行 JSON
+		if query, exists := s.queries[msg.Type]; exists {
+			reply := Message{Type: msg.Type, Timestamp: time.Now(), Data: query(msg)}
+			data, err := json.Marshal(reply)
+			if err != nil {
+				log.Printf("序列化回复失败: %v", err)
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+			conn.Write(append(data, '\n'))
+			continue
+		}
+
 		// 调用处理器
 		if handler, exists := s.handlers[msg.Type]; exists {
 			handler(msg)
@@ -111,6 +128,11 @@ func (s *Server) OnMessage(msgType string, handler func(Message)) {
 	s.handlers[msgType] = handler
 }
 
+// OnQuery 注册查询处理器，返回值作为回复消息的数据
+func (s *Server) OnQuery(msgType string, handler func(Message) map[string]interface{}) {
+	s.queries[msgType] = handler
+}
+
 // Stop 停止服务器
 func (s *Server) Stop() {
 	close(s.stopChan)
@@ -157,6 +179,35 @@ func (c *Client) SendMessage(msg Message) error {
 	return nil
 }
 
+// Query 发送查询并等待回复
+func (c *Client) Query(msg Message) (Message, error) {
+	var reply Message
+	conn, err := net.DialTimeout("unix", c.socketPath, 2*time.Second)
+	if err != nil {
+		return reply, fmt.Errorf("连接Socket服务器失败: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return reply, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return reply, err
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return reply, fmt.Errorf("读取回复失败: %w", err)
+	}
+	if err := json.Unmarshal(line, &reply); err != nil {
+		return reply, fmt.Errorf("解析回复失败: %w", err)
+	}
+	return reply, nil
+}
+
 // GetDefaultSocketPath 获取默认socket路径
 func GetDefaultSocketPath(storagePath string) string {
 	// 如果是文件存储，使用存储路径
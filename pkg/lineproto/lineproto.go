@@ -0,0 +1,216 @@
+package lineproto
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func init() {
+	events.Register("line_protocol", newEmitter)
+}
+
+// sendTimeout 连接和每次写入的超时时间
+const sendTimeout = 5 * time.Second
+
+// sharedLast 推送插件共用的每台虚拟机上一条记录（配置重载重新创建插件后继续计算增量）
+var sharedLast = newLastRecords()
+
+// lastRecords 每台虚拟机上一条采集的记录
+type lastRecords struct {
+	mu      sync.Mutex
+	records map[int]models.TrafficRecord
+}
+
+func newLastRecords() *lastRecords {
+	return &lastRecords{records: make(map[int]models.TrafficRecord)}
+}
+
+// swap 保存虚拟机最新的记录，返回之前的记录
+func (l *lastRecords) swap(record models.TrafficRecord) (models.TrafficRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev, ok := l.records[record.VMID]
+	l.records[record.VMID] = record
+	return prev, ok
+}
+
+// Emitter 行协议推送插件：每次采集后把虚拟机与上次采样之间的流量增量以 Influx 行协议发送到 UDP/TCP 地址
+// 每台虚拟机的第一条记录只作为基准，不发送
+type Emitter struct {
+	network     string
+	address     string
+	measurement string
+	tags        string // 已排序和转义的附加标签（",k=v..."）
+	node        string
+	last        *lastRecords
+
+	mu      sync.Mutex
+	conn    net.Conn
+	failing bool // 发送失败后只记录一次日志，恢复后再记录
+}
+
+// newEmitter 配置了 line_protocol.url 时创建推送插件
+func newEmitter(cfg *models.Config) (events.Subscriber, error) {
+	if !cfg.LineProtocol.Enabled() {
+		return nil, nil
+	}
+	e, err := New(cfg.LineProtocol, cfg.PVE.Node)
+	if err != nil {
+		return nil, err
+	}
+	e.last = sharedLast
+	return e, nil
+}
+
+// New 创建推送插件（node 为每行的 node 标签，为空时不添加）
+func New(config models.LineProtocolConfig, node string) (*Emitter, error) {
+	network, address, err := config.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(config.Tags))
+	for key := range config.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var tags strings.Builder
+	for _, key := range keys {
+		tags.WriteString("," + escapeTag(key) + "=" + escapeTag(config.Tags[key]))
+	}
+	return &Emitter{
+		network:     network,
+		address:     address,
+		measurement: escapeMeasurement(config.MeasurementName()),
+		tags:        tags.String(),
+		node:        node,
+		last:        newLastRecords(),
+	}, nil
+}
+
+// Types 只订阅采集事件
+func (e *Emitter) Types() []events.Type {
+	return []events.Type{events.SampleCollected}
+}
+
+// Handle 计算与上一条记录之间的增量并发送
+func (e *Emitter) Handle(ev events.Event) {
+	if ev.Record == nil {
+		return
+	}
+	prev, ok := e.last.swap(*ev.Record)
+	if !ok {
+		return
+	}
+	line, ok := e.Line(prev, *ev.Record)
+	if !ok {
+		return
+	}
+	e.send(line)
+}
+
+// Line 两次采样之间的增量（计数器变小视为虚拟机重启，增量为当前值），时间没有前进时返回 false
+// 字段: rx_bytes、tx_bytes、total_bytes（整数增量）、rx_bps、tx_bps（平均带宽）、interval（秒）、backup
+func (e *Emitter) Line(prev, record models.TrafficRecord) (string, bool) {
+	seconds := record.Timestamp.Sub(prev.Timestamp).Seconds()
+	if seconds <= 0 {
+		return "", false
+	}
+	rx, tx := delta(prev.RXBytes, record.RXBytes), delta(prev.TXBytes, record.TXBytes)
+
+	var b strings.Builder
+	b.WriteString(e.measurement)
+	b.WriteString(",vmid=" + strconv.Itoa(record.VMID))
+	if e.node != "" {
+		b.WriteString(",node=" + escapeTag(e.node))
+	}
+	b.WriteString(e.tags)
+	fmt.Fprintf(&b, " rx_bytes=%di,tx_bytes=%di,total_bytes=%di,rx_bps=%s,tx_bps=%s,interval=%s,backup=%t %d\n",
+		rx, tx, rx+tx,
+		formatFloat(float64(rx)*8/seconds), formatFloat(float64(tx)*8/seconds), formatFloat(seconds),
+		record.Backup, record.Timestamp.UnixNano())
+	return b.String(), true
+}
+
+// send 发送一行（TCP 连接断开时重新连接并重试一次）
+func (e *Emitter) send(line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err := e.write(line)
+	if err != nil && e.network == "tcp" {
+		err = e.write(line)
+	}
+	if err != nil {
+		if !e.failing {
+			log.Printf("行协议推送到 %s://%s 失败: %v", e.network, e.address, err)
+		}
+		e.failing = true
+		return
+	}
+	if e.failing {
+		log.Printf("行协议推送到 %s://%s 已恢复", e.network, e.address)
+		e.failing = false
+	}
+}
+
+// write 需要时建立连接并写入，失败时关闭连接（下次重新连接）
+func (e *Emitter) write(line string) error {
+	if e.conn == nil {
+		conn, err := net.DialTimeout(e.network, e.address, sendTimeout)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	e.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	if _, err := e.conn.Write([]byte(line)); err != nil {
+		e.conn.Close()
+		e.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close 关闭连接
+func (e *Emitter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// delta 计数器增量（变小时视为重启，增量为当前值）
+func delta(prev, current uint64) uint64 {
+	if current < prev {
+		return current
+	}
+	return current - prev
+}
+
+// formatFloat 行协议的浮点字段值
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escapeTag 转义标签的名称和值（逗号、等号、空格）
+func escapeTag(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// escapeMeasurement 转义 measurement（逗号、空格）
+func escapeMeasurement(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `).Replace(s)
+}
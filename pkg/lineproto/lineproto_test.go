@@ -0,0 +1,52 @@
+package lineproto
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestEmitterSendsDeltasOverUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	e, err := New(models.LineProtocolConfig{
+		URL:  "udp://" + listener.LocalAddr().String(),
+		Tags: map[string]string{"site": "fra 1", "cluster": "a,b"},
+	}, "pve1")
+	if err != nil {
+		t.Fatalf("new emitter: %v", err)
+	}
+	defer e.Close()
+
+	base := time.Unix(1700000000, 0)
+	e.Handle(events.Event{Type: events.SampleCollected, Record: &models.TrafficRecord{VMID: 101, Timestamp: base, RXBytes: 1000, TXBytes: 5000}})
+	// 虚拟机重启后 TX 计数从 0 开始
+	e.Handle(events.Event{Type: events.SampleCollected, Record: &models.TrafficRecord{VMID: 101, Timestamp: base.Add(10 * time.Second), RXBytes: 2000, TXBytes: 500, Backup: true}})
+
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := `pve_vm_traffic,vmid=101,node=pve1,cluster=a\,b,site=fra\ 1 rx_bytes=1000i,tx_bytes=500i,total_bytes=1500i,rx_bps=800,tx_bps=400,interval=10,backup=true 1700000010000000000` + "\n"
+	if got := string(buf[:n]); got != want {
+		t.Fatalf("line =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEmitterRejectsInvalidURL(t *testing.T) {
+	for _, url := range []string{"http://telegraf:8094", "udp://telegraf", "tcp://"} {
+		if _, err := New(models.LineProtocolConfig{URL: url}, ""); err == nil || !strings.Contains(err.Error(), url) {
+			t.Fatalf("New(%s) error = %v, want invalid url", url, err)
+		}
+	}
+}
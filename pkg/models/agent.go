@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// 运行模式
+const (
+	ModeStandalone = "standalone" // 单机：采集、存储、规则和 API 都在本实例（默认）
+	ModeAgent      = "agent"      // 采集代理：只采集本节点的虚拟机，推送到汇总端
+	ModeAggregator = "aggregator" // 汇总端：接收代理推送的采样，统一存储、执行规则并提供 API
+)
+
+// DefaultAgentMaxPending 代理在汇总端不可用时默认最多缓存的采样数
+const DefaultAgentMaxPending = 100000
+
+// AgentConfig 采集代理配置（mode=agent）
+type AgentConfig struct {
+	AggregatorURL string `json:"aggregator_url"`        // 汇总端 API 地址，如 https://monitor.example.com:8080
+	Token         string `json:"token"`                 // 推送令牌（与汇总端的 aggregator.agent_token 相同）
+	MaxPending    int    `json:"max_pending,omitempty"` // 汇总端不可用时最多缓存的采样数（默认 100000，超出后丢弃最早的）
+}
+
+// AggregatorConfig 汇总端配置（mode=aggregator）
+type AggregatorConfig struct {
+	AgentToken string `json:"agent_token"` // 代理推送使用的令牌（与 API token 分开）
+}
+
+// AgentPush 代理一次推送的采样
+type AgentPush struct {
+	Node        string          `json:"node"`                   // 代理所在的 PVE 节点
+	SentAt      time.Time       `json:"sent_at"`                // 发送时间
+	VMs         []VMInfo        `json:"vms"`                    // 本次采集的虚拟机（名称、标签、状态，用于规则匹配和虚拟机列表）
+	Records     []TrafficRecord `json:"records"`                // 虚拟机流量记录
+	NodeRecords []TrafficRecord `json:"node_records,omitempty"` // 节点网卡流量记录
+}
+
+// RunMode 运行模式（未配置时为单机）
+func (c *Config) RunMode() string {
+	if c.Mode == "" {
+		return ModeStandalone
+	}
+	return c.Mode
+}
+
+// MaxPendingRecords 代理最多缓存的采样数
+func (a AgentConfig) MaxPendingRecords() int {
+	if a.MaxPending <= 0 {
+		return DefaultAgentMaxPending
+	}
+	return a.MaxPending
+}
+
+// ValidateMode 验证运行模式及对应的配置
+func (c *Config) ValidateMode() error {
+	switch c.RunMode() {
+	case ModeStandalone:
+		return nil
+	case ModeAgent:
+		if c.Agent.AggregatorURL == "" {
+			return errors.New("agent.aggregator_url不能为空")
+		}
+		if u, err := url.Parse(c.Agent.AggregatorURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("agent.aggregator_url无效: %s", c.Agent.AggregatorURL)
+		}
+		if c.Agent.Token == "" {
+			return errors.New("agent.token不能为空")
+		}
+		if c.Agent.MaxPending < 0 {
+			return fmt.Errorf("agent.max_pending不能为负数，当前值: %d", c.Agent.MaxPending)
+		}
+	case ModeAggregator:
+		if c.Aggregator.AgentToken == "" {
+			return errors.New("aggregator.agent_token不能为空")
+		}
+		if !c.API.Enabled {
+			return errors.New("汇总端需要启用 API 服务器接收代理推送")
+		}
+	default:
+		return fmt.Errorf("mode必须是 standalone、agent 或 aggregator，当前值: %s", c.Mode)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 归档默认值
+const (
+	DefaultArchiveRegion     = "us-east-1"
+	DefaultArchivePrefix     = "pve-traffic-monitor/"
+	DefaultArchiveKeepMonths = 1
+)
+
+// ArchiveConfig 月度归档：已结束月份的流量记录压缩后上传到 S3 兼容的对象存储，再从本地存储删除（留空 endpoint 不启用）
+type ArchiveConfig struct {
+	Endpoint   string `json:"endpoint,omitempty"`    // 对象存储地址，如 https://s3.amazonaws.com、http://minio.local:9000
+	Region     string `json:"region,omitempty"`      // 签名使用的区域（默认 us-east-1）
+	Bucket     string `json:"bucket,omitempty"`      // 存储桶
+	Prefix     string `json:"prefix,omitempty"`      // 对象名前缀（默认 pve-traffic-monitor/）
+	AccessKey  string `json:"access_key,omitempty"`  // 访问密钥 ID
+	SecretKey  string `json:"secret_key,omitempty"`  // 访问密钥
+	PathStyle  bool   `json:"path_style,omitempty"`  // 使用路径形式访问存储桶（MinIO 等需要，默认使用虚拟主机形式）
+	KeepMonths int    `json:"keep_months,omitempty"` // 本地保留的已结束月份数（默认 1，即上个月在本月结束后才归档）
+}
+
+// Enabled 是否启用归档
+func (a ArchiveConfig) Enabled() bool {
+	return a.Endpoint != ""
+}
+
+// SigningRegion 签名使用的区域
+func (a ArchiveConfig) SigningRegion() string {
+	if a.Region == "" {
+		return DefaultArchiveRegion
+	}
+	return a.Region
+}
+
+// KeyPrefix 对象名前缀（非空时以 / 结尾）
+func (a ArchiveConfig) KeyPrefix() string {
+	prefix := a.Prefix
+	if prefix == "" {
+		prefix = DefaultArchivePrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.TrimPrefix(prefix, "/")
+}
+
+// LocalMonths 本地保留的已结束月份数
+func (a ArchiveConfig) LocalMonths() int {
+	if a.KeepMonths <= 0 {
+		return DefaultArchiveKeepMonths
+	}
+	return a.KeepMonths
+}
+
+// Validate 验证归档配置
+func (a ArchiveConfig) Validate() error {
+	if !a.Enabled() {
+		return nil
+	}
+	if u, err := url.Parse(a.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint无效: %s", a.Endpoint)
+	}
+	if a.Bucket == "" {
+		return errors.New("bucket不能为空")
+	}
+	if a.AccessKey == "" || a.SecretKey == "" {
+		return errors.New("access_key和secret_key不能为空")
+	}
+	if a.KeepMonths < 0 {
+		return fmt.Errorf("keep_months不能为负数，当前值: %d", a.KeepMonths)
+	}
+	return nil
+}
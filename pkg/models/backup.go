@@ -0,0 +1,40 @@
+package models
+
+import "strings"
+
+// 备份（vzdump）期间流量的处理方式
+const (
+	BackupTrafficCount   = "count"   // 不检测备份，流量照常计入（默认）
+	BackupTrafficTag     = "tag"     // 标记备份期间的流量记录，照常计入用量
+	BackupTrafficExclude = "exclude" // 标记备份期间的流量记录，这些采样区间的增量不计入用量统计
+)
+
+// LockBackup 虚拟机备份期间 PVE 设置的配置锁
+const LockBackup = "backup"
+
+// ValidBackupTrafficMode 是否为支持的备份流量处理方式（空值表示 count）
+func ValidBackupTrafficMode(mode string) bool {
+	switch strings.ToLower(mode) {
+	case "", BackupTrafficCount, BackupTrafficTag, BackupTrafficExclude:
+		return true
+	}
+	return false
+}
+
+// BackupTrafficMode 获取备份流量的处理方式
+func (m MonitorConfig) BackupTrafficMode() string {
+	if m.BackupTraffic == "" {
+		return BackupTrafficCount
+	}
+	return strings.ToLower(m.BackupTraffic)
+}
+
+// DetectsBackups 采集时是否检测虚拟机的备份任务
+func (m MonitorConfig) DetectsBackups() bool {
+	return m.BackupTrafficMode() != BackupTrafficCount
+}
+
+// ExcludesBackups 备份期间的流量是否不计入用量统计
+func (m MonitorConfig) ExcludesBackups() bool {
+	return m.BackupTrafficMode() == BackupTrafficExclude
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BridgeFilter 计入流量的网桥（按网卡统计，排除内部存储、备份等网络的流量）
+// 条目为网桥名（vmbr1）或网桥加 VLAN 标签（vmbr0.20，只匹配 tag=20 的网卡）
+type BridgeFilter struct {
+	Include []string `json:"include,omitempty"` // 只统计这些网桥上的网卡（留空则统计所有网卡）
+	Exclude []string `json:"exclude,omitempty"` // 不统计这些网桥上的网卡
+}
+
+// Enabled 是否按网桥过滤（都不配置时使用 PVE 汇总的 netin/netout）
+func (f BridgeFilter) Enabled() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// Counts 连接到指定网桥和 VLAN 的网卡流量是否计入（tag 为空表示未设置 VLAN）
+func (f BridgeFilter) Counts(bridge, tag string) bool {
+	if bridgeListMatches(f.Exclude, bridge, tag) {
+		return false
+	}
+	return len(f.Include) == 0 || bridgeListMatches(f.Include, bridge, tag)
+}
+
+// Validate 检查网桥名称格式
+func (f BridgeFilter) Validate() error {
+	for _, entry := range append(append([]string{}, f.Include...), f.Exclude...) {
+		bridge, vlan, hasVLAN := strings.Cut(entry, ".")
+		if strings.TrimSpace(bridge) == "" {
+			return fmt.Errorf("网桥名不能为空: %q", entry)
+		}
+		if hasVLAN {
+			if tag, err := strconv.Atoi(vlan); err != nil || tag < 1 || tag > 4094 {
+				return fmt.Errorf("无效的 VLAN 标签: %q（应为 1-4094）", entry)
+			}
+		}
+	}
+	return nil
+}
+
+// bridgeListMatches 网桥和 VLAN 是否在列表中
+func bridgeListMatches(entries []string, bridge, tag string) bool {
+	for _, entry := range entries {
+		if entry == bridge || (tag != "" && entry == bridge+"."+tag) {
+			return true
+		}
+	}
+	return false
+}
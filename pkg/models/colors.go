@@ -2,18 +2,21 @@ package models
 
 // ColorScheme 统一的配色方案
 type ColorScheme struct {
-	Primary    string
-	Success    string
-	Warning    string
-	Danger     string
-	Info       string
-	Download   string
-	Upload     string
-	Total      string
-	Background string
-	Text       string
-	Border     string
-	GridLine   string
+	Primary    string `json:"primary"`
+	Success    string `json:"success"`
+	Warning    string `json:"warning"`
+	Danger     string `json:"danger"`
+	Info       string `json:"info"`
+	Download   string `json:"download"`
+	Upload     string `json:"upload"`
+	Total      string `json:"total"`
+	Background string `json:"background"`
+	Text       string `json:"text"`
+	Border     string `json:"border"`
+	GridLine   string `json:"grid_line"`
+	Page       string `json:"page"`   // 页面底色（卡片之外的区域）
+	Muted      string `json:"muted"`  // 次要文字
+	Header     string `json:"header"` // 页头、表头背景
 }
 
 // LightTheme 亮色主题
@@ -30,6 +33,9 @@ var LightTheme = ColorScheme{
 	Text:       "#2c3e50", // 文字 - 深蓝灰
 	Border:     "#dcdfe6", // 边框 - 浅灰
 	GridLine:   "#e6e6e6", // 网格线 - 浅灰
+	Page:       "#f5f5f5", // 页面底色 - 浅灰
+	Muted:      "#7f8c8d", // 次要文字 - 灰色
+	Header:     "#2c3e50", // 页头 - 深蓝灰
 }
 
 // DarkTheme 暗色主题
@@ -46,20 +52,23 @@ var DarkTheme = ColorScheme{
 	Text:       "#e4e7ed", // 文字 - 浅灰
 	Border:     "#4c4d4f", // 边框 - 灰色
 	GridLine:   "#3a3a3a", // 网格线 - 深灰
+	Page:       "#141414", // 页面底色 - 近黑
+	Muted:      "#a8abb2", // 次要文字 - 中灰
+	Header:     "#2d2d2d", // 页头 - 深灰
 }
 
 // ChartColors ECharts 图表配色方案
 type ChartColors struct {
-	Download   string   // 下载颜色
-	Upload     string   // 上传颜色
-	Total      string   // 总计颜色
-	BarColors  []string // 柱状图颜色组
-	LineColors []string // 折线图颜色组
-	Background string   // 背景色
-	TextColor  string   // 文字颜色
-	AxisLine   string   // 坐标轴线颜色
-	SplitLine  string   // 分割线颜色
-	TooltipBg  string   // 提示框背景
+	Download   string   `json:"download"`    // 下载颜色
+	Upload     string   `json:"upload"`      // 上传颜色
+	Total      string   `json:"total"`       // 总计颜色
+	BarColors  []string `json:"bar_colors"`  // 柱状图颜色组
+	LineColors []string `json:"line_colors"` // 折线图颜色组
+	Background string   `json:"background"`  // 背景色
+	TextColor  string   `json:"text_color"`  // 文字颜色
+	AxisLine   string   `json:"axis_line"`   // 坐标轴线颜色
+	SplitLine  string   `json:"split_line"`  // 分割线颜色
+	TooltipBg  string   `json:"tooltip_bg"`  // 提示框背景
 }
 
 // GetChartColors 获取图表配色（支持主题）
@@ -91,3 +100,11 @@ func GetChartColors(isDark bool) ChartColors {
 		TooltipBg:  "#ffffff",
 	}
 }
+
+// GetColorScheme 获取界面配色（支持主题）
+func GetColorScheme(isDark bool) ColorScheme {
+	if isDark {
+		return DarkTheme
+	}
+	return LightTheme
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// 同一虚拟机同时超出多条限制规则时的冲突处理策略
+const (
+	ConflictMostSevere = "most_severe" // 执行最严厉的操作（默认）：stop > shutdown > disconnect > rate_limit（限速值越低越严厉）
+	ConflictFirstMatch = "first_match" // 执行按优先级排序后第一条超限规则的操作
+)
+
+// ValidConflictPolicy 是否为支持的冲突处理策略（空值表示默认的 most_severe）
+func ValidConflictPolicy(policy string) bool {
+	switch strings.ToLower(policy) {
+	case "", ConflictMostSevere, ConflictFirstMatch:
+		return true
+	}
+	return false
+}
+
+// ConflictResolution 获取冲突处理策略（默认 most_severe）
+func (m MonitorConfig) ConflictResolution() string {
+	if m.ConflictPolicy == "" {
+		return ConflictMostSevere
+	}
+	return strings.ToLower(m.ConflictPolicy)
+}
+
+// ActionSeverity 操作的严厉程度（越大越严厉；exec 不限制虚拟机，为 0）
+func ActionSeverity(action string) int {
+	switch action {
+	case ActionStop:
+		return 4
+	case ActionShutdown:
+		return 3
+	case ActionDisconnect:
+		return 2
+	case ActionRateLimit:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsRestrictive 操作是否限制虚拟机（exec 不限制，不参与冲突处理，超限时总会执行）
+func (r *Rule) IsRestrictive() bool {
+	return ActionSeverity(r.Action) > 0
+}
+
+// moreSevere a 的操作是否比 b 更严厉（强制停止的 shutdown 与 stop 相同，限速值越低越严厉）
+func moreSevere(a, b Rule) bool {
+	severity := func(r Rule) int {
+		if r.Action == ActionShutdown && r.ForceStop {
+			return ActionSeverity(ActionStop)
+		}
+		return ActionSeverity(r.Action)
+	}
+	if sa, sb := severity(a), severity(b); sa != sb {
+		return sa > sb
+	}
+	return a.Action == ActionRateLimit && a.RateLimitMB < b.RateLimitMB
+}
+
+// SortRulesByPriority 按优先级从高到低排序规则（优先级相同时保持配置文件中的顺序），即规则的检查顺序
+func SortRulesByPriority(rules []Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+}
+
+// SelectRule 按冲突处理策略从同时超限的限制规则中选出要执行的规则，返回下标（rules 需已按 SortRulesByPriority 排序）
+// most_severe 时严厉程度相同的规则取排在前面的一条
+func SelectRule(policy string, rules []Rule) int {
+	if len(rules) == 0 {
+		return -1
+	}
+	if strings.ToLower(policy) == ConflictFirstMatch {
+		return 0
+	}
+	selected := 0
+	for i := 1; i < len(rules); i++ {
+		if moreSevere(rules[i], rules[selected]) {
+			selected = i
+		}
+	}
+	return selected
+}
@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestSelectRule(t *testing.T) {
+	rules := []Rule{
+		{Name: "slow", Action: ActionRateLimit, RateLimitMB: 10},
+		{Name: "off", Action: ActionShutdown, Priority: -1},
+		{Name: "slower", Action: ActionRateLimit, RateLimitMB: 1, Priority: 5},
+		{Name: "cut", Action: ActionDisconnect},
+	}
+	SortRulesByPriority(rules)
+	if rules[0].Name != "slower" || rules[1].Name != "slow" || rules[2].Name != "cut" || rules[3].Name != "off" {
+		t.Fatalf("sorted rules = %v, want priority order with config order for ties", rules)
+	}
+
+	if got := rules[SelectRule(ConflictMostSevere, rules)].Name; got != "off" {
+		t.Fatalf("most_severe selected %s, want off", got)
+	}
+	if got := rules[SelectRule("", rules)].Name; got != "off" {
+		t.Fatalf("default policy selected %s, want off", got)
+	}
+	if got := rules[SelectRule(ConflictFirstMatch, rules)].Name; got != "slower" {
+		t.Fatalf("first_match selected %s, want slower", got)
+	}
+
+	// 都是限速时限速值越低越严厉；强制停止的 shutdown 与 stop 相同，取排在前面的一条
+	if got := rules[SelectRule(ConflictMostSevere, rules[:2])].Name; got != "slower" {
+		t.Fatalf("most_severe between rate limits selected %s, want slower", got)
+	}
+	stops := []Rule{{Name: "force", Action: ActionShutdown, ForceStop: true}, {Name: "stop", Action: ActionStop}}
+	if got := stops[SelectRule(ConflictMostSevere, stops)].Name; got != "force" {
+		t.Fatalf("most_severe between stops selected %s, want force", got)
+	}
+	if SelectRule(ConflictMostSevere, nil) != -1 {
+		t.Fatalf("SelectRule(nil) should return -1")
+	}
+}
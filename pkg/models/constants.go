@@ -36,15 +36,140 @@ const (
 	PeriodDay    = "day"
 	PeriodMonth  = "month"
 
+	// 内置页面主题
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+	ThemeAuto  = "auto" // 跟随浏览器（prefers-color-scheme）
+
+	// API 限流默认值
+	DefaultRateLimitRPS       = 5.0
+	DefaultRateLimitBurst     = 20
+	DefaultRateLimitExpensive = 5.0
+	DefaultMaxBodyBytes       = 1 << 20
+	DefaultMaxHeaderBytes     = 64 << 10
+
+	// 公开状态页签名密钥最小长度
+	MinPublicSecretLength = 16
+	// 公开状态页链接默认有效期
+	DefaultPublicLinkTTL = 30 * 24 * time.Hour
+
+	// 数据库连接池默认值
+	DefaultMaxOpenConns    = 10
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 3600 // 秒
+
+	// 本地缓冲默认最多保存的流量记录数
+	DefaultSpoolMaxRecords = 100000
+	// 本地缓冲重放间隔
+	SpoolReplayInterval = 30 * time.Second
+
+	// 存储操作超过多少毫秒时记录慢查询日志
+	DefaultSlowQueryMs = 1000
+
+	// 按步长聚合历史数据时的默认点数上限和允许的最大值（/api/history 的 max_points、导出）
+	DefaultHistoryMaxPoints = 500
+	MaxHistoryPoints        = 5000
+
+	// 主机流量趋势（/api/host/history、导出）默认单独显示的虚拟机数，其余合并显示
+	DefaultHostHistoryTop = 10
+
+	// 规则类型
+	RuleTypeVolume     = "volume"     // 按周期累计流量（默认）
+	RuleTypeRate       = "rate"       // 按持续带宽
+	RuleTypePercentile = "percentile" // 按周期内 5 分钟平均带宽的 95 百分位（突发计费）
+
+	// 规则的判断范围
+	RuleScopeVM   = "vm"   // 按每台虚拟机的用量判断（默认）
+	RuleScopeHost = "host" // 按所有匹配虚拟机的用量总和判断，超限时对 target 选出的虚拟机执行操作
+
+	// 主机规则超限时的操作对象
+	TargetTopTalkers = "top_talkers" // 周期内用量最高的 top_n 台虚拟机（默认）
+
+	// 主机规则默认限制的虚拟机数
+	DefaultTopTalkers = 3
+
+	// 规则的统计对象
+	MetricNetwork = "network" // 网络收发（默认）
+	MetricDisk    = "disk"    // 磁盘读写（仅 rate 规则）
+	MetricIPv4    = "ipv4"    // 客户机代理报告的 IPv4 收发（需要 monitor.ip_split）
+	MetricIPv6    = "ipv6"    // 客户机代理报告的 IPv6 收发
+
+	// 速率规则默认统计窗口（分钟）
+	DefaultRateWindowMinutes = 5
+
 	// 操作类型
 	ActionShutdown   = "shutdown"
 	ActionStop       = "stop"
 	ActionDisconnect = "disconnect"
 	ActionRateLimit  = "rate_limit"
+	ActionExec       = "exec" // 执行外部命令（不限制虚拟机，不需要恢复）
 
 	// 标签前缀
-	TagTrafficLimit      = "traffic-limit"
-	TagTrafficShutdown   = "traffic-exceeded-shutdown"
-	TagTrafficDisconnect = "traffic-exceeded-disconnected"
-	TagTrafficLimited    = "traffic-exceeded-limited"
+	TagTrafficLimit = "traffic-limit"
+	// LegacyRuleTagPrefix 旧版本的规则流量状态标签前缀（不随 prefix 变化），自定义前缀时仍清理，保留一个版本
+	LegacyRuleTagPrefix = TagTrafficLimit + "-"
+	// TagRuleLimit 规则流量状态标签的名称前缀（完整标签 = prefix + limit- + 规则名）
+	TagRuleLimit = "limit-"
+
+	// 操作标签默认值（完整标签 = 前缀 + 名称，如 traffic-exceeded-shutdown）
+	DefaultTagPrefix     = "traffic-"
+	DefaultTagShutdown   = "exceeded-shutdown"
+	DefaultTagDisconnect = "exceeded-disconnected"
+	DefaultTagLimited    = "exceeded-limited"
+	DefaultTagRatio      = "suspicious-upload"
+
+	// 限制状态标记方式
+	MarkerTags        = "tags"        // 添加操作标签（默认）
+	MarkerDescription = "description" // 在虚拟机备注（description）中写入 JSON 状态
+
+	// 操作失败的错误类型（ActionLog.ErrorKind）
+	ActionErrorPermission = "permission" // 权限不足（检查 API Token 或用户的权限）
+	ActionErrorNotFound   = "not_found"  // 虚拟机或资源不存在
+	ActionErrorLocked     = "locked"     // 虚拟机被锁定（备份、迁移、快照中）
+	ActionErrorTask       = "task"       // PVE 异步任务执行失败
+	ActionErrorTimeout    = "timeout"    // 等待异步任务或外部命令超时（任务可能仍在运行）
+	ActionErrorExec       = "exec"       // 外部命令无法启动或退出码不为 0
+	ActionErrorOther      = "other"
+
+	// PVE 认证方式
+	PVEAuthToken  = "token"  // API Token（默认）
+	PVEAuthTicket = "ticket" // 用户名密码登录获取票据（Cookie），自动续期
+
+	// 网络汇总类型（NetworkStats.Type）
+	NetworkTypeBridge = "bridge"
+	NetworkTypeVNet   = "vnet"
+
+	// 外部密钥来源
+	SecretProviderFile    = "file"    // 读取文件内容
+	SecretProviderSystemd = "systemd" // systemd 凭据（$CREDENTIALS_DIRECTORY 下的文件）
+	SecretProviderVault   = "vault"   // HashiCorp Vault KV
+
+	// Vault 密钥默认刷新间隔（秒）
+	DefaultVaultRefreshSeconds = 300
+
+	// PVE 异步任务（关机、停止、启动）的默认等待时间（秒）和轮询间隔
+	DefaultTaskTimeoutSeconds = 180
+	TaskPollInterval          = 2 * time.Second
+
+	// 运行中的虚拟机默认超过多少个采集间隔没有采样时告警
+	DefaultGapIntervals = 3
+
+	// minute 周期统计窗口的默认值和范围（秒）
+	DefaultMinuteWindowSeconds = 300
+	MinMinuteWindowSeconds     = 60
+	MaxMinuteWindowSeconds     = 3600
+
+	// 旧数据清理的默认时间（每天凌晨 3 点）
+	DefaultCleanupSchedule = "0 3 * * *"
+
+	// 主备模式的默认租约时长（秒）和允许的最小值
+	DefaultHALeaseSeconds = 30
+	MinHALeaseSeconds     = 6
+
+	// 操作执行队列的默认并发数、重试次数和首次重试间隔（秒），以及允许的最大值
+	DefaultActionConcurrency       = 4
+	DefaultActionRetries           = 2
+	DefaultActionRetryDelaySeconds = 30
+	MaxActionConcurrency           = 50
+	MaxActionRetries               = 10
 )
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+)
+
+// Effective 返回填充默认值后实际生效的配置（用于 config show -effective，不修改原配置）
+func (c Config) Effective() Config {
+	c.PVE.AuthMethod = c.PVE.AuthMode()
+	c.Storage = c.Storage.effective()
+
+	managed := c.Monitor.TagsManaged()
+	c.Monitor.ManageTags = &managed
+	c.Monitor.Tags = c.Monitor.Tags.withDefaults()
+	c.Monitor.Marker = c.Monitor.MarkerBackend()
+	c.Monitor.ConflictPolicy = c.Monitor.ConflictResolution()
+	c.Monitor.Templates = c.Monitor.TemplateMode()
+	c.Monitor.BackupTraffic = c.Monitor.BackupTrafficMode()
+	c.Monitor.TaskTimeout = int(c.Monitor.TaskWait() / time.Second)
+	nodeStats := c.Monitor.NodeStatsEnabled()
+	c.Monitor.NodeStats = &nodeStats
+	recoverOnExit := c.Monitor.RecoversOnExit()
+	c.Monitor.RecoverOnExit = &recoverOnExit
+	c.Monitor.CleanupSchedule = c.Monitor.CleanupCron()
+	if c.Monitor.GapIntervals == 0 {
+		c.Monitor.GapIntervals = DefaultGapIntervals
+	}
+	if c.Monitor.StoppedIntervals == 0 {
+		c.Monitor.StoppedIntervals = 1
+	}
+	if c.Monitor.RatioAlert.Enabled() {
+		c.Monitor.RatioAlert.WindowMinutes = int(c.Monitor.RatioAlert.Window() / time.Minute)
+		c.Monitor.RatioAlert.MinUploadMbps = c.Monitor.RatioAlert.MinUpload()
+	}
+	c.Monitor.MinuteWindowSeconds = int(c.Monitor.MinuteWindow() / time.Second)
+	retries := c.Monitor.Actions.Retries()
+	c.Monitor.Actions = ActionQueueConfig{
+		Concurrency:       c.Monitor.Actions.Workers(),
+		MaxRetries:        &retries,
+		RetryDelaySeconds: int(c.Monitor.Actions.RetryDelay() / time.Second),
+	}
+	c.Mode = c.RunMode()
+	if c.Mode == ModeAgent {
+		c.Agent.MaxPending = c.Agent.MaxPendingRecords()
+	}
+	if c.RemoteWrite.Enabled() {
+		if c.RemoteWrite.Source == "" {
+			c.RemoteWrite.Source = c.PVE.Node
+		}
+		c.RemoteWrite.BatchSize = c.RemoteWrite.Batch()
+		c.RemoteWrite.FlushSeconds = int(c.RemoteWrite.FlushInterval() / time.Second)
+		c.RemoteWrite.MaxPending = c.RemoteWrite.MaxPendingRecords()
+	}
+	if c.LineProtocol.Enabled() {
+		c.LineProtocol.Measurement = c.LineProtocol.MeasurementName()
+	}
+	if c.Archive.Enabled() {
+		c.Archive.Region = c.Archive.SigningRegion()
+		c.Archive.Prefix = c.Archive.KeyPrefix()
+		c.Archive.KeepMonths = c.Archive.LocalMonths()
+	}
+	if c.Events.WarnPercent == 0 {
+		c.Events.WarnPercent = DefaultWarnPercent
+	}
+	if len(c.Notify.Channels) > 0 {
+		if c.Notify.RepeatSeconds == 0 {
+			c.Notify.RepeatSeconds = DefaultNotifyRepeatSeconds
+		}
+		if c.Notify.Alerts.RenotifyHours == 0 {
+			c.Notify.Alerts.RenotifyHours = DefaultAlertRenotifyHours
+		}
+	}
+	if c.Monitor.HA.Enabled {
+		c.Monitor.HA.InstanceID = c.Monitor.HA.ID()
+		c.Monitor.HA.LeaseSeconds = int(c.Monitor.HA.LeaseDuration() / time.Second)
+	}
+
+	if c.API.Theme == "" {
+		c.API.Theme = ThemeAuto
+	}
+	if c.API.RateLimit.RequestsPerSecond <= 0 {
+		c.API.RateLimit.RequestsPerSecond = DefaultRateLimitRPS
+	}
+	if c.API.RateLimit.Burst <= 0 {
+		c.API.RateLimit.Burst = DefaultRateLimitBurst
+	}
+	if c.API.RateLimit.ExpensiveCost <= 0 {
+		c.API.RateLimit.ExpensiveCost = DefaultRateLimitExpensive
+	}
+	if c.API.RateLimit.MaxBodyBytes <= 0 {
+		c.API.RateLimit.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if c.API.RateLimit.MaxHeaderBytes <= 0 {
+		c.API.RateLimit.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+
+	if locale := i18n.Normalize(c.Locale); locale != "" {
+		c.Locale = locale
+	} else if c.Locale == "" {
+		c.Locale = i18n.DefaultLocale
+	}
+
+	if c.UsesVault() {
+		c.Vault.RefreshSeconds = int(c.Vault.RefreshInterval() / time.Second)
+	}
+
+	rules := make([]Rule, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.Type == "" {
+			rule.Type = RuleTypeVolume
+		}
+		if rule.TrafficDirection == "" {
+			rule.TrafficDirection = DirectionBoth
+		}
+		if rule.Scope == "" {
+			rule.Scope = RuleScopeVM
+		}
+		if rule.IsHostRule() {
+			if rule.Target == "" {
+				rule.Target = TargetTopTalkers
+			}
+			rule.TopN = rule.TopTalkers()
+		}
+		if rule.IsRateRule() && rule.RateWindowMinutes <= 0 {
+			rule.RateWindowMinutes = DefaultRateWindowMinutes
+		}
+		rules[i] = rule
+	}
+	c.Rules = rules
+
+	return c
+}
+
+// effective 填充存储配置的默认值
+func (s StorageConfig) effective() StorageConfig {
+	s.Type = strings.ToLower(s.Type)
+	if s.Type == "" {
+		s.Type = "file"
+	}
+	if s.Type != "file" {
+		if s.MaxOpenConns <= 0 {
+			s.MaxOpenConns = DefaultMaxOpenConns
+		}
+		if s.MaxIdleConns <= 0 {
+			s.MaxIdleConns = DefaultMaxIdleConns
+		}
+		if s.ConnMaxLifetime <= 0 {
+			s.ConnMaxLifetime = DefaultConnMaxLifetime
+		}
+	}
+	if s.SpoolDir != "" && s.SpoolMaxRecords <= 0 {
+		s.SpoolMaxRecords = DefaultSpoolMaxRecords
+	}
+	if s.SlowQueryMs == 0 {
+		s.SlowQueryMs = DefaultSlowQueryMs
+	}
+	if s.Replica != nil {
+		replica := s.Replica.effective()
+		s.Replica = &replica
+	}
+	return s
+}
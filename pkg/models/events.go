@@ -0,0 +1,35 @@
+package models
+
+import "fmt"
+
+// 事件默认值
+const (
+	// DefaultWarnPercent 用量达到限额的百分比时发布 limit_warning 事件
+	DefaultWarnPercent = 80
+)
+
+// EventsConfig 内部事件（采集、预警、操作、恢复、配置重载）的订阅设置
+type EventsConfig struct {
+	WarnPercent int    `json:"warn_percent,omitempty"` // 流量规则用量达到限额的百分比时发布预警（默认 80，-1 关闭）
+	AuditLog    string `json:"audit_log,omitempty"`    // 审计日志文件（JSON Lines，记录预警、操作、恢复和配置重载，留空不记录）
+}
+
+// WarnThreshold 预警百分比（0 表示不发布预警）
+func (e EventsConfig) WarnThreshold() int {
+	switch {
+	case e.WarnPercent < 0:
+		return 0
+	case e.WarnPercent == 0:
+		return DefaultWarnPercent
+	default:
+		return e.WarnPercent
+	}
+}
+
+// Validate 验证事件配置
+func (e EventsConfig) Validate() error {
+	if e.WarnPercent < -1 || e.WarnPercent >= 100 {
+		return fmt.Errorf("warn_percent 必须在 1-99 之间（-1 关闭），当前值: %d", e.WarnPercent)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 外部命令默认值
+const (
+	// DefaultExecTimeoutSeconds 外部命令的默认超时时间（秒）
+	DefaultExecTimeoutSeconds = 30
+	// MaxExecTimeoutSeconds 外部命令允许的最长超时时间（秒），避免长时间占用操作执行队列
+	MaxExecTimeoutSeconds = 600
+	// MaxExecOutputBytes 保存到操作日志的命令输出长度上限（超出部分截断）
+	MaxExecOutputBytes = 4096
+)
+
+// ExecConfig 外部命令（exec 操作和操作后钩子）
+// 命令直接执行，不经过 shell；需要管道或重定向时使用 ["sh", "-c", "..."]
+type ExecConfig struct {
+	Command        []string `json:"command"`                   // 程序路径和参数
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"` // 超时时间（秒，默认 30），超时后结束整个进程组
+}
+
+// Timeout 命令超时时间
+func (e ExecConfig) Timeout() time.Duration {
+	if e.TimeoutSeconds <= 0 {
+		return DefaultExecTimeoutSeconds * time.Second
+	}
+	return time.Duration(e.TimeoutSeconds) * time.Second
+}
+
+// Validate 验证外部命令配置
+func (e ExecConfig) Validate() error {
+	if len(e.Command) == 0 || strings.TrimSpace(e.Command[0]) == "" {
+		return errors.New("command 不能为空")
+	}
+	if e.TimeoutSeconds < 0 || e.TimeoutSeconds > MaxExecTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds 必须在 1-%d 之间，当前值: %d", MaxExecTimeoutSeconds, e.TimeoutSeconds)
+	}
+	return nil
+}
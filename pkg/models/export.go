@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// 导出目标类型
+const (
+	ExportDestinationS3     = "s3"
+	ExportDestinationWebDAV = "webdav"
+)
+
+// ExportLocal 命令行 -dest 使用此名称时导出到本地 export_path（忽略配置的默认目标）
+const ExportLocal = "local"
+
+// ExportDestination 导出文件的远程目标：导出的图表和数据直接上传，不写入本地 export_path
+type ExportDestination struct {
+	Name   string `json:"name"`             // 目标名称（命令行 -dest 和 monitor.export_destination 引用）
+	Type   string `json:"type"`             // s3 或 webdav
+	URL    string `json:"url"`              // s3: 对象存储地址（如 https://s3.amazonaws.com）; webdav: 目录地址（如 https://dav.example.com/reports/）
+	Prefix string `json:"prefix,omitempty"` // 文件名前缀（如 reports/，可包含目录）
+
+	// s3
+	Region    string `json:"region,omitempty"`     // 签名使用的区域（默认 us-east-1）
+	Bucket    string `json:"bucket,omitempty"`     // 存储桶
+	AccessKey string `json:"access_key,omitempty"` // 访问密钥 ID
+	SecretKey string `json:"secret_key,omitempty"` // 访问密钥
+	PathStyle bool   `json:"path_style,omitempty"` // 使用路径形式访问存储桶（MinIO 等需要）
+
+	// webdav
+	Username string `json:"username,omitempty"` // 基本认证用户名（留空不认证）
+	Password string `json:"password,omitempty"` // 基本认证密码
+}
+
+// Validate 验证导出目标
+func (d ExportDestination) Validate() error {
+	if d.Name == "" {
+		return errors.New("name不能为空")
+	}
+	if d.Name == ExportLocal {
+		return fmt.Errorf("name不能是 %s（保留给本地导出目录）", ExportLocal)
+	}
+	if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url无效: %s", d.URL)
+	}
+	switch d.Type {
+	case ExportDestinationS3:
+		if d.Bucket == "" {
+			return errors.New("s3 目标的bucket不能为空")
+		}
+		if d.AccessKey == "" || d.SecretKey == "" {
+			return errors.New("s3 目标的access_key和secret_key不能为空")
+		}
+	case ExportDestinationWebDAV:
+		if d.Bucket != "" || d.AccessKey != "" || d.SecretKey != "" {
+			return errors.New("webdav 目标不使用bucket、access_key和secret_key")
+		}
+	default:
+		return fmt.Errorf("type必须是 s3 或 webdav，当前值: %s", d.Type)
+	}
+	return nil
+}
+
+// Destination 按名称查找导出目标
+func (m MonitorConfig) Destination(name string) (ExportDestination, bool) {
+	for _, dest := range m.ExportDestinations {
+		if dest.Name == name {
+			return dest, true
+		}
+	}
+	return ExportDestination{}, false
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultLineProtocolMeasurement 行协议推送的默认 measurement
+const DefaultLineProtocolMeasurement = "pve_vm_traffic"
+
+// LineProtocolConfig 行协议推送：每个采集周期把每台虚拟机的流量增量以 Influx 行协议发送到 UDP/TCP 地址
+// （Telegraf socket_listener、InfluxDB UDP 等），不需要轮询 HTTP API（留空 url 不启用）
+type LineProtocolConfig struct {
+	URL         string            `json:"url,omitempty"`         // udp://host:port 或 tcp://host:port
+	Measurement string            `json:"measurement,omitempty"` // measurement 名称（默认 pve_vm_traffic）
+	Tags        map[string]string `json:"tags,omitempty"`        // 附加到每行的标签（如 cluster、site）
+}
+
+// Enabled 是否启用行协议推送
+func (l LineProtocolConfig) Enabled() bool {
+	return l.URL != ""
+}
+
+// MeasurementName 实际使用的 measurement
+func (l LineProtocolConfig) MeasurementName() string {
+	if l.Measurement == "" {
+		return DefaultLineProtocolMeasurement
+	}
+	return l.Measurement
+}
+
+// Endpoint 解析推送地址，返回网络类型（udp/tcp）和 host:port
+func (l LineProtocolConfig) Endpoint() (network, address string, err error) {
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+		return "", "", fmt.Errorf("url必须是 udp://host:port 或 tcp://host:port，当前值: %s", l.URL)
+	}
+	if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+		return "", "", fmt.Errorf("url缺少端口: %s", l.URL)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// Validate 验证行协议推送配置
+func (l LineProtocolConfig) Validate() error {
+	if !l.Enabled() {
+		return nil
+	}
+	if _, _, err := l.Endpoint(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(l.Measurement, "\n\r") {
+		return errors.New("measurement不能包含换行")
+	}
+	for key, value := range l.Tags {
+		if key == "" || value == "" {
+			return fmt.Errorf("tags 的名称和值不能为空: %q=%q", key, value)
+		}
+		if strings.ContainsAny(key+value, "\n\r") {
+			return fmt.Errorf("tags 不能包含换行: %q", key)
+		}
+	}
+	return nil
+}
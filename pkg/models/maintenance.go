@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Maintenance 维护模式：暂停执行规则和自动恢复虚拟机，继续采集数据
+// 保存在存储中，维护期间重启程序不会突然开始限制虚拟机
+type Maintenance struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"` // 进入维护模式的原因
+	Since   time.Time `json:"since,omitempty"`  // 进入（或退出）维护模式的时间
+	By      string    `json:"by,omitempty"`     // 设置来源（api、cli）
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// EnforcementMarker 写入虚拟机备注的限制状态（marker=description 时使用）
+type EnforcementMarker struct {
+	Action            string    `json:"action"`                        // 执行的操作
+	Rule              string    `json:"rule"`                          // 触发的规则名称
+	Reason            string    `json:"reason,omitempty"`              // 触发原因
+	Period            string    `json:"period,omitempty"`              // 规则周期
+	LimitGB           float64   `json:"limit_gb,omitempty"`            // 流量限制（流量规则）
+	RateThresholdMbps float64   `json:"rate_threshold_mbps,omitempty"` // 带宽阈值（带宽规则）
+	RateLimitMB       float64   `json:"rate_limit_mb,omitempty"`       // 限速值（rate_limit 操作）
+	ActionTime        time.Time `json:"action_time"`                   // 操作执行时间
+	RecoveryTime      time.Time `json:"recovery_time"`                 // 计划恢复时间
+}
+
+// ValidMarker 是否为支持的限制状态标记方式（空值表示默认的 tags）
+func ValidMarker(marker string) bool {
+	switch strings.ToLower(marker) {
+	case "", MarkerTags, MarkerDescription:
+		return true
+	}
+	return false
+}
+
+// MarkerBackend 获取限制状态标记方式（默认 tags）
+func (m MonitorConfig) MarkerBackend() string {
+	if m.Marker == "" {
+		return MarkerTags
+	}
+	return strings.ToLower(m.Marker)
+}
+
+// SameAction 比较操作（shutdown 与 stop 的效果相同，视为同一操作）
+func SameAction(a, b string) bool {
+	normalize := func(action string) string {
+		if action == ActionStop {
+			return ActionShutdown
+		}
+		return action
+	}
+	return normalize(a) == normalize(b)
+}
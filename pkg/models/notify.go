@@ -0,0 +1,301 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+
+	"pve-traffic-monitor/pkg/schedule"
+)
+
+// 通知通道类型
+const (
+	NotifyTelegram = "telegram" // Telegram Bot
+	NotifyGotify   = "gotify"   // Gotify 服务器
+	NotifyNtfy     = "ntfy"     // ntfy（ntfy.sh 或自建服务器）
+	NotifyEmail    = "email"    // SMTP 邮件（摘要以 HTML 发送）
+	NotifyWebhook  = "webhook"  // 通用 Webhook（POST JSON）
+)
+
+// 通知默认值
+const (
+	// DefaultNotifyRepeatSeconds 同一通知的默认最短发送间隔（秒）
+	DefaultNotifyRepeatSeconds = 3600
+	// DefaultTelegramURL Telegram Bot API 地址
+	DefaultTelegramURL = "https://api.telegram.org"
+	// DefaultNtfyURL ntfy 公共服务器地址
+	DefaultNtfyURL = "https://ntfy.sh"
+	// DefaultAlertRenotifyHours 持续超限时重复告警的默认间隔（小时）
+	DefaultAlertRenotifyHours = 6
+	// DefaultDigestSchedule 每日摘要的默认发送时间（每天早上 8 点）
+	DefaultDigestSchedule = "0 8 * * *"
+	// DefaultDigestTop 摘要中列出的流量最高的虚拟机数量
+	DefaultDigestTop = 10
+	// DefaultDigestQuotaPercent 摘要中列出的用量达到限额百分比的虚拟机
+	DefaultDigestQuotaPercent = 80
+)
+
+// NotifyAlert 超限告警的通知类型（开始超限、重复、升级和解除）
+const NotifyAlert = "alert"
+
+// NotifyDigest 每日摘要的通知类型（流量最高的虚拟机、接近限额的虚拟机、执行的操作和异常）
+const NotifyDigest = "digest"
+
+// NotifyEventTypes 可以发送通知的事件类型（除 alert、digest 外与 events 包的事件类型一致，不包括每次采集）
+var NotifyEventTypes = []string{"limit_warning", NotifyAlert, "action_executed", "recovery_done", "config_reloaded", "collection_gap", "external_change", "ratio_anomaly", NotifyDigest}
+
+// DefaultNotifyEvents 通道未指定 events 时订阅的事件类型（恢复通知包含在告警解除中）
+var DefaultNotifyEvents = []string{"limit_warning", NotifyAlert, "action_executed", "collection_gap", "external_change", "ratio_anomaly"}
+
+// NotifyConfig 通知设置：把预警、操作、恢复等事件发送到即时通讯和推送服务
+type NotifyConfig struct {
+	Channels      []NotifyChannel   `json:"channels,omitempty"`
+	Templates     map[string]string `json:"templates,omitempty"`      // 按事件类型覆盖消息内容（Go text/template，数据为事件）
+	RepeatSeconds int               `json:"repeat_seconds,omitempty"` // 同一通道、事件、虚拟机和规则的通知最短间隔（秒，默认 3600，-1 不限制）
+
+	Alerts AlertPolicy  `json:"alerts,omitempty"` // 超限告警的重复和升级策略
+	Digest DigestConfig `json:"digest,omitempty"` // 每日摘要（发送到 events 包含 digest 的通道）
+}
+
+// DigestConfig 每日摘要：按计划汇总流量最高的虚拟机、接近限额的虚拟机、执行的操作和异常，
+// 以一条消息代替大量的单独通知
+type DigestConfig struct {
+	Schedule     string  `json:"schedule,omitempty"`      // 发送时间（cron 表达式，默认每天 8 点），统计上次发送（或 24 小时前）以来的数据
+	Top          int     `json:"top,omitempty"`           // 列出流量最高的虚拟机数量（默认 10）
+	QuotaPercent float64 `json:"quota_percent,omitempty"` // 列出流量规则用量达到限额该百分比的虚拟机（默认 80）
+}
+
+// AlertPolicy 超限告警策略：开始超限时告警一次，持续超限时按间隔重复，
+// 超过一定时间后升级到其他通道，恢复时发送解除通知
+type AlertPolicy struct {
+	RenotifyHours      int      `json:"renotify_hours,omitempty"`       // 持续超限时重复告警的间隔（小时，默认 6，-1 不重复）
+	EscalateAfterHours int      `json:"escalate_after_hours,omitempty"` // 持续超限多少小时后升级（默认不升级）
+	EscalateTo         []string `json:"escalate_to,omitempty"`          // 升级后同时通知的通道（包括之后的重复告警和解除通知）
+}
+
+// NotifyChannel 通知通道
+type NotifyChannel struct {
+	Name     string   `json:"name"`               // 通道名称（规则的 notify 中引用）
+	Type     string   `json:"type"`               // telegram/gotify/ntfy/email/webhook
+	URL      string   `json:"url,omitempty"`      // 服务器地址（gotify、email 和 webhook 必填，telegram 和 ntfy 有默认值）
+	Token    string   `json:"token,omitempty"`    // telegram: Bot Token; gotify: 应用令牌; ntfy: 访问令牌（可选）; webhook: Bearer 令牌（可选）
+	ChatID   string   `json:"chat_id,omitempty"`  // telegram: 接收消息的会话 ID
+	Topic    string   `json:"topic,omitempty"`    // ntfy: 主题
+	Priority int      `json:"priority,omitempty"` // gotify: 0-10; ntfy: 1-5（默认使用服务器默认值）
+	Events   []string `json:"events,omitempty"`   // 订阅的事件类型（默认 limit_warning、alert、action_executed、collection_gap、external_change，摘要需要加上 digest）
+
+	// email: url 为 smtp://host:587（支持时使用 STARTTLS）或 smtps://host:465
+	Username string   `json:"username,omitempty"` // SMTP 用户名（留空不认证）
+	Password string   `json:"password,omitempty"` // SMTP 密码
+	From     string   `json:"from,omitempty"`     // 发件人
+	To       []string `json:"to,omitempty"`       // 收件人
+}
+
+// RepeatInterval 同一通知的最短发送间隔（0 表示不限制）
+func (n NotifyConfig) RepeatInterval() time.Duration {
+	switch {
+	case n.RepeatSeconds < 0:
+		return 0
+	case n.RepeatSeconds == 0:
+		return DefaultNotifyRepeatSeconds * time.Second
+	default:
+		return time.Duration(n.RepeatSeconds) * time.Second
+	}
+}
+
+// Renotify 持续超限时重复告警的间隔（0 表示不重复）
+func (a AlertPolicy) Renotify() time.Duration {
+	switch {
+	case a.RenotifyHours < 0:
+		return 0
+	case a.RenotifyHours == 0:
+		return DefaultAlertRenotifyHours * time.Hour
+	default:
+		return time.Duration(a.RenotifyHours) * time.Hour
+	}
+}
+
+// EscalateAfter 告警升级前持续超限的时间（0 表示不升级）
+func (a AlertPolicy) EscalateAfter() time.Duration {
+	return time.Duration(a.EscalateAfterHours) * time.Hour
+}
+
+// Cron 每日摘要的 cron 表达式
+func (d DigestConfig) Cron() string {
+	if d.Schedule == "" {
+		return DefaultDigestSchedule
+	}
+	return d.Schedule
+}
+
+// TopN 摘要中列出的流量最高的虚拟机数量
+func (d DigestConfig) TopN() int {
+	if d.Top <= 0 {
+		return DefaultDigestTop
+	}
+	return d.Top
+}
+
+// QuotaThreshold 摘要中列出接近限额的虚拟机的用量百分比
+func (d DigestConfig) QuotaThreshold() float64 {
+	if d.QuotaPercent <= 0 {
+		return DefaultDigestQuotaPercent
+	}
+	return d.QuotaPercent
+}
+
+// DigestEnabled 是否有通道订阅了每日摘要
+func (n NotifyConfig) DigestEnabled() bool {
+	for _, channel := range n.Channels {
+		if channel.Subscribes(NotifyDigest) {
+			return true
+		}
+	}
+	return false
+}
+
+// Channel 按名称查找通道
+func (n NotifyConfig) Channel(name string) (NotifyChannel, bool) {
+	for _, channel := range n.Channels {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+	return NotifyChannel{}, false
+}
+
+// Subscribes 通道是否订阅了该类型的事件
+func (c NotifyChannel) Subscribes(eventType string) bool {
+	events := c.Events
+	if len(events) == 0 {
+		events = DefaultNotifyEvents
+	}
+	for _, t := range events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 验证通知配置
+func (n NotifyConfig) Validate() error {
+	if n.RepeatSeconds < -1 {
+		return fmt.Errorf("repeat_seconds 不能小于 -1，当前值: %d", n.RepeatSeconds)
+	}
+
+	names := make(map[string]bool)
+	for i, channel := range n.Channels {
+		if channel.Name == "" {
+			return fmt.Errorf("channels[%d]: name 不能为空", i)
+		}
+		if names[channel.Name] {
+			return fmt.Errorf("通道名称重复: %s", channel.Name)
+		}
+		names[channel.Name] = true
+		if err := channel.Validate(); err != nil {
+			return fmt.Errorf("通道 %s: %w", channel.Name, err)
+		}
+	}
+
+	if n.Alerts.RenotifyHours < -1 {
+		return fmt.Errorf("alerts.renotify_hours 不能小于 -1，当前值: %d", n.Alerts.RenotifyHours)
+	}
+	if n.Alerts.EscalateAfterHours < 0 {
+		return fmt.Errorf("alerts.escalate_after_hours 不能为负数，当前值: %d", n.Alerts.EscalateAfterHours)
+	}
+	if n.Alerts.EscalateAfterHours > 0 && len(n.Alerts.EscalateTo) == 0 {
+		return errors.New("设置 alerts.escalate_after_hours 时需要指定 alerts.escalate_to")
+	}
+	for _, name := range n.Alerts.EscalateTo {
+		if !names[name] {
+			return fmt.Errorf("alerts.escalate_to 引用了未配置的通道: %s", name)
+		}
+	}
+
+	if _, err := schedule.Parse(n.Digest.Cron()); err != nil {
+		return fmt.Errorf("digest.schedule无效: %w", err)
+	}
+	if n.Digest.Top < 0 {
+		return fmt.Errorf("digest.top 不能为负数，当前值: %d", n.Digest.Top)
+	}
+	if n.Digest.QuotaPercent < 0 {
+		return fmt.Errorf("digest.quota_percent 不能为负数，当前值: %g", n.Digest.QuotaPercent)
+	}
+
+	for eventType, text := range n.Templates {
+		if !validNotifyEvent(eventType) {
+			return fmt.Errorf("templates 中不支持的事件类型: %s", eventType)
+		}
+		if _, err := template.New(eventType).Parse(text); err != nil {
+			return fmt.Errorf("templates.%s 模板无效: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// Validate 验证通知通道
+func (c NotifyChannel) Validate() error {
+	switch c.Type {
+	case NotifyTelegram:
+		if c.Token == "" || c.ChatID == "" {
+			return errors.New("telegram 通道需要 token 和 chat_id")
+		}
+	case NotifyGotify:
+		if c.URL == "" || c.Token == "" {
+			return errors.New("gotify 通道需要 url 和 token")
+		}
+		if c.Priority < 0 || c.Priority > 10 {
+			return fmt.Errorf("gotify 的 priority 必须在 0-10 之间，当前值: %d", c.Priority)
+		}
+	case NotifyNtfy:
+		if c.Topic == "" {
+			return errors.New("ntfy 通道需要 topic")
+		}
+		if c.Priority < 0 || c.Priority > 5 {
+			return fmt.Errorf("ntfy 的 priority 必须在 1-5 之间，当前值: %d", c.Priority)
+		}
+	case NotifyEmail:
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "" {
+			return fmt.Errorf("email 通道的 url 必须是 smtp://host:port 或 smtps://host:port，当前值: %s", c.URL)
+		}
+		if c.From == "" || len(c.To) == 0 {
+			return errors.New("email 通道需要 from 和 to")
+		}
+	case NotifyWebhook:
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook 通道的 url 无效: %s", c.URL)
+		}
+	default:
+		return fmt.Errorf("不支持的通道类型: %s (支持: telegram, gotify, ntfy, email, webhook)", c.Type)
+	}
+
+	for _, t := range c.Events {
+		if !validNotifyEvent(t) {
+			return fmt.Errorf("不支持的事件类型: %s", t)
+		}
+	}
+	return nil
+}
+
+// validNotifyEvent 事件类型是否可以发送通知
+func validNotifyEvent(eventType string) bool {
+	for _, t := range NotifyEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRuleNotify 验证规则引用的通知通道都已配置
+func (n NotifyConfig) ValidateRuleNotify(rule Rule) error {
+	for _, name := range rule.Notify {
+		if _, ok := n.Channel(name); !ok {
+			return fmt.Errorf("规则 %s 引用了未配置的通知通道: %s", rule.Name, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 上传/下载比例告警的默认值
+const (
+	DefaultRatioWindowMinutes = 30 // 统计窗口（分钟）
+	DefaultRatioMinUploadMbps = 1  // 窗口平均上传带宽低于该值时不判断（Mbps）
+)
+
+// MaxUploadRatio 上传与下载之比的上限（下载为 0 时记为该值）
+const MaxUploadRatio = 1000
+
+// RatioAlertConfig 上传/下载比例告警：窗口内上传持续远多于下载（被入侵后外传数据、做种的常见特征）时
+// 为虚拟机添加 tags.ratio 标签并发布 ratio_anomaly 事件，恢复正常后移除标签；不执行规则的操作
+type RatioAlertConfig struct {
+	Ratio         float64  `json:"ratio,omitempty"`           // 上传与下载之比的阈值（如 10 表示上传是下载的 10 倍，0 不检查）
+	WindowMinutes int      `json:"window_minutes,omitempty"`  // 统计窗口（分钟，默认 30）
+	MinUploadMbps float64  `json:"min_upload_mbps,omitempty"` // 窗口平均上传带宽低于该值时不判断（默认 1，避免流量很小时比例失真）
+	ExcludeTags   []string `json:"exclude_tags,omitempty"`    // 不检查带有这些标签的虚拟机（如备份、CDN 等正常以上传为主的虚拟机）
+}
+
+// Enabled 是否检查上传/下载比例
+func (r RatioAlertConfig) Enabled() bool {
+	return r.Ratio > 0
+}
+
+// Window 统计窗口
+func (r RatioAlertConfig) Window() time.Duration {
+	if r.WindowMinutes <= 0 {
+		return DefaultRatioWindowMinutes * time.Minute
+	}
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+// MinUpload 参与判断的最低平均上传带宽（Mbps）
+func (r RatioAlertConfig) MinUpload() float64 {
+	if r.MinUploadMbps <= 0 {
+		return DefaultRatioMinUploadMbps
+	}
+	return r.MinUploadMbps
+}
+
+// Excludes 虚拟机是否带有排除的标签（不区分大小写）
+func (r RatioAlertConfig) Excludes(vm VMInfo) bool {
+	for _, excluded := range r.ExcludeTags {
+		for _, tag := range vm.Tags {
+			if strings.EqualFold(tag, excluded) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Anomalous 窗口平均上传带宽（Mbps）和上传与下载之比是否构成比例异常
+func (r RatioAlertConfig) Anomalous(uploadMbps, ratio float64) bool {
+	return r.Enabled() && uploadMbps >= r.MinUpload() && ratio > r.Ratio
+}
+
+// Validate 验证上传/下载比例告警配置
+func (r RatioAlertConfig) Validate() error {
+	if r.Ratio < 0 || (r.Ratio > 0 && r.Ratio <= 1) {
+		return fmt.Errorf("ratio必须大于1（0 表示不检查），当前值: %v", r.Ratio)
+	}
+	if r.WindowMinutes < 0 {
+		return fmt.Errorf("window_minutes不能为负数，当前值: %d", r.WindowMinutes)
+	}
+	if r.MinUploadMbps < 0 {
+		return fmt.Errorf("min_upload_mbps不能为负数，当前值: %v", r.MinUploadMbps)
+	}
+	return nil
+}
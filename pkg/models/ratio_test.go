@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestRatioAlertAnomalous(t *testing.T) {
+	alert := RatioAlertConfig{Ratio: 10}
+	if alert.Anomalous(0.5, MaxUploadRatio) {
+		t.Fatal("upload below min_upload_mbps reported as anomalous")
+	}
+	if alert.Anomalous(5, 10) {
+		t.Fatal("ratio equal to threshold reported as anomalous")
+	}
+	if !alert.Anomalous(5, 12) {
+		t.Fatal("sustained 12:1 upload not reported")
+	}
+	if (RatioAlertConfig{}).Anomalous(5, 100) {
+		t.Fatal("disabled alert reported an anomaly")
+	}
+
+	if err := (RatioAlertConfig{Ratio: 1}).Validate(); err == nil {
+		t.Fatal("ratio 1 accepted")
+	}
+	vm := VMInfo{Tags: []string{"Backup"}}
+	if !(RatioAlertConfig{Ratio: 10, ExcludeTags: []string{"backup"}}).Excludes(vm) {
+		t.Fatal("exclude_tags should match case-insensitively")
+	}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// 远程写入默认值
+const (
+	DefaultRemoteWriteBatchSize    = 500
+	DefaultRemoteWriteFlushSeconds = 10
+	DefaultRemoteWriteMaxPending   = 100000
+	// RemoteWriteMaxBackoff 连续发送失败时重试间隔的上限
+	RemoteWriteMaxBackoff = 5 * time.Minute
+
+	// MaxIngestRecords /api/ingest 每次最多接收的记录数
+	MaxIngestRecords = 10000
+	// MaxIngestClockSkew 写入的记录时间最多可以比本机时间晚多少（来源主机的时钟误差）
+	MaxIngestClockSkew = 5 * time.Minute
+)
+
+// RemoteWriteConfig 远程写入：把采集的流量记录转发到另一个实例的 /api/ingest（留空 url 不启用）
+type RemoteWriteConfig struct {
+	URL          string `json:"url,omitempty"`           // 目标实例地址，如 https://central.example.com:8080
+	Token        string `json:"token,omitempty"`         // 目标实例的 API 令牌
+	Source       string `json:"source,omitempty"`        // 来源名称（默认 pve.node），目标实例按来源区分主机
+	BatchSize    int    `json:"batch_size,omitempty"`    // 每次发送的最多记录数（默认 500）
+	FlushSeconds int    `json:"flush_seconds,omitempty"` // 未凑满一批时最长等待时间（默认 10 秒）
+	MaxPending   int    `json:"max_pending,omitempty"`   // 目标不可用时最多保留的记录数（默认 100000，超出后丢弃最早的）
+}
+
+// IngestBatch 一次写入的流量记录（remote_write 发送、/api/ingest 接收）
+type IngestBatch struct {
+	Source  string          `json:"source"`
+	Records []TrafficRecord `json:"records"`
+}
+
+// Enabled 是否启用远程写入
+func (r RemoteWriteConfig) Enabled() bool {
+	return r.URL != ""
+}
+
+// Batch 每次发送的最多记录数
+func (r RemoteWriteConfig) Batch() int {
+	if r.BatchSize <= 0 {
+		return DefaultRemoteWriteBatchSize
+	}
+	return r.BatchSize
+}
+
+// FlushInterval 未凑满一批时最长等待时间
+func (r RemoteWriteConfig) FlushInterval() time.Duration {
+	if r.FlushSeconds <= 0 {
+		return DefaultRemoteWriteFlushSeconds * time.Second
+	}
+	return time.Duration(r.FlushSeconds) * time.Second
+}
+
+// MaxPendingRecords 目标不可用时最多保留的记录数
+func (r RemoteWriteConfig) MaxPendingRecords() int {
+	if r.MaxPending <= 0 {
+		return DefaultRemoteWriteMaxPending
+	}
+	return r.MaxPending
+}
+
+// Validate 验证远程写入配置
+func (r RemoteWriteConfig) Validate() error {
+	if !r.Enabled() {
+		return nil
+	}
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url无效: %s", r.URL)
+	}
+	if r.Token == "" {
+		return errors.New("token不能为空")
+	}
+	if r.BatchSize < 0 || r.FlushSeconds < 0 || r.MaxPending < 0 {
+		return errors.New("batch_size、flush_seconds和max_pending不能为负数")
+	}
+	if r.MaxPending > 0 && r.MaxPending < r.Batch() {
+		return fmt.Errorf("max_pending (%d) 不能小于batch_size (%d)", r.MaxPending, r.Batch())
+	}
+	return nil
+}
+
+// Validate 验证写入的记录（total_bytes 为 0 时视为 rx_bytes + tx_bytes）
+func (b IngestBatch) Validate(now time.Time) error {
+	if len(b.Records) == 0 {
+		return errors.New("records不能为空")
+	}
+	if len(b.Records) > MaxIngestRecords {
+		return fmt.Errorf("每次最多写入 %d 条记录，当前 %d 条", MaxIngestRecords, len(b.Records))
+	}
+	for i, record := range b.Records {
+		switch {
+		case record.VMID <= 0:
+			return fmt.Errorf("records[%d]: vmid无效: %d", i, record.VMID)
+		case record.Timestamp.IsZero():
+			return fmt.Errorf("records[%d]: timestamp不能为空", i)
+		case record.Timestamp.After(now.Add(MaxIngestClockSkew)):
+			return fmt.Errorf("records[%d]: timestamp %s 晚于当前时间", i, record.Timestamp.Format(time.RFC3339))
+		case record.TotalBytes != 0 && record.TotalBytes != record.RXBytes+record.TXBytes:
+			return fmt.Errorf("records[%d]: total_bytes 应等于 rx_bytes + tx_bytes", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PeriodRollingPrefix 滑动窗口周期前缀，如 rolling:7d（最近 7 天）、rolling:12h（最近 12 小时）
+const PeriodRollingPrefix = "rolling:"
+
+// ParseRollingPeriod 解析滑动窗口周期，返回窗口长度（单位支持 h、d，数值为正整数）
+func ParseRollingPeriod(period string) (time.Duration, bool) {
+	spec, ok := strings.CutPrefix(period, PeriodRollingPrefix)
+	if !ok || len(spec) < 2 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(spec[:len(spec)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	switch spec[len(spec)-1] {
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	default:
+		return 0, false
+	}
+}
+
+// minuteWindow minute 周期的统计窗口（monitor.minute_window_seconds）
+var minuteWindow atomic.Int64
+
+// SetMinuteWindow 设置 minute 周期的统计窗口（0 使用默认值），加载和重载配置时调用
+func SetMinuteWindow(d time.Duration) {
+	minuteWindow.Store(int64(d))
+}
+
+// MinuteWindow minute 周期的统计窗口：一个自然分钟内最多只有一次采样，无法计算差值，
+// 因此 minute 周期按最近一段时间（默认 5 分钟）的滑动窗口统计，与存储后端无关
+func MinuteWindow() time.Duration {
+	if d := time.Duration(minuteWindow.Load()); d > 0 {
+		return d
+	}
+	return DefaultMinuteWindowSeconds * time.Second
+}
+
+// RollingWindow 获取滑动窗口规则的窗口长度（非滑动窗口周期返回 false）
+// minute 周期同样是滑动窗口，长度为 MinuteWindow
+func (r *Rule) RollingWindow() (time.Duration, bool) {
+	if r.Period == PeriodMinute {
+		return MinuteWindow(), true
+	}
+	return ParseRollingPeriod(r.Period)
+}
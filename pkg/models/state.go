@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // VMState 虚拟机状态记录
 type VMState struct {
@@ -15,10 +18,27 @@ type VMState struct {
 	RuleName          string             `json:"rule_name"`                    // 触发的规则名称
 	NeedsRecovery     bool               `json:"needs_recovery"`               // 是否需要恢复
 	RecoveryTime      time.Time          `json:"recovery_time"`                // 计划恢复时间
+	RecoveryCanceled  bool               `json:"recovery_canceled,omitempty"`  // 已取消自动恢复（保持限制，直到重新安排恢复时间）
+	ExternalStatus    string             `json:"external_status,omitempty"`    // 限制期间被外部修改后的运行状态（为空表示没有被外部修改）
+	ExternalAt        time.Time          `json:"external_at,omitempty"`        // 检测到外部修改的时间
+}
+
+// ExpectedStatus 限制期间虚拟机应处的运行状态：关机/停止后为 stopped，其他操作不改变运行状态
+func (s VMState) ExpectedStatus() string {
+	if s.ActionTaken == ActionShutdown || s.ActionTaken == ActionStop {
+		return "stopped"
+	}
+	return s.OriginalStatus
+}
+
+// ExternallyModified 限制期间运行状态是否被外部修改过（如关机限制期间被手动开机，限速期间被手动关机）
+func (s VMState) ExternallyModified() bool {
+	return s.ExternalStatus != ""
 }
 
 // VMStateManager 虚拟机状态管理器
 type VMStateManager struct {
+	mu     sync.RWMutex
 	States map[int]*VMState `json:"states"` // VMID -> VMState
 }
 
@@ -31,22 +51,30 @@ func NewVMStateManager() *VMStateManager {
 
 // RecordState 记录虚拟机状态
 func (m *VMStateManager) RecordState(state *VMState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.States[state.VMID] = state
 }
 
 // GetState 获取虚拟机状态
 func (m *VMStateManager) GetState(vmid int) (*VMState, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	state, exists := m.States[vmid]
 	return state, exists
 }
 
 // RemoveState 移除虚拟机状态记录
 func (m *VMStateManager) RemoveState(vmid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	delete(m.States, vmid)
 }
 
 // GetAllStates 获取所有需要恢复的虚拟机状态
 func (m *VMStateManager) GetAllStates() []*VMState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	states := make([]*VMState, 0, len(m.States))
 	for _, state := range m.States {
 		states = append(states, state)
@@ -54,14 +82,86 @@ func (m *VMStateManager) GetAllStates() []*VMState {
 	return states
 }
 
-// GetRecoveryDueStates 获取到期需要恢复的虚拟机状态
+// GetRecoveryDueStates 获取到期需要恢复的虚拟机状态（已取消自动恢复的除外）
 func (m *VMStateManager) GetRecoveryDueStates() []*VMState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	now := time.Now()
 	states := make([]*VMState, 0)
 	for _, state := range m.States {
-		if state.NeedsRecovery && now.After(state.RecoveryTime) {
+		if state.NeedsRecovery && !state.RecoveryCanceled && now.After(state.RecoveryTime) {
 			states = append(states, state)
 		}
 	}
 	return states
 }
+
+// ExecutedAction 按规则执行过的操作（保存在虚拟机状态中，同一周期内不重复执行，不依赖 PVE 上的标签）
+type ExecutedAction struct {
+	Rule        string    `json:"rule"`
+	Action      string    `json:"action"`
+	PeriodStart time.Time `json:"period_start,omitempty"` // 执行时所在周期的开始时间（滑动窗口规则为零值）
+	ExecutedAt  time.Time `json:"executed_at"`
+	Count       int       `json:"count,omitempty"` // 本周期内（最近一次恢复之后）已执行的次数
+}
+
+// Executions 本周期内已执行的次数（旧记录没有计数，按 1 次计）
+func (e ExecutedAction) Executions() int {
+	if e.Count <= 0 {
+		return 1
+	}
+	return e.Count
+}
+
+// Covers 操作是否已在 periodStart 开始的周期内执行过，且执行后没有恢复过
+// 滑动窗口规则的周期随时间移动，periodStart 传零值，执行记录在恢复前一直有效
+func (e ExecutedAction) Covers(action string, periodStart, recoveredAt time.Time) bool {
+	return SameAction(e.Action, action) && e.ExecutedAt.After(recoveredAt) && e.PeriodStart.Equal(periodStart)
+}
+
+// PowerEvent 虚拟机运行状态的变化（每个采集周期检测一次，保存在虚拟机状态中）
+type PowerEvent struct {
+	Time time.Time `json:"time"` // 检测到变化的时间（实际变化发生在上一个采集周期之后）
+	From string    `json:"from"` // 之前的状态（running/stopped/paused）
+	To   string    `json:"to"`   // 变化后的状态
+}
+
+// 虚拟机当前的限制状态（Enforcement.State）
+const (
+	EnforcementNone         = "none"         // 没有被限制
+	EnforcementLimited      = "limited"      // 已限速
+	EnforcementDisconnected = "disconnected" // 已断网
+	EnforcementStopped      = "stopped"      // 已关机或停止
+)
+
+// EnforcementState 操作对应的限制状态
+func EnforcementState(action string) string {
+	switch action {
+	case ActionRateLimit:
+		return EnforcementLimited
+	case ActionDisconnect:
+		return EnforcementDisconnected
+	case ActionShutdown, ActionStop:
+		return EnforcementStopped
+	}
+	return EnforcementNone
+}
+
+// Enforcement 虚拟机当前被监控程序施加的限制（由恢复状态和操作日志组合而成）
+type Enforcement struct {
+	VMID        int        `json:"vmid"`
+	Enforced    bool       `json:"enforced"`                  // 是否处于限制中
+	State       string     `json:"state"`                     // none/limited/disconnected/stopped
+	Action      string     `json:"action,omitempty"`          // 执行的操作
+	Rule        string     `json:"rule,omitempty"`            // 触发的规则
+	Reason      string     `json:"reason,omitempty"`          // 触发原因（来自操作日志）
+	RateLimitMB float64    `json:"rate_limit_mb,omitempty"`   // 限速值（rate_limit 操作，来自规则配置）
+	Interfaces  []string   `json:"interfaces,omitempty"`      // 断网/限速作用的网卡（来自规则配置，空表示所有网卡）
+	Executions  int        `json:"executions,omitempty"`      // 本周期内按该规则执行的次数
+	Since       *time.Time `json:"since,omitempty"`           // 限制开始时间
+	RecoveryAt  *time.Time `json:"recovery_at,omitempty"`     // 计划恢复时间
+	RecoveredAt *time.Time `json:"recovered_at,omitempty"`    // 最近一次恢复的时间
+	External    string     `json:"external_status,omitempty"` // 限制期间被外部修改后的运行状态（恢复时不改变运行状态）
+	ExternalAt  *time.Time `json:"external_at,omitempty"`     // 检测到外部修改的时间
+	LastAction  *ActionLog `json:"last_action,omitempty"`     // 造成当前限制的最近一次操作日志
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pveTagPattern PVE 标签允许的字符（小写形式）
+var pveTagPattern = regexp.MustCompile(`^[a-z0-9_][a-z0-9_+.-]*$`)
+
+// ValidPVETag 是否为合法的 PVE 标签（字母、数字和 _ + . -，不能以 + . - 开头）
+func ValidPVETag(tag string) bool {
+	return pveTagPattern.MatchString(strings.ToLower(tag))
+}
+
+// TagConfig 操作标签配置（完整标签 = prefix + 名称）
+type TagConfig struct {
+	Prefix     string `json:"prefix,omitempty"`     // 标签命名空间（默认 traffic-），恢复和退出时清理该前缀的所有标签
+	Shutdown   string `json:"shutdown,omitempty"`   // 关机/强制停止（默认 exceeded-shutdown）
+	Disconnect string `json:"disconnect,omitempty"` // 断网（默认 exceeded-disconnected）
+	Limited    string `json:"limited,omitempty"`    // 限速（默认 exceeded-limited）
+	Ratio      string `json:"ratio,omitempty"`      // 上传/下载比例异常（默认 suspicious-upload）
+}
+
+// withDefaults 填充未配置的名称
+func (t TagConfig) withDefaults() TagConfig {
+	if t.Prefix == "" {
+		t.Prefix = DefaultTagPrefix
+	}
+	if t.Shutdown == "" {
+		t.Shutdown = DefaultTagShutdown
+	}
+	if t.Disconnect == "" {
+		t.Disconnect = DefaultTagDisconnect
+	}
+	if t.Limited == "" {
+		t.Limited = DefaultTagLimited
+	}
+	if t.Ratio == "" {
+		t.Ratio = DefaultTagRatio
+	}
+	return t
+}
+
+// ActionTag 获取操作对应的完整标签（小写），未知操作返回空字符串
+func (t TagConfig) ActionTag(action string) string {
+	t = t.withDefaults()
+
+	var name string
+	switch action {
+	case ActionShutdown, ActionStop:
+		name = t.Shutdown
+	case ActionDisconnect:
+		name = t.Disconnect
+	case ActionRateLimit:
+		name = t.Limited
+	default:
+		return ""
+	}
+	return strings.ToLower(t.Prefix + name)
+}
+
+// RatioTag 上传/下载比例异常期间添加的完整标签（小写）
+func (t TagConfig) RatioTag() string {
+	t = t.withDefaults()
+	return strings.ToLower(t.Prefix + t.Ratio)
+}
+
+// RuleTag 规则的流量状态标签（小写，规则名中的空格替换为 -），默认前缀下为 traffic-limit-<规则名>
+func (t TagConfig) RuleTag(ruleName string) string {
+	t = t.withDefaults()
+	return strings.ToLower(t.Prefix + TagRuleLimit + strings.ReplaceAll(ruleName, " ", "-"))
+}
+
+// IsManaged 标签是否属于监控程序的命名空间（恢复和退出时清理）
+// 旧版本的 traffic-limit-<规则名> 标签在自定义前缀时也属于命名空间，以便清理
+func (t TagConfig) IsManaged(tag string) bool {
+	lower := strings.ToLower(tag)
+	return strings.HasPrefix(lower, strings.ToLower(t.withDefaults().Prefix)) || strings.HasPrefix(lower, LegacyRuleTagPrefix)
+}
+
+// TagsManaged 是否由监控程序写入和清理 PVE 标签（未配置时默认启用）
+// 关闭后操作是否已执行由恢复状态和操作日志判断
+func (m MonitorConfig) TagsManaged() bool {
+	return m.ManageTags == nil || *m.ManageTags
+}
@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestTagConfigActionTag(t *testing.T) {
+	var defaults TagConfig
+	if got := defaults.ActionTag(ActionStop); got != "traffic-exceeded-shutdown" {
+		t.Fatalf("ActionTag(stop) = %q, want traffic-exceeded-shutdown", got)
+	}
+
+	custom := TagConfig{Prefix: "Quota.", Limited: "slow"}
+	if got := custom.ActionTag(ActionRateLimit); got != "quota.slow" {
+		t.Fatalf("ActionTag(rate_limit) = %q, want quota.slow", got)
+	}
+	if !custom.IsManaged("quota.exceeded-disconnected") || custom.IsManaged("traffic-exceeded-shutdown") {
+		t.Fatalf("IsManaged does not follow the configured prefix")
+	}
+}
+
+func TestTagConfigRuleTag(t *testing.T) {
+	var defaults TagConfig
+	if got := defaults.RuleTag("Monthly Quota"); got != "traffic-limit-monthly-quota" {
+		t.Fatalf("RuleTag() = %q, want the pre-prefix name traffic-limit-monthly-quota", got)
+	}
+
+	custom := TagConfig{Prefix: "quota."}
+	if got := custom.RuleTag("monthly"); got != "quota.limit-monthly" || !custom.IsManaged(got) {
+		t.Fatalf("RuleTag() = %q, want quota.limit-monthly inside the namespace", got)
+	}
+	// 旧版本固定前缀的规则标签仍然属于命名空间，恢复和退出时清理
+	if !custom.IsManaged("traffic-limit-monthly") {
+		t.Fatalf("IsManaged(traffic-limit-monthly) = false, want legacy rule tags cleaned")
+	}
+}
+
+func TestTagConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    TagConfig
+		wantErr bool
+	}{
+		{name: "defaults", tags: TagConfig{}},
+		{name: "custom", tags: TagConfig{Prefix: "quota_", Shutdown: "off"}},
+		{name: "invalid charset", tags: TagConfig{Prefix: "traffic "}, wantErr: true},
+		{name: "leading dash", tags: TagConfig{Prefix: "-x"}, wantErr: true},
+		{name: "duplicate", tags: TagConfig{Shutdown: "hit", Limited: "HIT"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tags.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMonitorConfigTagsManaged(t *testing.T) {
+	disabled := false
+	if !(MonitorConfig{}).TagsManaged() {
+		t.Fatalf("TagsManaged() = false, want true by default")
+	}
+	if (MonitorConfig{ManageTags: &disabled}).TagsManaged() {
+		t.Fatalf("TagsManaged() = true, want false when manage_tags=false")
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "strings"
+
+// 模板虚拟机的处理方式
+const (
+	TemplatesExclude = "exclude" // 不采集模板（默认）
+	TemplatesRecord  = "record"  // 采集模板的流量（如克隆期间），规则不作用于模板
+	TemplatesEnforce = "enforce" // 采集模板的流量，规则和普通虚拟机一样作用于模板
+)
+
+// ValidTemplateMode 是否为支持的模板处理方式（空值表示按 include_templates 决定）
+func ValidTemplateMode(mode string) bool {
+	switch strings.ToLower(mode) {
+	case "", TemplatesExclude, TemplatesRecord, TemplatesEnforce:
+		return true
+	}
+	return false
+}
+
+// TemplateMode 获取模板处理方式（未配置 templates 时，include_templates: true 等同于 record）
+func (m MonitorConfig) TemplateMode() string {
+	if m.Templates != "" {
+		return strings.ToLower(m.Templates)
+	}
+	if m.IncludeTemplates {
+		return TemplatesRecord
+	}
+	return TemplatesExclude
+}
+
+// ListsTemplates 获取虚拟机列表时是否包含模板
+func (m MonitorConfig) ListsTemplates() bool {
+	return m.TemplateMode() != TemplatesExclude
+}
+
+// AppliesToTemplates 规则是否作用于模板（规则的 include_templates 优先于 mode，mode 为 exclude 时模板不会被采集）
+func (r Rule) AppliesToTemplates(mode string) bool {
+	if mode == TemplatesExclude {
+		return false
+	}
+	if r.IncludeTemplates != nil {
+		return *r.IncludeTemplates
+	}
+	return mode == TemplatesEnforce
+}
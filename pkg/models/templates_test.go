@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestTemplateMode(t *testing.T) {
+	if mode := (MonitorConfig{}).TemplateMode(); mode != TemplatesExclude {
+		t.Fatalf("TemplateMode() = %s, want exclude by default", mode)
+	}
+	if mode := (MonitorConfig{IncludeTemplates: true}).TemplateMode(); mode != TemplatesRecord {
+		t.Fatalf("TemplateMode() = %s, want record for include_templates", mode)
+	}
+	if mode := (MonitorConfig{IncludeTemplates: true, Templates: "Enforce"}).TemplateMode(); mode != TemplatesEnforce {
+		t.Fatalf("TemplateMode() = %s, want templates to take precedence", mode)
+	}
+
+	on, off := true, false
+	cases := []struct {
+		mode string
+		rule Rule
+		want bool
+	}{
+		{TemplatesRecord, Rule{}, false},
+		{TemplatesRecord, Rule{IncludeTemplates: &on}, true},
+		{TemplatesEnforce, Rule{}, true},
+		{TemplatesEnforce, Rule{IncludeTemplates: &off}, false},
+		{TemplatesExclude, Rule{IncludeTemplates: &on}, false},
+	}
+	for _, c := range cases {
+		if got := c.rule.AppliesToTemplates(c.mode); got != c.want {
+			t.Errorf("AppliesToTemplates(%s) with include_templates=%v = %v, want %v", c.mode, c.rule.IncludeTemplates, got, c.want)
+		}
+	}
+}
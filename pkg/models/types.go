@@ -1,47 +1,408 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"os"
+	"time"
+)
 
 // Config 主配置结构
 type Config struct {
-	PVE     PVEConfig     `json:"pve"`
-	Monitor MonitorConfig `json:"monitor"`
-	Storage StorageConfig `json:"storage"`
-	Rules   []Rule        `json:"rules"`
-	API     APIConfig     `json:"api"`
+	PVE      PVEConfig     `json:"pve"`
+	Monitor  MonitorConfig `json:"monitor"`
+	Storage  StorageConfig `json:"storage"`
+	Rules    []Rule        `json:"rules"`
+	API      APIConfig     `json:"api"`
+	Tenants  TenantConfig  `json:"tenants"`
+	Locale   string        `json:"locale,omitempty"`   // 界面和日志语言: zh-CN/en-US（默认 zh-CN）
+	Timezone string        `json:"timezone,omitempty"` // 周期边界和图表时间的时区（IANA 名称，默认主机本地时区）
+
+	// 外部密钥：字符串值中的 ${secret:名称} 替换为对应密钥的内容
+	Secrets map[string]SecretConfig `json:"secrets,omitempty"`
+	Vault   VaultConfig             `json:"vault,omitempty"` // provider 为 vault 的密钥使用的 Vault 连接
+
+	// 运行模式：standalone（默认）、agent、aggregator
+	Mode       string           `json:"mode,omitempty"`
+	Agent      AgentConfig      `json:"agent,omitempty"`      // mode=agent 时的汇总端连接
+	Aggregator AggregatorConfig `json:"aggregator,omitempty"` // mode=aggregator 时接收代理推送的设置
+
+	// 远程写入：采集的流量记录同时转发到另一个实例
+	RemoteWrite RemoteWriteConfig `json:"remote_write,omitempty"`
+
+	// 行协议推送：每个采集周期把虚拟机的流量增量以 Influx 行协议发送到 Telegraf 等
+	LineProtocol LineProtocolConfig `json:"line_protocol,omitempty"`
+
+	// 月度归档：已结束月份的流量记录上传到对象存储后从本地删除
+	Archive ArchiveConfig `json:"archive,omitempty"`
+
+	// 内部事件：预警阈值和审计日志
+	Events EventsConfig `json:"events,omitempty"`
+
+	// 通知：预警、操作和恢复事件发送到 Telegram、Gotify、ntfy
+	Notify NotifyConfig `json:"notify,omitempty"`
+}
+
+// SecretConfig 外部密钥来源（令牌、数据库密码等不直接写在配置文件中）
+type SecretConfig struct {
+	Provider string `json:"provider"`       // 来源: file/systemd/vault
+	Path     string `json:"path,omitempty"` // file: 文件路径; vault: 密钥路径（如 secret/data/pve-monitor）
+	Name     string `json:"name,omitempty"` // systemd: 凭据名称（LoadCredential= 中的名称）
+	Key      string `json:"key,omitempty"`  // vault: 密钥中的字段名
 }
 
-// PVEConfig PVE 连接配置（使用API Token认证）
+// VaultConfig HashiCorp Vault 连接配置
+type VaultConfig struct {
+	Address        string `json:"address,omitempty"`         // Vault 地址（默认环境变量 VAULT_ADDR）
+	Token          string `json:"token,omitempty"`           // 访问令牌（默认环境变量 VAULT_TOKEN）
+	TokenFile      string `json:"token_file,omitempty"`      // 从文件读取访问令牌（如 Vault Agent 写入的 sink 文件）
+	Namespace      string `json:"namespace,omitempty"`       // Vault 企业版命名空间
+	RefreshSeconds int    `json:"refresh_seconds,omitempty"` // 重新读取密钥的间隔（秒，默认300），用于密钥轮换
+}
+
+// RefreshInterval 获取 Vault 密钥刷新间隔
+func (v VaultConfig) RefreshInterval() time.Duration {
+	if v.RefreshSeconds <= 0 {
+		return DefaultVaultRefreshSeconds * time.Second
+	}
+	return time.Duration(v.RefreshSeconds) * time.Second
+}
+
+// UsesVault 是否有密钥从 Vault 读取（需要定期刷新）
+func (c *Config) UsesVault() bool {
+	for _, secret := range c.Secrets {
+		if secret.Provider == SecretProviderVault {
+			return true
+		}
+	}
+	return false
+}
+
+// PVEConfig PVE 连接配置（API Token 认证，或无法创建 API Token 时使用用户名密码登录获取票据）
 type PVEConfig struct {
-	Host           string `json:"host"`             // PVE主机地址（默认 localhost）
-	Port           int    `json:"port"`             // PVE端口（默认 8006）
-	Node           string `json:"node"`             // 节点名称
-	APITokenID     string `json:"api_token_id"`     // API Token ID (格式: user@realm!tokenid)
-	APITokenSecret string `json:"api_token_secret"` // API Token Secret (UUID格式)
+	Host           string `json:"host"`                  // PVE主机地址（默认 localhost）
+	Port           int    `json:"port"`                  // PVE端口（默认 8006）
+	Node           string `json:"node"`                  // 节点名称
+	AuthMethod     string `json:"auth_method,omitempty"` // 认证方式: token/ticket（默认 token，只配置了 username 时为 ticket）
+	APITokenID     string `json:"api_token_id"`          // API Token ID (格式: user@realm!tokenid)
+	APITokenSecret string `json:"api_token_secret"`      // API Token Secret (UUID格式)
+	Username       string `json:"username,omitempty"`    // 票据认证用户名 (格式: user@realm，如 monitor@pve)
+	Password       string `json:"password,omitempty"`    // 票据认证密码
+
+	// 证书验证（都不配置时不验证证书，仅适合访问本机）
+	VerifyTLS   bool   `json:"verify_tls,omitempty"`  // 使用系统 CA 验证证书
+	CACert      string `json:"ca_cert,omitempty"`     // 使用指定的 CA 证书文件验证（PEM，如 /etc/pve/pve-root-ca.pem）
+	Fingerprint string `json:"fingerprint,omitempty"` // 固定证书的 SHA-256 指纹（如 pvenode cert info 输出的 AA:BB:...）
+}
+
+// TLSVerified 是否验证 PVE 证书
+func (p PVEConfig) TLSVerified() bool {
+	return p.VerifyTLS || p.CACert != "" || p.Fingerprint != ""
+}
+
+// AuthMode 获取实际使用的认证方式
+func (p PVEConfig) AuthMode() string {
+	if p.AuthMethod != "" {
+		return p.AuthMethod
+	}
+	if p.APITokenID == "" && p.Username != "" {
+		return PVEAuthTicket
+	}
+	return PVEAuthToken
 }
 
 // MonitorConfig 监控配置
 type MonitorConfig struct {
-	IntervalSeconds   int    `json:"interval_seconds"`
-	ExportPath        string `json:"export_path"`
-	IncludeTemplates  bool   `json:"include_templates,omitempty"`   // 是否包含模板虚拟机（默认 false）
-	DataRetentionDays int    `json:"data_retention_days,omitempty"` // 数据保留天数（0=永久保留，默认90天）
+	IntervalSeconds   int       `json:"interval_seconds"`
+	ExportPath        string    `json:"export_path"`
+	IncludeTemplates  bool      `json:"include_templates,omitempty"`    // 是否包含模板虚拟机（默认 false，templates 未配置时 true 等同于 record）
+	Templates         string    `json:"templates,omitempty"`            // 模板处理方式: exclude(默认), record, enforce
+	DataRetentionDays int       `json:"data_retention_days,omitempty"`  // 数据保留天数（0=永久保留，默认90天）
+	DiagnosticsDir    string    `json:"diagnostics_dir,omitempty"`      // SIGUSR1 诊断信息输出目录（留空则输出到日志）
+	Tags              TagConfig `json:"tags,omitempty"`                 // 操作标签名称
+	ManageTags        *bool     `json:"manage_tags,omitempty"`          // 是否写入/清理 PVE 标签（默认 true）
+	Marker            string    `json:"marker,omitempty"`               // 限制状态标记方式: tags(默认), description
+	TaskTimeout       int       `json:"task_timeout_seconds,omitempty"` // 等待关机/停止/启动任务完成的秒数（默认180）
+	NodeStats         *bool     `json:"node_stats,omitempty"`           // 是否采集 PVE 节点自身的网卡流量（默认 true）
+
+	// 导出文件的远程目标（S3 或 WebDAV），export_destination 为默认目标的名称（留空导出到 export_path）
+	ExportDestinations []ExportDestination `json:"export_destinations,omitempty"`
+	ExportDestination  string              `json:"export_destination,omitempty"`
+
+	Bridges   BridgeFilter    `json:"bridges,omitempty"`   // 只统计指定网桥上的网卡流量（默认统计所有网卡）
+	Retention RetentionConfig `json:"retention,omitempty"` // 流量记录以外的数据的保留天数
+
+	CleanupSchedule string `json:"cleanup_schedule,omitempty"` // 旧数据清理时间（cron 表达式，默认每天凌晨 3 点）
+
+	HA HAConfig `json:"ha,omitempty"` // 主备模式
+
+	// 退出时是否恢复被限制的虚拟机（默认 true；false 时保留限制和恢复记录，重启后继续按计划恢复）
+	RecoverOnExit *bool `json:"recover_on_exit,omitempty"`
+
+	Actions ActionQueueConfig `json:"actions,omitempty"` // 操作执行队列
+
+	// 运行中的虚拟机超过多少个采集间隔没有成功采样时告警（默认 3，-1 不检查）
+	GapIntervals int `json:"gap_intervals,omitempty"`
+
+	// 已停止的虚拟机每隔多少个采集间隔采样一次（默认 1 即每次都采样，-1 只在停止时采样一次）
+	// 停止的虚拟机流量不变，减少采样可以降低 PVE 接口调用和存储的记录数
+	StoppedIntervals int `json:"stopped_intervals,omitempty"`
+
+	// 通过 QEMU 客户机代理采集按协议（IPv4/IPv6）的累计流量，供 metric=ipv4/ipv6 的规则和图表使用（默认 false）
+	// 只支持 Linux 客户机；没有运行代理的虚拟机采集失败后暂停一段时间再尝试
+	IPSplit bool `json:"ip_split,omitempty"`
+
+	// 备份（vzdump）期间流量的处理方式: count(默认，不检测), tag(标记记录), exclude(标记并不计入用量)
+	// 按节点运行中的 vzdump 任务和虚拟机的 backup 配置锁判断，适用于备份流量经过虚拟机网卡计数的场景
+	BackupTraffic string `json:"backup_traffic,omitempty"`
+
+	// 上传/下载比例告警（ratio 未配置时不检查）
+	RatioAlert RatioAlertConfig `json:"ratio_alert,omitempty"`
+
+	// minute 周期的统计窗口（秒，默认 300）：按最近这段时间的滑动窗口统计，所有存储后端相同
+	MinuteWindowSeconds int `json:"minute_window_seconds,omitempty"`
+
+	// 同一虚拟机同时超出多条限制规则时的处理策略: most_severe(默认), first_match
+	ConflictPolicy string `json:"conflict_policy,omitempty"`
+}
+
+// ActionQueueConfig 操作执行队列：规则判断超限后把操作交给队列执行，限制同时发往 PVE 的操作数
+type ActionQueueConfig struct {
+	Concurrency       int  `json:"concurrency,omitempty"`         // 同时执行的操作数（默认 4）
+	MaxRetries        *int `json:"max_retries,omitempty"`         // 失败后的重试次数（默认 2，0 表示不重试）
+	RetryDelaySeconds int  `json:"retry_delay_seconds,omitempty"` // 首次重试等待的秒数（默认 30，之后每次翻倍）
+}
+
+// Workers 同时执行的操作数
+func (a ActionQueueConfig) Workers() int {
+	if a.Concurrency <= 0 {
+		return DefaultActionConcurrency
+	}
+	return a.Concurrency
+}
+
+// Retries 失败后的重试次数
+func (a ActionQueueConfig) Retries() int {
+	if a.MaxRetries == nil {
+		return DefaultActionRetries
+	}
+	return *a.MaxRetries
+}
+
+// RetryDelay 首次重试的等待时间
+func (a ActionQueueConfig) RetryDelay() time.Duration {
+	if a.RetryDelaySeconds <= 0 {
+		return DefaultActionRetryDelaySeconds * time.Second
+	}
+	return time.Duration(a.RetryDelaySeconds) * time.Second
+}
+
+// Validate 验证操作执行队列配置
+func (a ActionQueueConfig) Validate() error {
+	if a.Concurrency < 0 || a.Concurrency > MaxActionConcurrency {
+		return fmt.Errorf("concurrency 必须在 0-%d 之间，当前值: %d", MaxActionConcurrency, a.Concurrency)
+	}
+	if a.MaxRetries != nil && (*a.MaxRetries < 0 || *a.MaxRetries > MaxActionRetries) {
+		return fmt.Errorf("max_retries 必须在 0-%d 之间，当前值: %d", MaxActionRetries, *a.MaxRetries)
+	}
+	if a.RetryDelaySeconds < 0 {
+		return fmt.Errorf("retry_delay_seconds 不能为负数，当前值: %d", a.RetryDelaySeconds)
+	}
+	return nil
+}
+
+// HAConfig 主备模式：多个实例共用同一存储，只有持有租约的主实例采集数据、执行和恢复操作，所有实例都提供只读 API
+type HAConfig struct {
+	Enabled      bool   `json:"enabled"`
+	InstanceID   string `json:"instance_id,omitempty"`   // 实例标识（默认主机名，各实例必须不同）
+	LeaseSeconds int    `json:"lease_seconds,omitempty"` // 租约时长（默认 30 秒），主实例每 1/3 租约时长续期
+}
+
+// ID 实例标识（未配置时为主机名）
+func (h HAConfig) ID() string {
+	if h.InstanceID != "" {
+		return h.InstanceID
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// LeaseDuration 租约时长
+func (h HAConfig) LeaseDuration() time.Duration {
+	if h.LeaseSeconds <= 0 {
+		return DefaultHALeaseSeconds * time.Second
+	}
+	return time.Duration(h.LeaseSeconds) * time.Second
+}
+
+// RetentionConfig 按数据类型的保留天数（0=永久保留），由每天的旧数据清理执行
+type RetentionConfig struct {
+	ActionLogDays int `json:"action_logs_days,omitempty"` // 操作日志
+	StateDays     int `json:"states_days,omitempty"`      // 已不存在的虚拟机的状态（按最后更新时间计算）
+}
+
+// TaskWait 等待 PVE 异步任务完成的超时时间
+func (m MonitorConfig) TaskWait() time.Duration {
+	if m.TaskTimeout <= 0 {
+		return DefaultTaskTimeoutSeconds * time.Second
+	}
+	return time.Duration(m.TaskTimeout) * time.Second
+}
+
+// CleanupCron 旧数据清理的 cron 表达式（未配置时为 DefaultCleanupSchedule）
+func (m MonitorConfig) CleanupCron() string {
+	if m.CleanupSchedule == "" {
+		return DefaultCleanupSchedule
+	}
+	return m.CleanupSchedule
+}
+
+// RecoversOnExit 退出时是否恢复被限制的虚拟机（未配置时默认恢复）
+func (m MonitorConfig) RecoversOnExit() bool {
+	return m.RecoverOnExit == nil || *m.RecoverOnExit
+}
+
+// GapThreshold 采集中断告警的阈值（0 表示不检查）
+func (m MonitorConfig) GapThreshold() time.Duration {
+	switch {
+	case m.GapIntervals < 0:
+		return 0
+	case m.GapIntervals == 0:
+		return time.Duration(DefaultGapIntervals*m.IntervalSeconds) * time.Second
+	default:
+		return time.Duration(m.GapIntervals*m.IntervalSeconds) * time.Second
+	}
+}
+
+// StoppedSampleInterval 已停止的虚拟机的采样间隔（0 表示只在停止时采样一次）
+func (m MonitorConfig) StoppedSampleInterval() time.Duration {
+	switch {
+	case m.StoppedIntervals < 0:
+		return 0
+	case m.StoppedIntervals == 0:
+		return time.Duration(m.IntervalSeconds) * time.Second
+	default:
+		return time.Duration(m.StoppedIntervals*m.IntervalSeconds) * time.Second
+	}
+}
+
+// MinuteWindow minute 周期的统计窗口
+func (m MonitorConfig) MinuteWindow() time.Duration {
+	if m.MinuteWindowSeconds <= 0 {
+		return DefaultMinuteWindowSeconds * time.Second
+	}
+	return time.Duration(m.MinuteWindowSeconds) * time.Second
+}
+
+// NodeStatsEnabled 是否采集节点网卡流量（未配置时默认启用）
+func (m MonitorConfig) NodeStatsEnabled() bool {
+	return m.NodeStats == nil || *m.NodeStats
 }
 
 // Rule 流量规则
 type Rule struct {
-	Name             string   `json:"name"`
-	Enabled          bool     `json:"enabled"`
-	Period           string   `json:"period"`                      // hour, day, month
-	UseCreationTime  bool     `json:"use_creation_time,omitempty"` // 是否使用虚拟机创建时间作为周期基准
-	TrafficDirection string   `json:"traffic_direction,omitempty"` // both, upload, download (默认 both)
-	LimitGB          float64  `json:"limit_gb"`
-	Action           string   `json:"action"`                  // shutdown, stop, disconnect, rate_limit
-	ForceStop        bool     `json:"force_stop,omitempty"`    // 是否强制停止（仅当 action=shutdown 时有效）
-	RateLimitMB      float64  `json:"rate_limit_mb,omitempty"` // 限速值 MB/s（用于 rate_limit，支持小数）
-	VMIDs            []int    `json:"vm_ids"`
-	VMTags           []string `json:"vm_tags"`
-	ExcludeVMIDs     []int    `json:"exclude_vm_ids"`
+	Name              string   `json:"name"`
+	Enabled           bool     `json:"enabled"`
+	Priority          int      `json:"priority,omitempty"`            // 优先级（越大越先检查，默认 0，相同时按配置顺序）
+	Type              string   `json:"type,omitempty"`                // volume(默认), rate
+	Period            string   `json:"period"`                        // hour, day, month（rate 规则用于决定恢复时间）
+	UseCreationTime   bool     `json:"use_creation_time,omitempty"`   // 是否使用虚拟机创建时间作为周期基准
+	Timezone          string   `json:"timezone,omitempty"`            // 周期边界时区（IANA 名称，默认使用全局 timezone）
+	AnchorDay         int      `json:"anchor_day,omitempty"`          // 月周期的账单日 1-31（默认月初，大于当月天数时为月末）
+	AnchorHour        int      `json:"anchor_hour,omitempty"`         // 账单日的重置小时 0-23（默认 0）
+	TrafficDirection  string   `json:"traffic_direction,omitempty"`   // both, upload, download (默认 both)
+	LimitGB           float64  `json:"limit_gb"`                      // 流量限制 GB（type=volume 时使用）
+	RateThresholdMbps float64  `json:"rate_threshold_mbps,omitempty"` // 平均带宽阈值 Mbps（type=rate/percentile 时使用）
+	RateWindowMinutes int      `json:"rate_window_minutes,omitempty"` // 带宽统计窗口（分钟，默认5）
+	Metric            string   `json:"metric,omitempty"`              // 统计对象: network(默认), disk（仅 rate，rx=读取, tx=写入）, ipv4, ipv6
+	Action            string   `json:"action"`                        // shutdown, stop, disconnect, rate_limit, exec
+	ForceStop         bool     `json:"force_stop,omitempty"`          // 是否强制停止（仅当 action=shutdown 时有效）
+	RateLimitMB       float64  `json:"rate_limit_mb,omitempty"`       // 限速值 MB/s（用于 rate_limit，支持小数）
+	Interfaces        []string `json:"interfaces,omitempty"`          // 断网/限速作用的网卡（net0 或网桥名 vmbr0，默认所有网卡）
+	CooldownMinutes   int      `json:"cooldown_minutes,omitempty"`    // 同一规则两次执行之间的最小间隔（分钟，默认 0 不限制）
+	MaxExecutions     int      `json:"max_executions,omitempty"`      // 每个周期内最多执行的次数（默认 1，大于 1 时限制被手动解除后再次执行）
+	VMIDs             []int    `json:"vm_ids"`
+	VMTags            []string `json:"vm_tags"`
+	ExcludeVMIDs      []int    `json:"exclude_vm_ids"`
+	Scope             string   `json:"scope,omitempty"`             // vm(默认), host（按匹配虚拟机的流量总和判断）
+	Target            string   `json:"target,omitempty"`            // host 规则超限时的操作对象: top_talkers(默认)
+	TopN              int      `json:"top_n,omitempty"`             // top_talkers 限制的虚拟机数（默认 3）
+	IncludeTemplates  *bool    `json:"include_templates,omitempty"` // 是否作用于模板（默认按 monitor.templates，enforce 时作用）
+
+	Exec       *ExecConfig `json:"exec,omitempty"`        // action=exec 时执行的命令
+	PostAction *ExecConfig `json:"post_action,omitempty"` // 操作执行后（无论成功与否）运行的钩子命令
+
+	Notify []string `json:"notify,omitempty"` // 发送通知的通道名称（默认所有通道）
+}
+
+// Cooldown 同一规则两次执行之间的最小间隔（0 表示不限制）
+func (r *Rule) Cooldown() time.Duration {
+	if r.CooldownMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(r.CooldownMinutes) * time.Minute
+}
+
+// ExecutionLimit 每个周期内最多执行的次数（默认 1）
+func (r *Rule) ExecutionLimit() int {
+	if r.MaxExecutions <= 0 {
+		return 1
+	}
+	return r.MaxExecutions
+}
+
+// IsRateRule 检查是否为带宽（速率）规则
+func (r *Rule) IsRateRule() bool {
+	return r.Type == RuleTypeRate
+}
+
+// IsPercentileRule 检查是否为 95 百分位带宽规则
+func (r *Rule) IsPercentileRule() bool {
+	return r.Type == RuleTypePercentile
+}
+
+// IsHostRule 检查是否为主机级规则（按匹配虚拟机的用量总和判断）
+func (r *Rule) IsHostRule() bool {
+	return r.Scope == RuleScopeHost
+}
+
+// TopTalkers 主机规则超限时限制的虚拟机数
+func (r *Rule) TopTalkers() int {
+	if r.TopN <= 0 {
+		return DefaultTopTalkers
+	}
+	return r.TopN
+}
+
+// IsDiskRule 检查是否为磁盘吞吐规则（按磁盘读写速率而不是网络带宽）
+func (r *Rule) IsDiskRule() bool {
+	return r.IsRateRule() && r.Metric == MetricDisk
+}
+
+// IsProtocolRule 检查是否只统计客户机报告的 IPv4 或 IPv6 流量
+func (r *Rule) IsProtocolRule() bool {
+	return IsProtocolMetric(r.Metric)
+}
+
+// ValidMetric 是否为支持的统计对象（空值表示默认的 network）
+func ValidMetric(metric string) bool {
+	switch metric {
+	case "", MetricNetwork, MetricDisk, MetricIPv4, MetricIPv6:
+		return true
+	}
+	return false
+}
+
+// IsProtocolMetric 是否为按协议统计的对象（ipv4、ipv6）
+func IsProtocolMetric(metric string) bool {
+	return metric == MetricIPv4 || metric == MetricIPv6
+}
+
+// RateWindow 获取带宽规则的统计窗口
+func (r *Rule) RateWindow() time.Duration {
+	if r.RateWindowMinutes <= 0 {
+		return DefaultRateWindowMinutes * time.Minute
+	}
+	return time.Duration(r.RateWindowMinutes) * time.Minute
 }
 
 // StorageConfig 存储配置
@@ -54,14 +415,86 @@ type StorageConfig struct {
 	MaxOpenConns    int    `json:"max_open_conns,omitempty"`    // 最大打开连接数(默认10)
 	MaxIdleConns    int    `json:"max_idle_conns,omitempty"`    // 最大空闲连接数(默认5)
 	ConnMaxLifetime int    `json:"conn_max_lifetime,omitempty"` // 连接最大生命周期(秒,默认3600)
+	// 本地缓冲（存储不可用时暂存流量记录，恢复后重放）
+	SpoolDir        string `json:"spool_dir,omitempty"`         // 缓冲目录（留空不启用）
+	SpoolMaxRecords int    `json:"spool_max_records,omitempty"` // 最多缓冲的记录数(默认100000)
+	// 副本存储（双写：写入同时发送到副本，主存储读取失败时切换到副本）
+	Replica *StorageConfig `json:"replica,omitempty"`
+
+	// 慢查询日志阈值（毫秒，默认 1000，-1 不记录；副本中的设置不生效）
+	SlowQueryMs int `json:"slow_query_ms,omitempty"`
+}
+
+// SlowQueryThreshold 记录慢查询日志的阈值（0 表示不记录）
+func (s StorageConfig) SlowQueryThreshold() time.Duration {
+	switch {
+	case s.SlowQueryMs < 0:
+		return 0
+	case s.SlowQueryMs == 0:
+		return DefaultSlowQueryMs * time.Millisecond
+	default:
+		return time.Duration(s.SlowQueryMs) * time.Millisecond
+	}
 }
 
 // APIConfig API 服务器配置
 type APIConfig struct {
-	Enabled bool   `json:"enabled"` // 是否启用 API 服务器
-	Host    string `json:"host"`    // API 监听地址
-	Port    int    `json:"port"`    // API 监听端口
-	Token   string `json:"token"`   // API 访问令牌（留空则不验证）
+	Enabled bool     `json:"enabled"`        // 是否启用 API 服务器
+	Host    string   `json:"host"`           // API 监听地址
+	Port    int      `json:"port"`           // API 监听端口
+	Token   string   `json:"token"`          // API 访问令牌（留空则不验证）
+	Theme   string   `json:"theme"`          // 内置页面默认主题: light/dark/auto（默认 auto）
+	Keys    []APIKey `json:"keys,omitempty"` // 限定客户范围的 API 密钥
+
+	PublicSecret string `json:"public_secret,omitempty"` // 公开状态页链接的签名密钥（留空则禁用公开状态页）
+	PublicURL    string `json:"public_url,omitempty"`    // 生成公开链接使用的外部地址（如 https://traffic.example.com）
+
+	CORS            CORSConfig            `json:"cors"`             // 跨域配置
+	SecurityHeaders SecurityHeadersConfig `json:"security_headers"` // 安全响应头配置
+	RateLimit       RateLimitConfig       `json:"rate_limit"`       // 请求限流和大小限制
+}
+
+// RateLimitConfig API 请求限流配置（按 API 令牌或客户端 IP 计算）
+type RateLimitConfig struct {
+	Enabled           bool    `json:"enabled"`                       // 是否启用限流（默认关闭，请求大小限制始终生效）
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"` // 每个客户端每秒补充的请求数（默认 5）
+	Burst             int     `json:"burst,omitempty"`               // 突发请求数（默认 20）
+	ExpensiveCost     float64 `json:"expensive_cost,omitempty"`      // 统计、历史等耗时接口每次消耗的配额（默认 5）
+	TrustProxy        bool    `json:"trust_proxy,omitempty"`         // 是否信任 X-Forwarded-For / X-Real-IP（仅在反向代理后启用）
+	MaxBodyBytes      int64   `json:"max_body_bytes,omitempty"`      // 请求体大小上限（默认 1 MiB）
+	MaxHeaderBytes    int     `json:"max_header_bytes,omitempty"`    // 请求头大小上限（默认 64 KiB）
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`             // 允许的来源（未配置默认 ["*"]，[] 表示禁用跨域，支持 https://*.example.com）
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`   // 允许的方法（默认 GET, POST, OPTIONS）
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`   // 允许的请求头（默认 Content-Type, Authorization, X-API-Token）
+	AllowCredentials bool     `json:"allow_credentials,omitempty"` // 是否允许携带凭据（不能与 "*" 同时使用）
+	MaxAgeSeconds    int      `json:"max_age_seconds,omitempty"`   // 预检请求缓存时间（秒）
+}
+
+// SecurityHeadersConfig 安全响应头配置
+type SecurityHeadersConfig struct {
+	Disabled              bool   `json:"disabled,omitempty"`                // 是否禁用安全响应头
+	ContentSecurityPolicy string `json:"content_security_policy,omitempty"` // 内置页面的 CSP（留空使用默认策略）
+	FrameOptions          string `json:"frame_options,omitempty"`           // X-Frame-Options: DENY(默认)/SAMEORIGIN
+	HSTSMaxAgeSeconds     int    `json:"hsts_max_age_seconds,omitempty"`    // HTTPS 请求的 Strict-Transport-Security max-age（0 表示不发送）
+}
+
+// APIKey 限定客户范围的 API 密钥（只能访问该客户的虚拟机）
+type APIKey struct {
+	Name   string `json:"name"`   // 密钥名称（仅用于标识）
+	Token  string `json:"token"`  // 访问令牌
+	Tenant string `json:"tenant"` // 客户名称
+}
+
+// TenantConfig 客户（租户）划分配置
+// 显式映射优先于标签推导
+type TenantConfig struct {
+	TagPrefix   string           `json:"tag_prefix,omitempty"`   // 从 PVE 标签推导客户（如 "customer-"：标签 customer-acme -> acme）
+	MappingFile string           `json:"mapping_file,omitempty"` // 客户映射文件（JSON: {"acme": [100, 101]}）
+	Mapping     map[string][]int `json:"mapping,omitempty"`      // 内联客户映射（客户 -> VM ID 列表）
 }
 
 // VMInfo 虚拟机信息
@@ -70,12 +503,32 @@ type VMInfo struct {
 	Name         string    `json:"name"`
 	Status       string    `json:"status"`
 	Tags         []string  `json:"tags"`
-	MatchedRules []string  `json:"matched_rules"` // 匹配的规则名称列表
+	MatchedRules []string  `json:"matched_rules"` // 匹配的规则名称列表（按检查顺序）
 	NetworkRX    uint64    `json:"netrx"`         // 接收字节数
 	NetworkTX    uint64    `json:"nettx"`         // 发送字节数
 	LastUpdated  time.Time `json:"last_updated"`
-	CreationTime time.Time `json:"creation_time"` // 虚拟机创建时间
-	Template     bool      `json:"template"`      // 是否为模板虚拟机
+	CreationTime time.Time `json:"creation_time"`    // 虚拟机创建时间
+	Template     bool      `json:"template"`         // 是否为模板虚拟机
+	Lock         string    `json:"lock,omitempty"`   // 配置锁（backup、migrate 等，仅虚拟机列表返回）
+	Tenant       string    `json:"tenant,omitempty"` // 所属客户
+	NICs         NICMap    `json:"nics,omitempty"`   // 每张网卡的流量计数（仅 GetVMStatus 返回）
+
+	DiskRead  uint64 `json:"diskread,omitempty"`  // 磁盘读取字节数（仅 GetVMStatus 返回）
+	DiskWrite uint64 `json:"diskwrite,omitempty"` // 磁盘写入字节数
+
+	Node string `json:"node,omitempty"` // 所在节点（仅汇总端中由代理推送的虚拟机设置）
+
+	// 匹配的限制规则操作不同时，这些规则同时超限时按冲突处理策略执行的规则
+	ConflictWinner string `json:"conflict_winner,omitempty"`
+}
+
+// NICMap 按网卡（net0、net1 ...）的流量计数
+type NICMap map[string]NICTraffic
+
+// NICTraffic 单张网卡的流量计数（字节，虚拟机启动后累计）
+type NICTraffic struct {
+	RX uint64 `json:"netrx"`
+	TX uint64 `json:"nettx"`
 }
 
 // IsTemplate 检查是否为模板虚拟机
@@ -95,6 +548,18 @@ type TrafficRecord struct {
 	RXBytes    uint64    `json:"rx_bytes"`
 	TXBytes    uint64    `json:"tx_bytes"`
 	TotalBytes uint64    `json:"total_bytes"`
+
+	DiskRead  uint64 `json:"disk_read,omitempty"`  // 磁盘累计读取字节（PVE diskread，虚拟机启动后累计）
+	DiskWrite uint64 `json:"disk_write,omitempty"` // 磁盘累计写入字节（PVE diskwrite）
+
+	// 客户机代理报告的按协议累计字节（monitor.ip_split 启用时采集，客户机启动后累计，包括回环流量）
+	IPv4RX uint64 `json:"ipv4_rx,omitempty"`
+	IPv4TX uint64 `json:"ipv4_tx,omitempty"`
+	IPv6RX uint64 `json:"ipv6_rx,omitempty"`
+	IPv6TX uint64 `json:"ipv6_tx,omitempty"`
+
+	// 采样时（或上次采样后）虚拟机正在备份，monitor.backup_traffic 为 exclude 时上次采样到本次的增量不计入用量
+	Backup bool `json:"backup,omitempty"`
 }
 
 // TrafficStats 流量统计
@@ -111,6 +576,83 @@ type TrafficStats struct {
 	TotalGB    float64   `json:"total_gb"`
 }
 
+// TenantStats 客户流量统计（汇总该客户所有虚拟机）
+type TenantStats struct {
+	Tenant     string    `json:"tenant"`
+	VMIDs      []int     `json:"vmids"`
+	Period     string    `json:"period"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Direction  string    `json:"direction"`
+	RXBytes    uint64    `json:"rx_bytes"`
+	TXBytes    uint64    `json:"tx_bytes"`
+	TotalBytes uint64    `json:"total_bytes"`
+	TotalGB    float64   `json:"total_gb"`
+}
+
+// NetworkStats 网桥或 SDN VNet 的流量统计（汇总连接到该网络的虚拟机）
+type NetworkStats struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`           // bridge 或 vnet
+	Zone       string    `json:"zone,omitempty"` // VNet 所属的 SDN Zone
+	VMIDs      []int     `json:"vmids"`
+	Period     string    `json:"period"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Direction  string    `json:"direction"`
+	RXBytes    uint64    `json:"rx_bytes"`
+	TXBytes    uint64    `json:"tx_bytes"`
+	TotalBytes uint64    `json:"total_bytes"`
+	TotalGB    float64   `json:"total_gb"`
+}
+
+// UsageProfile 周期内的带宽分布（突发计费的 95 百分位、峰值小时和最忙的一天）
+type UsageProfile struct {
+	VMID            int       `json:"vmid"`
+	Period          string    `json:"period"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	Direction       string    `json:"direction"`
+	Samples         int       `json:"samples"`   // 参与计算的 5 分钟区间数
+	P95Mbps         float64   `json:"p95_mbps"`  // 5 分钟平均带宽的 95 百分位
+	PeakMbps        float64   `json:"peak_mbps"` // 最高的 5 分钟平均带宽
+	PeakHour        time.Time `json:"peak_hour"` // 流量最高的小时（没有数据时为零值）
+	PeakHourBytes   uint64    `json:"peak_hour_bytes"`
+	BusiestDay      time.Time `json:"busiest_day"`
+	BusiestDayBytes uint64    `json:"busiest_day_bytes"`
+}
+
+// CleanupResult 一次旧数据清理的结果（保存在存储中，通过 /api/system/stats 查询）
+type CleanupResult struct {
+	ScheduledAt   time.Time `json:"scheduled_at"` // 计划执行时间
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Missed        bool      `json:"missed,omitempty"`   // 错过计划时间，启动时补做
+	RetentionDays int       `json:"retention_days"`     // 流量记录保留天数（0 表示未清理流量记录）
+	ActionLogs    int64     `json:"action_logs"`        // 删除的操作日志数量
+	States        int64     `json:"states"`             // 删除的虚拟机状态数量
+	Archived      []string  `json:"archived,omitempty"` // 归档到对象存储的月份（2006-01）
+	Errors        []string  `json:"errors,omitempty"`
+}
+
+// NodeStats PVE 节点物理网卡流量与同期虚拟机流量总和的对比
+type NodeStats struct {
+	Node         string    `json:"node"`
+	Period       string    `json:"period"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Direction    string    `json:"direction"`
+	RXBytes      uint64    `json:"rx_bytes"` // 节点物理网卡接收（不含虚拟机 tap 网卡）
+	TXBytes      uint64    `json:"tx_bytes"`
+	TotalBytes   uint64    `json:"total_bytes"`
+	TotalGB      float64   `json:"total_gb"`
+	VMCount      int       `json:"vm_count"`
+	VMRXBytes    uint64    `json:"vm_rx_bytes"` // 同期所有虚拟机的流量总和（虚拟机接收）
+	VMTXBytes    uint64    `json:"vm_tx_bytes"`
+	VMTotalBytes uint64    `json:"vm_total_bytes"`
+	VMTotalGB    float64   `json:"vm_total_gb"`
+}
+
 // AggregatedPoint 聚合的流量数据点
 type AggregatedPoint struct {
 	Timestamp  time.Time `json:"timestamp"`
@@ -121,11 +663,21 @@ type AggregatedPoint struct {
 
 // ActionLog 操作日志
 type ActionLog struct {
-	VMID      int       `json:"vmid"`
-	RuleName  string    `json:"rule_name"`
-	Action    string    `json:"action"`
-	Reason    string    `json:"reason"`
-	Timestamp time.Time `json:"timestamp"`
-	Success   bool      `json:"success"`
-	Error     string    `json:"error,omitempty"`
+	VMID       int       `json:"vmid"`
+	RuleName   string    `json:"rule_name"`
+	Action     string    `json:"action"`
+	Reason     string    `json:"reason"`
+	Timestamp  time.Time `json:"timestamp"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	ErrorKind  string    `json:"error_kind,omitempty"`  // 错误类型: permission/not_found/locked/task/timeout/other
+	TaskID     string    `json:"task_id,omitempty"`     // PVE 异步任务 UPID（关机、停止、启动）
+	TaskStatus string    `json:"task_status,omitempty"` // 任务结束状态（OK、WARNINGS 或错误信息，超时为 running）
+
+	Output     string `json:"output,omitempty"`      // exec 操作的命令输出（stdout 和 stderr，超过 4KB 截断）
+	HookOutput string `json:"hook_output,omitempty"` // 操作后钩子的命令输出
+	HookError  string `json:"hook_error,omitempty"`  // 操作后钩子失败的原因
+
+	// 同时超限、按冲突处理策略被本次操作取代而没有执行的规则
+	OverriddenRules []string `json:"overridden_rules,omitempty"`
 }
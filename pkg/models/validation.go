@@ -4,6 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/schedule"
 )
 
 // Validate 验证配置的有效性
@@ -28,6 +32,46 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("API配置错误: %w", err)
 	}
 
+	// 验证语言
+	if c.Locale != "" && i18n.Normalize(c.Locale) == "" {
+		return fmt.Errorf("locale不支持: %s (支持: %s)", c.Locale, strings.Join(i18n.Supported(), ", "))
+	}
+
+	// 验证时区
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone无效: %s", c.Timezone)
+		}
+	}
+
+	// 验证运行模式
+	if err := c.ValidateMode(); err != nil {
+		return err
+	}
+
+	// 验证远程写入
+	if err := c.RemoteWrite.Validate(); err != nil {
+		return fmt.Errorf("remote_write配置错误: %w", err)
+	}
+	if err := c.LineProtocol.Validate(); err != nil {
+		return fmt.Errorf("line_protocol配置错误: %w", err)
+	}
+
+	// 验证事件配置
+	if err := c.Events.Validate(); err != nil {
+		return fmt.Errorf("events配置错误: %w", err)
+	}
+
+	// 验证通知配置
+	if err := c.Notify.Validate(); err != nil {
+		return fmt.Errorf("notify配置错误: %w", err)
+	}
+	for _, rule := range c.Rules {
+		if err := c.Notify.ValidateRuleNotify(rule); err != nil {
+			return fmt.Errorf("notify配置错误: %w", err)
+		}
+	}
+
 	// 验证规则配置
 	for i, rule := range c.Rules {
 		if err := rule.Validate(); err != nil {
@@ -52,12 +96,23 @@ func (p *PVEConfig) Validate() error {
 		return errors.New("node不能为空")
 	}
 
-	if p.APITokenID == "" {
-		return errors.New("api_token_id不能为空")
-	}
-
-	if p.APITokenSecret == "" {
-		return errors.New("api_token_secret不能为空")
+	switch p.AuthMode() {
+	case PVEAuthToken:
+		if p.APITokenID == "" {
+			return errors.New("api_token_id不能为空")
+		}
+		if p.APITokenSecret == "" {
+			return errors.New("api_token_secret不能为空")
+		}
+	case PVEAuthTicket:
+		if p.Username == "" {
+			return errors.New("username不能为空")
+		}
+		if p.Password == "" {
+			return errors.New("password不能为空")
+		}
+	default:
+		return fmt.Errorf("auth_method必须是token或ticket，当前值: %s", p.AuthMethod)
 	}
 
 	return nil
@@ -76,14 +131,95 @@ func (m *MonitorConfig) Validate() error {
 	if m.ExportPath == "" {
 		return errors.New("export_path不能为空")
 	}
+	seen := make(map[string]bool, len(m.ExportDestinations))
+	for i, dest := range m.ExportDestinations {
+		if err := dest.Validate(); err != nil {
+			return fmt.Errorf("export_destinations[%d]: %w", i, err)
+		}
+		if seen[dest.Name] {
+			return fmt.Errorf("export_destinations[%d]: 名称 %s 重复", i, dest.Name)
+		}
+		seen[dest.Name] = true
+	}
+	if m.ExportDestination != "" && !seen[m.ExportDestination] {
+		return fmt.Errorf("export_destination %s 不在 export_destinations 中", m.ExportDestination)
+	}
 
 	if m.DataRetentionDays < 0 {
 		return fmt.Errorf("data_retention_days不能为负数，当前值: %d", m.DataRetentionDays)
 	}
+	if m.Retention.ActionLogDays < 0 {
+		return fmt.Errorf("retention.action_logs_days不能为负数，当前值: %d", m.Retention.ActionLogDays)
+	}
+	if m.Retention.StateDays < 0 {
+		return fmt.Errorf("retention.states_days不能为负数，当前值: %d", m.Retention.StateDays)
+	}
+	if _, err := schedule.Parse(m.CleanupCron()); err != nil {
+		return fmt.Errorf("cleanup_schedule无效: %w", err)
+	}
+	if m.HA.LeaseSeconds < 0 || (m.HA.LeaseSeconds > 0 && m.HA.LeaseSeconds < MinHALeaseSeconds) {
+		return fmt.Errorf("ha.lease_seconds不能小于%d秒，当前值: %d", MinHALeaseSeconds, m.HA.LeaseSeconds)
+	}
+
+	if err := m.Actions.Validate(); err != nil {
+		return fmt.Errorf("actions: %w", err)
+	}
+	if m.GapIntervals < -1 {
+		return fmt.Errorf("gap_intervals不能小于-1，当前值: %d", m.GapIntervals)
+	}
+	if m.StoppedIntervals < -1 {
+		return fmt.Errorf("stopped_intervals不能小于-1，当前值: %d", m.StoppedIntervals)
+	}
+	if err := m.RatioAlert.Validate(); err != nil {
+		return fmt.Errorf("ratio_alert: %w", err)
+	}
+	if w := m.MinuteWindowSeconds; w != 0 && (w < MinMinuteWindowSeconds || w > MaxMinuteWindowSeconds) {
+		return fmt.Errorf("minute_window_seconds必须在%d-%d之间，当前值: %d", MinMinuteWindowSeconds, MaxMinuteWindowSeconds, w)
+	}
+
+	if err := m.Tags.Validate(); err != nil {
+		return fmt.Errorf("tags: %w", err)
+	}
+
+	if !ValidMarker(m.Marker) {
+		return fmt.Errorf("marker必须是 tags 或 description，当前值: %s", m.Marker)
+	}
+
+	if !ValidTemplateMode(m.Templates) {
+		return fmt.Errorf("templates必须是 exclude、record 或 enforce，当前值: %s", m.Templates)
+	}
+	if !ValidBackupTrafficMode(m.BackupTraffic) {
+		return fmt.Errorf("backup_traffic必须是 count、tag 或 exclude，当前值: %s", m.BackupTraffic)
+	}
+	if !ValidConflictPolicy(m.ConflictPolicy) {
+		return fmt.Errorf("conflict_policy必须是 most_severe 或 first_match，当前值: %s", m.ConflictPolicy)
+	}
 
 	return nil
 }
 
+// Validate 验证操作标签配置（完整标签需符合 PVE 标签字符集且互不相同）
+func (t TagConfig) Validate() error {
+	full := t.withDefaults()
+	seen := make(map[string]string)
+	for _, action := range []string{ActionShutdown, ActionDisconnect, ActionRateLimit} {
+		tag := full.ActionTag(action)
+		if !ValidPVETag(tag) {
+			return fmt.Errorf("标签 %q 不是合法的 PVE 标签（只能包含字母、数字和 _ + . -，且不能以 + . - 开头）", tag)
+		}
+		if other, exists := seen[tag]; exists {
+			return fmt.Errorf("%s 和 %s 的标签相同: %s", other, action, tag)
+		}
+		seen[tag] = action
+	}
+	if tag := full.RatioTag(); !ValidPVETag(tag) {
+		return fmt.Errorf("标签 %q 不是合法的 PVE 标签（只能包含字母、数字和 _ + . -，且不能以 + . - 开头）", tag)
+	} else if other, exists := seen[tag]; exists {
+		return fmt.Errorf("%s 和 ratio 的标签相同: %s", other, tag)
+	}
+	return nil
+}
+
 // Validate 验证存储配置
 func (s *StorageConfig) Validate() error {
 	if s.Type == "" {
@@ -114,9 +250,50 @@ func (s *StorageConfig) Validate() error {
 		return fmt.Errorf("%s存储需要指定dsn", s.Type)
 	}
 
+	if s.SpoolMaxRecords < 0 {
+		return fmt.Errorf("spool_max_records不能为负数，当前值: %d", s.SpoolMaxRecords)
+	}
+	if s.SlowQueryMs < -1 {
+		return fmt.Errorf("slow_query_ms不能小于-1，当前值: %d", s.SlowQueryMs)
+	}
+
+	if s.Replica != nil {
+		if s.Replica.Replica != nil {
+			return errors.New("replica不能再配置replica")
+		}
+		if err := s.Replica.Validate(); err != nil {
+			return fmt.Errorf("replica: %w", err)
+		}
+		if s.Replica.sameBackend(s) {
+			return errors.New("replica不能与主存储相同")
+		}
+	}
+
 	return nil
 }
 
+// sameBackend 是否指向同一个存储后端
+func (s *StorageConfig) sameBackend(other *StorageConfig) bool {
+	normalize := func(t string) string {
+		switch t = strings.ToLower(t); t {
+		case "", "file":
+			return "file"
+		case "postgresql":
+			return "postgres"
+		case "sqlite3":
+			return "sqlite"
+		}
+		return t
+	}
+	if normalize(s.Type) != normalize(other.Type) {
+		return false
+	}
+	if normalize(s.Type) == "file" {
+		return s.FilePath == other.FilePath
+	}
+	return s.DSN == other.DSN
+}
+
 // Validate 验证 API 配置
 func (a *APIConfig) Validate() error {
 	if a.Enabled {
@@ -129,6 +306,86 @@ func (a *APIConfig) Validate() error {
 		}
 	}
 
+	switch a.Theme {
+	case "", ThemeLight, ThemeDark, ThemeAuto:
+	default:
+		return fmt.Errorf("theme必须是light/dark/auto，当前值: %s", a.Theme)
+	}
+
+	tokens := make(map[string]bool)
+	for i, key := range a.Keys {
+		if key.Token == "" {
+			return fmt.Errorf("keys[%d].token不能为空", i)
+		}
+		if key.Tenant == "" {
+			return fmt.Errorf("keys[%d].tenant不能为空", i)
+		}
+		if key.Token == a.Token || tokens[key.Token] {
+			return fmt.Errorf("keys[%d].token与其他令牌重复", i)
+		}
+		tokens[key.Token] = true
+	}
+
+	if a.PublicSecret != "" && len(a.PublicSecret) < MinPublicSecretLength {
+		return fmt.Errorf("public_secret长度不能少于%d个字符", MinPublicSecretLength)
+	}
+
+	if err := a.CORS.Validate(); err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+	if err := a.SecurityHeaders.Validate(); err != nil {
+		return fmt.Errorf("security_headers: %w", err)
+	}
+	if err := a.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+
+	return nil
+}
+
+// Validate 验证跨域配置
+func (c *CORSConfig) Validate() error {
+	for i, origin := range c.AllowedOrigins {
+		if origin == "" {
+			return fmt.Errorf("allowed_origins[%d]不能为空", i)
+		}
+		if origin == "*" && c.AllowCredentials {
+			return errors.New("allow_credentials不能与allowed_origins中的\"*\"同时使用")
+		}
+		if strings.Count(origin, "*") > 1 || (origin != "*" && strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			return fmt.Errorf("allowed_origins[%d]格式无效: %s（通配符仅支持 * 或 https://*.example.com）", i, origin)
+		}
+	}
+	if c.MaxAgeSeconds < 0 {
+		return fmt.Errorf("max_age_seconds不能为负数，当前值: %d", c.MaxAgeSeconds)
+	}
+	return nil
+}
+
+// Validate 验证限流配置
+func (l *RateLimitConfig) Validate() error {
+	if l.RequestsPerSecond < 0 || l.Burst < 0 || l.ExpensiveCost < 0 {
+		return errors.New("requests_per_second、burst、expensive_cost不能为负数")
+	}
+	if l.MaxBodyBytes < 0 || l.MaxHeaderBytes < 0 {
+		return errors.New("max_body_bytes、max_header_bytes不能为负数")
+	}
+	if l.ExpensiveCost > 0 && l.Burst > 0 && l.ExpensiveCost > float64(l.Burst) {
+		return fmt.Errorf("expensive_cost(%v)不能大于burst(%d)，否则耗时接口永远无法访问", l.ExpensiveCost, l.Burst)
+	}
+	return nil
+}
+
+// Validate 验证安全响应头配置
+func (h *SecurityHeadersConfig) Validate() error {
+	switch strings.ToUpper(h.FrameOptions) {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		return fmt.Errorf("frame_options必须是DENY或SAMEORIGIN，当前值: %s", h.FrameOptions)
+	}
+	if h.HSTSMaxAgeSeconds < 0 {
+		return fmt.Errorf("hsts_max_age_seconds不能为负数，当前值: %d", h.HSTSMaxAgeSeconds)
+	}
 	return nil
 }
 
@@ -138,15 +395,84 @@ func (r *Rule) Validate() error {
 		return errors.New("name不能为空")
 	}
 
-	// 验证周期
+	// 验证规则类型
+	if r.Type != "" && r.Type != RuleTypeVolume && r.Type != RuleTypeRate && r.Type != RuleTypePercentile {
+		return fmt.Errorf("不支持的规则类型: %s (支持: volume, rate, percentile)", r.Type)
+	}
+
+	// 验证判断范围（主机规则按周期累计流量的总和判断，各虚拟机必须使用同一周期）
+	if r.Scope != "" && r.Scope != RuleScopeVM && r.Scope != RuleScopeHost {
+		return fmt.Errorf("不支持的判断范围: %s (支持: vm, host)", r.Scope)
+	}
+	if r.IsHostRule() {
+		if r.IsRateRule() || r.IsPercentileRule() {
+			return errors.New("scope=host仅支持volume规则")
+		}
+		if r.UseCreationTime {
+			return errors.New("scope=host不能与use_creation_time同时使用")
+		}
+		if r.Target != "" && r.Target != TargetTopTalkers {
+			return fmt.Errorf("不支持的操作对象: %s (支持: top_talkers)", r.Target)
+		}
+		if r.TopN < 0 {
+			return fmt.Errorf("top_n不能为负数，当前值: %d", r.TopN)
+		}
+	} else if r.Target != "" || r.TopN != 0 {
+		return errors.New("target和top_n仅支持scope=host的规则")
+	}
+
+	// 验证统计对象（只有 rate 规则可以按磁盘读写统计）
+	if !ValidMetric(r.Metric) {
+		return fmt.Errorf("不支持的统计对象: %s (支持: network, disk, ipv4, ipv6)", r.Metric)
+	}
+	if r.Metric == MetricDisk && !r.IsRateRule() {
+		return errors.New("metric=disk仅支持rate规则")
+	}
+
+	// 验证周期（rate 规则的周期仅用于决定恢复时间，可以为空）
 	validPeriods := map[string]bool{
 		PeriodHour:  true,
 		PeriodDay:   true,
 		PeriodMonth: true,
 	}
 
-	if !validPeriods[r.Period] {
-		return fmt.Errorf("不支持的周期: %s (支持: hour, day, month)", r.Period)
+	_, rolling := r.RollingWindow()
+	if !validPeriods[r.Period] && !rolling && !(r.IsRateRule() && r.Period == "") {
+		return fmt.Errorf("不支持的周期: %s (支持: minute, hour, day, month, rolling:<N>h, rolling:<N>d)", r.Period)
+	}
+	if rolling && r.IsPercentileRule() {
+		return errors.New("percentile规则不支持滑动窗口周期")
+	}
+	if rolling && r.UseCreationTime {
+		return errors.New("滑动窗口周期不能与use_creation_time同时使用")
+	}
+	// 未设置周期的 rate 规则按创建时间计算的下个周期开始就是当前时间，恢复后立即再次触发
+	if r.Period == "" && r.UseCreationTime {
+		return errors.New("未设置周期时不能使用use_creation_time")
+	}
+
+	// 验证时区
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("timezone无效: %s", r.Timezone)
+		}
+	}
+
+	// 验证账单日（仅用于月周期，不能与创建时间基准同时使用）
+	if r.AnchorDay < 0 || r.AnchorDay > 31 {
+		return fmt.Errorf("anchor_day必须在1-31之间，当前值: %d", r.AnchorDay)
+	}
+	if r.AnchorHour < 0 || r.AnchorHour > 23 {
+		return fmt.Errorf("anchor_hour必须在0-23之间，当前值: %d", r.AnchorHour)
+	}
+	if r.AnchorDay == 0 && r.AnchorHour != 0 {
+		return errors.New("anchor_hour需要同时设置anchor_day")
+	}
+	if r.AnchorDay > 0 && r.Period != PeriodMonth {
+		return fmt.Errorf("anchor_day仅支持month周期，当前周期: %s", r.Period)
+	}
+	if r.AnchorDay > 0 && r.UseCreationTime {
+		return errors.New("anchor_day不能与use_creation_time同时使用")
 	}
 
 	// 验证流量方向
@@ -165,7 +491,18 @@ func (r *Rule) Validate() error {
 	}
 
 	// 验证限制值
-	if r.LimitGB <= 0 {
+	if r.IsRateRule() {
+		if r.RateThresholdMbps <= 0 {
+			return fmt.Errorf("rate规则需要指定rate_threshold_mbps且必须大于0，当前值: %.2f", r.RateThresholdMbps)
+		}
+		if r.RateWindowMinutes < 0 {
+			return fmt.Errorf("rate_window_minutes不能为负数，当前值: %d", r.RateWindowMinutes)
+		}
+	} else if r.IsPercentileRule() {
+		if r.RateThresholdMbps <= 0 {
+			return fmt.Errorf("percentile规则需要指定rate_threshold_mbps且必须大于0，当前值: %.2f", r.RateThresholdMbps)
+		}
+	} else if r.LimitGB <= 0 {
 		return fmt.Errorf("limit_gb必须大于0，当前值: %.2f", r.LimitGB)
 	}
 
@@ -175,10 +512,26 @@ func (r *Rule) Validate() error {
 		ActionStop:       true,
 		ActionDisconnect: true,
 		ActionRateLimit:  true,
+		ActionExec:       true,
 	}
 
 	if !validActions[r.Action] {
-		return fmt.Errorf("不支持的操作: %s (支持: shutdown, stop, disconnect, rate_limit)", r.Action)
+		return fmt.Errorf("不支持的操作: %s (支持: shutdown, stop, disconnect, rate_limit, exec)", r.Action)
+	}
+
+	// 验证外部命令
+	if r.Action == ActionExec && r.Exec == nil {
+		return errors.New("exec操作需要指定exec.command")
+	}
+	if r.Exec != nil {
+		if err := r.Exec.Validate(); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+	}
+	if r.PostAction != nil {
+		if err := r.PostAction.Validate(); err != nil {
+			return fmt.Errorf("post_action: %w", err)
+		}
 	}
 
 	// 验证限速值
@@ -186,6 +539,14 @@ func (r *Rule) Validate() error {
 		return fmt.Errorf("rate_limit操作需要指定rate_limit_mb且必须大于0，当前值: %.2f", r.RateLimitMB)
 	}
 
+	// 验证重复执行限制
+	if r.CooldownMinutes < 0 {
+		return fmt.Errorf("cooldown_minutes不能为负数，当前值: %d", r.CooldownMinutes)
+	}
+	if r.MaxExecutions < 0 {
+		return fmt.Errorf("max_executions不能为负数，当前值: %d", r.MaxExecutions)
+	}
+
 	// 至少要有一个匹配条件
 	if len(r.VMIDs) == 0 && len(r.VMTags) == 0 {
 		return errors.New("至少需要指定vm_ids或vm_tags之一")
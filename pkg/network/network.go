@@ -0,0 +1,126 @@
+package network
+
+import (
+	"sort"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// Membership 虚拟机与网络（网桥或 SDN VNet）的对应关系
+type Membership struct {
+	Members map[string][]int  // 网络名 -> 连接到该网络的虚拟机（升序）
+	VNets   map[string]string // SDN VNet 名称 -> Zone（其余网络为普通网桥）
+}
+
+// NewMembership 根据每台虚拟机连接的网桥建立对应关系
+// 连接到多个网络的虚拟机的流量计入每个网络（按虚拟机汇总，不区分网卡）
+func NewMembership(bridges map[int][]string, vnets map[string]string) *Membership {
+	m := &Membership{
+		Members: make(map[string][]int),
+		VNets:   vnets,
+	}
+	for vmid, names := range bridges {
+		for _, name := range names {
+			m.Members[name] = append(m.Members[name], vmid)
+		}
+	}
+	for name := range m.Members {
+		sort.Ints(m.Members[name])
+	}
+	if m.VNets == nil {
+		m.VNets = make(map[string]string)
+	}
+	return m
+}
+
+// Names 获取所有网络名称（排序）
+func (m *Membership) Names() []string {
+	names := make([]string, 0, len(m.Members))
+	for name := range m.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Type 获取网络类型和 VNet 所属的 Zone
+func (m *Membership) Type(name string) (string, string) {
+	if zone, ok := m.VNets[name]; ok {
+		return models.NetworkTypeVNet, zone
+	}
+	return models.NetworkTypeBridge, ""
+}
+
+// Scope 只保留允许访问的虚拟机（allowed 为 nil 时不限制），没有成员的网络被移除
+func (m *Membership) Scope(allowed map[int]bool) *Membership {
+	if allowed == nil {
+		return m
+	}
+	scoped := &Membership{Members: make(map[string][]int), VNets: m.VNets}
+	for name, vmids := range m.Members {
+		for _, vmid := range vmids {
+			if allowed[vmid] {
+				scoped.Members[name] = append(scoped.Members[name], vmid)
+			}
+		}
+	}
+	return scoped
+}
+
+// Aggregate 汇总网络中所有虚拟机的流量统计
+func (m *Membership) Aggregate(name string, stats []models.TrafficStats) models.NetworkStats {
+	networkType, zone := m.Type(name)
+	result := models.NetworkStats{
+		Name:  name,
+		Type:  networkType,
+		Zone:  zone,
+		VMIDs: []int{},
+	}
+
+	for i, stat := range stats {
+		if i == 0 || stat.StartTime.Before(result.StartTime) {
+			result.StartTime = stat.StartTime
+		}
+		if stat.EndTime.After(result.EndTime) {
+			result.EndTime = stat.EndTime
+		}
+		result.Period = stat.Period
+		result.Direction = stat.Direction
+		result.VMIDs = append(result.VMIDs, stat.VMID)
+		result.RXBytes += stat.RXBytes
+		result.TXBytes += stat.TXBytes
+		result.TotalBytes += stat.TotalBytes
+	}
+
+	sort.Ints(result.VMIDs)
+	result.TotalGB = float64(result.TotalBytes) / models.BytesPerGB
+	return result
+}
+
+// MergeHistory 按时间点合并多台虚拟机的聚合流量（每个时间段的增量相加）
+func MergeHistory(series ...[]storage.AggregatedPoint) []storage.AggregatedPoint {
+	byTime := make(map[int64]*storage.AggregatedPoint)
+	for _, points := range series {
+		for _, point := range points {
+			key := point.Timestamp.UnixNano()
+			merged, ok := byTime[key]
+			if !ok {
+				merged = &storage.AggregatedPoint{Timestamp: point.Timestamp}
+				byTime[key] = merged
+			}
+			merged.RXBytes += point.RXBytes
+			merged.TXBytes += point.TXBytes
+			merged.TotalBytes += point.TotalBytes
+		}
+	}
+
+	result := make([]storage.AggregatedPoint, 0, len(byTime))
+	for _, point := range byTime {
+		result = append(result, *point)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
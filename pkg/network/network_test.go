@@ -0,0 +1,56 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestMembershipAggregate(t *testing.T) {
+	m := NewMembership(map[int][]string{
+		101: {"vmbr0", "tenant1"},
+		100: {"vmbr0"},
+		200: {"tenant1"},
+	}, map[string]string{"tenant1": "evpn"})
+
+	if names := m.Names(); len(names) != 2 || names[0] != "tenant1" || names[1] != "vmbr0" {
+		t.Fatalf("Names() = %v, want [tenant1 vmbr0]", names)
+	}
+	if vmids := m.Members["vmbr0"]; len(vmids) != 2 || vmids[0] != 100 || vmids[1] != 101 {
+		t.Fatalf("Members[vmbr0] = %v, want [100 101]", vmids)
+	}
+
+	got := m.Aggregate("tenant1", []models.TrafficStats{
+		{VMID: 200, Period: models.PeriodDay, RXBytes: 1, TXBytes: 2, TotalBytes: 3},
+		{VMID: 101, Period: models.PeriodDay, RXBytes: 10, TXBytes: 20, TotalBytes: 30},
+	})
+	if got.Type != models.NetworkTypeVNet || got.Zone != "evpn" || got.TotalBytes != 33 || got.VMIDs[0] != 101 {
+		t.Fatalf("Aggregate(tenant1) = %+v, want vnet in zone evpn with 33 bytes", got)
+	}
+	if typ, _ := m.Type("vmbr0"); typ != models.NetworkTypeBridge {
+		t.Fatalf("Type(vmbr0) = %s, want bridge", typ)
+	}
+
+	scoped := m.Scope(map[int]bool{200: true})
+	if len(scoped.Names()) != 1 || scoped.Members["tenant1"][0] != 200 {
+		t.Fatalf("Scope() = %v, want only tenant1 with VM 200", scoped.Members)
+	}
+}
+
+func TestMergeHistory(t *testing.T) {
+	t1 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	merged := MergeHistory(
+		[]storage.AggregatedPoint{{Timestamp: t2, RXBytes: 5, TXBytes: 1, TotalBytes: 6}},
+		[]storage.AggregatedPoint{
+			{Timestamp: t1, RXBytes: 1, TXBytes: 1, TotalBytes: 2},
+			{Timestamp: t2, RXBytes: 2, TXBytes: 2, TotalBytes: 4},
+		},
+	)
+	if len(merged) != 2 || !merged[0].Timestamp.Equal(t1) || merged[1].TotalBytes != 10 || merged[1].RXBytes != 7 {
+		t.Fatalf("MergeHistory() = %+v, want t1=2 bytes, t2=10 bytes", merged)
+	}
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// 告警通知的阶段
+const (
+	AlertFiring    = "firing"    // 开始超限
+	AlertRepeat    = "repeat"    // 持续超限，按间隔重复
+	AlertEscalated = "escalated" // 持续超限超过升级时间
+	AlertResolved  = "resolved"  // 虚拟机已恢复
+)
+
+// AlertNotice 告警通知（templates.alert 模板的数据）
+type AlertNotice struct {
+	Phase     string
+	VMID      int
+	Rule      string
+	Reason    string        // 最近一次超限的原因
+	Since     time.Time     // 开始超限的时间
+	Duration  time.Duration // 已持续的时间
+	Escalated bool          // 是否已升级（升级后的通知同时发送到 escalate_to 通道）
+}
+
+// alert 一条规则在一台虚拟机上的超限状态
+type alert struct {
+	reason      string
+	since       time.Time
+	notified    time.Time // 最近一次通知的时间
+	periodStart time.Time // 流量规则超限时所在的周期（新周期重新开始告警）
+	escalated   bool
+}
+
+// alertTracker 超限告警状态机：超限期间每个采集周期都会收到 limit_exceeded 事件，
+// 只在开始、到达重复间隔和升级时产生通知，恢复时产生解除通知
+// 保存在包级变量中，配置重载重新创建通知插件时保留告警状态
+type alertTracker struct {
+	mu     sync.Mutex
+	alerts map[string]*alert // "vmid/规则"
+}
+
+// sharedAlerts 通知插件共用的告警状态
+var sharedAlerts = newAlertTracker()
+
+// newAlertTracker 创建告警状态
+func newAlertTracker() *alertTracker {
+	return &alertTracker{alerts: make(map[string]*alert)}
+}
+
+// fire 处理一次超限，需要通知时返回 true
+func (t *alertTracker) fire(ev events.Event, policy models.AlertPolicy, now time.Time) (AlertNotice, bool) {
+	var periodStart time.Time
+	if ev.Usage != nil {
+		periodStart = ev.Usage.PeriodStart
+	}
+	key := fmt.Sprintf("%d/%s", ev.VMID, ev.Rule)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.alerts[key]
+	if !ok || !a.periodStart.Equal(periodStart) {
+		// 新的超限（或流量规则进入了新周期但没有恢复过，如 exec 操作）
+		t.alerts[key] = &alert{reason: ev.Reason, since: now, notified: now, periodStart: periodStart}
+		return AlertNotice{Phase: AlertFiring, VMID: ev.VMID, Rule: ev.Rule, Reason: ev.Reason, Since: now}, true
+	}
+	a.reason = ev.Reason
+
+	notice := AlertNotice{VMID: ev.VMID, Rule: ev.Rule, Reason: ev.Reason, Since: a.since, Duration: now.Sub(a.since)}
+	switch {
+	case !a.escalated && policy.EscalateAfter() > 0 && now.Sub(a.since) >= policy.EscalateAfter():
+		a.escalated = true
+		notice.Phase = AlertEscalated
+	case policy.Renotify() > 0 && now.Sub(a.notified) >= policy.Renotify():
+		notice.Phase = AlertRepeat
+	default:
+		return AlertNotice{}, false
+	}
+	a.notified = now
+	notice.Escalated = a.escalated
+	return notice, true
+}
+
+// resolve 虚拟机恢复后解除它的所有告警
+func (t *alertTracker) resolve(vmid int, now time.Time) []AlertNotice {
+	prefix := fmt.Sprintf("%d/", vmid)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var notices []AlertNotice
+	for key, a := range t.alerts {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		delete(t.alerts, key)
+		notices = append(notices, AlertNotice{
+			Phase:     AlertResolved,
+			VMID:      vmid,
+			Rule:      strings.TrimPrefix(key, prefix),
+			Reason:    a.reason,
+			Since:     a.since,
+			Duration:  now.Sub(a.since),
+			Escalated: a.escalated,
+		})
+	}
+	return notices
+}
+
+// formatDuration 通知中的持续时间（精确到分钟，如 6h、1h30m、45m）
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes < 1:
+		return "<1m"
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh%dm", minutes/60, minutes%60)
+	}
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"pve-traffic-monitor/pkg/digest"
+	"pve-traffic-monitor/pkg/models"
+)
+
+// SendDigest 把摘要发送到订阅了 digest 的通道（配置了 templates.digest 时用模板生成纯文本内容），返回发送成功的通道
+func (n *Notifier) SendDigest(report digest.Report) ([]string, error) {
+	text := report.Text(n.locale)
+	if tmpl, ok := n.templates[models.NotifyDigest]; ok {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, report); err != nil {
+			return nil, fmt.Errorf("渲染摘要失败: %w", err)
+		}
+		text = b.String()
+	}
+	html, err := report.HTML(n.locale)
+	if err != nil {
+		return nil, fmt.Errorf("渲染摘要失败: %w", err)
+	}
+	msg := Message{Title: report.Title(n.locale), Text: text, HTML: html, Data: report}
+
+	var sent, failed []string
+	for _, ch := range n.channels {
+		if !ch.config.Subscribes(models.NotifyDigest) {
+			continue
+		}
+		if err := ch.sender.Send(msg); err != nil {
+			log.Printf("通知通道 %s 发送摘要失败: %v", ch.config.Name, err)
+			failed = append(failed, ch.config.Name)
+			continue
+		}
+		sent = append(sent, ch.config.Name)
+	}
+	if len(sent) == 0 && len(failed) > 0 {
+		return nil, fmt.Errorf("所有通道发送摘要失败: %s", strings.Join(failed, ", "))
+	}
+	return sent, nil
+}
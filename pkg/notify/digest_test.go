@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/digest"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestSendDigest(t *testing.T) {
+	var body map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	cfg := &models.Config{
+		Locale: "en-US",
+		Notify: models.NotifyConfig{
+			Channels: []models.NotifyChannel{
+				{Name: "hook", Type: models.NotifyWebhook, URL: server.URL, Token: "secret", Events: []string{models.NotifyDigest}},
+				{Name: "ops", Type: models.NotifyNtfy, Topic: "ops"},
+			},
+		},
+	}
+	ops := &recorder{}
+	n, err := NewNotifier(cfg, map[string]Sender{"ops": ops})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	start := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	report := digest.Report{
+		Start:        start,
+		End:          start.Add(24 * time.Hour),
+		QuotaPercent: 80,
+		Consumers:    []digest.Consumer{{VMID: 101, Name: "web", TotalBytes: 3 * models.BytesPerGB, RXBytes: 2 * models.BytesPerGB, TXBytes: models.BytesPerGB}},
+		Quotas:       []digest.Quota{{VMID: 101, Name: "web", Rule: "monthly", UsedGB: 90, LimitGB: 100, Percent: 90}},
+	}
+	sent, err := n.SendDigest(report)
+	if err != nil || len(sent) != 1 || sent[0] != "hook" {
+		t.Fatalf("SendDigest() = %v, %v, want only the subscribed webhook", sent, err)
+	}
+	if len(ops.texts) != 0 {
+		t.Errorf("digest sent to unsubscribed channel: %q", ops.texts)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if title, _ := body["title"].(string); !strings.HasPrefix(title, "PVE Traffic Monitor digest") {
+		t.Errorf("title = %q", title)
+	}
+	if text, _ := body["text"].(string); !strings.Contains(text, "1. VM101 web: 3.00 GB") || !strings.Contains(text, "VM101 web rule monthly: 90.0%") {
+		t.Errorf("text = %q", text)
+	}
+	if html, _ := body["html"].(string); !strings.Contains(html, "<li") {
+		t.Errorf("html = %q", html)
+	}
+	if data, _ := body["data"].(map[string]interface{}); data["quota_percent"] != float64(80) {
+		t.Errorf("data = %v", body["data"])
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	msg := Message{Title: "流量摘要", Text: "plain", HTML: "<p>html</p>"}
+	data := string(buildEmail("monitor@example.com", []string{"a@example.com", "b@example.com"}, msg, time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)))
+	for _, want := range []string{
+		"From: monitor@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"multipart/alternative; boundary=",
+		"Content-Type: text/html; charset=utf-8\r\n",
+		"PHA+aHRtbDwvcD4=", // <p>html</p>
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("email missing %q:\n%s", want, data)
+		}
+	}
+
+	if _, err := newEmail(models.NotifyChannel{URL: "smtp://mail.example.com"}); err != nil {
+		t.Fatalf("newEmail() error = %v", err)
+	}
+	e, _ := newEmail(models.NotifyChannel{URL: "smtps://mail.example.com"})
+	if e.host != "mail.example.com:465" || !e.implicit {
+		t.Errorf("smtps host = %s, implicit = %v", e.host, e.implicit)
+	}
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// email SMTP 邮件（smtp:// 在服务器支持时使用 STARTTLS，smtps:// 使用隐式 TLS）
+type email struct {
+	host     string // host:port
+	implicit bool   // smtps://
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// newEmail 创建邮件通道（未指定端口时 smtp 使用 587，smtps 使用 465）
+func newEmail(channel models.NotifyChannel) (*email, error) {
+	u, err := url.Parse(channel.URL)
+	if err != nil || (u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "" {
+		return nil, fmt.Errorf("url必须是 smtp://host:port 或 smtps://host:port: %s", channel.URL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "587"
+		if u.Scheme == "smtps" {
+			port = "465"
+		}
+	}
+	return &email{
+		host:     net.JoinHostPort(u.Hostname(), port),
+		implicit: u.Scheme == "smtps",
+		username: channel.Username,
+		password: channel.Password,
+		from:     channel.From,
+		to:       channel.To,
+	}, nil
+}
+
+// Send 发送邮件（有 HTML 内容时同时包含纯文本和 HTML）
+func (e *email) Send(msg Message) error {
+	if err := e.send(buildEmail(e.from, e.to, msg, time.Now())); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// send 连接服务器并发送邮件
+func (e *email) send(body []byte) error {
+	hostname, _, _ := net.SplitHostPort(e.host)
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	var err error
+	if e.implicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.host, &tls.Config{ServerName: hostname})
+	} else {
+		conn, err = dialer.Dial("tcp", e.host)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	client, err := smtp.NewClient(conn, hostname)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !e.implicit {
+		if err := client.StartTLS(&tls.Config{ServerName: hostname}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		// PlainAuth 只允许在 TLS 连接或本机上发送密码
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, hostname)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail 生成邮件内容（标题按 RFC 2047 编码，正文 base64 编码）
+func buildEmail(from string, to []string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Title))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, msg.Text)
+		return b.Bytes()
+	}
+
+	boundary := randomBoundary()
+	header("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", boundary))
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType)
+		header("Content-Transfer-Encoding", "base64")
+		b.WriteString("\r\n")
+		writeBase64(&b, part.body)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+// writeBase64 写入 base64 编码的正文（每行 76 个字符）
+func writeBase64(b *bytes.Buffer, text string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
+
+// randomBoundary 多部分邮件的分隔符
+func randomBoundary() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "pvetm-" + hex.EncodeToString(buf)
+}
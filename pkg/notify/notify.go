@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// sendTimeout 单次发送的超时时间
+const sendTimeout = 10 * time.Second
+
+// Message 通知消息
+type Message struct {
+	Title string
+	Text  string
+	HTML  string      // HTML 内容（可选，email 发送 HTML 邮件，webhook 包含在请求中，其他通道只发送 Text）
+	Data  interface{} // 结构化数据（可选，只有 webhook 发送）
+}
+
+// Sender 通知通道
+type Sender interface {
+	Send(msg Message) error
+}
+
+// New 根据通道配置创建发送者
+func New(channel models.NotifyChannel, client *http.Client) (Sender, error) {
+	if client == nil {
+		client = &http.Client{Timeout: sendTimeout}
+	}
+	switch channel.Type {
+	case models.NotifyTelegram:
+		return &telegram{client: client, url: baseURL(channel.URL, models.DefaultTelegramURL), token: channel.Token, chatID: channel.ChatID}, nil
+	case models.NotifyGotify:
+		return &gotify{client: client, url: baseURL(channel.URL, ""), token: channel.Token, priority: channel.Priority}, nil
+	case models.NotifyNtfy:
+		return &ntfy{client: client, url: baseURL(channel.URL, models.DefaultNtfyURL), topic: channel.Topic, token: channel.Token, priority: channel.Priority}, nil
+	case models.NotifyEmail:
+		return newEmail(channel)
+	case models.NotifyWebhook:
+		return &webhook{client: client, url: channel.URL, token: channel.Token}, nil
+	default:
+		return nil, fmt.Errorf("不支持的通道类型: %s", channel.Type)
+	}
+}
+
+// baseURL 去掉结尾斜杠的服务器地址（未配置时使用默认地址）
+func baseURL(url, fallback string) string {
+	if url == "" {
+		url = fallback
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// telegram Telegram Bot API（sendMessage）
+type telegram struct {
+	client *http.Client
+	url    string
+	token  string
+	chatID string
+}
+
+// Send 发送消息（标题作为第一行）
+func (t *telegram) Send(msg Message) error {
+	text := msg.Text
+	if msg.Title != "" {
+		text = msg.Title + "\n" + text
+	}
+	return postJSON(t.client, t.url+"/bot"+t.token+"/sendMessage", nil, map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+}
+
+// gotify Gotify 服务器（POST /message）
+type gotify struct {
+	client   *http.Client
+	url      string
+	token    string
+	priority int
+}
+
+// Send 发送消息
+func (g *gotify) Send(msg Message) error {
+	body := map[string]interface{}{"title": msg.Title, "message": msg.Text}
+	if g.priority > 0 {
+		body["priority"] = g.priority
+	}
+	return postJSON(g.client, g.url+"/message", map[string]string{"X-Gotify-Key": g.token}, body)
+}
+
+// ntfy ntfy 服务器（JSON 发布，标题可以包含非 ASCII 字符）
+type ntfy struct {
+	client   *http.Client
+	url      string
+	topic    string
+	token    string
+	priority int
+}
+
+// Send 发送消息
+func (n *ntfy) Send(msg Message) error {
+	body := map[string]interface{}{"topic": n.topic, "title": msg.Title, "message": msg.Text}
+	if n.priority > 0 {
+		body["priority"] = n.priority
+	}
+	var headers map[string]string
+	if n.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+	return postJSON(n.client, n.url, headers, body)
+}
+
+// webhook 通用 Webhook（POST JSON：title、text，以及可选的 html 和 data）
+type webhook struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+// Send 发送消息
+func (w *webhook) Send(msg Message) error {
+	body := map[string]interface{}{"title": msg.Title, "text": msg.Text}
+	if msg.HTML != "" {
+		body["html"] = msg.HTML
+	}
+	if msg.Data != nil {
+		body["data"] = msg.Data
+	}
+	var headers map[string]string
+	if w.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + w.token}
+	}
+	return postJSON(w.client, w.url, headers, body)
+}
+
+// postJSON 发送 JSON 请求，非 2xx 响应返回包含状态码和响应内容的错误
+func postJSON(client *http.Client, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息中的地址包含 Telegram Bot Token，只保留底层错误
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("发送通知失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("发送通知失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestProviders(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]map[string]interface{})
+	headers := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests[r.URL.Path] = body
+		headers[r.URL.Path] = r.Header
+		mu.Unlock()
+		if strings.Contains(r.URL.Path, "bad") {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	msg := Message{Title: "标题", Text: "VM100 超出限制"}
+	channels := []models.NotifyChannel{
+		{Name: "tg", Type: models.NotifyTelegram, URL: server.URL + "/", Token: "123:abc", ChatID: "-100"},
+		{Name: "gotify", Type: models.NotifyGotify, URL: server.URL, Token: "app-token", Priority: 5},
+		{Name: "ntfy", Type: models.NotifyNtfy, URL: server.URL, Topic: "pve", Token: "tk_1"},
+	}
+	for _, ch := range channels {
+		sender, err := New(ch, nil)
+		if err != nil {
+			t.Fatalf("New(%s) error = %v", ch.Name, err)
+		}
+		if err := sender.Send(msg); err != nil {
+			t.Fatalf("%s Send() error = %v", ch.Name, err)
+		}
+	}
+
+	bad, _ := New(models.NotifyChannel{Type: models.NotifyNtfy, URL: server.URL + "/bad", Topic: "pve"}, nil)
+	if err := bad.Send(msg); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Send() error = %v, want HTTP 401", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if body := requests["/bot123:abc/sendMessage"]; body["chat_id"] != "-100" || body["text"] != "标题\nVM100 超出限制" {
+		t.Fatalf("telegram request = %v", body)
+	}
+	if body := requests["/message"]; body["title"] != "标题" || body["priority"] != float64(5) || headers["/message"].Get("X-Gotify-Key") != "app-token" {
+		t.Fatalf("gotify request = %v", body)
+	}
+	if body := requests["/"]; body["topic"] != "pve" || body["message"] != msg.Text || headers["/"].Get("Authorization") != "Bearer tk_1" {
+		t.Fatalf("ntfy request = %v", body)
+	}
+}
+
+// recorder 记录发送的消息
+type recorder struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (r *recorder) Send(msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.texts = append(r.texts, msg.Text)
+	return nil
+}
+
+func TestNotifierRoutingAndRepeat(t *testing.T) {
+	cfg := &models.Config{
+		Locale: "en-US",
+		Rules: []models.Rule{
+			{Name: "monthly", Notify: []string{"ops"}},
+			{Name: "daily"},
+		},
+		Notify: models.NotifyConfig{
+			Channels: []models.NotifyChannel{
+				{Name: "ops", Type: models.NotifyNtfy, Topic: "ops", Events: []string{"limit_warning", "action_executed", "recovery_done"}},
+				{Name: "billing", Type: models.NotifyNtfy, Topic: "billing", Events: []string{"action_executed"}},
+			},
+			Templates: map[string]string{"recovery_done": "VM{{.VMID}} back to {{.Recovery.OriginalStatus}}"},
+		},
+	}
+	ops, billing := &recorder{}, &recorder{}
+	n, err := NewNotifier(cfg, map[string]Sender{"ops": ops, "billing": billing})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	warning := events.Event{Type: events.LimitWarning, VMID: 100, Rule: "daily", Usage: &events.Usage{UsedGB: 85, LimitGB: 100, Percent: 85}}
+	n.Handle(warning)
+	n.Handle(warning) // 间隔内重复的预警不再发送
+	n.Handle(events.Event{Type: events.ActionExecuted, VMID: 100, Rule: "monthly", Action: &models.ActionLog{Action: models.ActionShutdown, Success: true, Reason: "over"}})
+	n.Handle(events.Event{Type: events.ActionExecuted, VMID: 101, Rule: "daily", Action: &models.ActionLog{Action: models.ActionStop, Error: "locked"}})
+	n.Handle(events.Event{Type: events.RecoveryDone, VMID: 100, Rule: "monthly", Recovery: &models.VMState{OriginalStatus: "running"}})
+
+	want := []string{
+		"VM100 rule daily reached 85% of its limit: 85.00 / 100.00 GB",
+		"VM100: rule monthly executed action Shutdown\nReason: over",
+		"VM101: rule daily failed to execute action Stop: locked\nReason: ",
+		"VM100 back to running",
+	}
+	if strings.Join(ops.texts, "|") != strings.Join(want, "|") {
+		t.Fatalf("ops got %q, want %q", ops.texts, want)
+	}
+	if len(billing.texts) != 1 || !strings.HasPrefix(billing.texts[0], "VM101") {
+		t.Fatalf("billing got %q, want only the daily action", billing.texts)
+	}
+
+	// 间隔过后再次发送
+	if !n.allow("ops", warning, time.Now().Add(2*time.Hour)) {
+		t.Fatal("allow() = false after repeat interval")
+	}
+}
+
+func TestNotifierAlerts(t *testing.T) {
+	cfg := &models.Config{
+		Locale: "en-US",
+		Notify: models.NotifyConfig{
+			Channels: []models.NotifyChannel{
+				{Name: "ops", Type: models.NotifyNtfy, Topic: "ops"},
+				{Name: "oncall", Type: models.NotifyNtfy, Topic: "oncall", Events: []string{"config_reloaded"}},
+			},
+			Alerts: models.AlertPolicy{RenotifyHours: 6, EscalateAfterHours: 12, EscalateTo: []string{"oncall"}},
+		},
+	}
+	ops, oncall := &recorder{}, &recorder{}
+	n, err := NewNotifier(cfg, map[string]Sender{"ops": ops, "oncall": oncall})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	start := time.Now()
+	exceeded := events.Event{Type: events.LimitExceeded, VMID: 100, Rule: "monthly", Reason: "over"}
+	fire := func(after time.Duration) {
+		if notice, ok := n.alerts.fire(exceeded, n.policy, start.Add(after)); ok {
+			n.sendAlert(notice)
+		}
+	}
+	fire(0)
+	fire(time.Hour) // 每个采集周期的超限不重复通知
+	fire(6 * time.Hour)
+	fire(7 * time.Hour)
+	fire(12 * time.Hour)
+	fire(13 * time.Hour)
+	for _, notice := range n.alerts.resolve(100, start.Add(14*time.Hour+30*time.Minute)) {
+		n.sendAlert(notice)
+	}
+	fire(15 * time.Hour) // 恢复后再次超限重新开始告警
+
+	want := []string{
+		"VM100 exceeded the limit of rule monthly: over",
+		"VM100 still exceeds the limit of rule monthly (for 6h): over",
+		"[Escalated] VM100 has exceeded the limit of rule monthly for 12h: over",
+		"VM100 alert for rule monthly resolved (lasted 14h30m)",
+		"VM100 exceeded the limit of rule monthly: over",
+	}
+	if strings.Join(ops.texts, "|") != strings.Join(want, "|") {
+		t.Fatalf("ops got %q, want %q", ops.texts, want)
+	}
+	if len(oncall.texts) != 2 || !strings.HasPrefix(oncall.texts[0], "[Escalated]") || !strings.Contains(oncall.texts[1], "resolved") {
+		t.Fatalf("oncall got %q, want escalation and resolve notices", oncall.texts)
+	}
+}
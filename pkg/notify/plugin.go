@@ -0,0 +1,269 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"pve-traffic-monitor/pkg/events"
+	"pve-traffic-monitor/pkg/i18n"
+	"pve-traffic-monitor/pkg/models"
+)
+
+func init() {
+	events.Register("notify", newNotifier)
+}
+
+// channel 已创建的通知通道
+type channel struct {
+	config models.NotifyChannel
+	sender Sender
+}
+
+// Notifier 通知插件：按规则选择通道，渲染消息并限制重复通知的频率；超限告警按告警策略重复、升级和解除
+type Notifier struct {
+	channels  []channel
+	rules     map[string][]string // 规则名称 -> 通道名称（未配置的规则发送到所有通道）
+	templates map[string]*template.Template
+	repeat    time.Duration
+	locale    string
+	policy    models.AlertPolicy
+	alerts    *alertTracker
+
+	mu   sync.Mutex
+	sent map[string]time.Time // 通道/事件/虚拟机/规则/结果 -> 最近发送时间
+}
+
+// newNotifier 配置了通知通道时创建通知插件
+func newNotifier(cfg *models.Config) (events.Subscriber, error) {
+	if len(cfg.Notify.Channels) == 0 {
+		return nil, nil
+	}
+	n, err := NewNotifier(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	n.alerts = sharedAlerts
+	return n, nil
+}
+
+// NewNotifier 创建通知插件（senders 为空时按通道配置创建，测试时可以替换）
+func NewNotifier(cfg *models.Config, senders map[string]Sender) (*Notifier, error) {
+	n := &Notifier{
+		rules:     make(map[string][]string),
+		templates: make(map[string]*template.Template),
+		repeat:    cfg.Notify.RepeatInterval(),
+		locale:    cfg.Locale,
+		policy:    cfg.Notify.Alerts,
+		alerts:    newAlertTracker(),
+		sent:      make(map[string]time.Time),
+	}
+	for _, config := range cfg.Notify.Channels {
+		sender := senders[config.Name]
+		if sender == nil {
+			var err error
+			if sender, err = New(config, nil); err != nil {
+				return nil, fmt.Errorf("通道 %s: %w", config.Name, err)
+			}
+		}
+		n.channels = append(n.channels, channel{config: config, sender: sender})
+	}
+	for _, rule := range cfg.Rules {
+		if len(rule.Notify) > 0 {
+			n.rules[rule.Name] = rule.Notify
+		}
+	}
+	for eventType, text := range cfg.Notify.Templates {
+		tmpl, err := template.New(eventType).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("模板 %s 无效: %w", eventType, err)
+		}
+		n.templates[eventType] = tmpl
+	}
+	return n, nil
+}
+
+// Types 可以发送通知的事件（各通道再按自己订阅的类型过滤）
+func (n *Notifier) Types() []events.Type {
+	return []events.Type{events.LimitWarning, events.LimitExceeded, events.ActionExecuted, events.RecoveryDone, events.ConfigReloaded, events.CollectionGap, events.ExternalChange, events.RatioAnomaly}
+}
+
+// Handle 处理事件：超限事件交给告警状态机，恢复时解除告警，其他事件直接发送
+func (n *Notifier) Handle(ev events.Event) {
+	now := time.Now()
+	switch ev.Type {
+	case events.LimitExceeded:
+		if notice, ok := n.alerts.fire(ev, n.policy, now); ok {
+			n.sendAlert(notice)
+		}
+		return
+	case events.RecoveryDone:
+		for _, notice := range n.alerts.resolve(ev.VMID, now) {
+			n.sendAlert(notice)
+		}
+	}
+	n.sendEvent(ev, now)
+}
+
+// sendAlert 发送告警通知：订阅了 alert 且被规则选中的通道，升级后还包括 escalate_to 中的通道
+func (n *Notifier) sendAlert(notice AlertNotice) {
+	escalate := make(map[string]bool)
+	if notice.Escalated {
+		for _, name := range n.policy.EscalateTo {
+			escalate[name] = true
+		}
+	}
+
+	var msg *Message
+	for _, ch := range n.channels {
+		subscribed := ch.config.Subscribes(models.NotifyAlert) && n.selected(ch.config.Name, notice.Rule)
+		if !subscribed && !escalate[ch.config.Name] {
+			continue
+		}
+
+		if msg == nil {
+			text, err := n.renderAlert(notice)
+			if err != nil {
+				log.Printf("渲染告警通知失败: %v", err)
+				return
+			}
+			msg = &Message{Title: i18n.Tl(n.locale, "notify.title"), Text: text}
+		}
+		if err := ch.sender.Send(*msg); err != nil {
+			log.Printf("通知通道 %s 发送失败: %v", ch.config.Name, err)
+		}
+	}
+}
+
+// sendEvent 把事件发送到订阅了该类型且被规则选中的通道
+func (n *Notifier) sendEvent(ev events.Event, now time.Time) {
+	var msg *Message
+	for _, ch := range n.channels {
+		if !ch.config.Subscribes(string(ev.Type)) || !n.selected(ch.config.Name, ev.Rule) {
+			continue
+		}
+		if !n.allow(ch.config.Name, ev, now) {
+			continue
+		}
+
+		if msg == nil {
+			text, err := n.render(ev)
+			if err != nil {
+				log.Printf("渲染 %s 通知失败: %v", ev.Type, err)
+				return
+			}
+			msg = &Message{Title: i18n.Tl(n.locale, "notify.title"), Text: text}
+		}
+		if err := ch.sender.Send(*msg); err != nil {
+			log.Printf("通知通道 %s 发送失败: %v", ch.config.Name, err)
+		}
+	}
+}
+
+// selected 规则是否选择了该通道（规则未配置 notify 或事件不属于规则时发送到所有通道）
+func (n *Notifier) selected(name, rule string) bool {
+	names, ok := n.rules[rule]
+	if !ok {
+		return true
+	}
+	for _, selected := range names {
+		if selected == name {
+			return true
+		}
+	}
+	return false
+}
+
+// allow 同一通道、事件、虚拟机、规则和结果的通知在间隔内只发送一次
+func (n *Notifier) allow(name string, ev events.Event, now time.Time) bool {
+	if n.repeat <= 0 {
+		return true
+	}
+	result := ""
+	if ev.Action != nil {
+		result = fmt.Sprint(ev.Action.Success)
+	}
+	key := fmt.Sprintf("%s/%s/%d/%s/%s", name, ev.Type, ev.VMID, ev.Rule, result)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, at := range n.sent {
+		if now.Sub(at) >= n.repeat {
+			delete(n.sent, k)
+		}
+	}
+	if _, ok := n.sent[key]; ok {
+		return false
+	}
+	n.sent[key] = now
+	return true
+}
+
+// render 渲染消息内容（配置了模板时使用模板，否则使用当前语言的默认消息）
+func (n *Notifier) render(ev events.Event) (string, error) {
+	if tmpl, ok := n.templates[string(ev.Type)]; ok {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, ev); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	switch {
+	case ev.Type == events.LimitWarning && ev.Usage != nil:
+		return i18n.Tl(n.locale, "notify.limit_warning", ev.VMID, ev.Rule, ev.Usage.Percent, ev.Usage.UsedGB, ev.Usage.LimitGB), nil
+	case ev.Type == events.ActionExecuted && ev.Action != nil:
+		action := n.actionName(ev.Action.Action)
+		if ev.Action.Success {
+			return i18n.Tl(n.locale, "notify.action_ok", ev.VMID, ev.Rule, action, ev.Action.Reason), nil
+		}
+		return i18n.Tl(n.locale, "notify.action_failed", ev.VMID, ev.Rule, action, ev.Action.Error, ev.Action.Reason), nil
+	case ev.Type == events.RecoveryDone && ev.Recovery != nil:
+		return i18n.Tl(n.locale, "notify.recovery_done", ev.VMID, ev.Rule, n.actionName(ev.Recovery.ActionTaken)), nil
+	case ev.Type == events.ConfigReloaded:
+		return i18n.Tl(n.locale, "notify.config_reloaded"), nil
+	case ev.Type == events.ExternalChange && ev.Power != nil && ev.Recovery != nil:
+		return i18n.Tl(n.locale, "notify.external_change", ev.VMID, ev.Rule, n.actionName(ev.Recovery.ActionTaken), ev.Power.From, ev.Power.To), nil
+	case ev.Type == events.RatioAnomaly && ev.Ratio != nil:
+		return i18n.Tl(n.locale, "notify.ratio_anomaly", ev.VMID, ev.Ratio.WindowMinutes, ev.Ratio.UploadMbps, ev.Ratio.DownloadMbps, ev.Ratio.Ratio, ev.Ratio.Threshold), nil
+	case ev.Type == events.CollectionGap && ev.Gap != nil:
+		text := i18n.Tl(n.locale, "notify.collection_gap", ev.VMID, ev.Gap.Intervals, ev.Gap.LastSample.Format("2006-01-02 15:04:05"))
+		if ev.Gap.Error != "" {
+			text += "\n" + i18n.Tl(n.locale, "notify.collection_gap_error", ev.Gap.Error)
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("事件缺少内容")
+	}
+}
+
+// renderAlert 渲染告警通知（配置了 templates.alert 时使用模板）
+func (n *Notifier) renderAlert(notice AlertNotice) (string, error) {
+	if tmpl, ok := n.templates[models.NotifyAlert]; ok {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, notice); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	duration := formatDuration(notice.Duration)
+	switch notice.Phase {
+	case AlertFiring:
+		return i18n.Tl(n.locale, "notify.alert_firing", notice.VMID, notice.Rule, notice.Reason), nil
+	case AlertRepeat:
+		return i18n.Tl(n.locale, "notify.alert_repeat", notice.VMID, notice.Rule, duration, notice.Reason), nil
+	case AlertEscalated:
+		return i18n.Tl(n.locale, "notify.alert_escalated", notice.VMID, notice.Rule, duration, notice.Reason), nil
+	default:
+		return i18n.Tl(n.locale, "notify.alert_resolved", notice.VMID, notice.Rule, duration), nil
+	}
+}
+
+// actionName 操作的显示名称
+func (n *Notifier) actionName(action string) string {
+	return i18n.Tl(n.locale, "ui.action."+action)
+}
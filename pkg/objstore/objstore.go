@@ -0,0 +1,50 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Object 对象存储中的对象
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Uploader 可上传文件的远程目标（S3 或 WebDAV）
+type Uploader interface {
+	// Put 上传文件（覆盖已有文件），key 为相对目标的路径
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Location 文件的位置（用于日志和命令输出）
+	Location(key string) string
+}
+
+// NewUploader 按导出目标配置创建上传目标
+func NewUploader(dest models.ExportDestination) (Uploader, error) {
+	if err := dest.Validate(); err != nil {
+		return nil, err
+	}
+	switch dest.Type {
+	case models.ExportDestinationS3:
+		return NewS3Client(S3Options{
+			Endpoint:  dest.URL,
+			Region:    dest.Region,
+			Bucket:    dest.Bucket,
+			AccessKey: dest.AccessKey,
+			SecretKey: dest.SecretKey,
+			PathStyle: dest.PathStyle,
+		})
+	case models.ExportDestinationWebDAV:
+		return NewWebDAVClient(dest.URL, dest.Username, dest.Password)
+	default:
+		return nil, fmt.Errorf("不支持的导出目标类型: %s", dest.Type)
+	}
+}
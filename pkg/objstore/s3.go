@@ -0,0 +1,307 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Timeout 每次对象存储请求的超时时间（上传一个月的数据可能较慢）
+const s3Timeout = 10 * time.Minute
+
+// defaultRegion 未配置区域时签名使用的区域
+const defaultRegion = "us-east-1"
+
+// S3Options S3 兼容对象存储的连接参数
+type S3Options struct {
+	Endpoint  string // 对象存储地址，如 https://s3.amazonaws.com、http://minio.local:9000
+	Region    string // 签名使用的区域（留空为 us-east-1）
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用路径形式访问存储桶（MinIO 等需要）
+}
+
+// S3Client S3 兼容对象存储客户端（只实现归档和导出需要的操作，使用 AWS Signature V4 签名）
+type S3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Client 创建对象存储客户端
+func NewS3Client(opts S3Options) (*S3Client, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("endpoint无效: %s", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, errors.New("bucket不能为空")
+	}
+	region := opts.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	return &S3Client{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    opts.Bucket,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		pathStyle: opts.PathStyle,
+		client:    &http.Client{Timeout: s3Timeout},
+		now:       time.Now,
+	}, nil
+}
+
+// Location 对象的位置（s3://bucket/key，用于日志和命令输出）
+func (c *S3Client) Location(key string) string {
+	return "s3://" + c.bucket + "/" + key
+}
+
+// Put 上传对象
+func (c *S3Client) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, body, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get 下载对象
+func (c *S3Client) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取对象 %s 失败: %w", key, err)
+	}
+	return data, nil
+}
+
+// listResult ListObjectsV2 的响应
+type listResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List 列出对象（自动翻页）
+func (c *S3Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析对象列表失败: %w", err)
+		}
+
+		for _, item := range result.Contents {
+			objects = append(objects, Object{Key: item.Key, Size: item.Size, LastModified: item.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// s3Error 对象存储返回的错误
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do 发送签名请求，非 2xx 响应转换为错误（404 为 ErrObjectNotFound）
+func (c *S3Client) do(ctx context.Context, method, key string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.sign(req, body, c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求对象存储失败: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var s3err s3Error
+	if xml.Unmarshal(data, &s3err) == nil && s3err.Code != "" {
+		return nil, fmt.Errorf("对象存储返回 %d: %s: %s", resp.StatusCode, s3err.Code, s3err.Message)
+	}
+	return nil, fmt.Errorf("对象存储返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// objectURL 对象地址（路径形式为 endpoint/bucket/key，否则为 bucket.host/key）
+func (c *S3Client) objectURL(key string, query url.Values) string {
+	host := c.endpoint.Host
+	path := strings.TrimSuffix(c.endpoint.EscapedPath(), "/")
+	if c.pathStyle {
+		path += "/" + escapePath(c.bucket)
+	} else {
+		host = c.bucket + "." + host
+	}
+	path += "/" + escapePath(key)
+
+	if raw := canonicalQuery(query); raw != "" {
+		path += "?" + raw
+	}
+	return c.endpoint.Scheme + "://" + host + path
+}
+
+// sign 按 AWS Signature V4 为请求签名（签名 host、range、content-type 和所有 x-amz-* 请求头）
+func (c *S3Client) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery 按参数名排序并编码的查询字符串
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath 编码对象名（保留 /）
+func escapePath(key string) string {
+	return escape(key, false)
+}
+
+// escape 按 AWS 规则编码：只保留 A-Z a-z 0-9 - _ . ~（encodeSlash 为 false 时还保留 /）
+func escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
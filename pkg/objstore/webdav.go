@@ -0,0 +1,121 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webdavTimeout 每次 WebDAV 请求的超时时间
+const webdavTimeout = 5 * time.Minute
+
+// WebDAVClient WebDAV 上传目标（PUT 上传，父目录不存在时用 MKCOL 逐级创建）
+type WebDAVClient struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAVClient 创建 WebDAV 客户端（base 为上传目录地址）
+func NewWebDAVClient(base, username, password string) (*WebDAVClient, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url无效: %s", base)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	return &WebDAVClient{
+		base:     u,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: webdavTimeout},
+	}, nil
+}
+
+// Location 文件的地址
+func (c *WebDAVClient) Location(key string) string {
+	return c.url(key)
+}
+
+// url 相对目录地址的文件地址
+func (c *WebDAVClient) url(key string) string {
+	u := *c.base
+	u.Path += strings.TrimPrefix(key, "/")
+	return u.String()
+}
+
+// Put 上传文件（父目录不存在时先创建再重试一次）
+func (c *WebDAVClient) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	status, err := c.put(ctx, key, body, contentType)
+	if err != nil {
+		return err
+	}
+	// 父目录不存在时服务器返回 409 Conflict
+	if status == http.StatusConflict {
+		if err := c.mkdirs(ctx, key); err != nil {
+			return err
+		}
+		if status, err = c.put(ctx, key, body, contentType); err != nil {
+			return err
+		}
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("WebDAV 上传 %s 返回 %d", key, status)
+	}
+	return nil
+}
+
+// put 发送 PUT 请求，返回状态码
+func (c *WebDAVClient) put(ctx context.Context, key string, body []byte, contentType string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(key), bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.send(req)
+}
+
+// mkdirs 逐级创建 key 的父目录（已存在时服务器返回 405，忽略）
+func (c *WebDAVClient) mkdirs(ctx context.Context, key string) error {
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	dir := ""
+	for _, part := range parts[:len(parts)-1] {
+		dir += part + "/"
+		req, err := http.NewRequestWithContext(ctx, "MKCOL", c.url(dir), nil)
+		if err != nil {
+			return fmt.Errorf("创建请求失败: %w", err)
+		}
+		status, err := c.send(req)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusCreated && status != http.StatusMethodNotAllowed && (status < 200 || status >= 300) {
+			return fmt.Errorf("WebDAV 创建目录 %s 返回 %d", dir, status)
+		}
+	}
+	return nil
+}
+
+// send 发送请求（配置了用户名时使用基本认证）
+func (c *WebDAVClient) send(req *http.Request) (int, error) {
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求 WebDAV 失败: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
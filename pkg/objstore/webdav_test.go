@@ -0,0 +1,73 @@
+package objstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWebDAVPutCreatesParents(t *testing.T) {
+	var mu sync.Mutex
+	dirs := map[string]bool{"/dav/": true}
+	files := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		parent := r.URL.Path[:strings.LastIndex(strings.TrimSuffix(r.URL.Path, "/"), "/")+1]
+		switch r.Method {
+		case "MKCOL":
+			if dirs[r.URL.Path] {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if !dirs[parent] {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			dirs[r.URL.Path] = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			if !dirs[parent] {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = r.Header.Get("Content-Type") + ":" + string(body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewWebDAVClient(server.URL+"/dav", "u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.Location("reports/vm/101.json"); got != server.URL+"/dav/reports/vm/101.json" {
+		t.Fatalf("location = %s", got)
+	}
+	if err := client.Put(context.Background(), "reports/vm/101.json", []byte("{}"), "application/json"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if files["/dav/reports/vm/101.json"] != "application/json:{}" {
+		t.Fatalf("files = %v", files)
+	}
+	// 目录已存在时直接上传
+	if err := client.Put(context.Background(), "reports/vm/102.json", []byte("[]"), "application/json"); err != nil {
+		t.Fatalf("put into existing directory: %v", err)
+	}
+
+	denied, _ := NewWebDAVClient(server.URL+"/dav/", "u", "wrong")
+	if err := denied.Put(context.Background(), "x.json", nil, ""); err == nil {
+		t.Fatal("put with wrong password succeeded")
+	}
+}
@@ -3,52 +3,161 @@ package period
 import (
 	"fmt"
 	"time"
+
+	"pve-traffic-monitor/pkg/models"
 )
 
 // PeriodType 周期类型
 type PeriodType string
 
 const (
-	PeriodHour  PeriodType = "hour"
-	PeriodDay   PeriodType = "day"
-	PeriodMonth PeriodType = "month"
+	PeriodMinute PeriodType = "minute"
+	PeriodHour   PeriodType = "hour"
+	PeriodDay    PeriodType = "day"
+	PeriodMonth  PeriodType = "month"
+)
+
+// 周期划分方式
+const (
+	BasisCalendar     = "calendar"      // 自然周期（整点/零点/月初）
+	BasisCreationTime = "creation_time" // 以虚拟机创建时间为基准
+	BasisAnchor       = "anchor"        // 以账单日为基准
+	BasisRolling      = "rolling"       // 滑动窗口
 )
 
+// Supported 是否为支持的周期（hour/day/month/minute 或 rolling:<N>h/d 滑动窗口）
+func Supported(period string) bool {
+	switch PeriodType(period) {
+	case PeriodMinute, PeriodHour, PeriodDay, PeriodMonth:
+		return true
+	}
+	_, rolling := models.ParseRollingPeriod(period)
+	return rolling
+}
+
 // Calculator 周期计算器
 type Calculator struct {
 	periodType      PeriodType
 	creationTime    time.Time
-	useCreationTime bool // 是否使用创建时间作为周期基准
+	useCreationTime bool           // 是否使用创建时间作为周期基准
+	loc             *time.Location // 周期边界所在时区（nil 使用默认时区）
+	anchorDay       int            // 月周期的账单日（1-31，0 表示月初；大于当月天数时为月末）
+	anchorHour      int            // 账单日的重置小时（0-23）
+	window          time.Duration  // 滑动窗口长度（rolling:<N>h/d 周期，0 表示日历周期）
 }
 
 // NewCalculator 创建周期计算器
+// 滑动窗口周期（rolling:7d 等，以及 minute）没有固定边界，周期开始为 t 之前一个窗口长度
 func NewCalculator(periodType string, creationTime time.Time, useCreationTime bool) *Calculator {
+	window, _ := models.ParseRollingPeriod(periodType)
+	if PeriodType(periodType) == PeriodMinute {
+		window = models.MinuteWindow()
+	}
 	return &Calculator{
 		periodType:      PeriodType(periodType),
 		creationTime:    creationTime,
 		useCreationTime: useCreationTime,
+		window:          window,
+	}
+}
+
+// In 返回使用指定时区划分周期的计算器副本（nil 使用默认时区）
+func (c *Calculator) In(loc *time.Location) *Calculator {
+	copied := *c
+	copied.loc = loc
+	return &copied
+}
+
+// WithAnchor 返回按账单日划分月周期的计算器副本（每月 day 日 hour 时重置，day 为 0 时不使用账单日）
+// 账单日优先于创建时间
+func (c *Calculator) WithAnchor(day, hour int) *Calculator {
+	copied := *c
+	copied.anchorDay = day
+	copied.anchorHour = hour
+	return &copied
+}
+
+// Period 周期类型
+func (c *Calculator) Period() string {
+	return string(c.periodType)
+}
+
+// Rolling 是否为滑动窗口周期，返回窗口长度
+func (c *Calculator) Rolling() (time.Duration, bool) {
+	return c.window, c.window > 0
+}
+
+// Basis 周期的划分方式（与 PeriodStartAt 的优先级一致）
+func (c *Calculator) Basis() string {
+	switch {
+	case c.window > 0:
+		return BasisRolling
+	case c.anchored():
+		return BasisAnchor
+	case c.useCreationTime && !c.creationTime.IsZero():
+		return BasisCreationTime
+	default:
+		return BasisCalendar
+	}
+}
+
+// anchored 是否按账单日划分月周期
+func (c *Calculator) anchored() bool {
+	return c.periodType == PeriodMonth && c.anchorDay > 0
+}
+
+// location 周期边界所在时区
+func (c *Calculator) location() *time.Location {
+	if c.loc != nil {
+		return c.loc
 	}
+	return Location()
 }
 
 // GetCurrentPeriodStart 获取当前周期开始时间
 func (c *Calculator) GetCurrentPeriodStart() time.Time {
-	now := time.Now()
+	return c.PeriodStartAt(time.Now())
+}
 
+// GetNextPeriodStart 获取下一个周期开始时间
+func (c *Calculator) GetNextPeriodStart() time.Time {
+	return c.NextPeriodStartAt(time.Now())
+}
+
+// PeriodStartAt 获取时间 t 所在周期的开始时间（用于回放历史数据）
+func (c *Calculator) PeriodStartAt(t time.Time) time.Time {
+	t = t.In(c.location())
+	if c.window > 0 {
+		return t.Add(-c.window)
+	}
+	if c.anchored() {
+		return c.getAnchoredPeriodStart(t)
+	}
 	if !c.useCreationTime || c.creationTime.IsZero() {
 		// 使用固定周期（月初/日初/小时初）
-		return c.getFixedPeriodStart(now)
+		return c.getFixedPeriodStart(t)
 	}
 
 	// 使用创建时间作为基准
-	return c.getCreationBasedPeriodStart(now)
+	return c.getCreationBasedPeriodStart(t)
 }
 
-// GetNextPeriodStart 获取下一个周期开始时间
-func (c *Calculator) GetNextPeriodStart() time.Time {
-	currentStart := c.GetCurrentPeriodStart()
+// NextPeriodStartAt 获取时间 t 所在周期的下一个周期开始时间
+// 滑动窗口周期返回 t 之后一个窗口长度，即 t 之前的流量全部移出窗口的时间
+func (c *Calculator) NextPeriodStartAt(t time.Time) time.Time {
+	t = t.In(c.location())
+	if c.window > 0 {
+		return t.Add(c.window)
+	}
+	currentStart := c.PeriodStartAt(t)
+
+	if c.anchored() {
+		year, month := addMonths(currentStart.Year(), currentStart.Month(), 1)
+		return c.anchorIn(year, month, currentStart.Location())
+	}
 
 	if c.useCreationTime && !c.creationTime.IsZero() {
-		return CalculateNextCreationBasedPeriodStart(string(c.periodType), c.creationTime, time.Now())
+		return CalculateNextCreationBasedPeriodStart(string(c.periodType), c.creationTime.In(c.location()), t)
 	}
 
 	switch c.periodType {
@@ -71,22 +180,43 @@ func (c *Calculator) GetPeriodRange() (start time.Time, end time.Time) {
 }
 
 // getFixedPeriodStart 获取固定周期的开始时间（传统方式）
+// 小时周期按当前时刻减去分秒计算，夏令时结束时重复的小时不会被合并为一个周期
 func (c *Calculator) getFixedPeriodStart(now time.Time) time.Time {
+	hourStart := now.Add(-time.Duration(now.Minute())*time.Minute -
+		time.Duration(now.Second())*time.Second -
+		time.Duration(now.Nanosecond()))
+
 	switch c.periodType {
 	case PeriodHour:
-		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+		return hourStart
 	case PeriodDay:
 		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	case PeriodMonth:
 		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	default:
-		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+		return hourStart
 	}
 }
 
 // getCreationBasedPeriodStart 基于创建时间计算周期开始时间
 func (c *Calculator) getCreationBasedPeriodStart(now time.Time) time.Time {
-	return CalculateCreationBasedPeriodStart(string(c.periodType), c.creationTime, now)
+	return CalculateCreationBasedPeriodStart(string(c.periodType), c.creationTime.In(now.Location()), now)
+}
+
+// getAnchoredPeriodStart 按账单日计算月周期开始时间：本月账单日未到时为上月账单日
+func (c *Calculator) getAnchoredPeriodStart(now time.Time) time.Time {
+	start := c.anchorIn(now.Year(), now.Month(), now.Location())
+	if now.Before(start) {
+		year, month := addMonths(now.Year(), now.Month(), -1)
+		start = c.anchorIn(year, month, now.Location())
+	}
+	return start
+}
+
+// anchorIn 指定月份的账单日重置时间（账单日大于当月天数时取月末）
+func (c *Calculator) anchorIn(year int, month time.Month, loc *time.Location) time.Time {
+	day := minInt(c.anchorDay, daysInMonth(year, month))
+	return time.Date(year, month, day, c.anchorHour, 0, 0, 0, loc)
 }
 
 // CalculateCreationBasedPeriodStart 基于创建时间计算当前周期开始时间。
@@ -103,9 +233,12 @@ func CalculateCreationBasedPeriodStart(periodType string, creation, now time.Tim
 
 	case PeriodDay:
 		// 从创建时间开始，每天一个周期
-		// 保持创建时的小时和分钟
-		daysSinceCreation := int(now.Sub(creation).Hours() / 24)
-		return creation.AddDate(0, 0, daysSinceCreation)
+		// 保持创建时的小时和分钟（按日历天数计算，夏令时切换当天仍在创建时的钟点切换）
+		start := creation.AddDate(0, 0, calendarDays(creation, now))
+		if now.Before(start) {
+			start = start.AddDate(0, 0, -1)
+		}
+		return start
 
 	case PeriodMonth:
 		// 从创建时间开始，每月一个周期
@@ -137,6 +270,14 @@ func CalculateNextCreationBasedPeriodStart(periodType string, creation, now time
 	}
 }
 
+// calendarDays 两个时间之间相差的日历天数（按 from 所在时区的日期计算）
+func calendarDays(from, to time.Time) int {
+	to = to.In(from.Location())
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
 func monthPeriodIndex(creation, now time.Time) int {
 	monthsSinceCreation := (now.Year()-creation.Year())*12 + int(now.Month()-creation.Month())
 	candidate := monthPeriodStart(creation, monthsSinceCreation)
@@ -189,6 +330,22 @@ func (c *Calculator) FormatPeriod() string {
 		periodName = "每月"
 	}
 
+	if c.window > 0 {
+		return fmt.Sprintf("滑动窗口 (最近 %v: %s - %s)",
+			c.window,
+			start.Format("01-02 15:04"),
+			time.Now().In(c.location()).Format("01-02 15:04"))
+	}
+
+	if c.anchored() {
+		return fmt.Sprintf("%s周期 (账单日 %d 日 %02d:00: %s - %s)",
+			periodName,
+			c.anchorDay,
+			c.anchorHour,
+			start.Format("01-02 15:04"),
+			end.Format("01-02 15:04"))
+	}
+
 	if c.useCreationTime && !c.creationTime.IsZero() {
 		return fmt.Sprintf("%s周期 (基于创建时间: %s - %s)",
 			periodName,
@@ -3,6 +3,8 @@ package period
 import (
 	"testing"
 	"time"
+
+	"pve-traffic-monitor/pkg/models"
 )
 
 func TestCalculateCreationBasedPeriodStart(t *testing.T) {
@@ -65,3 +67,178 @@ func TestCalculateNextCreationBasedPeriodStartClampsMonthEnd(t *testing.T) {
 		t.Fatalf("next period start = %s, want %s", got, want)
 	}
 }
+
+func TestCalculatorInLocation(t *testing.T) {
+	shanghai, err := LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	// 2026-03-01 20:00 UTC 为上海时间 3 月 2 日 04:00
+	at := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		period string
+		loc    *time.Location
+		start  time.Time
+		next   time.Time
+	}{
+		{"day", time.UTC, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"day", shanghai, time.Date(2026, 3, 2, 0, 0, 0, 0, shanghai), time.Date(2026, 3, 3, 0, 0, 0, 0, shanghai)},
+		{"month", time.UTC, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"month", shanghai, time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai), time.Date(2026, 4, 1, 0, 0, 0, 0, shanghai)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period+"/"+tt.loc.String(), func(t *testing.T) {
+			calc := NewCalculator(tt.period, time.Time{}, false).In(tt.loc)
+			if got := calc.PeriodStartAt(at); !got.Equal(tt.start) {
+				t.Fatalf("PeriodStartAt = %s, want %s", got, tt.start)
+			}
+			if got := calc.NextPeriodStartAt(at); !got.Equal(tt.next) {
+				t.Fatalf("NextPeriodStartAt = %s, want %s", got, tt.next)
+			}
+		})
+	}
+}
+
+func TestCalculatorWithAnchor(t *testing.T) {
+	tests := []struct {
+		name  string
+		day   int
+		hour  int
+		at    time.Time
+		start time.Time
+		next  time.Time
+	}{
+		{
+			name:  "after anchor",
+			day:   5,
+			hour:  8,
+			at:    time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+			start: time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC),
+			next:  time.Date(2026, 4, 5, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "before anchor hour",
+			day:   5,
+			hour:  8,
+			at:    time.Date(2026, 3, 5, 7, 59, 0, 0, time.UTC),
+			start: time.Date(2026, 2, 5, 8, 0, 0, 0, time.UTC),
+			next:  time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "year boundary",
+			day:   15,
+			at:    time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC),
+			start: time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC),
+			next:  time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "clamps to month end",
+			day:   31,
+			at:    time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC),
+			start: time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+			next:  time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc := NewCalculator("month", time.Time{}, false).In(time.UTC).WithAnchor(tt.day, tt.hour)
+			if got := calc.PeriodStartAt(tt.at); !got.Equal(tt.start) {
+				t.Fatalf("PeriodStartAt = %s, want %s", got, tt.start)
+			}
+			if got := calc.NextPeriodStartAt(tt.at); !got.Equal(tt.next) {
+				t.Fatalf("NextPeriodStartAt = %s, want %s", got, tt.next)
+			}
+		})
+	}
+}
+
+func TestCalculatorAcrossDST(t *testing.T) {
+	ny, err := LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	// 2026-03-08 02:00 开始夏令时（当天 23 小时），2026-11-01 02:00 结束（当天 25 小时）
+	creation := time.Date(2026, 3, 1, 10, 0, 0, 0, ny)
+
+	tests := []struct {
+		name  string
+		calc  *Calculator
+		at    time.Time
+		start time.Time
+		next  time.Time
+	}{
+		{
+			name:  "fixed day on spring forward",
+			calc:  NewCalculator("day", time.Time{}, false).In(ny),
+			at:    time.Date(2026, 3, 8, 12, 0, 0, 0, ny),
+			start: time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+			next:  time.Date(2026, 3, 9, 0, 0, 0, 0, ny),
+		},
+		{
+			name:  "fixed hour in repeated hour",
+			calc:  NewCalculator("hour", time.Time{}, false).In(ny),
+			at:    time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), // 第二次出现的 01:30（EST）
+			start: time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC),
+			next:  time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "creation day keeps wall clock after spring forward",
+			calc:  NewCalculator("day", creation, true).In(ny),
+			at:    time.Date(2026, 3, 9, 10, 15, 0, 0, ny),
+			start: time.Date(2026, 3, 9, 10, 0, 0, 0, ny),
+			next:  time.Date(2026, 3, 10, 10, 0, 0, 0, ny),
+		},
+		{
+			name:  "creation day before wall clock",
+			calc:  NewCalculator("day", creation, true).In(ny),
+			at:    time.Date(2026, 3, 9, 9, 30, 0, 0, ny),
+			start: time.Date(2026, 3, 8, 10, 0, 0, 0, ny),
+			next:  time.Date(2026, 3, 9, 10, 0, 0, 0, ny),
+		},
+		{
+			name:  "creation month clamps to month end",
+			calc:  NewCalculator("month", time.Date(2026, 1, 31, 10, 0, 0, 0, ny), true).In(ny),
+			at:    time.Date(2026, 3, 10, 0, 0, 0, 0, ny),
+			start: time.Date(2026, 2, 28, 10, 0, 0, 0, ny),
+			next:  time.Date(2026, 3, 31, 10, 0, 0, 0, ny),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.calc.PeriodStartAt(tt.at); !got.Equal(tt.start) {
+				t.Fatalf("PeriodStartAt = %s, want %s", got, tt.start)
+			}
+			if got := tt.calc.NextPeriodStartAt(tt.at); !got.Equal(tt.next) {
+				t.Fatalf("NextPeriodStartAt = %s, want %s", got, tt.next)
+			}
+		})
+	}
+}
+
+func TestCalculatorMinuteWindow(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	calc := NewCalculator("minute", time.Time{}, false).In(time.UTC)
+
+	if got, want := calc.PeriodStartAt(at), at.Add(-5*time.Minute); !got.Equal(want) {
+		t.Fatalf("PeriodStartAt = %s, want %s", got, want)
+	}
+
+	// 窗口由 monitor.minute_window_seconds 配置，对所有存储后端相同
+	models.SetMinuteWindow(2 * time.Minute)
+	defer models.SetMinuteWindow(0)
+	calc = NewCalculator("minute", time.Time{}, false).In(time.UTC)
+	if got, want := calc.PeriodStartAt(at), at.Add(-2*time.Minute); !got.Equal(want) {
+		t.Fatalf("configured PeriodStartAt = %s, want %s", got, want)
+	}
+	rule := models.Rule{Period: models.PeriodMinute}
+	if window, rolling := rule.RollingWindow(); !rolling || window != 2*time.Minute {
+		t.Fatalf("RollingWindow = %s, %v", window, rolling)
+	}
+	if !Supported("minute") || !Supported("rolling:7d") || Supported("week") {
+		t.Fatalf("Supported mismatch")
+	}
+}
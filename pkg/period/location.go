@@ -0,0 +1,47 @@
+package period
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	locationMu sync.RWMutex
+	location   = time.Local // 周期边界使用的默认时区
+
+	locationCache sync.Map // 时区名称 -> *time.Location
+)
+
+// SetLocation 设置周期边界和图表时间使用的默认时区（nil 表示主机本地时区）
+func SetLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.Local
+	}
+	locationMu.Lock()
+	defer locationMu.Unlock()
+	location = loc
+}
+
+// Location 获取默认时区
+func Location() *time.Location {
+	locationMu.RLock()
+	defer locationMu.RUnlock()
+	return location
+}
+
+// LoadLocation 解析 IANA 时区名称（如 Asia/Shanghai），空字符串返回 nil 表示使用默认时区
+// 解析结果会被缓存，可在每个监控周期中调用
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
@@ -0,0 +1,16 @@
+package period
+
+import (
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ForRule 按规则的周期、时区、账单日和创建时间基准创建周期计算器
+// creationTime 为零值或规则未启用 use_creation_time 时使用固定周期；规则时区无效时使用默认时区（配置校验会拒绝无效时区）
+func ForRule(rule models.Rule, creationTime time.Time) *Calculator {
+	loc, _ := LoadLocation(rule.Timezone)
+	return NewCalculator(rule.Period, creationTime, rule.UseCreationTime && !creationTime.IsZero()).
+		In(loc).
+		WithAnchor(rule.AnchorDay, rule.AnchorHour)
+}
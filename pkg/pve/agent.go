@@ -0,0 +1,153 @@
+package pve
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// agentExecTimeout 等待客户机代理执行的命令结束的时间
+const agentExecTimeout = 5 * time.Second
+
+// agentPollInterval 查询客户机命令执行状态的间隔
+const agentPollInterval = 200 * time.Millisecond
+
+// protocolCountersCommand 读取客户机内核按协议统计的累计字节（IPv4 在 netstat 的 IpExt 中，IPv6 在 snmp6 中）
+// 客户机禁用 IPv6 时 snmp6 不存在，cat 以非零状态退出但仍输出 netstat
+var protocolCountersCommand = []string{"cat", "/proc/net/netstat", "/proc/net/snmp6"}
+
+// ProtocolCounters 客户机报告的按协议累计字节（客户机启动后累计，包括回环接口的流量）
+type ProtocolCounters struct {
+	IPv4RX uint64
+	IPv4TX uint64
+	IPv6RX uint64
+	IPv6TX uint64
+}
+
+// AgentExecResult 客户机代理执行命令的结果
+type AgentExecResult struct {
+	ExitCode int
+	Output   string
+	Error    string
+}
+
+// AgentExec 通过 QEMU 客户机代理在虚拟机内执行命令并等待结束（需要虚拟机启用并运行客户机代理）
+func (c *Client) AgentExec(vmid int, command ...string) (*AgentExecResult, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/agent", c.config.Node, vmid)
+	body, err := c.doPostForm(path+"/exec", url.Values{"command": command})
+	if err != nil {
+		return nil, fmt.Errorf("客户机代理执行命令失败: %w", err)
+	}
+	var started struct {
+		Data struct {
+			PID int `json:"pid"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &started); err != nil {
+		return nil, fmt.Errorf("解析客户机代理响应失败: %w", err)
+	}
+
+	deadline := time.Now().Add(agentExecTimeout)
+	for {
+		resp, err := c.client.R().
+			SetQueryParam("pid", strconv.Itoa(started.Data.PID)).
+			Get(path + "/exec-status")
+		if err != nil {
+			return nil, fmt.Errorf("查询客户机命令状态失败: %w", err)
+		}
+		if err := checkResty(resp); err != nil {
+			return nil, err
+		}
+
+		var status struct {
+			Data struct {
+				Exited   int    `json:"exited"`
+				ExitCode int    `json:"exitcode"`
+				OutData  string `json:"out-data"`
+				ErrData  string `json:"err-data"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(resp.Body(), &status); err != nil {
+			return nil, fmt.Errorf("解析客户机命令状态失败: %w", err)
+		}
+		if status.Data.Exited != 0 {
+			return &AgentExecResult{ExitCode: status.Data.ExitCode, Output: status.Data.OutData, Error: status.Data.ErrData}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("客户机命令 %d 在 %s 内未结束", started.Data.PID, agentExecTimeout)
+		}
+		time.Sleep(agentPollInterval)
+	}
+}
+
+// GetGuestProtocolCounters 通过客户机代理读取 Linux 客户机按 IPv4/IPv6 统计的累计字节
+func (c *Client) GetGuestProtocolCounters(vmid int) (ProtocolCounters, error) {
+	result, err := c.AgentExec(vmid, protocolCountersCommand...)
+	if err != nil {
+		return ProtocolCounters{}, err
+	}
+	counters, err := ParseProtocolCounters(result.Output)
+	if err != nil && result.Error != "" {
+		return ProtocolCounters{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(result.Error))
+	}
+	return counters, err
+}
+
+// ParseProtocolCounters 解析 /proc/net/netstat 和 /proc/net/snmp6 的内容
+// netstat 的 IpExt 为一行字段名加一行数值；snmp6 每行一个计数（没有 IPv6 时 IPv6 计数为 0）
+func ParseProtocolCounters(output string) (ProtocolCounters, error) {
+	var counters ProtocolCounters
+	var ipExtNames []string
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case fields[0] == "IpExt:" && ipExtNames == nil:
+			ipExtNames = fields[1:]
+		case fields[0] == "IpExt:":
+			for i, value := range fields[1:] {
+				if i >= len(ipExtNames) {
+					break
+				}
+				n, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					return counters, fmt.Errorf("解析 IpExt %s 失败: %w", ipExtNames[i], err)
+				}
+				switch ipExtNames[i] {
+				case "InOctets":
+					counters.IPv4RX = n
+					found = true
+				case "OutOctets":
+					counters.IPv4TX = n
+				}
+			}
+			ipExtNames = nil
+		case len(fields) == 2 && (fields[0] == "Ip6InOctets" || fields[0] == "Ip6OutOctets"):
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return counters, fmt.Errorf("解析 %s 失败: %w", fields[0], err)
+			}
+			if fields[0] == "Ip6InOctets" {
+				counters.IPv6RX = n
+			} else {
+				counters.IPv6TX = n
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return counters, err
+	}
+	if !found {
+		return counters, fmt.Errorf("客户机输出中没有 IpExt InOctets（仅支持 Linux 客户机）")
+	}
+	return counters, nil
+}
@@ -0,0 +1,32 @@
+package pve
+
+import "testing"
+
+func TestParseProtocolCounters(t *testing.T) {
+	output := `TcpExt: SyncookiesSent SyncookiesRecv
+TcpExt: 0 0
+IpExt: InNoRoutes InTruncatedPkts InMcastPkts OutMcastPkts InBcastPkts OutBcastPkts InOctets OutOctets
+IpExt: 0 0 12 0 3 0 1048576 524288
+Ip6InReceives                   	120
+Ip6InOctets                     	4096
+Ip6OutOctets                    	2048
+`
+	counters, err := ParseProtocolCounters(output)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := ProtocolCounters{IPv4RX: 1048576, IPv4TX: 524288, IPv6RX: 4096, IPv6TX: 2048}
+	if counters != want {
+		t.Fatalf("counters = %+v, want %+v", counters, want)
+	}
+
+	// 客户机禁用 IPv6 时只有 netstat
+	counters, err = ParseProtocolCounters("IpExt: InOctets OutOctets\nIpExt: 10 20\n")
+	if err != nil || counters != (ProtocolCounters{IPv4RX: 10, IPv4TX: 20}) {
+		t.Fatalf("IPv4 only = %+v, %v", counters, err)
+	}
+
+	if _, err := ParseProtocolCounters("cat: /proc/net/netstat: No such file or directory"); err == nil {
+		t.Fatal("output without IpExt parsed")
+	}
+}
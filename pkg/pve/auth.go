@@ -0,0 +1,171 @@
+package pve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// PVE 票据有效期为 2 小时，提前续期
+const (
+	ticketLifetime   = 2 * time.Hour
+	ticketRenewAfter = time.Hour
+)
+
+// authState 当前认证信息
+type authState struct {
+	method string // models.PVEAuthToken 或 models.PVEAuthTicket
+
+	// API Token 认证
+	tokenHeader string // Authorization 请求头
+
+	// 票据认证
+	username string
+	password string
+	ticket   string    // PVEAuthCookie
+	csrf     string    // 写操作需要的 CSRFPreventionToken
+	issuedAt time.Time // 票据获取时间
+}
+
+// Login 登录 PVE（按配置使用 API Token 或用户名密码票据认证）
+func (c *Client) Login() error {
+	if c.tlsErr != nil {
+		return fmt.Errorf("PVE 证书验证配置无效: %w", c.tlsErr)
+	}
+
+	cfg := c.config
+	if cfg.AuthMode() == models.PVEAuthToken {
+		// 环境变量优先于配置文件
+		if id := os.Getenv("PVE_API_TOKEN_ID"); id != "" {
+			cfg.APITokenID = id
+		}
+		if secret := os.Getenv("PVE_API_TOKEN_SECRET"); secret != "" {
+			cfg.APITokenSecret = secret
+		}
+	}
+
+	if err := c.UpdateCredentials(cfg); err != nil {
+		return err
+	}
+	log.Printf("[PVE] 认证配置完成 (%s)", cfg.AuthMode())
+	return nil
+}
+
+// UpdateCredentials 更换认证信息（密钥轮换、切换认证方式时调用，之后的请求使用新凭据）
+// 票据认证会立即登录，登录失败时保留原认证信息
+func (c *Client) UpdateCredentials(cfg models.PVEConfig) error {
+	state := authState{method: cfg.AuthMode()}
+
+	switch state.method {
+	case models.PVEAuthToken:
+		if cfg.APITokenID == "" || cfg.APITokenSecret == "" {
+			return fmt.Errorf("必须配置API Token (api_token_id 和 api_token_secret)")
+		}
+		log.Printf("[PVE] 使用API Token认证: %s", cfg.APITokenID)
+		// API Token格式: PVEAPIToken=USER@REALM!TOKENID=UUID
+		state.tokenHeader = fmt.Sprintf("PVEAPIToken=%s=%s", cfg.APITokenID, cfg.APITokenSecret)
+
+	case models.PVEAuthTicket:
+		if cfg.Username == "" || cfg.Password == "" {
+			return fmt.Errorf("票据认证需要配置 username 和 password")
+		}
+		log.Printf("[PVE] 使用票据认证: %s", cfg.Username)
+		state.username, state.password = cfg.Username, cfg.Password
+		if err := c.requestTicket(&state); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("无效的 PVE 认证方式: %s", state.method)
+	}
+
+	c.authMu.Lock()
+	c.auth = state
+	c.authMu.Unlock()
+	return nil
+}
+
+// authHeaders 获取请求的认证头（票据即将过期时先续期）
+func (c *Client) authHeaders(write bool) (map[string]string, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	switch c.auth.method {
+	case models.PVEAuthToken:
+		return map[string]string{"Authorization": c.auth.tokenHeader}, nil
+
+	case models.PVEAuthTicket:
+		if age := time.Since(c.auth.issuedAt); age >= ticketRenewAfter {
+			if err := c.requestTicket(&c.auth); err != nil {
+				// 续期失败时旧票据仍在有效期内则继续使用，下次请求重试
+				if age >= ticketLifetime {
+					return nil, fmt.Errorf("PVE 票据已过期且续期失败: %w", err)
+				}
+				log.Printf("[PVE] 票据续期失败: %v", err)
+			}
+		}
+		headers := map[string]string{"Cookie": "PVEAuthCookie=" + c.auth.ticket}
+		if write {
+			headers["CSRFPreventionToken"] = c.auth.csrf
+		}
+		return headers, nil
+
+	default:
+		return nil, fmt.Errorf("未登录 PVE")
+	}
+}
+
+// requestTicket 用用户名密码获取新票据（POST /access/ticket）
+func (c *Client) requestTicket(state *authState) error {
+	form := url.Values{}
+	form.Set("username", state.username)
+	form.Set("password", state.password)
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/access/ticket", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建登录请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("登录 PVE 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取登录响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("登录 PVE 失败: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data struct {
+			Ticket string `json:"ticket"`
+			CSRF   string `json:"CSRFPreventionToken"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析登录响应失败: %w", err)
+	}
+	if result.Data.Ticket == "" {
+		return fmt.Errorf("登录 PVE 失败: 用户名或密码错误")
+	}
+
+	state.ticket = result.Data.Ticket
+	state.csrf = result.Data.CSRF
+	state.issuedAt = time.Now()
+	debugLog("已获取 PVE 票据: %s", state.username)
+	return nil
+}
@@ -0,0 +1,65 @@
+package pve
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestTicketAuthRenewsTicket(t *testing.T) {
+	var logins atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/access/ticket":
+			if r.FormValue("username") != "monitor@pve" || r.FormValue("password") != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			n := logins.Add(1)
+			w.Write([]byte(`{"data": {"ticket": "ticket-` + strconv.Itoa(int(n)) + `", "CSRFPreventionToken": "csrf"}}`))
+
+		case "/api2/json/nodes/pve/qemu/100/status/stop":
+			if _, err := r.Cookie("PVEAuthCookie"); err != nil || r.Header.Get("CSRFPreventionToken") != "csrf" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"data": null}`))
+		}
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", Username: "monitor@pve", Password: "pass"})
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if _, err := client.StopVM(100); err != nil {
+		t.Fatalf("StopVM() error = %v", err)
+	}
+	if got := logins.Load(); got != 1 {
+		t.Fatalf("logins = %d, want 1", got)
+	}
+
+	// 票据超过续期时间后，下一次请求前重新登录
+	client.authMu.Lock()
+	client.auth.issuedAt = time.Now().Add(-ticketRenewAfter)
+	client.authMu.Unlock()
+	if _, err := client.StopVM(100); err != nil {
+		t.Fatalf("StopVM() after renewal error = %v", err)
+	}
+	if got := logins.Load(); got != 2 {
+		t.Fatalf("logins after renewal = %d, want 2", got)
+	}
+
+	wrong := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", Username: "monitor@pve", Password: "wrong"})
+	if err := wrong.Login(); err == nil {
+		t.Fatal("Login() with wrong password should fail")
+	}
+}
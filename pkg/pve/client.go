@@ -4,33 +4,51 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"pve-traffic-monitor/pkg/models"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
+// creationTimeCacheTTL 创建时间缓存时间（创建时间不会改变，定期重新读取以发现重建后复用的 VMID）
+const creationTimeCacheTTL = time.Hour
+
+// cachedCreationTime 缓存的虚拟机创建时间
+type cachedCreationTime struct {
+	at        time.Time
+	fetchedAt time.Time
+}
+
 // Client PVE API 客户端
 type Client struct {
 	config     models.PVEConfig
 	client     *resty.Client
 	httpClient *http.Client
 	baseURL    string
+	tlsErr     error // 证书验证配置错误（Login 时返回）
+
+	authMu sync.Mutex
+	auth   authState // 当前认证信息（令牌轮换、票据续期时更新）
+
+	creationTimes sync.Map // VMID -> cachedCreationTime
 }
 
-// NewClient 创建新的 PVE 客户端（本地访问模式）
+// NewClient 创建新的 PVE 客户端（证书验证方式见 TLSConfig，配置无效时 Login 返回错误）
 func NewClient(config models.PVEConfig) *Client {
+	// 证书配置无效时使用系统 CA 严格验证，不会退回到不验证证书
+	tlsConfig, tlsErr := TLSConfig(config)
+	if tlsErr != nil {
+		tlsConfig = &tls.Config{}
+	}
+
 	// 创建自定义的 HTTP Transport
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // 本地访问不验证证书
-		},
+		TLSClientConfig:    tlsConfig,
 		DisableCompression: true, // 禁用压缩
 		DisableKeepAlives:  false,
 		MaxIdleConns:       10,
@@ -60,43 +78,24 @@ func NewClient(config models.PVEConfig) *Client {
 	baseURL := fmt.Sprintf("https://%s:%d/api2/json", config.Host, config.Port)
 	client.SetBaseURL(baseURL)
 
-	return &Client{
+	c := &Client{
 		config:     config,
 		client:     client,
 		httpClient: httpClient,
 		baseURL:    baseURL,
-	}
-}
-
-// Login 登录 PVE（使用API Token认证）
-func (c *Client) Login() error {
-	// 从环境变量读取 API Token
-	apiTokenID := os.Getenv("PVE_API_TOKEN_ID")
-	apiTokenSecret := os.Getenv("PVE_API_TOKEN_SECRET")
-
-	// 如果环境变量不存在，使用配置文件
-	if apiTokenID == "" {
-		apiTokenID = c.config.APITokenID
-	}
-	if apiTokenSecret == "" {
-		apiTokenSecret = c.config.APITokenSecret
+		tlsErr:     tlsErr,
 	}
 
-	// 验证API Token配置
-	if apiTokenID == "" || apiTokenSecret == "" {
-		return fmt.Errorf("必须配置API Token (api_token_id 和 api_token_secret)")
-	}
-
-	log.Printf("[PVE] 使用API Token认证: %s", apiTokenID)
-
-	// API Token格式: PVEAPIToken=USER@REALM!TOKENID=UUID
-	authHeader := fmt.Sprintf("PVEAPIToken=%s=%s", apiTokenID, apiTokenSecret)
-
-	// 设置Authorization header
-	c.client.SetHeader("Authorization", authHeader)
-
-	log.Printf("[PVE] API Token认证配置完成")
-	return nil
+	// 每个请求发送时读取当前的认证信息（令牌轮换、票据续期后无需重建客户端）
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		headers, err := c.authHeaders(req.Method != http.MethodGet)
+		if err != nil {
+			return err
+		}
+		req.SetHeaders(headers)
+		return nil
+	})
+	return c
 }
 
 // GetAllVMs 获取所有虚拟机（默认过滤模板）
@@ -114,8 +113,8 @@ func (c *Client) GetAllVMsWithFilter(includeTemplates bool) ([]models.VMInfo, er
 	}
 
 	// 检查响应状态码
-	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return nil, err
 	}
 
 	// 检查响应体是否为空
@@ -132,6 +131,7 @@ func (c *Client) GetAllVMsWithFilter(includeTemplates bool) ([]models.VMInfo, er
 			NetOut   uint64 `json:"netout"`
 			Tags     string `json:"tags"`
 			Template int    `json:"template"` // PVE 返回 0 或 1
+			Lock     string `json:"lock"`
 		} `json:"data"`
 	}
 
@@ -171,6 +171,7 @@ func (c *Client) GetAllVMsWithFilter(includeTemplates bool) ([]models.VMInfo, er
 			NetworkRX: vm.NetIn,
 			NetworkTX: vm.NetOut,
 			Template:  isTemplate,
+			Lock:      vm.Lock,
 		})
 	}
 
@@ -187,8 +188,8 @@ func (c *Client) GetVMStatus(vmid int) (*models.VMInfo, error) {
 	}
 
 	// 检查响应状态码
-	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return nil, err
 	}
 
 	// 检查响应体是否为空
@@ -203,6 +204,14 @@ func (c *Client) GetVMStatus(vmid int) (*models.VMInfo, error) {
 			Status string `json:"status"`
 			NetIn  uint64 `json:"netin"`
 			NetOut uint64 `json:"netout"`
+			// 每张网卡的计数，键为 tap 设备名（tap{vmid}i{n} 对应 net{n}）
+			NICs map[string]struct {
+				NetIn  uint64 `json:"netin"`
+				NetOut uint64 `json:"netout"`
+			} `json:"nics"`
+
+			DiskRead  uint64 `json:"diskread"`
+			DiskWrite uint64 `json:"diskwrite"`
 		} `json:"data"`
 	}
 
@@ -210,46 +219,62 @@ func (c *Client) GetVMStatus(vmid int) (*models.VMInfo, error) {
 		return nil, fmt.Errorf("解析虚拟机状态失败: %w\n响应内容: %s", err, string(resp.Body()))
 	}
 
+	var nics models.NICMap
+	for device, counters := range result.Data.NICs {
+		_, index, ok := strings.Cut(device, "i")
+		if !ok || !strings.HasPrefix(device, "tap") || !isNetworkConfigKey("net"+index) {
+			continue
+		}
+		if nics == nil {
+			nics = make(models.NICMap)
+		}
+		nics["net"+index] = models.NICTraffic{RX: counters.NetIn, TX: counters.NetOut}
+	}
+
 	return &models.VMInfo{
 		VMID:      result.Data.VMID,
 		Name:      result.Data.Name,
 		Status:    result.Data.Status,
 		NetworkRX: result.Data.NetIn,
 		NetworkTX: result.Data.NetOut,
+		NICs:      nics,
+		DiskRead:  result.Data.DiskRead,
+		DiskWrite: result.Data.DiskWrite,
 	}, nil
 }
 
 // ShutdownVM 关闭虚拟机（优雅关机，需要虚拟机支持 ACPI）
-func (c *Client) ShutdownVM(vmid int) error {
+// 返回异步任务的 UPID，调用方使用 WaitTask 等待任务完成
+func (c *Client) ShutdownVM(vmid int) (string, error) {
 	// 使用原生 HTTP 客户端避免 chunked encoding
-	_, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/shutdown", c.config.Node, vmid), map[string]string{})
+	body, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/shutdown", c.config.Node, vmid), map[string]string{})
 	if err != nil {
-		return fmt.Errorf("关闭虚拟机失败: %w", err)
+		return "", fmt.Errorf("关闭虚拟机失败: %w", err)
 	}
 
-	return nil
+	return parseUPID(body), nil
 }
 
-// StopVM 强制停止虚拟机（立即停止，不等待虚拟机响应）
-func (c *Client) StopVM(vmid int) error {
+// StopVM 强制停止虚拟机（立即停止，不等待虚拟机响应，返回异步任务的 UPID）
+func (c *Client) StopVM(vmid int) (string, error) {
 	// 使用原生 HTTP 客户端避免 chunked encoding
-	_, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/stop", c.config.Node, vmid), map[string]string{})
+	body, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/stop", c.config.Node, vmid), map[string]string{})
 	if err != nil {
-		return fmt.Errorf("停止虚拟机失败: %w", err)
+		return "", fmt.Errorf("停止虚拟机失败: %w", err)
 	}
 
-	return nil
+	return parseUPID(body), nil
 }
 
-// StartVM 启动虚拟机
-func (c *Client) StartVM(vmid int) error {
+// StartVM 启动虚拟机（返回异步任务的 UPID）
+func (c *Client) StartVM(vmid int) (string, error) {
 	// 使用原生 HTTP 客户端避免 chunked encoding
-	_, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/start", c.config.Node, vmid), map[string]string{})
+	body, err := c.doPost(fmt.Sprintf("/nodes/%s/qemu/%d/status/start", c.config.Node, vmid), map[string]string{})
 	if err != nil {
-		return fmt.Errorf("启动虚拟机失败: %w", err)
+		return "", fmt.Errorf("启动虚拟机失败: %w", err)
 	}
 
-	return nil
+	return parseUPID(body), nil
 }
 
 // RemoveNetworkRateLimit 移除网络速率限制
@@ -261,8 +286,8 @@ func (c *Client) RemoveNetworkRateLimit(vmid int) error {
 	if err != nil {
 		return fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return err
 	}
 
 	var config struct {
@@ -295,61 +320,48 @@ func (c *Client) RemoveNetworkRateLimit(vmid int) error {
 	return nil
 }
 
-// DisconnectNetwork 断开虚拟机网络连接
-func (c *Client) DisconnectNetwork(vmid int) error {
-	// 获取虚拟机配置
-	resp, err := c.client.R().
-		Get(fmt.Sprintf("/nodes/%s/qemu/%d/config", c.config.Node, vmid))
-
+// DisconnectNetwork 断开虚拟机网络连接（interfaces 为空时断开所有网卡）
+func (c *Client) DisconnectNetwork(vmid int, interfaces []string) error {
+	config, err := c.GetVMConfig(vmid)
 	if err != nil {
-		return fmt.Errorf("获取虚拟机配置失败: %w", err)
-	}
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+		return err
 	}
 
-	var config struct {
-		Data map[string]interface{} `json:"data"`
+	// 所有选中的网卡在同一次配置更新中设置 link_down=1，避免只断开部分网卡
+	updates := make(map[string]string)
+	for _, key := range NetworkInterfaceKeys(config, interfaces) {
+		if netConfig, ok := config[key].(string); ok {
+			updates[key] = setNetworkLinkDownInConfig(netConfig, true)
+		}
 	}
-
-	if err := json.Unmarshal(resp.Body(), &config); err != nil {
-		return fmt.Errorf("解析虚拟机配置失败: %w", err)
+	if len(updates) == 0 {
+		return fmt.Errorf("未找到网络接口配置")
 	}
 
-	// 查找网络接口并设置 link_down
-	updated := false
-	for _, key := range networkConfigKeys(config.Data) {
-		netConfig, ok := config.Data[key].(string)
-		if !ok {
-			continue
-		}
-
-		// 移除旧的 link_down 参数（如果有）
-		parts := strings.Split(netConfig, ",")
-		newParts := []string{}
-		for _, part := range parts {
-			trimmed := strings.TrimSpace(part)
-			if !strings.HasPrefix(trimmed, "link_down=") {
-				newParts = append(newParts, part)
-			}
-		}
+	if err := c.putVMConfig(vmid, updates); err != nil {
+		return fmt.Errorf("断开网络失败: %w", err)
+	}
 
-		// 添加 link_down=1
-		newParts = append(newParts, "link_down=1")
-		newNetConfig := strings.Join(newParts, ",")
+	return nil
+}
 
-		// 更新配置
-		if err := c.putVMConfig(vmid, map[string]string{key: newNetConfig}); err != nil {
-			return fmt.Errorf("断开网络失败: %w", err)
-		}
-		updated = true
+// NetworkDisconnected 选中的网卡是否都已断开（interfaces 为空时检查所有网卡）
+func (c *Client) NetworkDisconnected(vmid int, interfaces []string) (bool, error) {
+	config, err := c.GetVMConfig(vmid)
+	if err != nil {
+		return false, err
 	}
 
-	if !updated {
-		return fmt.Errorf("未找到网络接口配置")
+	keys := NetworkInterfaceKeys(config, interfaces)
+	if len(keys) == 0 {
+		return false, fmt.Errorf("未找到网络接口配置")
 	}
-
-	return nil
+	for _, key := range keys {
+		if netConfig, ok := config[key].(string); ok && !parseNetworkLinkDown(netConfig) {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 // ConnectNetwork 连接虚拟机网络
@@ -361,8 +373,8 @@ func (c *Client) ConnectNetwork(vmid int) error {
 	if err != nil {
 		return fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return err
 	}
 
 	var config struct {
@@ -415,8 +427,8 @@ func (c *Client) SetNetworkRateLimit(vmid int, rateMB float64) error {
 	if err != nil {
 		return fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return err
 	}
 
 	var config struct {
@@ -449,14 +461,14 @@ func (c *Client) SetNetworkRateLimit(vmid int, rateMB float64) error {
 	return nil
 }
 
-// ShouldTightenNetworkRateLimit 判断是否需要收紧任意网卡限速。
-func (c *Client) ShouldTightenNetworkRateLimit(vmid int, rateMB float64) (bool, error) {
+// ShouldTightenNetworkRateLimit 判断是否需要收紧任意选中网卡的限速（interfaces 为空时检查所有网卡）
+func (c *Client) ShouldTightenNetworkRateLimit(vmid int, rateMB float64, interfaces []string) (bool, error) {
 	config, err := c.GetVMConfig(vmid)
 	if err != nil {
 		return false, err
 	}
 
-	updates, err := networkRateLimitUpdates(config, rateMB)
+	updates, err := networkRateLimitUpdates(config, rateMB, interfaces)
 	if err != nil {
 		return false, err
 	}
@@ -464,14 +476,14 @@ func (c *Client) ShouldTightenNetworkRateLimit(vmid int, rateMB float64) (bool,
 	return len(updates) > 0, nil
 }
 
-// TightenNetworkRateLimit 只收紧所有网卡限速，不放宽已有更严格的限速。
-func (c *Client) TightenNetworkRateLimit(vmid int, rateMB float64) (bool, error) {
+// TightenNetworkRateLimit 只收紧选中网卡的限速，不放宽已有更严格的限速（interfaces 为空时作用于所有网卡）
+func (c *Client) TightenNetworkRateLimit(vmid int, rateMB float64, interfaces []string) (bool, error) {
 	config, err := c.GetVMConfig(vmid)
 	if err != nil {
 		return false, err
 	}
 
-	updates, err := networkRateLimitUpdates(config, rateMB)
+	updates, err := networkRateLimitUpdates(config, rateMB, interfaces)
 	if err != nil {
 		return false, err
 	}
@@ -479,16 +491,8 @@ func (c *Client) TightenNetworkRateLimit(vmid int, rateMB float64) (bool, error)
 		return false, nil
 	}
 
-	keys := make([]string, 0, len(updates))
-	for key := range updates {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-
-	for _, key := range keys {
-		if err := c.putVMConfig(vmid, map[string]string{key: updates[key]}); err != nil {
-			return false, fmt.Errorf("设置网络速率限制失败: %w", err)
-		}
+	if err := c.putVMConfig(vmid, updates); err != nil {
+		return false, fmt.Errorf("设置网络速率限制失败: %w", err)
 	}
 
 	return true, nil
@@ -606,28 +610,24 @@ func (c *Client) RestoreNetworkRateLimits(vmid int, rates map[string]float64) er
 		return fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
 
-	updated := false
+	updates := make(map[string]string)
 	for _, key := range networkConfigKeys(config) {
 		rate, exists := rates[key]
 		if !exists {
 			continue
 		}
 
-		netConfig, ok := config[key].(string)
-		if !ok {
-			continue
-		}
-
-		newNetConfig := setNetworkRateLimitInConfig(netConfig, rate, rate > 0)
-		if err := c.putVMConfig(vmid, map[string]string{key: newNetConfig}); err != nil {
-			return fmt.Errorf("恢复网络速率限制失败: %w", err)
+		if netConfig, ok := config[key].(string); ok {
+			updates[key] = setNetworkRateLimitInConfig(netConfig, rate, rate > 0)
 		}
-		updated = true
 	}
 
-	if !updated {
+	if len(updates) == 0 {
 		return fmt.Errorf("未找到可恢复的网络接口配置")
 	}
+	if err := c.putVMConfig(vmid, updates); err != nil {
+		return fmt.Errorf("恢复网络速率限制失败: %w", err)
+	}
 
 	return nil
 }
@@ -639,38 +639,34 @@ func (c *Client) RestoreNetworkLinkStates(vmid int, states map[string]bool) erro
 		return fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
 
-	updated := false
+	updates := make(map[string]string)
 	for _, key := range networkConfigKeys(config) {
 		linkDown, exists := states[key]
 		if !exists {
 			continue
 		}
 
-		netConfig, ok := config[key].(string)
-		if !ok {
-			continue
+		if netConfig, ok := config[key].(string); ok {
+			updates[key] = setNetworkLinkDownInConfig(netConfig, linkDown)
 		}
-
-		newNetConfig := setNetworkLinkDownInConfig(netConfig, linkDown)
-		if err := c.putVMConfig(vmid, map[string]string{key: newNetConfig}); err != nil {
-			return fmt.Errorf("恢复网络连接状态失败: %w", err)
-		}
-		updated = true
 	}
 
-	if !updated {
+	if len(updates) == 0 {
 		return fmt.Errorf("未找到可恢复的网络接口配置")
 	}
+	if err := c.putVMConfig(vmid, updates); err != nil {
+		return fmt.Errorf("恢复网络连接状态失败: %w", err)
+	}
 
 	return nil
 }
 
-func networkRateLimitUpdates(config map[string]interface{}, rateMB float64) (map[string]string, error) {
+func networkRateLimitUpdates(config map[string]interface{}, rateMB float64, interfaces []string) (map[string]string, error) {
 	if rateMB <= 0 {
 		return nil, fmt.Errorf("限速值必须大于 0 MB/s")
 	}
 
-	selectedKeys := networkConfigKeys(config)
+	selectedKeys := NetworkInterfaceKeys(config, interfaces)
 	if len(selectedKeys) == 0 {
 		return nil, fmt.Errorf("未找到网络接口配置")
 	}
@@ -748,6 +744,81 @@ func networkConfigKeys(config map[string]interface{}) []string {
 	return keys
 }
 
+// NetworkInterfaceKeys 获取规则选中的网卡（interfaces 中可以是网卡名 net0 或网桥名 vmbr0，为空时选中所有网卡）
+func NetworkInterfaceKeys(config map[string]interface{}, interfaces []string) []string {
+	keys := networkConfigKeys(config)
+	if len(interfaces) == 0 {
+		return keys
+	}
+
+	selected := make([]string, 0, len(keys))
+	for _, key := range keys {
+		netConfig, _ := config[key].(string)
+		bridge := networkBridge(netConfig)
+		for _, name := range interfaces {
+			if name == key || (bridge != "" && name == bridge) {
+				selected = append(selected, key)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// VMBridges 获取虚拟机网卡连接的网桥（SDN 的 VNet 同样以网桥名出现，去重排序）
+func VMBridges(config map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	bridges := make([]string, 0)
+	for _, key := range networkConfigKeys(config) {
+		netConfig, _ := config[key].(string)
+		if bridge := networkBridge(netConfig); bridge != "" && !seen[bridge] {
+			seen[bridge] = true
+			bridges = append(bridges, bridge)
+		}
+	}
+	sort.Strings(bridges)
+	return bridges
+}
+
+// networkBridge 获取网卡连接的网桥
+func networkBridge(netConfig string) string {
+	for _, part := range strings.Split(netConfig, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(part), "bridge="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// networkVLAN 获取网卡的 VLAN 标签（未设置时为空）
+func networkVLAN(netConfig string) string {
+	for _, part := range strings.Split(netConfig, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(part), "tag="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// CountedTraffic 按网桥过滤汇总网卡流量（排除内部存储、备份网络等）
+// 状态中没有网卡计数时返回 false，调用方使用 PVE 汇总的 netin/netout
+func CountedTraffic(status *models.VMInfo, config map[string]interface{}, filter models.BridgeFilter) (rx, tx uint64, ok bool) {
+	if len(status.NICs) == 0 {
+		return 0, 0, false
+	}
+
+	for _, key := range networkConfigKeys(config) {
+		netConfig, _ := config[key].(string)
+		if !filter.Counts(networkBridge(netConfig), networkVLAN(netConfig)) {
+			continue
+		}
+		counters := status.NICs[key]
+		rx += counters.RX
+		tx += counters.TX
+	}
+	return rx, tx, true
+}
+
 func isNetworkConfigKey(key string) bool {
 	if !strings.HasPrefix(key, "net") || len(key) == len("net") {
 		return false
@@ -770,8 +841,8 @@ func (c *Client) GetVMConfig(vmid int) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("获取虚拟机配置失败: %w", err)
 	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("PVE API 返回错误状态码: %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return nil, err
 	}
 
 	var result struct {
@@ -876,17 +947,13 @@ func (c *Client) AutoTagByTraffic(vmid int, trafficGB float64, threshold float64
 	return nil
 }
 
-// AutoTagByTrafficWithRule 根据流量使用情况为特定规则打标签（每个规则独立标签）
-func (c *Client) AutoTagByTrafficWithRule(vmid int, trafficGB float64, threshold float64, ruleName string) error {
-	// 清理规则名，用于标签（移除空格，转小写）
-	safeRuleName := strings.ToLower(strings.ReplaceAll(ruleName, " ", "-"))
-
+// AutoTagByTrafficWithRule 根据流量使用情况为特定规则打标签（每个规则独立标签，见 TagConfig.RuleTag）
+func (c *Client) AutoTagByTrafficWithRule(vmid int, trafficGB float64, threshold float64, tag string) error {
 	// 先移除该规则的旧标签
-	c.RemoveVMTag(vmid, fmt.Sprintf("traffic-limit-%s", safeRuleName))
+	c.RemoveVMTag(vmid, tag)
 
 	// 只在超限时打标签
 	if trafficGB > threshold {
-		tag := fmt.Sprintf("traffic-limit-%s", safeRuleName)
 		return c.AddVMTag(vmid, tag)
 	}
 
@@ -894,14 +961,25 @@ func (c *Client) AutoTagByTrafficWithRule(vmid int, trafficGB float64, threshold
 	return nil
 }
 
-// GetVMCreationTime 获取虚拟机创建时间
+// GetVMCreationTime 获取虚拟机创建时间（成功读取的结果缓存一小时，监控循环每个周期都会查询）
 func (c *Client) GetVMCreationTime(vmid int) (time.Time, error) {
+	if cached, ok := c.creationTimes.Load(vmid); ok {
+		if entry := cached.(cachedCreationTime); time.Since(entry.fetchedAt) < creationTimeCacheTTL {
+			return entry.at, nil
+		}
+	}
+
 	config, err := c.GetVMConfig(vmid)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	return CreationTimeFromConfig(config)
+	creationTime, err := CreationTimeFromConfig(config)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c.creationTimes.Store(vmid, cachedCreationTime{at: creationTime, fetchedAt: time.Now()})
+	return creationTime, nil
 }
 
 // CreationTimeFromConfig 从PVE VM配置的 meta.ctime 字段解析创建时间。
@@ -922,21 +1000,102 @@ func CreationTimeFromConfig(config map[string]interface{}) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("无法从虚拟机配置 meta.ctime 获取创建时间")
 }
 
+// Task PVE 节点任务（/nodes/{node}/tasks 的列表项）
+type Task struct {
+	UPID      string `json:"upid"`
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	StartTime int64  `json:"starttime"`
+	Status    string `json:"status"` // 运行中的任务为空
+}
+
+// GetVMCreateTaskTime 从节点任务历史查找虚拟机的创建任务（qmcreate），返回任务开始时间
+// 任务历史会被 PVE 轮转，较早创建的虚拟机可能找不到
+func (c *Client) GetVMCreateTaskTime(vmid int) (time.Time, error) {
+	resp, err := c.client.R().
+		SetQueryParams(map[string]string{
+			"vmid":       strconv.Itoa(vmid),
+			"typefilter": "qmcreate",
+			"source":     "all",
+			"limit":      "500",
+		}).
+		Get(fmt.Sprintf("/nodes/%s/tasks", c.config.Node))
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("获取任务历史失败: %w", err)
+	}
+	if err := checkResty(resp); err != nil {
+		return time.Time{}, err
+	}
+
+	var result struct {
+		Data []Task `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return time.Time{}, fmt.Errorf("解析任务历史失败: %w", err)
+	}
+
+	return CreationTimeFromTasks(result.Data, vmid)
+}
+
+// CreationTimeFromTasks 从任务列表中取虚拟机最近一次成功的创建任务的开始时间
+// VMID 被删除后复用时，最近一次创建才对应当前的虚拟机
+func CreationTimeFromTasks(tasks []Task, vmid int) (time.Time, error) {
+	id := strconv.Itoa(vmid)
+	var latest int64
+	for _, task := range tasks {
+		if task.Type != "qmcreate" || task.ID != id || (task.Status != "" && task.Status != "OK") {
+			continue
+		}
+		if task.StartTime > latest {
+			latest = task.StartTime
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}, fmt.Errorf("任务历史中没有虚拟机 %d 的创建任务", vmid)
+	}
+	return time.Unix(latest, 0), nil
+}
+
 // ParseVMID 解析字符串为 VMID
 func ParseVMID(s string) (int, error) {
 	return strconv.Atoi(s)
 }
 
-// ApplyRulesToVMs 为VM列表应用规则匹配
-func ApplyRulesToVMs(vms []models.VMInfo, rules []models.Rule) []models.VMInfo {
+// ApplyRulesToVMs 为VM列表应用规则匹配（规则按检查顺序排列，policy 为规则冲突处理策略）
+func ApplyRulesToVMs(vms []models.VMInfo, rules []models.Rule, policy string) []models.VMInfo {
+	ordered := append([]models.Rule(nil), rules...)
+	models.SortRulesByPriority(ordered)
+
 	result := make([]models.VMInfo, len(vms))
 	for i, vm := range vms {
 		result[i] = vm
-		result[i].MatchedRules = GetMatchedRulesForVM(vm, rules)
+		result[i].MatchedRules = GetMatchedRulesForVM(vm, ordered)
+		result[i].ConflictWinner = conflictWinner(vm, ordered, policy)
 	}
 	return result
 }
 
+// conflictWinner 虚拟机匹配的限制规则操作不同时，这些规则同时超限时按冲突处理策略执行的规则（没有冲突时为空）
+func conflictWinner(vm models.VMInfo, rules []models.Rule, policy string) string {
+	var limits []models.Rule
+	conflict := false
+	for _, rule := range rules {
+		if !rule.Enabled || !rule.IsRestrictive() || !VMMatchesRule(vm, rule) {
+			continue
+		}
+		if len(limits) > 0 && !models.SameAction(limits[0].Action, rule.Action) {
+			conflict = true
+		}
+		limits = append(limits, rule)
+	}
+	if !conflict {
+		return ""
+	}
+	return limits[models.SelectRule(policy, limits)].Name
+}
+
 // GetMatchedRulesForVM 获取VM匹配的规则名称列表
 func GetMatchedRulesForVM(vm models.VMInfo, rules []models.Rule) []string {
 	var matchedRules []string
@@ -1,8 +1,11 @@
 package pve
 
 import (
+	"strings"
 	"testing"
 	"time"
+
+	"pve-traffic-monitor/pkg/models"
 )
 
 func TestParseNetworkRateLimit(t *testing.T) {
@@ -126,6 +129,28 @@ func TestCreationTimeFromConfig(t *testing.T) {
 	}
 }
 
+func TestCreationTimeFromTasks(t *testing.T) {
+	tasks := []Task{
+		{Type: "qmcreate", ID: "100", StartTime: 1700000000, Status: "OK"},
+		{Type: "qmcreate", ID: "100", StartTime: 1767225600, Status: "OK"},
+		{Type: "qmcreate", ID: "100", StartTime: 1767300000, Status: "unable to create VM"},
+		{Type: "qmcreate", ID: "101", StartTime: 1767400000, Status: "OK"},
+		{Type: "qmstart", ID: "100", StartTime: 1767500000, Status: "OK"},
+	}
+
+	got, err := CreationTimeFromTasks(tasks, 100)
+	if err != nil {
+		t.Fatalf("CreationTimeFromTasks() error = %v", err)
+	}
+	if want := time.Unix(1767225600, 0); !got.Equal(want) {
+		t.Fatalf("creation time = %s, want %s (latest successful qmcreate)", got, want)
+	}
+
+	if _, err := CreationTimeFromTasks(tasks, 102); err == nil {
+		t.Fatal("CreationTimeFromTasks() without a create task should fail")
+	}
+}
+
 func TestNetworkRateLimitUpdatesOnlyTightens(t *testing.T) {
 	config := map[string]interface{}{
 		"net0": "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0,rate=5.00",
@@ -133,7 +158,7 @@ func TestNetworkRateLimitUpdatesOnlyTightens(t *testing.T) {
 		"net2": "virtio=AA:BB:CC:DD:EE:11,bridge=vmbr1,rate=20.00",
 	}
 
-	updates, err := networkRateLimitUpdates(config, 10)
+	updates, err := networkRateLimitUpdates(config, 10, nil)
 	if err != nil {
 		t.Fatalf("networkRateLimitUpdates() error = %v", err)
 	}
@@ -166,3 +191,74 @@ func TestNetworkLinkDownStatesFromConfig(t *testing.T) {
 		t.Fatalf("net1 link_down = true, want false")
 	}
 }
+
+func TestNetworkInterfaceKeys(t *testing.T) {
+	config := map[string]interface{}{
+		"net0":   "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0",
+		"net1":   "virtio=AA:BB:CC:DD:EE:00,bridge=vmbr1,tag=20",
+		"net2":   "virtio=AA:BB:CC:DD:EE:11,bridge=vmbr1",
+		"net10":  "virtio=AA:BB:CC:DD:EE:22,bridge=vmbr2",
+		"netmgr": "not a nic",
+	}
+
+	tests := []struct {
+		interfaces []string
+		want       []string
+	}{
+		{nil, []string{"net0", "net1", "net10", "net2"}},
+		{[]string{"net0"}, []string{"net0"}},
+		{[]string{"vmbr1"}, []string{"net1", "net2"}},
+		{[]string{"net10", "vmbr0"}, []string{"net0", "net10"}},
+		{[]string{"vmbr9"}, []string{}},
+	}
+	for _, tt := range tests {
+		got := NetworkInterfaceKeys(config, tt.interfaces)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Fatalf("NetworkInterfaceKeys(%v) = %v, want %v", tt.interfaces, got, tt.want)
+		}
+	}
+
+	updates, err := networkRateLimitUpdates(config, 10, []string{"vmbr1"})
+	if err != nil {
+		t.Fatalf("networkRateLimitUpdates() error = %v", err)
+	}
+	if len(updates) != 2 || updates["net0"] != "" {
+		t.Fatalf("updates = %#v, want net1 and net2 only", updates)
+	}
+}
+
+func TestCountedTraffic(t *testing.T) {
+	config := map[string]interface{}{
+		"net0": "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0",
+		"net1": "virtio=AA:BB:CC:DD:EE:00,bridge=vmbr0,tag=20",
+		"net2": "virtio=AA:BB:CC:DD:EE:11,bridge=vmbr9",
+	}
+	status := &models.VMInfo{NICs: models.NICMap{
+		"net0": {RX: 100, TX: 10},
+		"net1": {RX: 200, TX: 20},
+		"net2": {RX: 400, TX: 40},
+	}}
+
+	tests := []struct {
+		filter models.BridgeFilter
+		rx, tx uint64
+	}{
+		{models.BridgeFilter{Exclude: []string{"vmbr9"}}, 300, 30},
+		{models.BridgeFilter{Include: []string{"vmbr0"}}, 300, 30},
+		{models.BridgeFilter{Include: []string{"vmbr0.20"}}, 200, 20},
+		{models.BridgeFilter{Include: []string{"vmbr0"}, Exclude: []string{"vmbr0.20"}}, 100, 10},
+	}
+	for _, tt := range tests {
+		rx, tx, ok := CountedTraffic(status, config, tt.filter)
+		if !ok || rx != tt.rx || tx != tt.tx {
+			t.Fatalf("CountedTraffic(%+v) = %d, %d, %v, want %d, %d", tt.filter, rx, tx, ok, tt.rx, tt.tx)
+		}
+	}
+
+	if _, _, ok := CountedTraffic(&models.VMInfo{NetworkRX: 1}, config, tests[0].filter); ok {
+		t.Fatalf("CountedTraffic() without NIC counters ok = true, want false")
+	}
+	if err := (models.BridgeFilter{Exclude: []string{"vmbr0.5000"}}).Validate(); err == nil {
+		t.Fatalf("Validate() accepted VLAN 5000")
+	}
+}
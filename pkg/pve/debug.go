@@ -5,31 +5,38 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"pve-traffic-monitor/pkg/models"
+	"sync/atomic"
 )
 
-// DebugMode 是否启用调试模式
-var DebugMode = false
+// debugMode 是否启用调试模式（运行时可通过 SIGUSR2 切换）
+var debugMode atomic.Bool
 
 func init() {
 	// 从环境变量读取调试模式设置
 	if os.Getenv("PVE_DEBUG") == "1" || os.Getenv("PVE_DEBUG") == "true" {
-		DebugMode = true
+		debugMode.Store(true)
 	}
 }
 
 // EnableDebug 启用调试模式
 func EnableDebug() {
-	DebugMode = true
+	debugMode.Store(true)
 }
 
 // DisableDebug 禁用调试模式
 func DisableDebug() {
-	DebugMode = false
+	debugMode.Store(false)
+}
+
+// DebugEnabled 是否启用调试模式
+func DebugEnabled() bool {
+	return debugMode.Load()
 }
 
 // debugLog 输出调试日志
 func debugLog(format string, args ...interface{}) {
-	if DebugMode {
+	if debugMode.Load() {
 		log.Printf("[PVE DEBUG] "+format, args...)
 	}
 }
@@ -92,7 +99,11 @@ func (c *Client) PrintDebugInfo() {
 	fmt.Println("========================================")
 	fmt.Printf("主机: %s:%d\n", c.config.Host, c.config.Port)
 	fmt.Printf("节点: %s\n", c.config.Node)
-	fmt.Printf("API Token ID: %s\n", c.config.APITokenID)
+	if c.config.AuthMode() == models.PVEAuthTicket {
+		fmt.Printf("用户名: %s (票据认证)\n", c.config.Username)
+	} else {
+		fmt.Printf("API Token ID: %s\n", c.config.APITokenID)
+	}
 	fmt.Println("========================================")
 
 	// 测试连接
@@ -0,0 +1,112 @@
+package pve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// PVE API 错误类型（使用 errors.Is 判断）
+var (
+	ErrPermission = errors.New("PVE 权限不足")
+	ErrNotFound   = errors.New("PVE 资源不存在")
+	ErrLocked     = errors.New("虚拟机已锁定")
+	// ErrConfigChanged 写入配置时 digest 不匹配（读取之后配置被其他用户或进程修改）
+	ErrConfigChanged = errors.New("虚拟机配置已被修改")
+)
+
+// APIError PVE API 返回的非 2xx 响应
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string // PVE 的错误信息（HTTP 状态行中的说明和响应中的 errors）
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("PVE API %s %s 返回 HTTP %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Is 按状态码和 PVE 错误信息匹配错误类型
+// PVE 对不存在的虚拟机和锁冲突都返回 500，只能通过错误信息区分
+func (e *APIError) Is(target error) bool {
+	message := strings.ToLower(e.Message)
+	switch target {
+	case ErrPermission:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || strings.Contains(message, "does not exist")
+	case ErrLocked:
+		return strings.Contains(message, "is locked") || strings.Contains(message, "can't lock file")
+	case ErrConfigChanged:
+		return strings.Contains(message, "detected modified configuration")
+	default:
+		return false
+	}
+}
+
+// ErrorKind 获取错误类型（写入操作日志，便于区分权限、锁定等需要人工处理的失败）
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrPermission):
+		return models.ActionErrorPermission
+	case errors.Is(err, ErrNotFound):
+		return models.ActionErrorNotFound
+	case errors.Is(err, ErrLocked):
+		return models.ActionErrorLocked
+	case errors.Is(err, ErrTaskTimeout):
+		return models.ActionErrorTimeout
+	case errors.As(err, new(*TaskError)):
+		return models.ActionErrorTask
+	default:
+		return models.ActionErrorOther
+	}
+}
+
+// checkResponse 检查 PVE API 响应状态码，非 2xx 时返回 *APIError
+func checkResponse(method, path string, statusCode int, status string, body []byte) error {
+	if statusCode >= 200 && statusCode < 300 {
+		return nil
+	}
+
+	// PVE 把错误说明放在状态行中（如 "500 VM 100 is locked (backup)"），参数错误放在响应的 errors 中
+	message := strings.TrimSpace(strings.TrimPrefix(status, fmt.Sprint(statusCode)))
+	var result struct {
+		Errors map[string]string `json:"errors"`
+	}
+	if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+		fields := make([]string, 0, len(result.Errors))
+		for field, reason := range result.Errors {
+			fields = append(fields, field+": "+strings.TrimSpace(reason))
+		}
+		sort.Strings(fields)
+		message = strings.TrimSpace(message + " " + strings.Join(fields, "; "))
+	}
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+
+	return &APIError{Method: method, Path: path, StatusCode: statusCode, Message: message}
+}
+
+// checkResty 检查 resty 响应状态码
+func checkResty(resp *resty.Response) error {
+	return checkResponse(resp.Request.Method, apiPath(resp.Request.URL), resp.StatusCode(), resp.Status(), resp.Body())
+}
+
+// apiPath 去掉地址中的 /api2/json 前缀，错误信息中只保留 API 路径
+func apiPath(url string) string {
+	if i := strings.Index(url, "/api2/json"); i >= 0 {
+		return url[i+len("/api2/json"):]
+	}
+	return url
+}
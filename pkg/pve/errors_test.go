@@ -0,0 +1,69 @@
+package pve
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCheckResponseClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		line   string
+		body   string
+		want   string
+	}{
+		{"ok", 200, "200 OK", `{"data": null}`, ""},
+		{"permission", 403, "403 Permission check failed (/vms/100, VM.PowerMgmt)", `{"data": null}`, models.ActionErrorPermission},
+		{"missing vm", 500, "500 Configuration file 'nodes/pve/qemu-server/999.conf' does not exist", `{"data": null}`, models.ActionErrorNotFound},
+		{"locked", 500, "500 VM is locked (backup)", `{"data": null}`, models.ActionErrorLocked},
+		{"lock timeout", 500, "500 can't lock file '/var/lock/qemu-server/lock-100.conf' - got timeout", "", models.ActionErrorLocked},
+		{"parameter", 400, "400 Parameter verification failed.", `{"errors": {"net0": "invalid format"}}`, models.ActionErrorOther},
+	}
+	for _, tt := range tests {
+		err := checkResponse(http.MethodPut, "/nodes/pve/qemu/100/config", tt.status, tt.line, []byte(tt.body))
+		if got := ErrorKind(err); got != tt.want {
+			t.Fatalf("%s: ErrorKind() = %q, want %q (err = %v)", tt.name, got, tt.want, err)
+		}
+	}
+}
+
+func TestWritePathsReturnAPIErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"data": {"net0": "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0", "tags": "a"}}`))
+		case r.Method == http.MethodPut:
+			http.Error(w, `{"data": null}`, http.StatusForbidden)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"data": null}`))
+		}
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", APITokenID: "monitor@pve!t", APITokenSecret: "s"})
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if err := client.DisconnectNetwork(100, nil); !errors.Is(err, ErrPermission) {
+		t.Fatalf("DisconnectNetwork() error = %v, want ErrPermission", err)
+	}
+	if err := client.AddVMTag(100, "b"); !errors.Is(err, ErrPermission) {
+		t.Fatalf("AddVMTag() error = %v, want ErrPermission", err)
+	}
+	var apiErr *APIError
+	if _, err := client.StopVM(100); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("StopVM() error = %v, want APIError with status 500", err)
+	}
+}
@@ -15,6 +15,11 @@ func (c *Client) doPost(path string, data map[string]string) ([]byte, error) {
 	for key, value := range data {
 		formData.Set(key, value)
 	}
+	return c.doPostForm(path, formData)
+}
+
+// doPostForm 执行 POST 请求（表单参数可以重复，如客户机代理命令的各个参数）
+func (c *Client) doPostForm(path string, formData url.Values) ([]byte, error) {
 	bodyBytes := []byte(formData.Encode())
 
 	// 创建请求
@@ -29,9 +34,13 @@ func (c *Client) doPost(path string, data map[string]string) ([]byte, error) {
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(bodyBytes)))
 	req.Header.Set("Accept", "application/json")
 
-	// 从resty客户端复制Authorization header（API Token认证）
-	if auth := c.client.Header.Get("Authorization"); auth != "" {
-		req.Header.Set("Authorization", auth)
+	// 使用与 resty 请求相同的认证信息（API Token 或票据）
+	headers, err := c.authHeaders(true)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
 	}
 
 	debugLog("POST %s, Content-Length=%d", fullURL, len(bodyBytes))
@@ -52,8 +61,8 @@ func (c *Client) doPost(path string, data map[string]string) ([]byte, error) {
 	debugLog("响应状态码: %d, Content-Length=%d", resp.StatusCode, len(body))
 
 	// 检查状态码
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP %d, 响应: %s", resp.StatusCode, string(body))
+	if err := checkResponse(http.MethodPost, path, resp.StatusCode, resp.Status, body); err != nil {
+		return nil, err
 	}
 
 	return body, nil
@@ -68,8 +77,8 @@ func (c *Client) putVMConfig(vmid int, data map[string]string) error {
 		return fmt.Errorf("发送请求失败: %w", err)
 	}
 
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("HTTP %d, 响应: %s", resp.StatusCode(), string(resp.Body()))
+	if err := checkResty(resp); err != nil {
+		return err
 	}
 
 	return nil
@@ -0,0 +1,137 @@
+package pve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// 备注中限制状态块的起止标记（HTML 注释在 PVE 备注中不显示，JSON 以代码块形式展示）
+const (
+	markerBegin = "<!-- pve-traffic-monitor:begin -->"
+	markerEnd   = "<!-- pve-traffic-monitor:end -->"
+)
+
+// splitMarker 将备注拆分为状态块之外的内容和状态块中的 JSON（没有状态块时 raw 为空）
+func splitMarker(description string) (rest, raw string) {
+	begin := strings.Index(description, markerBegin)
+	if begin < 0 {
+		return description, ""
+	}
+	end := strings.Index(description[begin:], markerEnd)
+	if end < 0 {
+		return description, ""
+	}
+	end += begin
+
+	raw = description[begin+len(markerBegin) : end]
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimSuffix(raw, "```")
+
+	before := strings.TrimRight(description[:begin], "\n")
+	after := strings.TrimLeft(description[end+len(markerEnd):], "\n")
+	switch {
+	case before == "":
+		rest = after
+	case after == "":
+		rest = before
+	default:
+		rest = before + "\n\n" + after
+	}
+	return rest, strings.TrimSpace(raw)
+}
+
+// extractMarker 从备注中解析限制状态（没有状态块时返回 nil）
+func extractMarker(description string) (*models.EnforcementMarker, error) {
+	_, raw := splitMarker(description)
+	if raw == "" {
+		return nil, nil
+	}
+	var marker models.EnforcementMarker
+	if err := json.Unmarshal([]byte(raw), &marker); err != nil {
+		return nil, fmt.Errorf("解析备注中的限制状态失败: %w", err)
+	}
+	return &marker, nil
+}
+
+// embedMarker 将限制状态写入备注末尾（替换已有的状态块，marker 为 nil 时仅移除状态块）
+func embedMarker(description string, marker *models.EnforcementMarker) (string, error) {
+	rest, _ := splitMarker(description)
+	if marker == nil {
+		return rest, nil
+	}
+
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化限制状态失败: %w", err)
+	}
+
+	block := markerBegin + "\n```json\n" + string(data) + "\n```\n" + markerEnd
+	if rest == "" {
+		return block, nil
+	}
+	return rest + "\n\n" + block, nil
+}
+
+// markerWriteAttempts 写入备注时 digest 不匹配的最多尝试次数
+const markerWriteAttempts = 3
+
+// GetVMMarker 读取虚拟机备注中的限制状态（没有时返回 nil）
+func (c *Client) GetVMMarker(vmid int) (*models.EnforcementMarker, error) {
+	config, err := c.GetVMConfig(vmid)
+	if err != nil {
+		return nil, err
+	}
+	description, _ := config["description"].(string)
+	return extractMarker(description)
+}
+
+// SetVMMarker 在虚拟机备注中写入限制状态（保留备注中的其他内容）
+// 写入时带上读取到的 digest，期间备注被修改（PVE 拒绝写入）时重新读取后重试，避免覆盖其他人的修改
+func (c *Client) SetVMMarker(vmid int, marker *models.EnforcementMarker) error {
+	var err error
+	for attempt := 0; attempt < markerWriteAttempts; attempt++ {
+		if err = c.setVMMarker(vmid, marker); !errors.Is(err, ErrConfigChanged) {
+			return err
+		}
+	}
+	return err
+}
+
+// setVMMarker 读取备注并按读取时的 digest 写入一次
+func (c *Client) setVMMarker(vmid int, marker *models.EnforcementMarker) error {
+	config, err := c.GetVMConfig(vmid)
+	if err != nil {
+		return err
+	}
+	description, _ := config["description"].(string)
+
+	updated, err := embedMarker(description, marker)
+	if err != nil {
+		return err
+	}
+	if updated == description {
+		return nil
+	}
+
+	data := map[string]string{"description": updated}
+	if updated == "" {
+		data = map[string]string{"delete": "description"}
+	}
+	if digest, _ := config["digest"].(string); digest != "" {
+		data["digest"] = digest
+	}
+	if err := c.putVMConfig(vmid, data); err != nil {
+		return fmt.Errorf("更新虚拟机备注失败: %w", err)
+	}
+	return nil
+}
+
+// ClearVMMarker 移除虚拟机备注中的限制状态
+func (c *Client) ClearVMMarker(vmid int) error {
+	return c.SetVMMarker(vmid, nil)
+}
@@ -0,0 +1,105 @@
+package pve
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestEmbedMarkerRoundTrip(t *testing.T) {
+	marker := &models.EnforcementMarker{
+		Action:       models.ActionShutdown,
+		Rule:         "monthly",
+		LimitGB:      500,
+		ActionTime:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		RecoveryTime: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	description, err := embedMarker("Customer: ACME", marker)
+	if err != nil {
+		t.Fatalf("embedMarker() error = %v", err)
+	}
+	if !strings.HasPrefix(description, "Customer: ACME\n\n"+markerBegin) {
+		t.Fatalf("description = %q, want original notes kept before the marker", description)
+	}
+
+	// 再次写入时替换旧的状态块
+	marker.Action = models.ActionDisconnect
+	description, err = embedMarker(description, marker)
+	if err != nil {
+		t.Fatalf("embedMarker() error = %v", err)
+	}
+	if strings.Count(description, markerBegin) != 1 {
+		t.Fatalf("description = %q, want exactly one marker block", description)
+	}
+
+	got, err := extractMarker(description)
+	if err != nil {
+		t.Fatalf("extractMarker() error = %v", err)
+	}
+	if got == nil || got.Action != models.ActionDisconnect || got.Rule != "monthly" || !got.RecoveryTime.Equal(marker.RecoveryTime) {
+		t.Fatalf("marker = %#v, want disconnect/monthly with recovery time", got)
+	}
+
+	cleared, err := embedMarker(description, nil)
+	if err != nil {
+		t.Fatalf("embedMarker(nil) error = %v", err)
+	}
+	if cleared != "Customer: ACME" {
+		t.Fatalf("cleared = %q, want original notes", cleared)
+	}
+	if got, err := extractMarker(cleared); err != nil || got != nil {
+		t.Fatalf("extractMarker(cleared) = %v, %v, want nil", got, err)
+	}
+}
+
+func TestSetVMMarkerRetriesOnDigestMismatch(t *testing.T) {
+	var mu sync.Mutex
+	description, digest := "Customer: ACME", "d1"
+	puts := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"description": description, "digest": digest}})
+			return
+		}
+
+		r.ParseForm()
+		puts++
+		if puts == 1 {
+			// 读取之后其他用户修改了备注
+			description, digest = "Customer: ACME (renewed)", "d2"
+		}
+		if r.PostForm.Get("digest") != digest {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors": {"digest": "detected modified configuration - file changed by other user? Try again."}}`))
+			return
+		}
+		description, digest = r.PostForm.Get("description"), digest+"+"
+		w.Write([]byte(`{"data": null}`))
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", APITokenID: "monitor@pve!t", APITokenSecret: "s"})
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if err := client.SetVMMarker(100, &models.EnforcementMarker{Action: models.ActionShutdown, Rule: "monthly"}); err != nil {
+		t.Fatalf("SetVMMarker() error = %v", err)
+	}
+	if puts != 2 || !strings.HasPrefix(description, "Customer: ACME (renewed)\n\n"+markerBegin) {
+		t.Fatalf("puts = %d, description = %q, want a retry that keeps the concurrent edit", puts, description)
+	}
+}
@@ -0,0 +1,111 @@
+package pve
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// nodeRRDStep 节点 RRD（timeframe=hour）每个采样点覆盖的时间
+const nodeRRDStep = time.Minute
+
+// NodeNetPoint 节点网络 RRD 采样点（该分钟内的平均速率，字节/秒）
+type NodeNetPoint struct {
+	Time   time.Time
+	NetIn  float64
+	NetOut float64
+}
+
+// GetNodeNetRRD 获取节点最近一小时的网络 RRD 数据（物理网卡的收发速率，不含虚拟机的 tap 网卡）
+// 尚未汇总完成的采样点没有数值，不会返回；结果按时间升序
+func (c *Client) GetNodeNetRRD() ([]NodeNetPoint, error) {
+	resp, err := c.client.R().
+		SetQueryParams(map[string]string{"timeframe": "hour", "cf": "AVERAGE"}).
+		Get(fmt.Sprintf("/nodes/%s/rrddata", c.config.Node))
+	if err != nil {
+		return nil, fmt.Errorf("获取节点 RRD 数据失败: %w", err)
+	}
+	if err := checkResty(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			Time   int64    `json:"time"`
+			NetIn  *float64 `json:"netin"`
+			NetOut *float64 `json:"netout"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("解析节点 RRD 数据失败: %w", err)
+	}
+
+	points := make([]NodeNetPoint, 0, len(result.Data))
+	for _, item := range result.Data {
+		if item.NetIn == nil || item.NetOut == nil {
+			continue
+		}
+		points = append(points, NodeNetPoint{
+			Time:   time.Unix(item.Time, 0),
+			NetIn:  *item.NetIn,
+			NetOut: *item.NetOut,
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	return points, nil
+}
+
+// NodeCounter 把节点 RRD 的平均速率累加成与虚拟机相同的累计计数器
+// 计数器从 0 或上次保存的记录继续累加，按虚拟机重启的方式计算周期流量
+type NodeCounter struct {
+	last   time.Time
+	rx, tx float64
+}
+
+// Resume 从上次保存的节点记录继续累加（程序重启后补上 RRD 仍保留的采样点）
+func (c *NodeCounter) Resume(record models.TrafficRecord) {
+	c.last = record.Timestamp
+	c.rx = float64(record.RXBytes)
+	c.tx = float64(record.TXBytes)
+}
+
+// Add 累加上次之后的新采样点（按时间升序），每个采样点生成一条流量记录
+// 第一次调用且没有 Resume 时只记录起点，不累加 RRD 中已有的历史
+func (c *NodeCounter) Add(points []NodeNetPoint) []models.TrafficRecord {
+	if len(points) == 0 {
+		return nil
+	}
+
+	if c.last.IsZero() {
+		c.last = points[len(points)-1].Time
+		return []models.TrafficRecord{c.record()}
+	}
+
+	var records []models.TrafficRecord
+	for _, point := range points {
+		if !point.Time.After(c.last) {
+			continue
+		}
+		c.rx += point.NetIn * nodeRRDStep.Seconds()
+		c.tx += point.NetOut * nodeRRDStep.Seconds()
+		c.last = point.Time
+		records = append(records, c.record())
+	}
+	return records
+}
+
+// record 当前计数器对应的流量记录
+func (c *NodeCounter) record() models.TrafficRecord {
+	rx, tx := uint64(c.rx), uint64(c.tx)
+	return models.TrafficRecord{
+		Timestamp:  c.last,
+		RXBytes:    rx,
+		TXBytes:    tx,
+		TotalBytes: rx + tx,
+	}
+}
@@ -0,0 +1,60 @@
+package pve
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestNodeCounter(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api2/json/nodes/pve/rrddata" || r.URL.Query().Get("timeframe") != "hour" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// 最后一个采样点尚未汇总，没有数值
+		w.Write([]byte(`{"data": [
+			{"time": 1700000060, "netin": 100, "netout": 10},
+			{"time": 1700000000, "netin": 50, "netout": 5},
+			{"time": 1700000120}
+		]}`))
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", APITokenID: "monitor@pve!t", APITokenSecret: "s"})
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	points, err := client.GetNodeNetRRD()
+	if err != nil || len(points) != 2 || points[0].Time.Unix() != 1700000000 {
+		t.Fatalf("GetNodeNetRRD() = %+v, %v, want 2 points in time order", points, err)
+	}
+
+	// 没有历史记录时只记录起点
+	var fresh NodeCounter
+	if records := fresh.Add(points); len(records) != 1 || records[0].TotalBytes != 0 || records[0].Timestamp.Unix() != 1700000060 {
+		t.Fatalf("Add() on fresh counter = %+v, want a single zero baseline", records)
+	}
+
+	// 从保存的记录继续时累加之后的采样点（速率 × 60 秒）
+	var resumed NodeCounter
+	resumed.Resume(models.TrafficRecord{Timestamp: time.Unix(1699999940, 0), RXBytes: 1000, TXBytes: 100})
+	records := resumed.Add(points)
+	if len(records) != 2 {
+		t.Fatalf("Add() after Resume() returned %d records, want 2", len(records))
+	}
+	if last := records[1]; last.RXBytes != 1000+50*60+100*60 || last.TXBytes != 100+5*60+10*60 || last.TotalBytes != last.RXBytes+last.TXBytes {
+		t.Fatalf("Add() last record = %+v, want rx=10000 tx=1000", last)
+	}
+	if records := resumed.Add(points); len(records) != 0 {
+		t.Fatalf("Add() with no new points = %+v, want none", records)
+	}
+}
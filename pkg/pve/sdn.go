@@ -0,0 +1,34 @@
+package pve
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GetSDNVNets 获取集群中的 SDN VNet（VNet 名称 -> 所属 Zone）
+// 未安装或未配置 SDN 时返回错误，调用方按普通网桥处理
+func (c *Client) GetSDNVNets() (map[string]string, error) {
+	resp, err := c.client.R().Get("/cluster/sdn/vnets")
+	if err != nil {
+		return nil, fmt.Errorf("获取 SDN VNet 列表失败: %w", err)
+	}
+	if err := checkResty(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			VNet string `json:"vnet"`
+			Zone string `json:"zone"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("解析 SDN VNet 列表失败: %w", err)
+	}
+
+	vnets := make(map[string]string, len(result.Data))
+	for _, vnet := range result.Data {
+		vnets[vnet.VNet] = vnet.Zone
+	}
+	return vnets, nil
+}
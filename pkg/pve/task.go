@@ -0,0 +1,165 @@
+package pve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ErrTaskTimeout 等待异步任务超时（任务可能仍在运行）
+var ErrTaskTimeout = errors.New("等待 PVE 任务超时")
+
+// TaskStatus 异步任务状态（/nodes/{node}/tasks/{upid}/status）
+type TaskStatus struct {
+	UPID       string `json:"upid"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`     // running / stopped
+	ExitStatus string `json:"exitstatus"` // 结束后为 OK、WARNINGS: n 或错误信息
+}
+
+// Running 任务是否仍在运行
+func (s *TaskStatus) Running() bool {
+	return s.Status != "stopped"
+}
+
+// Succeeded 任务是否成功结束（有警告也视为成功）
+func (s *TaskStatus) Succeeded() bool {
+	return !s.Running() && (s.ExitStatus == "OK" || strings.HasPrefix(s.ExitStatus, "WARNINGS"))
+}
+
+// TaskError 异步任务执行失败
+type TaskError struct {
+	UPID       string
+	ExitStatus string
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("PVE 任务失败: %s", e.ExitStatus)
+}
+
+// Is 按任务的退出信息匹配错误类型（如虚拟机锁定）
+func (e *TaskError) Is(target error) bool {
+	return (&APIError{Message: e.ExitStatus}).Is(target)
+}
+
+// parseUPID 从异步操作的响应中读取任务 ID（{"data": "UPID:..."}），同步完成的操作返回空字符串
+func parseUPID(body []byte) string {
+	var result struct {
+		Data interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return ""
+	}
+	upid, _ := result.Data.(string)
+	if !strings.HasPrefix(upid, "UPID:") {
+		return ""
+	}
+	return upid
+}
+
+// upidNode 任务所在的节点（UPID:节点:PID:...，集群中任务可能在其他节点执行）
+func (c *Client) upidNode(upid string) string {
+	parts := strings.Split(upid, ":")
+	if len(parts) > 1 && parts[1] != "" {
+		return parts[1]
+	}
+	return c.config.Node
+}
+
+// GetTaskStatus 获取异步任务状态
+func (c *Client) GetTaskStatus(upid string) (*TaskStatus, error) {
+	resp, err := c.client.R().
+		Get(fmt.Sprintf("/nodes/%s/tasks/%s/status", c.upidNode(upid), url.PathEscape(upid)))
+
+	if err != nil {
+		return nil, fmt.Errorf("获取任务状态失败: %w", err)
+	}
+	if err := checkResty(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data TaskStatus `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("解析任务状态失败: %w", err)
+	}
+	result.Data.UPID = upid
+	return &result.Data, nil
+}
+
+// WaitTask 轮询等待异步任务结束（timeout 为 0 时使用默认超时）
+// 任务失败返回 *TaskError，超时返回 ErrTaskTimeout；返回的状态为最后一次查询的结果
+func (c *Client) WaitTask(upid string, timeout time.Duration) (*TaskStatus, error) {
+	if timeout <= 0 {
+		timeout = models.DefaultTaskTimeoutSeconds * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := c.GetTaskStatus(upid)
+		if err != nil {
+			return nil, err
+		}
+		if !status.Running() {
+			if !status.Succeeded() {
+				return status, &TaskError{UPID: upid, ExitStatus: status.ExitStatus}
+			}
+			return status, nil
+		}
+		if time.Now().Add(models.TaskPollInterval).After(deadline) {
+			return status, fmt.Errorf("%w (%v): %s", ErrTaskTimeout, timeout, upid)
+		}
+		time.Sleep(models.TaskPollInterval)
+	}
+}
+
+// GetActiveBackups 获取节点上正在备份的虚拟机（运行中的 vzdump 任务）
+// 一次备份多台虚拟机的任务没有 VMID，这些虚拟机由调用方按 backup 配置锁判断（见 BackingUp）
+func (c *Client) GetActiveBackups() (map[int]bool, error) {
+	resp, err := c.client.R().
+		SetQueryParams(map[string]string{
+			"typefilter": "vzdump",
+			"source":     "active",
+		}).
+		Get(fmt.Sprintf("/nodes/%s/tasks", c.config.Node))
+
+	if err != nil {
+		return nil, fmt.Errorf("获取运行中的任务失败: %w", err)
+	}
+	if err := checkResty(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []Task `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("解析运行中的任务失败: %w", err)
+	}
+	return ActiveBackups(result.Data), nil
+}
+
+// ActiveBackups 从任务列表中取运行中的单台虚拟机备份任务的 VMID
+func ActiveBackups(tasks []Task) map[int]bool {
+	vmids := make(map[int]bool)
+	for _, task := range tasks {
+		if task.Type != "vzdump" || task.Status != "" {
+			continue
+		}
+		if vmid, err := ParseVMID(task.ID); err == nil {
+			vmids[vmid] = true
+		}
+	}
+	return vmids
+}
+
+// BackingUp 虚拟机是否正在备份（有自己的 vzdump 任务，或在多台虚拟机的备份任务中持有 backup 配置锁）
+func BackingUp(vm models.VMInfo, active map[int]bool) bool {
+	return active[vm.VMID] || vm.Lock == models.LockBackup
+}
@@ -0,0 +1,111 @@
+package pve
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestWaitTask(t *testing.T) {
+	const (
+		stopUPID     = "UPID:pve2:0000A1B2:01234567:66000000:qmstop:100:monitor@pve!t:"
+		shutdownUPID = "UPID:pve:0000A1B3:01234568:66000001:qmshutdown:100:monitor@pve!t:"
+	)
+	statuses := map[string]string{
+		stopUPID:     `{"data": {"status": "stopped", "exitstatus": "OK", "type": "qmstop"}}`,
+		shutdownUPID: `{"data": {"status": "stopped", "exitstatus": "VM 100 is locked (backup)", "type": "qmshutdown"}}`,
+		"UPID:pve:0000A1B4:01234569:66000002:qmstart:100:monitor@pve!t:": `{"data": {"status": "running", "type": "qmstart"}}`,
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api2/json/nodes/pve/qemu/100/status/stop":
+			w.Write([]byte(`{"data": "` + stopUPID + `"}`))
+		case r.URL.Path == "/api2/json/nodes/pve/qemu/100/status/shutdown":
+			w.Write([]byte(`{"data": "` + shutdownUPID + `"}`))
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			// 任务状态需要从 UPID 所在的节点查询
+			parts := strings.Split(r.URL.Path, "/")
+			upid := parts[len(parts)-2]
+			if body, ok := statuses[upid]; ok && parts[4] == strings.Split(upid, ":")[1] {
+				w.Write([]byte(body))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := NewClient(models.PVEConfig{Host: host, Port: portNumber, Node: "pve", APITokenID: "monitor@pve!t", APITokenSecret: "s"})
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	upid, err := client.StopVM(100)
+	if err != nil || upid != stopUPID {
+		t.Fatalf("StopVM() = %q, %v, want %q", upid, err, stopUPID)
+	}
+	status, err := client.WaitTask(upid, time.Minute)
+	if err != nil || !status.Succeeded() {
+		t.Fatalf("WaitTask(stop) = %+v, %v, want success", status, err)
+	}
+
+	upid, err = client.ShutdownVM(100)
+	if err != nil || upid != shutdownUPID {
+		t.Fatalf("ShutdownVM() = %q, %v, want %q", upid, err, shutdownUPID)
+	}
+	_, err = client.WaitTask(upid, time.Minute)
+	if !errors.Is(err, ErrLocked) || ErrorKind(err) != models.ActionErrorLocked {
+		t.Fatalf("WaitTask(shutdown) error = %v, want ErrLocked", err)
+	}
+
+	status, err = client.WaitTask("UPID:pve:0000A1B4:01234569:66000002:qmstart:100:monitor@pve!t:", time.Second)
+	if !errors.Is(err, ErrTaskTimeout) || ErrorKind(err) != models.ActionErrorTimeout || !status.Running() {
+		t.Fatalf("WaitTask(start) = %+v, %v, want timeout", status, err)
+	}
+}
+
+func TestParseUPID(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"data": "UPID:pve:0000A1B2:01234567:66000000:qmstop:100:root@pam:"}`, "UPID:pve:0000A1B2:01234567:66000000:qmstop:100:root@pam:"},
+		{`{"data": null}`, ""},
+		{`{"data": {"ticket": "x"}}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := parseUPID([]byte(tt.body)); got != tt.want {
+			t.Fatalf("parseUPID(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestActiveBackups(t *testing.T) {
+	tasks := []Task{
+		{Type: "vzdump", ID: "101"},
+		{Type: "vzdump", ID: ""}, // 多台虚拟机的备份任务
+		{Type: "vzdump", ID: "102", Status: "OK"},
+		{Type: "qmstart", ID: "103"},
+	}
+	active := ActiveBackups(tasks)
+	if len(active) != 1 || !active[101] {
+		t.Fatalf("ActiveBackups() = %v, want only 101", active)
+	}
+	if !BackingUp(models.VMInfo{VMID: 104, Lock: models.LockBackup}, active) {
+		t.Fatal("VM holding the backup lock is not reported as backing up")
+	}
+	if BackingUp(models.VMInfo{VMID: 102}, active) {
+		t.Fatal("VM with a finished backup task is reported as backing up")
+	}
+}
@@ -0,0 +1,78 @@
+package pve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// TLSConfig 按配置创建访问 PVE API 的 TLS 配置
+// 优先级: 固定指纹 > 指定 CA 证书 > 系统 CA；都未配置时不验证证书（本机访问的默认行为）
+func TLSConfig(cfg models.PVEConfig) (*tls.Config, error) {
+	if cfg.Fingerprint != "" {
+		want, err := parseFingerprint(cfg.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{
+			// PVE 默认使用自签名证书，固定指纹时只比较证书本身，不验证证书链和主机名
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return fmt.Errorf("PVE 未提供证书")
+				}
+				got := sha256.Sum256(rawCerts[0])
+				if !bytes.Equal(got[:], want) {
+					return fmt.Errorf("PVE 证书指纹不匹配: %s", formatFingerprint(got[:]))
+				}
+				return nil
+			},
+		}, nil
+	}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书文件 %s 中没有有效的 PEM 证书", cfg.CACert)
+		}
+		return &tls.Config{RootCAs: pool}, nil
+	}
+
+	if cfg.VerifyTLS {
+		return &tls.Config{}, nil
+	}
+
+	return &tls.Config{
+		InsecureSkipVerify: true, // 本地访问不验证证书
+	}, nil
+}
+
+// parseFingerprint 解析 SHA-256 指纹（允许冒号分隔和大小写）
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", "")
+	sum, err := hex.DecodeString(cleaned)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("无效的证书指纹: %s（需要 SHA-256 指纹，如 AA:BB:...）", fingerprint)
+	}
+	return sum, nil
+}
+
+// formatFingerprint 格式化为 PVE 显示的指纹格式（大写，冒号分隔）
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
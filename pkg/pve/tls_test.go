@@ -0,0 +1,56 @@
+package pve
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestTLSConfigVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	sum := sha256.Sum256(cert.Raw)
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	wrong := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name    string
+		cfg     models.PVEConfig
+		wantErr bool
+	}{
+		{"insecure default", models.PVEConfig{}, false},
+		{"system CAs", models.PVEConfig{VerifyTLS: true}, true},
+		{"custom CA", models.PVEConfig{CACert: caPath}, false},
+		{"pinned fingerprint", models.PVEConfig{Fingerprint: formatFingerprint(sum[:])}, false},
+		{"wrong fingerprint", models.PVEConfig{Fingerprint: formatFingerprint(wrong[:])}, true},
+	}
+	for _, tt := range tests {
+		tlsConfig, err := TLSConfig(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: TLSConfig() error = %v", tt.name, err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Get() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := TLSConfig(models.PVEConfig{Fingerprint: "AA:BB"}); err == nil {
+		t.Fatal("TLSConfig() with short fingerprint should fail")
+	}
+}
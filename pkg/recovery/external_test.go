@@ -0,0 +1,64 @@
+package recovery
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestNoteExternalChange(t *testing.T) {
+	store, err := storage.NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	actionTime := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	for vmid, action := range map[int]string{101: models.ActionShutdown, 102: models.ActionRateLimit} {
+		storage.UpdateVMState(store, vmid, map[string]interface{}{
+			"original_status": "running",
+			"action_taken":    action,
+			"action_time":     actionTime,
+			"needs_recovery":  true,
+			"recovery_time":   actionTime.Add(24 * time.Hour),
+		})
+	}
+	m := NewManager(nil, store)
+	if loaded := m.LoadStates([]int{101, 102}); loaded != 2 {
+		t.Fatalf("LoadStates() = %d, want 2", loaded)
+	}
+
+	after := actionTime.Add(time.Minute)
+	cases := []struct {
+		name  string
+		vmid  int
+		event models.PowerEvent
+		want  bool
+	}{
+		{"shutdown completing", 101, models.PowerEvent{Time: after, From: "running", To: "stopped"}, false},
+		{"observed before the action", 101, models.PowerEvent{Time: actionTime.Add(-time.Minute), From: "stopped", To: "running"}, false},
+		{"manual start during shutdown", 101, models.PowerEvent{Time: after, From: "stopped", To: "running"}, true},
+		{"already marked", 101, models.PowerEvent{Time: after.Add(time.Minute), From: "running", To: "stopped"}, false},
+		{"manual stop during rate limit", 102, models.PowerEvent{Time: after, From: "running", To: "stopped"}, true},
+		{"no recovery record", 103, models.PowerEvent{Time: after, From: "running", To: "stopped"}, false},
+	}
+	for _, tc := range cases {
+		if _, got := m.NoteExternalChange(tc.vmid, tc.event); got != tc.want {
+			t.Fatalf("%s: NoteExternalChange() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// 标记保存在存储中，重新加载后仍然有效；再次执行操作后清除
+	reloaded := NewManager(nil, store)
+	reloaded.LoadStates([]int{101})
+	if state, _ := reloaded.State(101); state.ExternalStatus != "running" || !state.ExternalAt.Equal(after) {
+		t.Fatalf("reloaded state = %+v, want external status running", state)
+	}
+	reloaded.ClearExternalChange(101)
+	if state, _ := storage.LoadRecoveryState(store, 101); state == nil || state.ExternallyModified() {
+		t.Fatalf("state after clearing = %+v, want no external change", state)
+	}
+}
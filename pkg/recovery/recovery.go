@@ -1,20 +1,37 @@
 package recovery
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"pve-traffic-monitor/pkg/models"
 	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/pve"
 	"pve-traffic-monitor/pkg/storage"
+	"sort"
+	"sync"
 	"time"
 )
 
+// ErrNoPendingRecovery 虚拟机没有待恢复的限制
+var ErrNoPendingRecovery = errors.New("虚拟机没有待恢复的限制")
+
 // Manager 恢复管理器
 type Manager struct {
 	pveClient    *pve.Client
 	storage      storage.Interface
 	stateManager *models.VMStateManager
+
+	markerMu   sync.RWMutex
+	tags       models.TagConfig // 操作标签配置（恢复时清理该命名空间的标签）
+	manageTags bool             // 为 false 时不修改 PVE 标签
+	marker     string           // 限制状态标记方式（tags / description）
+
+	taskTimeout time.Duration // 等待启动任务完成的时间
+
+	clientFor func(vmid int) *pve.Client // 按虚拟机选择 PVE 客户端（汇总端中其他节点的虚拟机），为 nil 时使用 pveClient
+
+	onRecovered func(state models.VMState) // 恢复成功后的回调（参数为恢复前的限制状态）
 }
 
 // NewManager 创建恢复管理器
@@ -23,11 +40,110 @@ func NewManager(pveClient *pve.Client, storage storage.Interface) *Manager {
 		pveClient:    pveClient,
 		storage:      storage,
 		stateManager: models.NewVMStateManager(),
+		manageTags:   true,
+		marker:       models.MarkerTags,
+	}
+}
+
+// SetClientResolver 设置按虚拟机选择 PVE 客户端的函数（汇总端恢复代理节点上的虚拟机，或配置重载后换用新客户端）
+func (m *Manager) SetClientResolver(clientFor func(vmid int) *pve.Client) {
+	m.clientFor = clientFor
+}
+
+// OnRecovered 设置恢复成功后的回调（发布恢复事件）
+func (m *Manager) OnRecovered(fn func(state models.VMState)) {
+	m.onRecovered = fn
+}
+
+// client 虚拟机所在节点的 PVE 客户端
+func (m *Manager) client(vmid int) *pve.Client {
+	if m.clientFor != nil {
+		return m.clientFor(vmid)
+	}
+	return m.pveClient
+}
+
+// SetMarkerConfig 设置操作标签、限制状态标记方式和任务等待时间（配置重载时调用）
+func (m *Manager) SetMarkerConfig(cfg models.MonitorConfig) {
+	m.markerMu.Lock()
+	defer m.markerMu.Unlock()
+	m.tags = cfg.Tags
+	m.manageTags = cfg.TagsManaged()
+	m.marker = cfg.MarkerBackend()
+	m.taskTimeout = cfg.TaskWait()
+}
+
+// clearMarkers 清除虚拟机上的限制状态标记（标签命名空间下的所有标签或备注中的状态块）
+func (m *Manager) clearMarkers(vmid int) error {
+	m.markerMu.RLock()
+	marker := m.marker
+	m.markerMu.RUnlock()
+
+	if marker == models.MarkerDescription {
+		return m.client(vmid).ClearVMMarker(vmid)
+	}
+	return m.removeManagedTags(vmid)
+}
+
+// removeManagedTags 移除虚拟机上属于标签命名空间的所有标签（包括规则特定的标签）
+func (m *Manager) removeManagedTags(vmid int) error {
+	m.markerMu.RLock()
+	tagConfig, manage := m.tags, m.manageTags
+	m.markerMu.RUnlock()
+	if !manage {
+		return nil
+	}
+
+	tags, err := m.client(vmid).GetVMTags(vmid)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if tagConfig.IsManaged(tag) {
+			m.client(vmid).RemoveVMTag(vmid, tag)
+		}
+	}
+	return nil
+}
+
+// ActionActive 虚拟机是否处于该操作的限制中（尚未恢复），shutdown 与 stop 视为同一操作
+func (m *Manager) ActionActive(vmid int, action string) bool {
+	state, exists := m.stateManager.GetState(vmid)
+	if !exists || !state.NeedsRecovery {
+		return false
+	}
+	return models.SameAction(state.ActionTaken, action)
+}
+
+// State 获取虚拟机当前的状态记录（副本）
+func (m *Manager) State(vmid int) (models.VMState, bool) {
+	state, exists := m.stateManager.GetState(vmid)
+	if !exists {
+		return models.VMState{}, false
+	}
+	return *state, true
+}
+
+// LastRecoveredAt 获取持久化状态中的最近恢复时间（没有记录时返回零值）
+func (m *Manager) LastRecoveredAt(vmid int) time.Time {
+	return storage.LastRecoveredAt(m.storage, vmid)
+}
+
+// States 获取当前记录的所有虚拟机状态（副本，按 VMID 排序）
+func (m *Manager) States() []models.VMState {
+	all := m.stateManager.GetAllStates()
+	states := make([]models.VMState, 0, len(all))
+	for _, state := range all {
+		states = append(states, *state)
 	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].VMID < states[j].VMID
+	})
+	return states
 }
 
-// RecordVMState 记录虚拟机状态（在执行操作前）
-func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCreationTime bool, creationTime time.Time) error {
+// RecordVMState 记录虚拟机状态（在执行操作前），恢复时间为规则周期计算器给出的下一个周期开始
+func (m *Manager) RecordVMState(vmid int, action, ruleName string, interfaces []string, calc *periodcalc.Calculator) error {
 	if state, exists := m.stateManager.GetState(vmid); exists &&
 		state.NeedsRecovery &&
 		action == models.ActionRateLimit &&
@@ -36,7 +152,7 @@ func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCr
 	}
 
 	// 获取当前虚拟机状态
-	vmInfo, err := m.pveClient.GetVMStatus(vmid)
+	vmInfo, err := m.client(vmid).GetVMStatus(vmid)
 	if err != nil {
 		return fmt.Errorf("获取虚拟机状态失败: %w", err)
 	}
@@ -45,7 +161,7 @@ func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCr
 	rateLimit := 0.0
 	networkRates := map[string]float64{}
 	networkLinks := map[string]bool{}
-	config, err := m.pveClient.GetVMConfig(vmid)
+	config, err := m.client(vmid).GetVMConfig(vmid)
 	if err == nil {
 		parsedRates, err := pve.NetworkRateLimitsFromConfig(config)
 		if err == nil {
@@ -57,10 +173,29 @@ func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCr
 		if parsedLinks, err := pve.NetworkLinkDownStatesFromConfig(config); err == nil {
 			networkLinks = parsedLinks
 		}
+
+		// 只记录规则作用的网卡，恢复时不改动其他网卡
+		if len(interfaces) > 0 {
+			selected := make(map[string]bool)
+			for _, key := range pve.NetworkInterfaceKeys(config, interfaces) {
+				selected[key] = true
+			}
+			for key := range networkRates {
+				if !selected[key] {
+					delete(networkRates, key)
+				}
+			}
+			for key := range networkLinks {
+				if !selected[key] {
+					delete(networkLinks, key)
+				}
+			}
+		}
 	}
 
 	// 计算恢复时间（支持基于创建时间的周期）
-	recoveryTime := calculateRecoveryTime(period, useCreationTime, creationTime)
+	period := calc.Period()
+	recoveryTime := calculateRecoveryTime(calc)
 
 	state := &models.VMState{
 		VMID:              vmid,
@@ -78,8 +213,8 @@ func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCr
 
 	m.stateManager.RecordState(state)
 
-	// 持久化到存储
-	if err := m.storage.SaveVMState(vmid, map[string]interface{}{
+	// 持久化到存储（合并更新，保留状态中的其他键，如运维设置的创建时间）
+	if err := storage.UpdateVMState(m.storage, vmid, map[string]interface{}{
 		"original_status":     state.OriginalStatus,
 		"original_rate_limit": state.OriginalRateLimit,
 		"original_net_rates":  state.OriginalNetRates,
@@ -90,6 +225,9 @@ func (m *Manager) RecordVMState(vmid int, action, period, ruleName string, useCr
 		"rule_name":           state.RuleName,
 		"needs_recovery":      state.NeedsRecovery,
 		"recovery_time":       state.RecoveryTime,
+		"recovery_canceled":   nil,
+		"external_status":     nil,
+		"external_at":         nil,
 	}); err != nil {
 		log.Printf("保存虚拟机状态失败: %v", err)
 	}
@@ -109,60 +247,146 @@ func (m *Manager) RecoverVM(vmid int) error {
 	// 根据原始状态恢复
 	switch state.ActionTaken {
 	case "shutdown", "stop":
-		// 如果原本是运行状态，重新启动
-		if state.OriginalStatus == "running" {
-			if err := m.pveClient.StartVM(vmid); err != nil {
+		// 如果原本是运行状态，重新启动；限制期间被外部修改过运行状态时保持当前状态，不自动启动
+		if state.ExternallyModified() {
+			log.Printf("VM%d 限制期间运行状态被外部修改（%s），恢复时不启动虚拟机", vmid, state.ExternalStatus)
+		} else if state.OriginalStatus == "running" {
+			upid, err := m.client(vmid).StartVM(vmid)
+			if err != nil {
 				return fmt.Errorf("启动失败: %w", err)
 			}
+			// 等待启动任务完成，失败时保留状态记录，下次检查时重试
+			if upid != "" {
+				m.markerMu.RLock()
+				timeout := m.taskTimeout
+				m.markerMu.RUnlock()
+				if _, err := m.client(vmid).WaitTask(upid, timeout); err != nil {
+					return fmt.Errorf("启动失败: %w", err)
+				}
+			}
 		}
 
 	case "disconnect":
 		// 恢复网络连接
 		if len(state.OriginalNetLinks) > 0 {
-			if err := m.pveClient.RestoreNetworkLinkStates(vmid, state.OriginalNetLinks); err != nil {
+			if err := m.client(vmid).RestoreNetworkLinkStates(vmid, state.OriginalNetLinks); err != nil {
 				return fmt.Errorf("恢复网络失败: %w", err)
 			}
-		} else if err := m.pveClient.ConnectNetwork(vmid); err != nil {
+		} else if err := m.client(vmid).ConnectNetwork(vmid); err != nil {
 			return fmt.Errorf("恢复网络失败: %w", err)
 		}
 
 	case "rate_limit":
 		// 恢复原始速率限制
 		if len(state.OriginalNetRates) > 0 {
-			if err := m.pveClient.RestoreNetworkRateLimits(vmid, state.OriginalNetRates); err != nil {
+			if err := m.client(vmid).RestoreNetworkRateLimits(vmid, state.OriginalNetRates); err != nil {
 				return fmt.Errorf("恢复网络速率限制失败: %w", err)
 			}
 		} else if state.OriginalRateLimit == 0 {
-			if err := m.pveClient.RemoveNetworkRateLimit(vmid); err != nil {
+			if err := m.client(vmid).RemoveNetworkRateLimit(vmid); err != nil {
 				return fmt.Errorf("移除网络速率限制失败: %w", err)
 			}
 		} else {
-			if err := m.pveClient.SetNetworkRateLimit(vmid, state.OriginalRateLimit); err != nil {
+			if err := m.client(vmid).SetNetworkRateLimit(vmid, state.OriginalRateLimit); err != nil {
 				return fmt.Errorf("恢复网络速率限制失败: %w", err)
 			}
 		}
 	}
 
-	// 清理所有 traffic- 开头的标签
-	tags, err := m.pveClient.GetVMTags(vmid)
-	if err == nil {
-		for _, tag := range tags {
-			if len(tag) >= 8 && tag[:8] == "traffic-" {
-				m.pveClient.RemoveVMTag(vmid, tag)
-			}
-		}
+	// 清理限制状态标记（标签命名空间下的所有标签或备注中的状态块）
+	if err := m.clearMarkers(vmid); err != nil {
+		log.Printf("VM%d 清理限制状态标记失败: %v", vmid, err)
 	}
 
 	// 移除状态记录
 	m.stateManager.RemoveState(vmid)
-	m.storage.SaveVMState(vmid, map[string]interface{}{
-		"needs_recovery": false,
-		"recovered_at":   time.Now(),
+	storage.UpdateVMState(m.storage, vmid, map[string]interface{}{
+		"needs_recovery":    false,
+		"recovered_at":      time.Now(),
+		"recovery_canceled": nil,
+		"external_status":   nil,
+		"external_at":       nil,
 	})
 
+	if m.onRecovered != nil {
+		m.onRecovered(*state)
+	}
+
 	return nil
 }
 
+// Reschedule 修改虚拟机的计划恢复时间，until 为零值时取消自动恢复（保持限制，直到重新安排恢复时间），返回修改后的状态
+func (m *Manager) Reschedule(vmid int, until time.Time) (models.VMState, error) {
+	state, exists := m.stateManager.GetState(vmid)
+	if !exists || !state.NeedsRecovery {
+		return models.VMState{}, ErrNoPendingRecovery
+	}
+
+	// 修改副本再替换，不改动其他协程可能正在读取的记录
+	updated := *state
+	updated.RecoveryCanceled = until.IsZero()
+	if !until.IsZero() {
+		updated.RecoveryTime = until
+	}
+	if err := storage.UpdateVMState(m.storage, vmid, map[string]interface{}{
+		"recovery_time":     updated.RecoveryTime,
+		"recovery_canceled": updated.RecoveryCanceled,
+	}); err != nil {
+		return models.VMState{}, fmt.Errorf("保存恢复时间失败: %w", err)
+	}
+	m.stateManager.RecordState(&updated)
+
+	if updated.RecoveryCanceled {
+		log.Printf("VM%d 已取消自动恢复，保持 %s 直到重新安排恢复时间", vmid, updated.ActionTaken)
+	} else {
+		log.Printf("VM%d 恢复时间已修改为 %s", vmid, until.Format(time.RFC3339))
+	}
+	return updated, nil
+}
+
+// NoteExternalChange 检查限制期间的运行状态变化是否来自外部：限制开始之后变为与预期不同的状态
+// （如关机限制期间被手动开机，限速、断网期间被手动关机）时标记虚拟机已被外部修改，之后恢复时不再改变其运行状态
+// 每次限制只标记一次，返回标记后的状态和是否新标记
+func (m *Manager) NoteExternalChange(vmid int, event models.PowerEvent) (models.VMState, bool) {
+	state, exists := m.stateManager.GetState(vmid)
+	if !exists || !state.NeedsRecovery || state.ExternallyModified() || !event.Time.After(state.ActionTime) {
+		return models.VMState{}, false
+	}
+	if expected := state.ExpectedStatus(); expected == "" || event.To == expected {
+		return models.VMState{}, false
+	}
+
+	updated := *state
+	updated.ExternalStatus = event.To
+	updated.ExternalAt = event.Time
+	if err := storage.UpdateVMState(m.storage, vmid, map[string]interface{}{
+		"external_status": updated.ExternalStatus,
+		"external_at":     updated.ExternalAt,
+	}); err != nil {
+		log.Printf("VM%d 保存外部修改标记失败: %v", vmid, err)
+	}
+	m.stateManager.RecordState(&updated)
+	return updated, true
+}
+
+// ClearExternalChange 清除外部修改标记（再次执行操作、重新建立限制之后调用）
+func (m *Manager) ClearExternalChange(vmid int) {
+	state, exists := m.stateManager.GetState(vmid)
+	if !exists || !state.ExternallyModified() {
+		return
+	}
+	updated := *state
+	updated.ExternalStatus = ""
+	updated.ExternalAt = time.Time{}
+	if err := storage.UpdateVMState(m.storage, vmid, map[string]interface{}{
+		"external_status": nil,
+		"external_at":     nil,
+	}); err != nil {
+		log.Printf("VM%d 清除外部修改标记失败: %v", vmid, err)
+	}
+	m.stateManager.RecordState(&updated)
+}
+
 // CheckAndRecoverDue 检查并恢复到期的虚拟机
 func (m *Manager) CheckAndRecoverDue() error {
 	dueStates := m.stateManager.GetRecoveryDueStates()
@@ -201,60 +425,76 @@ func (m *Manager) RecoverAll() error {
 	return nil
 }
 
-// CleanupAllTags 清理所有虚拟机的流量标签
+// CleanupAllTags 清理所有虚拟机的限制状态标记（流量标签或备注中的状态块）
 func (m *Manager) CleanupAllTags(vms []models.VMInfo) error {
 	for _, vm := range vms {
-		// 获取虚拟机的所有标签
-		tags, err := m.pveClient.GetVMTags(vm.VMID)
+		if err := m.clearMarkers(vm.VMID); err != nil {
+			log.Printf("清理虚拟机 %d 限制状态标记失败: %v", vm.VMID, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadStatesFromStorage 从存储加载所有待恢复的虚拟机状态（程序启动时，上次退出时保留了限制），返回加载的数量
+func (m *Manager) LoadStatesFromStorage() (int, error) {
+	vmids, err := storage.StateVMIDs(m.storage)
+	if err != nil {
+		return 0, err
+	}
+	return m.LoadStates(vmids), nil
+}
+
+// LoadStates 从存储重新加载指定虚拟机的恢复记录（主备切换后接管其他实例执行的操作），返回需要恢复的虚拟机数量
+// 存储中没有待恢复记录的虚拟机从内存中移除
+func (m *Manager) LoadStates(vmids []int) int {
+	loaded := 0
+	for _, vmid := range vmids {
+		state, err := storage.LoadRecoveryState(m.storage, vmid)
 		if err != nil {
-			log.Printf("获取虚拟机 %d 标签失败: %v", vm.VMID, err)
+			log.Printf("%v", err)
 			continue
 		}
-
-		// 移除所有以 traffic- 开头的标签（包括规则特定的标签）
-		for _, tag := range tags {
-			if len(tag) >= 8 && tag[:8] == "traffic-" {
-				m.pveClient.RemoveVMTag(vm.VMID, tag)
-			}
+		if state == nil {
+			m.stateManager.RemoveState(vmid)
+			continue
 		}
+		m.stateManager.RecordState(state)
+		loaded++
 	}
-
-	return nil
+	return loaded
 }
 
-// LoadStatesFromStorage 从存储加载状态（程序启动时）
-func (m *Manager) LoadStatesFromStorage() error {
-	// TODO: 从存储中加载所有虚拟机状态
-	return nil
+// SyncStates 移除存储中已被清除的虚拟机的恢复记录（命令行清除虚拟机状态后调用），返回移除的数量
+func (m *Manager) SyncStates() int {
+	removed := 0
+	for _, state := range m.stateManager.GetAllStates() {
+		persisted, err := m.storage.LoadVMState(state.VMID)
+		if err != nil {
+			continue
+		}
+		if _, exists := persisted["needs_recovery"]; !exists {
+			m.stateManager.RemoveState(state.VMID)
+			removed++
+		}
+	}
+	return removed
 }
 
-// calculateRecoveryTime 计算恢复时间
-func calculateRecoveryTime(period string, useCreationTime bool, creationTime time.Time) time.Time {
+// calculateRecoveryTime 计算恢复时间：下一个周期开始（按规则的时区、账单日或创建时间划分周期）
+// 滑动窗口周期为窗口内流量全部移出的时间，用量提前回落时由监控循环提前恢复
+func calculateRecoveryTime(calc *periodcalc.Calculator) time.Time {
 	now := time.Now()
 
-	if useCreationTime && !creationTime.IsZero() {
-		// 基于创建时间计算下一个周期开始时间
-		return calculateNextPeriodStart(period, creationTime, now)
-	}
-
-	// 使用固定周期
-	switch period {
-	case "hour":
-		// 下一个小时的开始
-		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
-	case "day":
-		// 明天的开始
-		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-	case "month":
-		// 下个月的开始
-		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	if _, rolling := calc.Rolling(); rolling {
+		return calc.NextPeriodStartAt(now)
+	}
+
+	switch calc.Period() {
+	case "hour", "day", "month":
+		return calc.NextPeriodStartAt(now)
 	default:
 		// 默认一小时后
 		return now.Add(1 * time.Hour)
 	}
 }
-
-// calculateNextPeriodStart 计算基于创建时间的下一个周期开始时间
-func calculateNextPeriodStart(period string, creationTime, now time.Time) time.Time {
-	return periodcalc.CalculateNextCreationBasedPeriodStart(period, creationTime, now)
-}
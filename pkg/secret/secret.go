@@ -0,0 +1,175 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// credentialsDirEnv systemd 为服务提供凭据的目录（LoadCredential=/SetCredential= 等）
+const credentialsDirEnv = "CREDENTIALS_DIRECTORY"
+
+// vaultTimeout 读取 Vault 密钥的超时时间
+const vaultTimeout = 10 * time.Second
+
+var vaultClient = &http.Client{Timeout: vaultTimeout}
+
+// Validate 检查密钥来源配置是否完整
+func Validate(cfg models.SecretConfig) error {
+	switch cfg.Provider {
+	case models.SecretProviderFile:
+		if cfg.Path == "" {
+			return fmt.Errorf("file 密钥需要配置 path")
+		}
+	case models.SecretProviderSystemd:
+		if cfg.Name == "" {
+			return fmt.Errorf("systemd 密钥需要配置 name")
+		}
+	case models.SecretProviderVault:
+		if cfg.Path == "" || cfg.Key == "" {
+			return fmt.Errorf("vault 密钥需要配置 path 和 key")
+		}
+	default:
+		return fmt.Errorf("不支持的密钥来源: %s（支持 file, systemd, vault）", cfg.Provider)
+	}
+	return nil
+}
+
+// Resolve 读取密钥内容（去掉首尾空白，文件末尾的换行不会进入令牌或密码）
+func Resolve(cfg models.SecretConfig, vault models.VaultConfig) (string, error) {
+	if err := Validate(cfg); err != nil {
+		return "", err
+	}
+
+	switch cfg.Provider {
+	case models.SecretProviderVault:
+		return readVault(vault, cfg.Path, cfg.Key)
+	default:
+		path, err := filePath(cfg)
+		if err != nil {
+			return "", err
+		}
+		return readFile(path)
+	}
+}
+
+// Files 返回基于文件的密钥路径（主程序检测这些文件的修改以便在轮换后重新加载）
+func Files(secrets map[string]models.SecretConfig) []string {
+	var files []string
+	for _, cfg := range secrets {
+		if cfg.Provider == models.SecretProviderVault {
+			continue
+		}
+		if path, err := filePath(cfg); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// filePath 获取 file/systemd 密钥的文件路径
+func filePath(cfg models.SecretConfig) (string, error) {
+	if cfg.Provider == models.SecretProviderFile {
+		return cfg.Path, nil
+	}
+	dir := os.Getenv(credentialsDirEnv)
+	if dir == "" {
+		return "", fmt.Errorf("未设置 %s，systemd 凭据仅在服务配置了 LoadCredential= 时可用", credentialsDirEnv)
+	}
+	return filepath.Join(dir, cfg.Name), nil
+}
+
+// readFile 读取密钥文件
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("密钥文件 %s 为空", path)
+	}
+	return value, nil
+}
+
+// readVault 读取 Vault KV 密钥中的字段（同时支持 KV v1 和 v2 的响应格式）
+func readVault(vault models.VaultConfig, path, key string) (string, error) {
+	address := vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return "", fmt.Errorf("未配置 Vault 地址（vault.address 或 VAULT_ADDR）")
+	}
+
+	token, err := vaultToken(vault)
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimRight(address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建 Vault 请求失败: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("读取 Vault 密钥 %s 失败: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("读取 Vault 响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("读取 Vault 密钥 %s 失败: 状态码 %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+
+	// KV v2: {"data": {"data": {...}, "metadata": {...}}}
+	data := result.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+
+	value, ok := data[key].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("Vault 密钥 %s 中没有字段 %s", path, key)
+	}
+	return value, nil
+}
+
+// vaultToken 获取 Vault 访问令牌: token > token_file > VAULT_TOKEN
+func vaultToken(vault models.VaultConfig) (string, error) {
+	if vault.Token != "" {
+		return vault.Token, nil
+	}
+	if vault.TokenFile != "" {
+		return readFile(vault.TokenFile)
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("未配置 Vault 令牌（vault.token、vault.token_file 或 VAULT_TOKEN）")
+}
@@ -0,0 +1,56 @@
+package secret
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestResolveProviders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pve-token"), []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	t.Setenv(credentialsDirEnv, dir)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/monitor" || r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"db_password": "vault-secret"}, "metadata": {"version": 3}}}`))
+	}))
+	defer vault.Close()
+	vaultConfig := models.VaultConfig{Address: vault.URL, Token: "root"}
+
+	tests := []struct {
+		name   string
+		source models.SecretConfig
+		want   string
+	}{
+		{"file", models.SecretConfig{Provider: models.SecretProviderFile, Path: filepath.Join(dir, "pve-token")}, "file-secret"},
+		{"systemd", models.SecretConfig{Provider: models.SecretProviderSystemd, Name: "pve-token"}, "file-secret"},
+		{"vault", models.SecretConfig{Provider: models.SecretProviderVault, Path: "secret/data/monitor", Key: "db_password"}, "vault-secret"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(tt.source, vaultConfig)
+		if err != nil {
+			t.Fatalf("%s: Resolve() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: Resolve() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	missingKey := models.SecretConfig{Provider: models.SecretProviderVault, Path: "secret/data/monitor", Key: "other"}
+	if _, err := Resolve(missingKey, vaultConfig); err == nil {
+		t.Fatal("Resolve() with missing vault key should fail")
+	}
+	if _, err := Resolve(models.SecretConfig{Provider: "env"}, vaultConfig); err == nil {
+		t.Fatal("Resolve() with unknown provider should fail")
+	}
+}
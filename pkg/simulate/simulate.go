@@ -0,0 +1,295 @@
+package simulate
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+// Event 回放中规则会触发的一次操作
+type Event struct {
+	VMID              int       `json:"vmid"`
+	Name              string    `json:"name"`
+	Rule              string    `json:"rule"`
+	Action            string    `json:"action"`
+	TriggeredAt       time.Time `json:"triggered_at"`                  // 触发时间（触发时的采样时间）
+	RecoveryAt        time.Time `json:"recovery_at"`                   // 按规则周期计算的恢复时间
+	UsedGB            float64   `json:"used_gb,omitempty"`             // 流量规则：触发时周期内已用流量
+	RateMbps          float64   `json:"rate_mbps,omitempty"`           // 带宽规则：触发时窗口内平均带宽
+	LimitGB           float64   `json:"limit_gb,omitempty"`            // 流量规则：限额
+	RateThresholdMbps float64   `json:"rate_threshold_mbps,omitempty"` // 带宽规则：阈值
+}
+
+// Options 回放参数
+type Options struct {
+	CreationTime time.Time     // 虚拟机创建时间（规则 use_creation_time 时作为周期基准）
+	Interval     time.Duration // 采集间隔（带宽规则判断窗口是否被充分覆盖，与监控一致）
+}
+
+// Run 按时间顺序回放单个虚拟机的历史记录，返回规则会触发的操作
+// 与监控循环的判断一致：流量规则在周期内用量超过限额时触发，带宽规则在窗口平均带宽超过阈值时触发；
+// 触发后虚拟机在恢复时间之前保持限制状态，不会重复触发
+func Run(rule models.Rule, vm models.VMInfo, records []models.TrafficRecord, opts Options) []Event {
+	if len(records) == 0 {
+		return nil
+	}
+
+	sorted := make([]models.TrafficRecord, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	sorted = storage.MetricRecords(sorted, rule.Metric)
+
+	direction := models.DirectionBoth
+	if rule.TrafficDirection != "" {
+		direction = strings.ToLower(rule.TrafficDirection)
+	}
+	calc := periodcalc.ForRule(rule, opts.CreationTime)
+
+	newEvent := func(at time.Time) Event {
+		recoveryAt := calc.NextPeriodStartAt(at)
+		if rule.Period == "" {
+			// 与恢复管理器一致：未指定周期时一小时后恢复
+			recoveryAt = at.Add(time.Hour)
+		}
+		return Event{
+			VMID:        vm.VMID,
+			Name:        vm.Name,
+			Rule:        rule.Name,
+			Action:      rule.Action,
+			TriggeredAt: at,
+			RecoveryAt:  recoveryAt,
+		}
+	}
+
+	if rule.IsRateRule() {
+		return runRate(rule, vm.VMID, sorted, direction, opts.Interval, newEvent)
+	}
+	if rule.IsPercentileRule() {
+		return runPercentile(rule, sorted, direction, calc, newEvent)
+	}
+	if window, rolling := calc.Rolling(); rolling {
+		return runRolling(rule, sorted, direction, window, newEvent)
+	}
+	return runVolume(rule, sorted, direction, calc, newEvent)
+}
+
+// runVolume 回放流量规则：按周期累计相邻采样的增量
+func runVolume(rule models.Rule, records []models.TrafficRecord, direction string, calc *periodcalc.Calculator, newEvent func(time.Time) Event) []Event {
+	var events []Event
+	var periodStart, limitedUntil time.Time
+	var prev *models.TrafficRecord
+	var used uint64
+
+	for i := range records {
+		record := &records[i]
+
+		// 周期切换时重新累计（与监控只统计周期内的记录一致）
+		if start := calc.PeriodStartAt(record.Timestamp); !start.Equal(periodStart) {
+			periodStart = start
+			prev = nil
+			used = 0
+		}
+		if prev != nil {
+			used += delta(prev, record, direction)
+		}
+		prev = record
+
+		if record.Timestamp.Before(limitedUntil) {
+			continue
+		}
+
+		usedGB := float64(used) / models.BytesPerGB
+		if usedGB > rule.LimitGB {
+			event := newEvent(record.Timestamp)
+			event.UsedGB = usedGB
+			event.LimitGB = rule.LimitGB
+			events = append(events, event)
+			limitedUntil = event.RecoveryAt
+		}
+	}
+
+	return events
+}
+
+// runPercentile 回放 95 百分位规则：按周期把增量汇总到 5 分钟区间，
+// 超过阈值的区间数多于 5% 时（与监控一致，至少 MinPercentileSamples 个区间）触发
+func runPercentile(rule models.Rule, records []models.TrafficRecord, direction string, calc *periodcalc.Calculator, newEvent func(time.Time) Event) []Event {
+	var events []Event
+	var periodStart, limitedUntil time.Time
+	var prev *models.TrafficRecord
+	buckets := make(map[int64]uint64)
+	over := 0
+
+	for i := range records {
+		record := &records[i]
+
+		if start := calc.PeriodStartAt(record.Timestamp); !start.Equal(periodStart) {
+			periodStart = start
+			prev = nil
+			buckets = make(map[int64]uint64)
+			over = 0
+		}
+		if prev != nil {
+			key := record.Timestamp.Truncate(storage.PercentileBucket).Unix()
+			before := buckets[key]
+			buckets[key] = before + delta(prev, record, direction)
+			if storage.BucketMbps(before) <= rule.RateThresholdMbps && storage.BucketMbps(buckets[key]) > rule.RateThresholdMbps {
+				over++
+			}
+		}
+		prev = record
+
+		if record.Timestamp.Before(limitedUntil) || len(buckets) < storage.MinPercentileSamples {
+			continue
+		}
+
+		// 第 ceil(0.95n) 个值超过阈值，即超过阈值的区间数不少于 n-ceil(0.95n)+1
+		n := len(buckets)
+		if over >= n-int(math.Ceil(0.95*float64(n)))+1 {
+			rates := make([]float64, 0, n)
+			for _, bytes := range buckets {
+				rates = append(rates, storage.BucketMbps(bytes))
+			}
+			event := newEvent(record.Timestamp)
+			event.RateMbps = storage.Percentile(rates, 95)
+			event.RateThresholdMbps = rule.RateThresholdMbps
+			events = append(events, event)
+			limitedUntil = event.RecoveryAt
+		}
+	}
+
+	return events
+}
+
+// runRolling 回放滑动窗口流量规则：累计窗口内相邻采样的增量。
+// 触发后窗口内用量回落到限额以下时恢复（与监控提前恢复一致）；没有新采样时（如虚拟机已关机）
+// 按旧流量移出窗口的时间推算恢复时间
+func runRolling(rule models.Rule, records []models.TrafficRecord, direction string, window time.Duration, newEvent func(time.Time) Event) []Event {
+	type increment struct {
+		from  time.Time // 增量起点（前一条记录的时间），早于窗口开始时移出窗口
+		bytes uint64
+	}
+
+	var events []Event
+	var increments []increment
+	var used uint64
+	limited := false
+
+	// projectRecovery 没有新流量时用量回落到限额以下的时间
+	projectRecovery := func() time.Time {
+		remaining := used
+		for _, inc := range increments {
+			remaining -= inc.bytes
+			if float64(remaining)/models.BytesPerGB <= rule.LimitGB {
+				return inc.from.Add(window)
+			}
+		}
+		return events[len(events)-1].RecoveryAt
+	}
+
+	for i := 1; i < len(records); i++ {
+		at := records[i].Timestamp
+		if limited && at.After(events[len(events)-1].RecoveryAt) {
+			limited = false
+		}
+
+		b := delta(&records[i-1], &records[i], direction)
+		increments = append(increments, increment{from: records[i-1].Timestamp, bytes: b})
+		used += b
+
+		windowStart := at.Add(-window)
+		for len(increments) > 0 && increments[0].from.Before(windowStart) {
+			used -= increments[0].bytes
+			increments = increments[1:]
+		}
+
+		usedGB := float64(used) / models.BytesPerGB
+		if limited {
+			if usedGB <= rule.LimitGB {
+				events[len(events)-1].RecoveryAt = at
+				limited = false
+			} else {
+				events[len(events)-1].RecoveryAt = projectRecovery()
+			}
+			continue
+		}
+
+		if usedGB > rule.LimitGB {
+			event := newEvent(at)
+			event.UsedGB = usedGB
+			event.LimitGB = rule.LimitGB
+			events = append(events, event)
+			events[len(events)-1].RecoveryAt = projectRecovery()
+			limited = true
+		}
+	}
+
+	return events
+}
+
+// runRate 回放带宽规则：每个采样点计算之前 rate_window 内的平均带宽
+func runRate(rule models.Rule, vmid int, records []models.TrafficRecord, direction string, interval time.Duration, newEvent func(time.Time) Event) []Event {
+	window := rule.RateWindow()
+	minSpan := window - interval
+	if minSpan <= 0 {
+		minSpan = window / 2
+	}
+
+	var events []Event
+	var limitedUntil time.Time
+	lo := 0
+
+	for i := range records {
+		at := records[i].Timestamp
+		for records[lo].Timestamp.Before(at.Add(-window)) {
+			lo++
+		}
+		if at.Before(limitedUntil) {
+			continue
+		}
+
+		bitsPerSecond, span := storage.CalculateAverageRate(vmid, records[lo:i+1], direction)
+		if span <= 0 || span < minSpan {
+			continue
+		}
+
+		mbps := bitsPerSecond / 1_000_000
+		if mbps > rule.RateThresholdMbps {
+			event := newEvent(at)
+			event.RateMbps = mbps
+			event.RateThresholdMbps = rule.RateThresholdMbps
+			events = append(events, event)
+			limitedUntil = event.RecoveryAt
+		}
+	}
+
+	return events
+}
+
+// delta 计算相邻两条记录之间的流量增量（计数器变小视为虚拟机重启，从 0 开始累计，与 calculateTraffic 一致）
+func delta(prev, cur *models.TrafficRecord, direction string) uint64 {
+	rx := cur.RXBytes
+	if cur.RXBytes >= prev.RXBytes {
+		rx = cur.RXBytes - prev.RXBytes
+	}
+	tx := cur.TXBytes
+	if cur.TXBytes >= prev.TXBytes {
+		tx = cur.TXBytes - prev.TXBytes
+	}
+
+	switch direction {
+	case models.DirectionUpload, models.DirectionTX:
+		return tx
+	case models.DirectionDownload, models.DirectionRX:
+		return rx
+	default: // "both"
+		return rx + tx
+	}
+}
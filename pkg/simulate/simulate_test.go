@@ -0,0 +1,138 @@
+package simulate
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRunVolumeRuleTriggersOncePerPeriod(t *testing.T) {
+	rule := models.Rule{Name: "daily", Period: models.PeriodDay, LimitGB: 1, Action: models.ActionShutdown}
+	vm := models.VMInfo{VMID: 101, Name: "web"}
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	gb := uint64(models.BytesPerGB)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: base.Add(1 * time.Hour), RXBytes: 0},
+		{VMID: 101, Timestamp: base.Add(2 * time.Hour), RXBytes: gb / 2},
+		{VMID: 101, Timestamp: base.Add(3 * time.Hour), RXBytes: gb / 4}, // 重启，增量 0.25GB
+		{VMID: 101, Timestamp: base.Add(4 * time.Hour), RXBytes: gb},     // 累计 1.5GB，触发
+		{VMID: 101, Timestamp: base.Add(5 * time.Hour), RXBytes: 2 * gb}, // 已限制，不重复触发
+		// 第二天重新累计
+		{VMID: 101, Timestamp: base.Add(25 * time.Hour), RXBytes: 2 * gb},
+		{VMID: 101, Timestamp: base.Add(26 * time.Hour), RXBytes: 2*gb + gb/2},
+	}
+
+	events := Run(rule, vm, records, Options{})
+	if len(events) != 1 {
+		t.Fatalf("events = %#v, want 1 event", events)
+	}
+	event := events[0]
+	if !event.TriggeredAt.Equal(base.Add(4 * time.Hour)) {
+		t.Fatalf("triggered at %s, want %s", event.TriggeredAt, base.Add(4*time.Hour))
+	}
+	if !event.RecoveryAt.Equal(base.AddDate(0, 0, 1)) {
+		t.Fatalf("recovery at %s, want next day", event.RecoveryAt)
+	}
+	if event.UsedGB != 1.5 {
+		t.Fatalf("used = %v GB, want 1.5", event.UsedGB)
+	}
+}
+
+func TestRunRollingRuleRecoversWhenTrafficAgesOut(t *testing.T) {
+	rule := models.Rule{Name: "rolling", Period: "rolling:24h", LimitGB: 1, Action: models.ActionShutdown}
+	vm := models.VMInfo{VMID: 101, Name: "web"}
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	gb := uint64(models.BytesPerGB)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: base, RXBytes: 0},
+		{VMID: 101, Timestamp: base.Add(1 * time.Hour), RXBytes: gb * 3 / 4},
+		{VMID: 101, Timestamp: base.Add(2 * time.Hour), RXBytes: gb * 5 / 4}, // 窗口内 1.25GB，触发
+		// 关机后没有新流量，第一个小时的 0.75GB 移出窗口时回落到 0.5GB
+		{VMID: 101, Timestamp: base.Add(30 * time.Hour), RXBytes: gb * 5 / 4},
+	}
+
+	events := Run(rule, vm, records, Options{})
+	if len(events) != 1 {
+		t.Fatalf("events = %#v, want 1 event", events)
+	}
+	event := events[0]
+	if !event.TriggeredAt.Equal(base.Add(2 * time.Hour)) {
+		t.Fatalf("triggered at %s, want %s", event.TriggeredAt, base.Add(2*time.Hour))
+	}
+	if !event.RecoveryAt.Equal(base.Add(24 * time.Hour)) {
+		t.Fatalf("recovery at %s, want %s", event.RecoveryAt, base.Add(24*time.Hour))
+	}
+	if event.UsedGB != 1.25 {
+		t.Fatalf("used = %v GB, want 1.25", event.UsedGB)
+	}
+}
+
+func TestRunRateRuleRequiresCoveredWindow(t *testing.T) {
+	rule := models.Rule{
+		Name:              "burst",
+		Type:              models.RuleTypeRate,
+		Period:            models.PeriodHour,
+		RateThresholdMbps: 8,
+		RateWindowMinutes: 5,
+		Action:            models.ActionRateLimit,
+	}
+	vm := models.VMInfo{VMID: 102}
+
+	// 每分钟 120MB（16 Mbps）
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	var records []models.TrafficRecord
+	for i := 0; i <= 6; i++ {
+		records = append(records, models.TrafficRecord{
+			VMID:      102,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			TXBytes:   uint64(i) * 120_000_000,
+		})
+	}
+
+	events := Run(rule, vm, records, Options{Interval: time.Minute})
+	if len(events) != 1 {
+		t.Fatalf("events = %#v, want 1 event", events)
+	}
+	// 窗口需覆盖至少 4 分钟（窗口减去一个采集间隔）
+	if want := base.Add(4 * time.Minute); !events[0].TriggeredAt.Equal(want) {
+		t.Fatalf("triggered at %s, want %s", events[0].TriggeredAt, want)
+	}
+	if events[0].RateMbps != 16 {
+		t.Fatalf("rate = %v Mbps, want 16", events[0].RateMbps)
+	}
+}
+
+func TestRunPercentileRuleIgnoresShortBursts(t *testing.T) {
+	rule := models.Rule{Name: "p95", Type: models.RuleTypePercentile, Period: models.PeriodDay, RateThresholdMbps: 10, Action: models.ActionRateLimit, RateLimitMB: 1}
+	vm := models.VMInfo{VMID: 101, Name: "web"}
+
+	// 每 5 分钟一条记录，平时 1 Mbps，bursts 中的区间为 100 Mbps
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	build := func(buckets int, bursts map[int]bool) []models.TrafficRecord {
+		records := []models.TrafficRecord{{VMID: 101, Timestamp: base.Add(time.Minute)}}
+		var rx uint64
+		for i := 1; i <= buckets; i++ {
+			mbps := uint64(1)
+			if bursts[i] {
+				mbps = 100
+			}
+			rx += mbps * 1_000_000 / 8 * 300
+			records = append(records, models.TrafficRecord{VMID: 101, Timestamp: base.Add(time.Duration(i)*5*time.Minute + time.Minute), RXBytes: rx})
+		}
+		return records
+	}
+
+	// 60 个区间中 2 个突发：任何时刻突发都不超过已有区间的 5%，不触发
+	if events := Run(rule, vm, build(60, map[int]bool{5: true, 50: true}), Options{}); len(events) != 0 {
+		t.Fatalf("events = %#v, want none for 2 bursts in 60 buckets", events)
+	}
+
+	// 第 3 个突发使超过阈值的区间超过 5%
+	events := Run(rule, vm, build(60, map[int]bool{5: true, 50: true, 55: true}), Options{})
+	if len(events) != 1 || !events[0].TriggeredAt.Equal(base.Add(55*5*time.Minute+time.Minute)) || events[0].RateMbps != 100 {
+		t.Fatalf("events = %#v, want one trigger at the third burst with p95 100 Mbps", events)
+	}
+}
@@ -0,0 +1,282 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pve-traffic-monitor/pkg/cache"
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+	"pve-traffic-monitor/pkg/storage"
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// AllVMs 失效通知中表示全部虚拟机
+const AllVMs = 0
+
+// Invalidation 缓存失效事件
+type Invalidation struct {
+	VMID int       // 虚拟机 ID（AllVMs 表示全部虚拟机）
+	At   time.Time // 新采样的时间（零值表示不限时间，如清除数据）
+}
+
+// InvalidateFunc 缓存失效回调
+type InvalidateFunc func(ev Invalidation)
+
+// Service 流量统计服务（Monitor 和 API 服务器共用同一份缓存）
+// 相同虚拟机、周期和方向的并发统计只计算一次
+type Service struct {
+	storage   storage.Interface
+	cache     *cache.TrafficCache
+	flights   group
+	coalesced atomic.Uint64 // 被合并的调用次数
+
+	// 失效代数：每次失效递增，计算期间发生过失效的结果不写入缓存（计算可能读到失效前的数据）
+	genMu  sync.Mutex
+	allGen uint64
+	vmGens map[int]uint64
+
+	hooksMu sync.RWMutex
+	hooks   []InvalidateFunc
+}
+
+// NewService 创建流量统计服务（ttl 为统计缓存时间，0 使用默认值）
+func NewService(store storage.Interface, ttl time.Duration) *Service {
+	return &Service{
+		storage: store,
+		cache:   cache.NewTrafficCache(ttl),
+		vmGens:  make(map[int]uint64),
+	}
+}
+
+// Calculate 按周期计算流量统计（useCreationTime 时按创建时间划分周期，周期边界使用默认时区）
+// 当前周期的结果会被缓存，周期切换或失效后重新计算
+func (s *Service) Calculate(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
+	if !useCreationTime {
+		creationTime = time.Time{}
+	}
+
+	periodStart, cacheable := currentPeriodStart(period, creationTime, useCreationTime, time.Now().In(periodcalc.Location()))
+	if stats, ok := s.cached(vmid, period, direction, periodStart, cacheable); ok {
+		return stats, nil
+	}
+
+	gen := s.generation(vmid)
+	key := fmt.Sprintf("period:%d:%d:%s:%s:%d:%t", vmid, gen, period, direction, creationTime.Unix(), useCreationTime)
+	return s.do(key, func() (*models.TrafficStats, error) {
+		stats, err := s.storage.CalculateTrafficStatsWithDirection(vmid, period, creationTime, useCreationTime, direction)
+		if err == nil && cacheable {
+			s.setCached(gen, vmid, period, direction, periodStart, stats)
+		}
+		return stats, err
+	})
+}
+
+// CalculateFor 按周期计算器划分的当前周期计算流量统计（规则单独指定的时区、账单日等）
+// 缓存以周期开始时间区分，不同划分方式的结果互不干扰
+func (s *Service) CalculateFor(calc *periodcalc.Calculator, vmid int, direction string) (*models.TrafficStats, error) {
+	period := calc.Period()
+	now := time.Now()
+	periodStart := calc.PeriodStartAt(now)
+	cacheable := period == models.PeriodHour || period == models.PeriodDay || period == models.PeriodMonth
+	if stats, ok := s.cached(vmid, period, direction, periodStart, cacheable); ok {
+		return stats, nil
+	}
+
+	gen := s.generation(vmid)
+	key := fmt.Sprintf("calc:%d:%d:%s:%s:%d", vmid, gen, period, direction, periodStart.Unix())
+	return s.do(key, func() (*models.TrafficStats, error) {
+		stats, err := s.storage.CalculateTrafficStatsWithTimeRange(vmid, periodStart, now, direction)
+		if err != nil {
+			return nil, err
+		}
+		stats.Period = period
+		if cacheable {
+			s.setCached(gen, vmid, period, direction, periodStart, stats)
+		}
+		return stats, nil
+	})
+}
+
+// CalculateMetricFor 按统计对象计算当前周期的流量统计（network 等同于 CalculateFor）
+// 其他统计对象的结果不缓存（统计缓存不区分统计对象），相同的并发统计仍只计算一次
+func (s *Service) CalculateMetricFor(calc *periodcalc.Calculator, vmid int, direction, metric string) (*models.TrafficStats, error) {
+	if metric == "" || metric == models.MetricNetwork {
+		return s.CalculateFor(calc, vmid, direction)
+	}
+
+	period := calc.Period()
+	now := time.Now()
+	periodStart := calc.PeriodStartAt(now)
+	key := fmt.Sprintf("metric:%d:%d:%s:%s:%s:%d", vmid, s.generation(vmid), metric, period, direction, periodStart.Unix())
+	return s.do(key, func() (*models.TrafficStats, error) {
+		stats, err := storage.CalculateMetricStats(s.storage, vmid, periodStart, now, direction, metric)
+		if err != nil {
+			return nil, err
+		}
+		stats.Period = period
+		return stats, nil
+	})
+}
+
+// cached 读取当前周期的缓存结果
+func (s *Service) cached(vmid int, period, direction string, periodStart time.Time, cacheable bool) (*models.TrafficStats, bool) {
+	if !cacheable {
+		return nil, false
+	}
+	if stats, ok := s.cache.Get(vmid, period, direction, periodStart); ok {
+		utils.DebugLog("缓存命中: VM%d period=%s direction=%s", vmid, period, direction)
+		result := *stats
+		return &result, true
+	}
+	utils.DebugLog("缓存未命中: VM%d period=%s direction=%s", vmid, period, direction)
+	return nil, false
+}
+
+// generation 返回虚拟机当前的失效代数（全部失效和单个虚拟机失效的次数之和）
+// 失效代数也是合并计算的 key 的一部分：失效后发起的调用不会等待失效前开始的计算
+func (s *Service) generation(vmid int) uint64 {
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	return s.allGen + s.vmGens[vmid]
+}
+
+// setCached 计算开始后没有发生失效时写入缓存
+// 检查和写入在锁内进行，与 invalidate 的递增和清除互斥
+func (s *Service) setCached(gen uint64, vmid int, period, direction string, periodStart time.Time, stats *models.TrafficStats) {
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	if s.allGen+s.vmGens[vmid] != gen {
+		utils.DebugLog("计算期间缓存已失效，不缓存结果: VM%d period=%s direction=%s", vmid, period, direction)
+		return
+	}
+	s.cache.Set(vmid, period, direction, periodStart, stats)
+}
+
+// invalidate 递增失效代数并清除统计缓存（AllVMs 清除全部）
+func (s *Service) invalidate(vmid int) {
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	if vmid == AllVMs {
+		s.allGen++
+		s.cache.Clear()
+		return
+	}
+	s.vmGens[vmid]++
+	s.cache.Invalidate(vmid)
+}
+
+// CalculateRange 计算自定义时间范围内的流量统计（不缓存）
+func (s *Service) CalculateRange(vmid int, startTime, endTime time.Time, direction string) (*models.TrafficStats, error) {
+	key := fmt.Sprintf("range:%d:%s:%d:%d", vmid, direction, startTime.Unix(), endTime.Unix())
+	return s.do(key, func() (*models.TrafficStats, error) {
+		return s.storage.CalculateTrafficStatsWithTimeRange(vmid, startTime, endTime, direction)
+	})
+}
+
+// OnInvalidate 注册缓存失效回调（如 API 服务器的响应缓存）
+func (s *Service) OnInvalidate(fn InvalidateFunc) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.hooks = append(s.hooks, fn)
+}
+
+// SaveTrafficRecord 保存流量记录并发布失效事件
+// 包含该采样的统计和响应缓存在下一次查询时重新计算
+func (s *Service) SaveTrafficRecord(record models.TrafficRecord) error {
+	if err := s.storage.SaveTrafficRecord(record); err != nil {
+		return err
+	}
+	// 新采样总是落在各周期的当前周期内，该虚拟机的统计缓存全部失效
+	s.invalidate(record.VMID)
+	s.notify(Invalidation{VMID: record.VMID, At: record.Timestamp})
+	return nil
+}
+
+// SaveTrafficRecords 批量保存流量记录，每个虚拟机按最早的采样时间发布一次失效事件
+func (s *Service) SaveTrafficRecords(records []models.TrafficRecord) error {
+	if err := storage.SaveTrafficRecords(s.storage, records); err != nil {
+		return err
+	}
+	earliest := make(map[int]time.Time)
+	var vmids []int
+	for _, record := range records {
+		at, ok := earliest[record.VMID]
+		if !ok {
+			vmids = append(vmids, record.VMID)
+		}
+		if !ok || record.Timestamp.Before(at) {
+			earliest[record.VMID] = record.Timestamp
+		}
+	}
+	for _, vmid := range vmids {
+		s.invalidate(vmid)
+		s.notify(Invalidation{VMID: vmid, At: earliest[vmid]})
+	}
+	return nil
+}
+
+// Invalidate 使指定虚拟机的统计缓存失效并通知回调
+func (s *Service) Invalidate(vmid int) {
+	s.invalidate(vmid)
+	s.notify(Invalidation{VMID: vmid})
+}
+
+// InvalidateAll 清空统计缓存并通知回调（清除数据、重载缓存时使用）
+func (s *Service) InvalidateAll() {
+	s.Invalidate(AllVMs)
+}
+
+// CacheStats 返回统计缓存条目数和已过期条目数
+func (s *Service) CacheStats() (total, expired int) {
+	return s.cache.GetStats()
+}
+
+// Coalesced 返回被合并（未重复计算）的调用次数
+func (s *Service) Coalesced() uint64 {
+	return s.coalesced.Load()
+}
+
+// notify 调用所有失效回调
+func (s *Service) notify(ev Invalidation) {
+	s.hooksMu.RLock()
+	hooks := append([]InvalidateFunc(nil), s.hooks...)
+	s.hooksMu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(ev)
+	}
+}
+
+// do 合并相同 key 的并发计算，每个调用者得到独立的结果副本
+func (s *Service) do(key string, fn func() (*models.TrafficStats, error)) (*models.TrafficStats, error) {
+	val, err, shared := s.flights.do(key, func() (interface{}, error) {
+		return fn()
+	})
+	if shared {
+		s.coalesced.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats, _ := val.(*models.TrafficStats)
+	if stats == nil {
+		return nil, nil
+	}
+	result := *stats
+	return &result, nil
+}
+
+// currentPeriodStart 计算当前周期开始时间，用于检测周期切换
+// 分钟、滑动窗口等没有固定边界的周期不缓存
+func currentPeriodStart(period string, creationTime time.Time, useCreationTime bool, now time.Time) (time.Time, bool) {
+	switch period {
+	case models.PeriodHour, models.PeriodDay, models.PeriodMonth:
+		return periodcalc.NewCalculator(period, creationTime, useCreationTime).PeriodStartAt(now), true
+	default:
+		return time.Time{}, false
+	}
+}
@@ -0,0 +1,220 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/storage"
+)
+
+func TestGroupCoalescesConcurrentCalls(t *testing.T) {
+	var g group
+	var executions atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]interface{}, callers)
+	sharedCount := atomic.Int32{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		val, _, shared := g.do("vm100", func() (interface{}, error) {
+			executions.Add(1)
+			close(started)
+			<-release
+			return 42, nil
+		})
+		results[0] = val
+		if shared {
+			sharedCount.Add(1)
+		}
+	}()
+	<-started
+
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, _, shared := g.do("vm100", func() (interface{}, error) {
+				executions.Add(1)
+				return -1, nil
+			})
+			results[i] = val
+			if shared {
+				sharedCount.Add(1)
+			}
+		}(i)
+	}
+
+	// 等待其余调用者进入等待状态
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := executions.Load(); got != 1 {
+		t.Fatalf("executions = %d, want 1", got)
+	}
+	if got := sharedCount.Load(); got != callers-1 {
+		t.Fatalf("shared = %d, want %d", got, callers-1)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Fatalf("results[%d] = %v, want 42", i, v)
+		}
+	}
+
+	// 调用结束后同一 key 会重新计算
+	val, _, shared := g.do("vm100", func() (interface{}, error) { return 7, nil })
+	if val != 7 || shared {
+		t.Fatalf("second call = %v (shared %v), want 7 (not shared)", val, shared)
+	}
+}
+
+// countingStorage 统计计算次数的存储（其余方法不使用）
+type countingStorage struct {
+	storage.Interface
+	calls atomic.Int32
+}
+
+func (c *countingStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	return nil
+}
+
+func (c *countingStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
+	c.calls.Add(1)
+	return &models.TrafficStats{VMID: vmid, Period: period, Direction: direction, TotalBytes: 100}, nil
+}
+
+func TestServiceCacheAndInvalidate(t *testing.T) {
+	store := &countingStorage{}
+	svc := NewService(store, time.Minute)
+
+	var invalidated []Invalidation
+	svc.OnInvalidate(func(ev Invalidation) { invalidated = append(invalidated, ev) })
+
+	for i := 0; i < 3; i++ {
+		stats, err := svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+		if err != nil || stats.TotalBytes != 100 {
+			t.Fatalf("Calculate = %+v, %v", stats, err)
+		}
+		// 调用者修改结果不影响缓存
+		stats.TotalBytes = 0
+	}
+	if got := store.calls.Load(); got != 1 {
+		t.Fatalf("storage calls = %d, want 1", got)
+	}
+
+	// 分钟周期不缓存
+	svc.Calculate(100, models.PeriodMinute, time.Time{}, false, models.DirectionBoth)
+	svc.Calculate(100, models.PeriodMinute, time.Time{}, false, models.DirectionBoth)
+	if got := store.calls.Load(); got != 3 {
+		t.Fatalf("storage calls after minute = %d, want 3", got)
+	}
+
+	// 其他虚拟机的新采样不影响缓存
+	sampleAt := time.Now()
+	svc.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: sampleAt})
+	svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+	if got := store.calls.Load(); got != 3 {
+		t.Fatalf("storage calls after other sample = %d, want 3", got)
+	}
+
+	svc.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: sampleAt})
+	svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+	if got := store.calls.Load(); got != 4 {
+		t.Fatalf("storage calls after new sample = %d, want 4", got)
+	}
+
+	svc.InvalidateAll()
+	want := []Invalidation{{VMID: 101, At: sampleAt}, {VMID: 100, At: sampleAt}, {VMID: AllVMs}}
+	if len(invalidated) != len(want) {
+		t.Fatalf("invalidated = %v, want %v", invalidated, want)
+	}
+	for i := range want {
+		if invalidated[i].VMID != want[i].VMID || !invalidated[i].At.Equal(want[i].At) {
+			t.Fatalf("invalidated[%d] = %+v, want %+v", i, invalidated[i], want[i])
+		}
+	}
+}
+
+func TestGroupPanicReachesWaitersAsError(t *testing.T) {
+	var g group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	recovered := make(chan interface{}, 1)
+	go func() {
+		defer func() { recovered <- recover() }()
+		g.do("vm100", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waited := make(chan error, 1)
+	go func() {
+		val, err, shared := g.do("vm100", func() (interface{}, error) { return 1, nil })
+		if val != nil || !shared {
+			err = nil
+		}
+		waited <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if r := <-recovered; r != "boom" {
+		t.Fatalf("owner recovered %v, want the panic re-raised", r)
+	}
+	if err := <-waited; err == nil {
+		t.Fatal("waiter got no error from a panicking flight")
+	}
+}
+
+// blockingStorage 计算期间阻塞，直到 release 关闭
+type blockingStorage struct {
+	countingStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
+	if b.calls.Load() == 0 {
+		close(b.started)
+		<-b.release
+	}
+	return b.countingStorage.CalculateTrafficStatsWithDirection(vmid, period, creationTime, useCreationTime, direction)
+}
+
+func TestServiceDropsResultInvalidatedDuringCalculation(t *testing.T) {
+	store := &blockingStorage{started: make(chan struct{}), release: make(chan struct{})}
+	svc := NewService(store, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+	}()
+	<-store.started
+
+	// 计算读取存储之后写入了新采样
+	svc.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: time.Now()})
+	close(store.release)
+	<-done
+
+	svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+	if got := store.calls.Load(); got != 2 {
+		t.Fatalf("storage calls = %d, want the stale result not cached", got)
+	}
+	svc.Calculate(100, models.PeriodDay, time.Time{}, false, models.DirectionBoth)
+	if got := store.calls.Load(); got != 2 {
+		t.Fatalf("storage calls = %d, want the fresh result cached", got)
+	}
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+)
+
+// call 正在进行中的一次计算
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// group 合并相同 key 的并发调用：同一时刻只执行一次，其余调用者等待并共享结果
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do 执行 fn，如果相同 key 的调用正在进行则等待其结果
+// shared 表示结果来自其他调用者发起的计算
+func (g *group) do(key string, fn func() (interface{}, error)) (val interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// 即使 fn panic 也要唤醒等待者并移除记录；等待者得到错误，panic 继续在发起计算的调用者中传播
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.val, c.err = nil, fmt.Errorf("统计计算 panic: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// atomicTempSuffix 原子写入临时文件的后缀（启动检查时清理残留的临时文件）
+const atomicTempSuffix = ".tmp"
+
+// writeFileAtomic 先写入同目录的临时文件并同步到磁盘，再重命名替换目标文件
+// 写入过程中崩溃时目标文件保持原内容，不会只写了一半
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	f, err := os.CreateTemp(dir, filepath.Base(filename)+".*"+atomicTempSuffix)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("同步临时文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("替换文件失败: %w", err)
+	}
+
+	// 同步目录，确保重命名本身已写入磁盘
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// defaultBoundarySplitLimit 未设置时按比例拆分的最大采样间隔
+const defaultBoundarySplitLimit = 10 * time.Minute
+
+// boundarySplitLimit 按比例拆分跨周期边界增量的最大采样间隔（采集中断阈值，见 SetBoundarySplitLimit）
+var boundarySplitLimit atomic.Int64
+
+// SetBoundarySplitLimit 设置按比例拆分跨边界增量的最大采样间隔（0 使用默认值），加载和重载配置时调用
+// 相邻采样间隔不超过该值时，增量按时间比例分到边界两侧；超过时视为采集中断，整段增量计入后一次采样所在的时间段
+func SetBoundarySplitLimit(d time.Duration) {
+	boundarySplitLimit.Store(int64(d))
+}
+
+// excludeBackups 统计用量时是否去掉备份期间的增量（见 SetExcludeBackups）
+var excludeBackups atomic.Bool
+
+// SetExcludeBackups 设置统计用量时是否去掉备份期间的增量（monitor.backup_traffic 为 exclude），加载和重载配置时调用
+func SetExcludeBackups(exclude bool) {
+	excludeBackups.Store(exclude)
+}
+
+// ExcludingBackups 统计用量时是否去掉备份期间的增量
+func ExcludingBackups() bool {
+	return excludeBackups.Load()
+}
+
+// splitLimit 当前的最大拆分间隔
+func splitLimit() time.Duration {
+	if d := time.Duration(boundarySplitLimit.Load()); d > 0 {
+		return d
+	}
+	return defaultBoundarySplitLimit
+}
+
+// splittable 两次采样之间的增量是否可以按时间比例拆分
+func splittable(prev, next time.Time) bool {
+	gap := next.Sub(prev)
+	return gap > 0 && gap <= splitLimit()
+}
+
+// counterAt 按时间比例估算 prev 和 next 之间某一时刻的计数器值（frac 为已经过的比例）
+// 计数器变小视为重启：重启后的增量为 next，估算值为 next 的对应比例
+func counterAt(prev, next uint64, frac float64) uint64 {
+	if next < prev {
+		return uint64(float64(next) * frac)
+	}
+	return prev + uint64(float64(next-prev)*frac)
+}
+
+// interpolateRecord 估算 t 时刻（prev 和 next 之间）的累计流量记录
+func interpolateRecord(prev, next models.TrafficRecord, t time.Time) models.TrafficRecord {
+	frac := float64(t.Sub(prev.Timestamp)) / float64(next.Timestamp.Sub(prev.Timestamp))
+	record := models.TrafficRecord{
+		VMID:      next.VMID,
+		Timestamp: t,
+		RXBytes:   counterAt(prev.RXBytes, next.RXBytes, frac),
+		TXBytes:   counterAt(prev.TXBytes, next.TXBytes, frac),
+		DiskRead:  counterAt(prev.DiskRead, next.DiskRead, frac),
+		DiskWrite: counterAt(prev.DiskWrite, next.DiskWrite, frac),
+		IPv4RX:    counterAt(prev.IPv4RX, next.IPv4RX, frac),
+		IPv4TX:    counterAt(prev.IPv4TX, next.IPv4TX, frac),
+		IPv6RX:    counterAt(prev.IPv6RX, next.IPv6RX, frac),
+		IPv6TX:    counterAt(prev.IPv6TX, next.IPv6TX, frac),
+	}
+	record.TotalBytes = record.RXBytes + record.TXBytes
+	return record
+}
+
+// clipRecords 将按时间升序、包含范围前后相邻采样的记录裁剪到 [start, end]
+// 范围外的相邻采样与范围内第一条（最后一条）采样的间隔可拆分时，在 start（end）处插入按比例估算的记录，
+// 使跨越边界的那次增量只有落在范围内的部分计入统计
+func clipRecords(records []models.TrafficRecord, start, end time.Time) []models.TrafficRecord {
+	first := 0
+	for first < len(records) && records[first].Timestamp.Before(start) {
+		first++
+	}
+	last := len(records) - 1
+	for last >= 0 && records[last].Timestamp.After(end) {
+		last--
+	}
+
+	clipped := make([]models.TrafficRecord, 0, last-first+3)
+	if first > 0 && first < len(records) && records[first].Timestamp.After(start) &&
+		splittable(records[first-1].Timestamp, records[first].Timestamp) {
+		clipped = append(clipped, interpolateRecord(records[first-1], records[first], start))
+	}
+	if first <= last {
+		clipped = append(clipped, records[first:last+1]...)
+	}
+	if last >= 0 && last < len(records)-1 && records[last].Timestamp.Before(end) &&
+		splittable(records[last].Timestamp, records[last+1].Timestamp) {
+		clipped = append(clipped, interpolateRecord(records[last], records[last+1], end))
+	}
+	return clipped
+}
+
+// rangeRecords 获取 [start, end] 内用于统计的流量记录（连同范围前后的相邻采样一起读取，再按比例裁剪到边界）
+// 设置了 SetExcludeBackups 时先去掉备份期间的增量
+func rangeRecords(get func(vmid int, startTime, endTime time.Time) ([]models.TrafficRecord, error), vmid int, start, end time.Time) ([]models.TrafficRecord, error) {
+	margin := splitLimit()
+	records, err := get(vmid, start.Add(-margin), end.Add(margin))
+	if err != nil {
+		return nil, err
+	}
+	if excludeBackups.Load() {
+		records = WithoutBackupTraffic(records)
+	}
+	return clipRecords(records, start, end), nil
+}
+
+// splitDelta 将 prev 到 next 之间的增量按时间比例分到各时间段（bucketEnd 返回 t 所在时间段的终点），每段调用一次 fn
+// 间隔不可拆分时整段增量计入 next 所在的时间段
+func splitDelta(prev, next time.Time, deltaRX, deltaTX uint64, bucketEnd func(t time.Time) time.Time, fn func(t time.Time, deltaRX, deltaTX uint64)) {
+	if !splittable(prev, next) {
+		fn(next, deltaRX, deltaTX)
+		return
+	}
+
+	// 按累计比例计算每段的份额，保证各段之和等于总增量
+	span := float64(next.Sub(prev))
+	var doneRX, doneTX uint64
+	for cur := prev; ; {
+		boundary := bucketEnd(cur)
+		if !boundary.Before(next) {
+			fn(cur, deltaRX-doneRX, deltaTX-doneTX)
+			return
+		}
+		frac := float64(boundary.Sub(prev)) / span
+		rx, tx := uint64(float64(deltaRX)*frac), uint64(float64(deltaTX)*frac)
+		fn(cur, rx-doneRX, tx-doneTX)
+		doneRX, doneTX = rx, tx
+		cur = boundary
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+)
+
+// 空桶填充方式
+const (
+	FillNone = ""     // 不返回没有采样的时间段
+	FillZero = "zero" // 返回流量为 0 的数据点
+	FillNull = "null" // 返回标记为 Gap 的数据点（API 输出 null，图表断开）
+)
+
+// StepAuto 由点数上限自动选择步长
+const StepAuto = "auto"
+
+// BucketSteps 自动选择或放大步长时使用的候选步长（超过最大候选时按整天放大）
+var BucketSteps = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// BucketQuery 按步长分桶聚合的参数
+type BucketQuery struct {
+	Start     time.Time     // 范围起点（为零时使用第一条记录的时间）
+	End       time.Time     // 范围终点（为零时使用最后一条记录的时间）
+	Step      time.Duration // 桶宽度，为 0 时由 MaxPoints 自动选择
+	Fill      string        // 空桶填充方式（FillNone/FillZero/FillNull）
+	MaxPoints int           // 点数上限，超过时自动放大步长（0 表示不限制；自动步长时使用默认上限）
+}
+
+// ParseBucketStep 解析步长（如 5m、15m、6h、1d，或 auto 返回 0），至少 1 分钟且为整分钟
+func ParseBucketStep(spec string) (time.Duration, bool) {
+	if spec == StepAuto {
+		return 0, true
+	}
+	if len(spec) < 2 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(spec[:len(spec)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	var step time.Duration
+	switch spec[len(spec)-1] {
+	case 'm':
+		step = time.Duration(n) * time.Minute
+	case 'h':
+		step = time.Duration(n) * time.Hour
+	case 'd':
+		step = time.Duration(n) * 24 * time.Hour
+	default:
+		return 0, false
+	}
+	// 超过一年的步长没有意义，同时避免溢出
+	if step > 366*24*time.Hour {
+		return 0, false
+	}
+	return step, true
+}
+
+// FormatBucketStep 将步长格式化为 ParseBucketStep 接受的形式
+func FormatBucketStep(step time.Duration) string {
+	switch {
+	case step%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", step/(24*time.Hour))
+	case step%time.Hour == 0:
+		return fmt.Sprintf("%dh", step/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", step/time.Minute)
+	}
+}
+
+// bucketClock 按配置时区对齐的桶编号（整个查询使用范围起点的时区偏移，桶宽度一致）
+type bucketClock struct {
+	size   int64 // 桶宽度（秒）
+	offset int64 // 时区偏移（秒）
+	loc    *time.Location
+}
+
+func newBucketClock(start time.Time, step time.Duration) bucketClock {
+	loc := periodcalc.Location()
+	_, offset := start.In(loc).Zone()
+	return bucketClock{size: int64(step / time.Second), offset: int64(offset), loc: loc}
+}
+
+// index 时间所在桶的编号
+func (c bucketClock) index(t time.Time) int64 {
+	sec := t.Unix() + c.offset
+	k := sec / c.size
+	if sec%c.size < 0 {
+		k--
+	}
+	return k
+}
+
+// start 桶的起始时间
+func (c bucketClock) start(k int64) time.Time {
+	return time.Unix(k*c.size-c.offset, 0).In(c.loc)
+}
+
+// count 范围内的桶数
+func (c bucketClock) count(start, end time.Time) int64 {
+	return c.index(end) - c.index(start) + 1
+}
+
+// EffectiveStep 实际使用的步长：自动步长选择点数不超过上限的最小候选；指定步长超过上限时放大到满足上限的候选
+// 需要设置 Start 和 End
+func (q BucketQuery) EffectiveStep() time.Duration {
+	maxPoints := q.MaxPoints
+	if q.Step == 0 && maxPoints <= 0 {
+		maxPoints = models.DefaultHistoryMaxPoints
+	}
+	if q.Step > 0 && (maxPoints <= 0 || newBucketClock(q.Start, q.Step).count(q.Start, q.End) <= int64(maxPoints)) {
+		return q.Step
+	}
+
+	for _, step := range BucketSteps {
+		if step < q.Step {
+			continue
+		}
+		if newBucketClock(q.Start, step).count(q.Start, q.End) <= int64(maxPoints) {
+			return step
+		}
+	}
+	// 超过最大候选时按整天放大
+	day := 24 * time.Hour
+	days := max(q.End.Sub(q.Start)/day/time.Duration(maxPoints)+1, (q.Step+day-1)/day)
+	for newBucketClock(q.Start, days*day).count(q.Start, q.End) > int64(maxPoints) {
+		days++
+	}
+	return days * day
+}
+
+// AggregateTrafficByStep 按任意步长聚合流量数据（与 AggregateTrafficByPeriod 相同的增量计算），返回数据点和实际步长
+// 桶按配置时区的整点对齐（如 15m 桶从 :00/:15/:30/:45 开始，1d 桶从 0 点开始）
+func AggregateTrafficByStep(records []models.TrafficRecord, q BucketQuery) ([]AggregatedPoint, time.Duration) {
+	if len(records) > 0 {
+		if q.Start.IsZero() {
+			q.Start = records[0].Timestamp
+		}
+		if q.End.IsZero() {
+			q.End = records[len(records)-1].Timestamp
+		}
+	}
+	if q.Start.IsZero() || q.End.Before(q.Start) {
+		return []AggregatedPoint{}, q.Step
+	}
+
+	step := q.EffectiveStep()
+	clock := newBucketClock(q.Start, step)
+
+	groups := make(map[int64]*AggregatedPoint)
+	bucketEnd := func(t time.Time) time.Time {
+		return clock.start(clock.index(t) + 1)
+	}
+	eachBucketDelta(records, bucketEnd, func(t time.Time, deltaRX, deltaTX uint64) {
+		k := clock.index(t)
+		point := groups[k]
+		if point == nil {
+			point = &AggregatedPoint{Timestamp: clock.start(k)}
+			groups[k] = point
+		}
+		point.RXBytes += deltaRX
+		point.TXBytes += deltaTX
+		point.TotalBytes += deltaRX + deltaTX
+	})
+
+	if q.Fill == FillNone {
+		result := make([]AggregatedPoint, 0, len(groups))
+		for _, point := range groups {
+			result = append(result, *point)
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Timestamp.Before(result[j].Timestamp)
+		})
+		return result, step
+	}
+
+	// 填充空桶（范围外的采样不输出）
+	first, last := clock.index(q.Start), clock.index(q.End)
+	result := make([]AggregatedPoint, 0, last-first+1)
+	for k := first; k <= last; k++ {
+		if point, ok := groups[k]; ok {
+			result = append(result, *point)
+		} else {
+			result = append(result, AggregatedPoint{Timestamp: clock.start(k), Gap: true})
+		}
+	}
+	return result, step
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+)
+
+func TestAggregateTrafficByStep(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	periodcalc.SetLocation(shanghai)
+	defer periodcalc.SetLocation(nil)
+
+	// 每分钟一条，10:00-10:59，10:20-10:40 之间没有采样
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, shanghai)
+	var records []models.TrafficRecord
+	for i := 0; i < 60; i++ {
+		if i > 20 && i < 40 {
+			continue
+		}
+		records = append(records, models.TrafficRecord{VMID: 101, Timestamp: start.Add(time.Duration(i) * time.Minute), RXBytes: uint64(i) * 100, TXBytes: uint64(i) * 10})
+	}
+
+	points, step := AggregateTrafficByStep(records, BucketQuery{Start: start, End: start.Add(59 * time.Minute), Step: 15 * time.Minute, Fill: FillNull})
+	if step != 15*time.Minute || len(points) != 4 {
+		t.Fatalf("points = %+v, step %v, want 4 buckets of 15m", points, step)
+	}
+	if got := points[1].Timestamp; !got.Equal(start.Add(15*time.Minute)) || got.Format("15:04") != "10:15" {
+		t.Fatalf("bucket 1 starts at %v, want 10:15 local", got)
+	}
+	// 10:14-10:15 的增量在 10:15 之前，计入 10:00 的桶；
+	// 10:20-10:40 超过拆分间隔（采集中断），整段增量随 10:40 的采样计入 10:30 的桶
+	if points[0].RXBytes != 1500 || points[1].RXBytes != 500 || points[2].Gap != false || points[2].RXBytes != 2500 || points[3].RXBytes != 1400 {
+		t.Fatalf("points = %+v", points)
+	}
+
+	// 空桶填充：10:20-10:40 的增量整段计入 10:40，10:20-10:35 的桶为空
+	points, _ = AggregateTrafficByStep(records, BucketQuery{Start: start, End: start.Add(59 * time.Minute), Step: 5 * time.Minute, Fill: FillZero})
+	if len(points) != 12 || !points[4].Gap || !points[5].Gap || points[5].TotalBytes != 0 || points[3].Gap {
+		t.Fatalf("5m points = %+v, want 12 with 10:25 filled", points)
+	}
+	points, _ = AggregateTrafficByStep(records, BucketQuery{Start: start, End: start.Add(59 * time.Minute), Step: 5 * time.Minute})
+	if len(points) != 8 {
+		t.Fatalf("unfilled points = %d, want 8", len(points))
+	}
+
+	// 点数上限：指定步长和自动步长都不超过上限
+	if _, step := AggregateTrafficByStep(records, BucketQuery{Start: start, End: start.Add(59 * time.Minute), Step: time.Minute, MaxPoints: 10}); step != 15*time.Minute {
+		t.Fatalf("step with max 10 points = %v, want 15m", step)
+	}
+	month := BucketQuery{Start: start, End: start.AddDate(0, 1, 0), MaxPoints: 300}
+	if step := month.EffectiveStep(); step != 3*time.Hour {
+		t.Fatalf("auto step for a month = %v, want 3h", step)
+	}
+	decade := BucketQuery{Start: start, End: start.AddDate(10, 0, 0), MaxPoints: 300}
+	if step := decade.EffectiveStep(); step != 13*24*time.Hour {
+		t.Fatalf("auto step for ten years = %v, want 13d", step)
+	}
+}
+
+func TestParseBucketStep(t *testing.T) {
+	for spec, want := range map[string]time.Duration{"5m": 5 * time.Minute, "6h": 6 * time.Hour, "1d": 24 * time.Hour, "auto": 0} {
+		if got, ok := ParseBucketStep(spec); !ok || got != want {
+			t.Errorf("ParseBucketStep(%q) = %v, %v, want %v", spec, got, ok, want)
+		}
+		if want > 0 && FormatBucketStep(want) != spec {
+			t.Errorf("FormatBucketStep(%v) = %q, want %q", want, FormatBucketStep(want), spec)
+		}
+	}
+	for _, spec := range []string{"", "m", "30s", "0m", "-5m", "1w", "400d"} {
+		if _, ok := ParseBucketStep(spec); ok {
+			t.Errorf("ParseBucketStep(%q) ok, want invalid", spec)
+		}
+	}
+}
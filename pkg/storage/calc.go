@@ -2,6 +2,7 @@ package storage
 
 import (
 	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/utils"
 	"sort"
 	"time"
@@ -155,6 +156,144 @@ func calculateTraffic(vmid int, records []models.TrafficRecord) (totalRXBytes, t
 	return totalRX, totalTX
 }
 
+// DiskRecords 将流量记录转换为磁盘读写记录（RX=读取，TX=写入）
+// 转换后可直接复用流量的统计、聚合和平均速率计算（同样正确处理虚拟机重启）
+func DiskRecords(records []models.TrafficRecord) []models.TrafficRecord {
+	disk := make([]models.TrafficRecord, len(records))
+	for i, record := range records {
+		disk[i] = models.TrafficRecord{
+			VMID:       record.VMID,
+			Timestamp:  record.Timestamp,
+			RXBytes:    record.DiskRead,
+			TXBytes:    record.DiskWrite,
+			TotalBytes: record.DiskRead + record.DiskWrite,
+		}
+	}
+	return disk
+}
+
+// ProtocolRecords 将流量记录转换为客户机报告的 IPv4 或 IPv6 收发记录（protocol 为 ipv4 或 ipv6）
+// 没有按协议计数的记录（未启用 ip_split 或客户机代理不可用时的采样）被跳过，避免计数回到 0 被当作虚拟机重启
+func ProtocolRecords(records []models.TrafficRecord, protocol string) []models.TrafficRecord {
+	converted := make([]models.TrafficRecord, 0, len(records))
+	for _, record := range records {
+		if record.IPv4RX == 0 && record.IPv4TX == 0 && record.IPv6RX == 0 && record.IPv6TX == 0 {
+			continue
+		}
+		rx, tx := record.IPv4RX, record.IPv4TX
+		if protocol == models.MetricIPv6 {
+			rx, tx = record.IPv6RX, record.IPv6TX
+		}
+		converted = append(converted, models.TrafficRecord{
+			VMID:       record.VMID,
+			Timestamp:  record.Timestamp,
+			RXBytes:    rx,
+			TXBytes:    tx,
+			TotalBytes: rx + tx,
+		})
+	}
+	return converted
+}
+
+// MetricRecords 按统计对象转换流量记录（network 原样返回，disk 见 DiskRecords，ipv4/ipv6 见 ProtocolRecords）
+func MetricRecords(records []models.TrafficRecord, metric string) []models.TrafficRecord {
+	switch metric {
+	case models.MetricDisk:
+		return DiskRecords(records)
+	case models.MetricIPv4, models.MetricIPv6:
+		return ProtocolRecords(records, metric)
+	default:
+		return records
+	}
+}
+
+// CalculateMetricStats 按统计对象计算 [start, end] 内的流量统计（先转换记录再按比例裁剪到边界）
+func CalculateMetricStats(s Interface, vmid int, start, end time.Time, direction, metric string) (*models.TrafficStats, error) {
+	margin := splitLimit()
+	records, err := s.GetTrafficRecords(vmid, start.Add(-margin), end.Add(margin))
+	if err != nil {
+		return nil, err
+	}
+	if excludeBackups.Load() && (metric == "" || metric == models.MetricNetwork) {
+		records = WithoutBackupTraffic(records)
+	}
+	records = clipRecords(MetricRecords(records, metric), start, end)
+	return buildTrafficStats(vmid, "custom", start, end, direction, records), nil
+}
+
+// WithoutBackupTraffic 去掉备份期间的网卡流量：按相邻采样的增量重新累计计数（同样正确处理虚拟机重启），
+// 结束于 Backup 标记记录的采样区间增量记为 0；没有标记记录时原样返回
+func WithoutBackupTraffic(records []models.TrafficRecord) []models.TrafficRecord {
+	marked := false
+	for _, record := range records {
+		if record.Backup {
+			marked = true
+			break
+		}
+	}
+	if !marked {
+		return records
+	}
+
+	converted := make([]models.TrafficRecord, len(records))
+	copy(converted, records)
+	converted[0].RXBytes, converted[0].TXBytes, converted[0].TotalBytes = 0, 0, 0
+	var rx, tx uint64
+	i := 1
+	eachTrafficDelta(records, func(prev, record models.TrafficRecord, deltaRX, deltaTX uint64) {
+		if !record.Backup {
+			rx += deltaRX
+			tx += deltaTX
+		}
+		converted[i].RXBytes, converted[i].TXBytes, converted[i].TotalBytes = rx, tx, rx+tx
+		i++
+	})
+	return converted
+}
+
+// CalculateUploadRatio 计算记录区间内的平均上传、下载带宽（bit/s）和上传与下载之比（下载为 0 时为 models.MaxUploadRatio）
+func CalculateUploadRatio(vmid int, records []models.TrafficRecord) (upload, download, ratio float64, span time.Duration) {
+	upload, span = CalculateAverageRate(vmid, records, models.DirectionTX)
+	download, _ = CalculateAverageRate(vmid, records, models.DirectionRX)
+	switch {
+	case upload == 0:
+		ratio = 0
+	case download == 0:
+		ratio = models.MaxUploadRatio
+	default:
+		ratio = min(upload/download, models.MaxUploadRatio)
+	}
+	return upload, download, ratio, span
+}
+
+// CalculateAverageRate 计算记录区间内的平均带宽（bit/s）
+// 增量计算复用 calculateTraffic，正确处理VM重启；
+// 返回值 span 为首尾记录之间的实际时间跨度，调用方可据此判断窗口是否被充分覆盖
+func CalculateAverageRate(vmid int, records []models.TrafficRecord, direction string) (bitsPerSecond float64, span time.Duration) {
+	if len(records) < 2 {
+		return 0, 0
+	}
+
+	span = records[len(records)-1].Timestamp.Sub(records[0].Timestamp)
+	if span <= 0 {
+		return 0, 0
+	}
+
+	rxBytes, txBytes := calculateTraffic(vmid, records)
+
+	var bytes uint64
+	switch direction {
+	case models.DirectionUpload, models.DirectionTX:
+		bytes = txBytes
+	case models.DirectionDownload, models.DirectionRX:
+		bytes = rxBytes
+	default: // "both"
+		bytes = rxBytes + txBytes
+	}
+
+	return float64(bytes) * 8 / span.Seconds(), span
+}
+
 // CalculateTrafficExample 计算示例说明
 /*
 示例1: 无重启的情况
@@ -218,6 +357,31 @@ type AggregatedPoint struct {
 	RXBytes    uint64
 	TXBytes    uint64
 	TotalBytes uint64
+	Gap        bool `json:",omitempty"` // 填充的空桶（该时间段没有采样，按步长聚合并要求填充时出现）
+}
+
+// eachTrafficDelta 按顺序计算相邻采样的增量（计数器变小视为重启，增量为当前值），每个采样点调用一次 fn（第一条除外）
+func eachTrafficDelta(records []models.TrafficRecord, fn func(prev, record models.TrafficRecord, deltaRX, deltaTX uint64)) {
+	for i := 1; i < len(records); i++ {
+		prev, record := records[i-1], records[i]
+
+		deltaRX := record.RXBytes // 重启
+		if record.RXBytes >= prev.RXBytes {
+			deltaRX = record.RXBytes - prev.RXBytes
+		}
+		deltaTX := record.TXBytes
+		if record.TXBytes >= prev.TXBytes {
+			deltaTX = record.TXBytes - prev.TXBytes
+		}
+		fn(prev, record, deltaRX, deltaTX)
+	}
+}
+
+// eachBucketDelta 计算相邻采样的增量并按时间比例分到各时间段（见 splitDelta），t 为增量所在时间段内的时间
+func eachBucketDelta(records []models.TrafficRecord, bucketEnd func(t time.Time) time.Time, fn func(t time.Time, deltaRX, deltaTX uint64)) {
+	eachTrafficDelta(records, func(prev, record models.TrafficRecord, deltaRX, deltaTX uint64) {
+		splitDelta(prev.Timestamp, record.Timestamp, deltaRX, deltaTX, bucketEnd, fn)
+	})
 }
 
 // AggregateTrafficByPeriod 按时间段聚合流量数据（通用函数，API和图表都可使用）
@@ -234,8 +398,10 @@ func AggregateTrafficByPeriod(records []models.TrafficRecord, period string) []A
 	}
 	groups := make(map[string]*GroupData)
 
-	// 获取时间段的key
+	// 获取时间段的key（按配置的时区分组）
+	loc := periodcalc.Location()
 	getKey := func(t time.Time) string {
+		t = t.In(loc)
 		switch period {
 		case models.PeriodMinute:
 			return t.Format(models.TimeFormatMinute)
@@ -250,41 +416,19 @@ func AggregateTrafficByPeriod(records []models.TrafficRecord, period string) []A
 		}
 	}
 
-	// 计算每个采集点的增量，然后聚合到时间段
-	var prevRX, prevTX uint64
-
-	for i, record := range records {
-		if i == 0 {
-			prevRX = record.RXBytes
-			prevTX = record.TXBytes
-			continue
-		}
-
-		// 检测重启并计算增量
-		var deltaRX, deltaTX uint64
-		if record.RXBytes < prevRX {
-			deltaRX = record.RXBytes // 重启
-		} else {
-			deltaRX = record.RXBytes - prevRX
-		}
-		if record.TXBytes < prevTX {
-			deltaTX = record.TXBytes // 重启
-		} else {
-			deltaTX = record.TXBytes - prevTX
-		}
-
-		// 聚合到对应的时间段
-		key := getKey(record.Timestamp)
+	// 计算每个采集点的增量，跨越时间段边界的增量按时间比例拆分，然后聚合到时间段
+	bucketEnd := func(t time.Time) time.Time {
+		return nextPeriodBucket(period, PeriodBucketStart(period, t))
+	}
+	eachBucketDelta(records, bucketEnd, func(t time.Time, deltaRX, deltaTX uint64) {
+		key := getKey(t)
 		if groups[key] == nil {
 			groups[key] = &GroupData{}
 		}
 		groups[key].RXBytes += deltaRX
 		groups[key].TXBytes += deltaTX
 		groups[key].Count++
-
-		prevRX = record.RXBytes
-		prevTX = record.TXBytes
-	}
+	})
 
 	// 转换为数组
 	var result []AggregatedPoint
@@ -302,7 +446,7 @@ func AggregateTrafficByPeriod(records []models.TrafficRecord, period string) []A
 			format = models.TimeFormatDay
 		}
 
-		timestamp, err := time.ParseInLocation(format, timeStr, time.Local)
+		timestamp, err := time.ParseInLocation(format, timeStr, loc)
 		if err != nil {
 			// 解析失败，跳过这个数据点
 			utils.DebugLog("[聚合] 时间解析失败: %s, 格式: %s", timeStr, format)
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCalculateAverageRate(t *testing.T) {
+	baseTime := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: 0, TXBytes: 0},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 6_000_000, TXBytes: 1_500_000},
+		{VMID: 101, Timestamp: baseTime.Add(2 * time.Minute), RXBytes: 12_000_000, TXBytes: 3_000_000},
+	}
+
+	tests := []struct {
+		name      string
+		direction string
+		want      float64
+	}{
+		{name: "both", direction: models.DirectionBoth, want: 1_000_000},
+		{name: "download", direction: models.DirectionDownload, want: 800_000},
+		{name: "upload", direction: models.DirectionTX, want: 200_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, span := CalculateAverageRate(101, records, tt.direction)
+			if span != 2*time.Minute {
+				t.Fatalf("span = %s, want 2m", span)
+			}
+			if got != tt.want {
+				t.Fatalf("rate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateAverageRateHandlesRestart(t *testing.T) {
+	baseTime := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: 9_000_000},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 12_000_000},
+		{VMID: 101, Timestamp: baseTime.Add(2 * time.Minute), RXBytes: 3_000_000},
+	}
+
+	got, _ := CalculateAverageRate(101, records, models.DirectionRX)
+	if want := float64(6_000_000) * 8 / 120; got != want {
+		t.Fatalf("rate = %v, want %v", got, want)
+	}
+
+	if got, span := CalculateAverageRate(101, records[:1], models.DirectionBoth); got != 0 || span != 0 {
+		t.Fatalf("single record rate = %v span = %s, want 0", got, span)
+	}
+}
+
+func TestCalculateMetricStatsSkipsRecordsWithoutProtocolCounters(t *testing.T) {
+	store, err := NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: 1000, TXBytes: 1000, IPv4RX: 100, IPv4TX: 50},
+		// 客户机代理暂时不可用，没有按协议的计数，不能当作计数清零
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 2000, TXBytes: 1500},
+		{VMID: 101, Timestamp: baseTime.Add(2 * time.Minute), RXBytes: 3000, TXBytes: 2000, IPv4RX: 700, IPv4TX: 250, IPv6RX: 40},
+	}
+	if err := SaveTrafficRecords(store, records); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	stats, err := CalculateMetricStats(store, 101, baseTime, baseTime.Add(2*time.Minute), models.DirectionBoth, models.MetricIPv4)
+	if err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+	if stats.RXBytes != 600 || stats.TXBytes != 200 {
+		t.Fatalf("ipv4 stats = rx:%d tx:%d, want rx:600 tx:200", stats.RXBytes, stats.TXBytes)
+	}
+
+	stats, err = CalculateMetricStats(store, 101, baseTime, baseTime.Add(2*time.Minute), models.DirectionBoth, models.MetricNetwork)
+	if err != nil || stats.TotalBytes != 3000 {
+		t.Fatalf("network stats = %+v, %v, want total 3000", stats, err)
+	}
+}
+
+func TestCalculateUploadRatio(t *testing.T) {
+	baseTime := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: 0, TXBytes: 0},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 750_000, TXBytes: 15_000_000},
+	}
+	upload, download, ratio, span := CalculateUploadRatio(101, records)
+	if upload != 2_000_000 || download != 100_000 || ratio != 20 || span != time.Minute {
+		t.Fatalf("upload=%v download=%v ratio=%v span=%v, want 2000000 100000 20 1m", upload, download, ratio, span)
+	}
+
+	// 没有下载时比例为上限
+	records[1].RXBytes = 0
+	if _, _, ratio, _ := CalculateUploadRatio(101, records); ratio != models.MaxUploadRatio {
+		t.Fatalf("ratio without download = %v, want %v", ratio, models.MaxUploadRatio)
+	}
+}
+
+func TestExcludeBackupTraffic(t *testing.T) {
+	store, err := NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: 1000, TXBytes: 1000},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 1100, TXBytes: 1200},
+		// 备份期间的两个采样区间
+		{VMID: 101, Timestamp: baseTime.Add(2 * time.Minute), RXBytes: 1200, TXBytes: 9200, Backup: true},
+		// 备份期间虚拟机重启，计数从 0 开始
+		{VMID: 101, Timestamp: baseTime.Add(3 * time.Minute), RXBytes: 50, TXBytes: 4000, Backup: true},
+		{VMID: 101, Timestamp: baseTime.Add(4 * time.Minute), RXBytes: 150, TXBytes: 4300},
+	}
+	if err := SaveTrafficRecords(store, records); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	end := baseTime.Add(4 * time.Minute)
+	stats, err := store.CalculateTrafficStatsWithTimeRange(101, baseTime, end, models.DirectionBoth)
+	if err != nil || stats.RXBytes != 350 || stats.TXBytes != 12500 {
+		t.Fatalf("stats = %+v, %v, want backup traffic counted by default", stats, err)
+	}
+
+	SetExcludeBackups(true)
+	defer SetExcludeBackups(false)
+	stats, err = store.CalculateTrafficStatsWithTimeRange(101, baseTime, end, models.DirectionBoth)
+	if err != nil || stats.RXBytes != 200 || stats.TXBytes != 500 {
+		t.Fatalf("stats = %+v, %v, want rx:200 tx:500 without backup intervals", stats, err)
+	}
+	stats, err = CalculateMetricStats(store, 101, baseTime, end, models.DirectionUpload, models.MetricNetwork)
+	if err != nil || stats.TotalBytes != 500 {
+		t.Fatalf("metric stats = %+v, %v, want upload 500", stats, err)
+	}
+}
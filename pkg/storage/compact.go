@@ -0,0 +1,36 @@
+package storage
+
+import "errors"
+
+// ErrCompactUnsupported 存储不支持压缩
+var ErrCompactUnsupported = errors.New("存储不支持压缩")
+
+// CompactProgress 压缩进度（步骤为表名或目录名）
+type CompactProgress struct {
+	Step  string
+	Done  int
+	Total int
+}
+
+// CompactResult 压缩结果
+type CompactResult struct {
+	BytesBefore  int64 // 压缩前占用的空间（无法获取时为 0）
+	BytesAfter   int64
+	RemovedFiles int // 删除的空文件数（文件存储）
+	RemovedDirs  int // 删除的空目录数（文件存储）
+}
+
+// Compact 压缩存储（存储不支持时返回 ErrCompactUnsupported）
+func Compact(s Interface, progress func(CompactProgress)) (CompactResult, error) {
+	if compactor, ok := As[Compactor](s); ok {
+		return compactor.Compact(progress)
+	}
+	return CompactResult{}, ErrCompactUnsupported
+}
+
+// reportProgress 调用进度回调（可为 nil）
+func reportProgress(progress func(CompactProgress), step string, done, total int) {
+	if progress != nil {
+		progress(CompactProgress{Step: step, Done: done, Total: total})
+	}
+}
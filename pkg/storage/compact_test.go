@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	files, err := NewFileStorage(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	defer files.Close()
+	if err := files.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: time.Now(), RXBytes: 1}); err != nil {
+		t.Fatalf("save traffic record: %v", err)
+	}
+	// 删除记录后留下的空目录和空文件
+	os.MkdirAll(filepath.Join(dir, "file", "vm_102"), 0755)
+	os.MkdirAll(filepath.Join(dir, "file", "vm_103"), 0755)
+	os.WriteFile(filepath.Join(dir, "file", "vm_103", "traffic_2026-01-01.jsonl"), []byte("\n"), 0644)
+
+	var steps int
+	result, err := Compact(files, func(CompactProgress) { steps++ })
+	if err != nil || result.RemovedDirs != 2 || result.RemovedFiles != 1 || steps != 3 {
+		t.Fatalf("Compact(file) = %+v, %v after %d steps, want 1 file and 2 dirs removed in 3 steps", result, err, steps)
+	}
+	if records, _ := files.GetTrafficRecords(101, time.Now().Add(-time.Hour), time.Now()); len(records) != 1 {
+		t.Fatalf("records after compaction = %d, want 1", len(records))
+	}
+
+	db, err := NewStorageFromConfig(&models.StorageConfig{Type: "sqlite", DSN: filepath.Join(dir, "pve_traffic.db"), MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer db.Close()
+	if result, err := Compact(db, nil); err != nil || result.BytesBefore == 0 || result.BytesAfter == 0 {
+		t.Fatalf("Compact(sqlite) = %+v, %v, want database sizes", result, err)
+	}
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// conformanceBackend 一致性测试使用的存储后端
+type conformanceBackend struct {
+	name string
+	open func(t *testing.T) Interface
+}
+
+// conformanceBackends 所有存储实现（新增后端或包装器时加入这里，保证行为与现有实现一致）
+func conformanceBackends() []conformanceBackend {
+	openFile := func(t *testing.T) Interface {
+		store, err := NewFileStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("create file storage: %v", err)
+		}
+		return store
+	}
+	openSQLite := func(t *testing.T) Interface {
+		store, err := NewStorageFromConfig(&models.StorageConfig{
+			Type:         "sqlite",
+			DSN:          filepath.Join(t.TempDir(), "pve_traffic.db"),
+			MaxOpenConns: 1,
+			MaxIdleConns: 1,
+		})
+		if err != nil {
+			t.Fatalf("create sqlite storage: %v", err)
+		}
+		return store
+	}
+
+	return []conformanceBackend{
+		{"file", openFile},
+		{"sqlite", openSQLite},
+		{"sqlite-memory", func(t *testing.T) Interface {
+			store, err := NewDatabaseStorage("sqlite3", ":memory:", 1, 1, 0)
+			if err != nil {
+				t.Fatalf("create in-memory sqlite storage: %v", err)
+			}
+			return store
+		}},
+		{"instrumented", func(t *testing.T) Interface {
+			return NewInstrumentedStorage(openFile(t), time.Second)
+		}},
+		{"spool", func(t *testing.T) Interface {
+			store, err := NewSpoolStorage(openSQLite(t), t.TempDir(), 0)
+			if err != nil {
+				t.Fatalf("create spool storage: %v", err)
+			}
+			return store
+		}},
+		{"replicated", func(t *testing.T) Interface {
+			return NewReplicatedStorage(openSQLite(t), openFile(t))
+		}},
+	}
+}
+
+// runConformance 对每个存储后端执行 fn
+func runConformance(t *testing.T, fn func(t *testing.T, store Interface)) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.open(t)
+			defer store.Close()
+			fn(t, store)
+		})
+	}
+}
+
+func saveConformanceRecords(t *testing.T, store Interface, records []models.TrafficRecord) {
+	t.Helper()
+	for _, record := range records {
+		if err := store.SaveTrafficRecord(record); err != nil {
+			t.Fatalf("save traffic record: %v", err)
+		}
+	}
+}
+
+func TestConformanceRecordsAndRangeStats(t *testing.T) {
+	base := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	runConformance(t, func(t *testing.T, store Interface) {
+		saveConformanceRecords(t, store, []models.TrafficRecord{
+			{VMID: 101, Timestamp: base, RXBytes: 1000, TXBytes: 500, TotalBytes: 1500},
+			{VMID: 102, Timestamp: base, RXBytes: 9000, TXBytes: 9000, TotalBytes: 18000},
+			{VMID: 101, Timestamp: base.Add(time.Minute), RXBytes: 1400, TXBytes: 800, TotalBytes: 2200},
+			{VMID: 101, Timestamp: base.Add(2 * time.Minute), RXBytes: 2000, TXBytes: 1000, TotalBytes: 3000},
+		})
+
+		records, err := store.GetTrafficRecords(101, base, base.Add(2*time.Minute))
+		if err != nil {
+			t.Fatalf("get traffic records: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("record count = %d, want 3 (both bounds inclusive, other VMs excluded)", len(records))
+		}
+		for i := 1; i < len(records); i++ {
+			if !records[i].Timestamp.After(records[i-1].Timestamp) {
+				t.Fatalf("records not in ascending order: %v", records)
+			}
+		}
+		if records[0].VMID != 101 || records[0].RXBytes != 1000 || records[0].TXBytes != 500 || !records[0].Timestamp.Equal(base) {
+			t.Fatalf("first record = %+v, want the saved values", records[0])
+		}
+
+		for _, tc := range []struct {
+			direction string
+			rx, tx    uint64
+			total     uint64
+		}{
+			{models.DirectionBoth, 1000, 500, 1500},
+			{models.DirectionDownload, 1000, 500, 1000},
+			{models.DirectionUpload, 1000, 500, 500},
+		} {
+			stats, err := store.CalculateTrafficStatsWithTimeRange(101, base, base.Add(2*time.Minute), tc.direction)
+			if err != nil {
+				t.Fatalf("calculate %s stats: %v", tc.direction, err)
+			}
+			if stats.RXBytes != tc.rx || stats.TXBytes != tc.tx || stats.TotalBytes != tc.total || stats.Direction != tc.direction {
+				t.Fatalf("%s stats = %+v, want rx:%d tx:%d total:%d", tc.direction, stats, tc.rx, tc.tx, tc.total)
+			}
+			if stats.Period != "custom" || stats.VMID != 101 {
+				t.Fatalf("stats = %+v, want custom period for VM 101", stats)
+			}
+		}
+
+		empty, err := store.CalculateTrafficStatsWithTimeRange(103, base, base.Add(time.Hour), models.DirectionBoth)
+		if err != nil {
+			t.Fatalf("calculate stats without records: %v", err)
+		}
+		if empty.TotalBytes != 0 || empty.Direction != models.DirectionBoth {
+			t.Fatalf("stats without records = %+v, want zero", empty)
+		}
+	})
+}
+
+func TestConformanceCounterRestarts(t *testing.T) {
+	base := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	runConformance(t, func(t *testing.T, store Interface) {
+		// 第二条记录前重启：忽略第一条；第四条记录前再次重启：累加重启前的一段
+		saveConformanceRecords(t, store, []models.TrafficRecord{
+			{VMID: 101, Timestamp: base, RXBytes: 5000, TXBytes: 100},
+			{VMID: 101, Timestamp: base.Add(time.Minute), RXBytes: 100, TXBytes: 300},
+			{VMID: 101, Timestamp: base.Add(2 * time.Minute), RXBytes: 600, TXBytes: 900},
+			{VMID: 101, Timestamp: base.Add(3 * time.Minute), RXBytes: 50, TXBytes: 20},
+			{VMID: 101, Timestamp: base.Add(4 * time.Minute), RXBytes: 250, TXBytes: 120},
+		})
+
+		stats, err := store.CalculateTrafficStatsWithTimeRange(101, base, base.Add(4*time.Minute), models.DirectionBoth)
+		if err != nil {
+			t.Fatalf("calculate stats: %v", err)
+		}
+		// RX: 600（首次重启后从 0 开始）+ 250；TX: 900-100 + 120
+		if stats.RXBytes != 850 || stats.TXBytes != 920 || stats.TotalBytes != 1770 {
+			t.Fatalf("stats = rx:%d tx:%d total:%d, want rx:850 tx:920 total:1770", stats.RXBytes, stats.TXBytes, stats.TotalBytes)
+		}
+	})
+}
+
+func TestConformanceBoundarySplit(t *testing.T) {
+	midnight := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)
+	runConformance(t, func(t *testing.T, store Interface) {
+		// 23:59:30 到 00:00:30 的增量一半属于前一天，00:01:30 之后中断 1 小时后的增量不拆分
+		saveConformanceRecords(t, store, []models.TrafficRecord{
+			{VMID: 101, Timestamp: midnight.Add(-90 * time.Second), RXBytes: 0, TXBytes: 0},
+			{VMID: 101, Timestamp: midnight.Add(-30 * time.Second), RXBytes: 1000, TXBytes: 100},
+			{VMID: 101, Timestamp: midnight.Add(30 * time.Second), RXBytes: 2000, TXBytes: 300},
+			{VMID: 101, Timestamp: midnight.Add(90 * time.Second), RXBytes: 4000, TXBytes: 600},
+		})
+
+		before, err := store.CalculateTrafficStatsWithTimeRange(101, midnight.Add(-time.Hour), midnight, models.DirectionBoth)
+		if err != nil {
+			t.Fatalf("calculate stats before midnight: %v", err)
+		}
+		after, err := store.CalculateTrafficStatsWithTimeRange(101, midnight, midnight.Add(time.Hour), models.DirectionBoth)
+		if err != nil {
+			t.Fatalf("calculate stats after midnight: %v", err)
+		}
+		if before.RXBytes != 1500 || before.TXBytes != 200 {
+			t.Fatalf("stats before midnight = rx:%d tx:%d, want rx:1500 tx:200", before.RXBytes, before.TXBytes)
+		}
+		if after.RXBytes != 2500 || after.TXBytes != 400 {
+			t.Fatalf("stats after midnight = rx:%d tx:%d, want rx:2500 tx:400", after.RXBytes, after.TXBytes)
+		}
+
+		// 范围内没有采样时按比例取得跨越整个范围的那次增量中的一部分
+		inside, err := store.CalculateTrafficStatsWithTimeRange(101, midnight.Add(45*time.Second), midnight.Add(75*time.Second), models.DirectionRX)
+		if err != nil {
+			t.Fatalf("calculate stats between samples: %v", err)
+		}
+		if inside.RXBytes != 1000 {
+			t.Fatalf("stats between samples = rx:%d, want 1000", inside.RXBytes)
+		}
+	})
+}
+
+func TestConformancePeriodStats(t *testing.T) {
+	runConformance(t, func(t *testing.T, store Interface) {
+		now := time.Now()
+		window := models.MinuteWindow()
+		saveConformanceRecords(t, store, []models.TrafficRecord{
+			{VMID: 101, Timestamp: now.Add(-window - time.Hour), RXBytes: 100, TXBytes: 100},
+			{VMID: 101, Timestamp: now.Add(-window + time.Minute), RXBytes: 1000, TXBytes: 2000},
+			{VMID: 101, Timestamp: now.Add(-time.Second), RXBytes: 1500, TXBytes: 2600},
+		})
+
+		stats, err := store.CalculateTrafficStats(101, "minute")
+		if err != nil {
+			t.Fatalf("calculate minute stats: %v", err)
+		}
+		if stats.Period != "minute" || stats.RXBytes != 500 || stats.TXBytes != 600 {
+			t.Fatalf("minute stats = %+v, want rx:500 tx:600 (record long before the window excluded)", stats)
+		}
+
+		upload, err := store.CalculateTrafficStatsWithDirection(101, "minute", time.Time{}, false, models.DirectionUpload)
+		if err != nil {
+			t.Fatalf("calculate upload stats: %v", err)
+		}
+		if upload.TotalBytes != 600 {
+			t.Fatalf("upload total = %d, want 600", upload.TotalBytes)
+		}
+
+		if _, err := store.CalculateTrafficStats(101, "fortnight"); err == nil {
+			t.Fatalf("unsupported period accepted")
+		}
+	})
+}
+
+func TestConformanceCountsAndDeletes(t *testing.T) {
+	base := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	runConformance(t, func(t *testing.T, store Interface) {
+		var records []models.TrafficRecord
+		for i := 0; i < 4; i++ {
+			for _, vmid := range []int{101, 102} {
+				records = append(records, models.TrafficRecord{
+					VMID:      vmid,
+					Timestamp: base.Add(time.Duration(i) * 24 * time.Hour),
+					RXBytes:   uint64(i * 100),
+				})
+			}
+		}
+		saveConformanceRecords(t, store, records)
+
+		assertCount := func(what string, got int64, err error, want int64) {
+			t.Helper()
+			if err != nil {
+				t.Fatalf("%s: %v", what, err)
+			}
+			if got != want {
+				t.Fatalf("%s = %d, want %d", what, got, want)
+			}
+		}
+
+		total, err := store.GetTotalRecordCount()
+		assertCount("total count", total, err, 8)
+
+		day1, day2 := base.Add(24*time.Hour), base.Add(48*time.Hour)
+		n, err := store.CountRecordsInRange(0, day1, day2)
+		assertCount("count all VMs in range", n, err, 4)
+		n, err = store.CountRecordsInRange(101, day1, day2)
+		assertCount("count VM 101 in range", n, err, 2)
+		n, err = store.CountRecordsBefore(day1)
+		assertCount("count before", n, err, 2)
+
+		vmids, err := RecordVMIDs(store, day1, day2)
+		if err != nil || len(vmids) != 2 || vmids[0] != 101 || vmids[1] != 102 {
+			t.Fatalf("record vmids = %v, %v, want [101 102]", vmids, err)
+		}
+		if vmids, err := RecordVMIDs(store, base.Add(-time.Hour), base); err != nil || len(vmids) != 0 {
+			t.Fatalf("record vmids before first record = %v, %v, want none (end exclusive)", vmids, err)
+		}
+
+		n, err = store.DeleteRecordsInRange(101, day1, day2)
+		assertCount("delete VM 101 in range", n, err, 2)
+		n, err = store.CountRecordsInRange(0, day1, day2)
+		assertCount("count after VM delete", n, err, 2)
+		total, err = store.GetTotalRecordCount()
+		assertCount("total after VM delete", total, err, 6)
+
+		n, err = store.DeleteRecordsInRange(0, day1, day2)
+		assertCount("delete all VMs in range", n, err, 2)
+
+		n, err = store.DeleteRecordsBefore(day1)
+		assertCount("delete before", n, err, 2)
+		total, err = store.GetTotalRecordCount()
+		assertCount("total after deletes", total, err, 2)
+
+		remaining, err := store.GetTrafficRecords(102, base, base.Add(96*time.Hour))
+		if err != nil {
+			t.Fatalf("get remaining records: %v", err)
+		}
+		if len(remaining) != 1 || !remaining[0].Timestamp.Equal(base.Add(72*time.Hour)) {
+			t.Fatalf("remaining records = %+v, want only the last day", remaining)
+		}
+	})
+}
+
+func TestConformanceActionLogsAndVMState(t *testing.T) {
+	base := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	runConformance(t, func(t *testing.T, store Interface) {
+		for i, action := range []string{models.ActionDisconnect, models.ActionShutdown} {
+			log := models.ActionLog{
+				VMID:      101 + i,
+				RuleName:  "limit",
+				Action:    action,
+				Timestamp: base.Add(time.Duration(i) * 24 * time.Hour),
+				Success:   true,
+			}
+			if err := store.SaveActionLog(log); err != nil {
+				t.Fatalf("save action log: %v", err)
+			}
+		}
+
+		logs, err := store.GetActionLogs(base, base.Add(48*time.Hour))
+		if err != nil {
+			t.Fatalf("get action logs: %v", err)
+		}
+		if len(logs) != 2 {
+			t.Fatalf("action log count = %d, want 2", len(logs))
+		}
+		got := map[int]string{}
+		for _, log := range logs {
+			got[log.VMID] = log.Action
+		}
+		if got[101] != models.ActionDisconnect || got[102] != models.ActionShutdown {
+			t.Fatalf("action logs = %+v, want disconnect for 101 and shutdown for 102", logs)
+		}
+		if logs, err := store.GetActionLogs(base.Add(time.Hour), base.Add(2*time.Hour)); err != nil || len(logs) != 0 {
+			t.Fatalf("action logs outside range = %+v, %v, want none", logs, err)
+		}
+
+		if err := store.SaveVMState(101, map[string]interface{}{"limited": true, "rule": "limit"}); err != nil {
+			t.Fatalf("save VM state: %v", err)
+		}
+		if err := store.SaveVMState(101, map[string]interface{}{"limited": false}); err != nil {
+			t.Fatalf("overwrite VM state: %v", err)
+		}
+		state, err := store.LoadVMState(101)
+		if err != nil {
+			t.Fatalf("load VM state: %v", err)
+		}
+		if state["limited"] != false {
+			t.Fatalf("VM state = %+v, want the last saved state", state)
+		}
+	})
+}
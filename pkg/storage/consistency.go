@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// ConsistencyReport 文件存储启动检查的结果
+type ConsistencyReport struct {
+	FilesChecked     int // 检查的流量文件数
+	RepairedFiles    int // 修复的文件数（隔离损坏行或补全末尾换行）
+	QuarantinedLines int // 移到隔离目录的损坏行数
+	TempFilesRemoved int // 删除的残留临时文件数
+}
+
+// ConsistencyChecker 可在启动时检查并修复数据文件的存储
+type ConsistencyChecker interface {
+	// CheckConsistency 检查 since 之后修改过的数据文件（异常退出只会损坏当时正在写入的文件）
+	CheckConsistency(since time.Time) (ConsistencyReport, error)
+}
+
+// CheckConsistency 检查最近修改过的流量文件：写了一半的 JSON 行移到 quarantine/ 目录，
+// 缺少末尾换行的文件补全换行（否则下一条追加的记录会接在残缺的行后面），并删除所有原子写入残留的临时文件
+func (s *FileStorage) CheckConsistency(since time.Time) (ConsistencyReport, error) {
+	var report ConsistencyReport
+	dirs, err := filepath.Glob(filepath.Join(s.basePath, "vm_*"))
+	if err != nil {
+		return report, fmt.Errorf("读取存储目录失败: %w", err)
+	}
+	nodeDirs, _ := filepath.Glob(filepath.Join(s.basePath, "node_*"))
+	dirs = append(dirs, nodeDirs...)
+	// 操作日志、状态和运行信息目录只清理临时文件
+	for _, name := range []string{"logs", "states", "meta"} {
+		dirs = append(dirs, filepath.Join(s.basePath, name))
+	}
+
+	var quarantined int64
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || file.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+
+			// 正在进行的原子写入刚创建的临时文件不删除
+			if strings.HasSuffix(file.Name(), atomicTempSuffix) {
+				if time.Since(info.ModTime()) > time.Minute && os.Remove(path) == nil {
+					report.TempFilesRemoved++
+				}
+				continue
+			}
+			if info.ModTime().Before(since) || !strings.HasPrefix(file.Name(), "traffic_") || !strings.HasSuffix(file.Name(), ".jsonl") {
+				continue
+			}
+
+			report.FilesChecked++
+			var bad int
+			var repaired bool
+			vmFile := strings.HasPrefix(filepath.Base(dir), "vm_")
+			err = s.withLock(true, func() (err error) {
+				bad, repaired, err = s.repairJSONLFile(path)
+				// 损坏的行已计入总采样点数，持有锁时扣除，避免与后台重新统计交错
+				if vmFile {
+					s.recordCounter.add(-int64(bad))
+				}
+				return err
+			})
+			if err != nil {
+				return report, err
+			}
+			if repaired {
+				report.RepairedFiles++
+			}
+			report.QuarantinedLines += bad
+			if vmFile {
+				quarantined += int64(bad)
+			}
+		}
+	}
+
+	if quarantined > 0 {
+		s.recordCounter.save()
+	}
+
+	return report, nil
+}
+
+// repairJSONLFile 隔离文件中无法解析的行并补全末尾换行，返回隔离的行数和是否修改了文件
+func (s *FileStorage) repairJSONLFile(path string) (int, bool, error) {
+	return s.quarantineLines(path, json.Valid)
+}
+
+// quarantineLines 把 keep 返回 false 的行移到隔离目录并补全末尾换行，返回隔离的行数和是否修改了文件
+func (s *FileStorage) quarantineLines(path string, keep func(line []byte) bool) (int, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return 0, false, nil
+	}
+
+	var valid, bad [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if keep(line) {
+			valid = append(valid, line)
+		} else {
+			bad = append(bad, line)
+		}
+	}
+
+	if len(bad) == 0 {
+		if data[len(data)-1] == '\n' {
+			return 0, false, nil
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, false, fmt.Errorf("补全流量文件换行失败: %w", err)
+		}
+		defer f.Close()
+		if _, err := f.Write([]byte("\n")); err != nil {
+			return 0, false, fmt.Errorf("补全流量文件换行失败: %w", err)
+		}
+		return 0, true, nil
+	}
+
+	// 先保存损坏的行，再重写原文件
+	quarantineDir := filepath.Join(s.basePath, "quarantine")
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		return 0, false, fmt.Errorf("创建隔离目录失败: %w", err)
+	}
+	name := filepath.Base(filepath.Dir(path)) + "_" + filepath.Base(path)
+	f, err := os.OpenFile(filepath.Join(quarantineDir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, false, fmt.Errorf("打开隔离文件失败: %w", err)
+	}
+	_, err = f.Write(append(bytes.Join(bad, []byte("\n")), '\n'))
+	f.Close()
+	if err != nil {
+		return 0, false, fmt.Errorf("写入隔离文件失败: %w", err)
+	}
+
+	var content []byte
+	if len(valid) > 0 {
+		content = append(bytes.Join(valid, []byte("\n")), '\n')
+	}
+	if err := writeFileAtomic(path, content, 0644); err != nil {
+		return 0, false, fmt.Errorf("重写流量文件失败: %w", err)
+	}
+	utils.DebugLog("隔离 %s 中 %d 行损坏的记录", path, len(bad))
+	return len(bad), true, nil
+}
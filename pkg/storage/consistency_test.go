@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestFileStorageCheckConsistency(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: at, RXBytes: 1}); err != nil {
+		t.Fatalf("save traffic record: %v", err)
+	}
+
+	// 模拟追加记录时崩溃：最后一行只写了一半
+	path := filepath.Join(dir, "vm_101", "traffic_2026-01-02.jsonl")
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"vmid":101,"timestamp":"2026-01-02T03:01`)
+	f.Close()
+	// 以及缺少末尾换行的文件和残留的临时文件
+	os.MkdirAll(filepath.Join(dir, "vm_102"), 0755)
+	os.WriteFile(filepath.Join(dir, "vm_102", "traffic_2026-01-02.jsonl"), []byte(`{"vmid":102}`), 0644)
+	tmp := filepath.Join(dir, "vm_102", "traffic_2026-01-01.jsonl.123"+atomicTempSuffix)
+	os.WriteFile(tmp, []byte("x"), 0644)
+	os.Chtimes(tmp, at, at)
+
+	report, err := store.CheckConsistency(time.Now().Add(-time.Hour))
+	if err != nil || report.FilesChecked != 2 || report.RepairedFiles != 2 || report.QuarantinedLines != 1 || report.TempFilesRemoved != 1 {
+		t.Fatalf("CheckConsistency() = %+v, %v, want 2 repaired files, 1 quarantined line and 1 temp file", report, err)
+	}
+
+	// 修复后新追加的记录可以正常读取
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: at.Add(2 * time.Minute), RXBytes: 2}); err != nil {
+		t.Fatalf("save traffic record: %v", err)
+	}
+	if records, _ := store.GetTrafficRecords(101, at, at.Add(time.Hour)); len(records) != 2 {
+		t.Fatalf("records = %+v, want 2 after repair", records)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "quarantine", "vm_101_traffic_2026-01-02.jsonl")); !strings.Contains(string(data), "03:01") {
+		t.Fatalf("quarantine file = %q, want the truncated line", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "vm_102", "traffic_2026-01-02.jsonl")); string(data) != "{\"vmid\":102}\n" {
+		t.Fatalf("vm_102 file = %q, want a trailing newline", data)
+	}
+}
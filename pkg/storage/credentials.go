@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"pve-traffic-monitor/pkg/models"
+	"sync"
+)
+
+// dsnConnector 按当前连接字符串建立连接的 Connector
+// 数据库密码轮换后更新连接字符串，连接池中的新连接使用新密码，已有连接在 conn_max_lifetime 到期后替换
+type dsnConnector struct {
+	driver driver.Driver
+
+	mu        sync.RWMutex
+	dsn       string
+	connector driver.Connector // 驱动支持 DriverContext 时按连接字符串创建（只解析一次）
+}
+
+// newDSNConnector 创建指定驱动的 Connector
+func newDSNConnector(driverType, dsn string) (*dsnConnector, error) {
+	// sql.Open 不会建立连接，只用于按名称取得已注册的驱动
+	db, err := sql.Open(driverType, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	c := &dsnConnector{driver: drv}
+	if err := c.use(dsn); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// setDSN 更换连接字符串（返回是否有变化）
+func (c *dsnConnector) setDSN(dsn string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dsn == c.dsn {
+		return false, nil
+	}
+	if err := c.use(dsn); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// use 设置连接字符串（调用方持有锁或对象尚未共享）
+func (c *dsnConnector) use(dsn string) error {
+	var connector driver.Connector
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		var err error
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return fmt.Errorf("解析数据库连接字符串失败: %w", err)
+		}
+	}
+	c.dsn = dsn
+	c.connector = connector
+	return nil
+}
+
+// Connect 使用当前连接字符串建立连接
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	dsn, connector := c.dsn, c.connector
+	c.mu.RUnlock()
+
+	if connector != nil {
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver 返回底层驱动
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// SetDSN 更换数据库连接字符串（如密码轮换），之后建立的连接使用新的连接字符串
+func (s *DatabaseStorage) SetDSN(dsn string) (bool, error) {
+	if s.driverType == "sqlite3" {
+		dsn = sqliteTunedDSN(dsn)
+	}
+	return s.connector.setDSN(dsn)
+}
+
+// UpdateCredentials 按新配置更新存储的数据库连接字符串（包括副本），返回是否有变化
+// 只更新连接字符串，存储类型、路径等其他配置的修改仍需重启
+func UpdateCredentials(s Interface, config *models.StorageConfig) (bool, error) {
+	for s != nil && config != nil {
+		switch v := s.(type) {
+		case *ReplicatedStorage:
+			changed, err := UpdateCredentials(v.primary, config)
+			if err != nil {
+				return changed, err
+			}
+			replicaChanged, err := UpdateCredentials(v.secondary, config.Replica)
+			return changed || replicaChanged, err
+		case *DatabaseStorage:
+			return v.SetDSN(config.DSN)
+		case Wrapper:
+			s = v.Unwrap()
+		default:
+			return false, nil
+		}
+	}
+	return false, nil
+}
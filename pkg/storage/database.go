@@ -11,6 +11,8 @@ import (
 	"pve-traffic-monitor/pkg/utils"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
@@ -18,36 +20,49 @@ import (
 	_ "github.com/mattn/go-sqlite3"    // SQLite driver
 )
 
+// dbCounterResync 数据库记录计数器重新统计间隔
+// 计数在写入和删除时增量更新，定期重新统计以纠正其他进程（如 CLI 清理）造成的偏差
+const dbCounterResync = 30 * time.Minute
+
 // DatabaseStorage 数据库存储管理器(实现 Interface 接口)
 type DatabaseStorage struct {
-	db         *sql.DB
-	driverType string // mysql, postgres, sqlite3
+	db            *sql.DB
+	connector     *dsnConnector  // 新建连接时使用的连接字符串（凭据轮换时更新）
+	driverType    string         // mysql, postgres, sqlite3
+	recordCounter *RecordCounter // 记录计数器（避免每次 COUNT(*) 全表扫描）
+	recounting    atomic.Bool    // 是否正在后台重新统计
+
+	schemaVersion    int          // 数据库结构版本
+	partitionMu      sync.Mutex   // 创建流量记录分区
+	partitionedUntil atomic.Int64 // 已创建的流量记录分区的结束时间（Unix 秒，仅分区表）
 }
 
 // NewDatabaseStorage 创建新的数据库存储管理器
 func NewDatabaseStorage(driverType, dsn string, maxOpenConns, maxIdleConns, connMaxLifetime int) (*DatabaseStorage, error) {
 	// 设置默认值
 	if maxOpenConns <= 0 {
-		maxOpenConns = 10
+		maxOpenConns = models.DefaultMaxOpenConns
 	}
 	if maxIdleConns <= 0 {
-		maxIdleConns = 5
+		maxIdleConns = models.DefaultMaxIdleConns
 	}
 	if connMaxLifetime <= 0 {
-		connMaxLifetime = 3600
+		connMaxLifetime = models.DefaultConnMaxLifetime
 	}
 
 	if driverType == "sqlite3" {
 		if err := ensureSQLiteDatabaseDir(dsn); err != nil {
 			return nil, err
 		}
+		dsn = sqliteTunedDSN(dsn)
 	}
 
 	// 连接数据库
-	db, err := sql.Open(driverType, dsn)
+	connector, err := newDSNConnector(driverType, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
+	db := sql.OpenDB(connector)
 
 	// 设置连接池参数
 	db.SetMaxOpenConns(maxOpenConns)
@@ -61,7 +76,12 @@ func NewDatabaseStorage(driverType, dsn string, maxOpenConns, maxIdleConns, conn
 
 	storage := &DatabaseStorage{
 		db:         db,
+		connector:  connector,
 		driverType: driverType,
+		recordCounter: &RecordCounter{
+			cacheTTL:     dbCounterResync,
+			needsRebuild: true, // 首次查询时统计
+		},
 	}
 
 	// 初始化表结构
@@ -74,27 +94,23 @@ func NewDatabaseStorage(driverType, dsn string, maxOpenConns, maxIdleConns, conn
 }
 
 // initTables 初始化数据库表
+// 新建的数据库直接使用当前版本的流量记录表结构，已有的数据库由 migrateSchema 按版本迁移
 func (s *DatabaseStorage) initTables() error {
-	trafficRecordIndex := ""
 	actionLogIndex := ""
+	nodeRecordIndex := ""
 	if s.driverType == "mysql" {
-		trafficRecordIndex = `,
-		INDEX idx_vmid_interface_timestamp (vmid, network_interface, timestamp)`
 		actionLogIndex = `,
 		INDEX idx_timestamp (timestamp)`
+		nodeRecordIndex = `,
+		INDEX idx_node_timestamp (node, timestamp)`
 	}
 
-	// 流量记录表
-	trafficRecordsTable := fmt.Sprintf(`
-	CREATE TABLE IF NOT EXISTS traffic_records (
-		%s,
-		vmid INTEGER NOT NULL,
-		network_interface VARCHAR(64) NOT NULL DEFAULT 'all',
-		timestamp TIMESTAMP NOT NULL,
-		rx_bytes BIGINT NOT NULL,
-		tx_bytes BIGINT NOT NULL,
-		total_bytes BIGINT NOT NULL%s
-	)%s`, s.idColumn(), trafficRecordIndex, s.engine())
+	fresh := !s.tableExists("traffic_records")
+	if fresh {
+		if err := s.createTrafficRecordsTable(s.db, "traffic_records", time.Now()); err != nil {
+			return err
+		}
+	}
 
 	// 操作日志表
 	actionLogsTable := fmt.Sprintf(`
@@ -106,7 +122,13 @@ func (s *DatabaseStorage) initTables() error {
 		reason TEXT,
 		timestamp TIMESTAMP NOT NULL,
 		success BOOLEAN NOT NULL,
-		error TEXT%s
+		error TEXT,
+		error_kind VARCHAR(32),
+		task_id VARCHAR(255),
+		task_status TEXT,
+		output TEXT,
+		hook_output TEXT,
+		hook_error TEXT%s
 	)%s`, s.idColumn(), actionLogIndex, s.engine())
 
 	// VM状态表
@@ -117,7 +139,34 @@ func (s *DatabaseStorage) initTables() error {
 		updated_at TIMESTAMP NOT NULL
 	)` + s.engine()
 
-	tables := []string{trafficRecordsTable, actionLogsTable, vmStatesTable}
+	// 节点流量记录表（PVE 节点自身网卡的累计流量）
+	nodeTrafficRecordsTable := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS node_traffic_records (
+		%s,
+		node VARCHAR(255) NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		rx_bytes BIGINT NOT NULL,
+		tx_bytes BIGINT NOT NULL,
+		total_bytes BIGINT NOT NULL%s
+	)%s`, s.idColumn(), nodeRecordIndex, s.engine())
+
+	// 运行信息表（上次清理结果等）
+	metadataTable := `
+	CREATE TABLE IF NOT EXISTS metadata (
+		name VARCHAR(255) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)` + s.engine()
+
+	// 租约表（主备模式，过期时间为毫秒时间戳）
+	leasesTable := `
+	CREATE TABLE IF NOT EXISTS leases (
+		name VARCHAR(255) PRIMARY KEY,
+		holder VARCHAR(255) NOT NULL,
+		expires_at BIGINT NOT NULL
+	)` + s.engine()
+
+	tables := []string{actionLogsTable, vmStatesTable, nodeTrafficRecordsTable, metadataTable, leasesTable}
 
 	for _, table := range tables {
 		if _, err := s.db.Exec(table); err != nil {
@@ -128,6 +177,9 @@ func (s *DatabaseStorage) initTables() error {
 	if err := s.ensureTrafficRecordsSchema(); err != nil {
 		return err
 	}
+	if err := s.ensureActionLogsSchema(); err != nil {
+		return err
+	}
 
 	for _, index := range s.indexStatements() {
 		if _, err := s.db.Exec(index); err != nil {
@@ -135,6 +187,11 @@ func (s *DatabaseStorage) initTables() error {
 		}
 	}
 
+	if err := s.migrateSchema(fresh); err != nil {
+		return err
+	}
+	s.ensurePartition(time.Now())
+
 	return nil
 }
 
@@ -159,20 +216,53 @@ func (s *DatabaseStorage) indexStatements() []string {
 	}
 
 	return []string{
-		`CREATE INDEX IF NOT EXISTS idx_traffic_records_vmid_interface_timestamp ON traffic_records (vmid, network_interface, timestamp)`,
 		`CREATE INDEX IF NOT EXISTS idx_action_logs_timestamp ON action_logs (timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_traffic_records_node_timestamp ON node_traffic_records (node, timestamp)`,
 	}
 }
 
+// ensureTrafficRecordsSchema 为旧版本创建的流量记录表添加网卡和磁盘读写字段（迁移前执行）
 func (s *DatabaseStorage) ensureTrafficRecordsSchema() error {
-	rows, err := s.db.Query(`SELECT network_interface FROM traffic_records LIMIT 1`)
-	if err == nil {
-		rows.Close()
-		return nil
+	columns := []struct{ name, definition string }{
+		{"network_interface", "VARCHAR(64) NOT NULL DEFAULT 'all'"},
+		{"disk_read_bytes", "BIGINT NOT NULL DEFAULT 0"},
+		{"disk_write_bytes", "BIGINT NOT NULL DEFAULT 0"},
 	}
+	for _, column := range columns {
+		rows, err := s.db.Query(fmt.Sprintf(`SELECT %s FROM traffic_records LIMIT 1`, column.name))
+		if err == nil {
+			rows.Close()
+			continue
+		}
 
-	if _, err := s.db.Exec(`ALTER TABLE traffic_records ADD COLUMN network_interface VARCHAR(64) NOT NULL DEFAULT 'all'`); err != nil {
-		return fmt.Errorf("迁移流量记录表失败: %w", err)
+		if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE traffic_records ADD COLUMN %s %s`, column.name, column.definition)); err != nil {
+			return fmt.Errorf("迁移流量记录表失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ensureActionLogsSchema 为旧版本创建的操作日志表添加缺少的字段（错误类型、任务状态、命令输出）
+func (s *DatabaseStorage) ensureActionLogsSchema() error {
+	columns := []struct{ name, definition string }{
+		{"error_kind", "VARCHAR(32)"},
+		{"task_id", "VARCHAR(255)"},
+		{"task_status", "TEXT"},
+		{"output", "TEXT"},
+		{"hook_output", "TEXT"},
+		{"hook_error", "TEXT"},
+	}
+	for _, column := range columns {
+		rows, err := s.db.Query(fmt.Sprintf(`SELECT %s FROM action_logs LIMIT 1`, column.name))
+		if err == nil {
+			rows.Close()
+			continue
+		}
+
+		if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE action_logs ADD COLUMN %s %s`, column.name, column.definition)); err != nil {
+			return fmt.Errorf("迁移操作日志表失败: %w", err)
+		}
 	}
 
 	return nil
@@ -204,6 +294,49 @@ func ensureSQLiteDatabaseDir(dsn string) error {
 	return nil
 }
 
+// sqliteTunedDSN 为 SQLite 连接字符串加上默认的连接参数（连接字符串中已设置的参数不变）
+// WAL 模式下读取不阻塞写入，synchronous=NORMAL 在 WAL 模式下只在检查点时同步，busy_timeout 使并发写入等待而不是立即返回 database is locked
+func sqliteTunedDSN(dsn string) string {
+	if sqliteDatabasePath(dsn) == "" {
+		return dsn // 内存数据库
+	}
+
+	query := ""
+	if i := strings.Index(dsn, "?"); i >= 0 {
+		query = dsn[i+1:]
+	}
+	has := func(names ...string) bool {
+		for _, param := range strings.Split(query, "&") {
+			key, _, _ := strings.Cut(param, "=")
+			for _, name := range names {
+				if key == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var params []string
+	if !has("_journal_mode", "_journal") {
+		params = append(params, "_journal_mode=WAL")
+	}
+	if !has("_synchronous", "_sync") {
+		params = append(params, "_synchronous=NORMAL")
+	}
+	if !has("_busy_timeout", "_timeout") {
+		params = append(params, "_busy_timeout=5000")
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + strings.Join(params, "&")
+}
+
 func sqliteDatabasePath(dsn string) string {
 	dsn = strings.TrimSpace(dsn)
 	if dsn == "" || dsn == ":memory:" || strings.HasPrefix(dsn, "file::memory:") {
@@ -233,22 +366,116 @@ func sqliteDatabasePath(dsn string) string {
 }
 
 // SaveTrafficRecord 保存流量记录
+// 同一虚拟机同一时间点已有记录时忽略（本地缓冲重放、远程写入重试）
 func (s *DatabaseStorage) SaveTrafficRecord(record models.TrafficRecord) error {
-	query := s.buildQuery(`INSERT INTO traffic_records (vmid, network_interface, timestamp, rx_bytes, tx_bytes, total_bytes)
-			  VALUES (?, ?, ?, ?, ?, ?)`, 6)
+	s.ensurePartition(record.Timestamp)
+
+	query := s.buildQuery(s.insertTrafficRecord(`INSERT INTO traffic_records (`+trafficRecordColumns+`)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`), trafficRecordParams)
 
-	_, err := s.db.Exec(query, record.VMID, defaultTrafficRecordInterface, record.Timestamp, record.RXBytes, record.TXBytes, record.TotalBytes)
+	result, err := s.db.Exec(query, trafficRecordArgs(record)...)
 	if err != nil {
 		return fmt.Errorf("保存流量记录失败: %w", err)
 	}
+	if inserted, err := result.RowsAffected(); err == nil {
+		s.recordCounter.add(inserted)
+	}
 
 	return nil
 }
 
+// trafficRecordColumns 写入流量记录的字段
+const trafficRecordColumns = `vmid, network_interface, timestamp, rx_bytes, tx_bytes, disk_read_bytes, disk_write_bytes, ipv4_rx_bytes, ipv4_tx_bytes, ipv6_rx_bytes, ipv6_tx_bytes, backup`
+
+// trafficRecordParams 每条流量记录的参数个数
+const trafficRecordParams = 12
+
+// trafficInsertBatch 批量写入时每条 INSERT 语句的记录数（12 个参数 × 80 行，低于旧版 SQLite 999 个参数的限制）
+const trafficInsertBatch = 80
+
+// trafficRecordArgs 流量记录按 trafficRecordColumns 顺序的参数
+func trafficRecordArgs(record models.TrafficRecord) []interface{} {
+	return []interface{}{
+		record.VMID, defaultTrafficRecordInterface, record.Timestamp,
+		byteCounter(record.RXBytes), byteCounter(record.TXBytes),
+		byteCounter(record.DiskRead), byteCounter(record.DiskWrite),
+		byteCounter(record.IPv4RX), byteCounter(record.IPv4TX),
+		byteCounter(record.IPv6RX), byteCounter(record.IPv6TX),
+		backupFlag(record.Backup),
+	}
+}
+
+// backupFlag 备份标记的字段值（各数据库都按 SMALLINT 保存）
+func backupFlag(backup bool) int {
+	if backup {
+		return 1
+	}
+	return 0
+}
+
+// SaveTrafficRecords 在一个事务中批量保存流量记录（多行 INSERT，同一时间点已有记录时忽略）
+// PostgreSQL 的 COPY 不能忽略重复记录，同样使用多行 INSERT
+func (s *DatabaseStorage) SaveTrafficRecords(records []models.TrafficRecord) error {
+	for _, record := range records {
+		s.ensurePartition(record.Timestamp)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("保存流量记录失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var inserted int64
+	for start := 0; start < len(records); start += trafficInsertBatch {
+		chunk := records[start:min(start+trafficInsertBatch, len(records))]
+
+		var query strings.Builder
+		query.WriteString(`INSERT INTO traffic_records (` + trafficRecordColumns + `) VALUES `)
+		args := make([]interface{}, 0, len(chunk)*trafficRecordParams)
+		for i, record := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("(" + strings.Join(s.placeholdersFrom(len(args)+1, trafficRecordParams), ", ") + ")")
+			args = append(args, trafficRecordArgs(record)...)
+		}
+
+		result, err := tx.Exec(s.insertTrafficRecord(query.String()), args...)
+		if err != nil {
+			return fmt.Errorf("保存流量记录失败: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			inserted += n
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("保存流量记录失败: %w", err)
+	}
+	s.recordCounter.add(inserted)
+	return nil
+}
+
+// insertTrafficRecord 为插入流量记录的语句加上忽略重复记录的子句
+func (s *DatabaseStorage) insertTrafficRecord(query string) string {
+	switch s.driverType {
+	case "sqlite3":
+		return strings.Replace(query, "INSERT INTO", "INSERT OR IGNORE INTO", 1)
+	case "postgres":
+		return query + " ON CONFLICT DO NOTHING"
+	case "mysql":
+		return query + " ON DUPLICATE KEY UPDATE vmid = vmid"
+	default:
+		return query
+	}
+}
+
 // GetTrafficRecords 获取流量记录
 func (s *DatabaseStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
-	query := s.buildQuery(`SELECT vmid, timestamp, rx_bytes, tx_bytes, total_bytes
-			  FROM traffic_records 
+	query := s.buildQuery(`SELECT vmid, timestamp, rx_bytes, tx_bytes, disk_read_bytes, disk_write_bytes,
+			  ipv4_rx_bytes, ipv4_tx_bytes, ipv6_rx_bytes, ipv6_tx_bytes, backup
+			  FROM traffic_records
 			  WHERE vmid = ? AND network_interface = ? AND timestamp >= ? AND timestamp <= ?
 			  ORDER BY timestamp ASC`, 4)
 
@@ -261,9 +488,13 @@ func (s *DatabaseStorage) GetTrafficRecords(vmid int, startTime, endTime time.Ti
 	var records []models.TrafficRecord
 	for rows.Next() {
 		var record models.TrafficRecord
-		if err := rows.Scan(&record.VMID, &record.Timestamp, &record.RXBytes, &record.TXBytes, &record.TotalBytes); err != nil {
+		var backup int
+		if err := rows.Scan(&record.VMID, &record.Timestamp, scanCounter(&record.RXBytes), scanCounter(&record.TXBytes), scanCounter(&record.DiskRead), scanCounter(&record.DiskWrite),
+			scanCounter(&record.IPv4RX), scanCounter(&record.IPv4TX), scanCounter(&record.IPv6RX), scanCounter(&record.IPv6TX), &backup); err != nil {
 			return nil, fmt.Errorf("扫描流量记录失败: %w", err)
 		}
+		record.TotalBytes = record.RXBytes + record.TXBytes
+		record.Backup = backup != 0
 		records = append(records, record)
 	}
 
@@ -274,6 +505,63 @@ func (s *DatabaseStorage) GetTrafficRecords(vmid int, startTime, endTime time.Ti
 	return records, nil
 }
 
+// EarliestRecordTime 获取虚拟机最早的流量记录时间
+func (s *DatabaseStorage) EarliestRecordTime(vmid int) (time.Time, error) {
+	query := s.buildQuery(`SELECT timestamp FROM traffic_records
+			  WHERE vmid = ? AND network_interface = ?
+			  ORDER BY timestamp ASC LIMIT 1`, 2)
+
+	var earliest time.Time
+	err := s.db.QueryRow(query, vmid, defaultTrafficRecordInterface).Scan(&earliest)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("查询最早流量记录失败: %w", err)
+	}
+	return earliest, nil
+}
+
+// SaveNodeTrafficRecord 保存节点流量记录（不计入总采样点数）
+func (s *DatabaseStorage) SaveNodeTrafficRecord(node string, record models.TrafficRecord) error {
+	query := s.buildQuery(`INSERT INTO node_traffic_records (node, timestamp, rx_bytes, tx_bytes, total_bytes)
+			  VALUES (?, ?, ?, ?, ?)`, 5)
+
+	if _, err := s.db.Exec(query, node, record.Timestamp, byteCounter(record.RXBytes), byteCounter(record.TXBytes), byteCounter(record.TotalBytes)); err != nil {
+		return fmt.Errorf("保存节点流量记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetNodeTrafficRecords 获取节点流量记录
+func (s *DatabaseStorage) GetNodeTrafficRecords(node string, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	query := s.buildQuery(`SELECT timestamp, rx_bytes, tx_bytes, total_bytes
+			  FROM node_traffic_records
+			  WHERE node = ? AND timestamp >= ? AND timestamp <= ?
+			  ORDER BY timestamp ASC`, 3)
+
+	rows, err := s.db.Query(query, node, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("查询节点流量记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []models.TrafficRecord
+	for rows.Next() {
+		var record models.TrafficRecord
+		if err := rows.Scan(&record.Timestamp, scanCounter(&record.RXBytes), scanCounter(&record.TXBytes), scanCounter(&record.TotalBytes)); err != nil {
+			return nil, fmt.Errorf("扫描节点流量记录失败: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("迭代节点流量记录失败: %w", err)
+	}
+
+	return records, nil
+}
+
 // CalculateTrafficStats 计算流量统计
 func (s *DatabaseStorage) CalculateTrafficStats(vmid int, period string) (*models.TrafficStats, error) {
 	return s.CalculateTrafficStatsWithDirection(vmid, period, time.Time{}, false, models.DirectionBoth)
@@ -286,30 +574,15 @@ func (s *DatabaseStorage) CalculateTrafficStatsWithTime(vmid int, period string,
 
 // CalculateTrafficStatsWithDirection 使用指定方向和时间计算流量统计
 func (s *DatabaseStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
-	now := time.Now()
-	var startTime time.Time
+	if !periodcalc.Supported(period) {
+		return nil, fmt.Errorf("不支持的时间周期: %s", period)
+	}
 
-	if useCreationTime && !creationTime.IsZero() {
-		// 使用基于创建时间的周期计算
-		startTime = s.calculatePeriodStart(period, creationTime, now)
-	} else {
-		// 使用固定周期
-		switch period {
-		case models.PeriodMinute:
-			// 确保有2个数据能够统计差值
-			startTime = now.Add(-2 * time.Minute)
-		case models.PeriodHour:
-			startTime = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
-		case models.PeriodDay:
-			startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		case models.PeriodMonth:
-			startTime = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		default:
-			return nil, fmt.Errorf("不支持的时间周期: %s", period)
-		}
-	}
-
-	records, err := s.GetTrafficRecords(vmid, startTime, now)
+	// 周期边界按配置的时区划分（默认主机本地时区）
+	now := time.Now().In(periodcalc.Location())
+	startTime := periodcalc.NewCalculator(period, creationTime, useCreationTime).PeriodStartAt(now)
+
+	records, err := rangeRecords(s.GetTrafficRecords, vmid, startTime, now)
 	if err != nil {
 		return nil, err
 	}
@@ -320,7 +593,7 @@ func (s *DatabaseStorage) CalculateTrafficStatsWithDirection(vmid int, period st
 
 // CalculateTrafficStatsWithTimeRange 使用自定义时间范围计算流量统计
 func (s *DatabaseStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime, endTime time.Time, direction string) (*models.TrafficStats, error) {
-	records, err := s.GetTrafficRecords(vmid, startTime, endTime)
+	records, err := rangeRecords(s.GetTrafficRecords, vmid, startTime, endTime)
 	if err != nil {
 		return nil, err
 	}
@@ -331,10 +604,10 @@ func (s *DatabaseStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime
 
 // SaveActionLog 保存操作日志
 func (s *DatabaseStorage) SaveActionLog(log models.ActionLog) error {
-	query := s.buildQuery(`INSERT INTO action_logs (vmid, rule_name, action, reason, timestamp, success, error) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`, 7)
+	query := s.buildQuery(`INSERT INTO action_logs (vmid, rule_name, action, reason, timestamp, success, error, error_kind, task_id, task_status, output, hook_output, hook_error, overridden_rules) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, 14)
 
-	_, err := s.db.Exec(query, log.VMID, log.RuleName, log.Action, log.Reason, log.Timestamp, log.Success, log.Error)
+	_, err := s.db.Exec(query, log.VMID, log.RuleName, log.Action, log.Reason, log.Timestamp, log.Success, log.Error, log.ErrorKind, log.TaskID, log.TaskStatus, log.Output, log.HookOutput, log.HookError, encodeRuleNames(log.OverriddenRules))
 	if err != nil {
 		return fmt.Errorf("保存操作日志失败: %w", err)
 	}
@@ -342,9 +615,27 @@ func (s *DatabaseStorage) SaveActionLog(log models.ActionLog) error {
 	return nil
 }
 
+// encodeRuleNames 规则名称列表保存为 JSON 数组（为空时保存空字符串）
+func encodeRuleNames(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(names)
+	return string(data)
+}
+
+// decodeRuleNames 解析 encodeRuleNames 保存的规则名称列表
+func decodeRuleNames(text string) []string {
+	var names []string
+	if text != "" {
+		json.Unmarshal([]byte(text), &names)
+	}
+	return names
+}
+
 // GetActionLogs 获取操作日志
 func (s *DatabaseStorage) GetActionLogs(startTime, endTime time.Time) ([]models.ActionLog, error) {
-	query := s.buildQuery(`SELECT vmid, rule_name, action, reason, timestamp, success, error 
+	query := s.buildQuery(`SELECT vmid, rule_name, action, reason, timestamp, success, error, error_kind, task_id, task_status, output, hook_output, hook_error, overridden_rules 
 			  FROM action_logs 
 			  WHERE timestamp >= ? AND timestamp <= ?
 			  ORDER BY timestamp ASC`, 2)
@@ -358,13 +649,20 @@ func (s *DatabaseStorage) GetActionLogs(startTime, endTime time.Time) ([]models.
 	var logs []models.ActionLog
 	for rows.Next() {
 		var log models.ActionLog
-		var errorMsg sql.NullString
-		if err := rows.Scan(&log.VMID, &log.RuleName, &log.Action, &log.Reason, &log.Timestamp, &log.Success, &errorMsg); err != nil {
+		var errorMsg, errorKind, taskID, taskStatus, output, hookOutput, hookError, overridden sql.NullString
+		if err := rows.Scan(&log.VMID, &log.RuleName, &log.Action, &log.Reason, &log.Timestamp, &log.Success, &errorMsg, &errorKind, &taskID, &taskStatus, &output, &hookOutput, &hookError, &overridden); err != nil {
 			return nil, fmt.Errorf("扫描操作日志失败: %w", err)
 		}
 		if errorMsg.Valid {
 			log.Error = errorMsg.String
 		}
+		log.ErrorKind = errorKind.String
+		log.TaskID = taskID.String
+		log.TaskStatus = taskStatus.String
+		log.Output = output.String
+		log.HookOutput = hookOutput.String
+		log.HookError = hookError.String
+		log.OverriddenRules = decodeRuleNames(overridden.String)
 		logs = append(logs, log)
 	}
 
@@ -437,6 +735,89 @@ func (s *DatabaseStorage) LoadVMState(vmid int) (map[string]interface{}, error)
 	return state, nil
 }
 
+// SaveMetadata 保存运行信息
+func (s *DatabaseStorage) SaveMetadata(name string, data []byte) error {
+	query := `INSERT INTO metadata (name, value, updated_at) VALUES (?, ?, ?)
+			  ON DUPLICATE KEY UPDATE value = VALUES(value), updated_at = VALUES(updated_at)`
+	if s.driverType == "postgres" {
+		query = `INSERT INTO metadata (name, value, updated_at) VALUES ($1, $2, $3)
+				 ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at`
+	} else if s.driverType == "sqlite3" {
+		query = `INSERT OR REPLACE INTO metadata (name, value, updated_at) VALUES (?, ?, ?)`
+	}
+
+	if _, err := s.db.Exec(query, name, string(data), time.Now()); err != nil {
+		return fmt.Errorf("保存运行信息失败: %w", err)
+	}
+	return nil
+}
+
+// LoadMetadata 读取运行信息（不存在时返回 nil）
+func (s *DatabaseStorage) LoadMetadata(name string) ([]byte, error) {
+	var value string
+	err := s.db.QueryRow(s.buildQuery(`SELECT value FROM metadata WHERE name = ?`, 1), name).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取运行信息失败: %w", err)
+	}
+	return []byte(value), nil
+}
+
+// AcquireLease 获取或续期租约：先尝试插入，已存在时只在租约过期或由自己持有时更新
+func (s *DatabaseStorage) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	expiresAt := now.Add(ttl).UnixMilli()
+
+	insert := `INSERT IGNORE INTO leases (name, holder, expires_at) VALUES (?, ?, ?)`
+	if s.driverType == "postgres" {
+		insert = `INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING`
+	} else if s.driverType == "sqlite3" {
+		insert = `INSERT OR IGNORE INTO leases (name, holder, expires_at) VALUES (?, ?, ?)`
+	}
+	result, err := s.db.Exec(insert, name, holder, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("获取租约失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return true, nil
+	}
+
+	result, err = s.db.Exec(s.buildQuery(`UPDATE leases SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at <= ?)`, 5),
+		holder, expiresAt, name, holder, now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("获取租约失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("获取租约失败: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ReleaseLease 释放租约（保留持有者，过期时间设为 0）
+func (s *DatabaseStorage) ReleaseLease(name, holder string) error {
+	if _, err := s.db.Exec(s.buildQuery(`UPDATE leases SET expires_at = 0 WHERE name = ? AND holder = ?`, 2), name, holder); err != nil {
+		return fmt.Errorf("释放租约失败: %w", err)
+	}
+	return nil
+}
+
+// GetLease 读取租约
+func (s *DatabaseStorage) GetLease(name string) (*Lease, error) {
+	var lease Lease
+	var expiresAt int64
+	err := s.db.QueryRow(s.buildQuery(`SELECT holder, expires_at FROM leases WHERE name = ?`, 1), name).Scan(&lease.Holder, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取租约失败: %w", err)
+	}
+	lease.ExpiresAt = time.UnixMilli(expiresAt)
+	return &lease, nil
+}
+
 // CleanupOldData 清理旧数据
 func (s *DatabaseStorage) CleanupOldData(retentionDays int) error {
 	if retentionDays <= 0 {
@@ -456,45 +837,207 @@ func (s *DatabaseStorage) CleanupOldData(retentionDays int) error {
 	}
 
 	deletedCount, _ := result.RowsAffected()
+	s.recordCounter.add(-deletedCount)
 	if deletedCount > 0 {
 		utils.DebugLog("数据清理完成: 删除 %d 条过期记录 (保留天数: %d)", deletedCount, retentionDays)
 	}
 
+	// 节点流量记录使用相同的保留期
+	if _, err := s.db.Exec(s.buildQuery(`DELETE FROM node_traffic_records WHERE timestamp < ?`, 1), cutoffTime); err != nil {
+		return fmt.Errorf("清理旧节点流量记录失败: %w", err)
+	}
+
 	return nil
 }
 
+// DeleteActionLogs 删除时间范围内的操作日志
+func (s *DatabaseStorage) DeleteActionLogs(vmid int, startTime, endTime time.Time, dryRun bool) (int64, error) {
+	where := `timestamp < ?`
+	args := []interface{}{endTime}
+	if !startTime.IsZero() {
+		where += ` AND timestamp >= ?`
+		args = append(args, startTime)
+	}
+	if vmid != 0 {
+		where += ` AND vmid = ?`
+		args = append(args, vmid)
+	}
+
+	if dryRun {
+		var count int64
+		if err := s.db.QueryRow(s.buildQuery(`SELECT COUNT(*) FROM action_logs WHERE `+where, len(args)), args...).Scan(&count); err != nil {
+			return 0, fmt.Errorf("统计操作日志失败: %w", err)
+		}
+		return count, nil
+	}
+
+	result, err := s.db.Exec(s.buildQuery(`DELETE FROM action_logs WHERE `+where, len(args)), args...)
+	if err != nil {
+		return 0, fmt.Errorf("删除操作日志失败: %w", err)
+	}
+
+	deletedCount, _ := result.RowsAffected()
+	if deletedCount > 0 {
+		utils.DebugLog("删除 %d 条操作日志", deletedCount)
+	}
+	return deletedCount, nil
+}
+
+// DeleteVMStates 删除匹配的虚拟机状态
+func (s *DatabaseStorage) DeleteVMStates(match func(vmid int, updatedAt time.Time) bool, dryRun bool) (int64, error) {
+	rows, err := s.db.Query(`SELECT vmid, updated_at FROM vm_states`)
+	if err != nil {
+		return 0, fmt.Errorf("查询虚拟机状态失败: %w", err)
+	}
+
+	var vmids []int
+	for rows.Next() {
+		var vmid int
+		var updatedAt time.Time
+		if err := rows.Scan(&vmid, &updatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描虚拟机状态失败: %w", err)
+		}
+		if match(vmid, updatedAt) {
+			vmids = append(vmids, vmid)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("迭代虚拟机状态失败: %w", err)
+	}
+	if dryRun {
+		return int64(len(vmids)), nil
+	}
+
+	var deletedCount int64
+	query := s.buildQuery(`DELETE FROM vm_states WHERE vmid = ?`, 1)
+	for _, vmid := range vmids {
+		result, err := s.db.Exec(query, vmid)
+		if err != nil {
+			return deletedCount, fmt.Errorf("删除虚拟机状态失败: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		deletedCount += n
+		utils.DebugLog("删除虚拟机状态: VM %d", vmid)
+	}
+	return deletedCount, nil
+}
+
+// compactTables 压缩时处理的表
+var compactTables = []string{"traffic_records", "action_logs", "vm_states", "node_traffic_records", "metadata"}
+
+// Compact 回收删除记录后的空间（SQLite: VACUUM；MySQL: OPTIMIZE TABLE；PostgreSQL: VACUUM ANALYZE，不锁表，空间留给之后的写入复用）
+func (s *DatabaseStorage) Compact(progress func(CompactProgress)) (CompactResult, error) {
+	var result CompactResult
+	result.BytesBefore = s.databaseSize()
+
+	if s.driverType == "sqlite3" {
+		// SQLite 只能整库 VACUUM
+		if _, err := s.db.Exec(`VACUUM`); err != nil {
+			return result, fmt.Errorf("压缩数据库失败: %w", err)
+		}
+		reportProgress(progress, "VACUUM", 1, 1)
+	} else {
+		for i, table := range compactTables {
+			statement := fmt.Sprintf(`VACUUM ANALYZE %s`, table)
+			if s.driverType == "mysql" {
+				statement = fmt.Sprintf(`OPTIMIZE TABLE %s`, table)
+			}
+
+			// OPTIMIZE TABLE 返回结果集，需要读取完毕
+			rows, err := s.db.Query(statement)
+			if err != nil {
+				return result, fmt.Errorf("压缩表 %s 失败: %w", table, err)
+			}
+			for rows.Next() {
+			}
+			rows.Close()
+			reportProgress(progress, table, i+1, len(compactTables))
+		}
+	}
+
+	result.BytesAfter = s.databaseSize()
+	return result, nil
+}
+
+// databaseSize 数据库占用的空间（字节，无法获取时为 0）
+func (s *DatabaseStorage) databaseSize() int64 {
+	var query string
+	switch s.driverType {
+	case "sqlite3":
+		query = `SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`
+	case "mysql":
+		query = `SELECT COALESCE(SUM(data_length + index_length + data_free), 0) FROM information_schema.tables WHERE table_schema = DATABASE()`
+	case "postgres":
+		query = `SELECT pg_database_size(current_database())`
+	default:
+		return 0
+	}
+
+	var size int64
+	if err := s.db.QueryRow(query).Scan(&size); err != nil {
+		utils.DebugLog("获取数据库大小失败: %v", err)
+		return 0
+	}
+	return size
+}
+
 // Close 关闭数据库连接
 func (s *DatabaseStorage) Close() error {
 	return s.db.Close()
 }
 
-// GetTotalRecordCount 获取总采样点数（数据库实现）
+// GetTotalRecordCount 获取总采样点数（数据库实现：使用增量计数器）
 func (s *DatabaseStorage) GetTotalRecordCount() (int64, error) {
-	query := `SELECT COUNT(*) FROM traffic_records`
+	if count, ok := s.recordCounter.get(); ok {
+		return count, nil
+	}
 
-	var count int64
-	err := s.db.QueryRow(query).Scan(&count)
+	// 计数已过期时先返回当前值，在后台重新统计
+	if count, ok := s.recordCounter.stale(); ok {
+		if s.recounting.CompareAndSwap(false, true) {
+			go func() {
+				defer s.recounting.Store(false)
+				if _, err := s.recountRecords(); err != nil {
+					utils.DebugLog("[计数器] 重新统计失败: %v", err)
+				}
+			}()
+		}
+		return count, nil
+	}
+
+	return s.recountRecords()
+}
+
+// RebuildRecordCount 重新执行 COUNT(*) 并更新计数器
+func (s *DatabaseStorage) RebuildRecordCount() (int64, error) {
+	return s.recountRecords()
+}
+
+// recountRecords 执行 COUNT(*) 并更新计数器
+func (s *DatabaseStorage) recountRecords() (count int64, err error) {
+	s.recordCounter.beginRebuild()
+	defer func() { s.recordCounter.endRebuild(err) }()
+
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM traffic_records`).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("查询总记录数失败: %w", err)
 	}
 
+	s.recordCounter.set(count)
 	return count, nil
 }
 
-// calculatePeriodStart 基于创建时间计算周期开始时间
-func (s *DatabaseStorage) calculatePeriodStart(period string, creationTime, now time.Time) time.Time {
-	return periodcalc.CalculateCreationBasedPeriodStart(period, creationTime, now)
-}
-
 // GetActionLogsByVMID 获取指定VM的操作日志(辅助方法)
 func (s *DatabaseStorage) GetActionLogsByVMID(vmid int, startTime, endTime time.Time) ([]models.ActionLog, error) {
-	query := `SELECT vmid, rule_name, action, reason, timestamp, success, error 
+	query := `SELECT vmid, rule_name, action, reason, timestamp, success, error, error_kind, task_id, task_status, output, hook_output, hook_error, overridden_rules 
 			  FROM action_logs 
 			  WHERE vmid = ? AND timestamp >= ? AND timestamp <= ?
 			  ORDER BY timestamp ASC`
 
 	if s.driverType == "postgres" {
-		query = `SELECT vmid, rule_name, action, reason, timestamp, success, error 
+		query = `SELECT vmid, rule_name, action, reason, timestamp, success, error, error_kind, task_id, task_status, output, hook_output, hook_error, overridden_rules 
 				 FROM action_logs 
 				 WHERE vmid = $1 AND timestamp >= $2 AND timestamp <= $3
 				 ORDER BY timestamp ASC`
@@ -509,13 +1052,20 @@ func (s *DatabaseStorage) GetActionLogsByVMID(vmid int, startTime, endTime time.
 	var logs []models.ActionLog
 	for rows.Next() {
 		var log models.ActionLog
-		var errorMsg sql.NullString
-		if err := rows.Scan(&log.VMID, &log.RuleName, &log.Action, &log.Reason, &log.Timestamp, &log.Success, &errorMsg); err != nil {
+		var errorMsg, errorKind, taskID, taskStatus, output, hookOutput, hookError, overridden sql.NullString
+		if err := rows.Scan(&log.VMID, &log.RuleName, &log.Action, &log.Reason, &log.Timestamp, &log.Success, &errorMsg, &errorKind, &taskID, &taskStatus, &output, &hookOutput, &hookError, &overridden); err != nil {
 			return nil, fmt.Errorf("扫描操作日志失败: %w", err)
 		}
 		if errorMsg.Valid {
 			log.Error = errorMsg.String
 		}
+		log.ErrorKind = errorKind.String
+		log.TaskID = taskID.String
+		log.TaskStatus = taskStatus.String
+		log.Output = output.String
+		log.HookOutput = hookOutput.String
+		log.HookError = hookError.String
+		log.OverriddenRules = decodeRuleNames(overridden.String)
 		logs = append(logs, log)
 	}
 
@@ -568,7 +1118,17 @@ func (s *DatabaseStorage) DeleteRecordsInRange(vmid int, startTime, endTime time
 		return 0, err
 	}
 
-	return result.RowsAffected()
+	return s.recordsDeleted(result)
+}
+
+// recordsDeleted 获取删除的记录数并更新计数器
+func (s *DatabaseStorage) recordsDeleted(result sql.Result) (int64, error) {
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	s.recordCounter.add(-deleted)
+	return deleted, nil
 }
 
 // CountRecordsInRange 统计指定时间范围内的记录数
@@ -611,6 +1171,29 @@ func (s *DatabaseStorage) CountRecordsInRange(vmid int, startTime, endTime time.
 	return count, nil
 }
 
+// RecordVMIDs [startTime, endTime) 内有流量记录的虚拟机
+func (s *DatabaseStorage) RecordVMIDs(startTime, endTime time.Time) ([]int, error) {
+	query := s.buildQuery(`SELECT DISTINCT vmid FROM traffic_records
+			  WHERE timestamp >= ? AND timestamp < ?
+			  ORDER BY vmid`, 2)
+
+	rows, err := s.db.Query(query, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("查询有记录的虚拟机失败: %w", err)
+	}
+	defer rows.Close()
+
+	var vmids []int
+	for rows.Next() {
+		var vmid int
+		if err := rows.Scan(&vmid); err != nil {
+			return nil, fmt.Errorf("扫描虚拟机ID失败: %w", err)
+		}
+		vmids = append(vmids, vmid)
+	}
+	return vmids, rows.Err()
+}
+
 // DeleteRecordsBefore 删除指定日期之前的所有记录
 func (s *DatabaseStorage) DeleteRecordsBefore(beforeTime time.Time) (int64, error) {
 	var result sql.Result
@@ -632,7 +1215,7 @@ func (s *DatabaseStorage) DeleteRecordsBefore(beforeTime time.Time) (int64, erro
 		return 0, err
 	}
 
-	return result.RowsAffected()
+	return s.recordsDeleted(result)
 }
 
 // CountRecordsBefore 统计指定日期之前的记录数
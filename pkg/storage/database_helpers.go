@@ -19,6 +19,15 @@ func (s *DatabaseStorage) getPlaceholders(count int) []string {
 	return placeholders
 }
 
+// placeholdersFrom 返回从第 first 个参数开始的 count 个占位符
+func (s *DatabaseStorage) placeholdersFrom(first, count int) []string {
+	placeholders := make([]string, count)
+	for i := range placeholders {
+		placeholders[i] = s.getPlaceholder(first + i)
+	}
+	return placeholders
+}
+
 // buildQuery 构建带占位符的SQL查询
 func (s *DatabaseStorage) buildQuery(query string, argCount int) string {
 	if s.driverType == "postgres" {
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"math"
 	"path/filepath"
 	"testing"
 	"time"
@@ -24,8 +25,8 @@ func TestSQLiteStorageEndToEnd(t *testing.T) {
 
 	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
 	records := []models.TrafficRecord{
-		{VMID: 101, Timestamp: baseTime, RXBytes: 1000, TXBytes: 500, TotalBytes: 1500},
-		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 1400, TXBytes: 800, TotalBytes: 2200},
+		{VMID: 101, Timestamp: baseTime, RXBytes: 1000, TXBytes: 500, TotalBytes: 1500, DiskRead: 4096},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 1400, TXBytes: 800, TotalBytes: 2200, DiskRead: 8192, DiskWrite: 1024, IPv4RX: 900, IPv4TX: 600, IPv6RX: 300, Backup: true},
 	}
 
 	for _, record := range records {
@@ -41,6 +42,15 @@ func TestSQLiteStorageEndToEnd(t *testing.T) {
 	if len(gotRecords) != 2 {
 		t.Fatalf("record count = %d, want 2 all-interface records", len(gotRecords))
 	}
+	if disk := DiskRecords(gotRecords); disk[1].RXBytes != 8192 || disk[1].TXBytes != 1024 || disk[1].TotalBytes != 9216 {
+		t.Fatalf("disk records = %+v, want read 8192 write 1024", disk[1])
+	}
+	if ipv6 := ProtocolRecords(gotRecords, models.MetricIPv6); len(ipv6) != 1 || ipv6[0].RXBytes != 300 || ipv6[0].TXBytes != 0 {
+		t.Fatalf("ipv6 records = %+v, want only the record with protocol counters", ipv6)
+	}
+	if gotRecords[0].Backup || !gotRecords[1].Backup {
+		t.Fatalf("backup flags = %v/%v, want false/true", gotRecords[0].Backup, gotRecords[1].Backup)
+	}
 
 	stats, err := store.CalculateTrafficStatsWithTimeRange(101, baseTime.Add(-time.Second), baseTime.Add(2*time.Minute), models.DirectionBoth)
 	if err != nil {
@@ -51,12 +61,18 @@ func TestSQLiteStorageEndToEnd(t *testing.T) {
 	}
 
 	actionLog := models.ActionLog{
-		VMID:      101,
-		RuleName:  "test-rule",
-		Action:    models.ActionDisconnect,
-		Reason:    "limit exceeded",
-		Timestamp: baseTime,
-		Success:   true,
+		VMID:       101,
+		RuleName:   "test-rule",
+		Action:     models.ActionDisconnect,
+		Reason:     "limit exceeded",
+		Timestamp:  baseTime,
+		Success:    false,
+		Error:      "permission check failed",
+		ErrorKind:  models.ActionErrorPermission,
+		TaskID:     "UPID:pve:0000A1B2:01234567:66000000:qmstop:101:root@pam:",
+		TaskStatus: "OK",
+		HookOutput: "notified\n",
+		HookError:  "外部命令执行失败: exit status 1",
 	}
 	if err := store.SaveActionLog(actionLog); err != nil {
 		t.Fatalf("save action log: %v", err)
@@ -65,8 +81,10 @@ func TestSQLiteStorageEndToEnd(t *testing.T) {
 	if err != nil {
 		t.Fatalf("get action logs: %v", err)
 	}
-	if len(logs) != 1 || logs[0].RuleName != actionLog.RuleName {
-		t.Fatalf("logs = %#v, want one test-rule log", logs)
+	if len(logs) != 1 || logs[0].RuleName != actionLog.RuleName || logs[0].ErrorKind != actionLog.ErrorKind ||
+		logs[0].TaskID != actionLog.TaskID || logs[0].TaskStatus != actionLog.TaskStatus ||
+		logs[0].HookOutput != actionLog.HookOutput || logs[0].HookError != actionLog.HookError {
+		t.Fatalf("logs = %#v, want one test-rule log with error kind, task and hook output", logs)
 	}
 
 	state := map[string]interface{}{
@@ -108,3 +126,152 @@ func TestSQLiteStorageEndToEnd(t *testing.T) {
 		t.Fatalf("range count = %d, want 1", rangeCount)
 	}
 }
+
+func TestSQLiteRecordCounter(t *testing.T) {
+	store, err := NewDatabaseStorage("sqlite3", filepath.Join(t.TempDir(), "counter.db"), 1, 1, 0)
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer store.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: baseTime.Add(time.Duration(i) * time.Minute)})
+	}
+
+	assertCount := func(want int64) {
+		t.Helper()
+		got, err := store.GetTotalRecordCount()
+		if err != nil {
+			t.Fatalf("get total record count: %v", err)
+		}
+		if got != want {
+			t.Fatalf("total count = %d, want %d", got, want)
+		}
+	}
+
+	// 首次查询执行 COUNT(*)，之后增量更新
+	assertCount(3)
+	store.SaveTrafficRecord(models.TrafficRecord{VMID: 102, Timestamp: baseTime})
+	assertCount(4)
+	if _, err := store.DeleteRecordsBefore(baseTime.Add(time.Minute)); err != nil {
+		t.Fatalf("delete records before: %v", err)
+	}
+	assertCount(2)
+
+	// 其他进程直接修改表后，计数在重新统计时纠正
+	if _, err := store.db.Exec(`DELETE FROM traffic_records`); err != nil {
+		t.Fatalf("delete all: %v", err)
+	}
+	store.recordCounter.mu.Lock()
+	store.recordCounter.lastUpdate = time.Now().Add(-2 * dbCounterResync)
+	store.recordCounter.mu.Unlock()
+	assertCount(2) // 过期时先返回旧值，后台重新统计
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if count, ok := store.recordCounter.get(); ok && count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("counter was not recounted in background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSQLiteSaveTrafficRecords(t *testing.T) {
+	store, err := NewDatabaseStorage("sqlite3", filepath.Join(t.TempDir(), "batch.db"), 1, 1, 0)
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer store.Close()
+
+	// 跨越多条 INSERT 语句，并包含一条已存在的记录
+	baseTime := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 102, Timestamp: baseTime, RXBytes: 1}); err != nil {
+		t.Fatalf("save record: %v", err)
+	}
+	var records []models.TrafficRecord
+	for i := 0; i < trafficInsertBatch*2+5; i++ {
+		records = append(records, models.TrafficRecord{VMID: 101 + i%2, Timestamp: baseTime.Add(time.Duration(i/2) * time.Minute), RXBytes: uint64(i), DiskWrite: 7})
+	}
+	if err := SaveTrafficRecords(store, records); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+
+	got, err := store.GetTrafficRecords(101, baseTime, baseTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("get records: %v", err)
+	}
+	if len(got) != trafficInsertBatch+3 || got[1].RXBytes != 2 || got[1].DiskWrite != 7 {
+		t.Fatalf("records = %d (second %+v), want %d", len(got), got[1], trafficInsertBatch+3)
+	}
+	if count, _ := store.GetTotalRecordCount(); count != int64(len(records)) {
+		t.Fatalf("record count = %d, want %d with the duplicate ignored", count, len(records))
+	}
+}
+
+func TestSQLiteLargeCounters(t *testing.T) {
+	store, err := NewDatabaseStorage("sqlite3", filepath.Join(t.TempDir(), "large.db"), 1, 1, 0)
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer store.Close()
+
+	baseTime := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	want := []models.TrafficRecord{
+		{VMID: 101, Timestamp: baseTime, RXBytes: math.MaxInt64, TXBytes: 1 << 63, DiskRead: math.MaxUint64, DiskWrite: 1},
+		{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: math.MaxUint64, TXBytes: math.MaxUint64 - 1},
+	}
+	if err := store.SaveTrafficRecord(want[0]); err != nil {
+		t.Fatalf("save record: %v", err)
+	}
+	if err := store.SaveTrafficRecords(want[1:]); err != nil {
+		t.Fatalf("save records: %v", err)
+	}
+	got, err := store.GetTrafficRecords(101, baseTime, baseTime.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("get records: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("records = %+v, want 2", got)
+	}
+	for i := range want {
+		if got[i].RXBytes != want[i].RXBytes || got[i].TXBytes != want[i].TXBytes || got[i].DiskRead != want[i].DiskRead || got[i].DiskWrite != want[i].DiskWrite {
+			t.Fatalf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	node := models.TrafficRecord{Timestamp: baseTime, RXBytes: math.MaxUint64, TXBytes: 1 << 63, TotalBytes: math.MaxUint64}
+	if err := store.SaveNodeTrafficRecord("pve1", node); err != nil {
+		t.Fatalf("save node record: %v", err)
+	}
+	nodeRecords, err := store.GetNodeTrafficRecords("pve1", baseTime, baseTime)
+	if err != nil || len(nodeRecords) != 1 || nodeRecords[0].RXBytes != node.RXBytes || nodeRecords[0].TXBytes != node.TXBytes || nodeRecords[0].TotalBytes != node.TotalBytes {
+		t.Fatalf("node records = %+v, %v", nodeRecords, err)
+	}
+}
+
+func TestCounterScanner(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want uint64
+	}{
+		{int64(-1), math.MaxUint64},
+		{[]byte("18446744073709551615"), math.MaxUint64},
+		{"-9223372036854775808", 1 << 63},
+		{float64(4096), 4096},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		var got uint64 = 7
+		if err := scanCounter(&got).Scan(tt.src); err != nil || got != tt.want {
+			t.Errorf("Scan(%v) = %d, %v, want %d", tt.src, got, err, tt.want)
+		}
+	}
+	var got uint64
+	if err := scanCounter(&got).Scan("abc"); err == nil {
+		t.Error("Scan(\"abc\") error = nil")
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+)
+
+// byteCounter 写入数据库的字节计数
+// 三种数据库都没有通用的无符号 64 位整数类型，计数按位存为 BIGINT：小于 2^63 的值不变，
+// 更大的值（计数器异常、回绕）在数据库中显示为负数，读取时按位还原（database/sql 默认拒绝最高位为 1 的 uint64）
+type byteCounter uint64
+
+// Value 实现 driver.Valuer
+func (c byteCounter) Value() (driver.Value, error) {
+	return int64(c), nil
+}
+
+// counterScanner 读取字节计数到 uint64（兼容驱动返回的整数、文本和浮点数）
+type counterScanner struct {
+	dst *uint64
+}
+
+// scanCounter 返回读取字节计数的 Scanner
+func scanCounter(dst *uint64) counterScanner {
+	return counterScanner{dst: dst}
+}
+
+// Scan 实现 sql.Scanner
+func (s counterScanner) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s.dst = 0
+	case int64:
+		*s.dst = uint64(v)
+	case uint64:
+		*s.dst = v
+	case float64:
+		// 个别驱动对聚合结果返回浮点数，只接受整数值
+		if v != float64(int64(v)) {
+			return fmt.Errorf("字节计数不是整数: %v", v)
+		}
+		*s.dst = uint64(int64(v))
+	case []byte:
+		return s.parse(string(v))
+	case string:
+		return s.parse(v)
+	default:
+		return fmt.Errorf("不支持的字节计数类型 %T", src)
+	}
+	return nil
+}
+
+// parse 解析文本形式的计数（MySQL 文本协议），负数按位还原
+func (s counterScanner) parse(text string) error {
+	if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+		*s.dst = n
+		return nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("解析字节计数失败: %w", err)
+	}
+	*s.dst = uint64(n)
+	return nil
+}
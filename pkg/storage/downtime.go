@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	periodcalc "pve-traffic-monitor/pkg/period"
+)
+
+// 没有采样的原因
+const (
+	DowntimeStopped   = "vm_stopped"   // 期间规则执行了关机或停止
+	DowntimeRestarted = "vm_restarted" // 前后计数器变小：虚拟机在期间停止后重新启动
+	DowntimeNoSamples = "no_samples"   // 计数器连续：监控或采集中断（期间的流量计入中断后的第一个采样）
+)
+
+// Downtime 没有采样的时间段（虚拟机停止或采集中断）
+type Downtime struct {
+	Start    time.Time `json:"start"`               // 中断前最后一次采样（从范围起点开始时为范围起点）
+	End      time.Time `json:"end"`                 // 中断后第一次采样（持续到范围终点时为范围终点）
+	Reason   string    `json:"reason"`              // vm_stopped/vm_restarted/no_samples
+	Ongoing  bool      `json:"ongoing,omitempty"`   // 到范围终点仍没有采样
+	Action   string    `json:"action,omitempty"`    // 期间执行的关机或停止操作
+	RuleName string    `json:"rule_name,omitempty"` // 执行该操作的规则
+}
+
+// DetectDowntime 根据相邻采样的间隔找出 [start, end] 内没有采样的时间段（间隔超过 threshold）
+// actions 为该虚拟机的操作日志，用于判断期间是否被规则关机；leading 表示范围起点之前已有采样（范围开始到第一次采样也算中断）
+func DetectDowntime(records []models.TrafficRecord, actions []models.ActionLog, start, end time.Time, threshold time.Duration, leading bool) []Downtime {
+	downtimes := []Downtime{}
+	if threshold <= 0 {
+		return downtimes
+	}
+
+	add := func(d Downtime) {
+		for _, action := range actions {
+			if !action.Success || (action.Action != models.ActionShutdown && action.Action != models.ActionStop) {
+				continue
+			}
+			if !action.Timestamp.Before(d.Start) && !action.Timestamp.After(d.End) {
+				d.Reason, d.Action, d.RuleName = DowntimeStopped, action.Action, action.RuleName
+				break
+			}
+		}
+		downtimes = append(downtimes, d)
+	}
+
+	if len(records) == 0 {
+		if leading && end.Sub(start) > threshold {
+			add(Downtime{Start: start, End: end, Reason: DowntimeNoSamples, Ongoing: true})
+		}
+		return downtimes
+	}
+
+	if first := records[0].Timestamp; leading && first.Sub(start) > threshold {
+		add(Downtime{Start: start, End: first, Reason: DowntimeNoSamples})
+	}
+	for i := 1; i < len(records); i++ {
+		prev, next := records[i-1], records[i]
+		if next.Timestamp.Sub(prev.Timestamp) <= threshold {
+			continue
+		}
+		reason := DowntimeNoSamples
+		if next.RXBytes < prev.RXBytes || next.TXBytes < prev.TXBytes {
+			reason = DowntimeRestarted
+		}
+		add(Downtime{Start: prev.Timestamp, End: next.Timestamp, Reason: reason})
+	}
+	if last := records[len(records)-1].Timestamp; end.Sub(last) > threshold {
+		add(Downtime{Start: last, End: end, Reason: DowntimeNoSamples, Ongoing: true})
+	}
+	return downtimes
+}
+
+// PeriodBucketStart 时间所在的 minute/hour/day/month 时间段的起点（按配置的时区，与 AggregateTrafficByPeriod 一致）
+func PeriodBucketStart(period string, t time.Time) time.Time {
+	t = t.In(periodcalc.Location())
+	switch period {
+	case models.PeriodMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	case models.PeriodHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case models.PeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextPeriodBucket 下一个时间段的起点
+func nextPeriodBucket(period string, t time.Time) time.Time {
+	switch period {
+	case models.PeriodMinute:
+		return t.Add(time.Minute)
+	case models.PeriodHour:
+		return PeriodBucketStart(period, t.Add(time.Hour))
+	case models.PeriodMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// CountPeriodBuckets [start, end] 内 minute/hour/day/month 时间段的数量（填充前检查点数）
+func CountPeriodBuckets(period string, start, end time.Time) int {
+	n := 0
+	for t := PeriodBucketStart(period, start); !t.After(end) && n <= models.MaxHistoryPoints; t = nextPeriodBucket(period, t) {
+		n++
+	}
+	return n
+}
+
+// FillPeriodGaps 为 AggregateTrafficByPeriod 的结果填充 [start, end] 内没有数据的时间段（Gap 为 true，流量为 0）
+func FillPeriodGaps(points []AggregatedPoint, period string, start, end time.Time) []AggregatedPoint {
+	byTime := make(map[int64]AggregatedPoint, len(points))
+	for _, point := range points {
+		byTime[point.Timestamp.Unix()] = point
+	}
+
+	var result []AggregatedPoint
+	for t := PeriodBucketStart(period, start); !t.After(end); t = nextPeriodBucket(period, t) {
+		if point, ok := byTime[t.Unix()]; ok {
+			result = append(result, point)
+		} else {
+			result = append(result, AggregatedPoint{Timestamp: t, Gap: true})
+		}
+	}
+	return result
+}
+
+// BucketStart 时间所在桶的起点（需要设置 Start 和 Step）
+func (q BucketQuery) BucketStart(t time.Time) time.Time {
+	clock := newBucketClock(q.Start, q.Step)
+	return clock.start(clock.index(t))
+}
@@ -7,11 +7,27 @@ import (
 )
 
 // NewStorage 根据配置创建存储实例(工厂函数)
+// 配置了 replica 时返回双写存储
 func NewStorageFromConfig(config *models.StorageConfig) (Interface, error) {
 	if config == nil {
 		return nil, fmt.Errorf("存储配置不能为空")
 	}
 
+	primary, err := newBackend(config)
+	if err != nil || config.Replica == nil {
+		return primary, err
+	}
+
+	replica, err := newBackend(config.Replica)
+	if err != nil {
+		primary.Close()
+		return nil, fmt.Errorf("创建副本存储失败: %w", err)
+	}
+	return NewReplicatedStorage(primary, replica), nil
+}
+
+// newBackend 创建单个存储后端
+func newBackend(config *models.StorageConfig) (Interface, error) {
 	storageType := strings.ToLower(config.Type)
 
 	switch storageType {
@@ -71,5 +87,14 @@ func ValidateStorageConfig(config *models.StorageConfig) error {
 		return fmt.Errorf("不支持的存储类型: %s (支持: file, mysql, postgresql, sqlite)", config.Type)
 	}
 
+	if config.Replica != nil {
+		if config.Replica.Replica != nil {
+			return fmt.Errorf("副本存储不能再配置副本")
+		}
+		if err := ValidateStorageConfig(config.Replica); err != nil {
+			return fmt.Errorf("副本存储配置无效: %w", err)
+		}
+	}
+
 	return nil
 }
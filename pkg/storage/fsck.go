@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 完整性检查发现的问题类型
+const (
+	IssueCounterMissing  = "counter_missing"  // 计数器文件不存在或损坏（打开存储时已在后台重建）
+	IssueCounterMismatch = "counter_mismatch" // 计数器与重新统计的记录数不一致
+	IssueCorruptLines    = "corrupt_lines"    // 无法解析的 JSON 行（写了一半的记录）
+	IssueOrphanRecords   = "orphan_records"   // 可以解析但不会被读取的记录（字段类型不符、按网卡保存的旧记录），仍计入总记录数
+	IssueMissingNewline  = "missing_newline"  // 文件缺少末尾换行（下一条追加的记录会接在同一行）
+	IssueCorruptFile     = "corrupt_file"     // 无法解析的旧格式 JSON 文件（不会自动修复）
+	IssueOrphanRows      = "orphan_rows"      // 不会被查询的数据库记录（虚拟机编号无效、按网卡保存的旧记录）
+)
+
+// VerifyIssue 完整性检查发现的一处问题
+type VerifyIssue struct {
+	Kind     string `json:"kind"`
+	Location string `json:"location"`        // 文件路径或数据表
+	Count    int64  `json:"count,omitempty"` // 涉及的行数
+	Repaired bool   `json:"repaired"`
+}
+
+// VerifyReport 完整性检查的结果
+type VerifyReport struct {
+	Records  int64         `json:"records"`           // 重新统计的记录数（与总记录计数器的口径相同）
+	Readable int64         `json:"readable"`          // 其中可以读取、参与用量计算的记录数
+	Cached   int64         `json:"cached"`            // 检查前计数器保存的记录数（-1 表示没有可比较的计数器）
+	Files    int           `json:"files,omitempty"`   // 检查的流量文件数（文件存储）
+	Rebuilt  int64         `json:"rebuilt,omitempty"` // 修复后重建的计数器
+	Issues   []VerifyIssue `json:"issues,omitempty"`
+}
+
+// Unrepaired 未修复的问题数
+func (r VerifyReport) Unrepaired() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Verifier 可检查并修复数据完整性的存储（monitor fsck）
+type Verifier interface {
+	// Verify 重新统计记录数并与计数器比较，检查损坏或不会被读取的数据；repair 时修复发现的问题并重建计数器
+	Verify(repair bool) (VerifyReport, error)
+}
+
+// Verify 持有排他锁检查所有流量文件：统计每个文件的行数、无法解析的行和不会被读取的记录，
+// repair 时把这两类行移到 quarantine/ 目录、补全末尾换行，释放锁后重建计数器
+func (s *FileStorage) Verify(repair bool) (VerifyReport, error) {
+	report := VerifyReport{Cached: -1}
+	if s.counterLost {
+		report.Issues = append(report.Issues, VerifyIssue{Kind: IssueCounterMissing, Location: s.recordCounter.counterFile, Repaired: true})
+	} else if cached, err := readCounterFile(s.recordCounter.counterFile); err == nil {
+		report.Cached = cached
+	} else {
+		report.Issues = append(report.Issues, VerifyIssue{Kind: IssueCounterMissing, Location: s.recordCounter.counterFile})
+	}
+
+	err := s.withLock(true, func() error {
+		entries, err := os.ReadDir(s.basePath)
+		if err != nil {
+			return fmt.Errorf("读取存储目录失败: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(entry.Name(), "vm_"):
+				if err := s.verifyDir(&report, entry.Name(), repair, readableRecord); err != nil {
+					return err
+				}
+			case strings.HasPrefix(entry.Name(), "node_"):
+				// 节点流量不计入总记录数，只检查损坏的行
+				if err := s.verifyDir(&report, entry.Name(), repair, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if report.Cached >= 0 && report.Cached != report.Records {
+		report.Issues = append(report.Issues, VerifyIssue{Kind: IssueCounterMismatch, Location: s.recordCounter.counterFile, Count: report.Cached - report.Records})
+	}
+	if !repair {
+		return report, nil
+	}
+
+	// 隔离的行已不在文件中，按修复后的数据重建计数器
+	rebuilt, err := s.RebuildRecordCount()
+	if err != nil {
+		return report, err
+	}
+	report.Rebuilt = rebuilt
+	for i := range report.Issues {
+		if report.Issues[i].Kind == IssueCounterMismatch || report.Issues[i].Kind == IssueCounterMissing {
+			report.Issues[i].Repaired = true
+		}
+	}
+	return report, nil
+}
+
+// verifyDir 检查目录中的流量文件（readable 为 nil 时目录中的记录不计入总记录数，只检查损坏的行）
+func (s *FileStorage) verifyDir(report *VerifyReport, name string, repair bool, readable func(line []byte) bool) error {
+	dir := filepath.Join(s.basePath, name)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("读取目录 %s 失败: %w", dir, err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), "traffic_") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取流量文件失败: %w", err)
+		}
+		report.Files++
+
+		if strings.HasSuffix(file.Name(), ".json") {
+			if readable == nil {
+				continue
+			}
+			var records []storedTrafficRecord
+			if err := json.Unmarshal(data, &records); err != nil {
+				report.Issues = append(report.Issues, VerifyIssue{Kind: IssueCorruptFile, Location: path})
+				continue
+			}
+			report.Records += int64(len(records))
+			for _, record := range records {
+				if isDefaultTrafficRecordInterface(record.NetworkInterface) {
+					report.Readable++
+				}
+			}
+			continue
+		}
+		if !strings.HasSuffix(file.Name(), ".jsonl") {
+			continue
+		}
+
+		var lines, corrupt, orphan int64
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			lines++
+			switch {
+			case !json.Valid(line):
+				corrupt++
+			case readable != nil && !readable(line):
+				orphan++
+			}
+		}
+		if readable != nil {
+			report.Records += lines
+			report.Readable += lines - corrupt - orphan
+		}
+		missingNewline := len(data) > 0 && data[len(data)-1] != '\n'
+
+		repaired := false
+		if repair && (corrupt > 0 || orphan > 0 || missingNewline) {
+			keep := json.Valid
+			if readable != nil {
+				keep = readable
+			}
+			if _, _, err := s.quarantineLines(path, keep); err != nil {
+				return err
+			}
+			repaired = true
+		}
+		if corrupt > 0 {
+			report.Issues = append(report.Issues, VerifyIssue{Kind: IssueCorruptLines, Location: path, Count: corrupt, Repaired: repaired})
+		}
+		if orphan > 0 {
+			report.Issues = append(report.Issues, VerifyIssue{Kind: IssueOrphanRecords, Location: path, Count: orphan, Repaired: repaired})
+		}
+		if missingNewline && corrupt == 0 {
+			// 最后一行写了一半时随损坏的行一起处理
+			report.Issues = append(report.Issues, VerifyIssue{Kind: IssueMissingNewline, Location: path, Repaired: repaired})
+		}
+	}
+	return nil
+}
+
+// readableRecord 行能否被流量记录的读取逻辑读到（readJSONLFile 跳过无法解析的行和按网卡保存的记录）
+func readableRecord(line []byte) bool {
+	var record storedTrafficRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return false
+	}
+	return isDefaultTrafficRecordInterface(record.NetworkInterface)
+}
+
+// Verify 重新执行 COUNT(*)，检查不会被查询的流量记录（虚拟机编号无效、按网卡保存的旧记录），repair 时删除这些记录并重建计数器
+// 数据库存储的计数器只保存在主程序内存中，不与重新统计的记录数比较
+func (s *DatabaseStorage) Verify(repair bool) (VerifyReport, error) {
+	report := VerifyReport{Cached: -1}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM traffic_records`).Scan(&report.Records); err != nil {
+		return report, fmt.Errorf("查询总记录数失败: %w", err)
+	}
+
+	where := `vmid <= 0 OR network_interface <> ?`
+	var orphans int64
+	if err := s.db.QueryRow(s.buildQuery(`SELECT COUNT(*) FROM traffic_records WHERE `+where, 1), defaultTrafficRecordInterface).Scan(&orphans); err != nil {
+		return report, fmt.Errorf("查询无效流量记录失败: %w", err)
+	}
+	report.Readable = report.Records - orphans
+
+	if orphans > 0 {
+		issue := VerifyIssue{Kind: IssueOrphanRows, Location: "traffic_records", Count: orphans}
+		if repair {
+			if _, err := s.db.Exec(s.buildQuery(`DELETE FROM traffic_records WHERE `+where, 1), defaultTrafficRecordInterface); err != nil {
+				return report, fmt.Errorf("删除无效流量记录失败: %w", err)
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	if !repair {
+		return report, nil
+	}
+
+	rebuilt, err := s.RebuildRecordCount()
+	if err != nil {
+		return report, err
+	}
+	report.Rebuilt = rebuilt
+	return report, nil
+}
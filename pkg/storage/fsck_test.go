@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestFileStorageVerify(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+
+	at := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: at.Add(time.Duration(i) * time.Minute), RXBytes: uint64(i)}); err != nil {
+			t.Fatalf("save traffic record: %v", err)
+		}
+	}
+	// 重新打开，计数器从文件加载
+	store.Close()
+	if store, err = NewFileStorage(dir); err != nil {
+		t.Fatalf("reopen file storage: %v", err)
+	}
+	defer store.Close()
+
+	// 写了一半的行、按网卡保存的旧记录，以及计数器之外直接写入的文件
+	path := filepath.Join(dir, "vm_101", "traffic_2026-01-02.jsonl")
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"vmid":101,"network_interface":"net0","timestamp":"2026-01-02T03:05:00Z"}` + "\n" + `{"vmid":101,"timestamp":"2026-01-02T03:06`)
+	f.Close()
+
+	report, err := store.Verify(false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.Records != 5 || report.Readable != 3 || report.Cached != 3 || report.Files != 1 || report.Unrepaired() != 3 {
+		t.Fatalf("Verify(false) = %+v, want 5 records (3 readable), cached 3 and 3 issues", report)
+	}
+	kinds := map[string]int64{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = issue.Count
+	}
+	if kinds[IssueCorruptLines] != 1 || kinds[IssueOrphanRecords] != 1 || kinds[IssueCounterMismatch] != -2 {
+		t.Fatalf("issues = %+v", report.Issues)
+	}
+	if records, _ := store.GetTrafficRecords(101, at, at.Add(time.Hour)); len(records) != 3 {
+		t.Fatalf("records = %d, want check without -repair to leave files alone", len(records))
+	}
+
+	report, err = store.Verify(true)
+	if err != nil || report.Unrepaired() != 0 || report.Rebuilt != 3 {
+		t.Fatalf("Verify(true) = %+v, %v, want all issues repaired and counter rebuilt to 3", report, err)
+	}
+	if count, _ := readCounterFile(filepath.Join(dir, ".record_count")); count != 3 {
+		t.Fatalf("counter file = %d, want 3", count)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "quarantine", "vm_101_traffic_2026-01-02.jsonl")); len(data) == 0 {
+		t.Fatal("quarantine file is empty, want the removed lines")
+	}
+
+	report, err = store.Verify(false)
+	if err != nil || len(report.Issues) != 0 || report.Records != 3 {
+		t.Fatalf("Verify after repair = %+v, %v, want a clean report", report, err)
+	}
+}
+
+func TestSQLiteVerify(t *testing.T) {
+	store, err := NewDatabaseStorage("sqlite3", filepath.Join(t.TempDir(), "fsck.db"), 1, 1, 0)
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer store.Close()
+
+	at := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: at})
+	store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: at.Add(time.Minute)})
+	if _, err := store.db.Exec(`INSERT INTO traffic_records (vmid, network_interface, timestamp, rx_bytes, tx_bytes) VALUES (101, 'net0', ?, 0, 0), (0, 'all', ?, 0, 0)`, at, at); err != nil {
+		t.Fatalf("insert orphan rows: %v", err)
+	}
+
+	report, err := store.Verify(false)
+	if err != nil || report.Records != 4 || report.Readable != 2 || len(report.Issues) != 1 || report.Issues[0].Count != 2 || report.Issues[0].Repaired {
+		t.Fatalf("Verify(false) = %+v, %v, want 2 orphan rows", report, err)
+	}
+
+	report, err = store.Verify(true)
+	if err != nil || report.Unrepaired() != 0 || report.Rebuilt != 2 {
+		t.Fatalf("Verify(true) = %+v, %v, want orphan rows deleted and counter rebuilt to 2", report, err)
+	}
+	if count, _ := store.GetTotalRecordCount(); count != 2 {
+		t.Fatalf("total count = %d, want 2", count)
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StorageHealth 存储后端的内部状态（用于诊断和系统统计）
+type StorageHealth struct {
+	Backend   string        `json:"backend"`              // file, mysql, postgres, sqlite3
+	Pool      *PoolStats    `json:"pool,omitempty"`       // 数据库连接池（仅数据库存储）
+	Files     *FileUsage    `json:"files,omitempty"`      // 数据文件（仅文件存储）
+	SizeBytes int64         `json:"size_bytes,omitempty"` // 占用的空间（字节，无法获取时为 0）
+	Counter   CounterStatus `json:"counter"`              // 总记录计数器
+
+	SchemaVersion int `json:"schema_version,omitempty"` // 数据库结构版本（仅数据库存储）
+
+	Replica *StorageHealth `json:"replica,omitempty"` // 双写存储的副本
+}
+
+// PoolStats 数据库连接池统计（对应 sql.DBStats）
+type PoolStats struct {
+	MaxOpen           int   `json:"max_open"`
+	Open              int   `json:"open"`
+	InUse             int   `json:"in_use"`
+	Idle              int   `json:"idle"`
+	WaitCount         int64 `json:"wait_count"`          // 等待空闲连接的累计次数
+	WaitMillis        int64 `json:"wait_ms"`             // 等待空闲连接的累计时间（毫秒）
+	MaxIdleClosed     int64 `json:"max_idle_closed"`     // 超过 max_idle_conns 而关闭的连接数
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"` // 超过 conn_max_lifetime 而关闭的连接数
+}
+
+// FileUsage 文件存储的数据文件统计
+type FileUsage struct {
+	VMs        int   `json:"vms"`         // 虚拟机目录数
+	JSONLFiles int   `json:"jsonl_files"` // 流量记录文件数（traffic_*.jsonl）
+	Files      int   `json:"files"`       // 存储目录下的文件总数
+	Bytes      int64 `json:"bytes"`       // 存储目录下的文件总大小
+}
+
+// CounterStatus 总记录计数器状态
+type CounterStatus struct {
+	Count        int64     `json:"count"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`    // 最近一次实际统计或加载的时间
+	NeedsRebuild bool      `json:"needs_rebuild"`           // 尚未统计（文件存储计数器文件缺失或损坏）
+	Rebuilding   bool      `json:"rebuilding"`              // 正在重新统计
+	LastRebuild  time.Time `json:"last_rebuild,omitempty"`  // 最近一次重新统计完成的时间
+	LastError    string    `json:"last_error,omitempty"`    // 最近一次重新统计的错误
+	LastErrorAt  time.Time `json:"last_error_at,omitempty"` // 最近一次重新统计失败的时间
+}
+
+// HealthReporter 可报告内部状态的存储
+type HealthReporter interface {
+	StorageHealth() StorageHealth
+}
+
+// StorageHealth 文件存储状态：数据文件数量和大小、计数器状态
+func (s *FileStorage) StorageHealth() StorageHealth {
+	health := StorageHealth{Backend: "file", Counter: s.recordCounter.status()}
+	usage, err := s.fileUsage()
+	if err == nil {
+		health.Files = &usage
+		health.SizeBytes = usage.Bytes
+	}
+	return health
+}
+
+// fileUsage 统计存储目录下的文件（不读取文件内容）
+func (s *FileStorage) fileUsage() (FileUsage, error) {
+	var usage FileUsage
+	err := filepath.WalkDir(s.basePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 统计期间被删除的文件（如清理）直接跳过
+			return nil
+		}
+		if d.IsDir() {
+			if path != s.basePath && filepath.Dir(path) == s.basePath && strings.HasPrefix(d.Name(), "vm_") {
+				usage.VMs++
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		if strings.HasPrefix(d.Name(), "traffic_") && strings.HasSuffix(d.Name(), ".jsonl") {
+			usage.JSONLFiles++
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// StorageHealth 数据库存储状态：连接池、数据库大小、计数器状态
+func (s *DatabaseStorage) StorageHealth() StorageHealth {
+	stats := s.db.Stats()
+	health := StorageHealth{
+		Backend: s.driverType,
+		Pool: &PoolStats{
+			MaxOpen:           stats.MaxOpenConnections,
+			Open:              stats.OpenConnections,
+			InUse:             stats.InUse,
+			Idle:              stats.Idle,
+			WaitCount:         stats.WaitCount,
+			WaitMillis:        stats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:     stats.MaxIdleClosed,
+			MaxLifetimeClosed: stats.MaxLifetimeClosed,
+		},
+		SizeBytes: s.databaseSize(),
+		Counter:   s.recordCounter.status(),
+
+		SchemaVersion: s.schemaVersion,
+	}
+	return health
+}
+
+// StorageHealth 双写存储状态：主存储的状态，副本的状态放在 replica 中
+func (r *ReplicatedStorage) StorageHealth() StorageHealth {
+	var health StorageHealth
+	if reporter, ok := As[HealthReporter](r.primary); ok {
+		health = reporter.StorageHealth()
+	}
+	if reporter, ok := As[HealthReporter](r.secondary); ok {
+		replica := reporter.StorageHealth()
+		health.Replica = &replica
+	}
+	return health
+}
+
+// status 计数器状态
+func (c *RecordCounter) status() CounterStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return CounterStatus{
+		Count:        c.cachedCount,
+		UpdatedAt:    c.lastUpdate,
+		NeedsRebuild: c.needsRebuild,
+		Rebuilding:   c.rebuilding,
+		LastRebuild:  c.lastRebuild,
+		LastError:    c.lastError,
+		LastErrorAt:  c.lastErrorAt,
+	}
+}
+
+// beginRebuild 标记开始重新统计
+func (c *RecordCounter) beginRebuild() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebuilding = true
+}
+
+// endRebuild 记录重新统计的结果
+func (c *RecordCounter) endRebuild(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rebuilding = false
+	if err != nil {
+		c.lastError = err.Error()
+		c.lastErrorAt = time.Now()
+		return
+	}
+	c.lastRebuild = time.Now()
+	c.lastError = ""
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestStorageHealth(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("create file storage: %v", err)
+	}
+	defer files.Close()
+	db, err := NewDatabaseStorage("sqlite3", filepath.Join(t.TempDir(), "health.db"), 2, 1, 0)
+	if err != nil {
+		t.Fatalf("create sqlite storage: %v", err)
+	}
+	defer db.Close()
+
+	// 等待新建文件存储时的后台计数器重建完成
+	for files.recordCounter.status().NeedsRebuild {
+		time.Sleep(time.Millisecond)
+	}
+
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	for _, vmid := range []int{100, 101} {
+		for day := 0; day < 2; day++ {
+			record := models.TrafficRecord{VMID: vmid, Timestamp: baseTime.AddDate(0, 0, day)}
+			files.SaveTrafficRecord(record)
+			db.SaveTrafficRecord(record)
+		}
+	}
+	if _, err := files.RebuildRecordCount(); err != nil {
+		t.Fatalf("rebuild file counter: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "vm_100", "traffic_2026-01-01.json"), []byte("[]"), 0644)
+
+	health := files.StorageHealth()
+	if health.Backend != "file" || health.Files == nil || health.Files.VMs != 2 || health.Files.JSONLFiles != 4 {
+		t.Fatalf("file health = %+v, files = %+v", health, health.Files)
+	}
+	if health.Files.Bytes == 0 || health.SizeBytes != health.Files.Bytes {
+		t.Fatalf("file bytes = %d, size = %d", health.Files.Bytes, health.SizeBytes)
+	}
+	if health.Counter.Count != 4 || health.Counter.Rebuilding || health.Counter.LastRebuild.IsZero() {
+		t.Fatalf("file counter = %+v", health.Counter)
+	}
+
+	if _, err := db.RebuildRecordCount(); err != nil {
+		t.Fatalf("rebuild db counter: %v", err)
+	}
+	health = db.StorageHealth()
+	if health.Backend != "sqlite3" || health.Pool == nil || health.Pool.MaxOpen != 2 || health.SizeBytes == 0 {
+		t.Fatalf("db health = %+v, pool = %+v", health, health.Pool)
+	}
+	if health.Counter.Count != 4 || health.Counter.LastError != "" {
+		t.Fatalf("db counter = %+v", health.Counter)
+	}
+
+	// 双写存储报告主存储和副本的状态
+	reporter, ok := As[HealthReporter](NewReplicatedStorage(db, files))
+	if !ok {
+		t.Fatal("replicated storage does not report health")
+	}
+	if health := reporter.StorageHealth(); health.Backend != "sqlite3" || health.Replica == nil || health.Replica.Backend != "file" {
+		t.Fatalf("replicated health = %+v", health)
+	}
+}
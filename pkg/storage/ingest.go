@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// FilterDuplicates 去掉存储中已有和批次内重复的记录（同一虚拟机同一秒的记录视为重复），返回新记录和重复的数量
+// 外部来源重发同一批记录（如远程写入超时后重试）时不会重复计算流量
+func FilterDuplicates(s Interface, records []models.TrafficRecord) ([]models.TrafficRecord, int, error) {
+	type key struct {
+		vmid int
+		unix int64
+	}
+
+	// 按虚拟机查询批次时间范围内已有的记录
+	ranges := make(map[int][2]time.Time)
+	for _, record := range records {
+		r, ok := ranges[record.VMID]
+		if !ok || record.Timestamp.Before(r[0]) {
+			r[0] = record.Timestamp
+		}
+		if !ok || record.Timestamp.After(r[1]) {
+			r[1] = record.Timestamp
+		}
+		ranges[record.VMID] = r
+	}
+
+	seen := make(map[key]bool)
+	for vmid, r := range ranges {
+		existing, err := s.GetTrafficRecords(vmid, r[0].Add(-time.Second), r[1].Add(time.Second))
+		if err != nil {
+			return nil, 0, fmt.Errorf("读取虚拟机 %d 的已有记录失败: %w", vmid, err)
+		}
+		for _, record := range existing {
+			seen[key{vmid, record.Timestamp.Unix()}] = true
+		}
+	}
+
+	fresh := make([]models.TrafficRecord, 0, len(records))
+	for _, record := range records {
+		k := key{record.VMID, record.Timestamp.Unix()}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		fresh = append(fresh, record)
+	}
+	return fresh, len(records) - len(fresh), nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestFilterDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]*models.StorageConfig{
+		"file":   {Type: "file", FilePath: filepath.Join(dir, "file")},
+		"sqlite": {Type: "sqlite", DSN: filepath.Join(dir, "pve_traffic.db"), MaxOpenConns: 1, MaxIdleConns: 1},
+	}
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for name, config := range configs {
+		store, err := NewStorageFromConfig(config)
+		if err != nil {
+			t.Fatalf("%s: create storage: %v", name, err)
+		}
+		defer store.Close()
+
+		for i := 0; i < 2; i++ {
+			if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: base.Add(time.Duration(i) * time.Minute), RXBytes: 1, TotalBytes: 1}); err != nil {
+				t.Fatalf("%s: save: %v", name, err)
+			}
+		}
+
+		batch := []models.TrafficRecord{
+			{VMID: 100, Timestamp: base.Add(time.Minute)},                          // 已保存
+			{VMID: 100, Timestamp: base.Add(2 * time.Minute)},                      // 新记录
+			{VMID: 100, Timestamp: base.Add(2*time.Minute + 300*time.Millisecond)}, // 批次内同一秒
+			{VMID: 101, Timestamp: base.Add(time.Minute)},                          // 其他虚拟机
+		}
+		fresh, duplicates, err := FilterDuplicates(store, batch)
+		if err != nil {
+			t.Fatalf("%s: FilterDuplicates() error = %v", name, err)
+		}
+		if duplicates != 2 || len(fresh) != 2 || fresh[0].VMID != 100 || fresh[1].VMID != 101 {
+			t.Fatalf("%s: FilterDuplicates() = %+v, %d duplicates, want the new VM 100 record and the VM 101 record", name, fresh, duplicates)
+		}
+	}
+}
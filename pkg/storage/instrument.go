@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// QueryBuckets 存储操作耗时直方图的桶上限（秒，最后一个桶为 +Inf）
+var QueryBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// QueryMethodStats 一种存储操作的耗时统计
+type QueryMethodStats struct {
+	Method     string   `json:"method"`
+	Count      uint64   `json:"count"`
+	Errors     uint64   `json:"errors"`
+	Slow       uint64   `json:"slow"`        // 超过慢查询阈值的次数
+	SumSeconds float64  `json:"sum_seconds"` // 累计耗时
+	AvgMillis  float64  `json:"avg_ms"`
+	MaxMillis  float64  `json:"max_ms"`
+	Buckets    []uint64 `json:"buckets"` // 各桶的累计次数（对应 QueryBuckets，最后一个为 +Inf）
+}
+
+// QueryStats 存储操作统计
+type QueryStats struct {
+	SlowThresholdMillis int64              `json:"slow_threshold_ms"` // 慢查询阈值（0 表示不记录）
+	Methods             []QueryMethodStats `json:"methods"`           // 按操作名称排序
+}
+
+// QueryReporter 记录操作耗时的存储（用于 /metrics 和系统统计）
+type QueryReporter interface {
+	QueryStats() QueryStats
+}
+
+// queryHistogram 一种存储操作的耗时直方图
+type queryHistogram struct {
+	count   uint64
+	errors  uint64
+	slow    uint64
+	sum     time.Duration
+	max     time.Duration
+	buckets []uint64 // 每个桶的次数（非累计）
+}
+
+// InstrumentedStorage 记录每种存储操作耗时的包装器，超过阈值的操作记录慢查询日志
+type InstrumentedStorage struct {
+	Interface
+	slowThreshold atomic.Int64 // 纳秒，0 表示不记录
+
+	mu      sync.Mutex
+	methods map[string]*queryHistogram
+}
+
+// NewInstrumentedStorage 创建记录操作耗时的存储（slowThreshold <= 0 时不记录慢查询日志）
+func NewInstrumentedStorage(backend Interface, slowThreshold time.Duration) *InstrumentedStorage {
+	s := &InstrumentedStorage{
+		Interface: backend,
+		methods:   make(map[string]*queryHistogram),
+	}
+	s.SetSlowThreshold(slowThreshold)
+	return s
+}
+
+// SetSlowThreshold 修改慢查询阈值（配置重新加载时调用）
+func (s *InstrumentedStorage) SetSlowThreshold(threshold time.Duration) {
+	if threshold < 0 {
+		threshold = 0
+	}
+	s.slowThreshold.Store(int64(threshold))
+}
+
+// Unwrap 返回被包装的存储
+func (s *InstrumentedStorage) Unwrap() Interface {
+	return s.Interface
+}
+
+// observe 记录一次操作的耗时，超过阈值时记录慢查询日志
+// 在 defer 中调用：start 和 errp 在 defer 时求值，*errp 为操作返回的错误；args 只在记录日志时调用
+func (s *InstrumentedStorage) observe(method string, start time.Time, errp *error, args func() string) {
+	elapsed := time.Since(start)
+	err := *errp
+	threshold := time.Duration(s.slowThreshold.Load())
+	slow := threshold > 0 && elapsed >= threshold
+
+	s.mu.Lock()
+	h, ok := s.methods[method]
+	if !ok {
+		h = &queryHistogram{buckets: make([]uint64, len(QueryBuckets)+1)}
+		s.methods[method] = h
+	}
+	h.count++
+	h.sum += elapsed
+	if elapsed > h.max {
+		h.max = elapsed
+	}
+	if err != nil {
+		h.errors++
+	}
+	if slow {
+		h.slow++
+	}
+	h.buckets[sort.SearchFloat64s(QueryBuckets, elapsed.Seconds())]++
+	s.mu.Unlock()
+
+	if slow {
+		if err != nil {
+			log.Printf("慢查询: %s(%s) 耗时 %s，错误: %v", method, args(), elapsed.Truncate(time.Millisecond), err)
+		} else {
+			log.Printf("慢查询: %s(%s) 耗时 %s", method, args(), elapsed.Truncate(time.Millisecond))
+		}
+	}
+}
+
+// QueryStats 各种存储操作的耗时统计
+func (s *InstrumentedStorage) QueryStats() QueryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := QueryStats{
+		SlowThresholdMillis: time.Duration(s.slowThreshold.Load()).Milliseconds(),
+		Methods:             make([]QueryMethodStats, 0, len(s.methods)),
+	}
+	for method, h := range s.methods {
+		m := QueryMethodStats{
+			Method:     method,
+			Count:      h.count,
+			Errors:     h.errors,
+			Slow:       h.slow,
+			SumSeconds: h.sum.Seconds(),
+			MaxMillis:  float64(h.max.Microseconds()) / 1000,
+			Buckets:    make([]uint64, len(h.buckets)),
+		}
+		if h.count > 0 {
+			m.AvgMillis = float64(h.sum.Microseconds()) / 1000 / float64(h.count)
+		}
+		var cumulative uint64
+		for i, n := range h.buckets {
+			cumulative += n
+			m.Buckets[i] = cumulative
+		}
+		stats.Methods = append(stats.Methods, m)
+	}
+	sort.Slice(stats.Methods, func(i, j int) bool { return stats.Methods[i].Method < stats.Methods[j].Method })
+	return stats
+}
+
+// formatRange 慢查询日志中的时间范围参数
+func formatRange(vmid int, startTime, endTime time.Time) func() string {
+	return func() string {
+		return fmt.Sprintf("vmid=%d, %s ~ %s", vmid, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+	}
+}
+
+// SaveTrafficRecords 批量保存流量记录
+func (s *InstrumentedStorage) SaveTrafficRecords(records []models.TrafficRecord) (err error) {
+	defer s.observe("SaveTrafficRecords", time.Now(), &err, func() string { return fmt.Sprintf("records=%d", len(records)) })
+	return SaveTrafficRecords(s.Interface, records)
+}
+
+// SaveTrafficRecord 保存流量记录
+func (s *InstrumentedStorage) SaveTrafficRecord(record models.TrafficRecord) (err error) {
+	defer s.observe("SaveTrafficRecord", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d", record.VMID) })
+	return s.Interface.SaveTrafficRecord(record)
+}
+
+// GetTrafficRecords 获取流量记录
+func (s *InstrumentedStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time) (records []models.TrafficRecord, err error) {
+	defer s.observe("GetTrafficRecords", time.Now(), &err, formatRange(vmid, startTime, endTime))
+	return s.Interface.GetTrafficRecords(vmid, startTime, endTime)
+}
+
+// CalculateTrafficStats 计算流量统计
+func (s *InstrumentedStorage) CalculateTrafficStats(vmid int, period string) (stats *models.TrafficStats, err error) {
+	defer s.observe("CalculateTrafficStats", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d, period=%s", vmid, period) })
+	return s.Interface.CalculateTrafficStats(vmid, period)
+}
+
+// CalculateTrafficStatsWithTime 使用指定时间计算流量统计
+func (s *InstrumentedStorage) CalculateTrafficStatsWithTime(vmid int, period string, creationTime time.Time, useCreationTime bool) (stats *models.TrafficStats, err error) {
+	defer s.observe("CalculateTrafficStatsWithTime", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d, period=%s", vmid, period) })
+	return s.Interface.CalculateTrafficStatsWithTime(vmid, period, creationTime, useCreationTime)
+}
+
+// CalculateTrafficStatsWithDirection 使用指定方向和时间计算流量统计
+func (s *InstrumentedStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (stats *models.TrafficStats, err error) {
+	defer s.observe("CalculateTrafficStatsWithDirection", time.Now(), &err, func() string {
+		return fmt.Sprintf("vmid=%d, period=%s, direction=%s", vmid, period, direction)
+	})
+	return s.Interface.CalculateTrafficStatsWithDirection(vmid, period, creationTime, useCreationTime, direction)
+}
+
+// CalculateTrafficStatsWithTimeRange 使用自定义时间范围计算流量统计
+func (s *InstrumentedStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime, endTime time.Time, direction string) (stats *models.TrafficStats, err error) {
+	defer s.observe("CalculateTrafficStatsWithTimeRange", time.Now(), &err, formatRange(vmid, startTime, endTime))
+	return s.Interface.CalculateTrafficStatsWithTimeRange(vmid, startTime, endTime, direction)
+}
+
+// SaveActionLog 保存操作日志
+func (s *InstrumentedStorage) SaveActionLog(entry models.ActionLog) (err error) {
+	defer s.observe("SaveActionLog", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d", entry.VMID) })
+	return s.Interface.SaveActionLog(entry)
+}
+
+// GetActionLogs 获取操作日志
+func (s *InstrumentedStorage) GetActionLogs(startTime, endTime time.Time) (logs []models.ActionLog, err error) {
+	defer s.observe("GetActionLogs", time.Now(), &err, formatRange(0, startTime, endTime))
+	return s.Interface.GetActionLogs(startTime, endTime)
+}
+
+// SaveVMState 保存虚拟机状态
+func (s *InstrumentedStorage) SaveVMState(vmid int, state map[string]interface{}) (err error) {
+	defer s.observe("SaveVMState", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d", vmid) })
+	return s.Interface.SaveVMState(vmid, state)
+}
+
+// LoadVMState 加载虚拟机状态
+func (s *InstrumentedStorage) LoadVMState(vmid int) (state map[string]interface{}, err error) {
+	defer s.observe("LoadVMState", time.Now(), &err, func() string { return fmt.Sprintf("vmid=%d", vmid) })
+	return s.Interface.LoadVMState(vmid)
+}
+
+// CleanupOldData 清理旧数据
+func (s *InstrumentedStorage) CleanupOldData(retentionDays int) (err error) {
+	defer s.observe("CleanupOldData", time.Now(), &err, func() string { return fmt.Sprintf("retention_days=%d", retentionDays) })
+	return s.Interface.CleanupOldData(retentionDays)
+}
+
+// GetTotalRecordCount 获取总采样点数
+func (s *InstrumentedStorage) GetTotalRecordCount() (count int64, err error) {
+	defer s.observe("GetTotalRecordCount", time.Now(), &err, func() string { return "" })
+	return s.Interface.GetTotalRecordCount()
+}
+
+// DeleteRecordsInRange 删除指定时间范围内的记录
+func (s *InstrumentedStorage) DeleteRecordsInRange(vmid int, startTime, endTime time.Time) (deleted int64, err error) {
+	defer s.observe("DeleteRecordsInRange", time.Now(), &err, formatRange(vmid, startTime, endTime))
+	return s.Interface.DeleteRecordsInRange(vmid, startTime, endTime)
+}
+
+// CountRecordsInRange 统计指定时间范围内的记录数
+func (s *InstrumentedStorage) CountRecordsInRange(vmid int, startTime, endTime time.Time) (count int64, err error) {
+	defer s.observe("CountRecordsInRange", time.Now(), &err, formatRange(vmid, startTime, endTime))
+	return s.Interface.CountRecordsInRange(vmid, startTime, endTime)
+}
+
+// DeleteRecordsBefore 删除指定日期之前的所有记录
+func (s *InstrumentedStorage) DeleteRecordsBefore(beforeTime time.Time) (deleted int64, err error) {
+	defer s.observe("DeleteRecordsBefore", time.Now(), &err, func() string { return beforeTime.Format(time.RFC3339) })
+	return s.Interface.DeleteRecordsBefore(beforeTime)
+}
+
+// CountRecordsBefore 统计指定日期之前的记录数
+func (s *InstrumentedStorage) CountRecordsBefore(beforeTime time.Time) (count int64, err error) {
+	defer s.observe("CountRecordsBefore", time.Now(), &err, func() string { return beforeTime.Format(time.RFC3339) })
+	return s.Interface.CountRecordsBefore(beforeTime)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestInstrumentedStorageRecordsQueries(t *testing.T) {
+	backend := &flakyStorage{}
+	store := NewInstrumentedStorage(backend, time.Nanosecond)
+
+	record := models.TrafficRecord{VMID: 100, Timestamp: time.Now()}
+	store.SaveTrafficRecord(record)
+	store.SaveTrafficRecord(record)
+	backend.fail = true
+	if err := store.SaveTrafficRecord(record); err == nil {
+		t.Fatal("SaveTrafficRecord() error = nil, want backend error")
+	}
+
+	// 包装在其他存储中时仍可找到
+	spooled, err := NewSpoolStorage(store, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("create spool: %v", err)
+	}
+	defer spooled.Close()
+	reporter, ok := As[QueryReporter](spooled)
+	if !ok {
+		t.Fatal("As[QueryReporter]() not found through spool")
+	}
+
+	stats := reporter.QueryStats()
+	if stats.SlowThresholdMillis != 0 || len(stats.Methods) != 1 {
+		t.Fatalf("QueryStats() = %+v, want one method", stats)
+	}
+	m := stats.Methods[0]
+	if m.Method != "SaveTrafficRecord" || m.Count != 3 || m.Errors != 1 || m.Slow != 3 {
+		t.Fatalf("method stats = %+v, want 3 calls, 1 error, 3 slow", m)
+	}
+	if len(m.Buckets) != len(QueryBuckets)+1 || m.Buckets[len(m.Buckets)-1] != 3 {
+		t.Fatalf("buckets = %v, want cumulative counts ending in 3", m.Buckets)
+	}
+
+	store.SetSlowThreshold(time.Hour)
+	backend.fail = false
+	store.SaveTrafficRecord(record)
+	if m := store.QueryStats().Methods[0]; m.Slow != 3 || m.Count != 4 {
+		t.Fatalf("after raising threshold: %+v, want no new slow queries", m)
+	}
+}
@@ -61,3 +61,112 @@ type Interface interface {
 	// Close 关闭存储连接
 	Close() error
 }
+
+// BatchSaver 可一次保存多条流量记录的存储（导入、接收推送、重放本地缓冲时使用，通过 SaveTrafficRecords 调用）
+// 包装器实现时需要保持自身的语义（如写入失败时缓冲、转发），内部使用 SaveTrafficRecords 调用被包装的存储
+type BatchSaver interface {
+	// SaveTrafficRecords 保存多条流量记录（数据库存储在一个事务中写入，全部成功或全部失败）
+	SaveTrafficRecords(records []models.TrafficRecord) error
+}
+
+// SaveTrafficRecords 保存多条流量记录：存储实现了 BatchSaver 时批量写入，否则逐条写入
+// 只检查 s 本身而不查找包装链，避免绕过包装器
+func SaveTrafficRecords(s Interface, records []models.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if saver, ok := s.(BatchSaver); ok {
+		return saver.SaveTrafficRecords(records)
+	}
+	for _, record := range records {
+		if err := s.SaveTrafficRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterRebuilder 可按实际数据重建总记录计数器的存储（手动修改或导入数据后使用）
+type CounterRebuilder interface {
+	RebuildRecordCount() (int64, error)
+}
+
+// EarliestRecorder 可直接查询虚拟机最早采样时间的存储（推断创建时间时使用）
+type EarliestRecorder interface {
+	// EarliestRecordTime 获取虚拟机最早的流量记录时间（没有记录时返回零值）
+	EarliestRecordTime(vmid int) (time.Time, error)
+}
+
+// RecordLister 可列出有流量记录的虚拟机的存储（归档时使用）
+type RecordLister interface {
+	// RecordVMIDs [startTime, endTime) 内有流量记录的虚拟机（升序）
+	RecordVMIDs(startTime, endTime time.Time) ([]int, error)
+}
+
+// NodeRecorder 可保存 PVE 节点自身网卡流量的存储（与虚拟机流量分开存放，不计入总采样点数）
+type NodeRecorder interface {
+	// SaveNodeTrafficRecord 保存节点的累计流量记录（VMID 为 0）
+	SaveNodeTrafficRecord(node string, record models.TrafficRecord) error
+
+	// GetNodeTrafficRecords 获取节点在时间范围内的流量记录（按时间升序）
+	GetNodeTrafficRecords(node string, startTime, endTime time.Time) ([]models.TrafficRecord, error)
+}
+
+// DataCleaner 可删除操作日志和虚拟机状态的存储（流量记录由 CleanupOldData 和 DeleteRecords* 删除）
+type DataCleaner interface {
+	// DeleteActionLogs 删除 [startTime, endTime) 内的操作日志（vmid=0 表示所有虚拟机，startTime 为零值表示不限开始时间）
+	// dryRun 时只统计不删除，返回删除（或将删除）的数量
+	DeleteActionLogs(vmid int, startTime, endTime time.Time, dryRun bool) (int64, error)
+
+	// DeleteVMStates 删除 match 返回 true 的虚拟机状态（参数为 VMID 和最后更新时间），dryRun 时只统计
+	DeleteVMStates(match func(vmid int, updatedAt time.Time) bool, dryRun bool) (int64, error)
+}
+
+// MetadataStore 可保存程序自身运行信息（如上次清理结果）的存储
+type MetadataStore interface {
+	// SaveMetadata 保存指定名称的数据（覆盖已有数据）
+	SaveMetadata(name string, data []byte) error
+
+	// LoadMetadata 读取指定名称的数据（不存在时返回 nil）
+	LoadMetadata(name string) ([]byte, error)
+}
+
+// LeaseStore 支持租约的存储（多个实例共用同一存储时选出主实例）
+// 获取和续期必须是原子的：同一时刻只有一个持有者能成功
+type LeaseStore interface {
+	// AcquireLease 租约不存在、已过期或已由 holder 持有时，把租约设为 holder 持有到 now+ttl 并返回 true
+	AcquireLease(name, holder string, ttl time.Duration, now time.Time) (bool, error)
+
+	// ReleaseLease 释放 holder 持有的租约（由其他实例持有时不做任何事）
+	ReleaseLease(name, holder string) error
+
+	// GetLease 读取租约（不存在时返回 nil）
+	GetLease(name string) (*Lease, error)
+}
+
+// Compactor 可在大量删除后回收空间的存储
+type Compactor interface {
+	// Compact 回收已删除数据占用的空间，每完成一步调用 progress（可为 nil）
+	Compact(progress func(CompactProgress)) (CompactResult, error)
+}
+
+// Wrapper 包装其他存储的存储（如本地缓冲）
+type Wrapper interface {
+	Unwrap() Interface
+}
+
+// As 在存储包装链中查找实现了 T 的存储（如 SpoolReporter、ReplicationReporter）
+func As[T any](s Interface) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		w, ok := s.(Wrapper)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
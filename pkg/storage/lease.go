@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLeaseUnsupported 存储不支持租约
+var ErrLeaseUnsupported = errors.New("存储不支持租约（主备模式）")
+
+// LeaderLease 主备模式的主实例租约名称
+const LeaderLease = "leader"
+
+// Lease 租约（主备模式下持有租约的实例为主实例）
+type Lease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Active 租约在 now 时是否仍有效
+func (l *Lease) Active(now time.Time) bool {
+	return l != nil && now.Before(l.ExpiresAt)
+}
+
+// leaseMetadataName 文件存储中保存租约的运行信息名称
+func leaseMetadataName(name string) string {
+	return "lease_" + name
+}
+
+// AcquireLease 获取或续期租约（存储不支持时返回 ErrLeaseUnsupported）
+func AcquireLease(s Interface, name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	store, ok := As[LeaseStore](s)
+	if !ok {
+		return false, ErrLeaseUnsupported
+	}
+	return store.AcquireLease(name, holder, ttl, now)
+}
+
+// ReleaseLease 释放租约（存储不支持时忽略）
+func ReleaseLease(s Interface, name, holder string) error {
+	if store, ok := As[LeaseStore](s); ok {
+		return store.ReleaseLease(name, holder)
+	}
+	return nil
+}
+
+// GetLease 读取租约（没有租约或存储不支持时返回 nil）
+func GetLease(s Interface, name string) (*Lease, error) {
+	if store, ok := As[LeaseStore](s); ok {
+		return store.GetLease(name)
+	}
+	return nil, nil
+}
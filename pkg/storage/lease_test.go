@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestAcquireLease(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]*models.StorageConfig{
+		"file":   {Type: "file", FilePath: filepath.Join(dir, "file")},
+		"sqlite": {Type: "sqlite", DSN: filepath.Join(dir, "pve_traffic.db"), MaxOpenConns: 1, MaxIdleConns: 1},
+	}
+
+	for name, config := range configs {
+		store, err := NewStorageFromConfig(config)
+		if err != nil {
+			t.Fatalf("%s: create storage: %v", name, err)
+		}
+		defer store.Close()
+
+		now := time.Now()
+		steps := []struct {
+			holder string
+			at     time.Time
+			want   bool
+		}{
+			{"pve1", now, true},
+			{"pve2", now.Add(10 * time.Second), false}, // pve1 持有
+			{"pve1", now.Add(20 * time.Second), true},  // 续期到 +50s
+			{"pve2", now.Add(40 * time.Second), false}, // 续期后仍有效
+			{"pve2", now.Add(60 * time.Second), true},  // 过期后接管
+			{"pve1", now.Add(70 * time.Second), false},
+		}
+		for i, step := range steps {
+			got, err := AcquireLease(store, "leader", step.holder, 30*time.Second, step.at)
+			if err != nil || got != step.want {
+				t.Fatalf("%s: step %d AcquireLease(%s) = %v, %v, want %v", name, i, step.holder, got, err, step.want)
+			}
+		}
+
+		lease, err := GetLease(store, "leader")
+		if err != nil || lease == nil || lease.Holder != "pve2" || !lease.Active(now.Add(80*time.Second)) {
+			t.Fatalf("%s: GetLease() = %+v, %v, want active lease held by pve2", name, lease, err)
+		}
+
+		// 其他实例不能释放，持有者释放后立即可被获取
+		ReleaseLease(store, "leader", "pve1")
+		if got, _ := AcquireLease(store, "leader", "pve1", 30*time.Second, now.Add(75*time.Second)); got {
+			t.Fatalf("%s: AcquireLease() after release by non-holder = true, want false", name)
+		}
+		if err := ReleaseLease(store, "leader", "pve2"); err != nil {
+			t.Fatalf("%s: ReleaseLease() error = %v", name, err)
+		}
+		if got, err := AcquireLease(store, "leader", "pve1", 30*time.Second, now.Add(75*time.Second)); err != nil || !got {
+			t.Fatalf("%s: AcquireLease() after release = %v, %v, want true", name, got, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFileName 文件存储目录中的锁文件
+const lockFileName = ".lock"
+
+// lock 获取文件存储的进程间锁（flock），返回解锁函数
+// 追加记录使用共享锁，重写或删除数据文件使用排他锁，避免命令行清除数据与主程序追加同时修改同一文件；
+// 每次加锁单独打开锁文件，同一进程内的不同 goroutine 之间同样互斥
+func (s *FileStorage) lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.basePath, lockFileName), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开存储锁文件失败: %w", err)
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("获取存储锁失败: %w", err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// withLock 持有存储锁执行 fn
+func (s *FileStorage) withLock(exclusive bool, fn func() error) error {
+	unlock, err := s.lock(exclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
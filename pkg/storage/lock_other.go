@@ -0,0 +1,42 @@
+//go:build !unix
+
+package storage
+
+import (
+	"os"
+	"sync"
+)
+
+// 其他平台没有 flock：只在进程内按锁文件路径互斥，不防止命令行与主程序同时修改（PVE 主机运行在 Linux 上）
+var (
+	fileLocks sync.Map // 锁文件路径 -> *sync.RWMutex
+	heldLocks sync.Map // *os.File -> 是否为排他锁
+)
+
+// lockFile 获取锁文件路径对应的进程内读写锁（阻塞等待）
+func lockFile(f *os.File, exclusive bool) error {
+	value, _ := fileLocks.LoadOrStore(f.Name(), &sync.RWMutex{})
+	mu := value.(*sync.RWMutex)
+	if exclusive {
+		mu.Lock()
+	} else {
+		mu.RLock()
+	}
+	heldLocks.Store(f, exclusive)
+	return nil
+}
+
+// unlockFile 释放 lockFile 获取的锁
+func unlockFile(f *os.File) {
+	exclusive, ok := heldLocks.LoadAndDelete(f)
+	if !ok {
+		return
+	}
+	value, _ := fileLocks.Load(f.Name())
+	mu := value.(*sync.RWMutex)
+	if exclusive.(bool) {
+		mu.Unlock()
+	} else {
+		mu.RUnlock()
+	}
+}
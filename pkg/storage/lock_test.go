@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestFileStorageLock(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	// 持有排他锁（如命令行正在重写文件）时，追加记录需要等待
+	unlock, err := s.lock(true)
+	if err != nil {
+		t.Fatalf("lock(true) error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: time.Now(), RXBytes: 1})
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("SaveTrafficRecord() returned %v while exclusive lock is held, want it to wait", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("SaveTrafficRecord() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SaveTrafficRecord() still waiting after unlock")
+	}
+
+	// 共享锁之间不互斥
+	unlockA, _ := s.lock(false)
+	unlockB, err := s.lock(false)
+	if err != nil {
+		t.Fatalf("lock(false) while shared lock held error = %v", err)
+	}
+	unlockA()
+	unlockB()
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ErrMetadataUnsupported 存储不支持保存运行信息
+var ErrMetadataUnsupported = errors.New("存储不支持保存运行信息")
+
+// metadataLastCleanup 上次旧数据清理结果的名称
+const metadataLastCleanup = "last_cleanup"
+
+// metadataMaintenance 维护模式状态的名称
+const metadataMaintenance = "maintenance"
+
+// SaveMetadata 以 JSON 保存运行信息（存储不支持时返回 ErrMetadataUnsupported）
+func SaveMetadata(s Interface, name string, v interface{}) error {
+	store, ok := As[MetadataStore](s)
+	if !ok {
+		return ErrMetadataUnsupported
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化运行信息失败: %w", err)
+	}
+	return store.SaveMetadata(name, data)
+}
+
+// LoadMetadata 读取 JSON 运行信息到 v，不存在或存储不支持时返回 false
+func LoadMetadata(s Interface, name string, v interface{}) (bool, error) {
+	store, ok := As[MetadataStore](s)
+	if !ok {
+		return false, nil
+	}
+
+	data, err := store.LoadMetadata(name)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("解析运行信息 %s 失败: %w", name, err)
+	}
+	return true, nil
+}
+
+// SaveLastCleanup 保存最近一次旧数据清理的结果
+func SaveLastCleanup(s Interface, result models.CleanupResult) error {
+	return SaveMetadata(s, metadataLastCleanup, result)
+}
+
+// LastCleanup 读取最近一次旧数据清理的结果（没有记录时返回 nil）
+func LastCleanup(s Interface) (*models.CleanupResult, error) {
+	var result models.CleanupResult
+	found, err := LoadMetadata(s, metadataLastCleanup, &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SaveMaintenance 保存维护模式状态
+func SaveMaintenance(s Interface, state models.Maintenance) error {
+	return SaveMetadata(s, metadataMaintenance, state)
+}
+
+// LoadMaintenance 读取维护模式状态（没有记录或存储不支持时为未启用）
+func LoadMaintenance(s Interface) (models.Maintenance, error) {
+	var state models.Maintenance
+	_, err := LoadMetadata(s, metadataMaintenance, &state)
+	return state, err
+}
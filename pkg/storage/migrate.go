@@ -0,0 +1,434 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// schemaVersionKey 数据库结构版本在 metadata 表中的名称
+const schemaVersionKey = "schema_version"
+
+// 流量记录表分区（PostgreSQL/MySQL 按月分区）
+const (
+	partitionAhead   = 3       // 提前创建的月份数
+	partitionMaxBack = 10 * 12 // 迁移时最多为多少个月之前的数据创建分区（更早的数据放入默认分区或第一个分区）
+	partitionPrefix  = "traffic_records_p"
+	partitionLayout  = "200601"
+)
+
+// sqlExecer 执行迁移语句（*sql.DB 或 *sql.Tx）
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// schemaMigration 一次数据库结构迁移（按版本号顺序执行，每次迁移成功后记录版本）
+type schemaMigration struct {
+	version     int
+	description string
+	apply       func(s *DatabaseStorage, db sqlExecer) error
+}
+
+// schemaMigrations 数据库结构迁移（只能追加，不能修改已发布的迁移）
+var schemaMigrations = []schemaMigration{
+	{1, "流量记录表使用 (vmid, network_interface, timestamp) 主键并按月分区，去掉冗余的 id 和 total_bytes 字段", migrateTrafficRecordsLayout},
+	{2, "流量记录表添加客户机代理报告的 IPv4/IPv6 累计字节字段", migrateIPSplitColumns},
+	{3, "流量记录表添加备份标记字段", migrateBackupColumn},
+	{4, "操作日志表添加被取代的规则字段", migrateOverriddenRulesColumn},
+}
+
+// freshSchemaVersion 新建的数据库中 createTrafficRecordsTable 创建的表结构版本，之后的迁移照常执行
+const freshSchemaVersion = 1
+
+// latestSchemaVersion 当前程序的数据库结构版本
+func latestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// migrateSchema 执行尚未执行的迁移（新建的数据库从 freshSchemaVersion 开始）
+func (s *DatabaseStorage) migrateSchema(fresh bool) error {
+	latest := latestSchemaVersion()
+	version := freshSchemaVersion
+	if fresh {
+		if err := s.saveSchemaVersion(s.db, version); err != nil {
+			return err
+		}
+	} else {
+		loaded, err := s.loadSchemaVersion()
+		if err != nil {
+			return err
+		}
+		version = loaded
+	}
+	if version > latest {
+		return fmt.Errorf("数据库结构版本 %d 高于当前程序支持的版本 %d，请升级程序", version, latest)
+	}
+
+	for _, m := range schemaMigrations {
+		if m.version <= version {
+			continue
+		}
+		log.Printf("迁移数据库结构到版本 %d: %s（数据较多时需要较长时间，请勿中断）", m.version, m.description)
+		began := time.Now()
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("迁移数据库结构到版本 %d 失败: %w", m.version, err)
+		}
+		log.Printf("数据库结构已迁移到版本 %d（用时 %s）", m.version, time.Since(began).Truncate(time.Millisecond))
+		version = m.version
+	}
+	s.schemaVersion = version
+	return nil
+}
+
+// applyMigration 在事务中执行迁移并记录版本（MySQL 的 DDL 会隐式提交，不使用事务，迁移本身需要可以重新执行）
+func (s *DatabaseStorage) applyMigration(m schemaMigration) error {
+	if s.driverType == "mysql" {
+		if err := m.apply(s, s.db); err != nil {
+			return err
+		}
+		return s.saveSchemaVersion(s.db, m.version)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.apply(s, tx); err != nil {
+		return err
+	}
+	if err := s.saveSchemaVersion(tx, m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadSchemaVersion 读取数据库结构版本（没有记录时为 0，即引入迁移之前创建的数据库）
+func (s *DatabaseStorage) loadSchemaVersion() (int, error) {
+	data, err := s.LoadMetadata(schemaVersionKey)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("数据库结构版本无效: %q", data)
+	}
+	return version, nil
+}
+
+// saveSchemaVersion 记录数据库结构版本
+func (s *DatabaseStorage) saveSchemaVersion(db sqlExecer, version int) error {
+	query := `INSERT INTO metadata (name, value, updated_at) VALUES (?, ?, ?)
+			  ON DUPLICATE KEY UPDATE value = VALUES(value), updated_at = VALUES(updated_at)`
+	if s.driverType == "postgres" {
+		query = `INSERT INTO metadata (name, value, updated_at) VALUES ($1, $2, $3)
+				 ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at`
+	} else if s.driverType == "sqlite3" {
+		query = `INSERT OR REPLACE INTO metadata (name, value, updated_at) VALUES (?, ?, ?)`
+	}
+	if _, err := db.Exec(query, schemaVersionKey, strconv.Itoa(version), time.Now()); err != nil {
+		return fmt.Errorf("记录数据库结构版本失败: %w", err)
+	}
+	return nil
+}
+
+// tableExists 表是否存在
+func (s *DatabaseStorage) tableExists(table string) bool {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT 1 FROM %s LIMIT 1`, table))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// createTrafficRecordsTable 按当前版本的结构创建流量记录表
+// 主键 (vmid, network_interface, timestamp) 即按虚拟机和时间范围查询使用的索引，同一时间点只有一条记录，重放本地缓冲或远程写入重试时重复的记录被忽略
+// SQLite: WITHOUT ROWID，记录按主键聚集存放，查询时连续读取，不需要单独的索引
+// PostgreSQL: 按月分区（RANGE），另有默认分区存放没有对应分区的记录；只按时间的清理和统计只扫描相关分区
+// MySQL: 按月分区（RANGE UNIX_TIMESTAMP），最后一个分区为 MAXVALUE
+func (s *DatabaseStorage) createTrafficRecordsTable(db sqlExecer, name string, since time.Time) error {
+	columns := `
+		vmid INTEGER NOT NULL,
+		network_interface VARCHAR(64) NOT NULL DEFAULT 'all',
+		timestamp TIMESTAMP NOT NULL,
+		rx_bytes BIGINT NOT NULL,
+		tx_bytes BIGINT NOT NULL,
+		disk_read_bytes BIGINT NOT NULL DEFAULT 0,
+		disk_write_bytes BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (vmid, network_interface, timestamp)`
+
+	var statements []string
+	switch s.driverType {
+	case "sqlite3":
+		statements = []string{
+			fmt.Sprintf(`CREATE TABLE %s (%s
+	) WITHOUT ROWID`, name, columns),
+		}
+	case "postgres":
+		statements = []string{
+			fmt.Sprintf(`CREATE TABLE %s (%s
+	) PARTITION BY RANGE (timestamp)`, name, columns),
+			fmt.Sprintf(`CREATE TABLE traffic_records_default PARTITION OF %s DEFAULT`, name),
+		}
+		for _, month := range partitionMonths(since, time.Now()) {
+			statements = append(statements, postgresPartitionStatement(name, month))
+		}
+	case "mysql":
+		var partitions []string
+		for _, month := range partitionMonths(since, time.Now()) {
+			partitions = append(partitions, mysqlPartition(month))
+		}
+		partitions = append(partitions, `PARTITION pmax VALUES LESS THAN MAXVALUE`)
+		statements = []string{
+			fmt.Sprintf(`CREATE TABLE %s (%s
+	)%s
+	PARTITION BY RANGE (UNIX_TIMESTAMP(timestamp)) (%s)`, name, columns, s.engine(), strings.Join(partitions, ", ")),
+		}
+	default:
+		statements = []string{fmt.Sprintf(`CREATE TABLE %s (%s
+	)`, name, columns)}
+	}
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("创建流量记录表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateTrafficRecordsLayout 迁移 1：按新结构重建流量记录表并复制数据（同一时间点的重复记录只保留一条）
+func migrateTrafficRecordsLayout(s *DatabaseStorage, db sqlExecer) error {
+	// MySQL 不使用事务，上次中断时可能留下的临时表
+	for _, table := range []string{"traffic_records_new", "traffic_records_old"} {
+		if _, err := db.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM traffic_records`).Scan(&count); err != nil {
+		return fmt.Errorf("统计流量记录失败: %w", err)
+	}
+	// 分区从最早的记录所在月开始创建
+	since := time.Now()
+	if s.partitioned() {
+		var earliest sql.NullTime
+		if err := db.QueryRow(`SELECT MIN(timestamp) FROM traffic_records`).Scan(&earliest); err != nil {
+			return fmt.Errorf("查询最早的流量记录失败: %w", err)
+		}
+		if earliest.Valid {
+			since = earliest.Time
+		}
+	}
+	log.Printf("复制 %d 条流量记录到新的流量记录表", count)
+
+	if err := s.createTrafficRecordsTable(db, "traffic_records_new", since); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO traffic_records_new (%s) SELECT %s FROM traffic_records`
+	switch s.driverType {
+	case "sqlite3":
+		insert = `INSERT OR IGNORE INTO traffic_records_new (%s) SELECT %s FROM traffic_records ORDER BY vmid, network_interface, timestamp`
+	case "postgres":
+		insert += ` ON CONFLICT DO NOTHING`
+	case "mysql":
+		insert = `INSERT IGNORE INTO traffic_records_new (%s) SELECT %s FROM traffic_records`
+	}
+	columns := `vmid, network_interface, timestamp, rx_bytes, tx_bytes, disk_read_bytes, disk_write_bytes`
+	result, err := db.Exec(fmt.Sprintf(insert, columns, columns))
+	if err != nil {
+		return fmt.Errorf("复制流量记录失败: %w", err)
+	}
+	if copied, err := result.RowsAffected(); err == nil && copied < count {
+		log.Printf("已忽略 %d 条同一时间点的重复流量记录", count-copied)
+	}
+
+	if s.driverType == "mysql" {
+		if _, err := db.Exec(`RENAME TABLE traffic_records TO traffic_records_old, traffic_records_new TO traffic_records`); err != nil {
+			return fmt.Errorf("替换流量记录表失败: %w", err)
+		}
+		if _, err := db.Exec(`DROP TABLE traffic_records_old`); err != nil {
+			return fmt.Errorf("删除旧的流量记录表失败: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := db.Exec(`DROP TABLE traffic_records`); err != nil {
+		return fmt.Errorf("删除旧的流量记录表失败: %w", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE traffic_records_new RENAME TO traffic_records`); err != nil {
+		return fmt.Errorf("替换流量记录表失败: %w", err)
+	}
+	return nil
+}
+
+// migrateIPSplitColumns 迁移 2：添加按协议的累计字节字段（已有记录为 0，统计时视为没有按协议的数据）
+func migrateIPSplitColumns(s *DatabaseStorage, db sqlExecer) error {
+	for _, column := range []string{"ipv4_rx_bytes", "ipv4_tx_bytes", "ipv6_rx_bytes", "ipv6_tx_bytes"} {
+		if err := s.addColumn(db, "traffic_records", column, "BIGINT NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateBackupColumn 迁移 3：添加备份标记字段（已有记录视为不在备份期间）
+func migrateBackupColumn(s *DatabaseStorage, db sqlExecer) error {
+	return s.addColumn(db, "traffic_records", "backup", "SMALLINT NOT NULL DEFAULT 0")
+}
+
+// migrateOverriddenRulesColumn 迁移 4：操作日志添加因优先级没有执行的规则（已有日志为空）
+func migrateOverriddenRulesColumn(s *DatabaseStorage, db sqlExecer) error {
+	return s.addColumn(db, "action_logs", "overridden_rules", "TEXT")
+}
+
+// addColumn 为表添加字段，字段已存在时跳过（MySQL 的迁移不在事务中，中断后会重新执行）
+func (s *DatabaseStorage) addColumn(db sqlExecer, table, column, definition string) error {
+	query := `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	switch s.driverType {
+	case "postgres":
+		query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`
+	case "sqlite3":
+		query = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	}
+	var exists int
+	if err := db.QueryRow(query, table, column).Scan(&exists); err != nil {
+		return fmt.Errorf("查询 %s 表的字段失败: %w", table, err)
+	}
+	if exists > 0 {
+		return nil
+	}
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("为 %s 表添加字段 %s 失败: %w", table, column, err)
+	}
+	return nil
+}
+
+// partitioned 流量记录表是否按月分区
+func (s *DatabaseStorage) partitioned() bool {
+	return s.driverType == "postgres" || s.driverType == "mysql"
+}
+
+// ensurePartition 写入的记录接近已创建分区的末尾时创建之后的分区（启动时以当前时间调用一次）
+// 没有对应分区的记录仍可写入（PostgreSQL 的默认分区、MySQL 的 pmax），只是不能按分区裁剪
+func (s *DatabaseStorage) ensurePartition(ts time.Time) {
+	if !s.partitioned() || ts.AddDate(0, 1, 0).Unix() < s.partitionedUntil.Load() {
+		return
+	}
+
+	s.partitionMu.Lock()
+	defer s.partitionMu.Unlock()
+	if ts.AddDate(0, 1, 0).Unix() < s.partitionedUntil.Load() {
+		return
+	}
+	if err := s.createPartitions(ts); err != nil {
+		log.Printf("创建流量记录分区失败: %v", err)
+		// 避免每次写入都重试，下个月再试
+		s.partitionedUntil.Store(addMonths(monthStart(ts), 2).Unix())
+	}
+}
+
+// createPartitions 创建到 ts 之后 partitionAhead 个月为止缺少的月分区
+func (s *DatabaseStorage) createPartitions(ts time.Time) error {
+	existing, err := s.partitionNames()
+	if err != nil {
+		return err
+	}
+	target := addMonths(monthStart(ts), partitionAhead)
+	from := monthStart(time.Now())
+	if len(existing) > 0 {
+		last, err := time.ParseInLocation(partitionLayout, strings.TrimPrefix(existing[len(existing)-1], partitionPrefix), time.UTC)
+		if err == nil {
+			from = addMonths(last, 1)
+		}
+	}
+
+	for month := from; !month.After(target); month = addMonths(month, 1) {
+		statement := postgresPartitionStatement("traffic_records", month)
+		if s.driverType == "mysql" {
+			statement = fmt.Sprintf(`ALTER TABLE traffic_records REORGANIZE PARTITION pmax INTO (%s, PARTITION pmax VALUES LESS THAN MAXVALUE)`, mysqlPartition(month))
+		}
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("创建 %s 分区失败: %w", month.Format("2006-01"), err)
+		}
+		utils.DebugLog("已创建流量记录分区 %s", month.Format("2006-01"))
+	}
+	s.partitionedUntil.Store(addMonths(target, 1).Unix())
+	return nil
+}
+
+// partitionNames 已创建的月分区名称（按月份排序）
+func (s *DatabaseStorage) partitionNames() ([]string, error) {
+	query := `SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = 'traffic_records'::regclass`
+	if s.driverType == "mysql" {
+		query = `SELECT partition_name FROM information_schema.partitions
+				 WHERE table_schema = DATABASE() AND table_name = 'traffic_records' AND partition_name IS NOT NULL`
+	}
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询流量记录分区失败: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, partitionPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, rows.Err()
+}
+
+// partitionMonths 需要创建分区的月份：从 since 所在月（最多 partitionMaxBack 个月之前）到 now 之后 partitionAhead 个月
+func partitionMonths(since, now time.Time) []time.Time {
+	first := monthStart(since)
+	if oldest := addMonths(monthStart(now), -partitionMaxBack); first.Before(oldest) {
+		first = oldest
+	}
+	last := addMonths(monthStart(now), partitionAhead)
+
+	var months []time.Time
+	for month := first; !month.After(last); month = addMonths(month, 1) {
+		months = append(months, month)
+	}
+	return months
+}
+
+// postgresPartitionStatement 创建 PostgreSQL 月分区的语句
+func postgresPartitionStatement(table string, month time.Time) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s%s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+		partitionPrefix, month.Format(partitionLayout), table,
+		month.Format("2006-01-02"), addMonths(month, 1).Format("2006-01-02"))
+}
+
+// mysqlPartition MySQL 月分区定义（边界为 UTC 月初的 Unix 时间戳）
+func mysqlPartition(month time.Time) string {
+	return fmt.Sprintf(`PARTITION %s%s VALUES LESS THAN (%d)`, partitionPrefix, month.Format(partitionLayout), addMonths(month, 1).Unix())
+}
+
+// monthStart t 所在月的第一天（UTC）
+func monthStart(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// addMonths 加减月份（t 为月初）
+func addMonths(t time.Time, months int) time.Time {
+	return t.AddDate(0, months, 0)
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestMigrateLegacyTrafficRecords(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// 引入迁移之前的表结构，包含一条重复记录
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE traffic_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			vmid INTEGER NOT NULL,
+			network_interface VARCHAR(64) NOT NULL DEFAULT 'all',
+			timestamp TIMESTAMP NOT NULL,
+			rx_bytes BIGINT NOT NULL,
+			tx_bytes BIGINT NOT NULL,
+			total_bytes BIGINT NOT NULL
+		)`,
+		`CREATE INDEX idx_traffic_records_vmid_interface_timestamp ON traffic_records (vmid, network_interface, timestamp)`,
+	} {
+		if _, err := legacy.Exec(statement); err != nil {
+			t.Fatalf("create legacy schema: %v", err)
+		}
+	}
+	for i, minutes := range []int{0, 1, 1, 2} {
+		_, err := legacy.Exec(`INSERT INTO traffic_records (vmid, network_interface, timestamp, rx_bytes, tx_bytes, total_bytes) VALUES (?, 'all', ?, ?, ?, 0)`,
+			101, baseTime.Add(time.Duration(minutes)*time.Minute), 1000*(i+1), 100*(i+1))
+		if err != nil {
+			t.Fatalf("insert legacy record: %v", err)
+		}
+	}
+	legacy.Close()
+
+	store, err := NewDatabaseStorage("sqlite3", dbPath, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("open and migrate: %v", err)
+	}
+	defer store.Close()
+
+	if store.schemaVersion != latestSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", store.schemaVersion, latestSchemaVersion())
+	}
+	records, err := store.GetTrafficRecords(101, baseTime.Add(-time.Minute), baseTime.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("get records: %v", err)
+	}
+	if len(records) != 3 || records[2].TotalBytes != records[2].RXBytes+records[2].TXBytes {
+		t.Fatalf("records = %+v, want 3 records without the duplicate and total computed from rx+tx", records)
+	}
+	if count, err := store.recountRecords(); err != nil || count != 3 {
+		t.Fatalf("record count = %d, %v, want 3", count, err)
+	}
+
+	// 重复写入同一时间点的记录被忽略，计数不变
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: baseTime, RXBytes: 1}); err != nil {
+		t.Fatalf("save duplicate: %v", err)
+	}
+	if count, _ := store.GetTotalRecordCount(); count != 3 {
+		t.Fatalf("record count after duplicate = %d, want 3", count)
+	}
+	if version, err := store.loadSchemaVersion(); err != nil || version != latestSchemaVersion() {
+		t.Fatalf("stored schema version = %d, %v", version, err)
+	}
+}
+
+func TestMigrateAddsColumnsToVersion1Table(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v1.db")
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// 版本 1 的表结构（没有按协议的字段和备份标记）
+	v1, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open v1 db: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE traffic_records (
+			vmid INTEGER NOT NULL,
+			network_interface VARCHAR(64) NOT NULL DEFAULT 'all',
+			timestamp TIMESTAMP NOT NULL,
+			rx_bytes BIGINT NOT NULL,
+			tx_bytes BIGINT NOT NULL,
+			disk_read_bytes BIGINT NOT NULL DEFAULT 0,
+			disk_write_bytes BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (vmid, network_interface, timestamp)
+		) WITHOUT ROWID`,
+		`CREATE TABLE metadata (name VARCHAR(255) PRIMARY KEY, value TEXT NOT NULL, updated_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO metadata (name, value, updated_at) VALUES ('schema_version', '1', CURRENT_TIMESTAMP)`,
+	} {
+		if _, err := v1.Exec(statement); err != nil {
+			t.Fatalf("create v1 schema: %v", err)
+		}
+	}
+	if _, err := v1.Exec(`INSERT INTO traffic_records (vmid, timestamp, rx_bytes, tx_bytes) VALUES (101, ?, 1000, 100)`, baseTime); err != nil {
+		t.Fatalf("insert v1 record: %v", err)
+	}
+	v1.Close()
+
+	store, err := NewDatabaseStorage("sqlite3", dbPath, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("open and migrate: %v", err)
+	}
+	defer store.Close()
+	if store.schemaVersion != latestSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", store.schemaVersion, latestSchemaVersion())
+	}
+
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 101, Timestamp: baseTime.Add(time.Minute), RXBytes: 2000, TXBytes: 200, IPv4RX: 700, IPv6TX: 30, Backup: true}); err != nil {
+		t.Fatalf("save record after migration: %v", err)
+	}
+	records, err := store.GetTrafficRecords(101, baseTime, baseTime.Add(time.Hour))
+	if err != nil || len(records) != 2 {
+		t.Fatalf("records = %+v, %v, want the v1 record and the new one", records, err)
+	}
+	if records[0].IPv4RX != 0 || records[0].Backup || records[1].IPv4RX != 700 || records[1].IPv6TX != 30 || !records[1].Backup {
+		t.Fatalf("records = %+v, want ipv4/ipv6 counters and the backup flag only on the new record", records)
+	}
+}
+
+func TestMigrateAddsOverriddenRulesColumn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v3.db")
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// 版本 3 的操作日志表（没有被取代的规则字段）
+	v3, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open v3 db: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE traffic_records (
+			vmid INTEGER NOT NULL,
+			network_interface VARCHAR(64) NOT NULL DEFAULT 'all',
+			timestamp TIMESTAMP NOT NULL,
+			rx_bytes BIGINT NOT NULL,
+			tx_bytes BIGINT NOT NULL,
+			disk_read_bytes BIGINT NOT NULL DEFAULT 0,
+			disk_write_bytes BIGINT NOT NULL DEFAULT 0,
+			ipv4_rx_bytes BIGINT NOT NULL DEFAULT 0,
+			ipv4_tx_bytes BIGINT NOT NULL DEFAULT 0,
+			ipv6_rx_bytes BIGINT NOT NULL DEFAULT 0,
+			ipv6_tx_bytes BIGINT NOT NULL DEFAULT 0,
+			backup SMALLINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (vmid, network_interface, timestamp)
+		) WITHOUT ROWID`,
+		`CREATE TABLE action_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			vmid INTEGER NOT NULL,
+			rule_name VARCHAR(255) NOT NULL,
+			action VARCHAR(50) NOT NULL,
+			reason TEXT,
+			timestamp TIMESTAMP NOT NULL,
+			success BOOLEAN NOT NULL,
+			error TEXT,
+			error_kind VARCHAR(32),
+			task_id VARCHAR(255),
+			task_status TEXT,
+			output TEXT,
+			hook_output TEXT,
+			hook_error TEXT
+		)`,
+		`CREATE TABLE metadata (name VARCHAR(255) PRIMARY KEY, value TEXT NOT NULL, updated_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO metadata (name, value, updated_at) VALUES ('schema_version', '3', CURRENT_TIMESTAMP)`,
+	} {
+		if _, err := v3.Exec(statement); err != nil {
+			t.Fatalf("create v3 schema: %v", err)
+		}
+	}
+	if _, err := v3.Exec(`INSERT INTO action_logs (vmid, rule_name, action, reason, timestamp, success, error, error_kind, task_id, task_status, output, hook_output, hook_error)
+		VALUES (101, 'monthly', 'shutdown', '', ?, 1, '', '', '', '', '', '', '')`, baseTime); err != nil {
+		t.Fatalf("insert v3 action log: %v", err)
+	}
+	v3.Close()
+
+	store, err := NewDatabaseStorage("sqlite3", dbPath, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("open and migrate: %v", err)
+	}
+	defer store.Close()
+	if store.schemaVersion != latestSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", store.schemaVersion, latestSchemaVersion())
+	}
+
+	if err := store.SaveActionLog(models.ActionLog{VMID: 101, RuleName: "daily", Action: "rate_limit", Timestamp: baseTime.Add(time.Minute), Success: true, OverriddenRules: []string{"monthly"}}); err != nil {
+		t.Fatalf("save action log after migration: %v", err)
+	}
+	logs, err := store.GetActionLogs(baseTime, baseTime.Add(time.Hour))
+	if err != nil || len(logs) != 2 {
+		t.Fatalf("logs = %+v, %v, want the v3 log and the new one", logs, err)
+	}
+	for _, log := range logs {
+		want := 0
+		if log.RuleName == "daily" {
+			want = 1
+		}
+		if len(log.OverriddenRules) != want {
+			t.Fatalf("logs = %+v, want overridden rules only on the new log", logs)
+		}
+	}
+}
+
+func TestSQLiteTunedDSN(t *testing.T) {
+	tests := []struct{ dsn, want string }{
+		{"./data/pve.db", "./data/pve.db?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000"},
+		{"file:pve.db?cache=shared&_sync=FULL", "file:pve.db?cache=shared&_sync=FULL&_journal_mode=WAL&_busy_timeout=5000"},
+		{":memory:", ":memory:"},
+	}
+	for _, tt := range tests {
+		if got := sqliteTunedDSN(tt.dsn); got != tt.want {
+			t.Errorf("sqliteTunedDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestPartitionDefinitions(t *testing.T) {
+	now := time.Date(2026, 11, 15, 0, 0, 0, 0, time.UTC)
+	months := partitionMonths(time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC), now)
+	if len(months) != 6 || !months[0].Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) || months[5].Month() != time.February {
+		t.Fatalf("partitionMonths() = %v, want 2026-09 through 2027-02", months)
+	}
+	if old := partitionMonths(time.Unix(0, 0), now); len(old) != partitionMaxBack+partitionAhead+1 {
+		t.Fatalf("partitionMonths(1970) = %d months, want capped at %d", len(old), partitionMaxBack+partitionAhead+1)
+	}
+
+	pg := postgresPartitionStatement("traffic_records", months[3])
+	if !strings.Contains(pg, "traffic_records_p202612 PARTITION OF traffic_records FOR VALUES FROM ('2026-12-01') TO ('2027-01-01')") {
+		t.Fatalf("postgres partition = %s", pg)
+	}
+	if got := mysqlPartition(months[3]); got != "PARTITION traffic_records_p202612 VALUES LESS THAN (1798761600)" {
+		t.Fatalf("mysql partition = %s", got)
+	}
+}
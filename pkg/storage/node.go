@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ErrNodeRecordsUnsupported 存储不支持保存节点流量
+var ErrNodeRecordsUnsupported = errors.New("存储不支持节点流量记录")
+
+// SaveNodeTrafficRecord 保存节点流量记录（存储不支持时返回 ErrNodeRecordsUnsupported）
+func SaveNodeTrafficRecord(s Interface, node string, record models.TrafficRecord) error {
+	if recorder, ok := As[NodeRecorder](s); ok {
+		return recorder.SaveNodeTrafficRecord(node, record)
+	}
+	return ErrNodeRecordsUnsupported
+}
+
+// GetNodeTrafficRecords 获取节点流量记录（存储不支持时返回 ErrNodeRecordsUnsupported）
+func GetNodeTrafficRecords(s Interface, node string, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	if recorder, ok := As[NodeRecorder](s); ok {
+		return recorder.GetNodeTrafficRecords(node, startTime, endTime)
+	}
+	return nil, ErrNodeRecordsUnsupported
+}
+
+// NodeTrafficStats 根据节点记录计算时间范围内的流量（计数器回退按重启处理）
+func NodeTrafficStats(records []models.TrafficRecord, period string, startTime, endTime time.Time, direction string) *models.TrafficStats {
+	return buildTrafficStats(0, period, startTime, endTime, direction, records)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// PercentileBucket 百分位计算的采样区间（经典突发计费按 5 分钟平均带宽取 95 百分位）
+const PercentileBucket = 5 * time.Minute
+
+// MinPercentileSamples 百分位规则触发前至少需要的区间数（样本太少时百分位接近最大值）
+const MinPercentileSamples = 20
+
+// BucketRates 按 5 分钟区间汇总采样增量并换算为平均带宽（Mbps），按时间升序
+// 没有采样的区间（如虚拟机关机）不参与计算；采集间隔应不大于 5 分钟
+func BucketRates(records []models.TrafficRecord, direction string) []float64 {
+	buckets := make(map[int64]uint64)
+	for _, point := range AggregateTrafficByPeriod(records, models.PeriodMinute) {
+		buckets[point.Timestamp.Truncate(PercentileBucket).Unix()] += pointBytes(point, direction)
+	}
+
+	keys := make([]int64, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	rates := make([]float64, len(keys))
+	for i, key := range keys {
+		rates[i] = BucketMbps(buckets[key])
+	}
+	return rates
+}
+
+// BucketMbps 5 分钟区间内的字节数换算为平均带宽（Mbps）
+func BucketMbps(bytes uint64) float64 {
+	return float64(bytes) * 8 / PercentileBucket.Seconds() / 1_000_000
+}
+
+// Percentile 第 p 百分位（最近秩法：升序排序后取第 ceil(p/100*n) 个，与突发计费一致）
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// UsageProfileOf 计算周期内的 95 百分位带宽、峰值小时和最忙的一天
+func UsageProfileOf(vmid int, period string, startTime, endTime time.Time, direction string, records []models.TrafficRecord) *models.UsageProfile {
+	if direction == "" {
+		direction = models.DirectionBoth
+	}
+	profile := &models.UsageProfile{
+		VMID:      vmid,
+		Period:    period,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Direction: direction,
+	}
+
+	rates := BucketRates(records, direction)
+	profile.Samples = len(rates)
+	profile.P95Mbps = Percentile(rates, 95)
+	for _, rate := range rates {
+		profile.PeakMbps = math.Max(profile.PeakMbps, rate)
+	}
+
+	for _, point := range AggregateTrafficByPeriod(records, models.PeriodHour) {
+		if bytes := pointBytes(point, direction); bytes > profile.PeakHourBytes {
+			profile.PeakHour, profile.PeakHourBytes = point.Timestamp, bytes
+		}
+	}
+	for _, point := range AggregateTrafficByPeriod(records, models.PeriodDay) {
+		if bytes := pointBytes(point, direction); bytes > profile.BusiestDayBytes {
+			profile.BusiestDay, profile.BusiestDayBytes = point.Timestamp, bytes
+		}
+	}
+
+	return profile
+}
+
+// pointBytes 按方向取聚合点的字节数
+func pointBytes(point AggregatedPoint, direction string) uint64 {
+	switch direction {
+	case models.DirectionUpload, models.DirectionTX:
+		return point.TXBytes
+	case models.DirectionDownload, models.DirectionRX:
+		return point.RXBytes
+	default: // "both"
+		return point.TotalBytes
+	}
+}
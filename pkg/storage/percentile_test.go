@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestUsageProfileOf(t *testing.T) {
+	if got := Percentile([]float64{5, 1, 4, 2, 3}, 95); got != 5 {
+		t.Fatalf("Percentile(95) = %v, want 5", got)
+	}
+	if got := Percentile([]float64{5, 1, 4, 2, 3}, 40); got != 2 {
+		t.Fatalf("Percentile(40) = %v, want 2", got)
+	}
+
+	base := time.Date(2026, 1, 2, 3, 0, 0, 0, time.Local)
+	records := []models.TrafficRecord{
+		{VMID: 101, Timestamp: base, RXBytes: 0},
+		{VMID: 101, Timestamp: base.Add(5 * time.Minute), RXBytes: 37_500_000},         // 1 Mbps
+		{VMID: 101, Timestamp: base.Add(65 * time.Minute), RXBytes: 37_500_000 + 3750}, // 下一个小时几乎没有流量
+		{VMID: 101, Timestamp: base.Add(70 * time.Minute), RXBytes: 1000},              // 重启，增量 1000
+	}
+
+	// 03:00-03:05 的增量拆分到这 5 分钟内，落在 03:00 的区间；04:05 的采样前中断超过拆分间隔，整段计入 04:05 的区间
+	profile := UsageProfileOf(101, models.PeriodDay, base, base.Add(2*time.Hour), models.DirectionRX, records)
+	if profile.Samples != 2 || profile.PeakMbps != 1 {
+		t.Fatalf("profile = %+v, want 2 buckets with 1 Mbps peak", profile)
+	}
+	if !profile.PeakHour.Equal(base) || profile.PeakHourBytes != 37_500_000 {
+		t.Fatalf("peak hour = %s (%d bytes), want %s", profile.PeakHour, profile.PeakHourBytes, base)
+	}
+	if profile.BusiestDayBytes != 37_500_000+3750+1000 {
+		t.Fatalf("busiest day bytes = %d, want %d", profile.BusiestDayBytes, 37_500_000+3750+1000)
+	}
+}
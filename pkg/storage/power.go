@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// 虚拟机状态中保存的运行状态和运行状态变化
+const (
+	powerStatusKey = "power_status"
+	powerEventsKey = "power_events"
+)
+
+// maxPowerEvents 每台虚拟机最多保留的运行状态变化
+const maxPowerEvents = 500
+
+// RecordPowerState 记录虚拟机当前的运行状态，与上次记录的状态不同时保存一次变化并返回（首次记录或没有变化时返回 nil）
+// 早于 keepSince 的变化被删除（零值表示不按时间删除），每台虚拟机最多保留 maxPowerEvents 条
+func RecordPowerState(s Interface, vmid int, status string, at, keepSince time.Time) (*models.PowerEvent, error) {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return nil, err
+	}
+	previous, _ := state[powerStatusKey].(string)
+	if previous == status {
+		return nil, nil
+	}
+
+	updates := map[string]interface{}{powerStatusKey: status}
+	var event *models.PowerEvent
+	if previous != "" {
+		events, err := powerEvents(state)
+		if err != nil {
+			// 无法解析的旧记录直接覆盖
+			events = nil
+		}
+		event = &models.PowerEvent{Time: at, From: previous, To: status}
+		events = append(events, *event)
+
+		first := 0
+		for !keepSince.IsZero() && first < len(events) && events[first].Time.Before(keepSince) {
+			first++
+		}
+		if len(events)-first > maxPowerEvents {
+			first = len(events) - maxPowerEvents
+		}
+		updates[powerEventsKey] = events[first:]
+	}
+	if err := UpdateVMState(s, vmid, updates); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// LoadPowerEvents 读取虚拟机在 [start, end] 内的运行状态变化（按时间升序）
+func LoadPowerEvents(s Interface, vmid int, start, end time.Time) ([]models.PowerEvent, error) {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return nil, err
+	}
+	events, err := powerEvents(state)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.PowerEvent, 0, len(events))
+	for _, event := range events {
+		if !event.Time.Before(start) && !event.Time.After(end) {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+// powerEvents 解析状态中的运行状态变化（存储序列化后为通用的切片，经 JSON 转换）
+func powerEvents(state map[string]interface{}) ([]models.PowerEvent, error) {
+	raw, ok := state[powerEventsKey]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.PowerEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("解析运行状态变化记录失败: %w", err)
+	}
+	return events, nil
+}
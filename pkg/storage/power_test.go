@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRecordPowerState(t *testing.T) {
+	store, err := NewStorageFromConfig(&models.StorageConfig{Type: "file", FilePath: filepath.Join(t.TempDir(), "data")})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	defer store.Close()
+
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		status string
+		want   bool
+	}{
+		{"running", false}, // 首次记录没有变化
+		{"running", false},
+		{"stopped", true},
+		{"stopped", false},
+		{"running", true},
+	}
+	for i, step := range steps {
+		event, err := RecordPowerState(store, 100, step.status, base.Add(time.Duration(i)*time.Hour), time.Time{})
+		if err != nil {
+			t.Fatalf("RecordPowerState(%d) error = %v", i, err)
+		}
+		if (event != nil) != step.want {
+			t.Fatalf("RecordPowerState(%d, %s) = %+v, want change %v", i, step.status, event, step.want)
+		}
+	}
+
+	events, err := LoadPowerEvents(store, 100, base, base.Add(24*time.Hour))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("LoadPowerEvents() = %+v, %v, want 2 events", events, err)
+	}
+	if events[0].From != "running" || events[0].To != "stopped" || !events[0].Time.Equal(base.Add(2*time.Hour)) ||
+		events[1].To != "running" {
+		t.Fatalf("events = %+v, want stop at 02:00 then start", events)
+	}
+	if events, _ := LoadPowerEvents(store, 100, base.Add(3*time.Hour), base.Add(24*time.Hour)); len(events) != 1 {
+		t.Fatalf("events after 03:00 = %+v, want only the start", events)
+	}
+
+	// 早于保留期的变化在下一次记录时删除
+	if _, err := RecordPowerState(store, 100, "stopped", base.Add(48*time.Hour), base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("RecordPowerState() error = %v", err)
+	}
+	if events, _ := LoadPowerEvents(store, 100, base, base.Add(72*time.Hour)); len(events) != 2 || events[0].To != "running" {
+		t.Fatalf("events after trimming = %+v, want the start and the new stop", events)
+	}
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// remoteWriteTimeout 单次发送的超时时间
+const remoteWriteTimeout = 30 * time.Second
+
+// RemoteWriteStats 远程写入统计
+type RemoteWriteStats struct {
+	Pending     int       `json:"pending"`                 // 等待发送的记录数
+	Sent        uint64    `json:"sent"`                    // 累计发送成功的记录数
+	Dropped     uint64    `json:"dropped"`                 // 积压超过上限或被目标拒绝而丢弃的记录数
+	LastSentAt  time.Time `json:"last_sent_at,omitempty"`  // 最近一次发送成功的时间
+	LastError   string    `json:"last_error,omitempty"`    // 最近一次发送错误
+	LastErrorAt time.Time `json:"last_error_at,omitempty"` // 最近一次发送错误时间
+}
+
+// RemoteWriteReporter 带远程写入的存储（用于诊断和系统统计）
+type RemoteWriteReporter interface {
+	RemoteWriteStats() RemoteWriteStats
+}
+
+// errRemoteRejected 目标拒绝了发送的数据（HTTP 400 或 413），重试也不会成功
+var errRemoteRejected = errors.New("目标实例拒绝了写入的数据")
+
+// RemoteWriteStorage 远程写入包装器
+// 流量记录写入后端的同时加入发送队列，按批发送到另一个实例的 /api/ingest；
+// 发送失败时保留在内存中按退避间隔重试，不影响本地写入
+type RemoteWriteStorage struct {
+	Interface
+	config models.RemoteWriteConfig
+	source string
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []models.TrafficRecord
+	stats   RemoteWriteStats
+	sendMu  sync.Mutex // 保证批次按顺序发送
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRemoteWriteStorage 创建远程写入包装器（source 为空时使用配置中的来源名称）
+func NewRemoteWriteStorage(backend Interface, config models.RemoteWriteConfig, source string) *RemoteWriteStorage {
+	if config.Source != "" {
+		source = config.Source
+	}
+	s := &RemoteWriteStorage{
+		Interface: backend,
+		config:    config,
+		source:    source,
+		client:    &http.Client{Timeout: remoteWriteTimeout},
+		kick:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.sendLoop()
+	return s
+}
+
+// SaveTrafficRecord 保存流量记录并加入发送队列（本地写入失败时同样转发）
+func (s *RemoteWriteStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	err := s.Interface.SaveTrafficRecord(record)
+	s.enqueue(record)
+	return err
+}
+
+// SaveTrafficRecords 批量保存流量记录并加入发送队列
+func (s *RemoteWriteStorage) SaveTrafficRecords(records []models.TrafficRecord) error {
+	err := SaveTrafficRecords(s.Interface, records)
+	for _, record := range records {
+		s.enqueue(record)
+	}
+	return err
+}
+
+// Unwrap 返回被包装的存储
+func (s *RemoteWriteStorage) Unwrap() Interface {
+	return s.Interface
+}
+
+// RemoteWriteStats 获取远程写入统计
+func (s *RemoteWriteStorage) RemoteWriteStats() RemoteWriteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.Pending = len(s.pending)
+	return st
+}
+
+// enqueue 加入发送队列，超过上限时丢弃最早的记录；凑满一批时立即发送
+func (s *RemoteWriteStorage) enqueue(record models.TrafficRecord) {
+	s.mu.Lock()
+	s.pending = append(s.pending, record)
+	if over := len(s.pending) - s.config.MaxPendingRecords(); over > 0 {
+		s.pending = s.pending[over:]
+		s.stats.Dropped += uint64(over)
+	}
+	full := len(s.pending) >= s.config.Batch()
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush 按顺序发送队列中的记录，遇到错误时停止并保留剩余记录，返回发送成功的记录数
+func (s *RemoteWriteStorage) Flush() (int, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	sent := 0
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		if n > s.config.Batch() {
+			n = s.config.Batch()
+		}
+		batch := append([]models.TrafficRecord(nil), s.pending[:n]...)
+		s.mu.Unlock()
+		if len(batch) == 0 {
+			return sent, nil
+		}
+
+		err := s.send(batch)
+
+		s.mu.Lock()
+		if err != nil && !errors.Is(err, errRemoteRejected) {
+			s.stats.LastError = err.Error()
+			s.stats.LastErrorAt = time.Now()
+			s.mu.Unlock()
+			return sent, err
+		}
+		// 发送期间队列可能因超过上限丢弃了部分最早的记录，只移除仍在队首的本批记录
+		removed := removeSent(s.pending, batch)
+		s.pending = s.pending[removed:]
+		if err != nil {
+			s.stats.Dropped += uint64(removed)
+			s.stats.LastError = err.Error()
+			s.stats.LastErrorAt = time.Now()
+			log.Printf("远程写入被目标拒绝，丢弃 %d 条记录: %v", removed, err)
+		} else {
+			s.stats.Sent += uint64(removed)
+			s.stats.LastSentAt = time.Now()
+			sent += removed
+		}
+		s.mu.Unlock()
+	}
+}
+
+// removeSent 队首与已发送批次重叠的记录数
+func removeSent(pending, batch []models.TrafficRecord) int {
+	last := batch[len(batch)-1]
+	for i := 0; i < len(pending) && i < len(batch); i++ {
+		if pending[i] == last {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// send 发送一批记录
+func (s *RemoteWriteStorage) send(records []models.TrafficRecord) error {
+	body, err := json.Marshal(models.IngestBatch{Source: s.source, Records: records})
+	if err != nil {
+		return fmt.Errorf("序列化流量记录失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(s.config.URL, "/")+"/api/ingest", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.config.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("%w: HTTP %d %s", errRemoteRejected, resp.StatusCode, result.Error)
+	}
+	return fmt.Errorf("HTTP %d %s", resp.StatusCode, result.Error)
+}
+
+// Close 停止发送，尽量发送剩余记录后关闭后端（仍未发送的记录丢失）
+func (s *RemoteWriteStorage) Close() error {
+	close(s.stop)
+	<-s.done
+
+	if _, err := s.Flush(); err != nil {
+		log.Printf("退出前远程写入失败，%d 条记录未发送: %v", s.RemoteWriteStats().Pending, err)
+	}
+	return s.Interface.Close()
+}
+
+// sendLoop 每个发送间隔或凑满一批时发送，连续失败时按指数退避重试
+func (s *RemoteWriteStorage) sendLoop() {
+	defer close(s.done)
+
+	interval := s.config.FlushInterval()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	backoff := time.Duration(0)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.kick:
+			if backoff > 0 {
+				continue // 退避期间不因队列已满提前重试
+			}
+		case <-timer.C:
+		}
+
+		_, err := s.Flush()
+		switch {
+		case err == nil:
+			if backoff > 0 {
+				log.Printf("远程写入已恢复")
+			}
+			backoff = 0
+		case backoff == 0:
+			backoff = interval
+			log.Printf("远程写入失败，%d 条记录等待重试: %v", s.RemoteWriteStats().Pending, err)
+		default:
+			backoff = min(backoff*2, models.RemoteWriteMaxBackoff)
+			utils.DebugLog("远程写入失败，%v 后重试: %v", backoff, err)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if backoff > 0 {
+			timer.Reset(backoff)
+		} else {
+			timer.Reset(interval)
+		}
+	}
+}
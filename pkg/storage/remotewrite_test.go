@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestRemoteWriteStorageBatchesAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusServiceUnavailable
+	var batches []models.IngestBatch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/api/ingest" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		var batch models.IngestBatch
+		json.NewDecoder(r.Body).Decode(&batch)
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	backend := &flakyStorage{}
+	remote := NewRemoteWriteStorage(backend, models.RemoteWriteConfig{
+		URL:          server.URL,
+		Token:        "secret",
+		BatchSize:    2,
+		FlushSeconds: 3600,
+		MaxPending:   4,
+	}, "pve-a")
+	defer remote.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := remote.SaveTrafficRecord(models.TrafficRecord{VMID: 100 + i, Timestamp: baseTime}); err != nil {
+			t.Fatalf("SaveTrafficRecord() error = %v", err)
+		}
+	}
+	if len(backend.records) != 5 {
+		t.Fatalf("backend has %d records, want 5", len(backend.records))
+	}
+
+	// 凑满一批时后台立即发送，失败后进入退避（flush_seconds 很长，测试期间不会再自动重试）
+	for deadline := time.Now().Add(5 * time.Second); remote.RemoteWriteStats().LastError == ""; {
+		if time.Now().After(deadline) {
+			t.Fatalf("background send was not attempted after a full batch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 目标不可用：保留记录，超过 max_pending 时丢弃最早的
+	if sent, err := remote.Flush(); err == nil || sent != 0 {
+		t.Fatalf("Flush() with target down = %d, %v, want error", sent, err)
+	}
+	if st := remote.RemoteWriteStats(); st.Pending != 4 || st.Dropped != 1 || st.LastError == "" {
+		t.Fatalf("stats while down = %+v, want 4 pending and 1 dropped", st)
+	}
+
+	// 目标恢复后按顺序分批发送
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	if sent, err := remote.Flush(); err != nil || sent != 4 {
+		t.Fatalf("Flush() after recovery = %d, %v, want 4", sent, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || batches[0].Source != "pve-a" || batches[0].Records[0].VMID != 101 || batches[1].Records[1].VMID != 104 {
+		t.Fatalf("batches = %+v, want [101 102] [103 104] from pve-a", batches)
+	}
+	if st := remote.RemoteWriteStats(); st.Pending != 0 || st.Sent != 4 {
+		t.Fatalf("stats after recovery = %+v, want 0 pending and 4 sent", st)
+	}
+}
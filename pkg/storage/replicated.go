@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// ReplicationStats 双写存储统计
+type ReplicationStats struct {
+	PrimaryErrors   uint64    `json:"primary_errors"`          // 主存储操作失败次数
+	SecondaryErrors uint64    `json:"secondary_errors"`        // 副本存储操作失败次数
+	Failovers       uint64    `json:"failovers"`               // 读取切换到副本的次数
+	LastError       string    `json:"last_error,omitempty"`    // 最近一次错误
+	LastErrorAt     time.Time `json:"last_error_at,omitempty"` // 最近一次错误时间
+}
+
+// ReplicationReporter 双写存储（用于诊断和系统统计）
+type ReplicationReporter interface {
+	ReplicationStats() ReplicationStats
+}
+
+// ReplicatedStorage 双写存储（实现 Interface 接口）
+// 写入同时发送到主存储和副本存储，任一成功即视为成功；读取优先使用主存储，失败时切换到副本
+type ReplicatedStorage struct {
+	primary   Interface
+	secondary Interface
+
+	mu    sync.Mutex
+	stats ReplicationStats
+}
+
+// NewReplicatedStorage 创建双写存储
+func NewReplicatedStorage(primary, secondary Interface) *ReplicatedStorage {
+	return &ReplicatedStorage{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+// ReplicationStats 获取双写统计
+func (r *ReplicatedStorage) ReplicationStats() ReplicationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// noteError 记录主存储或副本存储的错误
+func (r *ReplicatedStorage) noteError(primary bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if primary {
+		r.stats.PrimaryErrors++
+		r.stats.LastError = "primary: " + err.Error()
+	} else {
+		r.stats.SecondaryErrors++
+		r.stats.LastError = "secondary: " + err.Error()
+	}
+	r.stats.LastErrorAt = time.Now()
+}
+
+// write 在两个存储上执行写操作，两者都失败时返回错误
+func (r *ReplicatedStorage) write(op string, fn func(Interface) error) error {
+	_, err := writeBoth(r, op, func(s Interface) (struct{}, error) {
+		return struct{}{}, fn(s)
+	})
+	return err
+}
+
+// writeBoth 在两个存储上执行写操作，优先返回主存储的结果
+func writeBoth[T any](r *ReplicatedStorage, op string, fn func(Interface) (T, error)) (T, error) {
+	pv, perr := fn(r.primary)
+	sv, serr := fn(r.secondary)
+
+	if serr != nil {
+		r.noteError(false, serr)
+	}
+	if perr == nil {
+		if serr != nil {
+			log.Printf("副本存储%s失败: %v", op, serr)
+		}
+		return pv, nil
+	}
+
+	r.noteError(true, perr)
+	if serr != nil {
+		return pv, fmt.Errorf("主存储和副本存储%s均失败: %w (副本: %v)", op, perr, serr)
+	}
+	log.Printf("主存储%s失败，已写入副本存储: %v", op, perr)
+	return sv, nil
+}
+
+// RebuildRecordCount 在两个存储上重建记录计数器（不支持重建的存储重新读取计数）
+func (r *ReplicatedStorage) RebuildRecordCount() (int64, error) {
+	return writeBoth(r, "重建记录计数器", func(s Interface) (int64, error) {
+		if rebuilder, ok := As[CounterRebuilder](s); ok {
+			return rebuilder.RebuildRecordCount()
+		}
+		return s.GetTotalRecordCount()
+	})
+}
+
+// EarliestRecordTime 获取虚拟机最早的流量记录时间（存储不支持直接查询时返回零值）
+func (r *ReplicatedStorage) EarliestRecordTime(vmid int) (time.Time, error) {
+	return readFailover(r, func(s Interface) (time.Time, error) {
+		return EarliestRecordTime(s, vmid)
+	})
+}
+
+// RecordVMIDs 时间范围内有流量记录的虚拟机
+func (r *ReplicatedStorage) RecordVMIDs(startTime, endTime time.Time) ([]int, error) {
+	return readFailover(r, func(s Interface) ([]int, error) {
+		return RecordVMIDs(s, startTime, endTime)
+	})
+}
+
+// SaveNodeTrafficRecord 保存节点流量记录（不支持的存储跳过）
+func (r *ReplicatedStorage) SaveNodeTrafficRecord(node string, record models.TrafficRecord) error {
+	return r.write("保存节点流量记录", func(s Interface) error {
+		return SaveNodeTrafficRecord(s, node, record)
+	})
+}
+
+// GetNodeTrafficRecords 获取节点流量记录
+func (r *ReplicatedStorage) GetNodeTrafficRecords(node string, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	return readFailover(r, func(s Interface) ([]models.TrafficRecord, error) {
+		return GetNodeTrafficRecords(s, node, startTime, endTime)
+	})
+}
+
+// DeleteActionLogs 在两个存储上删除操作日志
+func (r *ReplicatedStorage) DeleteActionLogs(vmid int, startTime, endTime time.Time, dryRun bool) (int64, error) {
+	return writeBoth(r, "删除操作日志", func(s Interface) (int64, error) {
+		if cleaner, ok := As[DataCleaner](s); ok {
+			return cleaner.DeleteActionLogs(vmid, startTime, endTime, dryRun)
+		}
+		return 0, nil
+	})
+}
+
+// DeleteVMStates 在两个存储上删除虚拟机状态
+func (r *ReplicatedStorage) DeleteVMStates(match func(vmid int, updatedAt time.Time) bool, dryRun bool) (int64, error) {
+	return writeBoth(r, "删除虚拟机状态", func(s Interface) (int64, error) {
+		if cleaner, ok := As[DataCleaner](s); ok {
+			return cleaner.DeleteVMStates(match, dryRun)
+		}
+		return 0, nil
+	})
+}
+
+// SaveMetadata 在两个存储上保存运行信息
+func (r *ReplicatedStorage) SaveMetadata(name string, data []byte) error {
+	return r.write("保存运行信息", func(s Interface) error {
+		if store, ok := As[MetadataStore](s); ok {
+			return store.SaveMetadata(name, data)
+		}
+		return ErrMetadataUnsupported
+	})
+}
+
+// LoadMetadata 读取运行信息
+func (r *ReplicatedStorage) LoadMetadata(name string) ([]byte, error) {
+	return readFailover(r, func(s Interface) ([]byte, error) {
+		if store, ok := As[MetadataStore](s); ok {
+			return store.LoadMetadata(name)
+		}
+		return nil, nil
+	})
+}
+
+// AcquireLease 只在主存储上获取租约（两个存储各自的租约可能不一致，不做双写）
+func (r *ReplicatedStorage) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	return AcquireLease(r.primary, name, holder, ttl, now)
+}
+
+// ReleaseLease 释放主存储上的租约
+func (r *ReplicatedStorage) ReleaseLease(name, holder string) error {
+	return ReleaseLease(r.primary, name, holder)
+}
+
+// GetLease 读取主存储上的租约
+func (r *ReplicatedStorage) GetLease(name string) (*Lease, error) {
+	return GetLease(r.primary, name)
+}
+
+// Compact 依次压缩主存储和副本存储（不支持压缩的存储跳过）
+func (r *ReplicatedStorage) Compact(progress func(CompactProgress)) (CompactResult, error) {
+	return writeBoth(r, "压缩", func(s Interface) (CompactResult, error) {
+		result, err := Compact(s, progress)
+		if errors.Is(err, ErrCompactUnsupported) {
+			return result, nil
+		}
+		return result, err
+	})
+}
+
+// CheckConsistency 检查两个存储中支持启动检查的存储
+func (r *ReplicatedStorage) CheckConsistency(since time.Time) (ConsistencyReport, error) {
+	return writeBoth(r, "一致性检查", func(s Interface) (ConsistencyReport, error) {
+		if checker, ok := As[ConsistencyChecker](s); ok {
+			return checker.CheckConsistency(since)
+		}
+		return ConsistencyReport{}, nil
+	})
+}
+
+// Verify 依次检查主存储和副本存储（记录数取主存储的统计，副本存储的问题位置加 secondary: 前缀）
+func (r *ReplicatedStorage) Verify(repair bool) (VerifyReport, error) {
+	report, err := verifyStorage(r.primary, repair)
+	if err != nil {
+		return report, fmt.Errorf("检查主存储失败: %w", err)
+	}
+	secondary, err := verifyStorage(r.secondary, repair)
+	if err != nil {
+		return report, fmt.Errorf("检查副本存储失败: %w", err)
+	}
+	for _, issue := range secondary.Issues {
+		issue.Location = "secondary:" + issue.Location
+		report.Issues = append(report.Issues, issue)
+	}
+	return report, nil
+}
+
+// verifyStorage 检查支持完整性检查的存储（不支持时返回空结果）
+func verifyStorage(s Interface, repair bool) (VerifyReport, error) {
+	if verifier, ok := As[Verifier](s); ok {
+		return verifier.Verify(repair)
+	}
+	return VerifyReport{Cached: -1}, nil
+}
+
+// readFailover 从主存储读取，失败时切换到副本存储
+func readFailover[T any](r *ReplicatedStorage, fn func(Interface) (T, error)) (T, error) {
+	v, err := fn(r.primary)
+	if err == nil {
+		return v, nil
+	}
+
+	r.noteError(true, err)
+	r.mu.Lock()
+	r.stats.Failovers++
+	r.mu.Unlock()
+
+	sv, serr := fn(r.secondary)
+	if serr != nil {
+		r.noteError(false, serr)
+		return v, err
+	}
+	return sv, nil
+}
+
+// SaveTrafficRecord 保存流量记录
+func (r *ReplicatedStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	return r.write("保存流量记录", func(s Interface) error {
+		return s.SaveTrafficRecord(record)
+	})
+}
+
+// SaveTrafficRecords 批量保存流量记录
+func (r *ReplicatedStorage) SaveTrafficRecords(records []models.TrafficRecord) error {
+	return r.write("保存流量记录", func(s Interface) error {
+		return SaveTrafficRecords(s, records)
+	})
+}
+
+// GetTrafficRecords 获取流量记录
+func (r *ReplicatedStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	return readFailover(r, func(s Interface) ([]models.TrafficRecord, error) {
+		return s.GetTrafficRecords(vmid, startTime, endTime)
+	})
+}
+
+// CalculateTrafficStats 计算流量统计
+func (r *ReplicatedStorage) CalculateTrafficStats(vmid int, period string) (*models.TrafficStats, error) {
+	return readFailover(r, func(s Interface) (*models.TrafficStats, error) {
+		return s.CalculateTrafficStats(vmid, period)
+	})
+}
+
+// CalculateTrafficStatsWithTime 使用指定时间计算流量统计
+func (r *ReplicatedStorage) CalculateTrafficStatsWithTime(vmid int, period string, creationTime time.Time, useCreationTime bool) (*models.TrafficStats, error) {
+	return readFailover(r, func(s Interface) (*models.TrafficStats, error) {
+		return s.CalculateTrafficStatsWithTime(vmid, period, creationTime, useCreationTime)
+	})
+}
+
+// CalculateTrafficStatsWithDirection 使用指定方向和时间计算流量统计
+func (r *ReplicatedStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
+	return readFailover(r, func(s Interface) (*models.TrafficStats, error) {
+		return s.CalculateTrafficStatsWithDirection(vmid, period, creationTime, useCreationTime, direction)
+	})
+}
+
+// CalculateTrafficStatsWithTimeRange 使用自定义时间范围计算流量统计
+func (r *ReplicatedStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime, endTime time.Time, direction string) (*models.TrafficStats, error) {
+	return readFailover(r, func(s Interface) (*models.TrafficStats, error) {
+		return s.CalculateTrafficStatsWithTimeRange(vmid, startTime, endTime, direction)
+	})
+}
+
+// SaveActionLog 保存操作日志
+func (r *ReplicatedStorage) SaveActionLog(actionLog models.ActionLog) error {
+	return r.write("保存操作日志", func(s Interface) error {
+		return s.SaveActionLog(actionLog)
+	})
+}
+
+// GetActionLogs 获取操作日志
+func (r *ReplicatedStorage) GetActionLogs(startTime, endTime time.Time) ([]models.ActionLog, error) {
+	return readFailover(r, func(s Interface) ([]models.ActionLog, error) {
+		return s.GetActionLogs(startTime, endTime)
+	})
+}
+
+// SaveVMState 保存虚拟机状态
+func (r *ReplicatedStorage) SaveVMState(vmid int, state map[string]interface{}) error {
+	return r.write("保存虚拟机状态", func(s Interface) error {
+		return s.SaveVMState(vmid, state)
+	})
+}
+
+// LoadVMState 加载虚拟机状态
+func (r *ReplicatedStorage) LoadVMState(vmid int) (map[string]interface{}, error) {
+	return readFailover(r, func(s Interface) (map[string]interface{}, error) {
+		return s.LoadVMState(vmid)
+	})
+}
+
+// CleanupOldData 清理旧数据
+func (r *ReplicatedStorage) CleanupOldData(retentionDays int) error {
+	return r.write("清理旧数据", func(s Interface) error {
+		return s.CleanupOldData(retentionDays)
+	})
+}
+
+// GetTotalRecordCount 获取总采样点数
+func (r *ReplicatedStorage) GetTotalRecordCount() (int64, error) {
+	return readFailover(r, func(s Interface) (int64, error) {
+		return s.GetTotalRecordCount()
+	})
+}
+
+// DeleteRecordsInRange 删除指定时间范围内的记录
+func (r *ReplicatedStorage) DeleteRecordsInRange(vmid int, startTime, endTime time.Time) (int64, error) {
+	return writeBoth(r, "删除记录", func(s Interface) (int64, error) {
+		return s.DeleteRecordsInRange(vmid, startTime, endTime)
+	})
+}
+
+// CountRecordsInRange 统计指定时间范围内的记录数
+func (r *ReplicatedStorage) CountRecordsInRange(vmid int, startTime, endTime time.Time) (int64, error) {
+	return readFailover(r, func(s Interface) (int64, error) {
+		return s.CountRecordsInRange(vmid, startTime, endTime)
+	})
+}
+
+// DeleteRecordsBefore 删除指定日期之前的所有记录
+func (r *ReplicatedStorage) DeleteRecordsBefore(beforeTime time.Time) (int64, error) {
+	return writeBoth(r, "删除记录", func(s Interface) (int64, error) {
+		return s.DeleteRecordsBefore(beforeTime)
+	})
+}
+
+// CountRecordsBefore 统计指定日期之前的记录数
+func (r *ReplicatedStorage) CountRecordsBefore(beforeTime time.Time) (int64, error) {
+	return readFailover(r, func(s Interface) (int64, error) {
+		return s.CountRecordsBefore(beforeTime)
+	})
+}
+
+// Close 关闭两个存储
+func (r *ReplicatedStorage) Close() error {
+	perr := r.primary.Close()
+	serr := r.secondary.Close()
+	if perr != nil {
+		return perr
+	}
+	return serr
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// downStorage 可模拟整体不可用的存储
+type downStorage struct {
+	Interface
+	down bool
+}
+
+var errStorageDown = errors.New("connection refused")
+
+func (d *downStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	if d.down {
+		return errStorageDown
+	}
+	return d.Interface.SaveTrafficRecord(record)
+}
+
+func (d *downStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	if d.down {
+		return nil, errStorageDown
+	}
+	return d.Interface.GetTrafficRecords(vmid, startTime, endTime)
+}
+
+func TestReplicatedStorageFailover(t *testing.T) {
+	primaryFiles, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("create primary: %v", err)
+	}
+	secondary, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("create secondary: %v", err)
+	}
+	primary := &downStorage{Interface: primaryFiles}
+	store := NewReplicatedStorage(primary, secondary)
+	defer store.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: baseTime, TotalBytes: 1}); err != nil {
+		t.Fatalf("save with both up: %v", err)
+	}
+
+	// 主存储不可用时写入副本，读取切换到副本
+	primary.down = true
+	if err := store.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: baseTime.Add(time.Minute), TotalBytes: 2}); err != nil {
+		t.Fatalf("save with primary down: %v", err)
+	}
+	records, err := store.GetTrafficRecords(100, baseTime.Add(-time.Hour), baseTime.Add(time.Hour))
+	if err != nil || len(records) != 2 {
+		t.Fatalf("read with primary down = %d records, %v, want 2 from replica", len(records), err)
+	}
+
+	st := store.ReplicationStats()
+	if st.PrimaryErrors != 2 || st.SecondaryErrors != 0 || st.Failovers != 1 || st.LastError == "" {
+		t.Fatalf("stats = %+v, want 2 primary errors and 1 failover", st)
+	}
+
+	// 主存储恢复后读取主存储（停机期间的采样只在副本中）
+	primary.down = false
+	records, err = store.GetTrafficRecords(100, baseTime.Add(-time.Hour), baseTime.Add(time.Hour))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("read with primary up = %d records, %v, want 1 from primary", len(records), err)
+	}
+
+	if _, ok := As[ReplicationReporter](store); !ok {
+		t.Fatalf("As[ReplicationReporter] failed on replicated storage")
+	}
+}
+
+func TestStorageConfigReplicaValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		replica *models.StorageConfig
+		wantErr bool
+	}{
+		{"sqlite replica", &models.StorageConfig{Type: "sqlite", DSN: "./data/replica.db"}, false},
+		{"same backend", &models.StorageConfig{Type: "postgresql", DSN: "postgres://db/pve"}, true},
+		{"nested replica", &models.StorageConfig{Type: "sqlite", DSN: "a.db", Replica: &models.StorageConfig{Type: "sqlite", DSN: "b.db"}}, true},
+		{"missing dsn", &models.StorageConfig{Type: "mysql"}, true},
+	}
+	for _, tt := range tests {
+		cfg := models.StorageConfig{Type: "postgres", DSN: "postgres://db/pve", Replica: tt.replica}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// RetentionResult 按数据类型清理的结果
+type RetentionResult struct {
+	ActionLogs int64
+	States     int64
+}
+
+// CleanupRetention 按数据类型的保留期清理操作日志和虚拟机状态（存储不支持时不清理）
+// existing 为当前仍存在的虚拟机，它们的状态即使长期未更新也保留（可能仍在等待恢复）；为 nil 时不清理状态
+func CleanupRetention(s Interface, retention models.RetentionConfig, existing map[int]bool, now time.Time) (RetentionResult, error) {
+	var result RetentionResult
+	cleaner, ok := As[DataCleaner](s)
+	if !ok {
+		return result, nil
+	}
+
+	if retention.ActionLogDays > 0 {
+		count, err := cleaner.DeleteActionLogs(0, time.Time{}, now.AddDate(0, 0, -retention.ActionLogDays), false)
+		if err != nil {
+			return result, fmt.Errorf("清理操作日志失败: %w", err)
+		}
+		result.ActionLogs = count
+	}
+
+	if retention.StateDays > 0 && existing != nil {
+		count, err := cleaner.DeleteVMStates(StaleStates(existing, now.AddDate(0, 0, -retention.StateDays)), false)
+		if err != nil {
+			return result, fmt.Errorf("清理虚拟机状态失败: %w", err)
+		}
+		result.States = count
+	}
+
+	return result, nil
+}
+
+// StaleStates 匹配已不存在的虚拟机在 before 之前最后更新的状态
+func StaleStates(existing map[int]bool, before time.Time) func(vmid int, updatedAt time.Time) bool {
+	return func(vmid int, updatedAt time.Time) bool {
+		return !existing[vmid] && updatedAt.Before(before)
+	}
+}
+
+// ErrRecordListUnsupported 存储不支持列出有流量记录的虚拟机
+var ErrRecordListUnsupported = errors.New("存储不支持列出有流量记录的虚拟机")
+
+// RecordVMIDs [startTime, endTime) 内有流量记录的虚拟机（存储不支持时返回 ErrRecordListUnsupported）
+func RecordVMIDs(s Interface, startTime, endTime time.Time) ([]int, error) {
+	if lister, ok := As[RecordLister](s); ok {
+		return lister.RecordVMIDs(startTime, endTime)
+	}
+	return nil, ErrRecordListUnsupported
+}
+
+// StateVMIDs 存储中保存了状态的虚拟机（存储不支持时返回空）
+func StateVMIDs(s Interface) ([]int, error) {
+	cleaner, ok := As[DataCleaner](s)
+	if !ok {
+		return nil, nil
+	}
+	var vmids []int
+	_, err := cleaner.DeleteVMStates(func(vmid int, updatedAt time.Time) bool {
+		vmids = append(vmids, vmid)
+		return false
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("读取虚拟机状态失败: %w", err)
+	}
+	return vmids, nil
+}
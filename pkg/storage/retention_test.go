@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestCleanupRetention(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]*models.StorageConfig{
+		"file":   {Type: "file", FilePath: filepath.Join(dir, "file")},
+		"sqlite": {Type: "sqlite", DSN: filepath.Join(dir, "pve_traffic.db"), MaxOpenConns: 1, MaxIdleConns: 1},
+	}
+
+	for name, config := range configs {
+		store, err := NewStorageFromConfig(config)
+		if err != nil {
+			t.Fatalf("%s: create storage: %v", name, err)
+		}
+		defer store.Close()
+
+		now := time.Now()
+		for _, log := range []models.ActionLog{
+			{VMID: 101, RuleName: "old", Action: models.ActionShutdown, Timestamp: now.AddDate(0, 0, -40)},
+			{VMID: 101, RuleName: "new", Action: models.ActionShutdown, Timestamp: now.AddDate(0, 0, -1)},
+		} {
+			if err := store.SaveActionLog(log); err != nil {
+				t.Fatalf("%s: save action log: %v", name, err)
+			}
+		}
+		for _, vmid := range []int{101, 102} {
+			if err := store.SaveVMState(vmid, map[string]interface{}{"seen": true}); err != nil {
+				t.Fatalf("%s: save vm state: %v", name, err)
+			}
+		}
+
+		// 按虚拟机和时间范围删除（dry run 只统计）
+		if err := store.SaveActionLog(models.ActionLog{VMID: 102, RuleName: "other", Action: models.ActionShutdown, Timestamp: now.AddDate(0, 0, -1)}); err != nil {
+			t.Fatalf("%s: save action log: %v", name, err)
+		}
+		cleaner, _ := As[DataCleaner](store)
+		if count, err := cleaner.DeleteActionLogs(102, now.AddDate(0, 0, -2), now, true); err != nil || count != 1 {
+			t.Fatalf("%s: DeleteActionLogs(dry run) = %d, %v, want 1", name, count, err)
+		}
+		if count, err := cleaner.DeleteActionLogs(102, now.AddDate(0, 0, -2), now, false); err != nil || count != 1 {
+			t.Fatalf("%s: DeleteActionLogs(102) = %d, %v, want 1", name, count, err)
+		}
+
+		// 10 天后清理：VM 102 已不存在，状态超过 5 天未更新
+		retention := models.RetentionConfig{ActionLogDays: 30, StateDays: 5}
+		result, err := CleanupRetention(store, retention, map[int]bool{101: true}, now.AddDate(0, 0, 10))
+		if err != nil || result.ActionLogs != 1 || result.States != 1 {
+			t.Fatalf("%s: CleanupRetention() = %+v, %v, want one log and one state", name, result, err)
+		}
+
+		logs, _ := store.GetActionLogs(now.AddDate(0, 0, -50), now)
+		if len(logs) != 1 || logs[0].RuleName != "new" {
+			t.Fatalf("%s: logs = %+v, want only the recent log", name, logs)
+		}
+		if state, _ := store.LoadVMState(101); state["seen"] != true {
+			t.Fatalf("%s: state of existing VM = %v, want kept", name, state)
+		}
+		if state, _ := store.LoadVMState(102); len(state) != 0 {
+			t.Fatalf("%s: state of removed VM = %v, want deleted", name, state)
+		}
+
+		if last, err := LastCleanup(store); last != nil || err != nil {
+			t.Fatalf("%s: LastCleanup() before saving = %+v, %v, want nil", name, last, err)
+		}
+		for _, states := range []int64{1, 2} {
+			if err := SaveLastCleanup(store, models.CleanupResult{StartedAt: now, ActionLogs: result.ActionLogs, States: states}); err != nil {
+				t.Fatalf("%s: SaveLastCleanup() error = %v", name, err)
+			}
+		}
+		if last, err := LastCleanup(store); err != nil || last == nil || last.States != 2 || !last.StartedAt.Equal(now) {
+			t.Fatalf("%s: LastCleanup() = %+v, %v, want the latest result", name, last, err)
+		}
+	}
+}
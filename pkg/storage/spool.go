@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+	"pve-traffic-monitor/pkg/utils"
+)
+
+// spoolFileName 本地缓冲文件名
+const spoolFileName = "traffic_spool.jsonl"
+
+// spoolReplayBatch 重放时每批写入后端的记录数
+const spoolReplayBatch = 500
+
+// SpoolStats 本地缓冲统计
+type SpoolStats struct {
+	Backlog     int       `json:"backlog"`                 // 等待重放的记录数
+	Spooled     uint64    `json:"spooled"`                 // 累计写入缓冲的记录数
+	Replayed    uint64    `json:"replayed"`                // 累计重放成功的记录数
+	Dropped     uint64    `json:"dropped"`                 // 缓冲已满或损坏而丢弃的记录数
+	LastError   string    `json:"last_error,omitempty"`    // 最近一次存储写入错误
+	LastErrorAt time.Time `json:"last_error_at,omitempty"` // 最近一次存储写入错误时间
+}
+
+// SpoolReporter 带本地缓冲的存储（用于诊断和系统统计）
+type SpoolReporter interface {
+	SpoolStats() SpoolStats
+}
+
+// SpoolStorage 带本地缓冲的存储包装器
+// 后端写入流量记录失败时暂存到本地 JSONL 文件，后端恢复后按顺序重放
+type SpoolStorage struct {
+	Interface
+	path       string
+	maxRecords int
+
+	mu    sync.Mutex
+	stats SpoolStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSpoolStorage 创建带本地缓冲的存储（maxRecords <= 0 使用默认值）
+func NewSpoolStorage(backend Interface, dir string, maxRecords int) (*SpoolStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建本地缓冲目录失败: %w", err)
+	}
+	if maxRecords <= 0 {
+		maxRecords = models.DefaultSpoolMaxRecords
+	}
+
+	s := &SpoolStorage{
+		Interface:  backend,
+		path:       filepath.Join(dir, spoolFileName),
+		maxRecords: maxRecords,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// 恢复上次退出时未重放的记录
+	records, err := s.readSpool()
+	if err != nil {
+		return nil, err
+	}
+	s.stats.Backlog = len(records)
+	if len(records) > 0 {
+		log.Printf("本地缓冲中有 %d 条待重放的流量记录", len(records))
+	}
+
+	go s.replayLoop(models.SpoolReplayInterval)
+	return s, nil
+}
+
+// SaveTrafficRecord 保存流量记录，后端失败或仍有积压时写入本地缓冲
+func (s *SpoolStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	return s.save([]models.TrafficRecord{record}, func() error {
+		return s.Interface.SaveTrafficRecord(record)
+	})
+}
+
+// SaveTrafficRecords 批量保存流量记录，后端失败或仍有积压时整批写入本地缓冲
+func (s *SpoolStorage) SaveTrafficRecords(records []models.TrafficRecord) error {
+	return s.save(records, func() error {
+		return SaveTrafficRecords(s.Interface, records)
+	})
+}
+
+// save 没有积压时写入后端，失败时写入本地缓冲
+// 有积压时新记录直接追加到缓冲末尾，由重放按顺序写入，避免新记录先于积压的旧记录写入后端
+// （旧记录晚于新记录写入时，按时间读取的计数器差值会被当作重启）
+func (s *SpoolStorage) save(records []models.TrafficRecord, write func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.Backlog > 0 {
+		backlog, err := s.spool(records)
+		if err != nil {
+			return fmt.Errorf("本地缓冲有待重放的记录，%w", err)
+		}
+		utils.DebugLog("本地缓冲有待重放的记录，%d 条流量记录已追加到缓冲 (积压 %d 条)", len(records), backlog)
+		return nil
+	}
+
+	err := write()
+	if err == nil {
+		return nil
+	}
+
+	s.recordError(err)
+	backlog, spoolErr := s.spool(records)
+	if spoolErr != nil {
+		return fmt.Errorf("%w (写入本地缓冲失败: %v)", err, spoolErr)
+	}
+	if len(records) == 1 {
+		log.Printf("存储写入失败，流量记录已写入本地缓冲 (积压 %d 条): %v", backlog, err)
+	} else {
+		log.Printf("存储写入失败，%d 条流量记录已写入本地缓冲 (积压 %d 条): %v", len(records), backlog, err)
+	}
+	return nil
+}
+
+// Unwrap 返回被包装的存储
+func (s *SpoolStorage) Unwrap() Interface {
+	return s.Interface
+}
+
+// SpoolStats 获取本地缓冲统计
+func (s *SpoolStorage) SpoolStats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Replay 将缓冲的记录按顺序写入后端，遇到错误时停止并保留剩余记录
+func (s *SpoolStorage) Replay() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.Backlog == 0 {
+		return 0, nil
+	}
+
+	records, err := s.readSpool()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	var replayErr error
+	for replayed < len(records) {
+		batch := records[replayed:min(replayed+spoolReplayBatch, len(records))]
+		if replayErr = SaveTrafficRecords(s.Interface, batch); replayErr != nil {
+			s.recordError(replayErr)
+			break
+		}
+		replayed += len(batch)
+	}
+
+	if err := s.writeSpool(records[replayed:]); err != nil {
+		return replayed, err
+	}
+	s.stats.Replayed += uint64(replayed)
+	s.stats.Backlog = len(records) - replayed
+
+	return replayed, replayErr
+}
+
+// Close 停止重放并关闭后端（未重放的记录保留在本地缓冲中，下次启动时重放）
+func (s *SpoolStorage) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.Interface.Close()
+}
+
+// spool 追加记录到本地缓冲，返回当前积压数（调用方持有锁）
+func (s *SpoolStorage) spool(records []models.TrafficRecord) (int, error) {
+	if s.stats.Backlog+len(records) > s.maxRecords {
+		s.stats.Dropped += uint64(len(records))
+		return s.stats.Backlog, fmt.Errorf("本地缓冲已满 (%d 条)", s.maxRecords)
+	}
+
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return s.stats.Backlog, fmt.Errorf("序列化流量记录失败: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return s.stats.Backlog, fmt.Errorf("打开本地缓冲文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return s.stats.Backlog, fmt.Errorf("写入本地缓冲失败: %w", err)
+	}
+
+	s.stats.Spooled += uint64(len(records))
+	s.stats.Backlog += len(records)
+	return s.stats.Backlog, nil
+}
+
+// recordError 记录最近一次存储写入错误（调用方持有锁）
+func (s *SpoolStorage) recordError(err error) {
+	s.stats.LastError = err.Error()
+	s.stats.LastErrorAt = time.Now()
+}
+
+// readSpool 读取缓冲文件中的全部记录（跳过损坏的行）
+func (s *SpoolStorage) readSpool() ([]models.TrafficRecord, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开本地缓冲文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var records []models.TrafficRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record models.TrafficRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			s.stats.Dropped++
+			utils.DebugLog("跳过损坏的缓冲记录: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取本地缓冲文件失败: %w", err)
+	}
+	return records, nil
+}
+
+// writeSpool 用剩余记录替换缓冲文件（先写临时文件再重命名）
+func (s *SpoolStorage) writeSpool(records []models.TrafficRecord) error {
+	if len(records) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除本地缓冲文件失败: %w", err)
+		}
+		return nil
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("创建本地缓冲临时文件失败: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("写入本地缓冲临时文件失败: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("写入本地缓冲临时文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入本地缓冲临时文件失败: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换本地缓冲文件失败: %w", err)
+	}
+	return nil
+}
+
+// replayLoop 定期重放本地缓冲
+func (s *SpoolStorage) replayLoop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			replayed, err := s.Replay()
+			if replayed > 0 {
+				log.Printf("本地缓冲已重放 %d 条流量记录", replayed)
+			}
+			if err != nil {
+				utils.DebugLog("本地缓冲重放中断: %v", err)
+			}
+		}
+	}
+}
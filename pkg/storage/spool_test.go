@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// flakyStorage 可切换写入失败的存储
+type flakyStorage struct {
+	Interface
+	fail    bool
+	records []models.TrafficRecord
+}
+
+func (f *flakyStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	if f.fail {
+		return errors.New("database is down")
+	}
+	f.records = append(f.records, record)
+	return nil
+}
+
+func (f *flakyStorage) Close() error { return nil }
+
+func TestSpoolStorageBuffersAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	backend := &flakyStorage{fail: true}
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	spool, err := NewSpoolStorage(backend, dir, 2)
+	if err != nil {
+		t.Fatalf("create spool: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := spool.SaveTrafficRecord(models.TrafficRecord{VMID: 100 + i, Timestamp: baseTime}); err != nil {
+			t.Fatalf("save while backend down: %v", err)
+		}
+	}
+	// 超过缓冲上限时返回错误
+	if err := spool.SaveTrafficRecord(models.TrafficRecord{VMID: 102, Timestamp: baseTime}); err == nil {
+		t.Fatalf("save beyond spool limit succeeded, want error")
+	}
+	if st := spool.SpoolStats(); st.Backlog != 2 || st.Spooled != 2 || st.Dropped != 1 || st.LastError == "" {
+		t.Fatalf("stats = %+v, want backlog 2, spooled 2, dropped 1 and last error", st)
+	}
+	spool.Close()
+
+	// 重启后恢复积压，后端恢复后按顺序重放
+	spool, err = NewSpoolStorage(backend, dir, 2)
+	if err != nil {
+		t.Fatalf("reopen spool: %v", err)
+	}
+	defer spool.Close()
+	if st := spool.SpoolStats(); st.Backlog != 2 {
+		t.Fatalf("backlog after restart = %d, want 2", st.Backlog)
+	}
+
+	if n, err := spool.Replay(); n != 0 || err == nil {
+		t.Fatalf("Replay while down = %d, %v, want 0 and error", n, err)
+	}
+
+	backend.fail = false
+	if n, err := spool.Replay(); n != 2 || err != nil {
+		t.Fatalf("Replay = %d, %v, want 2, nil", n, err)
+	}
+	if len(backend.records) != 2 || backend.records[0].VMID != 100 || backend.records[1].VMID != 101 {
+		t.Fatalf("backend records = %+v, want VM100 then VM101", backend.records)
+	}
+	if st := spool.SpoolStats(); st.Backlog != 0 || st.Replayed != 2 {
+		t.Fatalf("stats after replay = %+v, want backlog 0, replayed 2", st)
+	}
+	if _, err := os.Stat(filepath.Join(dir, spoolFileName)); !os.IsNotExist(err) {
+		t.Fatalf("spool file still exists after replay: %v", err)
+	}
+}
+
+func TestSpoolStorageKeepsOrderWhileBacklogged(t *testing.T) {
+	backend := &flakyStorage{fail: true}
+	spool, err := NewSpoolStorage(backend, t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("create spool: %v", err)
+	}
+	defer spool.Close()
+
+	baseTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	spool.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: baseTime, RXBytes: 1})
+
+	// 后端恢复后、重放之前的新记录排在积压之后
+	backend.fail = false
+	if err := spool.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: baseTime.Add(time.Minute), RXBytes: 2}); err != nil {
+		t.Fatalf("save while backlogged: %v", err)
+	}
+	if len(backend.records) != 0 {
+		t.Fatalf("backend records = %+v, want the new record queued behind the backlog", backend.records)
+	}
+	if n, err := spool.Replay(); n != 2 || err != nil {
+		t.Fatalf("Replay = %d, %v, want 2, nil", n, err)
+	}
+	if len(backend.records) != 2 || backend.records[0].RXBytes != 1 || backend.records[1].RXBytes != 2 {
+		t.Fatalf("backend records = %+v, want replay in write order", backend.records)
+	}
+
+	// 积压清空后直接写入后端
+	spool.SaveTrafficRecord(models.TrafficRecord{VMID: 100, Timestamp: baseTime.Add(2 * time.Minute), RXBytes: 3})
+	if len(backend.records) != 3 || spool.SpoolStats().Backlog != 0 {
+		t.Fatalf("backend records = %d, backlog = %d, want a direct write", len(backend.records), spool.SpoolStats().Backlog)
+	}
+}
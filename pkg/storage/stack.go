@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// StackOtherVMID 合并的其他虚拟机使用的 VMID
+const StackOtherVMID = 0
+
+// StackedSeries 叠加图中一个虚拟机（或合并的其他虚拟机）的流量序列
+type StackedSeries struct {
+	VMID       int
+	Name       string
+	Points     []AggregatedPoint // 各时间段的增量（StackTraffic 后与 HostTraffic.Timestamps 一一对应）
+	RXBytes    uint64
+	TXBytes    uint64
+	TotalBytes uint64
+}
+
+// Bytes 按方向取序列的流量合计
+func (s StackedSeries) Bytes(direction string) uint64 {
+	return PointBytes(AggregatedPoint{RXBytes: s.RXBytes, TXBytes: s.TXBytes, TotalBytes: s.TotalBytes}, direction)
+}
+
+// HostTraffic 按虚拟机叠加的主机流量趋势
+type HostTraffic struct {
+	Timestamps []time.Time
+	Series     []StackedSeries   // 按方向的流量从大到小，超过 top 的虚拟机合并为一个序列（VMID 为 StackOtherVMID）
+	Total      []AggregatedPoint // 每个时间段所有虚拟机的合计
+}
+
+// StackTraffic 将各虚拟机按相同周期或步长聚合的结果对齐到相同的时间点（缺少的时间段为 0），
+// 按方向的流量排序，保留前 top 个虚拟机（0 表示全部），其余合并为一个序列
+func StackTraffic(series []StackedSeries, direction string, top int) HostTraffic {
+	// 所有序列出现过的时间点
+	seen := make(map[int64]time.Time)
+	for _, s := range series {
+		for _, point := range s.Points {
+			seen[point.Timestamp.Unix()] = point.Timestamp
+		}
+	}
+	timestamps := make([]time.Time, 0, len(seen))
+	for _, t := range seen {
+		timestamps = append(timestamps, t)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+	index := make(map[int64]int, len(timestamps))
+	for i, t := range timestamps {
+		index[t.Unix()] = i
+	}
+
+	newPoints := func() []AggregatedPoint {
+		points := make([]AggregatedPoint, len(timestamps))
+		for i, t := range timestamps {
+			points[i].Timestamp = t
+		}
+		return points
+	}
+	add := func(dst *AggregatedPoint, src AggregatedPoint) {
+		dst.RXBytes += src.RXBytes
+		dst.TXBytes += src.TXBytes
+		dst.TotalBytes += src.TotalBytes
+	}
+
+	aligned := make([]StackedSeries, 0, len(series))
+	for _, s := range series {
+		out := StackedSeries{VMID: s.VMID, Name: s.Name, Points: newPoints()}
+		for _, point := range s.Points {
+			add(&out.Points[index[point.Timestamp.Unix()]], point)
+			out.RXBytes += point.RXBytes
+			out.TXBytes += point.TXBytes
+			out.TotalBytes += point.TotalBytes
+		}
+		aligned = append(aligned, out)
+	}
+	sort.SliceStable(aligned, func(i, j int) bool {
+		bi, bj := aligned[i].Bytes(direction), aligned[j].Bytes(direction)
+		if bi != bj {
+			return bi > bj
+		}
+		return aligned[i].VMID < aligned[j].VMID
+	})
+
+	if top > 0 && len(aligned) > top {
+		other := StackedSeries{VMID: StackOtherVMID, Name: "other", Points: newPoints()}
+		for _, s := range aligned[top:] {
+			for i, point := range s.Points {
+				add(&other.Points[i], point)
+			}
+			other.RXBytes += s.RXBytes
+			other.TXBytes += s.TXBytes
+			other.TotalBytes += s.TotalBytes
+		}
+		aligned = append(aligned[:top], other)
+	}
+
+	total := newPoints()
+	for _, s := range aligned {
+		for i, point := range s.Points {
+			add(&total[i], point)
+		}
+	}
+	return HostTraffic{Timestamps: timestamps, Series: aligned, Total: total}
+}
+
+// PointBytes 按方向取数据点的流量
+func PointBytes(point AggregatedPoint, direction string) uint64 {
+	switch direction {
+	case models.DirectionUpload, models.DirectionTX:
+		return point.TXBytes
+	case models.DirectionDownload, models.DirectionRX:
+		return point.RXBytes
+	default: // "both"
+		return point.TotalBytes
+	}
+}
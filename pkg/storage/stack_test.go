@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestStackTraffic(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	point := func(hour int, rx, tx uint64) AggregatedPoint {
+		return AggregatedPoint{Timestamp: t0.Add(time.Duration(hour) * time.Hour), RXBytes: rx, TXBytes: tx, TotalBytes: rx + tx}
+	}
+
+	// 101 只有 10:00 和 12:00，102 只有 11:00，103、104 上传较少
+	traffic := StackTraffic([]StackedSeries{
+		{VMID: 101, Name: "web", Points: []AggregatedPoint{point(0, 100, 500), point(2, 100, 500)}},
+		{VMID: 102, Name: "db", Points: []AggregatedPoint{point(1, 5000, 2000)}},
+		{VMID: 103, Points: []AggregatedPoint{point(0, 0, 10), point(1, 0, 10)}},
+		{VMID: 104, Points: []AggregatedPoint{point(2, 0, 5)}},
+	}, models.DirectionTX, 2)
+
+	if len(traffic.Timestamps) != 3 {
+		t.Fatalf("timestamps = %v, want 3 aligned hours", traffic.Timestamps)
+	}
+	if len(traffic.Series) != 3 || traffic.Series[0].VMID != 102 || traffic.Series[1].VMID != 101 || traffic.Series[2].VMID != StackOtherVMID {
+		t.Fatalf("series = %+v, want 102, 101 then other (by tx)", traffic.Series)
+	}
+	other := traffic.Series[2]
+	if other.TXBytes != 25 || other.Points[0].TXBytes != 10 || other.Points[2].TXBytes != 5 {
+		t.Fatalf("other = %+v, want 103 and 104 merged", other)
+	}
+	if web := traffic.Series[1]; len(web.Points) != 3 || web.Points[1].TotalBytes != 0 || !web.Points[1].Timestamp.Equal(t0.Add(time.Hour)) {
+		t.Fatalf("web points = %+v, want zero at 11:00", web.Points)
+	}
+	if got := traffic.Total[1]; got.TXBytes != 2010 || got.RXBytes != 5000 {
+		t.Fatalf("total at 11:00 = %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// UpdateVMState 合并更新虚拟机状态（SaveVMState 会覆盖整个状态，多个模块共用状态时使用）
+// updates 中值为 nil 的键会被删除
+func UpdateVMState(s Interface, vmid int, updates map[string]interface{}) error {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = make(map[string]interface{}, len(updates))
+	}
+
+	for key, value := range updates {
+		if value == nil {
+			delete(state, key)
+		} else {
+			state[key] = value
+		}
+	}
+	return s.SaveVMState(vmid, state)
+}
+
+// EarliestRecordTime 获取虚拟机最早的流量记录时间（存储不支持直接查询时返回零值）
+func EarliestRecordTime(s Interface, vmid int) (time.Time, error) {
+	if recorder, ok := As[EarliestRecorder](s); ok {
+		return recorder.EarliestRecordTime(vmid)
+	}
+	return time.Time{}, nil
+}
+
+// LoadRecoveryState 读取虚拟机待恢复的状态记录（没有处于限制中时返回 nil）
+func LoadRecoveryState(s Interface, vmid int) (*models.VMState, error) {
+	persisted, err := s.LoadVMState(vmid)
+	if err != nil {
+		return nil, err
+	}
+	if needs, _ := persisted["needs_recovery"].(bool); !needs {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return nil, err
+	}
+	var state models.VMState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析虚拟机 %d 的恢复记录失败: %w", vmid, err)
+	}
+	state.VMID = vmid
+	return &state, nil
+}
+
+// LastRecoveredAt 读取虚拟机最近一次被恢复的时间（没有记录时返回零值）
+func LastRecoveredAt(s Interface, vmid int) time.Time {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return time.Time{}
+	}
+	switch v := state["recovered_at"].(type) {
+	case time.Time:
+		return v
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// executedActionsKey 虚拟机状态中按规则保存的已执行操作
+const executedActionsKey = "executed_actions"
+
+// SaveExecutedAction 记录虚拟机按规则执行过的操作（每条规则只保留最近一次）
+func SaveExecutedAction(s Interface, vmid int, action models.ExecutedAction) error {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return err
+	}
+	executed, err := executedActions(state)
+	if err != nil {
+		// 无法解析的旧记录直接覆盖
+		executed = make(map[string]models.ExecutedAction)
+	}
+	executed[action.Rule] = action
+	return UpdateVMState(s, vmid, map[string]interface{}{executedActionsKey: executed})
+}
+
+// LoadExecutedAction 读取虚拟机按规则最近一次执行的操作（没有记录时返回 nil）
+func LoadExecutedAction(s Interface, vmid int, rule string) (*models.ExecutedAction, error) {
+	state, err := s.LoadVMState(vmid)
+	if err != nil {
+		return nil, err
+	}
+	executed, err := executedActions(state)
+	if err != nil {
+		return nil, err
+	}
+	if action, ok := executed[rule]; ok {
+		return &action, nil
+	}
+	return nil, nil
+}
+
+// executedActions 解析状态中的已执行操作（存储序列化后为通用的 map，经 JSON 转换）
+func executedActions(state map[string]interface{}) (map[string]models.ExecutedAction, error) {
+	executed := make(map[string]models.ExecutedAction)
+	raw, ok := state[executedActionsKey]
+	if !ok || raw == nil {
+		return executed, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &executed); err != nil {
+		return nil, fmt.Errorf("解析已执行操作记录失败: %w", err)
+	}
+	return executed, nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestExecutedActions(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]*models.StorageConfig{
+		"file":   {Type: "file", FilePath: filepath.Join(dir, "file")},
+		"sqlite": {Type: "sqlite", DSN: filepath.Join(dir, "pve_traffic.db"), MaxOpenConns: 1, MaxIdleConns: 1},
+	}
+
+	periodStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	executedAt := periodStart.Add(36 * time.Hour)
+
+	for name, config := range configs {
+		store, err := NewStorageFromConfig(config)
+		if err != nil {
+			t.Fatalf("%s: create storage: %v", name, err)
+		}
+		defer store.Close()
+
+		// 执行记录与状态中的其他键共存
+		if err := UpdateVMState(store, 100, map[string]interface{}{"creation_time": "2025-01-01T00:00:00Z"}); err != nil {
+			t.Fatalf("%s: UpdateVMState() error = %v", name, err)
+		}
+		if executed, err := LoadExecutedAction(store, 100, "monthly"); err != nil || executed != nil {
+			t.Fatalf("%s: LoadExecutedAction() before saving = %+v, %v, want nil", name, executed, err)
+		}
+		for _, action := range []models.ExecutedAction{
+			{Rule: "monthly", Action: models.ActionShutdown, PeriodStart: periodStart, ExecutedAt: executedAt},
+			{Rule: "burst", Action: models.ActionRateLimit, ExecutedAt: executedAt, Count: 3},
+		} {
+			if err := SaveExecutedAction(store, 100, action); err != nil {
+				t.Fatalf("%s: SaveExecutedAction(%s) error = %v", name, action.Rule, err)
+			}
+		}
+
+		executed, err := LoadExecutedAction(store, 100, "monthly")
+		if err != nil || executed == nil || executed.Action != models.ActionShutdown || !executed.PeriodStart.Equal(periodStart) {
+			t.Fatalf("%s: LoadExecutedAction() = %+v, %v, want the monthly shutdown", name, executed, err)
+		}
+		if executed.Executions() != 1 {
+			t.Fatalf("%s: Executions() without a count = %d, want 1", name, executed.Executions())
+		}
+		if !executed.Covers(models.ActionStop, periodStart, time.Time{}) {
+			t.Fatalf("%s: Covers() for stop in the same period = false, want true", name)
+		}
+		if executed.Covers(models.ActionShutdown, periodStart.AddDate(0, 1, 0), time.Time{}) {
+			t.Fatalf("%s: Covers() for the next period = true, want false", name)
+		}
+		if executed.Covers(models.ActionShutdown, periodStart, executedAt.Add(time.Hour)) {
+			t.Fatalf("%s: Covers() after recovery = true, want false", name)
+		}
+
+		state, err := store.LoadVMState(100)
+		if err != nil || state["creation_time"] != "2025-01-01T00:00:00Z" {
+			t.Fatalf("%s: state = %v, %v, want creation_time kept", name, state, err)
+		}
+		if burst, _ := LoadExecutedAction(store, 100, "burst"); burst == nil || !burst.PeriodStart.IsZero() || burst.Executions() != 3 {
+			t.Fatalf("%s: burst record = %+v, want a rolling record", name, burst)
+		}
+	}
+}
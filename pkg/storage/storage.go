@@ -9,6 +9,7 @@ import (
 	"pve-traffic-monitor/pkg/models"
 	periodcalc "pve-traffic-monitor/pkg/period"
 	"pve-traffic-monitor/pkg/utils"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -20,6 +21,8 @@ import (
 type FileStorage struct {
 	basePath      string
 	recordCounter *RecordCounter // 记录计数器（用于快速统计）
+	counterLost   bool           // 打开时计数器文件不存在或损坏（已在后台重建，monitor fsck 报告）
+	background    sync.WaitGroup // 后台重建和保存计数器的任务（Close 时等待完成）
 }
 
 type storedTrafficRecord struct {
@@ -29,6 +32,13 @@ type storedTrafficRecord struct {
 	RXBytes          uint64    `json:"rx_bytes"`
 	TXBytes          uint64    `json:"tx_bytes"`
 	TotalBytes       uint64    `json:"total_bytes"`
+	DiskRead         uint64    `json:"disk_read,omitempty"`
+	DiskWrite        uint64    `json:"disk_write,omitempty"`
+	IPv4RX           uint64    `json:"ipv4_rx,omitempty"`
+	IPv4TX           uint64    `json:"ipv4_tx,omitempty"`
+	IPv6RX           uint64    `json:"ipv6_rx,omitempty"`
+	IPv6TX           uint64    `json:"ipv6_tx,omitempty"`
+	Backup           bool      `json:"backup,omitempty"`
 }
 
 func (r storedTrafficRecord) trafficRecord() models.TrafficRecord {
@@ -38,6 +48,13 @@ func (r storedTrafficRecord) trafficRecord() models.TrafficRecord {
 		RXBytes:    r.RXBytes,
 		TXBytes:    r.TXBytes,
 		TotalBytes: r.TotalBytes,
+		DiskRead:   r.DiskRead,
+		DiskWrite:  r.DiskWrite,
+		IPv4RX:     r.IPv4RX,
+		IPv4TX:     r.IPv4TX,
+		IPv6RX:     r.IPv6RX,
+		IPv6TX:     r.IPv6TX,
+		Backup:     r.Backup,
 	}
 }
 
@@ -52,8 +69,13 @@ type RecordCounter struct {
 	cachedCount  int64
 	lastUpdate   time.Time
 	cacheTTL     time.Duration
-	counterFile  string
+	counterFile  string // 计数器持久化文件（为空时只保存在内存中）
 	needsRebuild bool
+
+	rebuilding  bool      // 是否正在重新统计
+	lastRebuild time.Time // 最近一次重新统计完成的时间
+	lastError   string    // 最近一次重新统计的错误
+	lastErrorAt time.Time
 }
 
 // NewFileStorage 创建新的文件存储管理器
@@ -77,10 +99,11 @@ func NewFileStorage(basePath string) (*FileStorage, error) {
 	if err := fs.recordCounter.load(); err != nil {
 		// 如果加载失败，标记需要重建
 		fs.recordCounter.needsRebuild = true
+		fs.counterLost = true
 		utils.DebugLog("计数器文件不存在或损坏，将在后台重建")
 
 		// 启动后台重建
-		go fs.rebuildCounter()
+		fs.goBackground(fs.rebuildCounter)
 	}
 
 	return fs, nil
@@ -88,6 +111,9 @@ func NewFileStorage(basePath string) (*FileStorage, error) {
 
 // Close 关闭文件存储(保存计数器并清理资源)
 func (s *FileStorage) Close() error {
+	// 等待后台任务结束，避免关闭后仍在写计数器文件
+	s.background.Wait()
+
 	// 在关闭前保存计数器，确保退出时数据准确
 	if s.recordCounter != nil {
 		if err := s.recordCounter.save(); err != nil {
@@ -106,19 +132,30 @@ func (s *FileStorage) GetTotalRecordCount() (int64, error) {
 		return count, nil
 	}
 
-	// 缓存未命中，执行实际统计
-	count, err := s.countRecordsActual()
+	// 缓存未命中，执行实际统计并更新缓存和计数器文件
+	count, err := s.recount()
 	if err != nil {
 		return 0, err
 	}
-
-	// 更新缓存和计数器文件
-	s.recordCounter.set(count)
 	s.recordCounter.save()
 
 	return count, nil
 }
 
+// recount 持有排他锁实际统计记录数并更新缓存（统计期间没有写入和删除，结果不会覆盖同时发生的增减）
+func (s *FileStorage) recount() (count int64, err error) {
+	err = s.withLock(true, func() error {
+		n, err := s.countRecordsActual()
+		if err != nil {
+			return err
+		}
+		count = n
+		s.recordCounter.set(n)
+		return nil
+	})
+	return count, err
+}
+
 // countRecordsActual 实际统计记录数（内部方法）
 func (s *FileStorage) countRecordsActual() (int64, error) {
 	var totalCount int64 = 0
@@ -204,23 +241,44 @@ func (s *FileStorage) countLinesInFile(filePath string) (int64, error) {
 	return count, nil
 }
 
+// goBackground 在后台执行 fn（Close 时等待完成）
+func (s *FileStorage) goBackground(fn func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+}
+
 // rebuildCounter 后台重建计数器
 func (s *FileStorage) rebuildCounter() {
 	utils.DebugLog("[计数器] 开始后台重建...")
 
-	count, err := s.countRecordsActual()
+	count, err := s.RebuildRecordCount()
 	if err != nil {
 		utils.DebugLog("[计数器] 重建失败: %v", err)
 		return
 	}
 
-	s.recordCounter.set(count)
-	s.recordCounter.save()
-	s.recordCounter.needsRebuild = false
-
 	utils.DebugLog("[计数器] 重建完成，总记录数: %d", count)
 }
 
+// RebuildRecordCount 重新统计所有数据文件的记录数并保存计数器
+func (s *FileStorage) RebuildRecordCount() (count int64, err error) {
+	s.recordCounter.beginRebuild()
+	defer func() { s.recordCounter.endRebuild(err) }()
+
+	count, err = s.recount()
+	if err != nil {
+		return 0, fmt.Errorf("统计记录数失败: %w", err)
+	}
+
+	if err := s.recordCounter.save(); err != nil {
+		return count, fmt.Errorf("保存计数器失败: %w", err)
+	}
+	return count, nil
+}
+
 // Storage 存储管理器(已弃用,保留以兼容旧代码)
 // 推荐使用 FileStorage
 type Storage = FileStorage
@@ -249,13 +307,36 @@ func (c *RecordCounter) get() (int64, bool) {
 	return c.cachedCount, true
 }
 
-// set 设置缓存计数
+// stale 获取计数（即使缓存已过期），从未统计过时返回 false
+func (c *RecordCounter) stale() (int64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.needsRebuild || c.lastUpdate.IsZero() {
+		return 0, false
+	}
+	return c.cachedCount, true
+}
+
+// set 设置缓存计数（实际统计后调用）
 func (c *RecordCounter) set(count int64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.cachedCount = count
 	c.lastUpdate = time.Now()
+	c.needsRebuild = false
+}
+
+// add 调整计数（不刷新缓存时间，到期后重新统计以纠正偏差）
+func (c *RecordCounter) add(delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cachedCount += delta
+	if c.cachedCount < 0 {
+		c.cachedCount = 0
+	}
 }
 
 // increment 增加计数（保存记录时调用）
@@ -269,12 +350,7 @@ func (c *RecordCounter) increment() {
 
 // load 从文件加载计数器
 func (c *RecordCounter) load() error {
-	data, err := os.ReadFile(c.counterFile)
-	if err != nil {
-		return err
-	}
-
-	count, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	count, err := readCounterFile(c.counterFile)
 	if err != nil {
 		return err
 	}
@@ -287,8 +363,21 @@ func (c *RecordCounter) load() error {
 	return nil
 }
 
+// readCounterFile 读取计数器文件中保存的记录数
+func readCounterFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
 // save 保存计数器到文件
 func (c *RecordCounter) save() error {
+	if c.counterFile == "" {
+		return nil
+	}
+
 	c.mu.RLock()
 	count := c.cachedCount
 	c.mu.RUnlock()
@@ -299,6 +388,13 @@ func (c *RecordCounter) save() error {
 
 // SaveTrafficRecord 保存流量记录（优化版：追加模式 + 计数器更新）
 func (s *FileStorage) SaveTrafficRecord(record models.TrafficRecord) error {
+	// 共享锁：多个追加可以同时进行，但不能与命令行的删除、重写交错
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	vmDir := filepath.Join(s.basePath, fmt.Sprintf("vm_%d", record.VMID))
 	if err := os.MkdirAll(vmDir, 0755); err != nil {
 		return fmt.Errorf("创建虚拟机目录失败: %w", err)
@@ -335,9 +431,52 @@ func (s *FileStorage) SaveTrafficRecord(record models.TrafficRecord) error {
 	s.recordCounter.mu.RUnlock()
 
 	if count%100 == 0 {
-		go s.recordCounter.save()
+		s.goBackground(func() { s.recordCounter.save() })
+	}
+
+	return nil
+}
+
+// SaveTrafficRecords 批量保存流量记录（按虚拟机和日期分组，每个文件只打开一次）
+func (s *FileStorage) SaveTrafficRecords(records []models.TrafficRecord) error {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 保持每个文件内的记录顺序
+	var files []string
+	lines := make(map[string][]byte)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("序列化流量记录失败: %w", err)
+		}
+		filename := filepath.Join(s.basePath, fmt.Sprintf("vm_%d", record.VMID), fmt.Sprintf("traffic_%s.jsonl", record.Timestamp.Format("2006-01-02")))
+		if _, ok := lines[filename]; !ok {
+			files = append(files, filename)
+		}
+		lines[filename] = append(append(lines[filename], data...), '\n')
 	}
 
+	for _, filename := range files {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return fmt.Errorf("创建虚拟机目录失败: %w", err)
+		}
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("打开流量记录文件失败: %w", err)
+		}
+		_, err = f.Write(lines[filename])
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("写入流量记录失败: %w", err)
+		}
+	}
+
+	s.recordCounter.add(int64(len(records)))
+	s.goBackground(func() { s.recordCounter.save() })
 	return nil
 }
 
@@ -379,6 +518,90 @@ func (s *FileStorage) GetTrafficRecords(vmid int, startTime, endTime time.Time)
 	return allRecords, nil
 }
 
+// SaveNodeTrafficRecord 保存节点流量记录（node_<节点名>/traffic_日期.jsonl，不计入总采样点数）
+func (s *FileStorage) SaveNodeTrafficRecord(node string, record models.TrafficRecord) error {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	nodeDir := filepath.Join(s.basePath, "node_"+node)
+	if err := os.MkdirAll(nodeDir, 0755); err != nil {
+		return fmt.Errorf("创建节点目录失败: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化节点流量记录失败: %w", err)
+	}
+
+	filename := filepath.Join(nodeDir, fmt.Sprintf("traffic_%s.jsonl", record.Timestamp.Format("2006-01-02")))
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开节点流量记录文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入节点流量记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetNodeTrafficRecords 获取节点流量记录
+func (s *FileStorage) GetNodeTrafficRecords(node string, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
+	nodeDir := filepath.Join(s.basePath, "node_"+node)
+
+	var allRecords []models.TrafficRecord
+	for current := startTime; current.Before(endTime.AddDate(0, 0, 1)); current = current.AddDate(0, 0, 1) {
+		filename := filepath.Join(nodeDir, fmt.Sprintf("traffic_%s.jsonl", current.Format("2006-01-02")))
+		if records, err := s.readJSONLFile(filename, startTime, endTime); err == nil {
+			allRecords = append(allRecords, records...)
+		}
+	}
+
+	sort.Slice(allRecords, func(i, j int) bool {
+		return allRecords[i].Timestamp.Before(allRecords[j].Timestamp)
+	})
+	return allRecords, nil
+}
+
+// EarliestRecordTime 获取虚拟机最早的流量记录时间（按日期文件顺序查找第一个有记录的文件）
+func (s *FileStorage) EarliestRecordTime(vmid int) (time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(s.basePath, fmt.Sprintf("vm_%d", vmid), "traffic_*.json*"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	sort.Strings(files)
+
+	end := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	for _, file := range files {
+		var records []models.TrafficRecord
+		switch filepath.Ext(file) {
+		case ".jsonl":
+			records, err = s.readJSONLFile(file, time.Time{}, end)
+		case ".json":
+			records, err = s.readJSONFile(file, time.Time{}, end)
+		default:
+			continue
+		}
+		if err != nil || len(records) == 0 {
+			continue
+		}
+
+		earliest := records[0].Timestamp
+		for _, record := range records[1:] {
+			if record.Timestamp.Before(earliest) {
+				earliest = record.Timestamp
+			}
+		}
+		return earliest, nil
+	}
+
+	return time.Time{}, nil
+}
+
 // readJSONLFile 读取JSONL格式文件（每行一个JSON对象）
 func (s *FileStorage) readJSONLFile(filename string, startTime, endTime time.Time) ([]models.TrafficRecord, error) {
 	data, err := os.ReadFile(filename)
@@ -446,31 +669,15 @@ func (s *FileStorage) CalculateTrafficStatsWithTime(vmid int, period string, cre
 
 // CalculateTrafficStatsWithDirection 使用指定方向和时间计算流量统计
 func (s *FileStorage) CalculateTrafficStatsWithDirection(vmid int, period string, creationTime time.Time, useCreationTime bool, direction string) (*models.TrafficStats, error) {
-	now := time.Now()
-	var startTime time.Time
-
-	if useCreationTime && !creationTime.IsZero() {
-		// 使用基于创建时间的周期计算
-		startTime = s.calculatePeriodStart(period, creationTime, now)
-	} else {
-		// 使用固定周期
-		switch period {
-		case "minute":
-			// minute周期：查询最近5分钟（确保有足够的数据点）
-			// 采集间隔60秒，5分钟内约有5条记录
-			startTime = now.Add(-5 * time.Minute)
-		case "hour":
-			startTime = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
-		case "day":
-			startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		case "month":
-			startTime = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		default:
-			return nil, fmt.Errorf("不支持的时间周期: %s", period)
-		}
+	if !periodcalc.Supported(period) {
+		return nil, fmt.Errorf("不支持的时间周期: %s", period)
 	}
 
-	records, err := s.GetTrafficRecords(vmid, startTime, now)
+	// 周期边界按配置的时区划分（默认主机本地时区）
+	now := time.Now().In(periodcalc.Location())
+	startTime := periodcalc.NewCalculator(period, creationTime, useCreationTime).PeriodStartAt(now)
+
+	records, err := rangeRecords(s.GetTrafficRecords, vmid, startTime, now)
 	if err != nil {
 		return nil, err
 	}
@@ -481,7 +688,7 @@ func (s *FileStorage) CalculateTrafficStatsWithDirection(vmid int, period string
 
 // CalculateTrafficStatsWithTimeRange 使用自定义时间范围计算流量统计
 func (s *FileStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime, endTime time.Time, direction string) (*models.TrafficStats, error) {
-	records, err := s.GetTrafficRecords(vmid, startTime, endTime)
+	records, err := rangeRecords(s.GetTrafficRecords, vmid, startTime, endTime)
 	if err != nil {
 		return nil, err
 	}
@@ -492,6 +699,13 @@ func (s *FileStorage) CalculateTrafficStatsWithTimeRange(vmid int, startTime, en
 
 // SaveActionLog 保存操作日志
 func (s *FileStorage) SaveActionLog(log models.ActionLog) error {
+	// 读取后整体重写，需要排他锁
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	logDir := filepath.Join(s.basePath, "logs")
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("创建日志目录失败: %w", err)
@@ -515,7 +729,7 @@ func (s *FileStorage) SaveActionLog(log models.ActionLog) error {
 		return fmt.Errorf("序列化操作日志失败: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := writeFileAtomic(filename, data, 0644); err != nil {
 		return fmt.Errorf("保存操作日志失败: %w", err)
 	}
 
@@ -574,7 +788,7 @@ func (s *FileStorage) SaveVMState(vmid int, state map[string]interface{}) error
 		return fmt.Errorf("序列化虚拟机状态失败: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := writeFileAtomic(filename, data, 0644); err != nil {
 		return fmt.Errorf("保存虚拟机状态失败: %w", err)
 	}
 
@@ -601,6 +815,85 @@ func (s *FileStorage) LoadVMState(vmid int) (map[string]interface{}, error) {
 	return state, nil
 }
 
+// SaveMetadata 保存运行信息（meta/<名称>.json）
+func (s *FileStorage) SaveMetadata(name string, data []byte) error {
+	metaDir := filepath.Join(s.basePath, "meta")
+	if err := os.MkdirAll(metaDir, 0755); err != nil {
+		return fmt.Errorf("创建运行信息目录失败: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(metaDir, name+".json"), data, 0644); err != nil {
+		return fmt.Errorf("保存运行信息失败: %w", err)
+	}
+	return nil
+}
+
+// LoadMetadata 读取运行信息（不存在时返回 nil）
+func (s *FileStorage) LoadMetadata(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.basePath, "meta", name+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取运行信息失败: %w", err)
+	}
+	return data, nil
+}
+
+// AcquireLease 获取或续期租约（租约保存在 meta/ 目录，读写期间持有存储排他锁）
+// 多台主机共用文件存储时，存储目录所在的网络文件系统需要支持 flock
+func (s *FileStorage) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	acquired := false
+	err := s.withLock(true, func() error {
+		lease, err := s.GetLease(name)
+		if err != nil {
+			return err
+		}
+		if lease.Active(now) && lease.Holder != holder {
+			return nil
+		}
+
+		data, err := json.Marshal(Lease{Holder: holder, ExpiresAt: now.Add(ttl)})
+		if err != nil {
+			return err
+		}
+		if err := s.SaveMetadata(leaseMetadataName(name), data); err != nil {
+			return err
+		}
+		acquired = true
+		return nil
+	})
+	return acquired, err
+}
+
+// ReleaseLease 释放租约
+func (s *FileStorage) ReleaseLease(name, holder string) error {
+	return s.withLock(true, func() error {
+		lease, err := s.GetLease(name)
+		if err != nil || lease == nil || lease.Holder != holder {
+			return err
+		}
+		data, err := json.Marshal(Lease{Holder: holder})
+		if err != nil {
+			return err
+		}
+		return s.SaveMetadata(leaseMetadataName(name), data)
+	})
+}
+
+// GetLease 读取租约
+func (s *FileStorage) GetLease(name string) (*Lease, error) {
+	data, err := s.LoadMetadata(leaseMetadataName(name))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("解析租约失败: %w", err)
+	}
+	return &lease, nil
+}
+
 // CleanupOldData 清理旧数据（删除超过保留期的文件）
 func (s *FileStorage) CleanupOldData(retentionDays int) error {
 	if retentionDays <= 0 {
@@ -618,7 +911,8 @@ func (s *FileStorage) CleanupOldData(retentionDays int) error {
 
 	deletedCount := 0
 	for _, entry := range entries {
-		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "vm_") {
+		// 节点流量记录（node_*）与虚拟机记录使用相同的保留期
+		if !entry.IsDir() || !(strings.HasPrefix(entry.Name(), "vm_") || strings.HasPrefix(entry.Name(), "node_")) {
 			continue
 		}
 
@@ -663,9 +957,171 @@ func (s *FileStorage) CleanupOldData(retentionDays int) error {
 	return nil
 }
 
-// calculatePeriodStart 基于创建时间计算周期开始时间
-func (s *FileStorage) calculatePeriodStart(period string, creationTime, now time.Time) time.Time {
-	return periodcalc.CalculateCreationBasedPeriodStart(period, creationTime, now)
+// DeleteActionLogs 删除时间范围内的操作日志（按天保存，整天都在范围内时直接删除文件）
+func (s *FileStorage) DeleteActionLogs(vmid int, startTime, endTime time.Time, dryRun bool) (int64, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	logDir := filepath.Join(s.basePath, "logs")
+	files, err := os.ReadDir(logDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	var deletedCount int64
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, "actions_") {
+			continue
+		}
+
+		// actions_2006-01-02.json
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSuffix(strings.TrimPrefix(name, "actions_"), ".json"), time.Local)
+		if err != nil || !day.Before(endTime) || (!startTime.IsZero() && !day.AddDate(0, 0, 1).After(startTime)) {
+			continue
+		}
+
+		filename := filepath.Join(logDir, name)
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		var logs []models.ActionLog
+		if err := json.Unmarshal(data, &logs); err != nil {
+			continue
+		}
+
+		kept := make([]models.ActionLog, 0, len(logs))
+		for _, log := range logs {
+			if (vmid == 0 || log.VMID == vmid) && !log.Timestamp.Before(startTime) && log.Timestamp.Before(endTime) {
+				continue
+			}
+			kept = append(kept, log)
+		}
+		removed := len(logs) - len(kept)
+		if removed == 0 {
+			continue
+		}
+		deletedCount += int64(removed)
+		if dryRun {
+			continue
+		}
+
+		if len(kept) == 0 {
+			if err := os.Remove(filename); err != nil {
+				return deletedCount, fmt.Errorf("删除操作日志失败: %w", err)
+			}
+			continue
+		}
+		data, err = json.MarshalIndent(kept, "", "  ")
+		if err != nil {
+			return deletedCount, fmt.Errorf("序列化操作日志失败: %w", err)
+		}
+		if err := writeFileAtomic(filename, data, 0644); err != nil {
+			return deletedCount, fmt.Errorf("保存操作日志失败: %w", err)
+		}
+	}
+
+	if deletedCount > 0 && !dryRun {
+		utils.DebugLog("删除 %d 条操作日志", deletedCount)
+	}
+	return deletedCount, nil
+}
+
+// DeleteVMStates 删除匹配的虚拟机状态文件（最后更新时间为文件修改时间）
+func (s *FileStorage) DeleteVMStates(match func(vmid int, updatedAt time.Time) bool, dryRun bool) (int64, error) {
+	stateDir := filepath.Join(s.basePath, "states")
+	files, err := os.ReadDir(stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("读取状态目录失败: %w", err)
+	}
+
+	var deletedCount int64
+	for _, file := range files {
+		var vmid int
+		if file.IsDir() {
+			continue
+		}
+		if _, err := fmt.Sscanf(file.Name(), "vm_%d_state.json", &vmid); err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil || !match(vmid, info.ModTime()) {
+			continue
+		}
+		if dryRun {
+			deletedCount++
+			continue
+		}
+		if err := os.Remove(filepath.Join(stateDir, file.Name())); err == nil {
+			deletedCount++
+			utils.DebugLog("删除虚拟机状态: VM %d", vmid)
+		}
+	}
+
+	return deletedCount, nil
+}
+
+// Compact 删除虚拟机和节点目录中的空流量文件，以及删除记录后留下的空目录
+func (s *FileStorage) Compact(progress func(CompactProgress)) (CompactResult, error) {
+	var result CompactResult
+	dirs, err := filepath.Glob(filepath.Join(s.basePath, "vm_*"))
+	if err != nil {
+		return result, fmt.Errorf("读取存储目录失败: %w", err)
+	}
+	nodeDirs, _ := filepath.Glob(filepath.Join(s.basePath, "node_*"))
+	dirs = append(dirs, nodeDirs...)
+
+	for i, dir := range dirs {
+		unlock, err := s.lock(true)
+		if err != nil {
+			return result, err
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			unlock()
+			continue
+		}
+
+		remaining := len(files)
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || file.IsDir() {
+				continue
+			}
+			result.BytesBefore += info.Size()
+
+			// 只有空白内容的流量文件不含记录
+			if strings.HasPrefix(file.Name(), "traffic_") && info.Size() <= 1 {
+				path := filepath.Join(dir, file.Name())
+				if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == "" && os.Remove(path) == nil {
+					result.RemovedFiles++
+					remaining--
+					continue
+				}
+			}
+			result.BytesAfter += info.Size()
+		}
+
+		if remaining == 0 && os.Remove(dir) == nil {
+			result.RemovedDirs++
+			utils.DebugLog("删除空目录: %s", dir)
+		}
+		unlock()
+		reportProgress(progress, filepath.Base(dir), i+1, len(dirs))
+	}
+
+	return result, nil
 }
 
 // DeleteRecordsInRange 删除指定时间范围内的记录
@@ -697,11 +1153,8 @@ func (s *FileStorage) DeleteRecordsInRange(vmid int, startTime, endTime time.Tim
 		deletedCount = count
 	}
 
-	// 更新计数器
+	// 计数器已在删除时更新，这里只保存
 	if deletedCount > 0 {
-		s.recordCounter.mu.Lock()
-		s.recordCounter.cachedCount -= deletedCount
-		s.recordCounter.mu.Unlock()
 		s.recordCounter.save()
 	}
 
@@ -710,11 +1163,19 @@ func (s *FileStorage) DeleteRecordsInRange(vmid int, startTime, endTime time.Tim
 
 // deleteRecordsInRangeForVM 删除指定VM目录下时间范围内的记录
 func (s *FileStorage) deleteRecordsInRangeForVM(vmDir string, startTime, endTime time.Time) (int64, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
 	if _, err := os.Stat(vmDir); os.IsNotExist(err) {
 		return 0, nil
 	}
 
+	// 持有锁时更新计数器，避免与后台重新统计交错
 	var deletedCount int64
+	defer func() { s.recordCounter.add(-deletedCount) }()
 
 	// 遍历可能的日期文件
 	current := startTime
@@ -779,25 +1240,18 @@ func (s *FileStorage) readJSONLFileAll(filename string) ([]models.TrafficRecord,
 	return records, nil
 }
 
-// writeJSONLFile 写入JSONL文件
+// writeJSONLFile 写入JSONL文件（原子替换，写入中途崩溃不会损坏原文件）
 func (s *FileStorage) writeJSONLFile(filename string, records []models.TrafficRecord) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
+	var buf []byte
 	for _, record := range records {
 		data, err := json.Marshal(record)
 		if err != nil {
 			return err
 		}
-		if _, err := f.Write(append(data, '\n')); err != nil {
-			return err
-		}
+		buf = append(append(buf, data...), '\n')
 	}
 
-	return nil
+	return writeFileAtomic(filename, buf, 0644)
 }
 
 // CountRecordsInRange 统计指定时间范围内的记录数
@@ -831,6 +1285,46 @@ func (s *FileStorage) CountRecordsInRange(vmid int, startTime, endTime time.Time
 	return count, nil
 }
 
+// RecordVMIDs [startTime, endTime) 内有流量记录的虚拟机（只读取日期在范围附近的文件，startTime 可以为零值）
+func (s *FileStorage) RecordVMIDs(startTime, endTime time.Time) ([]int, error) {
+	vmDirs, err := filepath.Glob(filepath.Join(s.basePath, "vm_*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// 文件按记录自身时区的日期命名，前后各放宽一天
+	first := startTime.AddDate(0, 0, -1).Format("2006-01-02")
+	last := endTime.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var vmids []int
+	for _, vmDir := range vmDirs {
+		vmid, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(vmDir), "vm_"))
+		if err != nil {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(vmDir, "traffic_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "traffic_"), ".jsonl")
+			if date < first || date > last {
+				continue
+			}
+			records, err := s.readJSONLFile(file, startTime, endTime)
+			if err != nil {
+				continue
+			}
+			if slices.ContainsFunc(records, func(r models.TrafficRecord) bool { return r.Timestamp.Before(endTime) }) {
+				vmids = append(vmids, vmid)
+				break
+			}
+		}
+	}
+	sort.Ints(vmids)
+	return vmids, nil
+}
+
 // countRecordsInRangeForVM 统计指定VM目录下时间范围内的记录数
 func (s *FileStorage) countRecordsInRangeForVM(vmDir string, startTime, endTime time.Time) (int64, error) {
 	if _, err := os.Stat(vmDir); os.IsNotExist(err) {
@@ -879,6 +1373,13 @@ func (s *FileStorage) DeleteRecordsBefore(beforeTime time.Time) (int64, error) {
 			continue
 		}
 
+		// 按虚拟机目录加排他锁，避免重写文件时丢失主程序同时追加的记录
+		unlock, err := s.lock(true)
+		if err != nil {
+			return deletedCount, err
+		}
+		vmStart := deletedCount
+
 		for _, file := range files {
 			// 从文件名提取日期
 			basename := filepath.Base(file)
@@ -920,13 +1421,12 @@ func (s *FileStorage) DeleteRecordsBefore(beforeTime time.Time) (int64, error) {
 				}
 			}
 		}
+		// 持有锁时更新计数器，避免与后台重新统计交错
+		s.recordCounter.add(vmStart - deletedCount)
+		unlock()
 	}
 
-	// 更新计数器
 	if deletedCount > 0 {
-		s.recordCounter.mu.Lock()
-		s.recordCounter.cachedCount -= deletedCount
-		s.recordCounter.mu.Unlock()
 		s.recordCounter.save()
 	}
 
@@ -0,0 +1,145 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+// Resolver 根据映射表或 PVE 标签确定虚拟机所属客户
+type Resolver struct {
+	tagPrefix string
+	byVM      map[int]string // 显式映射: VMID -> 客户
+}
+
+// NewResolver 创建客户解析器（合并映射文件和内联映射）
+func NewResolver(cfg models.TenantConfig) (*Resolver, error) {
+	r := &Resolver{
+		tagPrefix: strings.ToLower(strings.TrimSpace(cfg.TagPrefix)),
+		byVM:      make(map[int]string),
+	}
+
+	mapping := make(map[string][]int)
+	if cfg.MappingFile != "" {
+		data, err := os.ReadFile(cfg.MappingFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户映射文件失败: %w", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return nil, fmt.Errorf("解析客户映射文件失败: %w", err)
+		}
+	}
+	for tenant, vmids := range cfg.Mapping {
+		mapping[tenant] = append(mapping[tenant], vmids...)
+	}
+
+	for tenant, vmids := range mapping {
+		if tenant == "" {
+			return nil, fmt.Errorf("客户名称不能为空")
+		}
+		for _, vmid := range vmids {
+			if existing, ok := r.byVM[vmid]; ok && existing != tenant {
+				return nil, fmt.Errorf("虚拟机 %d 同时属于客户 %s 和 %s", vmid, existing, tenant)
+			}
+			r.byVM[vmid] = tenant
+		}
+	}
+
+	return r, nil
+}
+
+// Enabled 是否配置了客户划分
+func (r *Resolver) Enabled() bool {
+	return r != nil && (r.tagPrefix != "" || len(r.byVM) > 0)
+}
+
+// TenantOf 获取虚拟机所属客户（未划分返回空字符串）
+func (r *Resolver) TenantOf(vm models.VMInfo) string {
+	if r == nil {
+		return ""
+	}
+	if tenant, ok := r.byVM[vm.VMID]; ok {
+		return tenant
+	}
+	if r.tagPrefix == "" {
+		return ""
+	}
+
+	// PVE 标签不区分大小写
+	for _, tag := range vm.Tags {
+		tag = strings.ToLower(tag)
+		if strings.HasPrefix(tag, r.tagPrefix) && len(tag) > len(r.tagPrefix) {
+			return tag[len(r.tagPrefix):]
+		}
+	}
+	return ""
+}
+
+// Annotate 为虚拟机列表填充 Tenant 字段
+func (r *Resolver) Annotate(vms []models.VMInfo) []models.VMInfo {
+	result := make([]models.VMInfo, len(vms))
+	for i, vm := range vms {
+		result[i] = vm
+		result[i].Tenant = r.TenantOf(vm)
+	}
+	return result
+}
+
+// Filter 仅保留属于指定客户的虚拟机
+func (r *Resolver) Filter(vms []models.VMInfo, tenant string) []models.VMInfo {
+	result := make([]models.VMInfo, 0, len(vms))
+	for _, vm := range vms {
+		if r.TenantOf(vm) == tenant {
+			result = append(result, vm)
+		}
+	}
+	return result
+}
+
+// Group 按客户分组（未划分客户的虚拟机不包含在内），结果按客户名排序
+func (r *Resolver) Group(vms []models.VMInfo) ([]string, map[string][]models.VMInfo) {
+	groups := make(map[string][]models.VMInfo)
+	for _, vm := range vms {
+		if tenant := r.TenantOf(vm); tenant != "" {
+			groups[tenant] = append(groups[tenant], vm)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, groups
+}
+
+// Aggregate 汇总客户所有虚拟机的流量统计
+func Aggregate(tenant string, stats []models.TrafficStats) models.TenantStats {
+	result := models.TenantStats{
+		Tenant: tenant,
+		VMIDs:  []int{},
+	}
+
+	for i, stat := range stats {
+		if i == 0 || stat.StartTime.Before(result.StartTime) {
+			result.StartTime = stat.StartTime
+		}
+		if stat.EndTime.After(result.EndTime) {
+			result.EndTime = stat.EndTime
+		}
+		result.Period = stat.Period
+		result.Direction = stat.Direction
+		result.VMIDs = append(result.VMIDs, stat.VMID)
+		result.RXBytes += stat.RXBytes
+		result.TXBytes += stat.TXBytes
+		result.TotalBytes += stat.TotalBytes
+	}
+
+	sort.Ints(result.VMIDs)
+	result.TotalGB = float64(result.TotalBytes) / models.BytesPerGB
+	return result
+}
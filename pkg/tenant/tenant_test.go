@@ -0,0 +1,63 @@
+package tenant
+
+import (
+	"testing"
+
+	"pve-traffic-monitor/pkg/models"
+)
+
+func TestResolverTenantOf(t *testing.T) {
+	r, err := NewResolver(models.TenantConfig{
+		TagPrefix: "Customer-",
+		Mapping:   map[string][]int{"acme": {100, 101}},
+	})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		vm   models.VMInfo
+		want string
+	}{
+		{"explicit mapping", models.VMInfo{VMID: 100}, "acme"},
+		{"mapping wins over tag", models.VMInfo{VMID: 101, Tags: []string{"customer-globex"}}, "acme"},
+		{"tag prefix case insensitive", models.VMInfo{VMID: 200, Tags: []string{"web", "CUSTOMER-Globex"}}, "globex"},
+		{"bare prefix ignored", models.VMInfo{VMID: 201, Tags: []string{"customer-"}}, ""},
+		{"unassigned", models.VMInfo{VMID: 202, Tags: []string{"web"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := r.TenantOf(tt.vm); got != tt.want {
+			t.Fatalf("%s: TenantOf() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	names, groups := r.Group([]models.VMInfo{{VMID: 100}, {VMID: 200, Tags: []string{"customer-globex"}}, {VMID: 300}})
+	if len(names) != 2 || names[0] != "acme" || names[1] != "globex" || len(groups["acme"]) != 1 {
+		t.Fatalf("Group() = %v %v, want acme and globex with one VM each", names, groups)
+	}
+}
+
+func TestNewResolverConflict(t *testing.T) {
+	_, err := NewResolver(models.TenantConfig{
+		Mapping: map[string][]int{"acme": {100}, "globex": {100}},
+	})
+	if err == nil {
+		t.Fatalf("NewResolver() error = nil, want conflict error")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	stats := []models.TrafficStats{
+		{VMID: 101, Period: models.PeriodMonth, RXBytes: 1, TXBytes: 2, TotalBytes: 3},
+		{VMID: 100, Period: models.PeriodMonth, RXBytes: 10, TXBytes: 20, TotalBytes: 30},
+	}
+
+	got := Aggregate("acme", stats)
+	if got.TotalBytes != 33 || got.RXBytes != 11 || got.TXBytes != 22 {
+		t.Fatalf("Aggregate() bytes = %+v, want rx 11 / tx 22 / total 33", got)
+	}
+	if len(got.VMIDs) != 2 || got.VMIDs[0] != 100 {
+		t.Fatalf("Aggregate().VMIDs = %v, want [100 101]", got.VMIDs)
+	}
+}
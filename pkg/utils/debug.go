@@ -3,11 +3,29 @@ package utils
 import (
 	"log"
 	"os"
+	"sync/atomic"
 )
 
+// debugEnabled 调试日志开关（初始值来自 DEBUG 环境变量，运行时可通过 SIGUSR2 切换）
+var debugEnabled atomic.Bool
+
+func init() {
+	debugEnabled.Store(os.Getenv("DEBUG") == "1" || os.Getenv("DEBUG") == "true")
+}
+
+// DebugEnabled 是否启用调试日志
+func DebugEnabled() bool {
+	return debugEnabled.Load()
+}
+
+// SetDebug 设置调试日志开关
+func SetDebug(enabled bool) {
+	debugEnabled.Store(enabled)
+}
+
 // DebugLog 输出调试日志
 func DebugLog(format string, args ...interface{}) {
-	if os.Getenv("DEBUG") == "1" || os.Getenv("DEBUG") == "true" {
+	if debugEnabled.Load() {
 		log.Printf("[DEBUG] "+format, args...)
 	}
 }
@@ -72,6 +72,16 @@ export const api = {
     return request.get(`/history/${vmid}`, { params })
   },
 
+  // 获取网桥和 SDN VNet 流量汇总
+  getNetworks(params) {
+    return request.get('/networks', { params })
+  },
+
+  // 获取网络历史数据
+  getNetworkHistory(name, params) {
+    return request.get(`/networks/${encodeURIComponent(name)}/history`, { params })
+  },
+
   // 获取系统统计
   getSystemStats() {
     return request.get('/system/stats')
@@ -19,6 +19,12 @@ const routes = [
         component: () => import('@/views/Charts.vue'),
         meta: { title: 'Charts' }
       },
+      {
+        path: 'networks',
+        name: 'Networks',
+        component: () => import('@/views/Networks.vue'),
+        meta: { title: 'Networks' }
+      },
       {
         path: 'vm/:id',
         name: 'VMDetail',
@@ -218,8 +218,10 @@ export function createTrafficLineChart(data, isDark, t) {
 /**
  * 创建VM详情时间序列图表配置（横坐标为时间）
  */
-export function createVMTimeSeriesChart(historyData, isDark, t) {
+export function createVMTimeSeriesChart(historyData, isDark, t, names = {}, downtime = [], powerEvents = []) {
   const colors = getChartColors(isDark)
+  // 磁盘读写图表复用同一格式（rx=读取，tx=写入），只替换图例和坐标轴名称
+  const { rx = t('charts.download'), tx = t('charts.upload'), axis = 'Traffic' } = names
 
   if (!historyData || historyData.length === 0) {
     return {}
@@ -231,9 +233,11 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
   // 智能选择单位
   const { divisor, unit } = smartConvertBytes(historyData)
 
-  const rxData = historyData.map(item => Number((item.rx_bytes / divisor).toFixed(3)))
-  const txData = historyData.map(item => Number((item.tx_bytes / divisor).toFixed(3)))
-  const totalData = historyData.map(item => Number((item.total_bytes / divisor).toFixed(3)))
+  // 没有采样的时间段（fill=null）为 null，折线断开
+  const toUnit = value => (value == null ? null : Number((value / divisor).toFixed(3)))
+  const rxData = historyData.map(item => toUnit(item.rx_bytes))
+  const txData = historyData.map(item => toUnit(item.tx_bytes))
+  const totalData = historyData.map(item => toUnit(item.total_bytes))
 
   return {
     backgroundColor: 'transparent',
@@ -258,6 +262,10 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
         if (!params || params.length === 0) return ''
         let result = params[0].axisValue + '<br/>'
         params.forEach(item => {
+          if (item.value == null) {
+            result += item.marker + ' ' + item.seriesName + ': -<br/>'
+            return
+          }
           const value = Number(item.value)
           result += item.marker + ' ' + item.seriesName + ': ' + value.toFixed(3) + ' ' + unit + '<br/>'
         })
@@ -285,7 +293,7 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
     },
     yAxis: {
       type: 'value',
-      name: `Traffic (${unit})`,
+      name: `${axis} (${unit})`,
       axisLine: {
         lineStyle: {
           color: colors.axisLine
@@ -303,7 +311,7 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
     },
     series: [
       {
-        name: t('charts.download'),
+        name: rx,
         type: 'line',
         data: rxData,
         smooth: true,
@@ -328,7 +336,7 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
         }
       },
       {
-        name: t('charts.upload'),
+        name: tx,
         type: 'line',
         data: txData,
         smooth: true,
@@ -362,6 +370,40 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
         },
         lineStyle: {
           width: 3
+        },
+        // 没有采样的时间段（虚拟机停止、监控中断）
+        markArea: {
+          silent: true,
+          itemStyle: {
+            color: colors.splitLine + '80'
+          },
+          label: {
+            color: colors.textColor,
+            fontSize: 11
+          },
+          data: downtime.map(d => [
+            { name: t('vmDetail.downtime.' + d.reason), xAxis: d.from },
+            { xAxis: d.to }
+          ])
+        },
+        // 运行状态变化（开机、关机），便于把流量下降与重启对应起来
+        markLine: {
+          silent: true,
+          symbol: 'none',
+          lineStyle: {
+            color: colors.textColor,
+            type: 'dashed',
+            width: 1
+          },
+          label: {
+            color: colors.textColor,
+            fontSize: 11,
+            formatter: params => params.name
+          },
+          data: powerEvents.map(e => ({
+            name: t('vmDetail.power.' + (e.to === 'running' ? 'started' : 'stopped')),
+            xAxis: e.at
+          }))
         }
       }
     ]
@@ -369,9 +411,18 @@ export function createVMTimeSeriesChart(historyData, isDark, t) {
 }
 
 /**
- * 创建Top虚拟机柱状图配置
+ * 虚拟机标签（柱状图横坐标）
  */
-export function createTopVMsBarChart(data, isDark, t) {
+function vmLabel(s) {
+  const vmid = s.vmid || 'N/A'
+  const name = s.name || 'N/A'
+  return `VM${vmid} (${name})`
+}
+
+/**
+ * 创建Top虚拟机柱状图配置（labelOf 自定义横坐标，如网络名称）
+ */
+export function createTopVMsBarChart(data, isDark, t, labelOf = vmLabel) {
   const colors = getChartColors(isDark)
 
   console.log('Top VMs chart data:', data)
@@ -380,11 +431,7 @@ export function createTopVMsBarChart(data, isDark, t) {
 
   const { divisor, unit } = smartConvertBytes(sorted)
 
-  const labels = sorted.map(s => {
-    const vmid = s.vmid || 'N/A'
-    const name = s.name || 'N/A'
-    return `VM${vmid} (${name})`
-  })
+  const labels = sorted.map(labelOf)
   const values = sorted.map(s => Number((s.total_bytes / divisor).toFixed(3)))
 
   return {